@@ -0,0 +1,563 @@
+// cmd/dns-bench/main.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// options holds the command line configuration
+type options struct {
+	server      string
+	network     string
+	duration    time.Duration
+	qpsStart    float64
+	qpsEnd      float64
+	ramp        time.Duration
+	concurrency int
+	timeout     time.Duration
+	types       string
+	zones       string
+	namesFile   string
+	hitRatio    float64
+	interval    time.Duration
+	jsonOutput  bool
+	maxP99      time.Duration
+	maxErrRate  float64
+}
+
+// query is a single name/type pair to send
+type query struct {
+	name  string
+	qtype uint16
+}
+
+// result is the outcome of a single query
+type result struct {
+	latency time.Duration
+	rcode   int
+	err     error
+}
+
+// Summary is the final report, also emitted as JSON with -json
+type Summary struct {
+	Server      string         `json:"server"`
+	Duration    float64        `json:"duration_seconds"`
+	Sent        int64          `json:"sent"`
+	Received    int64          `json:"received"`
+	Timeouts    int64          `json:"timeouts"`
+	Errors      int64          `json:"errors"`
+	Skipped     int64          `json:"skipped"` // not sent because all workers were busy
+	AchievedQPS float64        `json:"achieved_qps"`
+	ErrorRate   float64        `json:"error_rate"`
+	Rcodes      map[string]int `json:"rcodes"`
+	Latency     LatencySummary `json:"latency_ms"`
+	Passed      bool           `json:"passed"`
+	Failures    []string       `json:"failures,omitempty"`
+}
+
+// LatencySummary holds latency percentiles in milliseconds
+type LatencySummary struct {
+	Min  float64 `json:"min"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p999"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+func main() {
+	opts := parseFlags()
+
+	mix, err := parseTypeMix(opts.types)
+	if err != nil {
+		fatalf("invalid -types: %v", err)
+	}
+
+	zones := splitList(opts.zones)
+	if len(zones) == 0 {
+		fatalf("-zones must list at least one zone")
+	}
+
+	known, err := loadNames(opts.namesFile, zones, mix)
+	if err != nil {
+		fatalf("failed to load names: %v", err)
+	}
+
+	gen := &generator{
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		known:    known,
+		zones:    zones,
+		mix:      mix,
+		hitRatio: opts.hitRatio,
+	}
+
+	summary := run(opts, gen)
+
+	if opts.jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(summary)
+	} else {
+		printSummary(summary)
+	}
+
+	if !summary.Passed {
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *options {
+	opts := &options{}
+	flag.StringVar(&opts.server, "server", "127.0.0.1:5353", "DNS server address (host:port)")
+	flag.StringVar(&opts.network, "net", "udp", "transport: udp or tcp")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "total test duration")
+	flag.Float64Var(&opts.qpsStart, "qps", 1000, "starting queries per second")
+	flag.Float64Var(&opts.qpsEnd, "qps-end", 0, "final queries per second for a linear ramp (0 = constant rate)")
+	flag.DurationVar(&opts.ramp, "ramp", 0, "time to ramp from -qps to -qps-end (default: whole duration)")
+	flag.IntVar(&opts.concurrency, "concurrency", 50, "number of concurrent workers")
+	flag.DurationVar(&opts.timeout, "timeout", 2*time.Second, "per-query timeout")
+	flag.StringVar(&opts.types, "types", "A=60,AAAA=20,MX=5,TXT=5,NS=5,SRV=5", "query type mix as TYPE=weight pairs")
+	flag.StringVar(&opts.zones, "zones", "test.internal", "comma-separated zones to query")
+	flag.StringVar(&opts.namesFile, "names", "", `file of known names, one "name TYPE" per line (e.g. testing/stress.dat)`)
+	flag.Float64Var(&opts.hitRatio, "hit-ratio", 0.9, "fraction of queries for known names; the rest are random names that miss every cache")
+	flag.DurationVar(&opts.interval, "interval", time.Second, "progress report interval (0 disables)")
+	flag.BoolVar(&opts.jsonOutput, "json", false, "print the final summary as JSON")
+	flag.DurationVar(&opts.maxP99, "max-p99", 0, "fail (exit 1) if p99 latency exceeds this")
+	flag.Float64Var(&opts.maxErrRate, "max-error-rate", 0, "fail (exit 1) if the timeout+error fraction exceeds this")
+	flag.Parse()
+
+	if opts.network != "udp" && opts.network != "tcp" {
+		fatalf("-net must be udp or tcp")
+	}
+	if opts.qpsStart <= 0 || opts.concurrency <= 0 || opts.duration <= 0 {
+		fatalf("-qps, -concurrency, and -duration must be greater than 0")
+	}
+	if opts.hitRatio < 0 || opts.hitRatio > 1 {
+		fatalf("-hit-ratio must be between 0 and 1")
+	}
+	if opts.qpsEnd <= 0 {
+		opts.qpsEnd = opts.qpsStart
+	}
+	if opts.ramp <= 0 || opts.ramp > opts.duration {
+		opts.ramp = opts.duration
+	}
+	return opts
+}
+
+// typeWeight is one entry of the query type mix
+type typeWeight struct {
+	qtype  uint16
+	weight int
+}
+
+// parseTypeMix parses "A=60,AAAA=20" into weighted types
+func parseTypeMix(spec string) ([]typeWeight, error) {
+	var mix []typeWeight
+	for _, item := range splitList(spec) {
+		name, weightString, found := strings.Cut(item, "=")
+		weight := 1
+		if found {
+			w, err := strconv.Atoi(weightString)
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("bad weight in %q", item)
+			}
+			weight = w
+		}
+		qtype, ok := dns.StringToType[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q", name)
+		}
+		if weight > 0 {
+			mix = append(mix, typeWeight{qtype: qtype, weight: weight})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("no types with positive weight")
+	}
+	return mix, nil
+}
+
+// loadNames reads known names from file, or synthesizes one per zone and type
+func loadNames(path string, zones []string, mix []typeWeight) ([]query, error) {
+	if path == "" {
+		var known []query
+		for _, zone := range zones {
+			for _, tw := range mix {
+				known = append(known, query{name: dns.Fqdn(zone), qtype: tw.qtype})
+			}
+		}
+		return known, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var known []query
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		qtype := dns.TypeA
+		if len(fields) > 1 {
+			t, ok := dns.StringToType[strings.ToUpper(fields[1])]
+			if !ok {
+				return nil, fmt.Errorf("unknown type %q for %s", fields[1], fields[0])
+			}
+			qtype = t
+		}
+		known = append(known, query{name: dns.Fqdn(fields[0]), qtype: qtype})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(known) == 0 {
+		return nil, fmt.Errorf("%s contains no names", path)
+	}
+	return known, nil
+}
+
+// generator produces the query stream. It is only used by the pacer goroutine
+type generator struct {
+	rng      *rand.Rand
+	known    []query
+	zones    []string
+	mix      []typeWeight
+	hitRatio float64
+}
+
+// next returns a known name with probability hitRatio, otherwise a random
+// name under one of the zones that no cache can have seen
+func (g *generator) next() query {
+	if g.rng.Float64() < g.hitRatio {
+		return g.known[g.rng.Intn(len(g.known))]
+	}
+
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	label := make([]byte, 12)
+	for i := range label {
+		label[i] = letters[g.rng.Intn(len(letters))]
+	}
+	zone := g.zones[g.rng.Intn(len(g.zones))]
+	return query{name: dns.Fqdn(string(label) + "." + zone), qtype: g.pickType()}
+}
+
+// pickType draws a type from the weighted mix
+func (g *generator) pickType() uint16 {
+	total := 0
+	for _, tw := range g.mix {
+		total += tw.weight
+	}
+	n := g.rng.Intn(total)
+	for _, tw := range g.mix {
+		if n < tw.weight {
+			return tw.qtype
+		}
+		n -= tw.weight
+	}
+	return g.mix[0].qtype
+}
+
+// collector accumulates results from all workers
+type collector struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	interval  []time.Duration
+	rcodes    map[string]int
+
+	sent     int64
+	received int64
+	timeouts int64
+	errors   int64
+	skipped  int64
+}
+
+func (c *collector) add(r result) {
+	if r.err != nil {
+		if netErr, ok := r.err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
+			atomic.AddInt64(&c.timeouts, 1)
+		} else {
+			atomic.AddInt64(&c.errors, 1)
+		}
+		return
+	}
+
+	atomic.AddInt64(&c.received, 1)
+	c.mu.Lock()
+	c.latencies = append(c.latencies, r.latency)
+	c.interval = append(c.interval, r.latency)
+	c.rcodes[dns.RcodeToString[r.rcode]]++
+	c.mu.Unlock()
+}
+
+// takeInterval returns and resets latencies since the last call
+func (c *collector) takeInterval() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	interval := c.interval
+	c.interval = nil
+	return interval
+}
+
+// run drives the load test and returns the summary
+func run(opts *options, gen *generator) *Summary {
+	results := &collector{rcodes: make(map[string]int)}
+	jobs := make(chan query, opts.concurrency)
+
+	var workers sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(opts, jobs, results)
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	start := time.Now()
+	deadline := start.Add(opts.duration)
+
+	var reporter *time.Ticker
+	var reports <-chan time.Time
+	if opts.interval > 0 && !opts.jsonOutput {
+		reporter = time.NewTicker(opts.interval)
+		defer reporter.Stop()
+		reports = reporter.C
+		fmt.Printf("%-8s %-10s %-10s %-10s %-10s %-10s\n", "elapsed", "target", "sent/s", "p50", "p99", "timeouts")
+	}
+
+	var lastSent, lastTimeouts int64
+	lastReport := start
+	next := start
+
+pacing:
+	for {
+		now := time.Now()
+		if now.After(deadline) {
+			break
+		}
+
+		select {
+		case <-stop:
+			break pacing
+		case <-reports:
+			sent := atomic.LoadInt64(&results.sent)
+			timeouts := atomic.LoadInt64(&results.timeouts)
+			elapsed := now.Sub(lastReport).Seconds()
+			p := percentiles(results.takeInterval())
+			fmt.Printf("%-8s %-10.0f %-10.0f %-10.2f %-10.2f %-10d\n",
+				now.Sub(start).Round(time.Second), currentRate(opts, now.Sub(start)),
+				float64(sent-lastSent)/elapsed, p.P50, p.P99, timeouts-lastTimeouts)
+			lastSent, lastTimeouts, lastReport = sent, timeouts, now
+		default:
+		}
+
+		if wait := next.Sub(now); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		select {
+		case jobs <- gen.next():
+			atomic.AddInt64(&results.sent, 1)
+		default:
+			atomic.AddInt64(&results.skipped, 1)
+		}
+
+		next = next.Add(time.Duration(float64(time.Second) / currentRate(opts, time.Since(start))))
+		// Don't try to catch up after a long stall; keep the rate honest
+		if behind := time.Since(next); behind > time.Second {
+			next = time.Now()
+		}
+	}
+
+	close(jobs)
+	workers.Wait()
+	elapsed := time.Since(start)
+
+	return summarize(opts, results, elapsed)
+}
+
+// currentRate returns the target QPS at elapsed time into the test
+func currentRate(opts *options, elapsed time.Duration) float64 {
+	if elapsed >= opts.ramp {
+		return opts.qpsEnd
+	}
+	fraction := float64(elapsed) / float64(opts.ramp)
+	return opts.qpsStart + (opts.qpsEnd-opts.qpsStart)*fraction
+}
+
+// worker sends queries over a single long-lived connection
+func worker(opts *options, jobs <-chan query, results *collector) {
+	client := &dns.Client{Net: opts.network, Timeout: opts.timeout}
+
+	var conn *dns.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for q := range jobs {
+		if conn == nil {
+			var err error
+			if conn, err = client.Dial(opts.server); err != nil {
+				results.add(result{err: err})
+				continue
+			}
+		}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(q.name, q.qtype)
+
+		reply, rtt, err := client.ExchangeWithConn(msg, conn)
+		if err != nil {
+			// Start over on a fresh connection so late replies can't be
+			// mistaken for answers to later queries
+			conn.Close()
+			conn = nil
+			results.add(result{err: err})
+			continue
+		}
+		results.add(result{latency: rtt, rcode: reply.Rcode})
+	}
+}
+
+// summarize builds the final report and evaluates pass/fail thresholds
+func summarize(opts *options, results *collector, elapsed time.Duration) *Summary {
+	summary := &Summary{
+		Server:   opts.server,
+		Duration: elapsed.Seconds(),
+		Sent:     atomic.LoadInt64(&results.sent),
+		Received: atomic.LoadInt64(&results.received),
+		Timeouts: atomic.LoadInt64(&results.timeouts),
+		Errors:   atomic.LoadInt64(&results.errors),
+		Skipped:  atomic.LoadInt64(&results.skipped),
+		Rcodes:   results.rcodes,
+		Latency:  percentiles(results.latencies),
+		Passed:   true,
+	}
+
+	summary.AchievedQPS = float64(summary.Received) / elapsed.Seconds()
+	if summary.Sent > 0 {
+		summary.ErrorRate = float64(summary.Timeouts+summary.Errors) / float64(summary.Sent)
+	}
+
+	if opts.maxP99 > 0 && summary.Latency.P99 > toMillis(opts.maxP99) {
+		summary.Passed = false
+		summary.Failures = append(summary.Failures,
+			fmt.Sprintf("p99 %.2fms exceeds %.2fms", summary.Latency.P99, toMillis(opts.maxP99)))
+	}
+	if opts.maxErrRate > 0 && summary.ErrorRate > opts.maxErrRate {
+		summary.Passed = false
+		summary.Failures = append(summary.Failures,
+			fmt.Sprintf("error rate %.4f exceeds %.4f", summary.ErrorRate, opts.maxErrRate))
+	}
+
+	return summary
+}
+
+// percentiles computes a latency summary over samples
+func percentiles(samples []time.Duration) LatencySummary {
+	if len(samples) == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	at := func(p float64) float64 {
+		index := int(float64(len(sorted))*p+0.5) - 1
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(sorted) {
+			index = len(sorted) - 1
+		}
+		return toMillis(sorted[index])
+	}
+
+	return LatencySummary{
+		Min:  toMillis(sorted[0]),
+		P50:  at(0.50),
+		P90:  at(0.90),
+		P99:  at(0.99),
+		P999: at(0.999),
+		Max:  toMillis(sorted[len(sorted)-1]),
+		Mean: toMillis(total / time.Duration(len(sorted))),
+	}
+}
+
+func printSummary(s *Summary) {
+	fmt.Println()
+	fmt.Printf("=== dns-bench results: %s ===\n", s.Server)
+	fmt.Printf("Duration:      %.1fs\n", s.Duration)
+	fmt.Printf("Sent:          %d (skipped %d with all workers busy)\n", s.Sent, s.Skipped)
+	fmt.Printf("Received:      %d (%.0f qps)\n", s.Received, s.AchievedQPS)
+	fmt.Printf("Timeouts:      %d\n", s.Timeouts)
+	fmt.Printf("Errors:        %d\n", s.Errors)
+	fmt.Printf("Error rate:    %.4f\n", s.ErrorRate)
+
+	codes := make([]string, 0, len(s.Rcodes))
+	for code := range s.Rcodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Printf("  %-12s %d\n", code, s.Rcodes[code])
+	}
+
+	fmt.Printf("Latency (ms):  min %.2f  p50 %.2f  p90 %.2f  p99 %.2f  p99.9 %.2f  max %.2f  mean %.2f\n",
+		s.Latency.Min, s.Latency.P50, s.Latency.P90, s.Latency.P99, s.Latency.P999, s.Latency.Max, s.Latency.Mean)
+
+	if s.Passed {
+		fmt.Println("Result:        PASS")
+	} else {
+		fmt.Printf("Result:        FAIL (%s)\n", strings.Join(s.Failures, "; "))
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dns-bench: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,177 @@
+// cmd/dns-cache/main.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"errantdns.io/internal/admin"
+	"errantdns.io/internal/storage"
+)
+
+const usage = `usage: dns-cache [-admin URL] <command> [flags]
+
+commands:
+  entries [-tier T] [-name NAME | -apex DOMAIN] [-type TYPE] [-limit N] [-offset N] [-json]
+                                  list cached entries
+  flush -name NAME [-type TYPE]   drop cached answers for a name (every type without -type)
+  flush -apex DOMAIN              drop cached answers for a domain and every name below it
+  flush -all                      drop every cached answer
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	adminURL := flag.String("admin", "http://127.0.0.1:8053", "admin server URL")
+	timeout := flag.Duration("timeout", 30*time.Second, "request timeout")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch command, args := flag.Arg(0), flag.Args()[1:]; command {
+	case "entries":
+		entries(ctx, *adminURL, args)
+	case "flush":
+		flush(ctx, *adminURL, args)
+	default:
+		fatalf("unknown command %q", command)
+	}
+}
+
+// entries lists what the server caches, one entry per line
+func entries(ctx context.Context, adminURL string, args []string) {
+	flags := flag.NewFlagSet("entries", flag.ExitOnError)
+	tier := flags.String("tier", "", "only list this tier: L0 (wire), L1 (memory), L2 (Redis), or stale")
+	name := flags.String("name", "", "only list entries for this name")
+	apex := flags.String("apex", "", "only list entries for this domain and the names below it")
+	recordType := flags.String("type", "", "only list entries of this record type")
+	limit := flags.Int("limit", 100, "entries per page")
+	offset := flags.Int("offset", 0, "entries to skip")
+	jsonOutput := flags.Bool("json", false, "print the page as JSON")
+	flags.Parse(args)
+
+	query := url.Values{}
+	for param, value := range map[string]string{"tier": *tier, "name": *name, "apex": *apex, "type": *recordType} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+	query.Set("limit", strconv.Itoa(*limit))
+	query.Set("offset", strconv.Itoa(*offset))
+
+	var page admin.CacheEntriesPage
+	if err := get(ctx, adminURL, "/cache/entries?"+query.Encode(), &page); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(page)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIER\tKEY\tRECORDS\tTTL\tHITS")
+	for _, entry := range page.Entries {
+		fmt.Fprintf(writer, "%s\t%s\t%d\t%ds\t%d\n", entry.Tier, entry.Key, entry.Records, entry.TTLRemaining, entry.Hits)
+	}
+	writer.Flush()
+	fmt.Printf("\n%d-%d of %d entries\n", min(page.Offset+1, page.Total), page.Offset+len(page.Entries), page.Total)
+}
+
+// flush asks the server to drop cached answers on every tier and node
+func flush(ctx context.Context, adminURL string, args []string) {
+	flags := flag.NewFlagSet("flush", flag.ExitOnError)
+	var f storage.Flush
+	flags.StringVar(&f.Name, "name", "", "name to flush")
+	flags.StringVar(&f.Type, "type", "", "record type to flush (with -name; default every type)")
+	flags.StringVar(&f.Apex, "apex", "", "domain to flush along with every name below it")
+	flags.BoolVar(&f.All, "all", false, "flush everything")
+	flags.Parse(args)
+
+	if err := f.Validate(); err != nil {
+		fatalf("flush: %v", err)
+	}
+
+	if err := post(ctx, adminURL, "/cache/flush", f); err != nil {
+		fatalf("%v", err)
+	}
+	fmt.Printf("Flushed %s\n", f)
+}
+
+// get decodes the JSON response of an admin endpoint into v, failing on
+// any status but 200 OK
+func get(ctx context.Context, adminURL, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(adminURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// post sends body as JSON to an admin endpoint, failing on any status but
+// 200 OK
+func post(ctx context.Context, adminURL, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(adminURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s returned HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dns-cache: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,307 @@
+// cmd/dns-dig/main.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const usage = `usage: dns-dig [flags] <name> [type]
+
+Sends one query and prints the whole response: header, EDNS options,
+question, answer, authority, and additional sections.
+
+Exits with status 1 if the response doesn't meet an -expect-* condition,
+and 2 if the query fails.
+`
+
+// listFlag collects every value of a repeated flag
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ", ") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+// options holds the command line configuration
+type options struct {
+	server   string
+	proto    string
+	timeout  time.Duration
+	insecure bool
+	sni      string
+
+	recurse bool
+	dnssec  bool
+	bufsize uint
+	nsid    bool
+	subnet  string
+	noEDNS  bool
+	short   bool
+
+	expectRcode string
+	expectCount int
+	expect      listFlag
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	var opts options
+	flag.StringVar(&opts.server, "server", "", "server address (host:port), or URL for DoH (default 127.0.0.1 on the protocol's port)")
+	flag.StringVar(&opts.proto, "proto", "udp", "transport: udp, tcp, dot (DNS over TLS), or doh (DNS over HTTPS)")
+	flag.DurationVar(&opts.timeout, "timeout", 5*time.Second, "query timeout")
+	flag.BoolVar(&opts.insecure, "insecure", false, "don't verify the server's TLS certificate (dot, doh)")
+	flag.StringVar(&opts.sni, "sni", "", "TLS server name (dot, doh; default the server's host)")
+	flag.BoolVar(&opts.recurse, "rd", false, "set the recursion desired flag")
+	flag.BoolVar(&opts.dnssec, "dnssec", false, "set the DNSSEC OK bit")
+	flag.UintVar(&opts.bufsize, "bufsize", 1232, "EDNS UDP payload size")
+	flag.BoolVar(&opts.nsid, "nsid", false, "request the server's NSID")
+	flag.StringVar(&opts.subnet, "subnet", "", "send an EDNS client subnet, e.g. 192.0.2.0/24")
+	flag.BoolVar(&opts.noEDNS, "noedns", false, "send the query without EDNS")
+	flag.BoolVar(&opts.short, "short", false, "print only the answer data")
+	flag.StringVar(&opts.expectRcode, "expect-rcode", "", "require this response code, e.g. NOERROR or NXDOMAIN")
+	flag.IntVar(&opts.expectCount, "expect-count", -1, "require this many answer records")
+	flag.Var(&opts.expect, "expect", "require an answer record whose data contains this text (repeatable)")
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	qtype := dns.TypeA
+	if flag.NArg() == 2 {
+		var err error
+		if qtype, err = parseType(flag.Arg(1)); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	msg, err := buildQuery(flag.Arg(0), qtype, &opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, server, err := exchange(ctx, msg, &opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	elapsed := time.Since(start)
+
+	if opts.short {
+		for _, rr := range resp.Answer {
+			fmt.Println(rdata(rr))
+		}
+	} else {
+		fmt.Println(resp)
+		fmt.Printf(";; Query time: %d msec\n", elapsed.Milliseconds())
+		fmt.Printf(";; SERVER: %s (%s)\n", server, strings.ToUpper(opts.proto))
+		fmt.Printf(";; MSG SIZE  rcvd: %d\n", resp.Len())
+	}
+
+	if failures := checkExpectations(resp, &opts); len(failures) > 0 {
+		for _, failure := range failures {
+			fmt.Fprintf(os.Stderr, "dns-dig: %s\n", failure)
+		}
+		os.Exit(1)
+	}
+}
+
+// parseType parses a record type name, or TYPEnnn for any type number
+// (RFC 3597)
+func parseType(name string) (uint16, error) {
+	name = strings.ToUpper(name)
+	if qtype, ok := dns.StringToType[name]; ok {
+		return qtype, nil
+	}
+	if digits, ok := strings.CutPrefix(name, "TYPE"); ok {
+		if code, err := strconv.ParseUint(digits, 10, 16); err == nil {
+			return uint16(code), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown record type %q", name)
+}
+
+// buildQuery makes the query message, with an OPT record carrying the
+// requested EDNS options unless -noedns is set
+func buildQuery(name string, qtype uint16, opts *options) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = opts.recurse
+	if opts.noEDNS {
+		return msg, nil
+	}
+
+	msg.SetEdns0(uint16(opts.bufsize), opts.dnssec)
+	opt := msg.IsEdns0()
+	if opts.nsid {
+		opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	}
+	if opts.subnet != "" {
+		_, network, err := net.ParseCIDR(opts.subnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -subnet: %w", err)
+		}
+		ones, _ := network.Mask.Size()
+		subnet := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, SourceNetmask: uint8(ones), Address: network.IP}
+		if ip4 := network.IP.To4(); ip4 != nil {
+			subnet.Family, subnet.Address = 1, ip4
+		} else {
+			subnet.Family = 2
+		}
+		opt.Option = append(opt.Option, subnet)
+	}
+	return msg, nil
+}
+
+// exchange sends msg over the chosen transport, returning the response
+// and the server it was sent to
+func exchange(ctx context.Context, msg *dns.Msg, opts *options) (*dns.Msg, string, error) {
+	switch opts.proto {
+	case "udp", "tcp", "dot":
+		port, network := "53", opts.proto
+		if opts.proto == "dot" {
+			port, network = "853", "tcp-tls"
+		}
+		server := opts.server
+		if server == "" {
+			server = net.JoinHostPort("127.0.0.1", port)
+		} else if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, port)
+		}
+
+		client := &dns.Client{Net: network, Timeout: opts.timeout}
+		if opts.proto == "dot" {
+			client.TLSConfig = tlsConfig(server, opts)
+		}
+		resp, _, err := client.ExchangeContext(ctx, msg, server)
+		if err != nil {
+			return nil, server, fmt.Errorf("query to %s failed: %w", server, err)
+		}
+		return resp, server, nil
+
+	case "doh":
+		server := opts.server
+		if server == "" {
+			server = "https://127.0.0.1/dns-query"
+		}
+		resp, err := exchangeHTTPS(ctx, msg, server, opts)
+		return resp, server, err
+
+	default:
+		return nil, "", fmt.Errorf("unknown -proto %q: must be udp, tcp, dot, or doh", opts.proto)
+	}
+}
+
+// exchangeHTTPS sends msg as an RFC 8484 POST request
+func exchangeHTTPS(ctx context.Context, msg *dns.Msg, url string, opts *options) (*dns.Msg, error) {
+	// RFC 8484 section 4.1: use ID 0 so responses can be cached
+	msg.Id = 0
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	client := &http.Client{
+		Timeout:   opts.timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig(req.URL.Host, opts), ForceAttemptHTTP2: true},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP %d: %s", url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	answer := new(dns.Msg)
+	if err := answer.Unpack(body); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return answer, nil
+}
+
+// tlsConfig returns the TLS settings for a DoT or DoH server at address
+func tlsConfig(address string, opts *options) *tls.Config {
+	serverName := opts.sni
+	if serverName == "" {
+		serverName = address
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			serverName = host
+		}
+	}
+	return &tls.Config{ServerName: serverName, InsecureSkipVerify: opts.insecure}
+}
+
+// checkExpectations returns a description of each -expect-* condition the
+// response fails
+func checkExpectations(resp *dns.Msg, opts *options) []string {
+	var failures []string
+	if opts.expectRcode != "" {
+		want, ok := dns.StringToRcode[strings.ToUpper(opts.expectRcode)]
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("unknown -expect-rcode %q", opts.expectRcode))
+		case resp.Rcode != want:
+			failures = append(failures, fmt.Sprintf("rcode is %s, expected %s", dns.RcodeToString[resp.Rcode], dns.RcodeToString[want]))
+		}
+	}
+
+	if opts.expectCount >= 0 && len(resp.Answer) != opts.expectCount {
+		failures = append(failures, fmt.Sprintf("%d answer records, expected %d", len(resp.Answer), opts.expectCount))
+	}
+
+	for _, want := range opts.expect {
+		found := false
+		for _, rr := range resp.Answer {
+			if strings.Contains(rdata(rr), want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("no answer record contains %q", want))
+		}
+	}
+	return failures
+}
+
+// rdata renders an RR's data without its header
+func rdata(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dns-dig: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,166 @@
+// cmd/dns-route53-import/main.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/admin"
+	"errantdns.io/internal/importer/route53"
+)
+
+func main() {
+	zoneID := flag.String("zone-id", "", "Route 53 hosted zone ID to read via the API (credentials from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)")
+	input := flag.String("input", "", "read `aws route53 list-resource-record-sets` JSON from this file instead of the API (- for stdin)")
+	zone := flag.String("zone", "", "zone name (required with -input; looked up from the API otherwise)")
+	output := flag.String("output", "-", "write the ErrantDNS import document here (- for stdout)")
+	post := flag.String("post", "", "POST the records to this admin server (e.g. http://127.0.0.1:8053) instead of writing a file")
+	defaultTTL := flag.Uint("default-ttl", 300, "TTL for record sets without one")
+	includeApex := flag.Bool("include-apex-ns-soa", false, "import the zone's Route 53 SOA and NS record sets")
+	resolveAliases := flag.Bool("resolve-aliases", false, "snapshot current addresses of aliases to AWS resources via DNS")
+	strict := flag.Bool("strict", false, "exit with status 1 if any record set could not be mapped")
+	flag.Parse()
+
+	if (*zoneID == "") == (*input == "") {
+		fatalf("exactly one of -zone-id or -input is required")
+	}
+	if *input != "" && *zone == "" {
+		fatalf("-zone is required with -input")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	sets, zoneName, err := loadRecordSets(ctx, *zoneID, *input, *zone)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	records, report := route53.Translate(ctx, sets, route53.Options{
+		Zone:             zoneName,
+		DefaultTTL:       uint32(*defaultTTL),
+		IncludeApexNSSOA: *includeApex,
+		ResolveAliases:   *resolveAliases,
+	})
+	report.Print(os.Stderr)
+
+	export := &admin.RecordExport{
+		Version:    1,
+		ExportedAt: time.Now().UTC(),
+		Records:    records,
+	}
+
+	if *post != "" {
+		if err := postImport(ctx, *post, export); err != nil {
+			fatalf("%v", err)
+		}
+	} else if err := writeExport(*output, export); err != nil {
+		fatalf("%v", err)
+	}
+
+	if *strict && len(report.Unmapped) > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadRecordSets reads record sets from a file or the Route 53 API
+func loadRecordSets(ctx context.Context, zoneID, input, zone string) ([]route53.RecordSet, string, error) {
+	if input != "" {
+		var reader io.Reader = os.Stdin
+		if input != "-" {
+			file, err := os.Open(input)
+			if err != nil {
+				return nil, "", err
+			}
+			defer file.Close()
+			reader = file
+		}
+
+		sets, err := route53.LoadJSON(reader)
+		return sets, zone, err
+	}
+
+	credentials, err := route53.CredentialsFromEnv()
+	if err != nil {
+		return nil, "", err
+	}
+	client := route53.NewClient(credentials)
+
+	if zone == "" {
+		hostedZone, err := client.GetHostedZone(ctx, zoneID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get hosted zone %s: %w", zoneID, err)
+		}
+		zone = hostedZone.Name
+	}
+
+	sets, err := client.ListRecordSets(ctx, zoneID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list record sets for %s: %w", zoneID, err)
+	}
+	return sets, zone, nil
+}
+
+func writeExport(path string, export *admin.RecordExport) error {
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// postImport sends the records to a running server's import endpoint
+func postImport(ctx context.Context, adminURL string, export *admin.RecordExport) error {
+	data, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(adminURL, "/") + "/records/import"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("import request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("import returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result admin.ImportResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to parse import result: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nImported %d records into %s, %d failed\n", result.Imported, adminURL, len(result.Failed))
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "  %s %s: %s\n", failure.Name, failure.Type, failure.Error)
+	}
+	return nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dns-route53-import: "+format+"\n", args...)
+	os.Exit(2)
+}
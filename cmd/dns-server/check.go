@@ -0,0 +1,264 @@
+// cmd/dns-server/check.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"errantdns.io/internal/blocklist"
+	"errantdns.io/internal/config"
+	"errantdns.io/internal/dns"
+	"errantdns.io/internal/hosts"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/pgsqlpool"
+	"errantdns.io/internal/redis"
+	"errantdns.io/internal/selection"
+	"errantdns.io/internal/storage"
+	"errantdns.io/internal/suffixes"
+)
+
+// probeTimeout bounds each connectivity probe of -check-config -probe
+const probeTimeout = 10 * time.Second
+
+// Outcomes of a configuration check
+const (
+	checkOK      = "ok"
+	checkFailed  = "FAILED"
+	checkSkipped = "skipped"
+)
+
+// checkResult is the outcome of one configuration check
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+// checkReport collects the outcomes of the configuration checks
+type checkReport struct {
+	results []checkResult
+	failed  int
+}
+
+func (r *checkReport) ok(name, detail string) {
+	r.results = append(r.results, checkResult{name: name, status: checkOK, detail: detail})
+}
+
+func (r *checkReport) skip(name, reason string) {
+	r.results = append(r.results, checkResult{name: name, status: checkSkipped, detail: reason})
+}
+
+// check records err as a failure of name, or detail when it's nil. It
+// reports whether the check passed
+func (r *checkReport) check(name string, err error, detail string) bool {
+	if err != nil {
+		r.results = append(r.results, checkResult{name: name, status: checkFailed, detail: err.Error()})
+		r.failed++
+		return false
+	}
+	r.ok(name, detail)
+	return true
+}
+
+// print writes the report as a table, followed by a summary line
+func (r *checkReport) print() {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "STATUS\tCHECK\tDETAIL")
+	for _, result := range r.results {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", result.status, result.name, result.detail)
+	}
+	writer.Flush()
+
+	if r.failed > 0 {
+		fmt.Printf("\nconfiguration check failed: %d problem(s)\n", r.failed)
+		return
+	}
+	fmt.Println("\nconfiguration OK")
+}
+
+// checkConfig validates the configuration the way startup would, without
+// binding listeners or starting background work, and prints a report. With
+// probe, PostgreSQL and Redis are connected to as well. It returns the exit
+// status: 0 when every check passed
+func checkConfig(cfg *config.Config, probe bool) int {
+	report := &checkReport{}
+	report.check("configuration", cfg.Validate(), "environment parsed and validated")
+
+	// Only the report goes to stdout; errors go to the configured log files,
+	// which also checks they can be written
+	componentLevels, err := logging.ParseComponentLevels(cfg.Logging.ComponentLevels)
+	if err == nil {
+		err = logging.Initialize(&logging.Config{
+			Level:           logging.LevelError,
+			Directory:       cfg.Logging.Directory,
+			AppLogFile:      cfg.Logging.AppLogFile,
+			QueryLogFile:    cfg.Logging.QueryLogFile,
+			ErrorLogFile:    cfg.Logging.ErrorLogFile,
+			ComponentLevels: componentLevels,
+		})
+	}
+	report.check("logging", err, fmt.Sprintf("level %s, directory %s", cfg.Logging.Level, cfg.Logging.Directory))
+
+	var profile string
+	validation, err := models.ParseValidationProfile(cfg.Database.ValidationProfile)
+	if err == nil {
+		profile = validation.String()
+		models.SetValidationProfile(validation)
+	}
+	report.check("validation profile", err, profile)
+
+	strategy, err := selection.Get(cfg.Priority.TieBreaker)
+	report.check("record selection", err, cfg.Priority.TieBreaker)
+
+	storageConfig, err := newStorageConfig(cfg)
+	report.check("SOA serial policy", err, cfg.Database.SerialStrategy)
+
+	checkPolicies(cfg, report)
+	checkFiles(cfg, report)
+
+	if !probe {
+		report.skip("postgresql", "connectivity not probed; pass -probe")
+		if cfg.Cache.Enabled && cfg.Redis.Enabled {
+			report.skip("redis", "connectivity not probed; pass -probe")
+		}
+	} else if storageConfig == nil || strategy == nil {
+		report.skip("postgresql", "storage configuration is invalid")
+	} else {
+		probePostgres(cfg, storageConfig, strategy, report)
+		if cfg.Cache.Enabled && cfg.Redis.Enabled {
+			probeRedis(cfg, report)
+		}
+	}
+
+	report.print()
+	if report.failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkPolicies parses the rule lists startup turns into policies
+func checkPolicies(cfg *config.Config, report *checkReport) {
+	if len(cfg.RcodePolicy) > 0 {
+		rcodes, err := dns.NewRcodePolicy(cfg.RcodePolicy)
+		if err == nil && rcodes.UsesStale() && !cfg.CircuitBreaker.Enabled {
+			err = fmt.Errorf("stale answers require DB_BREAKER_ENABLED")
+		}
+		report.check("rcode policy", err, fmt.Sprintf("%d rule(s)", len(cfg.RcodePolicy)))
+	}
+	if len(cfg.TTLOverrides) > 0 {
+		_, err := dns.NewTTLPolicy(cfg.TTLOverrides)
+		report.check("TTL overrides", err, fmt.Sprintf("%d rule(s)", len(cfg.TTLOverrides)))
+	}
+	if len(cfg.AnswerLimits) > 0 {
+		_, err := dns.NewAnswerLimitPolicy(cfg.AnswerLimits)
+		report.check("answer limits", err, fmt.Sprintf("%d rule(s)", len(cfg.AnswerLimits)))
+	}
+	if len(cfg.Priority.ReturnAll) > 0 {
+		_, err := dns.NewReturnAllPolicy(cfg.Priority.ReturnAll)
+		report.check("return-all", err, fmt.Sprintf("%d rule(s)", len(cfg.Priority.ReturnAll)))
+	}
+
+	var names string
+	chain, err := dns.NewChain(cfg.Middleware)
+	if err == nil {
+		names = fmt.Sprint(chain.Names())
+	}
+	report.check("middleware", err, names)
+}
+
+// checkFiles loads the local files startup reads
+func checkFiles(cfg *config.Config, report *checkReport) {
+	if cfg.Hosts.Enabled {
+		table := hosts.NewTable(cfg.Hosts.Files, cfg.Hosts.TTL)
+		err := table.Load()
+		report.check("hosts files", err, fmt.Sprintf("%d name(s) from %v", table.Len(), cfg.Hosts.Files))
+	}
+
+	if cfg.Blocklist.Enabled {
+		_, err := blocklist.ParseResponse(cfg.Blocklist.Response, cfg.Blocklist.TTL)
+		report.check("blocklist response", err, cfg.Blocklist.Response)
+
+		var entries string
+		allowlist, err := blocklist.NewAllowlist(cfg.Blocklist.Allow, cfg.Blocklist.AllowlistFile)
+		if err == nil {
+			entries = fmt.Sprintf("%d entries", len(allowlist.Entries()))
+		}
+		report.check("allowlist", err, entries)
+	}
+
+	// Stored rules are read by the PostgreSQL probe
+	if cfg.Suffixes.File != "" {
+		rules, err := loadSuffixes(context.Background(), cfg.Suffixes.File, nil)
+		report.check("public suffix file", err, rules)
+	}
+}
+
+// probePostgres connects to PostgreSQL and checks the schema is current
+func probePostgres(cfg *config.Config, storageConfig *storage.Config, strategy selection.Strategy, report *checkReport) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	pool := pgsqlpool.NewPool()
+	defer pool.Close()
+
+	pgStorage, err := storage.NewPostgresStorage(ctx, pool, cfg.Database.ConnectionName, storageConfig, strategy)
+	if err == nil {
+		err = pgStorage.Health(ctx)
+	}
+	target := fmt.Sprintf("%s:%d/%s", cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName)
+	if !report.check("postgresql", err, target) {
+		return
+	}
+
+	pending, err := pgStorage.PendingMigrations(ctx, migrateOptions(cfg))
+	switch {
+	case err != nil:
+		report.check("schema", err, "")
+	case len(pending) == 0:
+		report.ok("schema", "up to date")
+	case cfg.Database.AutoMigrate:
+		report.ok("schema", fmt.Sprintf("migrations %v will be applied at startup", pending))
+	default:
+		report.check("schema", fmt.Errorf("migrations %v are pending and DB_AUTO_MIGRATE is off", pending), "")
+	}
+
+	if !cfg.Suffixes.Database {
+		return
+	}
+	if len(pending) > 0 || err != nil {
+		report.skip("public suffix rules", "read once the schema is up to date")
+		return
+	}
+	rules, err := loadSuffixes(ctx, cfg.Suffixes.File, pgStorage.PublicSuffixRules)
+	report.check("public suffix rules", err, rules)
+}
+
+// loadSuffixes loads custom public suffix rules, describing how many there
+// are for the report
+func loadSuffixes(ctx context.Context, file string, source suffixes.RuleSource) (string, error) {
+	list, err := suffixes.NewLoader(file, source).Load(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d rule(s)", list.Len()), nil
+}
+
+// probeRedis connects to Redis and pings it
+func probeRedis(cfg *config.Config, report *checkReport) {
+	target := cfg.Redis.Address
+	if cfg.Redis.MasterName != "" {
+		target = fmt.Sprintf("sentinel master %s", cfg.Redis.MasterName)
+	}
+
+	_, err := redis.NewClientWithOptions(cfg.Redis.ClientName, redisClientOptions(&cfg.Redis), false)
+	if err == nil {
+		defer redis.Close(cfg.Redis.ClientName)
+		err = redis.PingClient(cfg.Redis.ClientName)
+	}
+	report.check("redis", err, target)
+}
@@ -3,30 +3,87 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"errantdns.io/internal/admin"
+	"errantdns.io/internal/analysis"
+	"errantdns.io/internal/blocklist"
 	"errantdns.io/internal/cache"
+	"errantdns.io/internal/catalog"
+	"errantdns.io/internal/chaos"
 	"errantdns.io/internal/config"
 	"errantdns.io/internal/dns"
+	"errantdns.io/internal/dockerwatch"
+	"errantdns.io/internal/externaldns"
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/hosts"
+	"errantdns.io/internal/importer/cloudflare"
 	"errantdns.io/internal/logging"
+	"errantdns.io/internal/mirror"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/monitor"
+	"errantdns.io/internal/operator"
 	"errantdns.io/internal/pgsqlpool"
+	"errantdns.io/internal/plugin"
+	"errantdns.io/internal/policy"
+	"errantdns.io/internal/privdrop"
+	"errantdns.io/internal/ratelimit"
 	"errantdns.io/internal/redis"
+	"errantdns.io/internal/reverse"
+	"errantdns.io/internal/selection"
+	"errantdns.io/internal/stats"
 	"errantdns.io/internal/storage"
+	"errantdns.io/internal/suffixes"
+	"errantdns.io/internal/systemd"
+	"errantdns.io/internal/version"
 )
 
+const usage = `usage: dns-server [-check-config [-probe]]
+
+Runs the ErrantDNS server, configured through the environment.
+
+With -check-config, the configuration is loaded and validated as startup
+would, a report is printed, and the server exits without serving: with
+status 0 if every check passed and 1 otherwise. -probe connects to
+PostgreSQL and Redis as well.
+`
+
 func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	checkOnly := flag.Bool("check-config", false, "validate the configuration, print a report, and exit")
+	probe := flag.Bool("probe", false, "with -check-config, also check PostgreSQL and Redis are reachable")
+	flag.Parse()
+	if *probe && !*checkOnly {
+		fmt.Fprintln(os.Stderr, "-probe requires -check-config")
+		os.Exit(2)
+	}
+
 	// Load configuration
 	cfg := config.Load()
+	if *checkOnly {
+		os.Exit(checkConfig(cfg, *probe))
+	}
 	if err := cfg.Validate(); err != nil {
-		logging.Error("main", "Configuration validation failed: %v", fmt.Errorf("Configuration validation failed: %v", err)); os.Exit(1)
+		logging.Error("main", "Configuration validation failed: %v", fmt.Errorf("Configuration validation failed: %v", err))
+		os.Exit(1)
 	}
 
 	// Initialize logging EARLY - before any other operations
+	componentLevels, err := logging.ParseComponentLevels(cfg.Logging.ComponentLevels)
+	if err != nil {
+		logging.Error("main", "Invalid component log levels", err)
+		os.Exit(1)
+	}
 	loggingConfig := &logging.Config{
 		Level:           logging.LogLevel(cfg.Logging.Level),
 		Directory:       cfg.Logging.Directory,
@@ -36,15 +93,17 @@ func main() {
 		EnableConsole:   cfg.Logging.EnableConsole,
 		QuerySampleRate: cfg.Logging.QuerySampleRate,
 		BufferSize:      cfg.Logging.BufferSize,
+		ComponentLevels: componentLevels,
 	}
 
 	if err := logging.Initialize(loggingConfig); err != nil {
-		logging.Error("main", "Failed to initialize logging: %v", fmt.Errorf("Failed to initialize logging: %v", err)); os.Exit(1)
+		logging.Error("main", "Failed to initialize logging: %v", fmt.Errorf("Failed to initialize logging: %v", err))
+		os.Exit(1)
 	}
 
 	// Now use the new logging system
 	logging.Info("main", "ErrantDNS server starting",
-		"version", "1.0.0",
+		"version", version.Version,
 		"dns_port", cfg.DNSPort,
 		"cache_enabled", cfg.Cache.Enabled,
 		"redis_enabled", cfg.Redis.Enabled)
@@ -58,34 +117,210 @@ func main() {
 	// Initialize database pool
 	pool := pgsqlpool.NewPool()
 
+	// Validated with the rest of the configuration
+	strategy, err := selection.Get(cfg.Priority.TieBreaker)
+	if err != nil {
+		logging.Error("main", "Invalid record selection strategy", err)
+		os.Exit(1)
+	}
 	// Create storage layer
-	storageConfig := &storage.Config{
-		Host:            cfg.Database.Host,
-		Port:            cfg.Database.Port,
-		User:            cfg.Database.User,
-		Password:        cfg.Database.Password,
-		DBName:          cfg.Database.DBName,
-		SSLMode:         cfg.Database.SSLMode,
-		MaxOpenConns:    cfg.Database.MaxOpenConns,
-		MaxIdleConns:    cfg.Database.MaxIdleConns,
-		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
-		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	storageConfig, err := newStorageConfig(cfg)
+	if err != nil {
+		logging.Error("main", "Invalid SOA serial strategy configuration", err)
+		os.Exit(1)
+	}
+	validation, err := models.ParseValidationProfile(cfg.Database.ValidationProfile)
+	if err != nil {
+		logging.Error("main", "Invalid record validation profile", err)
+		os.Exit(1)
+	}
+	models.SetValidationProfile(validation)
+	if validation.String() != models.ValidationStrict {
+		logging.Warn("main", "Record validation relaxed", "profile", validation.String())
 	}
 
-	pgStorage, err := storage.NewPostgresStorage(ctx, pool, cfg.Database.ConnectionName, storageConfig, cfg.Priority.TieBreaker)
+	pgStorage, err := storage.NewPostgresStorage(ctx, pool, cfg.Database.ConnectionName, storageConfig, strategy)
 	if err != nil {
-		logging.Error("main", "Failed to create storage: %v", fmt.Errorf("Failed to create storage: %v", err)); os.Exit(1)
+		logging.Error("main", "Failed to create storage: %v", fmt.Errorf("Failed to create storage: %v", err))
+		os.Exit(1)
 	}
 
-	logging.Info("main", "Connected to PostgreSQL database at %s:%d/%s",
+	if err := connectAtStartup(ctx, "postgresql", &cfg.Startup, pgStorage.Health); err != nil {
+		logging.Error("main", "Failed to connect to PostgreSQL", err, "startup_mode", cfg.Startup.Mode)
+		os.Exit(1)
+	}
+
+	logging.Info("main", "Using PostgreSQL database at %s:%d/%s",
 		cfg.Database.Host, cfg.Database.Port, cfg.Database.DBName)
 
+	if cfg.Database.AutoMigrate {
+		applied, err := pgStorage.Migrate(ctx, migrateOptions(cfg))
+		if err != nil {
+			logging.Error("main", "Failed to migrate PostgreSQL schema", err, "applied", applied)
+			os.Exit(1)
+		}
+		if len(applied) > 0 {
+			logging.Info("main", "Applied PostgreSQL schema migrations", "versions", applied)
+		}
+	}
+
+	// Reject record writes during database maintenance; switched at runtime
+	// through the admin API
+	if cfg.Database.ReadOnly {
+		pgStorage.SetReadOnly(true)
+		logging.Warn("main", "Starting read-only: record writes are rejected")
+	}
+
+	// Merge custom public suffixes into apex domains before any are derived
+	var suffixLoader *suffixes.Loader
+	var suffixList *models.SuffixList
+	if cfg.Suffixes.Enabled() {
+		var source suffixes.RuleSource
+		if cfg.Suffixes.Database {
+			source = pgStorage.PublicSuffixRules
+		}
+		suffixLoader = suffixes.NewLoader(cfg.Suffixes.File, source)
+		suffixList, err = suffixLoader.Load(ctx)
+		if err != nil {
+			logging.Error("main", "Failed to load custom public suffixes", err)
+			os.Exit(1)
+		}
+		logging.Info("main", "Custom public suffixes enabled",
+			"file", cfg.Suffixes.File, "database", cfg.Suffixes.Database, "rules", suffixList.Len())
+	}
+
+	// Fill in the domain columns wildcard and apex-filtered lookups rely on
+	// for records written before they were stored
+	go func() {
+		count, err := pgStorage.BackfillDomainColumns(ctx)
+		if err != nil {
+			logging.Warn("main", "Failed to backfill record domain columns", "error", err, "updated", count)
+		} else if count > 0 {
+			logging.Info("main", "Backfilled record domain columns", "updated", count)
+		}
+
+		if suffixLoader == nil {
+			return
+		}
+		// Records written under different suffixes are derived again
+		count, err = pgStorage.SetPublicSuffixes(ctx, suffixList)
+		if err != nil {
+			logging.Warn("main", "Failed to derive record domain columns for custom public suffixes", "error", err, "updated", count)
+		} else if count > 0 {
+			logging.Info("main", "Derived record domain columns for custom public suffixes", "updated", count)
+		}
+		suffixLoader.Run(ctx, cfg.Suffixes.RefreshInterval, pgStorage.SetPublicSuffixes)
+	}()
+
+	// Follow the primary across switchovers when several hosts are listed
+	if len(cfg.Database.Hosts) > 1 {
+		go pool.MonitorFailover(ctx, cfg.Database.FailoverCheckInterval)
+		logging.Info("main", "PostgreSQL failover enabled",
+			"hosts", cfg.Database.Hosts, "target_session_attrs", cfg.Database.TargetSessionAttrs)
+	}
+
+	// Pick per-zone responses for failed and unanswered lookups
+	var rcodes *dns.RcodePolicy
+	if len(cfg.RcodePolicy) > 0 {
+		rcodes, err = dns.NewRcodePolicy(cfg.RcodePolicy)
+		if err != nil {
+			logging.Error("main", "Invalid rcode policy", err)
+			os.Exit(1)
+		}
+		if rcodes.UsesStale() && !cfg.CircuitBreaker.Enabled {
+			logging.Error("main", "Invalid rcode policy", fmt.Errorf("stale answers require DB_BREAKER_ENABLED"))
+			os.Exit(1)
+		}
+		logging.Info("main", "Rcode policy enabled", "rules", cfg.RcodePolicy)
+	}
+
+	// Repeat a sample of lookups against a second database to compare it
+	// with the primary, e.g. before migrating to it
+	var backend storage.Storage = pgStorage
+	var shadowReads *storage.ShadowStorage
+	if cfg.ShadowRead.Enabled {
+		shadowConfig := newShadowStorageConfig(cfg, storageConfig)
+		shadowStorage, err := storage.NewPostgresStorage(ctx, pool, cfg.Database.ConnectionName+"_shadow", shadowConfig, strategy)
+		if err != nil {
+			logging.Error("main", "Failed to create shadow storage", err)
+			os.Exit(1)
+		}
+		healthCtx, healthCancel := context.WithTimeout(ctx, cfg.ShadowRead.Timeout)
+		if err := shadowStorage.Health(healthCtx); err != nil {
+			logging.Warn("main", "Shadow database unreachable; its lookups will be counted as failed", "error", err.Error())
+		}
+		healthCancel()
+		shadowReads = storage.NewShadowStorage(pgStorage, shadowStorage, &storage.ShadowConfig{
+			SampleRate: cfg.ShadowRead.SampleRate,
+			Timeout:    cfg.ShadowRead.Timeout,
+			QueueSize:  cfg.ShadowRead.QueueSize,
+			Workers:    cfg.ShadowRead.Workers,
+		})
+		go shadowReads.Run(ctx)
+		backend = shadowReads
+		logging.Info("main", "Shadow reads enabled",
+			"host", shadowConfig.Host, "dbname", shadowConfig.DBName, "sample_rate", cfg.ShadowRead.SampleRate)
+	}
+
+	// Fail and slow storage lookups on purpose, below the breaker and
+	// caches so they react as they would to a real outage
+	var injector *chaos.Injector
+	if cfg.Chaos.Enabled {
+		injector, err = chaos.NewInjector(cfg.Chaos.Faults)
+		if err != nil {
+			logging.Error("main", "Invalid fault injection configuration", err)
+			os.Exit(1)
+		}
+		logging.Warn("main", "Fault injection enabled; queries will be slowed, failed, and dropped on purpose",
+			"faults", cfg.Chaos.Faults)
+	}
+
+	// Answer lookups from stale data instead of hammering PostgreSQL while it's failing
+	if injector.Injects(chaos.StageStorage) {
+		backend = chaos.NewStorage(backend, injector)
+	}
+	var breaker *storage.BreakerStorage
+	if cfg.CircuitBreaker.Enabled {
+		breakerConfig := &storage.BreakerConfig{
+			FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+			ProbeInterval:    cfg.CircuitBreaker.ProbeInterval,
+			StaleTTL:         cfg.CircuitBreaker.StaleTTL,
+			StaleMaxEntries:  cfg.CircuitBreaker.StaleMaxEntries,
+		}
+		if rcodes != nil {
+			breakerConfig.ServeStale = rcodes.ServeStale
+		}
+		breaker = storage.NewBreakerStorage(backend, breakerConfig)
+		go breaker.Run(ctx)
+		backend = breaker
+		logging.Info("main", "PostgreSQL circuit breaker enabled",
+			"failure_threshold", cfg.CircuitBreaker.FailureThreshold, "stale_ttl", cfg.CircuitBreaker.StaleTTL)
+	}
+
+	// Answer from the caches alone when told to or while storage keeps
+	// failing; only meaningful in front of a cache
+	var cacheOnly *storage.CacheOnlyStorage
+	if cfg.Cache.Enabled {
+		cacheOnly = storage.NewCacheOnlyStorage(backend, &storage.CacheOnlyConfig{
+			FailureThreshold: cfg.Cache.OnlyFailureThreshold,
+			ProbeInterval:    cfg.Cache.OnlyProbeInterval,
+		})
+		if cfg.Cache.OnlyMode {
+			cacheOnly.SetCacheOnly(true, "CACHE_ONLY")
+			logging.Warn("main", "Starting cache-only: lookups are answered from the caches alone")
+		}
+		go cacheOnly.Run(ctx)
+		backend = cacheOnly
+	}
+
 	// Create cache layer if enabled
-	var finalStorage storage.Storage = pgStorage
+	var finalStorage storage.Storage = backend
 
 	if cfg.Cache.Enabled {
 		cacheConfig := &cache.Config{
 			MaxEntries:      cfg.Cache.MaxEntries,
+			MaxBytes:        cfg.Cache.MaxBytes,
+			Eviction:        cfg.Cache.Eviction,
 			CleanupInterval: cfg.Cache.CleanupInterval,
 		}
 
@@ -93,43 +328,504 @@ func main() {
 
 		if cfg.Redis.Enabled {
 			// Initialize Redis client
-			logging.Info("main", "Initializing Redis connection to %s", "details", fmt.Sprintf("Initializing Redis connection to %s", cfg.Redis.Address))
-			redis.NewClient(cfg.Redis.ClientName, cfg.Redis.Address, false)
+			if cfg.Redis.MasterName != "" {
+				logging.Info("main", "Initializing Redis connection through Sentinel",
+					"master_name", cfg.Redis.MasterName, "sentinels", cfg.Redis.SentinelAddresses, "tls", cfg.Redis.TLSEnabled)
+			} else {
+				logging.Info("main", "Initializing Redis connection", "address", cfg.Redis.Address, "tls", cfg.Redis.TLSEnabled)
+			}
+			if _, err := redis.NewClientWithOptions(cfg.Redis.ClientName, redisClientOptions(&cfg.Redis), false); err != nil {
+				logging.Error("main", "Failed to create Redis client", err)
+				os.Exit(1)
+			}
 
 			// Test Redis connection
-			if err := redis.PingClient(cfg.Redis.ClientName); err != nil {
-				logging.Error("main", "Failed to connect to Redis: %v", fmt.Errorf("Failed to connect to Redis: %v", err)); os.Exit(1)
+			pingRedis := func(context.Context) error { return redis.PingClient(cfg.Redis.ClientName) }
+			if err := connectAtStartup(ctx, "redis", &cfg.Startup, pingRedis); err != nil {
+				logging.Error("main", "Failed to connect to Redis: %v", fmt.Errorf("Failed to connect to Redis: %v", err))
+				os.Exit(1)
 			}
-			logging.Info("main", "Connected to Redis at %s", cfg.Redis.Address)
+			logging.Info("main", "Using Redis at %s", cfg.Redis.Address)
 
 			// Three-tier caching: Memory → Redis → PostgreSQL
-			finalStorage = storage.NewRedisCacheStorage(pgStorage, memCache, cfg.Redis.ClientName, "errantdns:", cfg.Priority.TieBreaker)
+			finalStorage = storage.NewRedisCacheStorage(backend, memCache, cfg.Redis.ClientName, "errantdns:", strategy, cfg.Timeouts.Redis, cfg.Redis.CacheCompression)
 			logging.Info("main", "Three-tier cache enabled: Memory → Redis → PostgreSQL")
 		} else {
 			// Two-tier caching: Memory → PostgreSQL
-			finalStorage = storage.NewCachedStorage(pgStorage, memCache, cfg.Priority.TieBreaker)
+			finalStorage = storage.NewCachedStorage(backend, memCache, strategy)
 			logging.Info("main", "Two-tier cache enabled: Memory → PostgreSQL")
 		}
 
-		log.Printf("Cache enabled: max entries=%d, cleanup interval=%v",
-			cfg.Cache.MaxEntries, cfg.Cache.CleanupInterval)
+		log.Printf("Cache enabled: max entries=%d, max bytes=%d, eviction=%s, cleanup interval=%v",
+			cfg.Cache.MaxEntries, cfg.Cache.MaxBytes, cfg.Cache.Eviction, cfg.Cache.CleanupInterval)
 	} else {
 		logging.Info("main", "Cache disabled")
 	}
 
-	// Test storage health
-	if err := finalStorage.Health(ctx); err != nil {
-		logging.Error("main", "Storage health check failed: %v", fmt.Errorf("Storage health check failed: %v", err)); os.Exit(1)
+	// Test storage health; degraded startup serves without it
+	if err := finalStorage.Health(ctx); err != nil && cfg.Startup.Mode != "degraded" {
+		logging.Error("main", "Storage health check failed: %v", fmt.Errorf("Storage health check failed: %v", err))
+		os.Exit(1)
 	}
 
 	logging.Info("main", "Storage layer initialized successfully")
 
+	// Persist per-zone query counts if enabled
+	var queryCounter *stats.QueryCounter
+	if cfg.QueryStats.Enabled {
+		queryCounter = stats.NewQueryCounter()
+		flusher := stats.NewFlusher(queryCounter, pgStorage, cfg.QueryStats.FlushInterval)
+		go flusher.Run(ctx)
+		logging.Info("main", "Query statistics persistence enabled", "flush_interval", cfg.QueryStats.FlushInterval)
+	}
+
+	// Delete records past their expires_at time
+	if cfg.Reaper.Enabled {
+		reaper := storage.NewReaper(pgStorage, cfg.Reaper.Interval)
+		go reaper.Run(ctx)
+		logging.Info("main", "Expired record reaper enabled", "interval", cfg.Reaper.Interval)
+	}
+
+	// Track top names, NXDOMAIN names, and clients if enabled
+	var analytics *stats.QueryAnalytics
+	if cfg.Analytics.Enabled {
+		analytics = stats.NewQueryAnalytics(cfg.Analytics.TopN, cfg.Analytics.Window,
+			cfg.Analytics.SketchWidth, cfg.Analytics.SketchDepth)
+	}
+
+	// Watch NXDOMAIN responses for floods and random-subdomain attacks if enabled
+	var nxAnalyzer *analysis.NXDomainAnalyzer
+	if cfg.NXDomainAlerts.Enabled {
+		sinks := []analysis.Sink{analysis.LogSink{}}
+		if cfg.NXDomainAlerts.WebhookURL != "" {
+			sinks = append(sinks, analysis.NewWebhookSink(cfg.NXDomainAlerts.WebhookURL, cfg.NXDomainAlerts.WebhookTimeout))
+		}
+		nxAnalyzer = analysis.NewNXDomainAnalyzer(&analysis.Config{
+			Window:           cfg.NXDomainAlerts.Window,
+			FloodThreshold:   cfg.NXDomainAlerts.FloodThreshold,
+			RandomThreshold:  cfg.NXDomainAlerts.RandomThreshold,
+			EntropyThreshold: cfg.NXDomainAlerts.EntropyThreshold,
+			Cooldown:         cfg.NXDomainAlerts.Cooldown,
+		}, sinks...)
+		go nxAnalyzer.Run(ctx)
+		logging.Info("main", "NXDOMAIN pattern alerting enabled", "webhook", cfg.NXDomainAlerts.WebhookURL != "")
+	}
+
+	// Limit queries per client IP or subnet if enabled
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		limiter, err = ratelimit.NewLimiter(&ratelimit.Config{
+			QPS:         cfg.RateLimit.QPS,
+			Burst:       cfg.RateLimit.Burst,
+			IPv4Prefix:  cfg.RateLimit.IPv4Prefix,
+			IPv6Prefix:  cfg.RateLimit.IPv6Prefix,
+			ExemptCIDRs: cfg.RateLimit.ExemptCIDRs,
+			Action:      ratelimit.Action(cfg.RateLimit.Action),
+		})
+		if err != nil {
+			logging.Error("main", "Failed to create rate limiter: %v", fmt.Errorf("Failed to create rate limiter: %v", err))
+			os.Exit(1)
+		}
+		go limiter.Run(ctx)
+		logging.Info("main", "Rate limiting enabled",
+			"qps", cfg.RateLimit.QPS, "burst", cfg.RateLimit.Burst, "action", cfg.RateLimit.Action)
+	}
+
+	// Forward queries we are not authoritative for if enabled
+	var fwd *forwarder.Forwarder
+	if cfg.Forwarder.Enabled {
+		fwd, err = forwarder.NewForwarder(&forwarder.Config{
+			Upstreams:     cfg.Forwarder.Upstreams,
+			Timeout:       cfg.Forwarder.Timeout,
+			AllowedCIDRs:  cfg.Forwarder.AllowedCIDRs,
+			CAFile:        cfg.Forwarder.CAFile,
+			ProbeInterval: cfg.Forwarder.ProbeInterval,
+		})
+		if err != nil {
+			logging.Error("main", "Failed to create forwarder: %v", fmt.Errorf("Failed to create forwarder: %v", err))
+			os.Exit(1)
+		}
+		go fwd.Run(ctx)
+		logging.Info("main", "Forwarding enabled",
+			"upstreams", cfg.Forwarder.Upstreams, "allowed_cidrs", cfg.Forwarder.AllowedCIDRs,
+			"probe_interval", cfg.Forwarder.ProbeInterval)
+	}
+
+	// Compare a sample of our answers with a shadow server's
+	var shadow *mirror.Mirror
+	if cfg.Mirror.Enabled {
+		shadow, err = mirror.New(&mirror.Config{
+			Target:     cfg.Mirror.Target,
+			SampleRate: cfg.Mirror.SampleRate,
+			Timeout:    cfg.Mirror.Timeout,
+			QueueSize:  cfg.Mirror.QueueSize,
+			Workers:    cfg.Mirror.Workers,
+		})
+		if err != nil {
+			logging.Error("main", "Invalid query mirroring configuration", err)
+			os.Exit(1)
+		}
+		go shadow.Run(ctx)
+		logging.Info("main", "Mirroring queries to a shadow server",
+			"target", cfg.Mirror.Target, "sample_rate", cfg.Mirror.SampleRate)
+	}
+
+	// Use sockets passed in by systemd socket activation when present
+	sockets, err := systemd.Listen()
+	if err != nil {
+		logging.Error("main", "Failed to use activated sockets: %v", fmt.Errorf("Failed to use activated sockets: %v", err))
+		os.Exit(1)
+	}
+	if !sockets.Empty() {
+		logging.Info("main", "Using systemd-activated sockets",
+			"datagram", len(sockets.PacketConns), "stream", len(sockets.Listeners))
+		for _, l := range sockets.Listeners {
+			if l.Name == "dot" || l.Name == "doh" {
+				logging.Warn("main", "Ignoring activated socket for unsupported listener", "name", l.Name)
+			}
+		}
+	}
+
+	packetConn := sockets.PacketConn("dns")
+	listener := sockets.Listener("dns")
+
+	// Bind the DNS port while still root, then switch to the unprivileged user
+	if cfg.Privileges.User != "" {
+		if packetConn == nil {
+			if packetConn, err = net.ListenPacket("udp4", "0.0.0.0:"+cfg.DNSPort); err != nil {
+				logging.Error("main", "Failed to bind UDP port: %v", fmt.Errorf("Failed to bind UDP port %s: %v", cfg.DNSPort, err))
+				os.Exit(1)
+			}
+		}
+		if listener == nil {
+			if listener, err = net.Listen("tcp4", "0.0.0.0:"+cfg.DNSPort); err != nil {
+				logging.Error("main", "Failed to bind TCP port: %v", fmt.Errorf("Failed to bind TCP port %s: %v", cfg.DNSPort, err))
+				os.Exit(1)
+			}
+		}
+
+		target, err := privdrop.Resolve(cfg.Privileges.User, cfg.Privileges.Group)
+		if err != nil {
+			logging.Error("main", "Failed to resolve run-as user: %v", fmt.Errorf("Failed to resolve run-as user: %v", err))
+			os.Exit(1)
+		}
+
+		fromUID := os.Geteuid()
+		if err := privdrop.Drop(target); err != nil {
+			logging.Error("main", "Failed to drop privileges: %v", fmt.Errorf("Failed to drop privileges: %v", err))
+			os.Exit(1)
+		}
+
+		capabilities, _ := privdrop.EffectiveCapabilities()
+		logging.Info("main", "Dropped privileges",
+			"from_uid", fromUID, "user", target.Username, "uid", target.UID, "gid", target.GID,
+			"effective_capabilities", capabilities)
+	}
+
+	// L0 cache of packed responses for hot queries if enabled
+	var wireCache *cache.WireCache
+	if cfg.Cache.WireEnabled {
+		wireCache = cache.NewWireCache(&cache.WireConfig{
+			MaxEntries: cfg.Cache.WireMaxEntries,
+			MaxTTL:     cfg.Cache.WireMaxTTL,
+		})
+		logging.Info("main", "Wire-format answer cache enabled",
+			"max_entries", cfg.Cache.WireMaxEntries, "max_ttl", cfg.Cache.WireMaxTTL)
+	}
+
+	// Drops cached answers for records changed outside the storage wrappers
+	invalidate := storage.InvalidatorFunc(func(name, recordType string) {
+		if invalidator, ok := finalStorage.(storage.Invalidator); ok {
+			invalidator.Invalidate(name, recordType)
+		}
+		if breaker != nil {
+			breaker.Invalidate(name, recordType)
+		}
+		if wireCache != nil {
+			wireCache.InvalidateName(models.NormalizeDomainName(name) + ".")
+		}
+	})
+
+	// Drops cached answers on request from every tier, and on every node
+	// sharing the Redis cache
+	var cacheFlusher storage.Flusher = storage.FlusherFunc(func(ctx context.Context, f storage.Flush) error {
+		if breaker != nil {
+			breaker.Flush(ctx, f)
+		}
+		if wireCache != nil {
+			switch {
+			case f.All:
+				wireCache.Clear()
+			case f.Apex != "":
+				wireCache.InvalidateZone(models.NormalizeDomainName(f.Apex) + ".")
+			default:
+				wireCache.InvalidateName(models.NormalizeDomainName(f.Name) + ".")
+			}
+		}
+		if cached, ok := finalStorage.(storage.Flusher); ok {
+			return cached.Flush(ctx, f)
+		}
+		return nil
+	})
+	if cfg.Cache.Enabled && cfg.Redis.Enabled {
+		bus := storage.NewFlushBus(cacheFlusher, cfg.Redis.ClientName, cfg.Redis.FlushChannel)
+		go bus.Run(ctx)
+		cacheFlusher = bus
+	}
+
+	// Lists what every tier on this node currently caches
+	cacheInspector := storage.CacheInspectorFunc(func(ctx context.Context, filter storage.CacheFilter) ([]storage.CacheEntry, error) {
+		var entries []storage.CacheEntry
+		if wireCache != nil {
+			entries = storage.DescribeEntries(storage.SourceWire, wireCache.Entries(), filter)
+		}
+		if breaker != nil {
+			stale, _ := breaker.CacheEntries(ctx, filter)
+			entries = append(entries, stale...)
+		}
+		if inspector, ok := finalStorage.(storage.CacheInspector); ok {
+			cached, err := inspector.CacheEntries(ctx, filter)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, cached...)
+		}
+		return entries, nil
+	})
+
+	// Let out-of-process plugins answer queries and approve record changes
+	var plugins *plugin.Manager
+	var applier plugin.ChangesetApplier = storage.NewChangesetApplier(pgStorage, invalidate)
+	if len(cfg.Plugins.Plugins) > 0 {
+		plugins, err = plugin.NewManager(&plugin.Config{
+			Plugins:  cfg.Plugins.Plugins,
+			Timeout:  cfg.Plugins.Timeout,
+			FailOpen: cfg.Plugins.FailOpen,
+		})
+		if err != nil {
+			logging.Error("main", "Failed to create plugins: %v", fmt.Errorf("Failed to create plugins: %v", err))
+			os.Exit(1)
+		}
+		if plugins.HasMutationHooks() {
+			applier = plugins.Changesets(applier)
+		}
+		logging.Info("main", "Plugins enabled", "plugins", cfg.Plugins.Plugins,
+			"timeout", cfg.Plugins.Timeout, "fail_open", cfg.Plugins.FailOpen)
+	}
+
+	// Invalidate cached answers when scheduled records activate or deactivate
+	if cfg.Schedule.Enabled {
+		scheduler := storage.NewChangeScheduler(pgStorage, invalidate, cfg.Schedule.PollInterval)
+		go scheduler.Run(ctx)
+		logging.Info("main", "Scheduled record activation enabled", "poll_interval", cfg.Schedule.PollInterval)
+	}
+
+	// Mirror Cloudflare zones into PostgreSQL if enabled
+	if cfg.CloudflareSync.Enabled {
+		client := cloudflare.NewClient(cfg.CloudflareSync.APIToken, cfg.CloudflareSync.Timeout)
+		syncer := cloudflare.NewSyncer(client, pgStorage, invalidate, cfg.CloudflareSync.Zones,
+			cfg.CloudflareSync.Interval, cfg.CloudflareSync.DefaultTTL)
+		go syncer.Run(ctx)
+		logging.Info("main", "Cloudflare zone sync enabled",
+			"zones", cfg.CloudflareSync.Zones, "interval", cfg.CloudflareSync.Interval)
+	}
+
+	// Serve AXFR of stored zones to secondaries if enabled
+	var zoneTransfer *dns.ZoneTransfer
+	if cfg.ZoneTransfer.Enabled {
+		acl, err := forwarder.NewACL(cfg.ZoneTransfer.AllowedCIDRs)
+		if err != nil {
+			logging.Error("main", "Failed to create zone transfer ACL: %v", fmt.Errorf("Failed to create zone transfer ACL: %v", err))
+			os.Exit(1)
+		}
+		zoneTransfer = &dns.ZoneTransfer{Lister: pgStorage, ACL: acl}
+		logging.Info("main", "Zone transfers enabled", "allowed_cidrs", cfg.ZoneTransfer.AllowedCIDRs)
+	}
+
+	// Publish our zones as a catalog zone if enabled
+	var catalogZone dns.CatalogZone
+	if cfg.Catalog.ProducerEnabled {
+		producer, err := catalog.NewProducer(pgStorage, &catalog.ProducerConfig{
+			Zone:            cfg.Catalog.Zone,
+			AllowTransfer:   cfg.ZoneTransfer.AllowedCIDRs,
+			Notify:          cfg.Catalog.Notify,
+			RefreshInterval: cfg.Catalog.RefreshInterval,
+		})
+		if err != nil {
+			logging.Error("main", "Failed to create catalog producer: %v", fmt.Errorf("Failed to create catalog producer: %v", err))
+			os.Exit(1)
+		}
+		go producer.Run(ctx)
+		catalogZone = producer
+		logging.Info("main", "Catalog zone producer enabled", "zone", cfg.Catalog.Zone)
+	}
+
+	// Mirror the member zones of a remote catalog if enabled
+	if cfg.Catalog.ConsumerEnabled {
+		consumer, err := catalog.NewConsumer(pgStorage, invalidate, &catalog.ConsumerConfig{
+			Zone:     cfg.Catalog.ConsumerZone,
+			Primary:  cfg.Catalog.Primary,
+			Interval: cfg.Catalog.ConsumerInterval,
+			Timeout:  cfg.Catalog.TransferTimeout,
+		})
+		if err != nil {
+			logging.Error("main", "Failed to create catalog consumer: %v", fmt.Errorf("Failed to create catalog consumer: %v", err))
+			os.Exit(1)
+		}
+		go consumer.Run(ctx)
+		logging.Info("main", "Catalog zone consumer enabled", "zone", cfg.Catalog.ConsumerZone, "primary", cfg.Catalog.Primary)
+	}
+
+	// Synthesize PTR answers from forward records if enabled
+	var reverseSynth *reverse.Synthesizer
+	if cfg.Reverse.Enabled {
+		reverseSynth, err = reverse.NewSynthesizer(pgStorage, cfg.Reverse.Prefixes)
+		if err != nil {
+			logging.Error("main", "Failed to create reverse synthesizer: %v", fmt.Errorf("Failed to create reverse synthesizer: %v", err))
+			os.Exit(1)
+		}
+		logging.Info("main", "Reverse PTR synthesis enabled", "prefixes", cfg.Reverse.Prefixes)
+	}
+
+	// Serve hosts file entries ahead of storage if enabled
+	var hostsTable *hosts.Table
+	if cfg.Hosts.Enabled {
+		hostsTable = hosts.NewTable(cfg.Hosts.Files, cfg.Hosts.TTL)
+		if err := hostsTable.Load(); err != nil {
+			logging.Error("main", "Failed to load hosts files: %v", fmt.Errorf("Failed to load hosts files: %v", err))
+			os.Exit(1)
+		}
+		go hostsTable.Run(ctx, cfg.Hosts.ReloadInterval)
+		logging.Info("main", "Hosts files enabled", "files", cfg.Hosts.Files, "names", hostsTable.Len())
+	}
+
+	// Block names listed in downloaded feeds if enabled
+	var blocker *blocklist.Manager
+	if cfg.Blocklist.Enabled {
+		response, err := blocklist.ParseResponse(cfg.Blocklist.Response, cfg.Blocklist.TTL)
+		if err != nil {
+			logging.Error("main", "Invalid blocklist response: %v", fmt.Errorf("Invalid blocklist response: %v", err))
+			os.Exit(1)
+		}
+		allowlist, err := blocklist.NewAllowlist(cfg.Blocklist.Allow, cfg.Blocklist.AllowlistFile)
+		if err != nil {
+			logging.Error("main", "Failed to load allowlist: %v", fmt.Errorf("Failed to load allowlist: %v", err))
+			os.Exit(1)
+		}
+		blocker = blocklist.NewManager(&blocklist.Config{
+			Feeds:           cfg.Blocklist.Feeds,
+			RefreshInterval: cfg.Blocklist.RefreshInterval,
+			Timeout:         cfg.Blocklist.Timeout,
+			Response:        response,
+			Allowlist:       allowlist,
+		})
+		go blocker.Run(ctx)
+		logging.Info("main", "Blocklist enabled", "feeds", len(cfg.Blocklist.Feeds),
+			"refresh_interval", cfg.Blocklist.RefreshInterval, "response", response.String(),
+			"allowlist_entries", len(allowlist.Entries()))
+	}
+
+	// Give client networks their own blocking, forwarding, and log level
+	var groups *policy.Groups
+	if len(cfg.Policy.Groups) > 0 {
+		groups, err = buildPolicyGroups(ctx, cfg, blocker)
+		if err != nil {
+			logging.Error("main", "Failed to create policy groups: %v", fmt.Errorf("Failed to create policy groups: %v", err))
+			os.Exit(1)
+		}
+		logging.Info("main", "Policy groups enabled", "rules", cfg.Policy.Groups, "networks", groups.Len())
+	}
+
+	// Override unreasonable TTLs of configured zones at answer time
+	var ttlOverrides *dns.TTLPolicy
+	if len(cfg.TTLOverrides) > 0 {
+		ttlOverrides, err = dns.NewTTLPolicy(cfg.TTLOverrides)
+		if err != nil {
+			logging.Error("main", "Invalid TTL override configuration", err)
+			os.Exit(1)
+		}
+		logging.Info("main", "TTL overrides enabled", "rules", cfg.TTLOverrides)
+	}
+
+	// Sample huge RRsets so answers stay UDP-friendly
+	var answerLimits *dns.AnswerLimitPolicy
+	if len(cfg.AnswerLimits) > 0 {
+		answerLimits, err = dns.NewAnswerLimitPolicy(cfg.AnswerLimits)
+		if err != nil {
+			logging.Error("main", "Invalid answer limit configuration", err)
+			os.Exit(1)
+		}
+		logging.Info("main", "Answer limits enabled", "rules", cfg.AnswerLimits)
+	}
+
+	// Answer configured queries with their whole priority group
+	var returnAll *dns.ReturnAllPolicy
+	if len(cfg.Priority.ReturnAll) > 0 {
+		returnAll, err = dns.NewReturnAllPolicy(cfg.Priority.ReturnAll)
+		if err != nil {
+			logging.Error("main", "Invalid return-all configuration", err)
+			os.Exit(1)
+		}
+		logging.Info("main", "Returning whole priority groups", "rules", cfg.Priority.ReturnAll)
+	}
+
+	// Track the zones we hold an SOA for, which are never forwarded, and
+	// only claim authority for them if configured
+	authority := dns.NewZoneAuthority(pgStorage, cfg.Authority.RefreshInterval, cfg.Authority.Mode != "soa")
+	zones, err := authority.Load(ctx)
+	switch {
+	case err != nil:
+		logging.Warn("main", "Failed to load authoritative zones, retrying in the background", "error", err)
+	case cfg.Authority.Mode == "soa":
+		logging.Info("main", "Authority limited to zones with an SOA record", "zones", zones)
+	default:
+		logging.Info("main", "Loaded zones with an SOA record", "zones", zones)
+	}
+	go authority.Run(ctx)
+
+	// Order the middleware queries run through
+	chain, err := dns.NewChain(cfg.Middleware)
+	if err != nil {
+		logging.Error("main", "Invalid middleware configuration", err, "available", dns.MiddlewareNames())
+		os.Exit(1)
+	}
+	logging.Info("main", "Query middleware chain", "middleware", chain.Names())
+
 	// Create DNS server
 	dnsConfig := &dns.Config{
 		Port:          cfg.DNSPort,
 		UDPTimeout:    5 * time.Second,
 		TCPTimeout:    10 * time.Second,
+		QueryTimeout:  cfg.Timeouts.Query,
 		MaxConcurrent: cfg.MaxConcurrentQueries,
+		TTLJitter:     cfg.TTLJitter,
+		TTLOverrides:  ttlOverrides,
+		AnswerLimits:  answerLimits,
+		LatencyWindow: cfg.Stats.LatencyWindow,
+		DedupWindow:   cfg.DedupWindow,
+		QueryCounter:  queryCounter,
+		Analytics:     analytics,
+
+		MaxResponseSize:  cfg.MaxResponseSize,
+		NXDomainAnalyzer: nxAnalyzer,
+		RateLimiter:      limiter,
+		Forwarder:        fwd,
+		WireCache:        wireCache,
+		Reverse:          reverseSynth,
+		Hosts:            hostsTable,
+		Blocklist:        blocker,
+		Groups:           groups,
+		Plugins:          plugins,
+		ZoneTransfer:     zoneTransfer,
+		ReturnAll:        returnAll,
+		Rcodes:           rcodes,
+		Authority:        authority,
+		Mirror:           shadow,
+		Chaos:            injector,
+		Chain:            chain,
+		Catalog:          catalogZone,
+		PacketConn:       packetConn,
+		Listener:         listener,
 	}
 
 	dnsServer := dns.NewServer(finalStorage, dnsConfig)
@@ -146,8 +842,107 @@ func main() {
 		}
 	}()
 
-	// Start statistics reporting
-	go reportStats(ctx, dnsServer, finalStorage, cfg)
+	// Statistics are sampled for the admin API and metrics exporters
+	collector := monitor.NewCollector(dnsServer, finalStorage, pgStorage, breaker, cacheOnly, shadowReads, pool)
+	go collector.Run(ctx, cfg.Stats.Interval)
+
+	// Push metrics to a StatsD agent
+	if cfg.StatsD.Enabled {
+		exporter, err := monitor.NewStatsDExporter(collector, &monitor.StatsDConfig{
+			Address:  cfg.StatsD.Address,
+			Prefix:   cfg.StatsD.Prefix,
+			Tags:     cfg.StatsD.Tags,
+			Interval: cfg.StatsD.Interval,
+		})
+		if err != nil {
+			logging.Error("main", "Failed to start StatsD exporter", err, "address", cfg.StatsD.Address)
+		} else {
+			go exporter.Run(ctx)
+			logging.Info("main", "StatsD metrics export enabled", "address", cfg.StatsD.Address, "interval", cfg.StatsD.Interval)
+		}
+	}
+
+	// Push metrics to Graphite
+	if cfg.Graphite.Enabled {
+		exporter := monitor.NewGraphiteExporter(collector, &monitor.GraphiteConfig{
+			Address:  cfg.Graphite.Address,
+			Prefix:   cfg.Graphite.Prefix,
+			Interval: cfg.Graphite.Interval,
+		})
+		go exporter.Run(ctx)
+		logging.Info("main", "Graphite metrics export enabled", "address", cfg.Graphite.Address, "interval", cfg.Graphite.Interval)
+	}
+
+	// Serve the Kubernetes external-dns webhook provider API
+	if cfg.ExternalDNS.Enabled {
+		provider := externaldns.NewProvider(pgStorage, applier,
+			cfg.ExternalDNS.Owner, cfg.ExternalDNS.Domains, cfg.ExternalDNS.DefaultTTL)
+		webhook := externaldns.NewServer(provider, cfg.ExternalDNS.Address)
+		go func() {
+			if err := webhook.Start(ctx); err != nil {
+				logging.Error("main", "external-dns webhook server error", err)
+			}
+		}()
+	}
+
+	// Reconcile DNSRecord/DNSZone custom resources into PostgreSQL
+	if cfg.Operator.Enabled {
+		controller, err := operator.NewController(pgStorage, applier,
+			cfg.Operator.Namespace, cfg.Operator.ResyncInterval)
+		if err != nil {
+			logging.Error("main", "Failed to start Kubernetes controller", err)
+		} else {
+			go controller.Run(ctx)
+			logging.Info("main", "Kubernetes controller enabled", "namespace", cfg.Operator.Namespace, "resync", cfg.Operator.ResyncInterval)
+		}
+	}
+
+	// Register records for labeled Docker containers
+	if cfg.Docker.Enabled {
+		watcher := dockerwatch.NewWatcher(pgStorage, applier,
+			cfg.Docker.Socket, cfg.Docker.LabelPrefix, cfg.Docker.DefaultTTL)
+		go watcher.Run(ctx)
+		logging.Info("main", "Docker container registration enabled", "socket", cfg.Docker.Socket, "label_prefix", cfg.Docker.LabelPrefix)
+	}
+
+	// Start admin HTTP server (stats and management endpoints)
+	if cfg.Admin.Enabled {
+		adminServer := admin.NewServer(&admin.Config{
+			Address:      cfg.Admin.Address,
+			ReadTimeout:  cfg.Admin.ReadTimeout,
+			WriteTimeout: cfg.Admin.WriteTimeout,
+		})
+		adminServer.RegisterStats(collector)
+		adminServer.RegisterLogLevels(logging.GetLogger())
+		adminServer.RegisterCache(cacheFlusher, cacheInspector)
+		adminServer.RegisterReadOnly(pgStorage)
+		if cacheOnly != nil {
+			adminServer.RegisterCacheOnly(cacheOnly)
+		}
+		if blocker != nil {
+			adminServer.RegisterBlocklist(blocker)
+		}
+		if cfg.Admin.RecordsAPI {
+			var recordStore admin.RecordStore = finalStorage
+			if cfg.AutoPTR.Enabled {
+				recordStore = storage.NewAutoPTRStorage(finalStorage, pgStorage, invalidate, cfg.AutoPTR.Zones)
+				logging.Info("main", "Automatic PTR records enabled", "zones", cfg.AutoPTR.Zones)
+			}
+			if plugins.HasMutationHooks() {
+				recordStore = plugins.Store(recordStore)
+			}
+			adminServer.RegisterRecords(recordStore, pgStorage)
+			adminServer.RegisterChangesets(applier)
+			adminServer.RegisterZones(pgStorage)
+			logging.Info("main", "Record management API enabled", "address", cfg.Admin.Address)
+		}
+
+		go func() {
+			if err := adminServer.Start(ctx); err != nil {
+				logging.Error("main", "Admin server error", err)
+			}
+		}()
+	}
 
 	// Wait for shutdown signal
 	<-sigChan
@@ -194,76 +989,6 @@ func main() {
 	}()
 }
 
-// reportStats periodically reports server and cache statistics
-func reportStats(ctx context.Context, dnsServer *dns.Server, storage storage.Storage, cfg *config.Config) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			// Get DNS server stats
-			dnsStats := dnsServer.GetStats()
-
-			log.Printf("DNS Stats - Queries: %d, Answered: %d, NXDOMAIN: %d, Errors: %d",
-				dnsStats.QueriesReceived, dnsStats.QueriesAnswered,
-				dnsStats.QueriesNXDomain, dnsStats.QueriesError)
-
-			log.Printf("Query Types - A: %d, AAAA: %d, CNAME: %d, MX: %d, TXT: %d, NS: %d, SOA: %d, PTR: %d, SRV: %d, CAA: %d, Other: %d",
-				dnsStats.TypeA, dnsStats.TypeAAAA, dnsStats.TypeCNAME,
-				dnsStats.TypeMX, dnsStats.TypeTXT, dnsStats.TypeNS, dnsStats.TypeSOA, dnsStats.TypePTR, dnsStats.TypeSRV, dnsStats.TypeCAA, dnsStats.TypeOther)
-
-			// Try to get cache stats using a type assertion that will work
-			// We need to check if the storage has a GetCacheStats method
-			type CacheStatsProvider interface {
-				GetCacheStats() cache.Stats
-			}
-
-			// Cache statistics reporting
-			if cfg.Cache.Enabled {
-				if cfg.Redis.Enabled {
-					// Three-tier cache stats
-					logging.Info("main", "Cache Status: Three-tier (Memory + Redis)")
-
-					// Try to get memory cache stats
-					type MemoryCacheProvider interface {
-						GetCacheStats() cache.Stats
-					}
-
-					// For now, log that we need to implement Redis-specific stats
-					logging.Info("main", "L1 Cache (Memory): Stats collection needs implementation")
-					logging.Info("main", "L2 Cache (Redis): Connected to %s", "details", fmt.Sprintf("L2 Cache (Redis): Connected to %s", cfg.Redis.Address))
-
-					// Check Redis connectivity
-					if err := redis.PingClient(cfg.Redis.ClientName); err != nil {
-						logging.Info("main", "L2 Cache (Redis): Connection error - %v", "details", fmt.Sprintf("L2 Cache (Redis): Connection error - %v", err))
-					} else {
-						logging.Info("main", "L2 Cache (Redis): Connection healthy")
-					}
-				} else {
-					// Two-tier cache stats
-					logging.Info("main", "Cache Status: Two-tier (Memory only)")
-
-					type CacheStatsProvider interface {
-						GetCacheStats() cache.Stats
-					}
-
-					if cacheProvider, ok := storage.(CacheStatsProvider); ok {
-						cacheStats := cacheProvider.GetCacheStats()
-						log.Printf("L1 Cache Stats - Entries: %d, Hits: %d, Misses: %d, Hit Rate: %.2f%%, Evictions: %d",
-							cacheStats.Entries, cacheStats.Hits, cacheStats.Misses,
-							cacheStats.HitRate, cacheStats.Evictions)
-					}
-				}
-			} else {
-				logging.Info("main", "Cache Status: Disabled (Direct database access)")
-			}
-		}
-	}
-}
-
 // printStartupInfo displays configuration information at startup
 func printStartupInfo(cfg *config.Config) {
 	fmt.Printf(`
@@ -289,3 +1014,162 @@ Log Level: %s
 		cfg.LogLevel,
 	)
 }
+
+// newShadowStorageConfig returns the primary's storage configuration with
+// the shadow database's connection settings, where they're set
+func newShadowStorageConfig(cfg *config.Config, primary *storage.Config) *storage.Config {
+	shadow := *primary
+	shadow.Host = cfg.ShadowRead.Host
+	shadow.Hosts = nil
+	shadow.TargetSessionAttrs = "any"
+	if cfg.ShadowRead.Port != 0 {
+		shadow.Port = cfg.ShadowRead.Port
+	}
+	if cfg.ShadowRead.User != "" {
+		shadow.User = cfg.ShadowRead.User
+	}
+	if cfg.ShadowRead.Password != "" {
+		shadow.Password = cfg.ShadowRead.Password
+	}
+	if cfg.ShadowRead.DBName != "" {
+		shadow.DBName = cfg.ShadowRead.DBName
+	}
+	if cfg.ShadowRead.SSLMode != "" {
+		shadow.SSLMode = cfg.ShadowRead.SSLMode
+	}
+	return &shadow
+}
+
+// migrateOptions selects the optional schema migrations from the database
+// configuration
+func migrateOptions(cfg *config.Config) *storage.MigrateOptions {
+	return &storage.MigrateOptions{
+		Partitions:       cfg.Database.Partitions,
+		RemoveDuplicates: cfg.Database.RemoveDuplicates,
+	}
+}
+
+// newStorageConfig converts the database configuration to storage
+// configuration. Reachability is left to the caller
+func newStorageConfig(cfg *config.Config) (*storage.Config, error) {
+	storageConfig := &storage.Config{
+		Host:            cfg.Database.Host,
+		Port:            cfg.Database.Port,
+		User:            cfg.Database.User,
+		Password:        cfg.Database.Password,
+		DBName:          cfg.Database.DBName,
+		SSLMode:         cfg.Database.SSLMode,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+
+		StatementCacheSize: cfg.Database.StatementCacheSize,
+
+		Hosts:              cfg.Database.Hosts,
+		TargetSessionAttrs: cfg.Database.TargetSessionAttrs,
+
+		SlowQueryThreshold: cfg.Database.SlowQueryThreshold,
+		LookupTimeout:      cfg.Timeouts.Storage,
+		WildcardLookups:    cfg.Database.WildcardLookups,
+		RRSetTTL:           cfg.Database.RRSetTTL,
+
+		Deferred: true,
+	}
+
+	var err error
+	storageConfig.SerialPolicy, err = models.NewSerialPolicy(cfg.Database.SerialStrategy, cfg.Database.SerialStrategies)
+	if err != nil {
+		return nil, err
+	}
+	return storageConfig, nil
+}
+
+// redisClientOptions converts the Redis configuration to client options
+func redisClientOptions(cfg *config.RedisConfig) *redis.ClientOptions {
+	opts := &redis.ClientOptions{
+		Address:           cfg.Address,
+		Username:          cfg.Username,
+		Password:          cfg.Password,
+		Database:          cfg.Database,
+		PoolSize:          cfg.PoolSize,
+		MinIdleConns:      cfg.MinIdleConns,
+		ConnMaxIdleTime:   cfg.ConnMaxIdleTime,
+		DialTimeout:       cfg.DialTimeout,
+		MasterName:        cfg.MasterName,
+		SentinelAddresses: cfg.SentinelAddresses,
+		SentinelPassword:  cfg.SentinelPassword,
+	}
+	if cfg.TLSEnabled {
+		opts.TLS = &redis.TLSOptions{
+			CAFile:             cfg.TLSCAFile,
+			CertFile:           cfg.TLSCertFile,
+			KeyFile:            cfg.TLSKeyFile,
+			ServerName:         cfg.TLSServerName,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		}
+	}
+	return opts
+}
+
+// buildPolicyGroups creates the configured policy groups, giving each its
+// blocklist view and, when it sets upstreams, a forwarder of its own
+func buildPolicyGroups(ctx context.Context, cfg *config.Config, blocker *blocklist.Manager) (*policy.Groups, error) {
+	specs, err := policy.ParseRules(cfg.Policy.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := policy.NewGroups()
+	for _, spec := range specs {
+		group := &policy.Group{Name: spec.Name, LogLevel: spec.LogLevel}
+
+		if blocker != nil && spec.Blocking {
+			group.Blocker = blocker.Default()
+			if len(spec.Feeds) > 0 || spec.Response != "" {
+				var response *blocklist.Response
+				if spec.Response != "" {
+					if response, err = blocklist.ParseResponse(spec.Response, cfg.Blocklist.TTL); err != nil {
+						return nil, fmt.Errorf("policy group %s: %w", spec.Name, err)
+					}
+				}
+				if group.Blocker, err = blocker.NewView(spec.Feeds, response); err != nil {
+					return nil, fmt.Errorf("policy group %s: %w", spec.Name, err)
+				}
+			}
+		}
+
+		if len(spec.Upstreams) > 0 {
+			group.Forwarder, err = forwarder.NewForwarder(&forwarder.Config{
+				Upstreams:     spec.Upstreams,
+				Timeout:       cfg.Forwarder.Timeout,
+				AllowedCIDRs:  cfg.Forwarder.AllowedCIDRs,
+				CAFile:        cfg.Forwarder.CAFile,
+				ProbeInterval: cfg.Forwarder.ProbeInterval,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("policy group %s: %w", spec.Name, err)
+			}
+			go group.Forwarder.Run(ctx)
+		}
+
+		if len(spec.NAT) > 0 {
+			if group.NAT, err = policy.NewNAT(spec.NAT); err != nil {
+				return nil, fmt.Errorf("policy group %s: %w", spec.Name, err)
+			}
+		}
+
+		// Group levels below the configured ones have to get past the
+		// logger's fast-path check
+		if spec.LogLevel != "" {
+			logging.GetLogger().EnableContextLevel(spec.LogLevel)
+		}
+
+		if err := groups.Add(group, spec.CIDRs); err != nil {
+			return nil, err
+		}
+		logging.Info("main", "Policy group configured", "group", spec.Name, "cidrs", spec.CIDRs,
+			"blocking", group.Blocker != nil, "upstreams", spec.Upstreams, "nat", spec.NAT, "log_level", spec.LogLevel)
+	}
+	return groups, nil
+}
@@ -0,0 +1,64 @@
+// cmd/dns-server/startup.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"errantdns.io/internal/config"
+	"errantdns.io/internal/logging"
+)
+
+// connectAtStartup calls connect once in "fail" mode, and until it succeeds
+// or the retry timeout passes in "retry" mode. In "degraded" mode a failure
+// is logged and connect is retried in the background, so the server can
+// start serving from its caches straight away
+func connectAtStartup(ctx context.Context, dependency string, policy *config.StartupConfig, connect func(context.Context) error) error {
+	err := connect(ctx)
+	if err == nil {
+		return nil
+	}
+
+	switch policy.Mode {
+	case "retry":
+		return retryConnect(ctx, dependency, policy, policy.RetryTimeout, err, connect)
+	case "degraded":
+		logging.Warn("main", "Dependency unavailable; starting degraded and reconnecting in the background",
+			"dependency", dependency, "error", err.Error())
+		go retryConnect(ctx, dependency, policy, 0, err, connect)
+		return nil
+	}
+	return err
+}
+
+// retryConnect retries connect with exponential backoff until it succeeds,
+// ctx is cancelled, or timeout passes (0 never times out)
+func retryConnect(ctx context.Context, dependency string, policy *config.StartupConfig, timeout time.Duration, err error, connect func(context.Context) error) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	delay := policy.RetryInitial
+	for attempt := 2; ; attempt++ {
+		logging.Warn("main", "Dependency unavailable, retrying",
+			"dependency", dependency, "error", err.Error(), "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-deadline:
+			return fmt.Errorf("%s still unavailable after %v: %w", dependency, timeout, err)
+		case <-time.After(delay):
+		}
+
+		if err = connect(ctx); err == nil {
+			logging.Info("main", "Dependency available", "dependency", dependency, "attempts", attempt)
+			return nil
+		}
+		delay = min(delay*2, policy.RetryMax)
+	}
+}
@@ -0,0 +1,297 @@
+// cmd/dns-verify/main.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/admin"
+	errantdns "errantdns.io/internal/dns"
+	"errantdns.io/internal/models"
+)
+
+const usage = `usage: dns-verify [flags]
+
+Queries a running server for a random sample of stored record sets and
+compares the answers with the records in the database, as listed by the
+admin API. Exits with status 1 if any answer disagrees.
+
+A served record that isn't stored, a stored record set answered with
+nothing, and an MX, NS, SRV, or DNSSEC key set (DS, DNSKEY, CDS, CDNSKEY)
+answered incompletely are mismatches.
+Other types are answered with one record chosen from the set, which only
+needs to be stored. TTLs aren't compared, since caches count them down.
+`
+
+// rrsetKey identifies a stored record set
+type rrsetKey struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Mismatch is a record set whose answer disagrees with storage
+type Mismatch struct {
+	rrsetKey
+	Rcode   string   `json:"rcode"`
+	Missing []string `json:"missing,omitempty"` // stored records not served
+	Extra   []string `json:"extra,omitempty"`   // served records not stored
+}
+
+// Report summarizes a verification run, also emitted as JSON with -json
+type Report struct {
+	Server     string     `json:"server"`
+	Records    int        `json:"records"` // stored records sampled from
+	Checked    int        `json:"checked"` // record sets compared
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// completeTypes are answered with every live record of the set
+var completeTypes = map[string]bool{
+	"MX": true, "NS": true, "SRV": true,
+	"DS": true, "DNSKEY": true, "CDS": true, "CDNSKEY": true,
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	adminURL := flag.String("admin", "http://127.0.0.1:8053", "admin server URL")
+	server := flag.String("server", "127.0.0.1:53", "DNS server address")
+	sample := flag.Int("sample", 200, "record sets to check")
+	zone := flag.String("zone", "", "only check records in this zone")
+	recordType := flag.String("type", "", "only check records of this type")
+	recheck := flag.Duration("recheck", time.Second, "wait this long and check a mismatched set again before reporting it (0 to report at once)")
+	timeout := flag.Duration("timeout", 2*time.Second, "DNS query timeout")
+	jsonOutput := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	v := &verifier{
+		adminURL: *adminURL,
+		server:   *server,
+		client:   &dns.Client{Timeout: *timeout},
+	}
+
+	keys, total, err := v.sample(ctx, *zone, *recordType, *sample)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	report := &Report{Server: *server, Records: total, Mismatches: []Mismatch{}}
+	for _, key := range keys {
+		mismatch, err := v.check(ctx, key)
+		if err == nil && mismatch != nil && *recheck > 0 {
+			time.Sleep(*recheck)
+			mismatch, err = v.check(ctx, key)
+		}
+		if err != nil {
+			fatalf("%s %s: %v", key.Name, key.Type, err)
+		}
+
+		report.Checked++
+		if mismatch != nil {
+			report.Mismatches = append(report.Mismatches, *mismatch)
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printReport(report)
+	}
+
+	if len(report.Mismatches) > 0 {
+		os.Exit(1)
+	}
+}
+
+// verifier compares a server's answers with its admin API's records
+type verifier struct {
+	adminURL string
+	server   string
+	client   *dns.Client
+}
+
+// sample pages through the stored records, choosing up to n record sets at
+// random, and returns them with the number of records seen
+func (v *verifier) sample(ctx context.Context, zone, recordType string, n int) ([]rrsetKey, int, error) {
+	query := url.Values{"limit": {"1000"}}
+	if zone != "" {
+		query.Set("zone", zone)
+	}
+	if recordType != "" {
+		query.Set("type", recordType)
+	}
+
+	// Reservoir sampling over records, then deduplicated by set, keeps
+	// memory flat however many records are stored
+	var reservoir []rrsetKey
+	total := 0
+	for {
+		page, next, err := v.listRecords(ctx, query)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, record := range page {
+			key := rrsetKey{models.NormalizeDomainName(record.Name), strings.ToUpper(record.RecordType)}
+			total++
+			if len(reservoir) < n {
+				reservoir = append(reservoir, key)
+			} else if i := rand.IntN(total); i < n {
+				reservoir[i] = key
+			}
+		}
+		if next == "" {
+			break
+		}
+		query.Set("after", next)
+	}
+
+	slices.SortFunc(reservoir, func(a, b rrsetKey) int {
+		return strings.Compare(a.Name+" "+a.Type, b.Name+" "+b.Type)
+	})
+	return slices.Compact(reservoir), total, nil
+}
+
+// check compares the answer for one record set with the stored records,
+// returning nil if they agree
+func (v *verifier) check(ctx context.Context, key rrsetKey) (*Mismatch, error) {
+	qtype := errantdns.RecordQtype(key.Type)
+	if qtype == 0 {
+		return nil, fmt.Errorf("unknown record type")
+	}
+
+	stored, _, err := v.listRecords(ctx, url.Values{"name": {key.Name}, "type": {key.Type}})
+	if err != nil {
+		return nil, err
+	}
+	expected := make(map[string]bool)
+	now := time.Now()
+	for _, record := range stored {
+		if !record.IsActive(now) {
+			continue
+		}
+		rr, err := errantdns.ResourceRecord(record, qtype)
+		if err != nil {
+			return nil, fmt.Errorf("record %d can't be served: %w", record.ID, err)
+		}
+		if rr != nil {
+			expected[rdata(rr)] = true
+		}
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(key.Name), qtype)
+	msg.RecursionDesired = false
+	resp, _, err := v.client.ExchangeContext(ctx, msg, v.server)
+	if err == nil && resp.Truncated {
+		tcp := &dns.Client{Net: "tcp", Timeout: v.client.Timeout}
+		resp, _, err = tcp.ExchangeContext(ctx, msg, v.server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	served := make(map[string]bool)
+	for _, rr := range resp.Answer {
+		header := rr.Header()
+		if header.Rrtype == qtype && strings.EqualFold(header.Name, dns.Fqdn(key.Name)) {
+			served[rdata(rr)] = true
+		}
+	}
+
+	mismatch := &Mismatch{rrsetKey: key, Rcode: dns.RcodeToString[resp.Rcode]}
+	for data := range served {
+		if !expected[data] {
+			mismatch.Extra = append(mismatch.Extra, data)
+		}
+	}
+	if len(served) == 0 || completeTypes[key.Type] {
+		for data := range expected {
+			if !served[data] {
+				mismatch.Missing = append(mismatch.Missing, data)
+			}
+		}
+	}
+	if len(mismatch.Missing) == 0 && len(mismatch.Extra) == 0 {
+		return nil, nil
+	}
+	slices.Sort(mismatch.Missing)
+	slices.Sort(mismatch.Extra)
+	return mismatch, nil
+}
+
+// rdata renders an RR's data for comparison, without its header
+func rdata(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// listRecords fetches one page of GET /records, returning the cursor of
+// the next page or "" if it was the last
+func (v *verifier) listRecords(ctx context.Context, query url.Values) ([]*models.DNSRecord, string, error) {
+	path := "/records?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(v.adminURL, "/")+path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s returned HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var records []*models.DNSRecord
+	if err := json.Unmarshal(body, &records); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return records, resp.Header.Get(admin.NextCursorHeader), nil
+}
+
+// printReport writes the mismatches as a table, one stored or served
+// record per line
+func printReport(report *Report) {
+	if len(report.Mismatches) > 0 {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tTYPE\tRCODE\tPROBLEM\tDATA")
+		for _, m := range report.Mismatches {
+			for _, data := range m.Missing {
+				fmt.Fprintf(writer, "%s\t%s\t%s\tnot served\t%s\n", m.Name, m.Type, m.Rcode, data)
+			}
+			for _, data := range m.Extra {
+				fmt.Fprintf(writer, "%s\t%s\t%s\tnot stored\t%s\n", m.Name, m.Type, m.Rcode, data)
+			}
+		}
+		writer.Flush()
+		fmt.Println()
+	}
+	fmt.Printf("Checked %d record sets (sampled from %d records) against %s: %d mismatched\n",
+		report.Checked, report.Records, report.Server, len(report.Mismatches))
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dns-verify: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,161 @@
+// cmd/dns-zone-check/main.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"errantdns.io/internal/admin"
+	"errantdns.io/internal/zonecheck"
+)
+
+const usage = `usage: dns-zone-check [-admin URL | -input FILE] [-json] [-strict] <zone>...
+
+Checks each zone for a missing SOA or NS at the apex, CNAME conflicts,
+dangling CNAME/MX/SRV targets, duplicate records, and TTL mismatches.
+Exits with status 1 if any zone has errors (or warnings, with -strict).
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	adminURL := flag.String("admin", "http://127.0.0.1:8053", "admin server URL")
+	input := flag.String("input", "", "check the records of this export document instead of asking the server (- for stdin)")
+	jsonOutput := flag.Bool("json", false, "print the reports as JSON")
+	strict := flag.Bool("strict", false, "exit with status 1 on warnings too")
+	timeout := flag.Duration("timeout", 30*time.Second, "request timeout")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var export *admin.RecordExport
+	if *input != "" {
+		var err error
+		if export, err = readExport(*input); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	var reports []*zonecheck.Report
+	for _, zone := range flag.Args() {
+		if export != nil {
+			reports = append(reports, zonecheck.Check(zone, export.Records, time.Now()))
+			continue
+		}
+
+		var report zonecheck.Report
+		if err := get(ctx, *adminURL, "/zones/"+url.PathEscape(zone)+"/check", &report); err != nil {
+			fatalf("%v", err)
+		}
+		reports = append(reports, &report)
+	}
+
+	failed := false
+	for _, report := range reports {
+		if report.Errors > 0 || (*strict && report.Warnings > 0) {
+			failed = true
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(reports)
+	} else {
+		for _, report := range reports {
+			printReport(report)
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// printReport writes a report as a table of findings
+func printReport(report *zonecheck.Report) {
+	fmt.Printf("%s: %d records, %d errors, %d warnings\n", report.Zone, report.Records, report.Errors, report.Warnings)
+	if len(report.Findings) == 0 {
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "SEVERITY\tCHECK\tNAME\tTYPE\tMESSAGE")
+	for _, finding := range report.Findings {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", finding.Severity, finding.Check, finding.Name, finding.Type, finding.Message)
+	}
+	writer.Flush()
+	fmt.Println()
+}
+
+// readExport reads an export document from a file, or stdin for "-".
+// Records are checked as the server would store them
+func readExport(path string) (*admin.RecordExport, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var export admin.RecordExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, record := range export.Records {
+		if record != nil {
+			record.Normalize()
+		}
+	}
+	return &export, nil
+}
+
+// get decodes the JSON response of an admin endpoint into v, failing on
+// any status but 200 OK
+func get(ctx context.Context, adminURL, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(adminURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "dns-zone-check: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,84 @@
+// internal/admin/blocklist.go
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"errantdns.io/internal/blocklist"
+	"errantdns.io/internal/logging"
+)
+
+// AllowlistEntry is the body of POST /blocklist/allowlist
+type AllowlistEntry struct {
+	Entry string `json:"entry"`
+}
+
+// RegisterBlocklist exposes feed status at GET /blocklist, starts an
+// immediate download of every feed with POST /blocklist/refresh, and
+// manages allowlist entries under /blocklist/allowlist
+func (s *Server) RegisterBlocklist(manager *blocklist.Manager) {
+	s.HandleFunc("GET /blocklist", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, manager.Status())
+	})
+
+	s.HandleFunc("POST /blocklist/refresh", func(w http.ResponseWriter, r *http.Request) {
+		manager.Refresh()
+		logging.Info("admin", "Blocklist refresh requested", "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusAccepted, map[string]string{"status": "refreshing"})
+	})
+
+	allowlist := manager.Allowlist()
+
+	s.HandleFunc("GET /blocklist/allowlist", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string][]string{"entries": allowlist.Entries()})
+	})
+
+	s.HandleFunc("POST /blocklist/allowlist", func(w http.ResponseWriter, r *http.Request) {
+		var body AllowlistEntry
+		if err := decodeBody(w, r, maxRecordBodyBytes, &body); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		added, err := allowlist.Add(body.Entry)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, blocklist.ErrInvalidEntry) {
+				status = http.StatusBadRequest
+			}
+			WriteError(w, status, err)
+			return
+		}
+		if !added {
+			WriteJSON(w, http.StatusOK, map[string]string{"status": "exists"})
+			return
+		}
+
+		logging.Info("admin", "Allowlist entry added", "entry", body.Entry, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusCreated, map[string]string{"status": "added"})
+	})
+
+	s.HandleFunc("DELETE /blocklist/allowlist", func(w http.ResponseWriter, r *http.Request) {
+		entry := r.URL.Query().Get("entry")
+		if entry == "" {
+			WriteError(w, http.StatusBadRequest, errors.New("entry parameter is required"))
+			return
+		}
+
+		if err := allowlist.Remove(entry); err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, blocklist.ErrInvalidEntry):
+				status = http.StatusBadRequest
+			case errors.Is(err, blocklist.ErrEntryNotFound):
+				status = http.StatusNotFound
+			}
+			WriteError(w, status, err)
+			return
+		}
+
+		logging.Info("admin", "Allowlist entry removed", "entry", entry, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	})
+}
@@ -0,0 +1,118 @@
+// internal/admin/cache.go
+package admin
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/storage"
+)
+
+const (
+	defaultCacheEntriesLimit = 100
+	maxCacheEntriesLimit     = 1000
+)
+
+// CacheEntriesPage is one page of GET /cache/entries
+type CacheEntriesPage struct {
+	Total   int                  `json:"total"`
+	Offset  int                  `json:"offset"`
+	Entries []storage.CacheEntry `json:"entries"`
+}
+
+// tierOrder lists entries from the tier nearest the client outward
+var tierOrder = map[storage.CacheSource]int{
+	storage.SourceWire:   0,
+	storage.SourceMemory: 1,
+	storage.SourceRedis:  2,
+	storage.SourceStale:  3,
+}
+
+// RegisterCache exposes the caches:
+//
+//	GET  /cache/entries  list cached entries (filters: tier, name, apex, type; paged with limit and offset)
+//	POST /cache/flush    drop cached answers
+//
+// The flush body selects a name and optional type ({"name":
+// "www.example.com", "type": "A"}), an apex domain and everything below
+// it ({"apex": "example.com"}), or everything ({"all": true})
+func (s *Server) RegisterCache(flusher storage.Flusher, inspector storage.CacheInspector) {
+	s.HandleFunc("GET /cache/entries", func(w http.ResponseWriter, r *http.Request) {
+		filter, offset, limit, err := parseCacheQuery(r.URL.Query())
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		entries, err := inspector.CacheEntries(r.Context(), filter)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		slices.SortFunc(entries, func(a, b storage.CacheEntry) int {
+			return cmp.Or(cmp.Compare(tierOrder[a.Tier], tierOrder[b.Tier]), cmp.Compare(a.Key, b.Key))
+		})
+
+		page := CacheEntriesPage{Total: len(entries), Offset: offset, Entries: []storage.CacheEntry{}}
+		if offset < len(entries) {
+			page.Entries = entries[offset:min(offset+limit, len(entries))]
+		}
+		WriteJSON(w, http.StatusOK, page)
+	})
+
+	s.HandleFunc("POST /cache/flush", func(w http.ResponseWriter, r *http.Request) {
+		var flush storage.Flush
+		if err := decodeBody(w, r, maxRecordBodyBytes, &flush); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := flush.Validate(); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := flusher.Flush(r.Context(), flush); err != nil {
+			logging.Error("admin", "Cache flush failed", err, "flush", flush.String())
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		logging.Info("admin", "Cache flushed", "flush", flush.String(), "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "flushed"})
+	})
+}
+
+// parseCacheQuery reads the filter and page of GET /cache/entries
+func parseCacheQuery(query url.Values) (storage.CacheFilter, int, int, error) {
+	filter := storage.CacheFilter{
+		Tier: storage.CacheSource(query.Get("tier")),
+		Name: query.Get("name"),
+		Apex: query.Get("apex"),
+		Type: query.Get("type"),
+	}
+	if err := filter.Validate(); err != nil {
+		return filter, 0, 0, err
+	}
+
+	offset, limit := 0, defaultCacheEntriesLimit
+	if param := query.Get("offset"); param != "" {
+		val, err := strconv.Atoi(param)
+		if err != nil || val < 0 {
+			return filter, 0, 0, fmt.Errorf("invalid offset: %q", param)
+		}
+		offset = val
+	}
+	if param := query.Get("limit"); param != "" {
+		val, err := strconv.Atoi(param)
+		if err != nil || val <= 0 || val > maxCacheEntriesLimit {
+			return filter, 0, 0, fmt.Errorf("invalid limit: %q (1 to %d)", param, maxCacheEntriesLimit)
+		}
+		limit = val
+	}
+	return filter, offset, limit, nil
+}
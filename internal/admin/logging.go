@@ -0,0 +1,67 @@
+// internal/admin/logging.go
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"errantdns.io/internal/logging"
+)
+
+// LogLevels is the body of GET and PUT /logging/levels. In a PUT, an empty
+// Default keeps the default level and an empty component level removes
+// that component's override
+type LogLevels struct {
+	Default    logging.LogLevel            `json:"default"`
+	Components map[string]logging.LogLevel `json:"components"`
+}
+
+// RegisterLogLevels exposes the logger's levels at GET /logging/levels and
+// changes them at runtime with PUT /logging/levels
+func (s *Server) RegisterLogLevels(logger *logging.Logger) {
+	current := func() *LogLevels {
+		def, components := logger.Levels()
+		return &LogLevels{Default: def, Components: components}
+	}
+
+	s.HandleFunc("GET /logging/levels", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, current())
+	})
+
+	s.HandleFunc("PUT /logging/levels", func(w http.ResponseWriter, r *http.Request) {
+		var levels LogLevels
+		if err := decodeBody(w, r, maxRecordBodyBytes, &levels); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if levels.Default != "" {
+			level, err := logging.ParseLevel(string(levels.Default))
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+			levels.Default = level
+		}
+		for component, name := range levels.Components {
+			if component == "" {
+				WriteError(w, http.StatusBadRequest, errors.New("empty component name"))
+				return
+			}
+			if name == "" {
+				continue
+			}
+			level, err := logging.ParseLevel(string(name))
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, fmt.Errorf("component %q: %w", component, err))
+				return
+			}
+			levels.Components[component] = level
+		}
+
+		logger.SetLevels(levels.Default, levels.Components)
+		logging.Info("admin", "Log levels changed", "default", levels.Default, "components", levels.Components, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, current())
+	})
+}
@@ -0,0 +1,100 @@
+// internal/admin/maintenance.go
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/storage"
+)
+
+// ReadOnlySwitch turns rejection of record writes on and off
+type ReadOnlySwitch interface {
+	ReadOnly() bool
+	SetReadOnly(readOnly bool)
+}
+
+// ReadOnlyMode is the body of GET and PUT /maintenance/read-only. Reason
+// is only logged
+type ReadOnlyMode struct {
+	ReadOnly *bool  `json:"read_only"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RegisterReadOnly reports whether record writes are rejected at GET
+// /maintenance/read-only and switches it at runtime with PUT. While
+// read-only, writes through the records API answer 503 Service Unavailable
+// and queries are answered as usual
+func (s *Server) RegisterReadOnly(mode ReadOnlySwitch) {
+	current := func() *ReadOnlyMode {
+		readOnly := mode.ReadOnly()
+		return &ReadOnlyMode{ReadOnly: &readOnly}
+	}
+
+	s.HandleFunc("GET /maintenance/read-only", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, current())
+	})
+
+	s.HandleFunc("PUT /maintenance/read-only", func(w http.ResponseWriter, r *http.Request) {
+		var body ReadOnlyMode
+		if err := decodeBody(w, r, maxRecordBodyBytes, &body); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.ReadOnly == nil {
+			WriteError(w, http.StatusBadRequest, errors.New("read_only is required"))
+			return
+		}
+
+		mode.SetReadOnly(*body.ReadOnly)
+		if *body.ReadOnly {
+			logging.Warn("admin", "Record writes disabled", "reason", body.Reason, "remote_addr", r.RemoteAddr)
+		} else {
+			logging.Info("admin", "Record writes enabled", "reason", body.Reason, "remote_addr", r.RemoteAddr)
+		}
+		WriteJSON(w, http.StatusOK, current())
+	})
+}
+
+// CacheOnlySwitch enters and leaves cache-only mode
+type CacheOnlySwitch interface {
+	Status() storage.CacheOnlyStatus
+	SetCacheOnly(active bool, reason string)
+}
+
+// CacheOnlyMode is the body of PUT /maintenance/cache-only
+type CacheOnlyMode struct {
+	CacheOnly *bool  `json:"cache_only"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// RegisterCacheOnly reports cache-only mode at GET /maintenance/cache-only
+// and enters or leaves it at runtime with PUT. Entered by hand, it lasts
+// until it's left by hand; PUT with false also ends a mode entered after
+// storage failures
+func (s *Server) RegisterCacheOnly(mode CacheOnlySwitch) {
+	s.HandleFunc("GET /maintenance/cache-only", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, mode.Status())
+	})
+
+	s.HandleFunc("PUT /maintenance/cache-only", func(w http.ResponseWriter, r *http.Request) {
+		var body CacheOnlyMode
+		if err := decodeBody(w, r, maxRecordBodyBytes, &body); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.CacheOnly == nil {
+			WriteError(w, http.StatusBadRequest, errors.New("cache_only is required"))
+			return
+		}
+
+		mode.SetCacheOnly(*body.CacheOnly, body.Reason)
+		if *body.CacheOnly {
+			logging.Warn("admin", "Cache-only mode entered", "reason", body.Reason, "remote_addr", r.RemoteAddr)
+		} else {
+			logging.Info("admin", "Cache-only mode left", "reason", body.Reason, "remote_addr", r.RemoteAddr)
+		}
+		WriteJSON(w, http.StatusOK, mode.Status())
+	})
+}
@@ -0,0 +1,665 @@
+// internal/admin/records.go
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/plugin"
+	"errantdns.io/internal/storage"
+)
+
+const (
+	maxRecordBodyBytes = 1 << 20  // single record requests
+	maxImportBodyBytes = 64 << 20 // bulk imports and changesets
+	maxChangesetSize   = 1000     // changes per changeset
+	maxRecordsLimit    = 1000     // records per listing page
+	defaultSearchLimit = 100      // search results without a limit
+)
+
+// NextCursorHeader carries the cursor for the next page of a limited
+// listing
+const NextCursorHeader = "X-Next-Cursor"
+
+// RecordStore applies record changes, invalidating caches as needed
+type RecordStore interface {
+	CreateRecord(ctx context.Context, record *models.DNSRecord) error
+	UpdateRecord(ctx context.Context, record *models.DNSRecord) error
+	DeleteRecord(ctx context.Context, id int) error
+}
+
+// RecordUpserter creates a record or updates the stored record with the
+// same name, type, target, and priority, reporting whether it created one
+type RecordUpserter interface {
+	UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error)
+}
+
+// RecordBulkCreator creates many records in one transaction, storing all
+// of them or none
+type RecordBulkCreator interface {
+	CreateRecords(ctx context.Context, records []*models.DNSRecord) error
+}
+
+// ChangesetApplier applies a batch of record changes atomically
+type ChangesetApplier interface {
+	Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error)
+}
+
+// ChangesetRequest is the body accepted by POST /records/changes
+type ChangesetRequest struct {
+	Comment string          `json:"comment,omitempty"`
+	Changes []models.Change `json:"changes"`
+}
+
+// RecordLister enumerates records for management and export
+type RecordLister interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+}
+
+// SerialStrategist reports how a zone's SOA serial is advanced
+type SerialStrategist interface {
+	SerialStrategy(zone string) models.SerialStrategy
+}
+
+// RecordGetter fetches a single record by ID
+type RecordGetter interface {
+	GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error)
+}
+
+// RecordSearcher finds and counts records for operators
+type RecordSearcher interface {
+	SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error)
+	CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error)
+}
+
+// RecordValidator checks records without storing them
+type RecordValidator interface {
+	ValidateRecords(ctx context.Context, records []*models.DNSRecord) ([]*storage.RecordError, error)
+}
+
+// RecordProblem describes why a record submitted for validation would be
+// rejected. Kind is "invalid", "duplicate", or "cname_conflict"
+type RecordProblem struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+// ValidationResult is the answer to POST /records/validate
+type ValidationResult struct {
+	Valid    bool            `json:"valid"`
+	Records  int             `json:"records"`
+	Problems []RecordProblem `json:"problems,omitempty"`
+}
+
+// RecordExport is the document produced by export and accepted by import
+type RecordExport struct {
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	Records    []*models.DNSRecord `json:"records"`
+
+	// SerialStrategies is the SOA serial strategy of each exported zone
+	SerialStrategies map[string]models.SerialStrategy `json:"serial_strategies,omitempty"`
+}
+
+// ImportFailure describes a record that could not be imported
+type ImportFailure struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	Imported int             `json:"imported"`
+	Updated  int             `json:"updated"`
+	Failed   []ImportFailure `json:"failed,omitempty"`
+}
+
+const exportVersion = 1
+
+// RegisterRecords exposes record management:
+//
+//	GET    /records           list records (filters: name, prefix, zone, type, owner, labels, external_key)
+//	POST   /records           create a record
+//	PUT    /records           create or update a record, matched by ExternalKey if set, otherwise by name, type, and target
+//	GET    /records/{id}      fetch a record (ETag: "<version>")
+//	PUT    /records/{id}      replace a record (If-Match: "<version>" guards against lost updates)
+//	DELETE /records/{id}      delete a record
+//	GET    /records/export    export matching records with metadata
+//	POST   /records/import    create every record in an export document
+//	GET    /records/search    find records by name or target (q, field, type, limit)
+//	GET    /records/count     count records (same filters as GET /records)
+//	POST   /records/validate  check a record, a list of records, or an export document without storing anything
+//
+// When store is a RecordBulkCreator, imports first try to create every
+// record in one transaction, falling back to one record at a time if that
+// fails. When store is a RecordUpserter, that fallback updates records that
+// already exist instead of failing, so a retried import converges; PUT
+// /records is only served then. Fetching by ID is only served when lister
+// is a RecordGetter, search and count when it's a RecordSearcher, and
+// validation when it's a RecordValidator. Validation answers 200 with
+// valid set to false when it finds problems
+//
+// labels is a selector such as "team=payments,env=prod". Listings are
+// ordered by sort (name, created, updated, or id; "-" reverses) and paged
+// with limit: a full page sets X-Next-Cursor, passed back as after to get
+// the next one
+func (s *Server) RegisterRecords(store RecordStore, lister RecordLister) {
+	s.HandleFunc("GET /records", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseRecordFilter(r.URL.Query())
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		records, err := lister.ListRecords(r.Context(), filter)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if records == nil {
+			records = []*models.DNSRecord{}
+		}
+		setNextCursor(w, filter, records)
+		WriteJSON(w, http.StatusOK, records)
+	})
+
+	s.HandleFunc("POST /records", func(w http.ResponseWriter, r *http.Request) {
+		var record models.DNSRecord
+		if err := decodeBody(w, r, maxRecordBodyBytes, &record); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		record.ID = 0
+		if err := store.CreateRecord(r.Context(), &record); err != nil {
+			WriteError(w, storeErrorStatus(err, http.StatusBadRequest), err)
+			return
+		}
+
+		logging.Info("admin", "Record created", "id", record.ID, "name", record.Name, "type", record.RecordType, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusCreated, &record)
+	})
+
+	if upserter, ok := store.(RecordUpserter); ok {
+		s.HandleFunc("PUT /records", func(w http.ResponseWriter, r *http.Request) {
+			var record models.DNSRecord
+			if err := decodeBody(w, r, maxRecordBodyBytes, &record); err != nil {
+				WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+
+			record.ID, record.Version = 0, 0
+			created, err := upserter.UpsertRecord(r.Context(), &record)
+			if err != nil {
+				WriteError(w, storeErrorStatus(err, http.StatusBadRequest), err)
+				return
+			}
+
+			status := http.StatusOK
+			if created {
+				status = http.StatusCreated
+			}
+			logging.Info("admin", "Record upserted", "id", record.ID, "name", record.Name, "type", record.RecordType,
+				"external_key", record.ExternalKey, "created", created, "remote_addr", r.RemoteAddr)
+			w.Header().Set("ETag", strconv.Quote(strconv.Itoa(record.Version)))
+			WriteJSON(w, status, &record)
+		})
+	}
+
+	s.HandleFunc("PUT /records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid record id: %q", r.PathValue("id")))
+			return
+		}
+
+		var record models.DNSRecord
+		if err := decodeBody(w, r, maxRecordBodyBytes, &record); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		record.ID = id
+		if match := r.Header.Get("If-Match"); match != "" {
+			version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(match, "W/"), `"`))
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid If-Match version: %q", match))
+				return
+			}
+			record.Version = version
+		}
+
+		if err := store.UpdateRecord(r.Context(), &record); err != nil {
+			WriteError(w, storeErrorStatus(err, http.StatusBadRequest), err)
+			return
+		}
+
+		logging.Info("admin", "Record updated", "id", record.ID, "name", record.Name, "type", record.RecordType, "version", record.Version, "remote_addr", r.RemoteAddr)
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(record.Version)))
+		WriteJSON(w, http.StatusOK, &record)
+	})
+
+	s.HandleFunc("DELETE /records/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid record id: %q", r.PathValue("id")))
+			return
+		}
+
+		if err := store.DeleteRecord(r.Context(), id); err != nil {
+			WriteError(w, storeErrorStatus(err, http.StatusNotFound), err)
+			return
+		}
+
+		logging.Info("admin", "Record deleted", "id", id, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+	})
+
+	if getter, ok := lister.(RecordGetter); ok {
+		s.HandleFunc("GET /records/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.Atoi(r.PathValue("id"))
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid record id: %q", r.PathValue("id")))
+				return
+			}
+
+			record, err := getter.GetRecordByID(r.Context(), id)
+			if err != nil {
+				WriteError(w, storeErrorStatus(err, http.StatusInternalServerError), err)
+				return
+			}
+
+			w.Header().Set("ETag", strconv.Quote(strconv.Itoa(record.Version)))
+			WriteJSON(w, http.StatusOK, record)
+		})
+	}
+
+	if searcher, ok := lister.(RecordSearcher); ok {
+		s.registerRecordSearch(searcher)
+	}
+
+	if validator, ok := lister.(RecordValidator); ok {
+		s.registerRecordValidation(validator)
+	}
+
+	s.HandleFunc("GET /records/export", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseRecordFilter(r.URL.Query())
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		records, err := lister.ListRecords(r.Context(), filter)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if records == nil {
+			records = []*models.DNSRecord{}
+		}
+		setNextCursor(w, filter, records)
+
+		export := &RecordExport{
+			Version:    exportVersion,
+			ExportedAt: time.Now().UTC(),
+			Records:    records,
+		}
+		if strategist, ok := lister.(SerialStrategist); ok {
+			for _, record := range records {
+				if record.RecordType != string(models.RecordTypeSOA) {
+					continue
+				}
+				if export.SerialStrategies == nil {
+					export.SerialStrategies = make(map[string]models.SerialStrategy)
+				}
+				zone := models.NormalizeDomainName(record.Name)
+				export.SerialStrategies[zone] = strategist.SerialStrategy(zone)
+			}
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="errantdns-records.json"`)
+		WriteJSON(w, http.StatusOK, export)
+	})
+
+	s.HandleFunc("POST /records/import", func(w http.ResponseWriter, r *http.Request) {
+		var export RecordExport
+		if err := decodeBody(w, r, maxImportBodyBytes, &export); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if export.Version > exportVersion {
+			WriteError(w, http.StatusBadRequest, fmt.Errorf("unsupported export version %d", export.Version))
+			return
+		}
+
+		// IDs and timestamps belong to the source database
+		records := make([]*models.DNSRecord, 0, len(export.Records))
+		for _, record := range export.Records {
+			if record != nil {
+				record.ID = 0
+				records = append(records, record)
+			}
+		}
+
+		var result *ImportResult
+		if creator, ok := store.(RecordBulkCreator); ok && len(records) > 0 {
+			err := creator.CreateRecords(r.Context(), records)
+			switch {
+			case errors.Is(err, storage.ErrReadOnly):
+				WriteError(w, http.StatusServiceUnavailable, err)
+				return
+			case err != nil:
+				logging.Warn("admin", "Bulk import failed, importing records one at a time", "records", len(records), "error", err)
+			default:
+				result = &ImportResult{Imported: len(records)}
+			}
+		}
+		if result == nil {
+			result = importRecords(r.Context(), store, export.Records)
+		}
+
+		logging.Info("admin", "Records imported", "imported", result.Imported, "updated", result.Updated, "failed", len(result.Failed), "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, result)
+	})
+}
+
+// RegisterChangesets exposes atomic multi-record changes:
+//
+//	POST   /records/changes  apply a batch of creates, updates, upserts, and deletes
+//
+// Either every change is applied or none is
+func (s *Server) RegisterChangesets(applier ChangesetApplier) {
+	s.HandleFunc("POST /records/changes", func(w http.ResponseWriter, r *http.Request) {
+		var request ChangesetRequest
+		if err := decodeBody(w, r, maxImportBodyBytes, &request); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(request.Changes) == 0 {
+			WriteError(w, http.StatusBadRequest, fmt.Errorf("changeset has no changes"))
+			return
+		}
+		if len(request.Changes) > maxChangesetSize {
+			WriteError(w, http.StatusBadRequest, fmt.Errorf("changeset has %d changes, limit is %d", len(request.Changes), maxChangesetSize))
+			return
+		}
+
+		result, err := applier.Apply(r.Context(), request.Changes)
+		if err != nil {
+			WriteError(w, storeErrorStatus(err, http.StatusBadRequest), err)
+			return
+		}
+
+		logging.Info("admin", "Changeset applied", "created", result.Created, "updated", result.Updated, "deleted", result.Deleted,
+			"comment", request.Comment, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, result)
+	})
+}
+
+// storeErrorStatus maps conflicts with stored records to 409 Conflict,
+// changes a plugin rejected to 403 Forbidden, missing records to 404 Not
+// Found, writes while storage is read-only to 503 Service Unavailable, and
+// anything else to fallback
+func storeErrorStatus(err error, fallback int) int {
+	if errors.Is(err, storage.ErrReadOnly) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, plugin.ErrRejected) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, storage.ErrRecordNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, storage.ErrVersionConflict) ||
+		errors.Is(err, storage.ErrDuplicateRecord) ||
+		errors.Is(err, storage.ErrCNAMEConflict) ||
+		errors.Is(err, storage.ErrTTLMismatch) {
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+// registerRecordSearch exposes record search and count
+func (s *Server) registerRecordSearch(searcher RecordSearcher) {
+	s.HandleFunc("GET /records/search", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		search := &models.RecordSearch{
+			Query:      params.Get("q"),
+			Field:      params.Get("field"),
+			RecordType: params.Get("type"),
+			Limit:      defaultSearchLimit,
+		}
+		if param := params.Get("limit"); param != "" {
+			limit, err := strconv.Atoi(param)
+			if err != nil || limit <= 0 || limit > maxRecordsLimit {
+				WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: %q (1 to %d)", param, maxRecordsLimit))
+				return
+			}
+			search.Limit = limit
+		}
+		if err := search.Validate(); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		records, err := searcher.SearchRecords(r.Context(), search)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if records == nil {
+			records = []*models.DNSRecord{}
+		}
+		WriteJSON(w, http.StatusOK, records)
+	})
+
+	s.HandleFunc("GET /records/count", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseRecordFilter(r.URL.Query())
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		count, err := searcher.CountRecords(r.Context(), filter)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]int{"count": count})
+	})
+}
+
+// registerRecordValidation exposes dry-run validation of record changes
+func (s *Server) registerRecordValidation(validator RecordValidator) {
+	s.HandleFunc("POST /records/validate", func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := decodeBody(w, r, maxImportBodyBytes, &body); err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		records, err := decodeRecordBatch(body)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		errs, err := validator.ValidateRecords(r.Context(), records)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		result := &ValidationResult{Valid: len(errs) == 0, Records: len(records)}
+		for _, recordErr := range errs {
+			problem := RecordProblem{
+				Index: recordErr.Index,
+				Kind:  problemKind(recordErr),
+				Error: recordErr.Err.Error(),
+			}
+			if record := records[recordErr.Index]; record != nil {
+				problem.Name = record.Name
+				problem.Type = record.RecordType
+			}
+			result.Problems = append(result.Problems, problem)
+		}
+		WriteJSON(w, http.StatusOK, result)
+	})
+}
+
+// decodeRecordBatch reads the records of a validation request: a single
+// record, an array of records, or an export document
+func decodeRecordBatch(body json.RawMessage) ([]*models.DNSRecord, error) {
+	decode := func(v interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("invalid request body: %w", err)
+		}
+		return nil
+	}
+
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var records []*models.DNSRecord
+		if err := decode(&records); err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return nil, fmt.Errorf("invalid request body: must be a record, an array of records, or an export document")
+	}
+	if _, ok := fields["records"]; ok {
+		var export RecordExport
+		if err := decode(&export); err != nil {
+			return nil, err
+		}
+		return export.Records, nil
+	}
+	var record models.DNSRecord
+	if err := decode(&record); err != nil {
+		return nil, err
+	}
+	return []*models.DNSRecord{&record}, nil
+}
+
+// problemKind classifies a validation problem for RecordProblem
+func problemKind(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrDuplicateRecord):
+		return "duplicate"
+	case errors.Is(err, storage.ErrCNAMEConflict):
+		return "cname_conflict"
+	default:
+		return "invalid"
+	}
+}
+
+// importRecords imports records one at a time, reporting each failure
+// against its index in the export
+func importRecords(ctx context.Context, store RecordStore, records []*models.DNSRecord) *ImportResult {
+	result := &ImportResult{}
+	for i, record := range records {
+		if record == nil {
+			continue
+		}
+
+		created, err := importRecord(ctx, store, record)
+		if err != nil {
+			result.Failed = append(result.Failed, ImportFailure{
+				Index: i,
+				Name:  record.Name,
+				Type:  record.RecordType,
+				Error: err.Error(),
+			})
+			continue
+		}
+		if created {
+			result.Imported++
+		} else {
+			result.Updated++
+		}
+	}
+	return result
+}
+
+// importRecord upserts record when store supports it, otherwise creates it
+func importRecord(ctx context.Context, store RecordStore, record *models.DNSRecord) (bool, error) {
+	if upserter, ok := store.(RecordUpserter); ok {
+		return upserter.UpsertRecord(ctx, record)
+	}
+	if err := store.CreateRecord(ctx, record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parseRecordFilter builds a record filter from query parameters
+func parseRecordFilter(params url.Values) (*models.RecordFilter, error) {
+	filter := &models.RecordFilter{
+		Name:       params.Get("name"),
+		NamePrefix: params.Get("prefix"),
+		Zone:       params.Get("zone"),
+		RecordType: params.Get("type"),
+		Owner:      params.Get("owner"),
+		Sort:       params.Get("sort"),
+		After:      params.Get("after"),
+
+		ExternalKey: params.Get("external_key"),
+	}
+
+	if param := params.Get("limit"); param != "" {
+		limit, err := strconv.Atoi(param)
+		if err != nil || limit <= 0 || limit > maxRecordsLimit {
+			return nil, fmt.Errorf("invalid limit: %q (1 to %d)", param, maxRecordsLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if selector := params.Get("labels"); selector != "" {
+		labels, err := models.ParseLabelSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		filter.Labels = labels
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// setNextCursor points a full page of a limited listing at the next page
+func setNextCursor(w http.ResponseWriter, filter *models.RecordFilter, records []*models.DNSRecord) {
+	if filter.Limit > 0 && len(records) == filter.Limit {
+		w.Header().Set(NextCursorHeader, filter.Cursor(records[len(records)-1]))
+	}
+}
+
+// decodeBody decodes a size-limited JSON request body, rejecting unknown fields
+func decodeBody(w http.ResponseWriter, r *http.Request, limit int64, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// internal/admin/server.go
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// Server is the administrative HTTP listener used for stats and management
+type Server struct {
+	httpServer *http.Server
+	mux        *http.ServeMux
+	address    string
+}
+
+// Config holds configuration for the admin HTTP server
+type Config struct {
+	Address      string
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// DefaultConfig returns admin server config with sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Address:      "127.0.0.1:8053",
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+}
+
+// NewServer creates a new admin HTTP server
+func NewServer(config *Config) *Server {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	mux := http.NewServeMux()
+
+	return &Server{
+		mux:     mux,
+		address: config.Address,
+		httpServer: &http.Server{
+			Addr:         config.Address,
+			Handler:      mux,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+		},
+	}
+}
+
+// Handle registers a handler for the given pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers a handler function for the given pattern
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// Start serves admin requests until the context is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	logging.Info("admin", "Starting admin HTTP server", "address", s.address)
+
+	errChan := make(chan error, 1)
+	go func() {
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("admin server error: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		logging.Info("admin", "Admin HTTP server shutting down...")
+		return s.Stop()
+	}
+}
+
+// Stop gracefully stops the admin server
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("admin server shutdown error: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes v as an indented JSON response with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		logging.Error("admin", "Failed to encode JSON response", err)
+	}
+}
+
+// WriteError writes a JSON error response
+func WriteError(w http.ResponseWriter, status int, err error) {
+	WriteJSON(w, status, map[string]string{"error": err.Error()})
+}
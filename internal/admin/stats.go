@@ -0,0 +1,51 @@
+// internal/admin/stats.go
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/monitor"
+)
+
+// RegisterStats exposes the collector's snapshot at GET /stats, top-N
+// query analytics at GET /stats/top, a counter reset at POST /stats/reset,
+// and whether queries are answered normally at GET /health. A degraded
+// server still answers 200 OK, since it's still serving
+func (s *Server) RegisterStats(collector *monitor.Collector) {
+	s.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, collector.Health())
+	})
+
+	s.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, collector.Collect())
+	})
+
+	s.HandleFunc("GET /stats/top", func(w http.ResponseWriter, r *http.Request) {
+		n := 0
+		if param := r.URL.Query().Get("n"); param != "" {
+			val, err := strconv.Atoi(param)
+			if err != nil || val <= 0 {
+				WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid n: %q", param))
+				return
+			}
+			n = val
+		}
+
+		top := collector.TopQueries(n)
+		if top == nil {
+			WriteError(w, http.StatusNotFound, errors.New("query analytics are disabled"))
+			return
+		}
+		WriteJSON(w, http.StatusOK, top)
+	})
+
+	s.HandleFunc("POST /stats/reset", func(w http.ResponseWriter, r *http.Request) {
+		collector.Reset()
+		logging.Info("admin", "Statistics reset", "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+	})
+}
@@ -0,0 +1,39 @@
+// internal/admin/zones.go
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/zonecheck"
+)
+
+// RegisterZones exposes zone audits:
+//
+//	GET /zones/{zone}/check  check a zone's apex, aliases, targets, duplicates, and TTLs
+//
+// The check answers 200 with a zonecheck.Report whatever it finds; errors
+// in the zone are counted in the report, not signaled by the status
+func (s *Server) RegisterZones(lister RecordLister) {
+	s.HandleFunc("GET /zones/{zone}/check", func(w http.ResponseWriter, r *http.Request) {
+		zone := models.NormalizeDomainName(r.PathValue("zone"))
+		if zone == "" {
+			WriteError(w, http.StatusBadRequest, errors.New("zone cannot be empty"))
+			return
+		}
+
+		records, err := lister.ListRecords(r.Context(), &models.RecordFilter{Zone: zone})
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		report := zonecheck.Check(zone, records, time.Now())
+		logging.Info("admin", "Zone checked", "zone", zone, "records", report.Records,
+			"errors", report.Errors, "warnings", report.Warnings, "remote_addr", r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, report)
+	})
+}
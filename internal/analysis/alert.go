@@ -0,0 +1,94 @@
+// internal/analysis/alert.go
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// AlertType identifies the pattern that triggered an alert
+type AlertType string
+
+const (
+	AlertNXDomainFlood   AlertType = "nxdomain_flood"
+	AlertRandomSubdomain AlertType = "random_subdomain"
+)
+
+// Alert is a structured event describing suspicious query activity for a zone
+type Alert struct {
+	Type      AlertType `json:"type"`
+	Zone      string    `json:"zone"` // a zone we hold, or (other) for every other name
+	Timestamp time.Time `json:"timestamp"`
+	Window    string    `json:"window"`
+	Count     int64     `json:"count"`         // NXDOMAIN responses in the window
+	Unique    int       `json:"unique_labels"` // distinct unknown labels seen
+	Random    int       `json:"random_labels"` // distinct labels that look machine-generated
+	Samples   []string  `json:"samples"`       // a few of the names queried
+	Message   string    `json:"message"`
+}
+
+// Sink receives alerts
+type Sink interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// LogSink writes alerts to the application log
+type LogSink struct{}
+
+// Send logs the alert as a warning
+func (LogSink) Send(ctx context.Context, alert Alert) error {
+	logging.Warn("analysis", alert.Message,
+		"alert_type", string(alert.Type),
+		"zone", alert.Zone,
+		"window", alert.Window,
+		"count", alert.Count,
+		"unique_labels", alert.Unique,
+		"random_labels", alert.Random,
+		"samples", alert.Samples)
+	return nil
+}
+
+// WebhookSink posts alerts as JSON to an HTTP endpoint
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting to url with the given request timeout
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts the alert to the webhook
+func (s *WebhookSink) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
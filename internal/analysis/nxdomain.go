@@ -0,0 +1,256 @@
+// internal/analysis/nxdomain.go
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// Config holds thresholds for NXDOMAIN pattern detection
+type Config struct {
+	// Window is the period over which per-zone counts accumulate
+	Window time.Duration
+
+	// FloodThreshold is the number of NXDOMAIN responses for one zone within
+	// Window that raises a flood alert
+	FloodThreshold int64
+
+	// RandomThreshold is the number of distinct machine-looking labels for
+	// one zone within Window that raises a random-subdomain alert
+	RandomThreshold int
+
+	// EntropyThreshold is the Shannon entropy (bits per character) above
+	// which a label is considered machine-generated
+	EntropyThreshold float64
+
+	// Cooldown suppresses repeat alerts of the same type for a zone
+	Cooldown time.Duration
+}
+
+// DefaultConfig returns analyzer config with sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Window:           time.Minute,
+		FloodThreshold:   1000,
+		RandomThreshold:  200,
+		EntropyThreshold: 3.0,
+		Cooldown:         5 * time.Minute,
+	}
+}
+
+const (
+	maxTrackedLabels = 4096 // distinct labels remembered per zone per window
+	maxSamples       = 5
+	alertQueueSize   = 64
+)
+
+// zoneState accumulates NXDOMAIN activity for a zone over the current window
+type zoneState struct {
+	windowStart time.Time
+	count       int64
+	labels      map[string]bool // label -> looks random
+	random      int
+	samples     []string
+	lastAlert   map[AlertType]time.Time
+}
+
+// NXDomainAnalyzer watches NXDOMAIN responses per zone and raises alerts
+// for floods and random-subdomain (dictionary) attacks
+type NXDomainAnalyzer struct {
+	config *Config
+	sinks  []Sink
+
+	mu     sync.Mutex
+	zones  map[string]*zoneState
+	alerts chan Alert
+}
+
+// NewNXDomainAnalyzer creates an analyzer delivering alerts to sinks
+func NewNXDomainAnalyzer(config *Config, sinks ...Sink) *NXDomainAnalyzer {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	return &NXDomainAnalyzer{
+		config: config,
+		sinks:  sinks,
+		zones:  make(map[string]*zoneState),
+		alerts: make(chan Alert, alertQueueSize),
+	}
+}
+
+// Record notes an NXDOMAIN response for name under zone: the zone we hold
+// that name is in, or one shared bucket for every other name, so made-up
+// names can't grow the tracked zones without limit. It never blocks on
+// alert delivery
+func (a *NXDomainAnalyzer) Record(zone, name string) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" {
+		return
+	}
+
+	label := firstLabel(name, zone)
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, exists := a.zones[zone]
+	if !exists || now.Sub(state.windowStart) >= a.config.Window {
+		lastAlert := make(map[AlertType]time.Time)
+		if exists {
+			lastAlert = state.lastAlert
+		}
+		state = &zoneState{
+			windowStart: now,
+			labels:      make(map[string]bool),
+			lastAlert:   lastAlert,
+		}
+		a.zones[zone] = state
+	}
+
+	state.count++
+	if label != "" {
+		if _, seen := state.labels[label]; !seen && len(state.labels) < maxTrackedLabels {
+			random := looksRandom(label, a.config.EntropyThreshold)
+			state.labels[label] = random
+			if random {
+				state.random++
+			}
+			if len(state.samples) < maxSamples {
+				state.samples = append(state.samples, name)
+			}
+		}
+	}
+
+	if state.count >= a.config.FloodThreshold {
+		a.raise(now, zone, state, AlertNXDomainFlood,
+			fmt.Sprintf("NXDOMAIN flood for zone %s: %d responses in %s", zone, state.count, a.config.Window))
+	}
+
+	if state.random >= a.config.RandomThreshold {
+		a.raise(now, zone, state, AlertRandomSubdomain,
+			fmt.Sprintf("Random-subdomain pattern for zone %s: %d random-looking labels in %s", zone, state.random, a.config.Window))
+	}
+}
+
+// raise queues an alert unless one of the same type fired within the
+// cooldown. Callers must hold a.mu
+func (a *NXDomainAnalyzer) raise(now time.Time, zone string, state *zoneState, alertType AlertType, message string) {
+	if last, ok := state.lastAlert[alertType]; ok && now.Sub(last) < a.config.Cooldown {
+		return
+	}
+	state.lastAlert[alertType] = now
+
+	alert := Alert{
+		Type:      alertType,
+		Zone:      zone,
+		Timestamp: now,
+		Window:    a.config.Window.String(),
+		Count:     state.count,
+		Unique:    len(state.labels),
+		Random:    state.random,
+		Samples:   append([]string(nil), state.samples...),
+		Message:   message,
+	}
+
+	select {
+	case a.alerts <- alert:
+	default:
+		logging.Warn("analysis", "Alert queue full, dropping alert", "alert_type", string(alertType), "zone", zone)
+	}
+}
+
+// Run delivers queued alerts to the sinks and prunes idle zones until ctx is done
+func (a *NXDomainAnalyzer) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.config.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-a.alerts:
+			a.deliver(ctx, alert)
+		case <-ticker.C:
+			a.prune(time.Now())
+		}
+	}
+}
+
+// deliver sends an alert to every sink, logging failures
+func (a *NXDomainAnalyzer) deliver(ctx context.Context, alert Alert) {
+	for _, sink := range a.sinks {
+		if err := sink.Send(ctx, alert); err != nil {
+			logging.Error("analysis", "Failed to deliver alert", err,
+				"alert_type", string(alert.Type), "zone", alert.Zone)
+		}
+	}
+}
+
+// prune drops zones whose window and cooldowns have all expired
+func (a *NXDomainAnalyzer) prune(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for zone, state := range a.zones {
+		if now.Sub(state.windowStart) < a.config.Window {
+			continue
+		}
+		cooling := false
+		for _, last := range state.lastAlert {
+			if now.Sub(last) < a.config.Cooldown {
+				cooling = true
+				break
+			}
+		}
+		if !cooling {
+			delete(a.zones, zone)
+		}
+	}
+}
+
+// firstLabel returns the leftmost label of name beneath zone, or of the
+// whole name when it isn't under zone
+func firstLabel(name, zone string) string {
+	if name == zone {
+		return ""
+	}
+	sub := strings.TrimSuffix(name, "."+zone)
+	if i := strings.IndexByte(sub, '.'); i >= 0 {
+		return sub[:i]
+	}
+	return sub
+}
+
+// looksRandom reports whether a label resembles generated rather than
+// human-chosen text, using character entropy and digit density
+func looksRandom(label string, entropyThreshold float64) bool {
+	if len(label) < 10 {
+		return false
+	}
+
+	counts := make(map[rune]int)
+	digits := 0
+	for _, r := range label {
+		counts[r]++
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+
+	var entropy float64
+	length := float64(len(label))
+	for _, c := range counts {
+		p := float64(c) / length
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy >= entropyThreshold || float64(digits)/length >= 0.3
+}
@@ -0,0 +1,277 @@
+// internal/blocklist/allowlist.go
+package blocklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/models"
+)
+
+// Allowlist errors
+var (
+	ErrEntryNotFound = errors.New("allowlist entry not found")
+	ErrInvalidEntry  = errors.New("invalid allowlist entry")
+)
+
+// Allowlist exempts names from blocking. Entries take three forms:
+//
+//	example.com      exactly that name
+//	*.example.com    any name below example.com, but not example.com itself
+//	/^ads[0-9]+\./   names matching a regular expression
+//
+// When the allowlist has a file, entries added or removed at runtime are
+// saved to it, one per line, so they survive a restart
+type Allowlist struct {
+	path string
+
+	mu       sync.RWMutex
+	entries  []string
+	exact    map[string]bool
+	suffixes map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewAllowlist creates an allowlist from entries plus those saved in path,
+// if it's set and exists
+func NewAllowlist(entries []string, path string) (*Allowlist, error) {
+	a := &Allowlist{path: path}
+	all := entries
+	if path != "" {
+		saved, err := readEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(append([]string(nil), entries...), saved...)
+	}
+
+	for _, entry := range all {
+		if _, err := a.add(entry); err != nil {
+			return nil, err
+		}
+	}
+	a.compile()
+	return a, nil
+}
+
+// Allowed reports whether name is exempt from blocking
+func (a *Allowlist) Allowed(name string) bool {
+	name = models.NormalizeDomainName(name)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.exact[name] {
+		return true
+	}
+	for parent := name; ; {
+		dot := strings.IndexByte(parent, '.')
+		if dot < 0 {
+			break
+		}
+		parent = parent[dot+1:]
+		if a.suffixes[parent] {
+			return true
+		}
+	}
+	for _, pattern := range a.patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the allowlist's entries in the order they were added
+func (a *Allowlist) Entries() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]string{}, a.entries...)
+}
+
+// Add adds an entry, reporting whether it was new, and saves the
+// allowlist. The entry isn't added if it can't be saved
+func (a *Allowlist) Add(entry string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	previous := a.entries
+	added, err := a.add(entry)
+	if err != nil || !added {
+		return false, err
+	}
+	if err := a.save(); err != nil {
+		a.entries = previous
+		return false, err
+	}
+	a.compile()
+	return true, nil
+}
+
+// Remove removes an entry and saves the allowlist. The entry is kept if
+// the allowlist can't be saved
+func (a *Allowlist) Remove(entry string) error {
+	entry, err := normalizeEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i, existing := range a.entries {
+		if existing != entry {
+			continue
+		}
+		previous := a.entries
+		a.entries = append(append([]string(nil), previous[:i]...), previous[i+1:]...)
+		if err := a.save(); err != nil {
+			a.entries = previous
+			return err
+		}
+		a.compile()
+		return nil
+	}
+	return ErrEntryNotFound
+}
+
+// add appends a validated entry unless it's already present. The caller
+// recompiles the lookup tables afterwards
+func (a *Allowlist) add(entry string) (bool, error) {
+	entry, err := normalizeEntry(entry)
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range a.entries {
+		if existing == entry {
+			return false, nil
+		}
+	}
+	a.entries = append(a.entries, entry)
+	return true, nil
+}
+
+// compile rebuilds the lookup tables from the entries. Entries were
+// validated when added, so patterns always compile
+func (a *Allowlist) compile() {
+	a.exact = make(map[string]bool)
+	a.suffixes = make(map[string]bool)
+	a.patterns = nil
+
+	for _, entry := range a.entries {
+		switch {
+		case isPattern(entry):
+			a.patterns = append(a.patterns, regexp.MustCompile(entry[1:len(entry)-1]))
+		case strings.HasPrefix(entry, "*."):
+			a.suffixes[entry[2:]] = true
+		default:
+			a.exact[entry] = true
+		}
+	}
+}
+
+// save writes the entries to the allowlist's file, if it has one
+func (a *Allowlist) save() error {
+	if a.path == "" {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), ".allowlist-*")
+	if err != nil {
+		return fmt.Errorf("failed to save allowlist: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range a.entries {
+		fmt.Fprintln(writer, entry)
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save allowlist: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save allowlist: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), a.path); err != nil {
+		return fmt.Errorf("failed to save allowlist: %w", err)
+	}
+	return nil
+}
+
+// readEntries reads saved entries from path, skipping blank lines and
+// "#" comments. A missing file has no entries
+func readEntries(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open allowlist file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read allowlist file: %w", err)
+	}
+	return entries, nil
+}
+
+// normalizeEntry validates an entry and returns its canonical form
+func normalizeEntry(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if isPattern(entry) {
+		if _, err := regexp.Compile(entry[1 : len(entry)-1]); err != nil {
+			return "", fmt.Errorf("%w %q: %v", ErrInvalidEntry, entry, err)
+		}
+		return entry, nil
+	}
+
+	entry = models.NormalizeDomainName(entry)
+	if !validName(strings.TrimPrefix(entry, "*.")) {
+		return "", fmt.Errorf("%w %q", ErrInvalidEntry, entry)
+	}
+	return entry, nil
+}
+
+// validName reports whether name is made of hostname labels, allowing the
+// underscores service names use
+func validName(name string) bool {
+	if _, ok := dns.IsDomainName(name); !ok || name == "" {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' && r != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isPattern reports whether entry is a /regular expression/
+func isPattern(entry string) bool {
+	return len(entry) >= 2 && entry[0] == '/' && entry[len(entry)-1] == '/'
+}
@@ -0,0 +1,246 @@
+// internal/blocklist/blocklist.go
+package blocklist
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// maxFeedSize bounds how much of one feed is downloaded
+const maxFeedSize = 64 << 20
+
+// Config holds configuration for blocklist feeds
+type Config struct {
+	Feeds           []string      // feed URLs
+	RefreshInterval time.Duration // how often feeds are downloaded again
+	Timeout         time.Duration // per-feed download timeout
+	Response        *Response     // how blocked names are answered; nil answers NXDOMAIN
+	Allowlist       *Allowlist    // names exempt from blocking; nil exempts none
+}
+
+// FeedStatus is the state of one feed
+type FeedStatus struct {
+	URL         string    `json:"url"`
+	Domains     int       `json:"domains"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Status is the state of every feed and the compiled set
+type Status struct {
+	Domains     int          `json:"domains"`
+	Response    string       `json:"response"`
+	CompiledAt  time.Time    `json:"compiled_at,omitzero"`
+	Blocked     int64        `json:"blocked"`
+	Allowed     int64        `json:"allowed"` // listed names exempted by the allowlist
+	Feeds       []FeedStatus `json:"feeds"`
+	NextRefresh time.Time    `json:"next_refresh,omitzero"`
+}
+
+// feed is one downloaded list. Its last good contents are kept when a
+// download fails, so an unreachable feed doesn't unblock its names
+type feed struct {
+	status       FeedStatus
+	domains      domainSet
+	etag         string
+	lastModified string
+}
+
+// Manager downloads blocklist feeds on a schedule and compiles them into
+// the views that answer whether a name is blocked
+type Manager struct {
+	client    *http.Client
+	interval  time.Duration
+	response  *Response
+	allowlist *Allowlist
+
+	// mu guards feed contents and status, and the views compiled from them
+	mu          sync.Mutex
+	feeds       []*feed
+	views       []*View
+	defaultView *View
+	compiledAt  time.Time
+	nextRefresh time.Time
+
+	blocked atomic.Int64
+	allowed atomic.Int64
+	refresh chan struct{}
+}
+
+// NewManager creates a manager for the configured feeds. Nothing is blocked
+// until Run downloads them
+func NewManager(config *Config) *Manager {
+	response := config.Response
+	if response == nil {
+		response = &Response{Mode: ModeNXDomain}
+	}
+	allowlist := config.Allowlist
+	if allowlist == nil {
+		allowlist, _ = NewAllowlist(nil, "")
+	}
+
+	m := &Manager{
+		client:    &http.Client{Timeout: config.Timeout},
+		interval:  config.RefreshInterval,
+		response:  response,
+		allowlist: allowlist,
+		refresh:   make(chan struct{}, 1),
+	}
+	for _, url := range config.Feeds {
+		m.feeds = append(m.feeds, &feed{status: FeedStatus{URL: url}})
+	}
+	m.defaultView, _ = m.NewView(nil, response)
+	return m
+}
+
+// Default returns the view of every feed with the configured response
+func (m *Manager) Default() *View {
+	return m.defaultView
+}
+
+// Allowlist returns the names exempt from blocking
+func (m *Manager) Allowlist() *Allowlist {
+	return m.allowlist
+}
+
+// Status returns the state of the feeds and the compiled set
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := Status{
+		Domains:     len(*m.defaultView.set.Load()),
+		Response:    m.response.String(),
+		CompiledAt:  m.compiledAt,
+		Blocked:     m.blocked.Load(),
+		Allowed:     m.allowed.Load(),
+		NextRefresh: m.nextRefresh,
+		Feeds:       make([]FeedStatus, len(m.feeds)),
+	}
+	for i, f := range m.feeds {
+		status.Feeds[i] = f.status
+	}
+	return status
+}
+
+// Refresh asks Run to download the feeds now rather than waiting for the
+// next scheduled refresh
+func (m *Manager) Refresh() {
+	select {
+	case m.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Run downloads the feeds now and then every refresh interval, or when
+// Refresh is called, until ctx is cancelled
+func (m *Manager) Run(ctx context.Context) {
+	for {
+		m.update(ctx)
+
+		timer := time.NewTimer(m.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-m.refresh:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// update downloads every feed and recompiles every view
+func (m *Manager) update(ctx context.Context) {
+	for _, f := range m.feeds {
+		if ctx.Err() != nil {
+			return
+		}
+		m.download(ctx, f)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	domains := 0
+	for _, view := range m.views {
+		count := view.compile()
+		if view == m.defaultView {
+			domains = count
+		}
+	}
+	m.compiledAt = time.Now()
+	m.nextRefresh = m.compiledAt.Add(m.interval)
+
+	logging.Info("blocklist", "Compiled blocklist", "domains", domains, "feeds", len(m.feeds), "views", len(m.views))
+}
+
+// download fetches one feed, keeping its previous contents on failure or
+// when the server reports it unchanged
+func (m *Manager) download(ctx context.Context, f *feed) {
+	attempt := time.Now()
+	domains, err := m.fetch(ctx, f)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f.status.LastAttempt = attempt
+	if err != nil {
+		f.status.LastError = err.Error()
+		logging.Warn("blocklist", "Failed to download feed", "url", f.status.URL, "error", err)
+		return
+	}
+
+	f.status.LastError = ""
+	f.status.LastSuccess = f.status.LastAttempt
+	if domains != nil {
+		f.domains = domains
+		f.status.Domains = len(domains)
+	}
+}
+
+// fetch downloads and parses a feed, returning nil domains if it hasn't
+// changed since the last download
+func (m *Manager) fetch(ctx context.Context, f *feed) (domainSet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.status.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.domains != nil {
+		if f.etag != "" {
+			req.Header.Set("If-None-Match", f.etag)
+		}
+		if f.lastModified != "" {
+			req.Header.Set("If-Modified-Since", f.lastModified)
+		}
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	domains, err := parseFeed(http.MaxBytesReader(nil, resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	f.etag = resp.Header.Get("ETag")
+	f.lastModified = resp.Header.Get("Last-Modified")
+	return domains, nil
+}
@@ -0,0 +1,97 @@
+// internal/blocklist/response.go
+package blocklist
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Response modes for blocked names
+const (
+	ModeNXDomain = "nxdomain" // name does not exist
+	ModeRefused  = "refused"  // query refused
+	ModeNull     = "null"     // A 0.0.0.0 and AAAA ::
+	ModeSinkhole = "sinkhole" // A and AAAA of a sinkhole host
+)
+
+// Response is how queries for blocked names are answered
+type Response struct {
+	Mode string
+	IPv4 net.IP // answers A queries in null and sinkhole modes
+	IPv6 net.IP // answers AAAA queries in null and sinkhole modes
+	TTL  uint32
+}
+
+// ParseResponse parses a response setting: "nxdomain", "refused", "null",
+// or one or two comma-separated sinkhole addresses (one per family).
+// Sinkhole queries of a family without an address get an empty answer
+func ParseResponse(value string, ttl uint32) (*Response, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "", ModeNXDomain:
+		return &Response{Mode: ModeNXDomain}, nil
+	case ModeRefused:
+		return &Response{Mode: ModeRefused}, nil
+	case ModeNull:
+		return &Response{Mode: ModeNull, IPv4: net.IPv4zero.To4(), IPv6: net.IPv6zero, TTL: ttl}, nil
+	}
+
+	response := &Response{Mode: ModeSinkhole, TTL: ttl}
+	for _, field := range strings.Split(value, ",") {
+		ip := net.ParseIP(strings.TrimSpace(field))
+		switch {
+		case ip == nil:
+			return nil, fmt.Errorf("invalid block response %q: expected nxdomain, refused, null, or sinkhole addresses", value)
+		case ip.To4() != nil:
+			if response.IPv4 != nil {
+				return nil, fmt.Errorf("invalid block response %q: more than one IPv4 address", value)
+			}
+			response.IPv4 = ip.To4()
+		default:
+			if response.IPv6 != nil {
+				return nil, fmt.Errorf("invalid block response %q: more than one IPv6 address", value)
+			}
+			response.IPv6 = ip
+		}
+	}
+	return response, nil
+}
+
+// String returns the response in the form ParseResponse accepts
+func (r *Response) String() string {
+	if r.Mode != ModeSinkhole {
+		return r.Mode
+	}
+	var addresses []string
+	if r.IPv4 != nil {
+		addresses = append(addresses, r.IPv4.String())
+	}
+	if r.IPv6 != nil {
+		addresses = append(addresses, r.IPv6.String())
+	}
+	return strings.Join(addresses, ",")
+}
+
+// Write fills msg, already set up as a reply to query, with the response
+func (r *Response) Write(msg *dns.Msg, query *dns.Msg) {
+	switch r.Mode {
+	case ModeRefused:
+		msg.Rcode = dns.RcodeRefused
+		return
+	case ModeNull, ModeSinkhole:
+	default:
+		msg.Rcode = dns.RcodeNameError
+		return
+	}
+
+	question := query.Question[0]
+	header := dns.RR_Header{Name: question.Name, Rrtype: question.Qtype, Class: dns.ClassINET, Ttl: r.TTL}
+	switch {
+	case question.Qtype == dns.TypeA && r.IPv4 != nil:
+		msg.Answer = append(msg.Answer, &dns.A{Hdr: header, A: r.IPv4})
+	case question.Qtype == dns.TypeAAAA && r.IPv6 != nil:
+		msg.Answer = append(msg.Answer, &dns.AAAA{Hdr: header, AAAA: r.IPv6})
+	}
+}
@@ -0,0 +1,111 @@
+// internal/blocklist/set.go
+package blocklist
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/models"
+)
+
+// domainSet is a compiled set of blocked names. A name whose value is true
+// also blocks every name below it
+type domainSet map[string]bool
+
+// contains reports whether name, or a parent of it blocked with its
+// subdomains, is in the set
+func (s domainSet) contains(name string) bool {
+	name = models.NormalizeDomainName(name)
+	if _, ok := s[name]; ok {
+		return true
+	}
+	for {
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			return false
+		}
+		name = name[dot+1:]
+		if s[name] {
+			return true
+		}
+	}
+}
+
+// add adds name to the set, keeping a subdomain block if one was added
+func (s domainSet) add(name string, subdomains bool) {
+	s[name] = s[name] || subdomains
+}
+
+// parseFeed reads a feed in any of the common formats, one entry per line:
+//
+//	0.0.0.0 ads.example.com tracker.example.com   hosts file
+//	ads.example.com                               domain list
+//	||ads.example.com^                            adblock; blocks subdomains too
+//
+// "#" and "!" start comments. Entries that aren't valid names, and the
+// localhost names hosts-format feeds carry, are skipped
+func parseFeed(r io.Reader) (domainSet, error) {
+	set := make(domainSet)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '!' || line[0] == '[' {
+			continue
+		}
+		line, _, _ = strings.Cut(line, "#")
+
+		if rule, ok := strings.CutPrefix(line, "||"); ok {
+			// Rules with options or paths block more than a name
+			name, rest, found := strings.Cut(rule, "^")
+			if !found || rest != "" {
+				continue
+			}
+			if name = normalize(name); name != "" {
+				set.add(name, true)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			if name := normalize(field); name != "" && !isLocal(name) {
+				set.add(name, false)
+			}
+		}
+	}
+	return set, scanner.Err()
+}
+
+// normalize returns name in set form, or "" if it isn't a valid name
+func normalize(name string) string {
+	name = models.NormalizeDomainName(name)
+	if name == "" || !strings.Contains(name, ".") {
+		return ""
+	}
+	if _, ok := dns.IsDomainName(name); !ok {
+		return ""
+	}
+	return name
+}
+
+// isLocal reports whether name is one of the loopback names hosts files
+// map alongside their blocked entries
+func isLocal(name string) bool {
+	switch name {
+	case "localhost.localdomain", "local", "broadcasthost", "ip6-localhost", "ip6-loopback":
+		return true
+	}
+	return false
+}
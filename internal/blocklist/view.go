@@ -0,0 +1,85 @@
+// internal/blocklist/view.go
+package blocklist
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// View blocks the names on a subset of the manager's feeds, answering
+// them with its own response. Policy groups use views to block different
+// lists for different clients; the manager's default view uses every feed
+type View struct {
+	manager  *Manager
+	feeds    []bool // by feed index
+	response *Response
+	set      atomic.Pointer[domainSet]
+}
+
+// NewView creates a view of the named feeds, or of every feed if feeds is
+// empty. A nil response uses the manager's
+func (m *Manager) NewView(feeds []string, response *Response) (*View, error) {
+	if response == nil {
+		response = m.response
+	}
+	v := &View{manager: m, feeds: make([]bool, len(m.feeds)), response: response}
+
+	for _, url := range feeds {
+		found := false
+		for i, f := range m.feeds {
+			if f.status.URL == url {
+				v.feeds[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("blocklist feed %q is not configured", url)
+		}
+	}
+	if len(feeds) == 0 {
+		for i := range v.feeds {
+			v.feeds[i] = true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v.compile()
+	m.views = append(m.views, v)
+	return v, nil
+}
+
+// Blocked reports whether name is on one of the view's feeds and not
+// exempted by the allowlist, counting it if so
+func (v *View) Blocked(name string) bool {
+	if !(*v.set.Load()).contains(name) {
+		return false
+	}
+	if v.manager.allowlist.Allowed(name) {
+		v.manager.allowed.Add(1)
+		return false
+	}
+	v.manager.blocked.Add(1)
+	return true
+}
+
+// Response returns how the view's blocked names are answered
+func (v *View) Response() *Response {
+	return v.response
+}
+
+// compile rebuilds the view's set from its feeds' current contents. The
+// caller holds the manager's lock
+func (v *View) compile() int {
+	set := make(domainSet)
+	for i, f := range v.manager.feeds {
+		if !v.feeds[i] {
+			continue
+		}
+		for name, subdomains := range f.domains {
+			set.add(name, subdomains)
+		}
+	}
+	v.set.Store(&set)
+	return len(set)
+}
@@ -0,0 +1,106 @@
+// internal/cache/breakdown.go
+package cache
+
+import (
+	"strings"
+
+	"errantdns.io/internal/models"
+)
+
+// Counts are the hit, miss, and eviction counters for one record type or
+// zone
+type Counts struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// OtherBreakdown collects the types or zones seen after maxBreakdownKeys
+// others, so random names can't grow the breakdown without bound
+const OtherBreakdown = "(other)"
+
+const maxBreakdownKeys = 1000
+
+// breakdown counts a cache's hits, misses, and evictions by record type
+// and by zone (the registrable domain of the name). Callers serialize
+// access
+type breakdown struct {
+	byType map[string]*Counts
+	byZone map[string]*Counts
+}
+
+func newBreakdown() breakdown {
+	return breakdown{byType: make(map[string]*Counts), byZone: make(map[string]*Counts)}
+}
+
+// hit counts a hit in zone for a record type
+func (b *breakdown) hit(zone, recordType string) {
+	counter(b.byType, recordType).Hits++
+	counter(b.byZone, zone).Hits++
+}
+
+// miss counts a miss in zone for a record type
+func (b *breakdown) miss(zone, recordType string) {
+	counter(b.byType, recordType).Misses++
+	counter(b.byZone, zone).Misses++
+}
+
+// evict counts an eviction in zone for a record type
+func (b *breakdown) evict(zone, recordType string) {
+	counter(b.byType, recordType).Evictions++
+	counter(b.byZone, zone).Evictions++
+}
+
+// snapshot copies the counters into stats, with hit rates
+func (b *breakdown) snapshot(stats *Stats) {
+	stats.ByType = snapshotCounts(b.byType)
+	stats.ByZone = snapshotCounts(b.byZone)
+}
+
+// counter returns the counters for key, or for OtherBreakdown once the map
+// is full
+func counter(counts map[string]*Counts, key string) *Counts {
+	if c, ok := counts[key]; ok {
+		return c
+	}
+	if len(counts) >= maxBreakdownKeys {
+		key = OtherBreakdown
+		if c, ok := counts[key]; ok {
+			return c
+		}
+	}
+	c := &Counts{}
+	counts[key] = c
+	return c
+}
+
+func snapshotCounts(counts map[string]*Counts) map[string]Counts {
+	if len(counts) == 0 {
+		return nil
+	}
+	snapshot := make(map[string]Counts, len(counts))
+	for key, c := range counts {
+		copied := *c
+		if total := copied.Hits + copied.Misses; total > 0 {
+			copied.HitRate = float64(copied.Hits) / float64(total) * 100.0
+		}
+		snapshot[key] = copied
+	}
+	return snapshot
+}
+
+// classifyKey returns the zone and record type of a memory cache key,
+// which ends in "name:TYPE" after any prefixes. ok is false for keys of
+// another form
+func classifyKey(key string) (zone, recordType string, ok bool) {
+	i := strings.LastIndexByte(key, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	name, recordType := key[:i], key[i+1:]
+	if j := strings.LastIndexByte(name, ':'); j >= 0 {
+		name = name[j+1:]
+	}
+	return models.ApexDomain(name), recordType, true
+}
@@ -0,0 +1,298 @@
+// internal/cache/eviction.go
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// EvictionPolicy tracks how a memory cache's entries are used and picks
+// which one to evict when the cache is full. The cache serializes calls
+type EvictionPolicy interface {
+	// Added records a new entry
+	Added(key string)
+	// Accessed records a hit or an update of an existing entry
+	Accessed(key string)
+	// Removed forgets an entry that was deleted or expired
+	Removed(key string)
+	// Evict forgets and returns the entry to evict, or false if the policy
+	// tracks no entries
+	Evict() (string, bool)
+}
+
+// EvictionFactory creates a policy for a cache of up to capacity entries
+type EvictionFactory func(capacity int) EvictionPolicy
+
+// Names of the built-in eviction policies
+const (
+	EvictionLRU = "lru"
+	EvictionLFU = "lfu"
+	Eviction2Q  = "2q"
+)
+
+var (
+	evictionMu sync.RWMutex
+	evictions  = map[string]EvictionFactory{
+		EvictionLRU: func(int) EvictionPolicy { return newLRU() },
+		EvictionLFU: func(int) EvictionPolicy { return newLFU() },
+		Eviction2Q:  func(capacity int) EvictionPolicy { return newTwoQueue(capacity) },
+	}
+)
+
+// RegisterEviction makes an eviction policy available by name, replacing
+// any registered under it. Call it before configuration is loaded
+func RegisterEviction(name string, factory EvictionFactory) {
+	evictionMu.Lock()
+	defer evictionMu.Unlock()
+	evictions[name] = factory
+}
+
+// NewEviction creates the policy registered under name for a cache of up
+// to capacity entries
+func NewEviction(name string, capacity int) (EvictionPolicy, error) {
+	evictionMu.RLock()
+	defer evictionMu.RUnlock()
+
+	factory, ok := evictions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown eviction policy %q", name)
+	}
+	return factory(capacity), nil
+}
+
+// EvictionNames returns the names of all registered eviction policies,
+// sorted
+func EvictionNames() []string {
+	evictionMu.RLock()
+	defer evictionMu.RUnlock()
+
+	names := make([]string, 0, len(evictions))
+	for name := range evictions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lru evicts the least recently used entry
+type lru struct {
+	order    *list.List // most recently used first
+	elements map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{order: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (p *lru) Added(key string) {
+	if element, ok := p.elements[key]; ok {
+		p.order.MoveToFront(element)
+		return
+	}
+	p.elements[key] = p.order.PushFront(key)
+}
+
+func (p *lru) Accessed(key string) {
+	if element, ok := p.elements[key]; ok {
+		p.order.MoveToFront(element)
+	}
+}
+
+func (p *lru) Removed(key string) {
+	if element, ok := p.elements[key]; ok {
+		p.order.Remove(element)
+		delete(p.elements, key)
+	}
+}
+
+func (p *lru) Evict() (string, bool) {
+	element := p.order.Back()
+	if element == nil {
+		return "", false
+	}
+	key := p.order.Remove(element).(string)
+	delete(p.elements, key)
+	return key, true
+}
+
+// lfu evicts the least frequently used entry, the least recently used of
+// those on a tie. A new entry starts at one use, so hot entries survive
+// bursts of names queried once
+type lfu struct {
+	entries map[string]*lfuEntry
+	counts  map[int]*list.List // entries by use count, most recently used first
+	min     int                // lowest use count, maybe stale after removals
+}
+
+type lfuEntry struct {
+	count   int
+	element *list.Element
+}
+
+func newLFU() *lfu {
+	return &lfu{entries: make(map[string]*lfuEntry), counts: make(map[int]*list.List)}
+}
+
+func (p *lfu) Added(key string) {
+	if _, ok := p.entries[key]; ok {
+		p.Accessed(key)
+		return
+	}
+	p.entries[key] = &lfuEntry{count: 1, element: p.bucket(1).PushFront(key)}
+	p.min = 1
+}
+
+func (p *lfu) Accessed(key string) {
+	entry, ok := p.entries[key]
+	if !ok {
+		return
+	}
+	p.unlink(entry)
+	entry.count++
+	entry.element = p.bucket(entry.count).PushFront(key)
+}
+
+func (p *lfu) Removed(key string) {
+	if entry, ok := p.entries[key]; ok {
+		p.unlink(entry)
+		delete(p.entries, key)
+	}
+}
+
+func (p *lfu) Evict() (string, bool) {
+	if len(p.entries) == 0 {
+		return "", false
+	}
+	if _, ok := p.counts[p.min]; !ok {
+		p.min = 0
+		for count := range p.counts {
+			if p.min == 0 || count < p.min {
+				p.min = count
+			}
+		}
+	}
+
+	key := p.counts[p.min].Back().Value.(string)
+	p.Removed(key)
+	return key, true
+}
+
+// bucket returns the list of entries used count times, creating it
+func (p *lfu) bucket(count int) *list.List {
+	bucket, ok := p.counts[count]
+	if !ok {
+		bucket = list.New()
+		p.counts[count] = bucket
+	}
+	return bucket
+}
+
+// unlink takes an entry out of its count's list, dropping the list once
+// it's empty
+func (p *lfu) unlink(entry *lfuEntry) {
+	bucket := p.counts[entry.count]
+	bucket.Remove(entry.element)
+	if bucket.Len() == 0 {
+		delete(p.counts, entry.count)
+	}
+}
+
+// twoQueue is the 2Q policy. New entries wait in a FIFO probation queue
+// and are evicted from it first, so a scan of names queried once only
+// churns the probation queue. Entries hit while on probation, or added
+// again soon after being evicted from it (a ghost queue remembers recently
+// evicted keys), join the main LRU queue
+type twoQueue struct {
+	probation *list.List // FIFO, newest first
+	main      *list.List // LRU, most recently used first
+	ghosts    *list.List // keys evicted from probation, newest first
+	elements  map[string]*list.Element
+	inMain    map[string]bool
+	ghostKeys map[string]*list.Element
+
+	probationSize int // probation is evicted first once it holds more
+	ghostSize     int
+}
+
+func newTwoQueue(capacity int) *twoQueue {
+	return &twoQueue{
+		probation:     list.New(),
+		main:          list.New(),
+		ghosts:        list.New(),
+		elements:      make(map[string]*list.Element),
+		inMain:        make(map[string]bool),
+		ghostKeys:     make(map[string]*list.Element),
+		probationSize: max(capacity/4, 1),
+		ghostSize:     max(capacity/2, 1),
+	}
+}
+
+func (p *twoQueue) Added(key string) {
+	if _, ok := p.elements[key]; ok {
+		p.Accessed(key)
+		return
+	}
+	if ghost, ok := p.ghostKeys[key]; ok {
+		p.ghosts.Remove(ghost)
+		delete(p.ghostKeys, key)
+		p.elements[key] = p.main.PushFront(key)
+		p.inMain[key] = true
+		return
+	}
+	p.elements[key] = p.probation.PushFront(key)
+}
+
+func (p *twoQueue) Accessed(key string) {
+	element, ok := p.elements[key]
+	switch {
+	case !ok:
+	case p.inMain[key]:
+		p.main.MoveToFront(element)
+	default:
+		p.probation.Remove(element)
+		p.elements[key] = p.main.PushFront(key)
+		p.inMain[key] = true
+	}
+}
+
+func (p *twoQueue) Removed(key string) {
+	element, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	if p.inMain[key] {
+		p.main.Remove(element)
+		delete(p.inMain, key)
+	} else {
+		p.probation.Remove(element)
+	}
+	delete(p.elements, key)
+}
+
+func (p *twoQueue) Evict() (string, bool) {
+	if p.probation.Len() > 0 && (p.probation.Len() > p.probationSize || p.main.Len() == 0) {
+		key := p.probation.Remove(p.probation.Back()).(string)
+		delete(p.elements, key)
+		p.remember(key)
+		return key, true
+	}
+
+	element := p.main.Back()
+	if element == nil {
+		return "", false
+	}
+	key := p.main.Remove(element).(string)
+	delete(p.elements, key)
+	delete(p.inMain, key)
+	return key, true
+}
+
+// remember adds a key evicted from probation to the ghost queue
+func (p *twoQueue) remember(key string) {
+	p.ghostKeys[key] = p.ghosts.PushFront(key)
+	for p.ghosts.Len() > p.ghostSize {
+		delete(p.ghostKeys, p.ghosts.Remove(p.ghosts.Back()).(string))
+	}
+}
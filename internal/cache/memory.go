@@ -14,22 +14,30 @@ type Cache interface {
 	Get(key string) ([]*models.DNSRecord, bool)
 	Set(key string, records []*models.DNSRecord, ttl time.Duration)
 	Delete(key string)
+	DeleteFunc(match func(key string) bool) int
 	Clear()
 
 	// Management
+	Entries() []EntryInfo
 	Size() int
 	Stats() Stats
+	ResetStats()
 	Close() error
 }
 
 // Stats represents cache performance statistics
 type Stats struct {
-	Hits        int64     `json:"hits"`
-	Misses      int64     `json:"misses"`
-	Entries     int       `json:"entries"`
-	Evictions   int64     `json:"evictions"`
-	LastCleanup time.Time `json:"last_cleanup"`
-	HitRate     float64   `json:"hit_rate"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"` // approximate memory held by entries
+
+	// Hits, misses, and evictions by record type and by zone
+	ByType      map[string]Counts `json:"by_type,omitempty"`
+	ByZone      map[string]Counts `json:"by_zone,omitempty"`
+	Evictions   int64             `json:"evictions"`
+	LastCleanup time.Time         `json:"last_cleanup"`
+	HitRate     float64           `json:"hit_rate"`
 }
 
 // calculateHitRate computes the cache hit rate as a percentage
@@ -42,10 +50,20 @@ func (s *Stats) calculateHitRate() {
 	}
 }
 
+// EntryInfo describes a live cache entry for inspection
+type EntryInfo struct {
+	Key       string
+	Records   int
+	ExpiresAt time.Time
+	Hits      int64
+}
+
 type cacheEntry struct {
 	records    []*models.DNSRecord // <- Should be records (plural)
 	expiresAt  time.Time
 	lastAccess time.Time
+	hits       int64
+	size       int64 // approximate bytes, see entrySize
 }
 
 // isExpired checks if the cache entry has expired
@@ -53,13 +71,18 @@ func (e *cacheEntry) isExpired() bool {
 	return time.Now().After(e.expiresAt)
 }
 
-// MemoryCache implements an in-memory LRU cache with TTL support
+// MemoryCache implements an in-memory cache with TTL support, evicting
+// entries with a pluggable policy (LRU by default) when full
 type MemoryCache struct {
-	mu          sync.RWMutex
-	data        map[string]*cacheEntry
-	accessOrder []string
-	maxEntries  int
-	stats       Stats
+	mu         sync.RWMutex
+	data       map[string]*cacheEntry
+	eviction   EvictionPolicy
+	newPolicy  func() EvictionPolicy
+	maxEntries int
+	maxBytes   int64 // 0 bounds entries only
+	bytes      int64
+	stats      Stats
+	breakdown  breakdown
 
 	// Background cleanup
 	cleanupInterval time.Duration
@@ -71,6 +94,8 @@ type MemoryCache struct {
 // Config holds configuration for the memory cache
 type Config struct {
 	MaxEntries      int
+	MaxBytes        int64  // approximate memory bound, evicting entries until new ones fit; 0 disables
+	Eviction        string // registered eviction policy; empty or unknown uses LRU
 	CleanupInterval time.Duration
 }
 
@@ -88,10 +113,20 @@ func NewMemoryCache(config *Config) *MemoryCache {
 		config = DefaultConfig()
 	}
 
+	newPolicy := func() EvictionPolicy {
+		if policy, err := NewEviction(config.Eviction, config.MaxEntries); err == nil {
+			return policy
+		}
+		return newLRU()
+	}
+
 	cache := &MemoryCache{
 		data:            make(map[string]*cacheEntry),
-		accessOrder:     make([]string, 0, config.MaxEntries),
+		eviction:        newPolicy(),
+		newPolicy:       newPolicy,
+		breakdown:       newBreakdown(),
 		maxEntries:      config.MaxEntries,
+		maxBytes:        config.MaxBytes,
 		cleanupInterval: config.CleanupInterval,
 		cleanupStop:     make(chan struct{}),
 		cleanupDone:     make(chan struct{}),
@@ -107,12 +142,17 @@ func NewMemoryCache(config *Config) *MemoryCache {
 
 // Get retrieves records from the cache
 func (c *MemoryCache) Get(key string) ([]*models.DNSRecord, bool) {
+	zone, recordType, classified := classifyKey(key)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	entry, exists := c.data[key]
 	if !exists {
 		c.stats.Misses++
+		if classified {
+			c.breakdown.miss(zone, recordType)
+		}
 		return nil, false
 	}
 
@@ -120,13 +160,19 @@ func (c *MemoryCache) Get(key string) ([]*models.DNSRecord, bool) {
 	if entry.isExpired() {
 		c.deleteUnlocked(key)
 		c.stats.Misses++
+		if classified {
+			c.breakdown.miss(zone, recordType)
+		}
 		return nil, false
 	}
 
-	// Update access time and move to front for LRU
 	entry.lastAccess = time.Now()
-	c.moveToFrontUnlocked(key)
+	entry.hits++
+	c.eviction.Accessed(key)
 	c.stats.Hits++
+	if classified {
+		c.breakdown.hit(zone, recordType)
+	}
 
 	return entry.records, true
 }
@@ -137,32 +183,43 @@ func (c *MemoryCache) Set(key string, records []*models.DNSRecord, ttl time.Dura
 	defer c.mu.Unlock()
 
 	now := time.Now()
+	entry := &cacheEntry{
+		records:    records,
+		expiresAt:  now.Add(ttl),
+		lastAccess: now,
+		size:       entrySize(key, records),
+	}
+
+	// An entry over the whole byte budget would only evict everything else
+	if c.maxBytes > 0 && entry.size > c.maxBytes {
+		c.deleteUnlocked(key)
+		return
+	}
 
 	// If key already exists, update it
-	if _, exists := c.data[key]; exists {
-		c.data[key] = &cacheEntry{
-			records:    records,
-			expiresAt:  now.Add(ttl),
-			lastAccess: now,
+	if old, exists := c.data[key]; exists {
+		c.bytes += entry.size - old.size
+		c.data[key] = entry
+		c.eviction.Accessed(key)
+		for c.maxBytes > 0 && c.bytes > c.maxBytes {
+			if !c.evictUnlocked() {
+				break
+			}
 		}
-		c.moveToFrontUnlocked(key)
 		return
 	}
 
 	// Check if we need to evict entries
-	for len(c.data) >= c.maxEntries {
-		c.evictLRUUnlocked()
+	for len(c.data) >= c.maxEntries || c.maxBytes > 0 && c.bytes+entry.size > c.maxBytes {
+		if !c.evictUnlocked() {
+			break
+		}
 	}
 
 	// Add new entry
-	c.data[key] = &cacheEntry{
-		records:    records,
-		expiresAt:  now.Add(ttl),
-		lastAccess: now,
-	}
-
-	// Add to front of access order
-	c.accessOrder = append([]string{key}, c.accessOrder...)
+	c.data[key] = entry
+	c.bytes += entry.size
+	c.eviction.Added(key)
 }
 
 // Delete removes a record from the cache
@@ -172,16 +229,54 @@ func (c *MemoryCache) Delete(key string) {
 	c.deleteUnlocked(key)
 }
 
+// DeleteFunc removes every entry whose key matches and returns how many
+// were removed
+func (c *MemoryCache) DeleteFunc(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for key := range c.data {
+		if match(key) {
+			c.deleteUnlocked(key)
+			removed++
+		}
+	}
+	return removed
+}
+
 // Clear removes all entries from the cache
 func (c *MemoryCache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.data = make(map[string]*cacheEntry)
-	c.accessOrder = c.accessOrder[:0]
+	c.eviction = c.newPolicy()
+	c.bytes = 0
 	c.stats.Entries = 0
 }
 
+// Entries describes every unexpired entry, in no particular order
+func (c *MemoryCache) Entries() []EntryInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	entries := make([]EntryInfo, 0, len(c.data))
+	for key, entry := range c.data {
+		if now.After(entry.expiresAt) {
+			continue
+		}
+		entries = append(entries, EntryInfo{
+			Key:       key,
+			Records:   len(entry.records),
+			ExpiresAt: entry.expiresAt,
+			Hits:      entry.hits,
+		})
+	}
+	return entries
+}
+
 // Size returns the current number of entries in the cache
 func (c *MemoryCache) Size() int {
 	c.mu.RLock()
@@ -196,10 +291,21 @@ func (c *MemoryCache) Stats() Stats {
 
 	stats := c.stats
 	stats.Entries = len(c.data)
+	stats.Bytes = c.bytes
 	stats.calculateHitRate()
+	c.breakdown.snapshot(&stats)
 	return stats
 }
 
+// ResetStats zeroes the hit, miss, and eviction counters
+func (c *MemoryCache) ResetStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = Stats{LastCleanup: c.stats.LastCleanup}
+	c.breakdown = newBreakdown()
+}
+
 // Close stops the background cleanup and releases resources
 func (c *MemoryCache) Close() error {
 	if c.cleanupTicker != nil {
@@ -253,45 +359,34 @@ func (c *MemoryCache) cleanupExpired() {
 	c.stats.LastCleanup = now
 }
 
-// moveToFrontUnlocked moves a key to the front of the access order (most recently used)
+// evictUnlocked removes the entry the eviction policy picks, reporting
+// whether there was one
 // Must be called with mutex locked
-func (c *MemoryCache) moveToFrontUnlocked(key string) {
-	// Find and remove the key from its current position
-	for i, k := range c.accessOrder {
-		if k == key {
-			// Remove from current position
-			c.accessOrder = append(c.accessOrder[:i], c.accessOrder[i+1:]...)
-			break
-		}
+func (c *MemoryCache) evictUnlocked() bool {
+	key, ok := c.eviction.Evict()
+	if !ok {
+		return false
 	}
 
-	// Add to front
-	c.accessOrder = append([]string{key}, c.accessOrder...)
-}
-
-// evictLRUUnlocked removes the least recently used entry
-// Must be called with mutex locked
-func (c *MemoryCache) evictLRUUnlocked() {
-	if len(c.accessOrder) == 0 {
-		return
+	if entry, exists := c.data[key]; exists {
+		delete(c.data, key)
+		c.bytes -= entry.size
 	}
-
-	// Remove the last entry (least recently used)
-	lruKey := c.accessOrder[len(c.accessOrder)-1]
-	c.deleteUnlocked(lruKey)
 	c.stats.Evictions++
+	if zone, recordType, ok := classifyKey(key); ok {
+		c.breakdown.evict(zone, recordType)
+	}
+	return true
 }
 
 // deleteUnlocked removes an entry from the cache
 // Must be called with mutex locked
 func (c *MemoryCache) deleteUnlocked(key string) {
-	delete(c.data, key)
-
-	// Remove from access order
-	for i, k := range c.accessOrder {
-		if k == key {
-			c.accessOrder = append(c.accessOrder[:i], c.accessOrder[i+1:]...)
-			break
-		}
+	entry, exists := c.data[key]
+	if !exists {
+		return
 	}
+	delete(c.data, key)
+	c.bytes -= entry.size
+	c.eviction.Removed(key)
 }
@@ -0,0 +1,61 @@
+// internal/cache/size.go
+package cache
+
+import (
+	"time"
+	"unsafe"
+
+	"errantdns.io/internal/models"
+)
+
+// Approximate costs of the memory a cached entry holds beyond its
+// contents. They needn't be exact, only proportional enough that a byte
+// budget tracks real usage
+const (
+	stringHeaderSize = int64(unsafe.Sizeof(""))
+	pointerSize      = int64(unsafe.Sizeof(uintptr(0)))
+	mapEntryOverhead = 48 // bucket slot, hash, and per-entry bookkeeping
+)
+
+// entrySize approximates the bytes held by a cache entry: the entry, its
+// map and access order slots, and the records
+func entrySize(key string, records []*models.DNSRecord) int64 {
+	size := int64(unsafe.Sizeof(cacheEntry{})) + mapEntryOverhead
+	size += 2 * (stringHeaderSize + int64(len(key))) // map key and access order
+	size += int64(cap(records)) * pointerSize
+	for _, record := range records {
+		size += recordSize(record)
+	}
+	return size
+}
+
+// recordSize approximates the bytes held by a record
+func recordSize(record *models.DNSRecord) int64 {
+	if record == nil {
+		return 0
+	}
+
+	size := int64(unsafe.Sizeof(*record))
+	for _, s := range []string{
+		record.Name, record.RecordType, record.Target, record.ETLD, record.ApexDomain,
+		record.Mbox, record.Tag, record.Comment, record.Owner,
+	} {
+		size += int64(len(s))
+	}
+
+	size += int64(cap(record.SubdomainLabels)) * stringHeaderSize
+	for _, label := range record.SubdomainLabels {
+		size += int64(len(label))
+	}
+
+	for _, t := range []*time.Time{record.ExpiresAt, record.NotBefore, record.NotAfter} {
+		if t != nil {
+			size += int64(unsafe.Sizeof(*t))
+		}
+	}
+
+	for k, v := range record.Labels {
+		size += mapEntryOverhead + 2*stringHeaderSize + int64(len(k)+len(v))
+	}
+	return size
+}
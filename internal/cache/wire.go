@@ -0,0 +1,235 @@
+// internal/cache/wire.go
+package cache
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/models"
+)
+
+// WireKey identifies a rendered response
+type WireKey struct {
+	Name     string // lowercased query name
+	Type     uint16
+	EDNSSize uint16 // advertised UDP payload size, 0 without EDNS
+	View     string // policy group whose answers are rewritten; empty for unrewritten answers
+}
+
+// WireCache is an L0 cache of fully packed DNS responses. Entries are
+// copied into a buffer and patched (ID, flags, name case) on the way out,
+// so hot queries skip lookup and RR construction entirely
+type WireCache struct {
+	mu         sync.Mutex
+	entries    map[WireKey]*list.Element
+	lru        *list.List
+	maxEntries int
+	maxTTL     time.Duration
+	bytes      int64 // packed response sizes
+	stats      Stats
+	breakdown  breakdown
+}
+
+type wireEntry struct {
+	key       WireKey
+	packed    []byte
+	expiresAt time.Time
+	hits      int64
+}
+
+// WireConfig holds configuration for the wire-format cache
+type WireConfig struct {
+	MaxEntries int
+	MaxTTL     time.Duration // caps entry lifetime regardless of record TTLs
+}
+
+// NewWireCache creates a wire-format response cache
+func NewWireCache(config *WireConfig) *WireCache {
+	if config == nil {
+		config = &WireConfig{MaxEntries: 10000, MaxTTL: 10 * time.Second}
+	}
+
+	return &WireCache{
+		entries:    make(map[WireKey]*list.Element),
+		lru:        list.New(),
+		maxEntries: config.MaxEntries,
+		maxTTL:     config.MaxTTL,
+		breakdown:  newBreakdown(),
+	}
+}
+
+// Get copies the packed response for key into dst (growing it if needed)
+// and returns the filled slice
+func (c *WireCache) Get(key WireKey, dst []byte) ([]byte, bool) {
+	zone, recordType := models.ApexDomain(key.Name), dns.TypeToString[key.Type]
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		c.stats.Misses++
+		c.breakdown.miss(zone, recordType)
+		return nil, false
+	}
+
+	entry := element.Value.(*wireEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.removeUnlocked(element)
+		c.stats.Misses++
+		c.breakdown.miss(zone, recordType)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	c.stats.Hits++
+	c.breakdown.hit(zone, recordType)
+	entry.hits++
+	return append(dst[:0], entry.packed...), true
+}
+
+// Set stores a copy of packed under key for ttl, capped at the configured maximum
+func (c *WireCache) Set(key WireKey, packed []byte, ttl time.Duration) {
+	if ttl <= 0 || c.maxEntries <= 0 {
+		return
+	}
+	if c.maxTTL > 0 && ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+
+	entry := &wireEntry{
+		key:       key,
+		packed:    append([]byte(nil), packed...),
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[key]; exists {
+		c.bytes += int64(len(entry.packed) - len(element.Value.(*wireEntry).packed))
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return
+	}
+
+	for c.lru.Len() >= c.maxEntries {
+		evicted := c.lru.Back().Value.(*wireEntry).key
+		c.removeUnlocked(c.lru.Back())
+		c.stats.Evictions++
+		c.breakdown.evict(models.ApexDomain(evicted.Name), dns.TypeToString[evicted.Type])
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += int64(len(entry.packed))
+}
+
+// InvalidateName removes every entry for name (lowercased, fully qualified)
+// regardless of query type or EDNS size
+func (c *WireCache) InvalidateName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.Name == name {
+			c.removeUnlocked(element)
+		}
+	}
+}
+
+// InvalidateZone removes every entry for apex (lowercased, fully qualified)
+// and the names below it
+func (c *WireCache) InvalidateZone(apex string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.Name == apex || strings.HasSuffix(key.Name, "."+apex) {
+			c.removeUnlocked(element)
+		}
+	}
+}
+
+// Clear removes all entries
+func (c *WireCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[WireKey]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// Entries describes every unexpired response, most recently used first.
+// Keys are "EDNSSIZE:name:TYPE" and Records counts the answer section
+func (c *WireCache) Entries() []EntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]EntryInfo, 0, c.lru.Len())
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*wireEntry)
+		if now.After(entry.expiresAt) {
+			continue
+		}
+
+		answers := 0
+		if len(entry.packed) >= 8 {
+			answers = int(binary.BigEndian.Uint16(entry.packed[6:8]))
+		}
+		key := fmt.Sprintf("%d:%s:%s", entry.key.EDNSSize, strings.TrimSuffix(entry.key.Name, "."), dns.TypeToString[entry.key.Type])
+		if entry.key.View != "" {
+			key = entry.key.View + ":" + key
+		}
+		entries = append(entries, EntryInfo{
+			Key:       key,
+			Records:   answers,
+			ExpiresAt: entry.expiresAt,
+			Hits:      entry.hits,
+		})
+	}
+	return entries
+}
+
+// Size returns the number of cached responses
+func (c *WireCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Stats returns cache statistics
+func (c *WireCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	stats.calculateHitRate()
+	c.breakdown.snapshot(&stats)
+	return stats
+}
+
+// ResetStats zeroes hit, miss, and eviction counters
+func (c *WireCache) ResetStats() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats = Stats{}
+	c.breakdown = newBreakdown()
+}
+
+// removeUnlocked drops an element. Callers must hold c.mu
+func (c *WireCache) removeUnlocked(element *list.Element) {
+	c.lru.Remove(element)
+	entry := element.Value.(*wireEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= int64(len(entry.packed))
+}
@@ -0,0 +1,154 @@
+// internal/catalog/catalog.go
+package catalog
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/models"
+)
+
+// Version is the catalog zone schema version implemented (RFC 9432)
+const Version = "2"
+
+// Member is a zone listed in a catalog
+type Member struct {
+	ID    string // unique label under zones.<catalog>
+	Zone  string // member zone name, normalized
+	Group string // optional group property
+}
+
+// MemberID returns the stable member label for a zone: the hex SHA-1 of
+// its lowercase wire-format name, as suggested by RFC 9432
+func MemberID(zone string) string {
+	name := dns.Fqdn(models.NormalizeDomainName(zone))
+
+	wire := make([]byte, 255)
+	n, err := dns.PackDomainName(name, wire, 0, nil, false)
+	if err != nil {
+		// Unpackable names can't be zones; hash the text so IDs stay unique
+		sum := sha1.Sum([]byte(name))
+		return hex.EncodeToString(sum[:])
+	}
+
+	sum := sha1.Sum(wire[:n])
+	return hex.EncodeToString(sum[:])
+}
+
+// catalogTTL is the TTL of catalog records, which are never resolved
+const catalogTTL = 0
+
+// Build returns the catalog zone's records: SOA, NS, version, and one PTR
+// per member, ordered by member ID
+func Build(catalog string, members []string, serial uint32) []dns.RR {
+	origin := dns.Fqdn(models.NormalizeDomainName(catalog))
+
+	records := []dns.RR{
+		&dns.SOA{
+			Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: catalogTTL},
+			Ns:      "invalid.",
+			Mbox:    "invalid.",
+			Serial:  serial,
+			Refresh: 60,
+			Retry:   10,
+			Expire:  3600,
+			Minttl:  0,
+		},
+		&dns.NS{
+			Hdr: dns.RR_Header{Name: origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: catalogTTL},
+			Ns:  "invalid.",
+		},
+		&dns.TXT{
+			Hdr: dns.RR_Header{Name: "version." + origin, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: catalogTTL},
+			Txt: []string{Version},
+		},
+	}
+
+	ptrs := make([]*dns.PTR, 0, len(members))
+	for _, member := range members {
+		ptrs = append(ptrs, &dns.PTR{
+			Hdr: dns.RR_Header{Name: MemberID(member) + ".zones." + origin, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: catalogTTL},
+			Ptr: dns.Fqdn(models.NormalizeDomainName(member)),
+		})
+	}
+	sort.Slice(ptrs, func(i, j int) bool { return ptrs[i].Hdr.Name < ptrs[j].Hdr.Name })
+
+	for _, ptr := range ptrs {
+		records = append(records, ptr)
+	}
+	return records
+}
+
+// Parse extracts the member zones from a transferred catalog. It fails if
+// the catalog's schema version is missing or unsupported, since RFC 9432
+// forbids processing such catalogs. Member IDs with more than one PTR and
+// zones listed more than once are ignored
+func Parse(catalog string, records []dns.RR) ([]Member, error) {
+	origin := dns.Fqdn(models.NormalizeDomainName(catalog))
+	zonesSuffix := ".zones." + origin
+
+	var version string
+	ptrs := make(map[string][]string)
+	groups := make(map[string]string)
+
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header().Name)
+
+		switch v := rr.(type) {
+		case *dns.TXT:
+			if name == "version."+origin {
+				version = strings.Join(v.Txt, "")
+				continue
+			}
+			// group.<id>.zones.<catalog>
+			if strings.HasPrefix(name, "group.") && strings.HasSuffix(name, zonesSuffix) {
+				id := strings.TrimSuffix(strings.TrimPrefix(name, "group."), zonesSuffix)
+				if !strings.Contains(id, ".") {
+					groups[id] = strings.Join(v.Txt, "")
+				}
+			}
+		case *dns.PTR:
+			if !strings.HasSuffix(name, zonesSuffix) {
+				continue
+			}
+			id := strings.TrimSuffix(name, zonesSuffix)
+			if id == "" || strings.Contains(id, ".") {
+				continue // property of a member, e.g. coo
+			}
+			ptrs[id] = append(ptrs[id], models.NormalizeDomainName(v.Ptr))
+		}
+	}
+
+	if version == "" {
+		return nil, fmt.Errorf("catalog %s has no version record", origin)
+	}
+	if version != Version {
+		return nil, fmt.Errorf("catalog %s has unsupported schema version %q", origin, version)
+	}
+
+	ids := make([]string, 0, len(ptrs))
+	for id := range ptrs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var members []Member
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if len(ptrs[id]) != 1 {
+			continue
+		}
+		zone := ptrs[id][0]
+		if seen[zone] {
+			continue
+		}
+		seen[zone] = true
+		members = append(members, Member{ID: id, Zone: zone, Group: groups[id]})
+	}
+	return members, nil
+}
@@ -0,0 +1,304 @@
+// internal/catalog/consumer.go
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/importer"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/storage"
+)
+
+// Labels identifying records owned by the consumer. Records without them
+// are never modified
+const (
+	LabelSource  = "source"
+	LabelCatalog = "catalog/name"
+	LabelZone    = "catalog/zone"
+	LabelGroup   = "catalog/group"
+
+	sourceName = "catalog"
+)
+
+// ConsumerConfig holds configuration for following a remote catalog zone
+type ConsumerConfig struct {
+	Zone     string        // catalog zone name
+	Primary  string        // primary nameserver serving the catalog and its members
+	Interval time.Duration // how often SOA serials are checked
+	Timeout  time.Duration // per query and transfer
+}
+
+// Consumer follows a catalog zone on a primary nameserver and mirrors
+// each member zone into storage by zone transfer. Serials are polled, so
+// changes arrive within one interval
+type Consumer struct {
+	origin     string
+	primary    string
+	interval   time.Duration
+	timeout    time.Duration
+	store      importer.Store
+	reconciler *importer.Reconciler
+
+	// Only touched by Run
+	catalogSerial uint32
+	members       []Member
+	serials       map[string]uint32 // member zone -> last transferred serial
+}
+
+// NewConsumer creates a catalog consumer. The invalidator, if not nil, is
+// told about every name/type the consumer changes
+func NewConsumer(store importer.Store, invalidator storage.Invalidator, config *ConsumerConfig) (*Consumer, error) {
+	if config.Zone == "" || config.Primary == "" {
+		return nil, fmt.Errorf("catalog zone and primary are required")
+	}
+
+	primary := config.Primary
+	if _, _, err := net.SplitHostPort(primary); err != nil {
+		primary = net.JoinHostPort(primary, "53")
+	}
+
+	return &Consumer{
+		origin:   dns.Fqdn(models.NormalizeDomainName(config.Zone)),
+		primary:  primary,
+		interval: config.Interval,
+		timeout:  config.Timeout,
+		store:    store,
+		reconciler: &importer.Reconciler{
+			Store:       store,
+			Invalidator: invalidator,
+			Key:         recordKey,
+		},
+		serials: make(map[string]uint32),
+	}, nil
+}
+
+// Run syncs immediately and then on every tick until the context is cancelled
+func (c *Consumer) Run(ctx context.Context) {
+	c.Sync(ctx)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Sync(ctx)
+		}
+	}
+}
+
+// Sync refreshes the member list when the catalog serial changes,
+// transfers members whose serial changed, and deletes the records of
+// zones removed from the catalog
+func (c *Consumer) Sync(ctx context.Context) {
+	if err := c.refreshMembers(ctx); err != nil {
+		logging.Error("catalog", "Failed to refresh catalog", err, "catalog", c.origin, "primary", c.primary)
+		return
+	}
+
+	current := make(map[string]bool, len(c.members))
+	for _, member := range c.members {
+		current[member.Zone] = true
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err := c.syncMember(ctx, member); err != nil {
+			logging.Error("catalog", "Failed to sync member zone", err, "catalog", c.origin, "zone", member.Zone)
+		}
+	}
+
+	c.removeStale(ctx, current)
+}
+
+// refreshMembers transfers and parses the catalog if its serial changed
+func (c *Consumer) refreshMembers(ctx context.Context) error {
+	serial, err := c.soaSerial(ctx, c.origin)
+	if err != nil {
+		return err
+	}
+	if c.members != nil && serial == c.catalogSerial {
+		return nil
+	}
+
+	records, err := c.transfer(c.origin)
+	if err != nil {
+		return err
+	}
+
+	members, err := Parse(c.origin, records)
+	if err != nil {
+		return err
+	}
+	if members == nil {
+		members = []Member{}
+	}
+
+	logging.Info("catalog", "Catalog loaded", "catalog", c.origin, "serial", serial, "members", len(members))
+	c.catalogSerial = serial
+	c.members = members
+	return nil
+}
+
+// syncMember transfers a member zone if its serial changed and reconciles
+// the result into storage
+func (c *Consumer) syncMember(ctx context.Context, member Member) error {
+	serial, err := c.soaSerial(ctx, member.Zone)
+	if err != nil {
+		return err
+	}
+	if last, ok := c.serials[member.Zone]; ok && last == serial {
+		return nil
+	}
+
+	rrs, err := c.transfer(member.Zone)
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{
+		LabelSource:  sourceName,
+		LabelCatalog: models.NormalizeDomainName(c.origin),
+		LabelZone:    member.Zone,
+	}
+	if member.Group != "" {
+		labels[LabelGroup] = member.Group
+	}
+
+	var desired []*models.DNSRecord
+	skipped := 0
+	for _, rr := range rrs {
+		record, err := importer.RecordFromRR(rr)
+		if err != nil {
+			skipped++
+			continue
+		}
+		record.Labels = make(map[string]string, len(labels))
+		for key, value := range labels {
+			record.Labels[key] = value
+		}
+
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			logging.Debug("catalog", "Transferred record not imported", "zone", member.Zone, "name", record.Name, "type", record.RecordType, "reason", err)
+			skipped++
+			continue
+		}
+		desired = append(desired, record)
+	}
+
+	if len(desired) == 0 {
+		return fmt.Errorf("transfer of %s produced no importable records", member.Zone)
+	}
+
+	existing, err := c.store.ListRecords(ctx, &models.RecordFilter{
+		Labels: map[string]string{LabelSource: sourceName, LabelZone: member.Zone},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list synced records: %w", err)
+	}
+
+	result := c.reconciler.Reconcile(ctx, existing, desired, nil)
+	if result.Failed == 0 {
+		c.serials[member.Zone] = serial
+	}
+
+	logging.Info("catalog", "Member zone synced", "zone", member.Zone, "serial", serial,
+		"created", result.Created, "updated", result.Updated, "deleted", result.Deleted,
+		"unchanged", result.Unchanged, "skipped", skipped, "failed", result.Failed)
+	return nil
+}
+
+// removeStale deletes records synced from this catalog for zones that are
+// no longer members
+func (c *Consumer) removeStale(ctx context.Context, current map[string]bool) {
+	synced, err := c.store.ListRecords(ctx, &models.RecordFilter{
+		Labels: map[string]string{LabelSource: sourceName, LabelCatalog: models.NormalizeDomainName(c.origin)},
+	})
+	if err != nil {
+		logging.Error("catalog", "Failed to list synced records", err, "catalog", c.origin)
+		return
+	}
+
+	stale := make(map[string][]*models.DNSRecord)
+	for _, record := range synced {
+		if zone := record.Labels[LabelZone]; !current[zone] {
+			stale[zone] = append(stale[zone], record)
+		}
+	}
+
+	for zone, records := range stale {
+		result := c.reconciler.Reconcile(ctx, records, nil, nil)
+		delete(c.serials, zone)
+		logging.Info("catalog", "Member zone removed", "catalog", c.origin, "zone", zone,
+			"deleted", result.Deleted, "failed", result.Failed)
+	}
+}
+
+// soaSerial queries the primary for a zone's current SOA serial
+func (c *Consumer) soaSerial(ctx context.Context, zone string) (uint32, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(zone), dns.TypeSOA)
+
+	client := &dns.Client{Net: "udp", Timeout: c.timeout}
+	response, _, err := client.ExchangeContext(ctx, msg, c.primary)
+	if err != nil {
+		return 0, fmt.Errorf("SOA query for %s failed: %w", zone, err)
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("SOA query for %s returned %s", zone, dns.RcodeToString[response.Rcode])
+	}
+
+	for _, rr := range response.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+	return 0, fmt.Errorf("primary returned no SOA for %s", zone)
+}
+
+// transfer performs an AXFR of zone from the primary, returning its
+// records without the closing SOA
+func (c *Consumer) transfer(zone string) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(zone))
+
+	tr := &dns.Transfer{DialTimeout: c.timeout, ReadTimeout: c.timeout}
+	envelopes, err := tr.In(msg, c.primary)
+	if err != nil {
+		return nil, fmt.Errorf("transfer of %s failed: %w", zone, err)
+	}
+
+	var records []dns.RR
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, fmt.Errorf("transfer of %s failed: %w", zone, envelope.Error)
+		}
+		records = append(records, envelope.RR...)
+	}
+
+	if n := len(records); n > 1 && records[n-1].Header().Rrtype == dns.TypeSOA {
+		records = records[:n-1]
+	}
+	return records, nil
+}
+
+// recordKey identifies a transferred record by owner, type, and data, so
+// TTL and SOA timer changes become updates. A zone has one SOA
+func recordKey(record *models.DNSRecord) string {
+	if record.RecordType == string(models.RecordTypeSOA) {
+		return record.Name + "|SOA"
+	}
+	return record.Name + "|" + record.RecordType + "|" + record.Target + "|" +
+		strconv.Itoa(record.Priority) + "|" + strconv.FormatUint(uint64(record.Weight), 10) + "|" +
+		strconv.FormatUint(uint64(record.Port), 10) + "|" + record.Tag
+}
@@ -0,0 +1,269 @@
+// internal/catalog/producer.go
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ZoneLister enumerates records; zones are the names holding an SOA record
+type ZoneLister interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+}
+
+// ProducerConfig holds configuration for publishing a catalog zone
+type ProducerConfig struct {
+	Zone            string        // catalog zone name, e.g. catalog.example.internal
+	AllowTransfer   []string      // CIDRs permitted to query and transfer the catalog
+	Notify          []string      // consumers sent a NOTIFY when membership changes
+	RefreshInterval time.Duration // how often membership is reloaded from storage
+}
+
+// Producer publishes every zone ErrantDNS is authoritative for as a
+// catalog zone that consumers can transfer with AXFR
+type Producer struct {
+	zones    ZoneLister
+	origin   string
+	acl      *forwarder.ACL
+	notify   []string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	members []string
+	serial  uint32
+	records []dns.RR
+}
+
+// NewProducer creates a catalog producer, returning an error for an
+// invalid transfer ACL
+func NewProducer(zones ZoneLister, config *ProducerConfig) (*Producer, error) {
+	if config.Zone == "" {
+		return nil, fmt.Errorf("catalog zone name is required")
+	}
+
+	acl, err := forwarder.NewACL(config.AllowTransfer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog transfer ACL: %w", err)
+	}
+
+	notify := make([]string, len(config.Notify))
+	for i, address := range config.Notify {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, "53")
+		}
+		notify[i] = address
+	}
+
+	return &Producer{
+		zones:    zones,
+		origin:   dns.Fqdn(models.NormalizeDomainName(config.Zone)),
+		acl:      acl,
+		notify:   notify,
+		interval: config.RefreshInterval,
+	}, nil
+}
+
+// Zone returns the catalog zone's fully qualified name
+func (p *Producer) Zone() string {
+	return p.origin
+}
+
+// Run refreshes membership immediately and then on every tick until the
+// context is cancelled
+func (p *Producer) Run(ctx context.Context) {
+	if err := p.Refresh(ctx); err != nil {
+		logging.Error("catalog", "Failed to build catalog zone", err, "zone", p.origin)
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Refresh(ctx); err != nil {
+				logging.Error("catalog", "Failed to refresh catalog zone", err, "zone", p.origin)
+			}
+		}
+	}
+}
+
+// Refresh reloads the member zones and, if they changed, bumps the
+// catalog serial and notifies consumers
+func (p *Producer) Refresh(ctx context.Context) error {
+	soas, err := p.zones.ListRecords(ctx, &models.RecordFilter{RecordType: string(models.RecordTypeSOA)})
+	if err != nil {
+		return err
+	}
+
+	catalogName := models.NormalizeDomainName(p.origin)
+	seen := make(map[string]bool, len(soas))
+	members := make([]string, 0, len(soas))
+	for _, soa := range soas {
+		zone := models.NormalizeDomainName(soa.Name)
+		if zone == catalogName || seen[zone] {
+			continue
+		}
+		seen[zone] = true
+		members = append(members, zone)
+	}
+	sort.Strings(members)
+
+	p.mu.Lock()
+	if p.records != nil && slices.Equal(p.members, members) {
+		p.mu.Unlock()
+		return nil
+	}
+
+	// Date-based serials survive restarts; +1 covers several changes a second
+	serial := uint32(time.Now().Unix())
+	if serial <= p.serial {
+		serial = p.serial + 1
+	}
+	p.members = members
+	p.serial = serial
+	p.records = Build(p.origin, members, serial)
+	p.mu.Unlock()
+
+	logging.Info("catalog", "Catalog zone updated", "zone", p.origin, "members", len(members), "serial", serial)
+	p.sendNotify()
+	return nil
+}
+
+// ServeDNS answers queries and zone transfers for the catalog zone. The
+// catalog is only visible to clients in the transfer ACL
+func (p *Producer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	msg.Authoritative = true
+
+	if r.Opcode != dns.OpcodeQuery {
+		msg.Rcode = dns.RcodeNotImplemented
+		w.WriteMsg(msg)
+		return
+	}
+	if len(r.Question) != 1 {
+		msg.Rcode = dns.RcodeFormatError
+		w.WriteMsg(msg)
+		return
+	}
+	if !p.acl.Contains(remoteIP(w.RemoteAddr())) {
+		msg.Rcode = dns.RcodeRefused
+		w.WriteMsg(msg)
+		return
+	}
+
+	p.mu.RLock()
+	records := p.records
+	p.mu.RUnlock()
+
+	if records == nil {
+		msg.Rcode = dns.RcodeServerFailure
+		w.WriteMsg(msg)
+		return
+	}
+
+	question := r.Question[0]
+	_, tcp := w.RemoteAddr().(*net.TCPAddr)
+
+	switch question.Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		if tcp && strings.EqualFold(question.Name, p.origin) {
+			p.transfer(w, r, records)
+			return
+		}
+		if question.Qtype == dns.TypeIXFR && strings.EqualFold(question.Name, p.origin) {
+			// IXFR over UDP gets the current SOA, telling the client to retry over TCP
+			msg.Answer = append(msg.Answer, records[0])
+		} else {
+			msg.Rcode = dns.RcodeRefused
+		}
+		w.WriteMsg(msg)
+		return
+	}
+
+	exists := false
+	for _, rr := range records {
+		if !strings.EqualFold(rr.Header().Name, question.Name) {
+			continue
+		}
+		exists = true
+		if question.Qtype == dns.TypeANY || rr.Header().Rrtype == question.Qtype {
+			msg.Answer = append(msg.Answer, rr)
+		}
+	}
+	if len(msg.Answer) == 0 {
+		if !exists {
+			msg.Rcode = dns.RcodeNameError
+		}
+		msg.Ns = append(msg.Ns, records[0])
+	}
+	w.WriteMsg(msg)
+}
+
+// transfer streams the catalog as a full zone transfer, framed by the SOA
+func (p *Producer) transfer(w dns.ResponseWriter, r *dns.Msg, records []dns.RR) {
+	envelope := make([]dns.RR, 0, len(records)+1)
+	envelope = append(envelope, records...)
+	envelope = append(envelope, records[0])
+
+	ch := make(chan *dns.Envelope)
+	tr := new(dns.Transfer)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := tr.Out(w, r, ch); err != nil {
+			logging.Warn("catalog", "Catalog transfer failed", "zone", p.origin, "client", w.RemoteAddr().String(), "error", err)
+		}
+	}()
+
+	ch <- &dns.Envelope{RR: envelope}
+	close(ch)
+	wg.Wait()
+	w.Close()
+
+	logging.Debug("catalog", "Catalog transferred", "zone", p.origin, "client", w.RemoteAddr().String(), "records", len(records))
+}
+
+// sendNotify tells each configured consumer the catalog has changed
+func (p *Producer) sendNotify() {
+	if len(p.notify) == 0 {
+		return
+	}
+
+	client := &dns.Client{Net: "udp", Timeout: 2 * time.Second}
+	for _, address := range p.notify {
+		msg := new(dns.Msg)
+		msg.SetNotify(p.origin)
+
+		if _, _, err := client.Exchange(msg, address); err != nil {
+			logging.Warn("catalog", "Failed to notify catalog consumer", "zone", p.origin, "consumer", address, "error", err)
+		}
+	}
+}
+
+func remoteIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// internal/chaos/chaos.go
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected is the storage error injected faults fail lookups with
+var ErrInjected = errors.New("chaos: injected storage error")
+
+// Stage is where in query handling a fault is injected
+type Stage string
+
+// Stages faults can be injected at
+const (
+	StageStorage  Stage = "storage"  // storage lookups, below the caches
+	StageResponse Stage = "response" // responses as they're written to clients
+)
+
+// Kinds of fault
+const (
+	FaultLatency = "latency" // any stage
+	FaultError   = "error"   // storage only
+	FaultDrop    = "drop"    // response only
+)
+
+// fault injects one kind of failure into a share of the calls at a stage
+type fault struct {
+	stage Stage
+	kind  string
+	delay time.Duration // latency only
+	rate  float64
+
+	injected atomic.Int64
+}
+
+// FaultStats counts the injections of one fault
+type FaultStats struct {
+	Fault    string `json:"fault"` // the rule, e.g. "storage:latency=200ms@0.1"
+	Injected int64  `json:"injected"`
+}
+
+// Injector injects artificial latency, errors, and dropped responses for
+// resilience testing. It's meant for test environments only. A nil
+// Injector injects nothing
+type Injector struct {
+	faults []*fault
+}
+
+// NewInjector parses fault rules of the form "stage:fault@rate", with a
+// duration for latency: "storage:latency=200ms@0.1" delays 10% of storage
+// lookups by 200ms, "storage:error@0.05" fails 5% of them, and
+// "response:drop@0.01" never sends 1% of responses
+func NewInjector(rules []string) (*Injector, error) {
+	injector := &Injector{}
+	for _, rule := range rules {
+		f, err := parseFault(rule)
+		if err != nil {
+			return nil, err
+		}
+		injector.faults = append(injector.faults, f)
+	}
+	return injector, nil
+}
+
+// parseFault parses one fault rule
+func parseFault(rule string) (*fault, error) {
+	selector, rate, found := strings.Cut(strings.ToLower(strings.TrimSpace(rule)), "@")
+	if !found {
+		return nil, fmt.Errorf("invalid fault %q: expected stage:fault@rate", rule)
+	}
+	stage, kind, found := strings.Cut(selector, ":")
+	if !found {
+		return nil, fmt.Errorf("invalid fault %q: expected stage:fault@rate", rule)
+	}
+
+	f := &fault{stage: Stage(stage)}
+	var err error
+	if f.rate, err = strconv.ParseFloat(rate, 64); err != nil || f.rate <= 0 || f.rate > 1 {
+		return nil, fmt.Errorf("invalid fault %q: rate must be a fraction above 0 and at most 1", rule)
+	}
+
+	kind, delay, hasDelay := strings.Cut(kind, "=")
+	f.kind = kind
+	switch f.stage {
+	case StageStorage, StageResponse:
+	default:
+		return nil, fmt.Errorf("invalid fault %q: unknown stage %q", rule, stage)
+	}
+	switch {
+	case kind == FaultLatency:
+		if !hasDelay {
+			return nil, fmt.Errorf("invalid fault %q: latency needs a duration, e.g. latency=200ms", rule)
+		}
+		if f.delay, err = time.ParseDuration(delay); err != nil || f.delay <= 0 {
+			return nil, fmt.Errorf("invalid fault %q: invalid latency %q", rule, delay)
+		}
+	case hasDelay:
+		return nil, fmt.Errorf("invalid fault %q: only latency takes a duration", rule)
+	case kind == FaultError && f.stage == StageStorage:
+	case kind == FaultDrop && f.stage == StageResponse:
+	default:
+		return nil, fmt.Errorf("invalid fault %q: %s can't be injected at %s", rule, kind, stage)
+	}
+	return f, nil
+}
+
+// String returns the fault's rule
+func (f *fault) String() string {
+	kind := f.kind
+	if f.kind == FaultLatency {
+		kind += "=" + f.delay.String()
+	}
+	return fmt.Sprintf("%s:%s@%s", f.stage, kind, strconv.FormatFloat(f.rate, 'f', -1, 64))
+}
+
+// fire reports whether the fault strikes this call, counting it if so
+func (f *fault) fire() bool {
+	if rand.Float64() >= f.rate {
+		return false
+	}
+	f.injected.Add(1)
+	return true
+}
+
+// Delay sleeps for the latency faults at stage that strike, returning
+// early with ctx's error if it's done first
+func (i *Injector) Delay(ctx context.Context, stage Stage) error {
+	if i == nil {
+		return nil
+	}
+
+	var delay time.Duration
+	for _, f := range i.faults {
+		if f.stage == stage && f.kind == FaultLatency && f.fire() {
+			delay += f.delay
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Fail returns ErrInjected when an error fault at stage strikes
+func (i *Injector) Fail(stage Stage) error {
+	if i == nil {
+		return nil
+	}
+	for _, f := range i.faults {
+		if f.stage == stage && f.kind == FaultError && f.fire() {
+			return ErrInjected
+		}
+	}
+	return nil
+}
+
+// Drop reports whether a drop fault at stage strikes
+func (i *Injector) Drop(stage Stage) bool {
+	if i == nil {
+		return false
+	}
+	for _, f := range i.faults {
+		if f.stage == stage && f.kind == FaultDrop && f.fire() {
+			return true
+		}
+	}
+	return false
+}
+
+// Injects reports whether any fault is configured at stage
+func (i *Injector) Injects(stage Stage) bool {
+	if i == nil {
+		return false
+	}
+	for _, f := range i.faults {
+		if f.stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns how often each fault was injected
+func (i *Injector) Stats() []FaultStats {
+	if i == nil {
+		return nil
+	}
+	stats := make([]FaultStats, len(i.faults))
+	for n, f := range i.faults {
+		stats[n] = FaultStats{Fault: f.String(), Injected: f.injected.Load()}
+	}
+	return stats
+}
@@ -0,0 +1,114 @@
+// internal/chaos/storage.go
+package chaos
+
+import (
+	"context"
+
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/storage"
+)
+
+// Storage injects the storage stage's faults into lookups before passing
+// them to the backend. Management operations and writes always go to the
+// backend untouched
+type Storage struct {
+	next     storage.Storage
+	injector *Injector
+}
+
+// NewStorage wraps next with injector's storage faults
+func NewStorage(next storage.Storage, injector *Injector) *Storage {
+	return &Storage{next: next, injector: injector}
+}
+
+// inject delays the lookup and fails it as the faults strike
+func (s *Storage) inject(ctx context.Context) error {
+	if err := s.injector.Delay(ctx, StageStorage); err != nil {
+		return err
+	}
+	return s.injector.Fail(StageStorage)
+}
+
+// LookupRecord finds a single record, unless a fault strikes
+func (s *Storage) LookupRecord(ctx context.Context, query *models.LookupQuery) (*models.DNSRecord, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.next.LookupRecord(ctx, query)
+}
+
+// LookupRecords finds all matching records, unless a fault strikes
+func (s *Storage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.next.LookupRecords(ctx, query)
+}
+
+// LookupRecordGroup finds the lowest priority group, unless a fault strikes
+func (s *Storage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	if err := s.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.next.LookupRecordGroup(ctx, query)
+}
+
+// GetRecordByID fetches a record from the backend
+func (s *Storage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	return s.next.GetRecordByID(ctx, id)
+}
+
+// CreateRecord creates a record in the backend
+func (s *Storage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return s.next.CreateRecord(ctx, record)
+}
+
+// UpdateRecord updates a record in the backend
+func (s *Storage) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return s.next.UpdateRecord(ctx, record)
+}
+
+// UpsertRecord creates or updates a record in the backend
+func (s *Storage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	return s.next.UpsertRecord(ctx, record)
+}
+
+// CreateRecords creates records in the backend in one transaction
+func (s *Storage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	return s.next.CreateRecords(ctx, records)
+}
+
+// DeleteRecord deletes a record from the backend
+func (s *Storage) DeleteRecord(ctx context.Context, id int) error {
+	return s.next.DeleteRecord(ctx, id)
+}
+
+// DeleteRecords deletes records from the backend
+func (s *Storage) DeleteRecords(ctx context.Context, name string, recordType string) error {
+	return s.next.DeleteRecords(ctx, name, recordType)
+}
+
+// ListRecords lists records from the backend
+func (s *Storage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	return s.next.ListRecords(ctx, filter)
+}
+
+// SearchRecords searches records in the backend
+func (s *Storage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	return s.next.SearchRecords(ctx, search)
+}
+
+// CountRecords counts records in the backend
+func (s *Storage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	return s.next.CountRecords(ctx, filter)
+}
+
+// Health checks the backend's health
+func (s *Storage) Health(ctx context.Context) error {
+	return s.next.Health(ctx)
+}
+
+// Close closes the backend
+func (s *Storage) Close() error {
+	return s.next.Close()
+}
@@ -0,0 +1,130 @@
+// internal/dns/answerlimit.go
+package dns
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// AnswerLimitPolicy caps the records answered per RRset by zone, so huge
+// RRsets are answered with a random sample of their members that fits in
+// a UDP response
+type AnswerLimitPolicy struct {
+	rules []answerLimitRule
+}
+
+// answerLimitRule caps RRsets owned by names matching zone at limit
+// records
+type answerLimitRule struct {
+	zone       string
+	subdomains bool // "*.zone": names below zone but not zone itself
+	limit      int
+}
+
+// NewAnswerLimitPolicy parses rules of the form "zone=N", e.g.
+// "example.com=8" or "*.pool.example.net=4". A zone covers its subdomains;
+// "*.zone" covers only the subdomains. The rule for the most specific
+// matching zone wins
+func NewAnswerLimitPolicy(rules []string) (*AnswerLimitPolicy, error) {
+	policy := &AnswerLimitPolicy{}
+	for _, rule := range rules {
+		zone, value, found := strings.Cut(rule, "=")
+		zone = strings.ToLower(strings.TrimSpace(zone))
+		value = strings.TrimSpace(value)
+		if !found || zone == "" || value == "" {
+			return nil, fmt.Errorf("invalid answer limit %q: expected zone=N", rule)
+		}
+
+		var parsed answerLimitRule
+		if rest, ok := strings.CutPrefix(zone, "*."); ok {
+			zone, parsed.subdomains = rest, true
+		}
+		if _, ok := dns.IsDomainName(zone); !ok {
+			return nil, fmt.Errorf("invalid answer limit %q: invalid zone %q", rule, zone)
+		}
+		parsed.zone = dns.Fqdn(zone)
+
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid answer limit %q: limit must be a positive number of records", rule)
+		}
+		parsed.limit = limit
+
+		policy.rules = append(policy.rules, parsed)
+	}
+	return policy, nil
+}
+
+// limit returns the record limit for RRsets owned by name, or 0 if no rule
+// matches
+func (p *AnswerLimitPolicy) limit(name string) int {
+	name = strings.ToLower(dns.Fqdn(name))
+	limit, depth := 0, -1
+	for _, rule := range p.rules {
+		if !dns.IsSubDomain(rule.zone, name) {
+			continue
+		}
+		if rule.subdomains && dns.CountLabel(name) == dns.CountLabel(rule.zone) {
+			continue
+		}
+		// A wildcard rule is more specific than its zone's own rule
+		labels := 2 * dns.CountLabel(rule.zone)
+		if rule.subdomains {
+			labels++
+		}
+		if labels > depth {
+			limit, depth = rule.limit, labels
+		}
+	}
+	return limit
+}
+
+// Apply trims each RRset in rrs over its zone's limit to a random sample of
+// its records, keeping the order of the rest. It reports whether anything
+// was trimmed
+func (p *AnswerLimitPolicy) Apply(rrs []dns.RR) ([]dns.RR, bool) {
+	if p == nil || len(p.rules) == 0 || len(rrs) < 2 {
+		return rrs, false
+	}
+
+	type rrsetKey struct {
+		name  string
+		rtype uint16
+	}
+	members := make(map[rrsetKey][]int)
+	for i, rr := range rrs {
+		hdr := rr.Header()
+		key := rrsetKey{strings.ToLower(hdr.Name), hdr.Rrtype}
+		members[key] = append(members[key], i)
+	}
+
+	var drop map[int]bool
+	for key, indexes := range members {
+		limit := p.limit(key.name)
+		if limit == 0 || len(indexes) <= limit {
+			continue
+		}
+		if drop == nil {
+			drop = make(map[int]bool)
+		}
+		rand.Shuffle(len(indexes), func(i, j int) { indexes[i], indexes[j] = indexes[j], indexes[i] })
+		for _, i := range indexes[limit:] {
+			drop[i] = true
+		}
+	}
+	if drop == nil {
+		return rrs, false
+	}
+
+	kept := rrs[:0]
+	for i, rr := range rrs {
+		if !drop[i] {
+			kept = append(kept, rr)
+		}
+	}
+	return kept, true
+}
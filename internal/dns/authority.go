@@ -0,0 +1,102 @@
+// internal/dns/authority.go
+package dns
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ZoneAuthority tracks the zones the server holds: those with a stored SOA
+// record. Names inside them are answered by us alone, never forwarded.
+// Answers for names outside them don't set AA unless claimAll is set
+type ZoneAuthority struct {
+	lister   RecordLister
+	refresh  time.Duration
+	claimAll bool
+	zones    atomic.Pointer[map[string]struct{}]
+}
+
+// NewZoneAuthority creates a tracker that reloads zones from lister every
+// refresh interval once Run is called. With claimAll, every answer claims
+// authority, whether or not its name is in a zone we hold
+func NewZoneAuthority(lister RecordLister, refresh time.Duration, claimAll bool) *ZoneAuthority {
+	a := &ZoneAuthority{lister: lister, refresh: refresh, claimAll: claimAll}
+	a.zones.Store(&map[string]struct{}{})
+	return a
+}
+
+// Load reloads the zones from their SOA records, returning how many there are
+func (a *ZoneAuthority) Load(ctx context.Context) (int, error) {
+	records, err := a.lister.ListRecords(ctx, &models.RecordFilter{RecordType: string(models.RecordTypeSOA)})
+	if err != nil {
+		return 0, err
+	}
+
+	zones := make(map[string]struct{}, len(records))
+	for _, record := range records {
+		zones[models.NormalizeDomainName(record.Name)] = struct{}{}
+	}
+	a.zones.Store(&zones)
+	return len(zones), nil
+}
+
+// Run reloads the zones every refresh interval until ctx is cancelled,
+// keeping the last zones loaded when a reload fails
+func (a *ZoneAuthority) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := a.Load(ctx); err != nil && ctx.Err() == nil {
+				logging.Warn("dns", "Failed to reload authoritative zones", "error", err)
+			}
+		}
+	}
+}
+
+// Authoritative reports whether answers for name claim authority: when
+// it's at or under a zone we hold, or always with claimAll. A nil tracker
+// treats every name as authoritative
+func (a *ZoneAuthority) Authoritative(name string) bool {
+	if a == nil || a.claimAll {
+		return true
+	}
+	return a.Holds(name)
+}
+
+// Holds reports whether name is at or under a zone we hold. A nil tracker
+// holds no zones
+func (a *ZoneAuthority) Holds(name string) bool {
+	_, ok := a.Zone(name)
+	return ok
+}
+
+// Zone returns the closest zone we hold that name is at or under, or
+// reports false if there is none
+func (a *ZoneAuthority) Zone(name string) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+
+	zones := *a.zones.Load()
+	name = models.NormalizeDomainName(name)
+	for {
+		if _, ok := zones[name]; ok {
+			return name, true
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			return "", false
+		}
+		name = name[dot+1:]
+	}
+}
@@ -0,0 +1,166 @@
+// internal/dns/chain.go
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/blocklist"
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/policy"
+	"errantdns.io/internal/storage"
+)
+
+// Request is one query on its way through the middleware chain. Middleware
+// may replace its context, writer, or policy fields for the stages after it
+type Request struct {
+	Ctx    context.Context
+	W      dns.ResponseWriter
+	Msg    *dns.Msg
+	Client net.IP
+
+	// Group is the client's policy group, if any. Blocker and Forwarder
+	// start as the server's and are replaced by the group's; a nil Blocker
+	// blocks nothing
+	Group     *policy.Group
+	Blocker   *blocklist.View
+	Forwarder *forwarder.Forwarder
+
+	// Source is the tier the answer came from, set once it's answered from
+	// one, for per-tier latency
+	Source storage.CacheSource
+}
+
+// Handler answers a request
+type Handler func(req *Request)
+
+// Middleware wraps the rest of the chain. It either answers the request
+// itself or passes it on by calling next
+type Middleware func(next Handler) Handler
+
+// MiddlewareFactory builds a middleware for a server. It returns nil when
+// the feature the middleware implements isn't configured, leaving it out of
+// the chain entirely
+type MiddlewareFactory func(s *Server) Middleware
+
+// Names of the built-in middleware
+const (
+	MiddlewareLog       = "log"
+	MiddlewareChaos     = "chaos"
+	MiddlewareRateLimit = "ratelimit"
+	MiddlewareMirror    = "mirror"
+	MiddlewareDedup     = "dedup"
+	MiddlewareTransfer  = "transfer"
+	MiddlewarePolicy    = "policy"
+	MiddlewareBlocklist = "blocklist"
+	MiddlewarePlugin    = "plugin"
+	MiddlewareCache     = "cache"
+)
+
+// DefaultChain is the order middleware runs in when none is configured.
+// Every chain ends by resolving the query from hosts files, storage, and
+// the forwarder
+var DefaultChain = []string{
+	MiddlewareLog,
+	MiddlewareChaos,
+	MiddlewareRateLimit,
+	MiddlewareMirror,
+	MiddlewareDedup,
+	MiddlewareTransfer,
+	MiddlewarePolicy,
+	MiddlewareBlocklist,
+	MiddlewarePlugin,
+	MiddlewareCache,
+}
+
+var (
+	middlewareMu sync.RWMutex
+	middleware   = map[string]MiddlewareFactory{
+		MiddlewareLog:       (*Server).logMiddleware,
+		MiddlewareChaos:     (*Server).chaosMiddleware,
+		MiddlewareRateLimit: (*Server).rateLimitMiddleware,
+		MiddlewareMirror:    (*Server).mirrorMiddleware,
+		MiddlewareDedup:     (*Server).dedupMiddleware,
+		MiddlewareTransfer:  (*Server).transferMiddleware,
+		MiddlewarePolicy:    (*Server).policyMiddleware,
+		MiddlewareBlocklist: (*Server).blocklistMiddleware,
+		MiddlewarePlugin:    (*Server).pluginMiddleware,
+		MiddlewareCache:     (*Server).cacheMiddleware,
+	}
+)
+
+// RegisterMiddleware makes a middleware available by name, replacing any
+// registered under it. Call it before the chain is built
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	middleware[name] = factory
+}
+
+// MiddlewareNames returns the names of all registered middleware, sorted
+func MiddlewareNames() []string {
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+
+	names := make([]string, 0, len(middleware))
+	for name := range middleware {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Chain is an ordered list of registered middleware
+type Chain struct {
+	names     []string
+	factories []MiddlewareFactory
+}
+
+// NewChain looks up the named middleware in order, outermost first. An
+// empty list uses DefaultChain
+func NewChain(names []string) (*Chain, error) {
+	if len(names) == 0 {
+		names = DefaultChain
+	}
+
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+
+	c := &Chain{}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		factory, ok := middleware[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("middleware %q is listed more than once", name)
+		}
+		seen[name] = true
+		c.names = append(c.names, name)
+		c.factories = append(c.factories, factory)
+	}
+	return c, nil
+}
+
+// Names returns the chain's middleware in order
+func (c *Chain) Names() []string {
+	return append([]string{}, c.names...)
+}
+
+// build wraps final in the chain's middleware for s, skipping those that
+// aren't configured
+func (c *Chain) build(s *Server, final Handler) Handler {
+	handler := final
+	for i := len(c.factories) - 1; i >= 0; i-- {
+		if m := c.factories[i](s); m != nil {
+			handler = m(handler)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,151 @@
+// internal/dns/dedup.go
+package dns
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+)
+
+// dedupKey identifies a UDP query and its retransmissions: a client
+// resending a query it got no answer to keeps the same ID and question
+type dedupKey struct {
+	client string
+	id     uint16
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+// inflight is one query being resolved, or resolved within the window.
+// done closes once response holds what was written, nil if the query was
+// dropped
+type inflight struct {
+	done     chan struct{}
+	response []byte
+	expires  time.Time
+}
+
+// dedupTable tracks the UDP queries in flight so retransmissions are
+// answered from the original resolution instead of resolving again.
+// Finished queries stay for window, catching retransmissions of answers
+// lost on the way back
+type dedupTable struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	entries   map[dedupKey]*inflight
+	lastSweep time.Time
+}
+
+func newDedupTable(window time.Duration) *dedupTable {
+	return &dedupTable{window: window, entries: make(map[dedupKey]*inflight)}
+}
+
+// begin returns the entry for key and whether the caller is the original,
+// which must resolve the query and call finish
+func (t *dedupTable) begin(key dedupKey) (*inflight, bool) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.lastSweep) >= t.window {
+		t.sweepUnlocked(now)
+	}
+	if entry, ok := t.entries[key]; ok && (entry.expires.IsZero() || now.Before(entry.expires)) {
+		return entry, false
+	}
+	entry := &inflight{done: make(chan struct{})}
+	t.entries[key] = entry
+	return entry, true
+}
+
+// finish records the original's response and wakes its duplicates
+func (t *dedupTable) finish(entry *inflight, response []byte) {
+	t.mu.Lock()
+	entry.response = response
+	entry.expires = time.Now().Add(t.window)
+	t.mu.Unlock()
+	close(entry.done)
+}
+
+// sweepUnlocked drops the finished entries past their window
+func (t *dedupTable) sweepUnlocked(now time.Time) {
+	for key, entry := range t.entries {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			delete(t.entries, key)
+		}
+	}
+	t.lastSweep = now
+}
+
+// dedupMiddleware answers UDP retransmissions of a query still being
+// resolved, or resolved moments ago, with the original's response
+func (s *Server) dedupMiddleware() Middleware {
+	if s.dedup == nil {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if _, isUDP := req.W.RemoteAddr().(*net.UDPAddr); !isUDP {
+				next(req)
+				return
+			}
+
+			question := req.Msg.Question[0]
+			key := dedupKey{
+				client: req.Client.String(),
+				id:     req.Msg.Id,
+				name:   strings.ToLower(question.Name),
+				qtype:  question.Qtype,
+				qclass: question.Qclass,
+			}
+
+			entry, original := s.dedup.begin(key)
+			if original {
+				writer := &captureWriter{ResponseWriter: req.W}
+				req.W = writer
+				defer func() { s.dedup.finish(entry, writer.response) }()
+				next(req)
+				return
+			}
+
+			atomic.AddInt64(&s.stats.QueriesDuplicate, 1)
+			select {
+			case <-entry.done:
+			case <-req.Ctx.Done():
+				return
+			}
+			if entry.response == nil {
+				return
+			}
+			if _, err := req.W.Write(entry.response); err != nil {
+				logging.ErrorContext(req.Ctx, "dns", "Failed to write duplicate response", err, "domain", question.Name)
+			}
+		}
+	}
+}
+
+// captureWriter keeps a copy of the response written through it
+type captureWriter struct {
+	dns.ResponseWriter
+	response []byte
+}
+
+// WriteMsg packs msg and writes it through Write
+func (w *captureWriter) WriteMsg(msg *dns.Msg) error {
+	return writeMsg(w, msg)
+}
+
+// Write copies the packed response and writes it
+func (w *captureWriter) Write(packed []byte) (int, error) {
+	w.response = append([]byte(nil), packed...)
+	return w.ResponseWriter.Write(packed)
+}
@@ -0,0 +1,63 @@
+// internal/dns/ede.go
+package dns
+
+import (
+	"context"
+	"errors"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/storage"
+)
+
+// Extended DNS Error (RFC 8914) texts explaining why a query wasn't
+// answered normally
+const (
+	edeTextBlocked          = "Blocked by policy"
+	edeTextBackendDown      = "Backend unreachable"
+	edeTextBackendTimeout   = "Backend timed out"
+	edeTextCacheOnly        = "Serving from cache only"
+	edeTextStale            = "Stale answer"
+	edeTextNotAuthoritative = "Not authoritative for name"
+	edeTextNoRecursion      = "Recursion not allowed"
+	edeTextForwardFailed    = "Forwarder unreachable"
+	edeTextForwardTimeout   = "Forwarder timed out"
+	edeTextNoTransfer       = "Zone transfer not allowed"
+	edeTextPluginFailed     = "Query plugin failed"
+)
+
+// extendedError describes a failure to answer a query
+type extendedError struct {
+	code uint16
+	text string
+}
+
+// lookupError returns the extended error for a storage lookup that failed
+// with err
+func lookupError(ctx context.Context, err error) *extendedError {
+	switch {
+	case errors.Is(err, storage.ErrCacheOnly):
+		return &extendedError{dns.ExtendedErrorCodeNotReady, edeTextCacheOnly}
+	case ctx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded):
+		return &extendedError{dns.ExtendedErrorCodeNoReachableAuthority, edeTextBackendTimeout}
+	default:
+		return &extendedError{dns.ExtendedErrorCodeNoReachableAuthority, edeTextBackendDown}
+	}
+}
+
+// setExtendedError attaches ede to msg, the response to r, in its OPT
+// record, adding one when msg has none. Clients that didn't send EDNS
+// can't be sent options, so their responses are left alone
+func setExtendedError(msg, r *dns.Msg, ede *extendedError) {
+	if ede == nil || r.IsEdns0() == nil {
+		return
+	}
+
+	opt := msg.IsEdns0()
+	if opt == nil {
+		opt = &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+		opt.SetUDPSize(dns.DefaultMsgSize)
+		msg.Extra = append(msg.Extra, opt)
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_EDE{InfoCode: ede.code, ExtraText: ede.text})
+}
@@ -0,0 +1,283 @@
+// internal/dns/middleware.go
+package dns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/chaos"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/plugin"
+	"errantdns.io/internal/stats"
+)
+
+// logMiddleware writes every query, with the rcode it was answered with,
+// to the sampled query log
+func (s *Server) logMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			start := time.Now()
+			writer := &rcodeWriter{ResponseWriter: req.W, rcode: -1}
+			req.W = writer
+			next(req)
+
+			result := "dropped"
+			if writer.rcode >= 0 {
+				result = dns.RcodeToString[writer.rcode]
+			}
+			question := req.Msg.Question[0]
+			logging.LogQuery(question.Name, dns.TypeToString[question.Qtype], result,
+				req.Client.String(), time.Since(start))
+		}
+	}
+}
+
+// chaosMiddleware delays and drops responses as the injector's response
+// faults strike, so clients and monitoring can be tested against a
+// misbehaving server
+func (s *Server) chaosMiddleware() Middleware {
+	if !s.chaos.Injects(chaos.StageResponse) {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			req.W = &chaosWriter{ResponseWriter: req.W, ctx: req.Ctx, injector: s.chaos}
+			next(req)
+		}
+	}
+}
+
+// mirrorMiddleware sends a sample of queries, with the answers we gave
+// them, to the shadow server for comparison once they're answered
+func (s *Server) mirrorMiddleware() Middleware {
+	if s.mirror == nil {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if !s.mirror.Sample(req.Msg) {
+				next(req)
+				return
+			}
+			writer := &answerWriter{ResponseWriter: req.W}
+			req.W = writer
+			next(req)
+			if writer.answer != nil {
+				s.mirror.Submit(req.Msg, writer.answer)
+			}
+		}
+	}
+}
+
+// rateLimitMiddleware drops or truncates queries from sources over their
+// rate limit before any resolution work
+func (s *Server) rateLimitMiddleware() Middleware {
+	if s.limiter == nil {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if !s.limiter.Allow(req.Client) {
+				s.rejectRateLimited(req.W, req.Msg)
+				return
+			}
+			next(req)
+		}
+	}
+}
+
+// transferMiddleware serves zone transfers, which stream many messages and
+// bypass normal resolution
+func (s *Server) transferMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if isTransfer(req.Msg) {
+				s.handleTransfer(req.W, req.Msg, req.Client)
+				return
+			}
+			next(req)
+		}
+	}
+}
+
+// policyMiddleware looks up the client's policy group once, giving the
+// rest of the chain the group's blocklist, forwarder, and log level
+func (s *Server) policyMiddleware() Middleware {
+	if s.groups.Len() == 0 {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if group := s.groups.Match(req.Client); group != nil {
+				req.Group = group
+				req.Blocker, req.Forwarder = s.policyFor(group)
+				if group.LogLevel != "" {
+					req.Ctx = logging.WithLevel(req.Ctx, group.LogLevel)
+				}
+			}
+			next(req)
+		}
+	}
+}
+
+// blocklistMiddleware answers queries for blocked names, which never reach
+// the caches or storage
+func (s *Server) blocklistMiddleware() Middleware {
+	if s.blocklist == nil {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if req.Blocker != nil && req.Blocker.Blocked(req.Msg.Question[0].Name) {
+				s.answerBlocked(req)
+				return
+			}
+			next(req)
+		}
+	}
+}
+
+// pluginMiddleware lets the query plugins answer or drop queries. When a
+// plugin fails and plugins don't fail open, the query gets SERVFAIL
+func (s *Server) pluginMiddleware() Middleware {
+	if !s.plugins.HasQueryHooks() {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			transport := "udp"
+			if _, isTCP := req.W.RemoteAddr().(*net.TCPAddr); isTCP {
+				transport = "tcp"
+			}
+			group := ""
+			if req.Group != nil {
+				group = req.Group.Name
+			}
+
+			action, reply, err := s.plugins.Query(req.Ctx, req.Msg, req.Client, transport, group)
+			switch {
+			case err != nil:
+				logging.ErrorContext(req.Ctx, "dns", "Query plugin failed", err, "domain", req.Msg.Question[0].Name)
+				s.writeRcode(req.W, req.Msg, dns.RcodeServerFailure, &extendedError{dns.ExtendedErrorCodeOther, edeTextPluginFailed})
+				atomic.AddInt64(&s.stats.QueriesError, 1)
+			case action == plugin.ActionDrop:
+				atomic.AddInt64(&s.stats.QueriesPlugin, 1)
+			case action == plugin.ActionAnswer:
+				if err := writeMsg(req.W, reply); err != nil {
+					logging.ErrorContext(req.Ctx, "dns", "Failed to write plugin response", err)
+				}
+				atomic.AddInt64(&s.stats.QueriesPlugin, 1)
+				s.recordOutcome(req.Msg, req.Client, pluginOutcome(reply))
+			default:
+				next(req)
+			}
+		}
+	}
+}
+
+// pluginOutcome classifies a plugin's reply for the query counters
+func pluginOutcome(reply *dns.Msg) stats.Outcome {
+	switch {
+	case reply.Rcode == dns.RcodeSuccess && len(reply.Answer) > 0:
+		return stats.OutcomeAnswered
+	case reply.Rcode == dns.RcodeSuccess || reply.Rcode == dns.RcodeNameError:
+		return stats.OutcomeNXDomain
+	default:
+		return stats.OutcomeError
+	}
+}
+
+// cacheMiddleware answers hot queries straight from the packed-response
+// cache
+func (s *Server) cacheMiddleware() Middleware {
+	if s.wireCache == nil {
+		return nil
+	}
+	return func(next Handler) Handler {
+		return func(req *Request) {
+			if s.answerFromWireCache(req) {
+				return
+			}
+			next(req)
+		}
+	}
+}
+
+// rcodeWriter records the rcode of the response written through it, or
+// -1 if nothing was written
+type rcodeWriter struct {
+	dns.ResponseWriter
+	rcode int
+}
+
+// WriteMsg records msg's rcode and writes it
+func (w *rcodeWriter) WriteMsg(msg *dns.Msg) error {
+	w.rcode = msg.Rcode
+	return w.ResponseWriter.WriteMsg(msg)
+}
+
+// Write records the rcode of a packed message and writes it
+func (w *rcodeWriter) Write(packed []byte) (int, error) {
+	if len(packed) >= dnsHeaderSize {
+		w.rcode = int(packed[3] & 0x0f)
+	}
+	return w.ResponseWriter.Write(packed)
+}
+
+// answerWriter keeps the response written through it, or nil if nothing
+// was written or it couldn't be unpacked
+type answerWriter struct {
+	dns.ResponseWriter
+	answer *dns.Msg
+}
+
+// WriteMsg keeps msg and writes it
+func (w *answerWriter) WriteMsg(msg *dns.Msg) error {
+	w.answer = msg
+	return w.ResponseWriter.WriteMsg(msg)
+}
+
+// Write keeps the unpacked message and writes it
+func (w *answerWriter) Write(packed []byte) (int, error) {
+	answer := new(dns.Msg)
+	if answer.Unpack(packed) == nil {
+		w.answer = answer
+	}
+	return w.ResponseWriter.Write(packed)
+}
+
+// chaosWriter delays responses written through it, or discards them as if
+// they were lost on the way to the client
+type chaosWriter struct {
+	dns.ResponseWriter
+	ctx      context.Context
+	injector *chaos.Injector
+}
+
+// WriteMsg writes msg unless it's dropped
+func (w *chaosWriter) WriteMsg(msg *dns.Msg) error {
+	if w.inject() {
+		return nil
+	}
+	return w.ResponseWriter.WriteMsg(msg)
+}
+
+// Write writes a packed message unless it's dropped
+func (w *chaosWriter) Write(packed []byte) (int, error) {
+	if w.inject() {
+		return len(packed), nil
+	}
+	return w.ResponseWriter.Write(packed)
+}
+
+// inject delays the response and reports whether it should be dropped
+func (w *chaosWriter) inject() bool {
+	if w.injector.Delay(w.ctx, chaos.StageResponse) != nil {
+		return true
+	}
+	return w.injector.Drop(chaos.StageResponse)
+}
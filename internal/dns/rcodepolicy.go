@@ -0,0 +1,151 @@
+// internal/dns/rcodepolicy.go
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ErrorClass is a kind of failure to answer a query from storage
+type ErrorClass string
+
+// Error classes an RcodePolicy maps to responses
+const (
+	ClassError    ErrorClass = "error"    // storage lookup failed
+	ClassTimeout  ErrorClass = "timeout"  // storage lookup ran out of time
+	ClassNotFound ErrorClass = "notfound" // no record matched the name
+)
+
+// Actions an RcodePolicy can take for an error class
+const (
+	ActionServfail = "servfail"
+	ActionRefused  = "refused"
+	ActionNXDomain = "nxdomain" // notfound only
+	ActionStale    = "stale"    // error and timeout only; needs the circuit breaker
+)
+
+// RcodePolicy picks the response for queries that fail or find nothing,
+// per zone. Without a matching rule failures get SERVFAIL, stale answers
+// are served where the circuit breaker has them, and unknown names get
+// NXDOMAIN
+type RcodePolicy struct {
+	rules []rcodeRule
+}
+
+// rcodeRule applies action to class for names at or under zone ("" for any)
+type rcodeRule struct {
+	zone   string
+	class  ErrorClass
+	action string
+}
+
+// NewRcodePolicy parses rules of the form "class=action" or
+// "zone:class=action", e.g. "notfound=refused" or "example.com:timeout=stale".
+// The rule for the longest matching zone wins
+func NewRcodePolicy(rules []string) (*RcodePolicy, error) {
+	policy := &RcodePolicy{}
+	for _, rule := range rules {
+		selector, action, found := strings.Cut(rule, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid rcode rule %q: expected class=action", rule)
+		}
+
+		zone, class, found := strings.Cut(selector, ":")
+		if !found {
+			zone, class = "", selector
+		} else if zone == "" {
+			return nil, fmt.Errorf("invalid rcode rule %q: empty zone", rule)
+		}
+
+		parsed := rcodeRule{class: ErrorClass(strings.ToLower(class)), action: strings.ToLower(action)}
+		switch parsed.class {
+		case ClassError, ClassTimeout:
+			if parsed.action != ActionServfail && parsed.action != ActionRefused && parsed.action != ActionStale {
+				return nil, fmt.Errorf("invalid rcode rule %q: %s must be servfail, refused, or stale", rule, parsed.class)
+			}
+		case ClassNotFound:
+			if parsed.action != ActionNXDomain && parsed.action != ActionRefused {
+				return nil, fmt.Errorf("invalid rcode rule %q: notfound must be nxdomain or refused", rule)
+			}
+		default:
+			return nil, fmt.Errorf("invalid rcode rule %q: unknown class %q", rule, class)
+		}
+
+		if zone != "" {
+			parsed.zone = dns.Fqdn(strings.ToLower(zone))
+		}
+		policy.rules = append(policy.rules, parsed)
+	}
+	return policy, nil
+}
+
+// action returns the action of the most specific rule for name and class,
+// or "" if none matches
+func (p *RcodePolicy) action(name string, class ErrorClass) string {
+	if p == nil {
+		return ""
+	}
+
+	name = strings.ToLower(dns.Fqdn(name))
+	action, depth := "", -1
+	for _, rule := range p.rules {
+		if rule.class != class {
+			continue
+		}
+		if rule.zone != "" && !dns.IsSubDomain(rule.zone, name) {
+			continue
+		}
+		if labels := dns.CountLabel(rule.zone); labels > depth {
+			action, depth = rule.action, labels
+		}
+	}
+	return action
+}
+
+// Failure returns the rcode for a query for name whose lookup failed with err
+func (p *RcodePolicy) Failure(name string, err error) int {
+	if p.action(name, errorClass(err)) == ActionRefused {
+		return dns.RcodeRefused
+	}
+	return dns.RcodeServerFailure
+}
+
+// NotFound returns the rcode for a query for name that matched no record
+func (p *RcodePolicy) NotFound(name string) int {
+	if p.action(name, ClassNotFound) == ActionRefused {
+		return dns.RcodeRefused
+	}
+	return dns.RcodeNameError
+}
+
+// ServeStale reports whether a query for name whose lookup failed with err
+// may be answered with a stale answer. Failures without a matching rule may
+func (p *RcodePolicy) ServeStale(name string, err error) bool {
+	action := p.action(name, errorClass(err))
+	return action == "" || action == ActionStale
+}
+
+// UsesStale reports whether any rule asks for stale answers
+func (p *RcodePolicy) UsesStale() bool {
+	if p == nil {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.action == ActionStale {
+			return true
+		}
+	}
+	return false
+}
+
+// errorClass classifies a lookup error
+func errorClass(err error) ErrorClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	return ClassError
+}
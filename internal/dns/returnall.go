@@ -0,0 +1,66 @@
+// internal/dns/returnall.go
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ReturnAllPolicy decides which queries are answered with the whole lowest
+// priority group instead of the one record chosen by tie-breaking
+type ReturnAllPolicy struct {
+	rules []returnAllRule
+}
+
+// returnAllRule matches queries of qtype (0 for any type) for names at or
+// under zone ("" for any zone)
+type returnAllRule struct {
+	zone  string
+	qtype uint16
+}
+
+// NewReturnAllPolicy parses rules of the form "TYPE", "zone:TYPE", or
+// "zone:*", e.g. "AAAA" or "example.com:A"
+func NewReturnAllPolicy(rules []string) (*ReturnAllPolicy, error) {
+	policy := &ReturnAllPolicy{}
+	for _, rule := range rules {
+		zone, typeName, found := strings.Cut(rule, ":")
+		if !found {
+			zone, typeName = "", rule
+		} else if zone == "" {
+			return nil, fmt.Errorf("invalid return-all rule %q: empty zone", rule)
+		}
+
+		var qtype uint16
+		if typeName != "*" || zone == "" {
+			var ok bool
+			if qtype, ok = dns.StringToType[strings.ToUpper(typeName)]; !ok {
+				return nil, fmt.Errorf("invalid return-all rule %q: unknown record type %q", rule, typeName)
+			}
+		}
+
+		if zone != "" {
+			zone = dns.Fqdn(strings.ToLower(zone))
+		}
+		policy.rules = append(policy.rules, returnAllRule{zone: zone, qtype: qtype})
+	}
+	return policy, nil
+}
+
+// Matches reports whether a query for name and qtype gets the whole group
+func (p *ReturnAllPolicy) Matches(name string, qtype uint16) bool {
+	if p == nil {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.qtype != 0 && rule.qtype != qtype {
+			continue
+		}
+		if rule.zone == "" || dns.IsSubDomain(rule.zone, strings.ToLower(dns.Fqdn(name))) {
+			return true
+		}
+	}
+	return false
+}
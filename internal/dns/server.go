@@ -3,17 +3,37 @@ package dns
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"math"
+	"math/rand"
 	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
 
+	"errantdns.io/internal/analysis"
+	"errantdns.io/internal/blocklist"
+	"errantdns.io/internal/cache"
+	"errantdns.io/internal/chaos"
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/hosts"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/mirror"
 	"errantdns.io/internal/models"
+	"errantdns.io/internal/plugin"
+	"errantdns.io/internal/policy"
+	"errantdns.io/internal/ratelimit"
 	"errantdns.io/internal/resolver"
+	"errantdns.io/internal/reverse"
+	"errantdns.io/internal/stats"
 	"errantdns.io/internal/storage"
-	"errantdns.io/internal/logging"
 )
 
 // Server represents a DNS server instance
@@ -22,30 +42,88 @@ type Server struct {
 	udpServer *dns.Server
 	tcpServer *dns.Server
 	port      string
+	ttlJitter float64
+	ttls      *TTLPolicy
+
+	// answerLimits samples oversized RRsets; maxResponseSize caps UDP
+	// responses below the client's advertised size, 0 for no cap
+	answerLimits    *AnswerLimitPolicy
+	maxResponseSize int
+
+	// queryTimeout bounds the work done for one request
+	queryTimeout time.Duration
 
 	// Server statistics
-	stats Stats
+	stats      Stats
+	latency    *stats.LatencyWindow
+	tiers      map[storage.CacheSource]*stats.LatencyHistogram // latency by answering tier
+	queryCount *stats.QueryCounter
+	analytics  *stats.QueryAnalytics
+	nxAnalyzer *analysis.NXDomainAnalyzer
+	limiter    *ratelimit.Limiter
+	forwarder  *forwarder.Forwarder
+	wireCache  *cache.WireCache
+	transfer   *ZoneTransfer
+	reverse    *reverse.Synthesizer
+	hosts      *hosts.Table
+	blocklist  *blocklist.Manager
+	groups     *policy.Groups
+	plugins    *plugin.Manager
+	returnAll  *ReturnAllPolicy
+	rcodes     *RcodePolicy
+	authority  *ZoneAuthority
+	dedup      *dedupTable
+	chaos      *chaos.Injector
+	mirror     *mirror.Mirror
+
+	// handler is the middleware chain every query runs through
+	handler Handler
 }
 
 // Stats holds DNS server statistics
 type Stats struct {
-	QueriesReceived int64
-	QueriesAnswered int64
-	QueriesNXDomain int64
-	QueriesError    int64
+	QueriesReceived int64 `json:"queries_received"`
+	QueriesAnswered int64 `json:"queries_answered"`
+	QueriesNXDomain int64 `json:"queries_nxdomain"`
+	QueriesError    int64 `json:"queries_error"`
 
 	// Query type breakdown
-	TypeA     int64
-	TypeAAAA  int64
-	TypeCNAME int64
-	TypeMX    int64
-	TypeTXT   int64
-	TypeNS    int64
-	TypeSRV   int64
-	TypeSOA   int64
-	TypePTR   int64
-	TypeCAA   int64
-	TypeOther int64
+	TypeA     int64 `json:"type_a"`
+	TypeAAAA  int64 `json:"type_aaaa"`
+	TypeCNAME int64 `json:"type_cname"`
+	TypeMX    int64 `json:"type_mx"`
+	TypeTXT   int64 `json:"type_txt"`
+	TypeNS    int64 `json:"type_ns"`
+	TypeSRV   int64 `json:"type_srv"`
+	TypeSOA   int64 `json:"type_soa"`
+	TypePTR   int64 `json:"type_ptr"`
+	TypeCAA   int64 `json:"type_caa"`
+	TypeOther int64 `json:"type_other"`
+
+	// Rate limiting
+	RateLimitedDropped   int64 `json:"rate_limited_dropped"`
+	RateLimitedTruncated int64 `json:"rate_limited_truncated"`
+
+	// Forwarding
+	QueriesForwarded int64 `json:"queries_forwarded"`
+	QueriesRefused   int64 `json:"queries_refused"`
+
+	// Queries that ran out of their time budget
+	QueriesTimedOut int64 `json:"queries_timed_out"`
+
+	// Queries for names on a blocklist
+	QueriesBlocked int64 `json:"queries_blocked"`
+
+	// Queries answered or dropped by a plugin
+	QueriesPlugin int64 `json:"queries_plugin"`
+
+	// UDP retransmissions answered from the original query's resolution
+	QueriesDuplicate int64 `json:"queries_duplicate"`
+
+	// Answers sampled down to their zone's answer limit, and UDP responses
+	// truncated to fit the client's payload size
+	AnswersLimited     int64 `json:"answers_limited"`
+	ResponsesTruncated int64 `json:"responses_truncated"`
 }
 
 // Config holds configuration for the DNS server
@@ -54,6 +132,107 @@ type Config struct {
 	UDPTimeout    time.Duration
 	TCPTimeout    time.Duration
 	MaxConcurrent int
+
+	// QueryTimeout is the total time budget for answering one request,
+	// including storage lookups and forwarding
+	QueryTimeout time.Duration
+
+	// TTLJitter randomly spreads answer TTLs by up to this fraction
+	TTLJitter float64
+
+	// TTLOverrides, when set, replaces or clamps the TTLs of answers in
+	// its zones before jitter is applied
+	TTLOverrides *TTLPolicy
+
+	// AnswerLimits, when set, answers RRsets with more records than their
+	// zone's limit with a random sample of that many
+	AnswerLimits *AnswerLimitPolicy
+
+	// MaxResponseSize, when positive, caps UDP responses below the payload
+	// size clients advertise; responses are always truncated to fit
+	MaxResponseSize int
+
+	// LatencyWindow is how far back rolling latency percentiles look
+	LatencyWindow time.Duration
+
+	// QueryCounter, when set, receives per-zone/per-type query outcomes
+	QueryCounter *stats.QueryCounter
+
+	// Analytics, when set, tracks top names, NXDOMAIN names, and clients
+	Analytics *stats.QueryAnalytics
+
+	// NXDomainAnalyzer, when set, watches NXDOMAIN responses for floods
+	// and random-subdomain patterns
+	NXDomainAnalyzer *analysis.NXDomainAnalyzer
+
+	// RateLimiter, when set, limits queries per client IP or subnet
+	RateLimiter *ratelimit.Limiter
+
+	// Forwarder, when set, resolves names we hold no records for on behalf
+	// of clients allowed to recurse
+	Forwarder *forwarder.Forwarder
+
+	// WireCache, when set, serves repeated queries from packed responses
+	WireCache *cache.WireCache
+
+	// Reverse, when set, synthesizes PTR answers from A/AAAA records for
+	// addresses without a stored PTR record
+	Reverse *reverse.Synthesizer
+
+	// Blocklist, when set, answers queries for blocked names with its
+	// configured response before any cache or storage lookup
+	Blocklist *blocklist.Manager
+
+	// Groups, when set, gives clients in its networks their group's
+	// blocklist, forwarder, and log level in place of the defaults above
+	Groups *policy.Groups
+
+	// Plugins, when set, lets out-of-process plugins answer or drop
+	// queries before the caches
+	Plugins *plugin.Manager
+
+	// Hosts, when set, answers A, AAAA, and PTR queries for the names in
+	// its hosts files ahead of storage
+	Hosts *hosts.Table
+
+	// ZoneTransfer, when set, serves AXFR of stored zones to its ACL
+	ZoneTransfer *ZoneTransfer
+
+	// ReturnAll, when set, picks queries answered with their whole lowest
+	// priority group rather than one record chosen by tie-breaking
+	ReturnAll *ReturnAllPolicy
+
+	// Authority, when set, limits AA to answers for names under zones we
+	// hold an SOA for; otherwise every answer from storage sets it
+	Authority *ZoneAuthority
+
+	// Rcodes, when set, picks the response code per zone for lookups that
+	// fail or find nothing
+	Rcodes *RcodePolicy
+
+	// DedupWindow, when positive, answers UDP retransmissions of a query
+	// from its original resolution, while it runs and for this long after
+	DedupWindow time.Duration
+
+	// Mirror, when set, sends a sample of answered queries to a shadow
+	// server and compares its answers with ours
+	Mirror *mirror.Mirror
+
+	// Chaos, when set, delays and drops responses as its response faults
+	// strike. For resilience testing only
+	Chaos *chaos.Injector
+
+	// Chain, when set, orders the middleware queries run through; nil
+	// uses DefaultChain
+	Chain *Chain
+
+	// Catalog, when set, handles every query for the catalog zone
+	Catalog CatalogZone
+
+	// PacketConn and Listener, when set, are pre-opened sockets (e.g. from
+	// systemd socket activation) used instead of binding Port
+	PacketConn net.PacketConn
+	Listener   net.Listener
 }
 
 // DefaultConfig returns DNS server config with sensible defaults
@@ -62,7 +241,9 @@ func DefaultConfig() *Config {
 		Port:          "5353",
 		UDPTimeout:    5 * time.Second,
 		TCPTimeout:    10 * time.Second,
+		QueryTimeout:  5 * time.Second,
 		MaxConcurrent: 1000,
+		LatencyWindow: time.Minute,
 	}
 }
 
@@ -72,21 +253,63 @@ func NewServer(storage storage.Storage, config *Config) *Server {
 		config = DefaultConfig()
 	}
 
+	queryTimeout := config.QueryTimeout
+	if queryTimeout <= 0 {
+		queryTimeout = DefaultConfig().QueryTimeout
+	}
+
 	resolverConfig := &resolver.Config{}
 	dnsResolver := resolver.NewResolver(storage, resolverConfig)
 
 	server := &Server{
-		resolver: dnsResolver,
-		port:     config.Port,
+		resolver:   dnsResolver,
+		port:       config.Port,
+		ttlJitter:  config.TTLJitter,
+		ttls:       config.TTLOverrides,
+		latency:    stats.NewLatencyWindow(8192, config.LatencyWindow),
+		tiers:      newTierLatency(),
+		queryCount: config.QueryCounter,
+		analytics:  config.Analytics,
+		nxAnalyzer: config.NXDomainAnalyzer,
+		limiter:    config.RateLimiter,
+		forwarder:  config.Forwarder,
+		wireCache:  config.WireCache,
+		transfer:   config.ZoneTransfer,
+		reverse:    config.Reverse,
+		hosts:      config.Hosts,
+		blocklist:  config.Blocklist,
+		groups:     config.Groups,
+		plugins:    config.Plugins,
+		returnAll:  config.ReturnAll,
+		rcodes:     config.Rcodes,
+		authority:  config.Authority,
+		chaos:      config.Chaos,
+		mirror:     config.Mirror,
+
+		queryTimeout:    queryTimeout,
+		answerLimits:    config.AnswerLimits,
+		maxResponseSize: config.MaxResponseSize,
+	}
+	if config.DedupWindow > 0 {
+		server.dedup = newDedupTable(config.DedupWindow)
 	}
 
 	// Set up DNS request handler
+	chain := config.Chain
+	if chain == nil {
+		chain, _ = NewChain(nil)
+	}
+	server.handler = chain.build(server, server.resolve)
 	dns.HandleFunc(".", server.handleDNSRequest)
+	if config.Catalog != nil {
+		dns.Handle(config.Catalog.Zone(), config.Catalog)
+	}
 
 	// Create UDP server
 	server.udpServer = &dns.Server{
 		Addr:         "0.0.0.0:" + config.Port,
 		Net:          "udp4",
+		PacketConn:   config.PacketConn,
 		ReadTimeout:  config.UDPTimeout,
 		WriteTimeout: config.UDPTimeout,
 	}
@@ -95,6 +318,7 @@ func NewServer(storage storage.Storage, config *Config) *Server {
 	server.tcpServer = &dns.Server{
 		Addr:         "0.0.0.0:" + config.Port,
 		Net:          "tcp4",
+		Listener:     config.Listener,
 		ReadTimeout:  config.TCPTimeout,
 		WriteTimeout: config.TCPTimeout,
 	}
@@ -108,14 +332,14 @@ func (s *Server) Start(ctx context.Context) error {
 
 	// Start UDP server in goroutine
 	go func() {
-		if err := s.udpServer.ListenAndServe(); err != nil {
+		if err := serve(s.udpServer); err != nil {
 			logging.Info("dns", "UDP server error: %v", "details", fmt.Sprintf("UDP server error: %v", err))
 		}
 	}()
 
 	// Start TCP server in goroutine
 	go func() {
-		if err := s.tcpServer.ListenAndServe(); err != nil {
+		if err := serve(s.tcpServer); err != nil {
 			logging.Info("dns", "TCP server error: %v", "details", fmt.Sprintf("TCP server error: %v", err))
 		}
 	}()
@@ -129,6 +353,15 @@ func (s *Server) Start(ctx context.Context) error {
 	return s.Stop()
 }
 
+// serve runs server on its pre-opened socket if it has one, otherwise it
+// binds its own
+func serve(server *dns.Server) error {
+	if server.PacketConn != nil || server.Listener != nil {
+		return server.ActivateAndServe()
+	}
+	return server.ListenAndServe()
+}
+
 // Stop gracefully stops both DNS servers
 func (s *Server) Stop() error {
 	var udpErr, tcpErr error
@@ -154,99 +387,772 @@ func (s *Server) Stop() error {
 }
 
 // GetStats returns current server statistics
+// Counters are updated concurrently by query handlers, so each one is read atomically
 func (s *Server) GetStats() Stats {
-	return s.stats
+	return Stats{
+		QueriesReceived: atomic.LoadInt64(&s.stats.QueriesReceived),
+		QueriesAnswered: atomic.LoadInt64(&s.stats.QueriesAnswered),
+		QueriesNXDomain: atomic.LoadInt64(&s.stats.QueriesNXDomain),
+		QueriesError:    atomic.LoadInt64(&s.stats.QueriesError),
+		TypeA:           atomic.LoadInt64(&s.stats.TypeA),
+		TypeAAAA:        atomic.LoadInt64(&s.stats.TypeAAAA),
+		TypeCNAME:       atomic.LoadInt64(&s.stats.TypeCNAME),
+		TypeMX:          atomic.LoadInt64(&s.stats.TypeMX),
+		TypeTXT:         atomic.LoadInt64(&s.stats.TypeTXT),
+		TypeNS:          atomic.LoadInt64(&s.stats.TypeNS),
+		TypeSRV:         atomic.LoadInt64(&s.stats.TypeSRV),
+		TypeSOA:         atomic.LoadInt64(&s.stats.TypeSOA),
+		TypePTR:         atomic.LoadInt64(&s.stats.TypePTR),
+		TypeCAA:         atomic.LoadInt64(&s.stats.TypeCAA),
+		TypeOther:       atomic.LoadInt64(&s.stats.TypeOther),
+
+		RateLimitedDropped:   atomic.LoadInt64(&s.stats.RateLimitedDropped),
+		RateLimitedTruncated: atomic.LoadInt64(&s.stats.RateLimitedTruncated),
+
+		QueriesForwarded: atomic.LoadInt64(&s.stats.QueriesForwarded),
+		QueriesRefused:   atomic.LoadInt64(&s.stats.QueriesRefused),
+
+		QueriesTimedOut: atomic.LoadInt64(&s.stats.QueriesTimedOut),
+		QueriesBlocked:  atomic.LoadInt64(&s.stats.QueriesBlocked),
+		QueriesPlugin:   atomic.LoadInt64(&s.stats.QueriesPlugin),
+
+		QueriesDuplicate: atomic.LoadInt64(&s.stats.QueriesDuplicate),
+
+		AnswersLimited:     atomic.LoadInt64(&s.stats.AnswersLimited),
+		ResponsesTruncated: atomic.LoadInt64(&s.stats.ResponsesTruncated),
+	}
 }
 
-// handleDNSRequest processes incoming DNS requests
+// GetPluginStats returns call counts for each plugin, or nil when no
+// plugins are configured
+func (s *Server) GetPluginStats() []plugin.Stats {
+	if s.plugins == nil {
+		return nil
+	}
+	return s.plugins.Stats()
+}
+
+// GetMirrorStats returns how mirrored queries' answers compared with the
+// shadow server's, or nil when mirroring is off
+func (s *Server) GetMirrorStats() *mirror.Stats {
+	if s.mirror == nil {
+		return nil
+	}
+	return s.mirror.Stats()
+}
+
+// GetChaosStats returns how often each injected fault struck, or nil when
+// fault injection is off
+func (s *Server) GetChaosStats() []chaos.FaultStats {
+	return s.chaos.Stats()
+}
+
+// GetLatency returns rolling query latency percentiles
+func (s *Server) GetLatency() stats.Percentiles {
+	return s.latency.Percentiles()
+}
+
+// GetTierLatency returns the latency distribution of queries answered by
+// each tier: the wire cache (L0), memory (L1), Redis (L2), the database,
+// or the forwarder. Tiers that answered nothing are left out
+func (s *Server) GetTierLatency() map[string]stats.Histogram {
+	tiers := make(map[string]stats.Histogram)
+	for source, histogram := range s.tiers {
+		if snapshot := histogram.Snapshot(); snapshot.Count > 0 {
+			tiers[source.String()] = snapshot
+		}
+	}
+	return tiers
+}
+
+// newTierLatency creates a histogram for each tier a query can be
+// answered from
+func newTierLatency() map[storage.CacheSource]*stats.LatencyHistogram {
+	tiers := make(map[storage.CacheSource]*stats.LatencyHistogram)
+	for _, source := range []storage.CacheSource{
+		storage.SourceWire, storage.SourceMemory, storage.SourceRedis, storage.SourceDatabase, storage.SourceForwarded,
+	} {
+		tiers[source] = stats.NewLatencyHistogram()
+	}
+	return tiers
+}
+
+// GetTopQueries returns the top n names, NXDOMAIN names, and clients, or
+// nil when analytics are disabled
+func (s *Server) GetTopQueries(n int) *stats.TopQueries {
+	if s.analytics == nil {
+		return nil
+	}
+	top := s.analytics.Top(n)
+	return &top
+}
+
+// GetWireCacheStats returns L0 wire cache statistics, or nil when it is disabled
+func (s *Server) GetWireCacheStats() *cache.Stats {
+	if s.wireCache == nil {
+		return nil
+	}
+	stats := s.wireCache.Stats()
+	return &stats
+}
+
+// GetForwarderStats returns per-upstream forwarding statistics, or nil if
+// forwarding is disabled
+func (s *Server) GetForwarderStats() []forwarder.UpstreamStats {
+	if s.forwarder == nil {
+		return nil
+	}
+	return s.forwarder.UpstreamStats()
+}
+
+// ResetStats zeroes all counters and discards latency samples and analytics
+func (s *Server) ResetStats() {
+	counters := []*int64{
+		&s.stats.QueriesReceived, &s.stats.QueriesAnswered, &s.stats.QueriesNXDomain, &s.stats.QueriesError,
+		&s.stats.TypeA, &s.stats.TypeAAAA, &s.stats.TypeCNAME, &s.stats.TypeMX, &s.stats.TypeTXT,
+		&s.stats.TypeNS, &s.stats.TypeSRV, &s.stats.TypeSOA, &s.stats.TypePTR, &s.stats.TypeCAA, &s.stats.TypeOther,
+		&s.stats.RateLimitedDropped, &s.stats.RateLimitedTruncated,
+		&s.stats.QueriesForwarded, &s.stats.QueriesRefused, &s.stats.QueriesTimedOut, &s.stats.QueriesBlocked,
+		&s.stats.QueriesPlugin, &s.stats.QueriesDuplicate,
+		&s.stats.AnswersLimited, &s.stats.ResponsesTruncated,
+	}
+	for _, counter := range counters {
+		atomic.StoreInt64(counter, 0)
+	}
+	s.latency.Reset()
+	for _, histogram := range s.tiers {
+		histogram.Reset()
+	}
+	if s.wireCache != nil {
+		s.wireCache.ResetStats()
+	}
+	if s.analytics != nil {
+		s.analytics.Reset()
+	}
+}
+
+// handleDNSRequest processes incoming DNS requests, passing each well-formed
+// query down the middleware chain
 func (s *Server) handleDNSRequest(w dns.ResponseWriter, r *dns.Msg) {
-	s.stats.QueriesReceived++
+	start := time.Now()
+	var req *Request
+	defer func() {
+		elapsed := time.Since(start)
+		s.latency.Record(elapsed)
+		if req == nil {
+			return
+		}
+		if histogram := s.tiers[req.Source]; histogram != nil {
+			histogram.Record(elapsed)
+		}
+	}()
+
+	atomic.AddInt64(&s.stats.QueriesReceived, 1)
 
-	// Create response message
-	msg := dns.Msg{}
+	// A query carries exactly one question (RFC 9619). There's no defined
+	// way to combine the rcodes of several, so they're rejected outright
+	if len(r.Question) != 1 {
+		s.rejectFormat(w, r)
+		return
+	}
+
+	// Every log line for this request carries its query ID, and all of its
+	// lookups share one time budget
+	ctx := logging.WithQueryID(context.Background(), logging.NewQueryID())
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	req = &Request{Ctx: ctx, W: w, Msg: r, Client: clientIP(w.RemoteAddr())}
+	req.Blocker, req.Forwarder = s.policyFor(nil)
+	s.handler(req)
+}
+
+// resolve answers a query from hosts files, storage, and the forwarder. It
+// ends every middleware chain
+func (s *Server) resolve(req *Request) {
+	ctx, w, r, client, fwd := req.Ctx, req.W, req.Msg, req.Client, req.Forwarder
+
+	// Transfers only reach here when the chain has no transfer middleware
+	if isTransfer(r) {
+		s.writeRcode(w, r, dns.RcodeRefused, &extendedError{dns.ExtendedErrorCodeProhibited, edeTextNoTransfer})
+		return
+	}
+
+	// Create response message, reusing a pooled one
+	msg := acquireMsg()
+	defer releaseMsg(msg)
 	msg.SetReply(r)
-	msg.Authoritative = true
-	msg.RecursionAvailable = false
+	question := &r.Question[0]
+	msg.Authoritative = s.authority.Authoritative(question.Name)
+	msg.RecursionAvailable = fwd != nil && fwd.Allowed(client)
 
-	// Process each question in the request
-	for _, question := range r.Question {
-		if err := s.processQuestion(&msg, &question); err != nil {
-			logging.Error("dns", "Error processing question %s %s: %v", nil,
-				question.Name, dns.TypeToString[question.Qtype], err)
-			msg.Rcode = dns.RcodeServerFailure
-			s.stats.QueriesError++
+	// Answer the question, tracing which tiers its lookups reach; failures
+	// are counted with the rcode below and explained to EDNS clients with an
+	// extended error
+	var ede *extendedError
+	lookupCtx, trace := storage.WithLookupTrace(ctx)
+	if err := s.processQuestion(lookupCtx, msg, question); err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			s.recordTimeout(ctx, question)
+		case errors.Is(err, storage.ErrCacheOnly):
+			// Expected for every cache miss; the mode is logged once
+			logging.DebugContext(ctx, "dns", "Cache miss while serving from cache only",
+				"domain", question.Name, "type", dns.TypeToString[question.Qtype])
+		default:
+			logging.ErrorContext(ctx, "dns", "Error processing question", err,
+				"domain", question.Name, "type", dns.TypeToString[question.Qtype])
+		}
+		msg.Authoritative = false
+		s.setPolicyRcode(msg, s.rcodes.Failure(question.Name, err))
+		ede = lookupError(ctx, err)
+	} else if trace.Stale() {
+		ede = &extendedError{dns.ExtendedErrorCodeStaleAnswer, edeTextStale}
+	}
+
+	// Names outside the zones we hold are recursed for allowed clients and
+	// refused for everyone else, and without recursion the rcode policy
+	// decides how they're answered. Names inside them keep our NXDOMAIN
+	// whoever asks, and never leave for the upstreams
+	forwarded := false
+	if msg.Rcode == dns.RcodeNameError && len(msg.Answer) == 0 && !s.authority.Holds(question.Name) {
+		if fwd != nil && r.RecursionDesired {
+			ede = s.forward(ctx, r, msg, fwd)
+			forwarded = ede == nil
+		} else {
+			s.setPolicyRcode(msg, s.rcodes.NotFound(question.Name))
+			if msg.Rcode == dns.RcodeRefused {
+				ede = &extendedError{dns.ExtendedErrorCodeNotAuthoritative, edeTextNotAuthoritative}
+			}
 		}
 	}
 
 	// Update statistics based on response code
+	var outcome stats.Outcome
 	switch msg.Rcode {
 	case dns.RcodeSuccess:
 		if len(msg.Answer) > 0 {
-			s.stats.QueriesAnswered++
+			atomic.AddInt64(&s.stats.QueriesAnswered, 1)
+			outcome = stats.OutcomeAnswered
 		} else {
-			s.stats.QueriesNXDomain++
+			atomic.AddInt64(&s.stats.QueriesNXDomain, 1)
+			outcome = stats.OutcomeNXDomain
 		}
 	case dns.RcodeNameError:
-		s.stats.QueriesNXDomain++
+		atomic.AddInt64(&s.stats.QueriesNXDomain, 1)
+		outcome = stats.OutcomeNXDomain
 	default:
-		s.stats.QueriesError++
+		atomic.AddInt64(&s.stats.QueriesError, 1)
+		outcome = stats.OutcomeError
+	}
+
+	s.recordOutcome(r, client, outcome)
+	if outcome == stats.OutcomeNXDomain {
+		s.analyzeNXDomain(r)
+	}
+	req.Source = trace.Source()
+	if forwarded {
+		req.Source = storage.SourceForwarded
+	}
+
+	// Owner names echo the question's letter case, which resolvers using
+	// 0x20 randomization check against the case they sent
+	echoQueryCase(msg, question.Name)
+
+	// Rewrite addresses for the client's view before the answer is cached
+	view := ""
+	if req.Group != nil && req.Group.NAT != nil {
+		translateAddresses(msg, req.Group.NAT)
+		view = req.Group.Name
+	}
+
+	setExtendedError(msg, r, ede)
+
+	// Sample oversized RRsets, then trim what still won't fit the client's
+	// UDP payload size, setting TC
+	var limited bool
+	if msg.Answer, limited = s.answerLimits.Apply(msg.Answer); limited {
+		atomic.AddInt64(&s.stats.AnswersLimited, 1)
+	}
+	if s.truncate(w, r, msg) {
+		atomic.AddInt64(&s.stats.ResponsesTruncated, 1)
+	}
+
+	// Send the response, keeping a copy of cacheable answers in the L0 cache.
+	// Sampled answers aren't kept, so each query gets a fresh sample, and
+	// neither are answers too big for UDP, which the cache serves too, or
+	// stale answers, whose extended error would outlive the outage
+	// Compressed, owner names echoing the question point at it, so they
+	// follow the question name the cache patches into each hit
+	bufp := packPool.Get().(*[]byte)
+	defer packPool.Put(bufp)
+
+	msg.Compress = true
+	packed, err := msg.PackBuffer(*bufp)
+	if err == nil {
+		if s.wireCache != nil && outcome == stats.OutcomeAnswered && !forwarded &&
+			ede == nil && !limited && !msg.Truncated && len(packed) <= s.udpSize(r) {
+			if key, ok := wireKey(r, view); ok {
+				s.wireCache.Set(key, packed, minTTL(msg.Answer))
+			}
+		}
+		_, err = w.Write(packed)
+	}
+	if err != nil {
+		logging.ErrorContext(ctx, "dns", "Failed to write DNS response", err)
+		atomic.AddInt64(&s.stats.QueriesError, 1)
+	}
+}
+
+// recordOutcome feeds a finished query to the optional per-zone counters
+// and analytics
+func (s *Server) recordOutcome(r *dns.Msg, client net.IP, outcome stats.Outcome) {
+	if s.queryCount != nil {
+		for _, question := range r.Question {
+			zone, ok := s.authority.Zone(question.Name)
+			if !ok {
+				zone = stats.OtherZone
+			}
+			s.queryCount.Record(zone, dns.TypeToString[question.Qtype], outcome)
+		}
+	}
+
+	if s.analytics != nil {
+		source := ""
+		if client != nil {
+			source = client.String()
+		}
+		for _, question := range r.Question {
+			s.analytics.Record(strings.ToLower(question.Name), source, outcome == stats.OutcomeNXDomain)
+		}
+	}
+}
+
+// analyzeNXDomain feeds a query answered NXDOMAIN to the optional NXDOMAIN
+// analyzer, under the zone we hold its name in or stats.OtherZone. Blocked
+// queries aren't fed to it; their answers come from the blocklist
+func (s *Server) analyzeNXDomain(r *dns.Msg) {
+	if s.nxAnalyzer == nil {
+		return
+	}
+	for _, question := range r.Question {
+		zone, ok := s.authority.Zone(question.Name)
+		if !ok {
+			zone = stats.OtherZone
+		}
+		s.nxAnalyzer.Record(zone, question.Name)
+	}
+}
+
+// answerFromWireCache writes a cached packed response for the request,
+// patched with its ID, RD bit, RA bit for this client, and question name
+// case. It reports whether the query was answered
+func (s *Server) answerFromWireCache(req *Request) bool {
+	ctx, w, r, client, fwd := req.Ctx, req.W, req.Msg, req.Client, req.Forwarder
+
+	view := ""
+	if req.Group != nil && req.Group.NAT != nil {
+		view = req.Group.Name
+	}
+	key, ok := wireKey(r, view)
+	if !ok {
+		return false
 	}
 
-	// Send the response
-	if err := w.WriteMsg(&msg); err != nil {
-		logging.Error("dns", "Failed to write DNS response: %v", nil, err)
-		s.stats.QueriesError++
+	bufp := packPool.Get().(*[]byte)
+	defer packPool.Put(bufp)
+
+	packed, hit := s.wireCache.Get(key, *bufp)
+	if !hit {
+		return false
+	}
+
+	binary.BigEndian.PutUint16(packed[0:2], r.Id)
+	packed[2] &^= 0x01 // RD
+	if r.RecursionDesired {
+		packed[2] |= 0x01
+	}
+	packed[3] &^= 0x80 // RA
+	if fwd != nil && fwd.Allowed(client) {
+		packed[3] |= 0x80
+	}
+	// The cached question differs from this one at most in letter case,
+	// so the name packs to the same length at the same offset
+	if _, err := dns.PackDomainName(r.Question[0].Name, packed, dnsHeaderSize, nil, false); err != nil {
+		return false
+	}
+
+	if _, err := w.Write(packed); err != nil {
+		logging.ErrorContext(ctx, "dns", "Failed to write cached DNS response", err)
+		atomic.AddInt64(&s.stats.QueriesError, 1)
+		return true
+	}
+
+	if logging.Enabled(logging.LevelDebug) {
+		logging.DebugContext(ctx, "dns", "Answered from wire cache", "domain", r.Question[0].Name, "type", dns.TypeToString[r.Question[0].Qtype])
+	}
+
+	s.updateTypeStats(r.Question[0].Qtype)
+	atomic.AddInt64(&s.stats.QueriesAnswered, 1)
+	s.recordOutcome(r, client, stats.OutcomeAnswered)
+	req.Source = storage.SourceWire
+	return true
+}
+
+// dnsHeaderSize is the length of the fixed DNS message header
+const dnsHeaderSize = 12
+
+// wireKey derives the L0 cache key for r as answered for view, or reports
+// false for requests whose responses aren't cached (multiple questions,
+// non-query opcodes)
+func wireKey(r *dns.Msg, view string) (cache.WireKey, bool) {
+	if len(r.Question) != 1 || r.Opcode != dns.OpcodeQuery || r.Question[0].Qclass != dns.ClassINET {
+		return cache.WireKey{}, false
+	}
+
+	key := cache.WireKey{
+		Name: strings.ToLower(r.Question[0].Name),
+		Type: r.Question[0].Qtype,
+		View: view,
+	}
+	if opt := r.IsEdns0(); opt != nil {
+		key.EDNSSize = opt.UDPSize()
+	}
+	return key, true
+}
+
+// echoQueryCase gives the records in msg owned by qname, whatever their
+// case, qname's exact spelling
+func echoQueryCase(msg *dns.Msg, qname string) {
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if hdr := rr.Header(); hdr.Name != qname && strings.EqualFold(hdr.Name, qname) {
+				hdr.Name = qname
+			}
+		}
+	}
+}
+
+// udpSize returns the largest UDP response for r: the payload size it
+// advertises, at least 512 bytes, capped by the configured maximum
+func (s *Server) udpSize(r *dns.Msg) int {
+	size := dns.MinMsgSize
+	if opt := r.IsEdns0(); opt != nil {
+		size = max(size, int(opt.UDPSize()))
+	}
+	if s.maxResponseSize > 0 {
+		size = min(size, s.maxResponseSize)
+	}
+	return size
+}
+
+// truncate drops the records that don't fit a UDP response to r from msg,
+// setting TC so the client retries over TCP. It reports whether any were
+// dropped; TCP responses are left alone
+func (s *Server) truncate(w dns.ResponseWriter, r *dns.Msg, msg *dns.Msg) bool {
+	if _, isUDP := w.RemoteAddr().(*net.UDPAddr); !isUDP || msg.Truncated {
+		return false
+	}
+	msg.Truncate(s.udpSize(r))
+	return msg.Truncated
+}
+
+// translateAddresses rewrites the A and AAAA records in msg's answer and
+// additional sections with nat's mappings
+func translateAddresses(msg *dns.Msg, nat *policy.NAT) {
+	for _, section := range [][]dns.RR{msg.Answer, msg.Extra} {
+		for _, rr := range section {
+			switch rr := rr.(type) {
+			case *dns.A:
+				if addr, ok := netip.AddrFromSlice(rr.A); ok {
+					if translated, ok := nat.Translate(addr); ok {
+						rr.A = translated.AsSlice()
+					}
+				}
+			case *dns.AAAA:
+				if addr, ok := netip.AddrFromSlice(rr.AAAA); ok {
+					if translated, ok := nat.Translate(addr); ok {
+						rr.AAAA = translated.AsSlice()
+					}
+				}
+			}
+		}
+	}
+}
+
+// minTTL returns the smallest TTL among rrs
+func minTTL(rrs []dns.RR) time.Duration {
+	if len(rrs) == 0 {
+		return 0
+	}
+	lowest := rrs[0].Header().Ttl
+	for _, rr := range rrs[1:] {
+		if ttl := rr.Header().Ttl; ttl < lowest {
+			lowest = ttl
+		}
+	}
+	return time.Duration(lowest) * time.Second
+}
+
+// forward replaces msg with fwd's answer to r, or with REFUSED when the
+// client is not allowed to recurse. Failures return the extended error
+// explaining them
+func (s *Server) forward(ctx context.Context, r *dns.Msg, msg *dns.Msg, fwd *forwarder.Forwarder) *extendedError {
+	msg.Authoritative = false
+	msg.Ns = msg.Ns[:0]
+	msg.Extra = msg.Extra[:0]
+
+	if !msg.RecursionAvailable {
+		atomic.AddInt64(&s.stats.QueriesRefused, 1)
+		msg.Rcode = dns.RcodeRefused
+		return &extendedError{dns.ExtendedErrorCodeProhibited, edeTextNoRecursion}
+	}
+
+	reply, err := fwd.Forward(ctx, r)
+	if err != nil {
+		msg.Rcode = dns.RcodeServerFailure
+		if ctx.Err() == context.DeadlineExceeded {
+			s.recordTimeout(ctx, &r.Question[0])
+			return &extendedError{dns.ExtendedErrorCodeNoReachableAuthority, edeTextForwardTimeout}
+		}
+		logging.ErrorContext(ctx, "dns", "Forwarding failed", err, "domain", r.Question[0].Name)
+		return &extendedError{dns.ExtendedErrorCodeNetworkError, edeTextForwardFailed}
+	}
+
+	atomic.AddInt64(&s.stats.QueriesForwarded, 1)
+	*msg = *reply
+	msg.Id = r.Id
+	msg.Authoritative = false
+	msg.RecursionDesired = r.RecursionDesired
+	msg.RecursionAvailable = true
+	return nil
+}
+
+// policyFor returns the blocklist view and forwarder for a client in group,
+// falling back to the server's for clients in no group and groups that
+// don't set a forwarder. A nil view blocks nothing
+func (s *Server) policyFor(group *policy.Group) (*blocklist.View, *forwarder.Forwarder) {
+	if group == nil {
+		if s.blocklist == nil {
+			return nil, s.forwarder
+		}
+		return s.blocklist.Default(), s.forwarder
+	}
+	if group.Forwarder != nil {
+		return group.Blocker, group.Forwarder
+	}
+	return group.Blocker, s.forwarder
+}
+
+// setPolicyRcode sets an rcode chosen by the rcode policy. Refusals don't
+// claim authority over the name
+func (s *Server) setPolicyRcode(msg *dns.Msg, rcode int) {
+	msg.Rcode = rcode
+	if rcode == dns.RcodeRefused {
+		atomic.AddInt64(&s.stats.QueriesRefused, 1)
+		msg.Authoritative = false
+	}
+}
+
+// recordTimeout counts and logs a question that ran out of the query budget
+func (s *Server) recordTimeout(ctx context.Context, question *dns.Question) {
+	atomic.AddInt64(&s.stats.QueriesTimedOut, 1)
+	logging.LogQueryTimeout(ctx, question.Name, dns.TypeToString[question.Qtype], s.queryTimeout)
+}
+
+// rejectRateLimited handles a query from a source over its rate limit,
+// either dropping it or replying with an empty truncated response
+func (s *Server) rejectRateLimited(w dns.ResponseWriter, r *dns.Msg) {
+	_, isUDP := w.RemoteAddr().(*net.UDPAddr)
+	if s.limiter.Action() != ratelimit.ActionTruncate || !isUDP {
+		atomic.AddInt64(&s.stats.RateLimitedDropped, 1)
+		return
+	}
+
+	msg := acquireMsg()
+	defer releaseMsg(msg)
+	msg.SetReply(r)
+	msg.Truncated = true
+	if err := writeMsg(w, msg); err != nil {
+		logging.Error("dns", "Failed to write truncated response: %v", nil, err)
+	}
+	atomic.AddInt64(&s.stats.RateLimitedTruncated, 1)
+}
+
+// rejectFormat answers a message without exactly one question with FORMERR
+func (s *Server) rejectFormat(w dns.ResponseWriter, r *dns.Msg) {
+	msg := acquireMsg()
+	defer releaseMsg(msg)
+	msg.SetRcodeFormatError(r)
+	if err := writeMsg(w, msg); err != nil {
+		logging.Error("dns", "Failed to write format error response", err)
+	}
+	atomic.AddInt64(&s.stats.QueriesError, 1)
+}
+
+// answerBlocked answers a query for a name the request's blocker blocked
+// with the blocker's response
+func (s *Server) answerBlocked(req *Request) {
+	ctx, w, r, client, fwd := req.Ctx, req.W, req.Msg, req.Client, req.Forwarder
+	response := req.Blocker.Response()
+
+	msg := acquireMsg()
+	defer releaseMsg(msg)
+	msg.SetReply(r)
+	msg.RecursionAvailable = fwd != nil && fwd.Allowed(client)
+	response.Write(msg, r)
+	setExtendedError(msg, r, &extendedError{dns.ExtendedErrorCodeBlocked, edeTextBlocked})
+
+	logging.InfoContext(ctx, "blocklist", "Blocked query", "domain", r.Question[0].Name,
+		"type", dns.TypeToString[r.Question[0].Qtype], "client", client, "response", response.String())
+	if err := writeMsg(w, msg); err != nil {
+		logging.ErrorContext(ctx, "dns", "Failed to write blocked response", err)
+	}
+	atomic.AddInt64(&s.stats.QueriesBlocked, 1)
+
+	outcome := stats.OutcomeNXDomain
+	switch {
+	case msg.Rcode == dns.RcodeRefused:
+		outcome = stats.OutcomeError
+	case len(msg.Answer) > 0:
+		outcome = stats.OutcomeAnswered
+	}
+	s.recordOutcome(r, client, outcome)
+}
+
+// msgPool recycles response messages, and with them the capacity of their
+// record slices, across queries
+var msgPool = sync.Pool{
+	New: func() interface{} { return new(dns.Msg) },
+}
+
+// packPool recycles buffers responses are packed into before writing
+var packPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, packBufferSize)
+		return &buf
+	},
+}
+
+// packBufferSize covers typical EDNS payloads; larger responses fall back
+// to a one-off allocation inside PackBuffer
+const packBufferSize = 4096
+
+// acquireMsg returns an empty message from the pool
+func acquireMsg() *dns.Msg {
+	return msgPool.Get().(*dns.Msg)
+}
+
+// releaseMsg resets msg, keeping its slice capacity, and returns it to the pool
+func releaseMsg(msg *dns.Msg) {
+	clear(msg.Answer)
+	clear(msg.Ns)
+	clear(msg.Extra)
+	answer, ns, extra := msg.Answer[:0], msg.Ns[:0], msg.Extra[:0]
+
+	*msg = dns.Msg{}
+	msg.Answer, msg.Ns, msg.Extra = answer, ns, extra
+	msgPool.Put(msg)
+}
+
+// writeMsg packs msg into a pooled buffer and writes it to w
+func writeMsg(w dns.ResponseWriter, msg *dns.Msg) error {
+	bufp := packPool.Get().(*[]byte)
+	defer packPool.Put(bufp)
+
+	packed, err := msg.PackBuffer(*bufp)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(packed)
+	return err
+}
+
+// clientIP extracts the IP address from a client's network address
+func clientIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.TCPAddr:
+		return a.IP
+	case nil:
+		return nil
+	default:
+		host, _, err := net.SplitHostPort(a.String())
+		if err != nil {
+			return nil
+		}
+		return net.ParseIP(host)
 	}
 }
 
 // processQuestion handles a single DNS question
-func (s *Server) processQuestion(msg *dns.Msg, question *dns.Question) error {
+func (s *Server) processQuestion(ctx context.Context, msg *dns.Msg, question *dns.Question) error {
 	// Extract query details
 	queryName := question.Name
 	queryType := dns.TypeToString[question.Qtype]
 
-	logging.Debug("dns", "DNS Query received", "domain", queryName, "type", queryType)
+	if logging.Enabled(logging.LevelDebug) {
+		logging.DebugContext(ctx, "dns", "DNS Query received", "domain", queryName, "type", queryType)
+	}
 
 	// Update type statistics
 	s.updateTypeStats(question.Qtype)
 
-	// Convert to our internal query format
-	query := models.NewLookupQuery(queryName, queryType)
+	// ANY queries get the minimal answer of RFC 8482 rather than every
+	// record at the name
+	if question.Qtype == dns.TypeANY {
+		s.answerANY(msg, question)
+		return nil
+	}
 
-	// Look up the record in storage
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// Hosts file entries override stored records
+	if s.hosts != nil && s.answerHosts(ctx, msg, question) {
+		return nil
+	}
+
+	// Convert to our internal query format
+	query := models.NewLookupQuery(queryName, StoredType(question.Qtype))
 
 	// Handle record types that should return multiple records
-	if question.Qtype == dns.TypeSRV || question.Qtype == dns.TypeMX || question.Qtype == dns.TypeNS {
-		// For SRV, MX, and NS records, return all records
+	if answersRRset(question.Qtype) {
+		// For SRV, MX, NS, and DNSSEC key records, return all records
 		records, err := s.resolver.ResolveAll(ctx, query)
 		if err != nil {
 			return fmt.Errorf("resolver lookup failed: %w", err)
 		}
 
 		if len(records) == 0 {
-			logging.Info("dns", "No records found for %s %s", "details", fmt.Sprintf("No records found for %s %s", queryName, queryType))
-			msg.Rcode = dns.RcodeNameError
-			return nil
+			return s.answerNotFound(ctx, msg, question)
 		}
 
 		// Convert all records to DNS resource records
+		answerStart := len(msg.Answer)
 		for _, record := range records {
-			rr, err := s.createResourceRecord(record, question.Qtype)
+			rr, err := ResourceRecord(record, question.Qtype)
 			if err != nil {
 				return fmt.Errorf("failed to create resource record: %w", err)
 			}
 
 			if rr != nil {
 				msg.Answer = append(msg.Answer, rr)
-				logging.Info("dns", "Answered %s %s -> %s (priority: %d) [DB]", "details", fmt.Sprintf("Answered %s %s -> %s (priority: %d) [DB]", queryName, queryType, record.Target, record.Priority))
+				logging.InfoContext(ctx, "dns", "Answered %s %s -> %s (priority: %d) [DB]", "details", logging.Lazyf("Answered %s %s -> %s (priority: %d) [DB]", queryName, queryType, record.Target, record.Priority))
 			}
 		}
+		s.applyTTLs(msg.Answer[answerStart:])
 
 		return nil
 	}
 
+	// Answer with the whole lowest priority group where configured
+	if s.returnAll.Matches(queryName, question.Qtype) {
+		found, err := s.answerGroup(ctx, msg, question, query)
+		if err != nil || found {
+			return err
+		}
+		return s.answerNotFound(ctx, msg, question)
+	}
+
 	record, err := s.resolver.Resolve(ctx, query)
 	if err != nil {
 		return fmt.Errorf("resolver lookup failed: %w", err)
@@ -254,33 +1160,182 @@ func (s *Server) processQuestion(msg *dns.Msg, question *dns.Question) error {
 
 	// Handle no record found
 	if record == nil {
-		logging.LogNXDOMAIN(queryName, queryType, 0)
-		msg.Rcode = dns.RcodeNameError
-		return nil
+		return s.answerNotFound(ctx, msg, question)
 	}
 
 	// Convert to DNS resource record
-	rr, err := s.createResourceRecord(record, question.Qtype)
+	rr, err := ResourceRecord(record, question.Qtype)
 	if err != nil {
 		return fmt.Errorf("failed to create resource record: %w", err)
 	}
 
 	if rr != nil {
+		s.applyTTLs([]dns.RR{rr})
 		msg.Answer = append(msg.Answer, rr)
-		logging.Info("dns", "Answered %s %s -> %s [DB]", "details", fmt.Sprintf("Answered %s %s -> %s [DB]", queryName, queryType, record.Target))
+		logging.InfoContext(ctx, "dns", "Answered %s %s -> %s [DB]", "details", logging.Lazyf("Answered %s %s -> %s [DB]", queryName, queryType, record.Target))
 	} else {
 		// Record type mismatch
-		log.Printf("Record type mismatch for %s: found %s, requested %s",
-			queryName, record.RecordType, queryType)
+		logging.WarnContext(ctx, "dns", "Record type mismatch", "domain", queryName, "found", record.RecordType, "requested", queryType)
 		msg.Rcode = dns.RcodeNameError
 	}
 
 	return nil
 }
 
-// createResourceRecord converts our internal record to a DNS resource record
-func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (dns.RR, error) {
+// answerGroup adds every record in the lowest priority group as answers,
+// reporting whether any were found
+func (s *Server) answerGroup(ctx context.Context, msg *dns.Msg, question *dns.Question, query *models.LookupQuery) (bool, error) {
+	records, err := s.resolver.ResolveGroup(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("resolver lookup failed: %w", err)
+	}
+
+	answerStart := len(msg.Answer)
+	for _, record := range records {
+		rr, err := ResourceRecord(record, question.Qtype)
+		if err != nil {
+			return false, fmt.Errorf("failed to create resource record: %w", err)
+		}
+		if rr != nil {
+			msg.Answer = append(msg.Answer, rr)
+			logging.InfoContext(ctx, "dns", "Answered %s %s -> %s [DB]", "details", logging.Lazyf("Answered %s %s -> %s [DB]", question.Name, dns.TypeToString[question.Qtype], record.Target))
+		}
+	}
+	s.applyTTLs(msg.Answer[answerStart:])
+
+	return len(msg.Answer) > answerStart, nil
+}
+
+// answerNotFound answers a question no stored record matched, with a CNAME
+// synthesized from a DNAME or a synthesized PTR where possible and NXDOMAIN
+// otherwise
+func (s *Server) answerNotFound(ctx context.Context, msg *dns.Msg, question *dns.Question) error {
+	found, err := s.answerDNAME(ctx, msg, question)
+	if err != nil || found {
+		return err
+	}
+
+	// Stored PTR records take precedence over synthesized ones
+	if question.Qtype == dns.TypePTR && s.reverse != nil {
+		found, err := s.answerReverse(ctx, msg, question)
+		if err != nil || found {
+			return err
+		}
+	}
+
+	logging.LogNXDOMAIN(ctx, question.Name, dns.TypeToString[question.Qtype], 0)
+	msg.Rcode = dns.RcodeNameError
+	return nil
+}
+
+// answerDNAME answers a question for a name below a DNAME with the DNAME
+// and the CNAME synthesized from it, reporting whether a DNAME covered the
+// name. Resolvers follow the CNAME to the redirected name. A substituted
+// name too long to exist is answered with the DNAME and YXDOMAIN (RFC 6672
+// section 2.2)
+func (s *Server) answerDNAME(ctx context.Context, msg *dns.Msg, question *dns.Question) (bool, error) {
+	dname, cname, err := s.resolver.ResolveDNAME(ctx, question.Name)
+	switch {
+	case errors.Is(err, models.ErrDNAMETooLong):
+	case err != nil:
+		return false, fmt.Errorf("resolver DNAME lookup failed: %w", err)
+	case dname == nil:
+		return false, nil
+	}
+
+	answerStart := len(msg.Answer)
+	rr, err := ResourceRecord(dname, dns.TypeDNAME)
+	if err != nil {
+		return false, fmt.Errorf("failed to create resource record: %w", err)
+	}
+	msg.Answer = append(msg.Answer, rr)
+
+	if cname == nil {
+		logging.InfoContext(ctx, "dns", "DNAME substitution too long", "domain", question.Name, "dname", dname.Name)
+		msg.Rcode = dns.RcodeYXDomain
+		return true, nil
+	}
+	rr, err = ResourceRecord(cname, dns.TypeCNAME)
+	if err != nil {
+		return false, fmt.Errorf("failed to create resource record: %w", err)
+	}
+	msg.Answer = append(msg.Answer, rr)
+	s.applyTTLs(msg.Answer[answerStart:])
+	logging.InfoContext(ctx, "dns", "Answered %s %s -> %s [DNAME]", "details", logging.Lazyf("Answered %s %s -> %s [DNAME]", question.Name, dns.TypeToString[question.Qtype], cname.Target))
+
+	return true, nil
+}
+
+// anyTTL is the TTL of the HINFO record answering ANY queries, long
+// enough to spare us repeated ANY queries for the same name
+const anyTTL = 3600
+
+// answerANY answers an ANY query with a synthesized HINFO record (RFC 8482
+// section 4.2)
+func (s *Server) answerANY(msg *dns.Msg, question *dns.Question) {
+	msg.Answer = append(msg.Answer, &dns.HINFO{
+		Hdr: dns.RR_Header{
+			Name:   question.Name,
+			Rrtype: dns.TypeHINFO,
+			Class:  dns.ClassINET,
+			Ttl:    anyTTL,
+		},
+		Cpu: "RFC8482",
+		Os:  "",
+	})
+}
+
+// answerHosts adds answers from the hosts files, reporting whether they
+// hold the name. A name they hold without records of the queried type is
+// answered with no data
+func (s *Server) answerHosts(ctx context.Context, msg *dns.Msg, question *dns.Question) bool {
+	records, found := s.hosts.Lookup(question.Name, question.Qtype)
+	if !found {
+		return false
+	}
+
+	msg.Authoritative = false
+	answerStart := len(msg.Answer)
+	for _, record := range records {
+		rr, err := ResourceRecord(record, question.Qtype)
+		if err != nil || rr == nil {
+			continue
+		}
+		msg.Answer = append(msg.Answer, rr)
+		logging.InfoContext(ctx, "dns", "Answered %s %s -> %s [hosts]", "details", logging.Lazyf("Answered %s %s -> %s [hosts]", question.Name, dns.TypeToString[question.Qtype], record.Target))
+	}
+	s.applyTTLs(msg.Answer[answerStart:])
+	return true
+}
+
+// answerReverse adds synthesized PTR answers for a reverse name, reporting
+// whether any were found
+func (s *Server) answerReverse(ctx context.Context, msg *dns.Msg, question *dns.Question) (bool, error) {
+	records, err := s.reverse.Lookup(ctx, question.Name)
+	if err != nil {
+		return false, fmt.Errorf("reverse synthesis failed: %w", err)
+	}
+
+	answerStart := len(msg.Answer)
+	for _, record := range records {
+		rr, err := ResourceRecord(record, dns.TypePTR)
+		if err != nil {
+			return false, fmt.Errorf("failed to create resource record: %w", err)
+		}
+		msg.Answer = append(msg.Answer, rr)
+		msg.Authoritative = false // synthesized, not stored
+		logging.InfoContext(ctx, "dns", "Answered %s PTR -> %s [synthesized]", "details", logging.Lazyf("Answered %s PTR -> %s [synthesized]", question.Name, record.Target))
+	}
+	s.applyTTLs(msg.Answer[answerStart:])
+
+	return len(msg.Answer) > answerStart, nil
+}
+
+// ResourceRecord converts a stored record to the resource record answering
+// qtype, or nil if the record doesn't answer it
+func ResourceRecord(record *models.DNSRecord, qtype uint16) (dns.RR, error) {
 	recordType := models.RecordType(record.RecordType)
+	ttl := record.EffectiveTTL()
 
 	switch recordType {
 	case models.RecordTypeA:
@@ -294,7 +1349,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeA,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				A: ip.To4(),
 			}, nil
@@ -311,7 +1366,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeAAAA,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				AAAA: ip.To16(),
 			}, nil
@@ -324,7 +1379,20 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeCNAME,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
+				},
+				Target: dns.Fqdn(record.Target),
+			}, nil
+		}
+
+	case models.RecordTypeDNAME:
+		if qtype == dns.TypeDNAME {
+			return &dns.DNAME{
+				Hdr: dns.RR_Header{
+					Name:   dns.Fqdn(record.Name),
+					Rrtype: dns.TypeDNAME,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
 				},
 				Target: dns.Fqdn(record.Target),
 			}, nil
@@ -337,9 +1405,9 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeTXT,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
-				Txt: []string{record.Target},
+				Txt: txtStrings(record.Target),
 			}, nil
 		}
 
@@ -350,7 +1418,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeMX,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				Mx:         dns.Fqdn(record.Target),
 				Preference: uint16(record.Priority),
@@ -364,7 +1432,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeNS,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				Ns: dns.Fqdn(record.Target),
 			}, nil
@@ -377,7 +1445,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeSOA,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				Ns:      dns.Fqdn(record.Target),
 				Mbox:    dns.Fqdn(record.Mbox),
@@ -396,7 +1464,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypePTR,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				Ptr: dns.Fqdn(record.Target),
 			}, nil
@@ -409,7 +1477,7 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 					Name:   dns.Fqdn(record.Name),
 					Rrtype: dns.TypeSRV,
 					Class:  dns.ClassINET,
-					Ttl:    record.TTL,
+					Ttl:    ttl,
 				},
 				Priority: uint16(record.Priority),
 				Weight:   uint16(record.Weight),
@@ -417,36 +1485,224 @@ func (s *Server) createResourceRecord(record *models.DNSRecord, qtype uint16) (d
 				Target:   dns.Fqdn(record.Target),
 			}, nil
 		}
+
+	case models.RecordTypeDS, models.RecordTypeCDS:
+		if rrtype := dns.StringToType[record.RecordType]; qtype == rrtype {
+			data, err := models.ParseDSData(record.Target, rrtype == dns.TypeCDS)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s data: %s: %w", record.RecordType, record.Target, err)
+			}
+			ds := dns.DS{
+				Hdr: dns.RR_Header{
+					Name:   dns.Fqdn(record.Name),
+					Rrtype: rrtype,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
+				},
+				KeyTag:     data.KeyTag,
+				Algorithm:  data.Algorithm,
+				DigestType: data.DigestType,
+				Digest:     data.Digest,
+			}
+			if rrtype == dns.TypeCDS {
+				return &dns.CDS{DS: ds}, nil
+			}
+			return &ds, nil
+		}
+
+	case models.RecordTypeDNSKEY, models.RecordTypeCDNSKEY:
+		if rrtype := dns.StringToType[record.RecordType]; qtype == rrtype {
+			data, err := models.ParseDNSKEYData(record.Target, rrtype == dns.TypeCDNSKEY)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s data: %s: %w", record.RecordType, record.Target, err)
+			}
+			key := dns.DNSKEY{
+				Hdr: dns.RR_Header{
+					Name:   dns.Fqdn(record.Name),
+					Rrtype: rrtype,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
+				},
+				Flags:     data.Flags,
+				Protocol:  data.Protocol,
+				Algorithm: data.Algorithm,
+				PublicKey: data.PublicKey,
+			}
+			if rrtype == dns.TypeCDNSKEY {
+				return &dns.CDNSKEY{DNSKEY: key}, nil
+			}
+			return &key, nil
+		}
+
+	case models.RecordTypeHINFO:
+		if qtype == dns.TypeHINFO {
+			data, err := models.ParseHINFOData(record.Target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid HINFO data: %s: %w", record.Target, err)
+			}
+			return &dns.HINFO{
+				Hdr: dns.RR_Header{
+					Name:   dns.Fqdn(record.Name),
+					Rrtype: dns.TypeHINFO,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
+				},
+				Cpu: models.EscapeCharacterString(data.CPU),
+				Os:  models.EscapeCharacterString(data.OS),
+			}, nil
+		}
+
+	case models.RecordTypeLOC:
+		if qtype == dns.TypeLOC {
+			data, err := models.ParseLOCData(record.Target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid LOC data: %s: %w", record.Target, err)
+			}
+			return &dns.LOC{
+				Hdr: dns.RR_Header{
+					Name:   dns.Fqdn(record.Name),
+					Rrtype: dns.TypeLOC,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
+				},
+				Size:      data.Size,
+				HorizPre:  data.HorizPre,
+				VertPre:   data.VertPre,
+				Latitude:  data.Latitude,
+				Longitude: data.Longitude,
+				Altitude:  data.Altitude,
+			}, nil
+		}
+
+	default:
+		if code, ok := recordType.GenericCode(); ok && qtype == code {
+			data, err := models.ParseGenericData(record.Target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s data: %w", record.RecordType, err)
+			}
+			return &dns.RFC3597{
+				Hdr: dns.RR_Header{
+					Name:   dns.Fqdn(record.Name),
+					Rrtype: code,
+					Class:  dns.ClassINET,
+					Ttl:    ttl,
+				},
+				Rdata: hex.EncodeToString(data),
+			}, nil
+		}
 	}
 
 	// No matching record type for the query
 	return nil, nil
 }
 
+// maxTXTString is the most octets in one string of a TXT record
+const maxTXTString = 255
+
+// txtStrings splits TXT data into strings of at most maxTXTString octets
+// on the wire. An escape (\X or \DDD) is one octet and is never split
+func txtStrings(text string) []string {
+	var strs []string
+	start, octets := 0, 0
+	for i := 0; i < len(text); {
+		if octets == maxTXTString {
+			strs = append(strs, text[start:i])
+			start, octets = i, 0
+		}
+
+		width := 1
+		if text[i] == '\\' && i+1 < len(text) {
+			width = 2
+			if i+3 < len(text) && isDigit(text[i+1]) && isDigit(text[i+2]) && isDigit(text[i+3]) {
+				width = 4
+			}
+		}
+		i += width
+		octets++
+	}
+	return append(strs, text[start:])
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// answersRRset reports whether queries for qtype are answered with every
+// record of the RRset rather than one selected record. Resolvers validate
+// DS and DNSKEY RRsets as a whole
+func answersRRset(qtype uint16) bool {
+	switch qtype {
+	case dns.TypeSRV, dns.TypeMX, dns.TypeNS, dns.TypeDS, dns.TypeDNSKEY, dns.TypeCDS, dns.TypeCDNSKEY:
+		return true
+	}
+	return false
+}
+
+// StoredType returns the record type of the stored records answering
+// qtype: its name if it's supported natively, and TYPEnnn otherwise
+func StoredType(qtype uint16) string {
+	if name := dns.TypeToString[qtype]; models.RecordType(name).IsNative() {
+		return name
+	}
+	return string(models.GenericRecordType(qtype))
+}
+
+// RecordQtype returns the query type a stored record type answers, or 0
+// if it's unknown
+func RecordQtype(recordType string) uint16 {
+	if code, ok := models.RecordType(recordType).GenericCode(); ok {
+		return code
+	}
+	return dns.StringToType[recordType]
+}
+
+// applyTTLs gives an answer RRset one TTL, its lowest, and applies any TTL
+// overrides, then scales its TTLs by a single random factor within
+// +/- ttlJitter so large client populations don't expire in lockstep. The
+// same factor is used for every RR so the RRset keeps a uniform TTL.
+func (s *Server) applyTTLs(rrs []dns.RR) {
+	uniformTTLs(rrs)
+	s.ttls.Apply(rrs)
+	if s.ttlJitter <= 0 || len(rrs) == 0 {
+		return
+	}
+
+	factor := 1 + (rand.Float64()*2-1)*s.ttlJitter
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		if hdr.Ttl == 0 {
+			continue // zero TTL means "do not cache" and must stay that way
+		}
+		// Jittering up can push TTLs near the limit past it
+		ttl := min(float64(hdr.Ttl)*factor, math.MaxUint32)
+		hdr.Ttl = max(uint32(ttl), 1)
+	}
+}
+
 // updateTypeStats updates query type statistics
 func (s *Server) updateTypeStats(qtype uint16) {
 	switch qtype {
 	case dns.TypeA:
-		s.stats.TypeA++
+		atomic.AddInt64(&s.stats.TypeA, 1)
 	case dns.TypeAAAA:
-		s.stats.TypeAAAA++
+		atomic.AddInt64(&s.stats.TypeAAAA, 1)
 	case dns.TypeCNAME:
-		s.stats.TypeCNAME++
+		atomic.AddInt64(&s.stats.TypeCNAME, 1)
 	case dns.TypeMX:
-		s.stats.TypeMX++
+		atomic.AddInt64(&s.stats.TypeMX, 1)
 	case dns.TypeTXT:
-		s.stats.TypeTXT++
+		atomic.AddInt64(&s.stats.TypeTXT, 1)
 	case dns.TypeNS:
-		s.stats.TypeNS++
+		atomic.AddInt64(&s.stats.TypeNS, 1)
 	case dns.TypeSRV:
-		s.stats.TypeSRV++
+		atomic.AddInt64(&s.stats.TypeSRV, 1)
 	case dns.TypeSOA:
-		s.stats.TypeSOA++
+		atomic.AddInt64(&s.stats.TypeSOA, 1)
 	case dns.TypePTR:
-		s.stats.TypePTR++
+		atomic.AddInt64(&s.stats.TypePTR, 1)
 	case dns.TypeCAA:
-		s.stats.TypeCAA++
+		atomic.AddInt64(&s.stats.TypeCAA, 1)
 	default:
-		s.stats.TypeOther++
+		atomic.AddInt64(&s.stats.TypeOther, 1)
 	}
 }
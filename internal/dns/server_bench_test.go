@@ -0,0 +1,144 @@
+// internal/dns/server_bench_test.go
+package dns
+
+// These benchmarks measure the query handler alone, with no network or
+// database. Logs go to a temporary directory at WARN, or at the level named
+// by ERRANTDNS_BENCH_LOG_LEVEL:
+//
+//	go test ./internal/dns -run '^$' -bench HandleDNSRequest -benchmem -benchtime 200000x -count 3
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/cache"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/storage"
+)
+
+var benchLoggingOnce sync.Once
+
+// benchLogging sends the benchmark's logs to a temporary directory, once
+// per process since the logger can only be initialized once
+func benchLogging(b *testing.B) {
+	b.Helper()
+
+	benchLoggingOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "errantdns-bench-logs")
+		if err != nil {
+			b.Fatalf("failed to create log directory: %v", err)
+		}
+
+		config := logging.DefaultConfig()
+		config.Directory = dir
+		config.EnableConsole = false
+		config.Level = logging.LevelWarn
+		if level := os.Getenv("ERRANTDNS_BENCH_LOG_LEVEL"); level != "" {
+			config.Level = logging.LogLevel(level)
+		}
+		if err := logging.Initialize(config); err != nil {
+			b.Fatalf("failed to initialize logging: %v", err)
+		}
+	})
+}
+
+// benchStorage answers every lookup with one A record for www.example.com.
+// Anything else it's asked for panics through the nil embedded Storage
+type benchStorage struct {
+	storage.Storage
+	record *models.DNSRecord
+}
+
+func (s *benchStorage) lookup(query *models.LookupQuery) []*models.DNSRecord {
+	if query.Name != s.record.Name || query.Type.String() != s.record.RecordType {
+		return nil
+	}
+	return []*models.DNSRecord{s.record}
+}
+
+func (s *benchStorage) LookupRecord(ctx context.Context, query *models.LookupQuery) (*models.DNSRecord, error) {
+	if records := s.lookup(query); len(records) > 0 {
+		return records[0], nil
+	}
+	return nil, nil
+}
+
+func (s *benchStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	return s.lookup(query), nil
+}
+
+func (s *benchStorage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	return s.lookup(query), nil
+}
+
+// discardWriter is a ResponseWriter that drops every response
+type discardWriter struct {
+	local, remote net.Addr
+}
+
+func newDiscardWriter() *discardWriter {
+	return &discardWriter{
+		local:  &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53},
+		remote: &net.UDPAddr{IP: net.IPv4(192, 0, 2, 10), Port: 40000},
+	}
+}
+
+func (w *discardWriter) LocalAddr() net.Addr         { return w.local }
+func (w *discardWriter) RemoteAddr() net.Addr        { return w.remote }
+func (w *discardWriter) WriteMsg(*dns.Msg) error     { return nil }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) Close() error                { return nil }
+func (w *discardWriter) TsigStatus() error           { return nil }
+func (w *discardWriter) TsigTimersOnly(bool)         {}
+func (w *discardWriter) Hijack()                     {}
+
+// benchServer builds a server over benchStorage with the given config
+func benchServer(b *testing.B, config *Config) *Server {
+	b.Helper()
+	benchLogging(b)
+
+	record := &models.DNSRecord{Name: "www.example.com", RecordType: "A", Target: "192.0.2.1", TTL: 300, Priority: 10}
+	return NewServer(&benchStorage{record: record}, config)
+}
+
+// BenchmarkHandleDNSRequest resolves a single A question through the
+// default middleware chain, with and without the packed-response cache
+func BenchmarkHandleDNSRequest(b *testing.B) {
+	for _, bench := range []struct {
+		name      string
+		wireCache bool
+	}{
+		{"resolve", false},
+		{"wirecache", true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			config := DefaultConfig()
+			if bench.wireCache {
+				config.WireCache = cache.NewWireCache(&cache.WireConfig{MaxEntries: 1024, MaxTTL: time.Hour})
+			}
+			s := benchServer(b, config)
+			w := newDiscardWriter()
+			req := new(dns.Msg)
+			req.SetQuestion("www.example.com.", dns.TypeA)
+
+			// The first query fills the cache when there is one
+			s.handleDNSRequest(w, req)
+			if s.stats.QueriesAnswered == 0 {
+				b.Fatal("the stub record was not answered")
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.handleDNSRequest(w, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// internal/dns/transfer.go
+package dns
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// transferChunkSize is the number of records sent per AXFR message
+const transferChunkSize = 100
+
+// RecordLister enumerates stored records for zone transfers
+type RecordLister interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+}
+
+// ZoneTransfer allows clients in ACL to AXFR any zone we hold an SOA for
+type ZoneTransfer struct {
+	Lister RecordLister
+	ACL    *forwarder.ACL
+}
+
+// CatalogZone answers queries and transfers for a catalog zone
+type CatalogZone interface {
+	dns.Handler
+	Zone() string
+}
+
+// isTransfer reports whether r asks for a zone transfer
+func isTransfer(r *dns.Msg) bool {
+	if len(r.Question) != 1 {
+		return false
+	}
+	qtype := r.Question[0].Qtype
+	return qtype == dns.TypeAXFR || qtype == dns.TypeIXFR
+}
+
+// handleTransfer answers an AXFR, or an IXFR with a full transfer, over TCP
+// for clients in the transfer ACL
+func (s *Server) handleTransfer(w dns.ResponseWriter, r *dns.Msg, client net.IP) {
+	_, isTCP := w.RemoteAddr().(*net.TCPAddr)
+	if s.transfer == nil || !isTCP || !s.transfer.ACL.Contains(client) {
+		s.writeRcode(w, r, dns.RcodeRefused, &extendedError{dns.ExtendedErrorCodeProhibited, edeTextNoTransfer})
+		return
+	}
+
+	zone := models.NormalizeDomainName(r.Question[0].Name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	records, err := s.zoneRecords(ctx, zone)
+	if err != nil {
+		logging.Error("dns", "Failed to load zone for transfer", err, "zone", zone)
+		s.writeRcode(w, r, dns.RcodeServerFailure, lookupError(ctx, err))
+		return
+	}
+	if records == nil {
+		s.writeRcode(w, r, dns.RcodeNotAuth, nil)
+		return
+	}
+
+	ch := make(chan *dns.Envelope)
+	tr := new(dns.Transfer)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := tr.Out(w, r, ch); err != nil {
+			logging.Warn("dns", "Zone transfer failed", "zone", zone, "client", client.String(), "error", err)
+		}
+	}()
+
+	for start := 0; start < len(records); start += transferChunkSize {
+		end := min(start+transferChunkSize, len(records))
+		ch <- &dns.Envelope{RR: records[start:end]}
+	}
+	close(ch)
+	wg.Wait()
+	w.Close()
+
+	logging.Info("dns", "Zone transferred", "zone", zone, "client", client.String(), "records", len(records)-1)
+}
+
+// zoneRecords returns a zone's live records framed by its SOA, or nil if
+// we hold no SOA for the zone. Records belonging to child zones with their
+// own SOA are left out
+func (s *Server) zoneRecords(ctx context.Context, zone string) ([]dns.RR, error) {
+	stored, err := s.transfer.Lister.ListRecords(ctx, &models.RecordFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	apexes := make(map[string]bool)
+	var soa *models.DNSRecord
+	for _, record := range stored {
+		if record.RecordType != string(models.RecordTypeSOA) || !record.IsActive(now) {
+			continue
+		}
+		name := models.NormalizeDomainName(record.Name)
+		apexes[name] = true
+		if name == zone && (soa == nil || record.Priority < soa.Priority) {
+			soa = record
+		}
+	}
+	if soa == nil {
+		return nil, nil
+	}
+
+	soaRR, err := ResourceRecord(soa, dns.TypeSOA)
+	if err != nil || soaRR == nil {
+		return nil, err
+	}
+
+	records := []dns.RR{soaRR}
+	for _, record := range stored {
+		if record == soa || !record.IsActive(now) {
+			continue
+		}
+		name := models.NormalizeDomainName(record.Name)
+		if record.RecordType == string(models.RecordTypeSOA) || enclosingZone(name, apexes) != zone {
+			continue
+		}
+
+		rr, err := ResourceRecord(record, RecordQtype(record.RecordType))
+		if err != nil || rr == nil {
+			logging.Debug("dns", "Record left out of zone transfer", "zone", zone, "name", record.Name, "type", record.RecordType, "error", err)
+			continue
+		}
+		records = append(records, rr)
+	}
+
+	return append(records, soaRR), nil
+}
+
+// enclosingZone returns the closest apex at or above name
+func enclosingZone(name string, apexes map[string]bool) string {
+	for {
+		if apexes[name] {
+			return name
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			return ""
+		}
+		name = name[dot+1:]
+	}
+}
+
+// writeRcode replies to r with an empty response carrying rcode and, for
+// EDNS clients, ede when set
+func (s *Server) writeRcode(w dns.ResponseWriter, r *dns.Msg, rcode int, ede *extendedError) {
+	msg := acquireMsg()
+	defer releaseMsg(msg)
+	msg.SetRcode(r, rcode)
+	setExtendedError(msg, r, ede)
+	if err := writeMsg(w, msg); err != nil {
+		logging.Error("dns", "Failed to write DNS response: %v", nil, err)
+	}
+}
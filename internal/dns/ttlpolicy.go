@@ -0,0 +1,159 @@
+// internal/dns/ttlpolicy.go
+package dns
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TTLPolicy overrides the TTLs of answers per zone at answer time, leaving
+// stored records alone. It's for zones whose source of records sets TTLs
+// we can't change
+type TTLPolicy struct {
+	rules []ttlRule
+}
+
+// ttlRule clamps the TTLs of names matching zone to [min, max]. A fixed
+// override has min == max
+type ttlRule struct {
+	zone       string
+	subdomains bool // "*.zone": names below zone but not zone itself
+	min, max   uint32
+}
+
+// NewTTLPolicy parses rules of the form "zone=TTL" or "zone=MIN-MAX",
+// either bound of a range being optional, e.g. "example.com=300",
+// "cdn.example.com=30-3600", or "*.example.net=-600". A zone covers its
+// subdomains; "*.zone" covers only the subdomains. The rule for the most
+// specific matching zone wins
+func NewTTLPolicy(rules []string) (*TTLPolicy, error) {
+	policy := &TTLPolicy{}
+	for _, rule := range rules {
+		zone, value, found := strings.Cut(rule, "=")
+		zone = strings.ToLower(strings.TrimSpace(zone))
+		value = strings.TrimSpace(value)
+		if !found || zone == "" || value == "" {
+			return nil, fmt.Errorf("invalid TTL override %q: expected zone=TTL or zone=MIN-MAX", rule)
+		}
+
+		parsed := ttlRule{max: ^uint32(0)}
+		if rest, ok := strings.CutPrefix(zone, "*."); ok {
+			zone, parsed.subdomains = rest, true
+		}
+		if _, ok := dns.IsDomainName(zone); !ok {
+			return nil, fmt.Errorf("invalid TTL override %q: invalid zone %q", rule, zone)
+		}
+		parsed.zone = dns.Fqdn(zone)
+
+		low, high, isRange := strings.Cut(value, "-")
+		var err error
+		if !isRange {
+			if parsed.min, err = parseTTL(value); err != nil {
+				return nil, fmt.Errorf("invalid TTL override %q: %w", rule, err)
+			}
+			parsed.max = parsed.min
+		} else {
+			if low != "" {
+				if parsed.min, err = parseTTL(low); err != nil {
+					return nil, fmt.Errorf("invalid TTL override %q: %w", rule, err)
+				}
+			}
+			if high != "" {
+				if parsed.max, err = parseTTL(high); err != nil {
+					return nil, fmt.Errorf("invalid TTL override %q: %w", rule, err)
+				}
+			}
+			if low == "" && high == "" {
+				return nil, fmt.Errorf("invalid TTL override %q: empty range", rule)
+			}
+			if parsed.min > parsed.max {
+				return nil, fmt.Errorf("invalid TTL override %q: minimum exceeds maximum", rule)
+			}
+		}
+
+		policy.rules = append(policy.rules, parsed)
+	}
+	return policy, nil
+}
+
+// parseTTL parses a TTL in seconds
+func parseTTL(value string) (uint32, error) {
+	ttl, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid TTL %q", value)
+	}
+	return uint32(ttl), nil
+}
+
+// rule returns the most specific rule for name, or nil if none matches
+func (p *TTLPolicy) rule(name string) *ttlRule {
+	name = strings.ToLower(dns.Fqdn(name))
+	var best *ttlRule
+	depth := -1
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !dns.IsSubDomain(rule.zone, name) {
+			continue
+		}
+		if rule.subdomains && dns.CountLabel(name) == dns.CountLabel(rule.zone) {
+			continue
+		}
+		// A wildcard rule is more specific than its zone's own rule
+		labels := 2 * dns.CountLabel(rule.zone)
+		if rule.subdomains {
+			labels++
+		}
+		if labels > depth {
+			best, depth = rule, labels
+		}
+	}
+	return best
+}
+
+// Apply overrides the TTLs of rrs whose owner names match a rule. Records
+// of an RRset share an owner name, so the RRset keeps a uniform TTL
+func (p *TTLPolicy) Apply(rrs []dns.RR) {
+	if p == nil || len(p.rules) == 0 {
+		return
+	}
+
+	var name string
+	var rule *ttlRule
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		if hdr.Name != name {
+			name, rule = hdr.Name, p.rule(hdr.Name)
+		}
+		if rule != nil {
+			hdr.Ttl = min(max(hdr.Ttl, rule.min), rule.max)
+		}
+	}
+}
+
+// uniformTTLs gives every RRset in rrs the lowest TTL among its records, as
+// RFC 2181 section 5.2 requires of records stored with different TTLs
+func uniformTTLs(rrs []dns.RR) {
+	if len(rrs) < 2 {
+		return
+	}
+
+	type rrsetKey struct {
+		name  string
+		rtype uint16
+	}
+	lowest := make(map[rrsetKey]uint32, len(rrs))
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		key := rrsetKey{strings.ToLower(hdr.Name), hdr.Rrtype}
+		if ttl, ok := lowest[key]; !ok || hdr.Ttl < ttl {
+			lowest[key] = hdr.Ttl
+		}
+	}
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		hdr.Ttl = lowest[rrsetKey{strings.ToLower(hdr.Name), hdr.Rrtype}]
+	}
+}
@@ -0,0 +1,148 @@
+// internal/dockerwatch/client.go
+package dockerwatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiVersion is the Docker Engine API version requested; every engine
+// since 20.10 serves it
+const apiVersion = "v1.41"
+
+// dockerClient talks to the Docker Engine API over its unix socket
+type dockerClient struct {
+	http *http.Client
+}
+
+// container is the part of a container inspection the watcher uses
+type container struct {
+	ID     string `json:"Id"`
+	Name   string `json:"Name"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	State struct {
+		Running bool `json:"Running"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string `json:"IPAddress"`
+			GlobalIPv6Address string `json:"GlobalIPv6Address"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// event is a container event from the events stream
+type event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID string `json:"ID"`
+	} `json:"Actor"`
+}
+
+// newDockerClient creates a client for the engine listening on socket
+func newDockerClient(socket string) *dockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &dockerClient{http: &http.Client{Transport: transport}}
+}
+
+// get sends a GET request for path and returns the response body
+func (c *dockerClient) get(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	u := "http://docker/" + apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp.Body, nil
+}
+
+// runningWithLabel returns the IDs of running containers carrying label
+func (c *dockerClient) runningWithLabel(ctx context.Context, label string) ([]string, error) {
+	filters, _ := json.Marshal(map[string][]string{"label": {label}, "status": {"running"}})
+	body, err := c.get(ctx, "/containers/json", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var summaries []struct {
+		ID string `json:"Id"`
+	}
+	if err := json.NewDecoder(body).Decode(&summaries); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %w", err)
+	}
+
+	ids := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		ids = append(ids, summary.ID)
+	}
+	return ids, nil
+}
+
+// inspect returns the details of the container with id
+func (c *dockerClient) inspect(ctx context.Context, id string) (*container, error) {
+	body, err := c.get(ctx, "/containers/"+url.PathEscape(id)+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var details container
+	if err := json.NewDecoder(body).Decode(&details); err != nil {
+		return nil, fmt.Errorf("failed to decode container %s: %w", id, err)
+	}
+	return &details, nil
+}
+
+// events streams container start and stop events to handle until the
+// stream ends or ctx is cancelled
+func (c *dockerClient) events(ctx context.Context, label string, handle func(event)) error {
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die", "stop", "destroy"},
+		"label": {label},
+	})
+	body, err := c.get(ctx, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var e event
+		if err := decoder.Decode(&e); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("events stream ended: %w", err)
+		}
+		handle(e)
+	}
+}
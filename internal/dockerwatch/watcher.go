@@ -0,0 +1,326 @@
+// internal/dockerwatch/watcher.go
+package dockerwatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// Owner is the owner of every record the watcher manages. Records are tied
+// to their container by ContainerLabel
+const (
+	Owner          = "docker"
+	ContainerLabel = "errantdns.io/container"
+)
+
+// Container labels the watcher reads, after the configured prefix:
+//
+//	<prefix>.hostname  name of the A/AAAA records (required)
+//	<prefix>.ttl       record TTL in seconds
+//	<prefix>.network   network whose addresses are registered (default: all)
+//	<prefix>.port      registers an SRV record for this port
+//	<prefix>.service   SRV service name (default "http")
+//	<prefix>.protocol  SRV protocol (default "tcp")
+const (
+	labelHostname = "hostname"
+	labelTTL      = "ttl"
+	labelNetwork  = "network"
+	labelPort     = "port"
+	labelService  = "service"
+	labelProtocol = "protocol"
+)
+
+// retryDelay is how long the watcher waits after losing the events stream
+const retryDelay = 5 * time.Second
+
+// RecordLister enumerates stored records
+type RecordLister interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+}
+
+// ChangesetApplier applies a batch of record changes atomically
+type ChangesetApplier interface {
+	Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error)
+}
+
+// Watcher registers A/AAAA and SRV records for running containers that
+// carry a hostname label, and removes them when the containers stop
+type Watcher struct {
+	client     *dockerClient
+	lister     RecordLister
+	applier    ChangesetApplier
+	prefix     string
+	defaultTTL uint32
+}
+
+// NewWatcher creates a watcher for the Docker engine listening on socket,
+// reading labels under prefix (e.g. "errantdns")
+func NewWatcher(lister RecordLister, applier ChangesetApplier, socket, prefix string, defaultTTL uint32) *Watcher {
+	return &Watcher{
+		client:     newDockerClient(socket),
+		lister:     lister,
+		applier:    applier,
+		prefix:     prefix,
+		defaultTTL: defaultTTL,
+	}
+}
+
+// label returns the full name of a container label
+func (w *Watcher) label(name string) string {
+	return w.prefix + "." + name
+}
+
+// Run follows container events until ctx is cancelled. Each time the
+// events stream (re)connects, every running container is registered again
+// and records of containers that are gone are removed
+func (w *Watcher) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		streamCtx, cancel := context.WithCancel(ctx)
+		events := make(chan event, 64)
+		streamErr := make(chan error, 1)
+		go func() {
+			streamErr <- w.client.events(streamCtx, w.label(labelHostname), func(e event) {
+				select {
+				case events <- e:
+				case <-streamCtx.Done():
+				}
+			})
+		}()
+
+		if err := w.syncAll(ctx); err != nil {
+			logging.Warn("docker", "Failed to register running containers", "error", err)
+		}
+
+	follow:
+		for {
+			select {
+			case e := <-events:
+				w.handle(ctx, e)
+			case err := <-streamErr:
+				if err != nil && ctx.Err() == nil {
+					logging.Warn("docker", "Lost Docker events stream", "error", err)
+				}
+				break follow
+			case <-ctx.Done():
+				break follow
+			}
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+// handle registers or removes a container's records for one event
+func (w *Watcher) handle(ctx context.Context, e event) {
+	var err error
+	switch e.Action {
+	case "start":
+		err = w.register(ctx, e.Actor.ID)
+	case "die", "stop", "destroy":
+		err = w.sync(ctx, e.Actor.ID, nil)
+	}
+	if err != nil {
+		logging.Warn("docker", "Failed to update container records", "container", shortID(e.Actor.ID), "event", e.Action, "error", err)
+	}
+}
+
+// syncAll registers every running container and removes the records of
+// containers that aren't running
+func (w *Watcher) syncAll(ctx context.Context) error {
+	ids, err := w.client.runningWithLabel(ctx, w.label(labelHostname))
+	if err != nil {
+		return err
+	}
+
+	running := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		running[id] = true
+		if err := w.register(ctx, id); err != nil {
+			logging.Warn("docker", "Failed to register container", "container", shortID(id), "error", err)
+		}
+	}
+
+	records, err := w.lister.ListRecords(ctx, &models.RecordFilter{Owner: Owner})
+	if err != nil {
+		return err
+	}
+	var changes []models.Change
+	for _, record := range records {
+		if !running[record.Labels[ContainerLabel]] {
+			changes = append(changes, models.Change{Action: models.ChangeDelete, ID: record.ID})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	_, err = w.applier.Apply(ctx, changes)
+	return err
+}
+
+// register stores the records a container's labels declare
+func (w *Watcher) register(ctx context.Context, id string) error {
+	details, err := w.client.inspect(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !details.State.Running {
+		return w.sync(ctx, id, nil)
+	}
+
+	records, err := w.records(details)
+	if err != nil {
+		return err
+	}
+	if err := w.sync(ctx, id, records); err != nil {
+		return err
+	}
+
+	logging.Info("docker", "Registered container", "container", strings.TrimPrefix(details.Name, "/"),
+		"hostname", details.Config.Labels[w.label(labelHostname)], "records", len(records))
+	return nil
+}
+
+// records builds and validates the records a container declares
+func (w *Watcher) records(details *container) ([]*models.DNSRecord, error) {
+	labels := details.Config.Labels
+	hostname := labels[w.label(labelHostname)]
+	if hostname == "" {
+		return nil, fmt.Errorf("label %s is empty", w.label(labelHostname))
+	}
+
+	ttl := w.defaultTTL
+	if value := labels[w.label(labelTTL)]; value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label %s: %q", w.label(labelTTL), value)
+		}
+		ttl = uint32(parsed)
+	}
+
+	var records []*models.DNSRecord
+	add := func(record *models.DNSRecord) {
+		record.TTL = ttl
+		record.Owner = Owner
+		record.Labels = map[string]string{ContainerLabel: details.ID}
+		records = append(records, record)
+	}
+
+	// Sort networks so the records don't churn between inspections
+	network := labels[w.label(labelNetwork)]
+	names := make([]string, 0, len(details.NetworkSettings.Networks))
+	for name := range details.NetworkSettings.Networks {
+		if network == "" || name == network {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		settings := details.NetworkSettings.Networks[name]
+		if settings.IPAddress != "" {
+			add(&models.DNSRecord{Name: hostname, RecordType: string(models.RecordTypeA), Target: settings.IPAddress})
+		}
+		if settings.GlobalIPv6Address != "" {
+			add(&models.DNSRecord{Name: hostname, RecordType: string(models.RecordTypeAAAA), Target: settings.GlobalIPv6Address})
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("container has no address on network %q", network)
+	}
+
+	if value := labels[w.label(labelPort)]; value != "" {
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid label %s: %q", w.label(labelPort), value)
+		}
+		service, protocol := labels[w.label(labelService)], labels[w.label(labelProtocol)]
+		if service == "" {
+			service = "http"
+		}
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		add(&models.DNSRecord{
+			Name:       "_" + service + "._" + protocol + "." + hostname,
+			RecordType: string(models.RecordTypeSRV),
+			Target:     hostname,
+			Port:       uint16(port),
+		})
+	}
+
+	for _, record := range records {
+		if err := record.Validate(); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", record.Name, record.RecordType, err)
+		}
+		record.Normalize()
+	}
+	return records, nil
+}
+
+// sync replaces the stored records of container id with desired, unless
+// they already match
+func (w *Watcher) sync(ctx context.Context, id string, desired []*models.DNSRecord) error {
+	stored, err := w.lister.ListRecords(ctx, &models.RecordFilter{
+		Owner:  Owner,
+		Labels: map[string]string{ContainerLabel: id},
+	})
+	if err != nil {
+		return err
+	}
+	if sameRecords(stored, desired) {
+		return nil
+	}
+
+	changes := make([]models.Change, 0, len(stored)+len(desired))
+	for _, record := range stored {
+		changes = append(changes, models.Change{Action: models.ChangeDelete, ID: record.ID})
+	}
+	for _, record := range desired {
+		changes = append(changes, models.Change{Action: models.ChangeCreate, Record: record})
+	}
+	_, err = w.applier.Apply(ctx, changes)
+	return err
+}
+
+// sameRecords reports whether a and b declare the same records
+func sameRecords(a, b []*models.DNSRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, record := range a {
+		counts[identity(record)]++
+	}
+	for _, record := range b {
+		key := identity(record)
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+// identity is what makes two records the same declaration
+func identity(record *models.DNSRecord) string {
+	return fmt.Sprintf("%s|%s|%s|%d|%d", models.NormalizeDomainName(record.Name), record.RecordType,
+		models.NormalizeDomainName(record.Target), record.TTL, record.Port)
+}
+
+// shortID abbreviates a container ID the way the Docker CLI does
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,301 @@
+// internal/externaldns/provider.go
+package externaldns
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"errantdns.io/internal/models"
+)
+
+// Endpoint is a DNS name and type with all of its targets, as exchanged
+// with external-dns
+type Endpoint struct {
+	DNSName          string                     `json:"dnsName"`
+	Targets          []string                   `json:"targets"`
+	RecordType       string                     `json:"recordType"`
+	SetIdentifier    string                     `json:"setIdentifier,omitempty"`
+	RecordTTL        int64                      `json:"recordTTL,omitempty"`
+	Labels           map[string]string          `json:"labels,omitempty"`
+	ProviderSpecific []ProviderSpecificProperty `json:"providerSpecific,omitempty"`
+}
+
+// ProviderSpecificProperty is a provider-specific endpoint option. None are
+// supported; they're accepted and ignored
+type ProviderSpecificProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Changes is a plan external-dns asks the provider to apply. UpdateOld and
+// UpdateNew hold the before and after of each updated endpoint
+type Changes struct {
+	Create    []*Endpoint `json:"Create"`
+	UpdateOld []*Endpoint `json:"UpdateOld"`
+	UpdateNew []*Endpoint `json:"UpdateNew"`
+	Delete    []*Endpoint `json:"Delete"`
+}
+
+// DomainFilter tells external-dns which domains the provider manages
+type DomainFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// supportedTypes are the record types external-dns may manage
+var supportedTypes = map[string]bool{
+	"A": true, "AAAA": true, "CNAME": true, "TXT": true,
+	"MX": true, "SRV": true, "NS": true, "PTR": true,
+}
+
+// RecordLister enumerates stored records
+type RecordLister interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+}
+
+// ChangesetApplier applies a batch of record changes atomically
+type ChangesetApplier interface {
+	Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error)
+}
+
+// Provider maps external-dns endpoints onto stored records. Records it
+// creates carry Owner, and it never lists or changes records that don't,
+// so records managed by hand are left alone
+type Provider struct {
+	lister     RecordLister
+	applier    ChangesetApplier
+	owner      string
+	domains    []string
+	defaultTTL uint32
+}
+
+// NewProvider creates a provider managing records owned by owner under
+// domains (any domain if empty). Endpoints without a TTL get defaultTTL
+func NewProvider(lister RecordLister, applier ChangesetApplier, owner string, domains []string, defaultTTL uint32) *Provider {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		normalized = append(normalized, models.NormalizeDomainName(domain))
+	}
+	return &Provider{
+		lister:     lister,
+		applier:    applier,
+		owner:      owner,
+		domains:    normalized,
+		defaultTTL: defaultTTL,
+	}
+}
+
+// DomainFilter returns the domains the provider manages
+func (p *Provider) DomainFilter() DomainFilter {
+	return DomainFilter{Include: p.domains}
+}
+
+// Records returns the managed records as endpoints, one per name and type
+func (p *Provider) Records(ctx context.Context) ([]*Endpoint, error) {
+	records, err := p.lister.ListRecords(ctx, &models.RecordFilter{Owner: p.owner})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := []*Endpoint{}
+	byKey := make(map[string]*Endpoint)
+	for _, record := range records {
+		if !supportedTypes[record.RecordType] || !p.manages(record.Name) {
+			continue
+		}
+
+		key := models.NormalizeDomainName(record.Name) + "/" + record.RecordType
+		endpoint, ok := byKey[key]
+		if !ok {
+			endpoint = &Endpoint{
+				DNSName:    models.NormalizeDomainName(record.Name),
+				RecordType: record.RecordType,
+				RecordTTL:  int64(record.TTL),
+			}
+			byKey[key] = endpoint
+			endpoints = append(endpoints, endpoint)
+		}
+		endpoint.Targets = append(endpoint.Targets, formatTarget(record))
+	}
+	return endpoints, nil
+}
+
+// AdjustEndpoints normalizes desired endpoints the way Records will return
+// them, so external-dns doesn't plan updates that change nothing. Endpoints
+// of unsupported types are dropped
+func (p *Provider) AdjustEndpoints(endpoints []*Endpoint) []*Endpoint {
+	adjusted := make([]*Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		endpoint.RecordType = strings.ToUpper(endpoint.RecordType)
+		if !supportedTypes[endpoint.RecordType] {
+			continue
+		}
+		endpoint.DNSName = models.NormalizeDomainName(endpoint.DNSName)
+		if endpoint.RecordTTL <= 0 {
+			endpoint.RecordTTL = int64(p.defaultTTL)
+		}
+		adjusted = append(adjusted, endpoint)
+	}
+	return adjusted
+}
+
+// ApplyChanges applies a plan as one changeset: the records of deleted
+// endpoints and the old side of updates are removed, then the records of
+// created endpoints and the new side of updates are stored
+func (p *Provider) ApplyChanges(ctx context.Context, plan *Changes) error {
+	var changes []models.Change
+
+	for _, endpoint := range append(append([]*Endpoint{}, plan.Delete...), plan.UpdateOld...) {
+		ids, err := p.storedIDs(ctx, endpoint)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			changes = append(changes, models.Change{Action: models.ChangeDelete, ID: id})
+		}
+	}
+
+	for _, endpoint := range append(append([]*Endpoint{}, plan.Create...), plan.UpdateNew...) {
+		records, err := p.toRecords(endpoint)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			changes = append(changes, models.Change{Action: models.ChangeCreate, Record: record})
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	_, err := p.applier.Apply(ctx, changes)
+	return err
+}
+
+// storedIDs returns the IDs of the managed records for endpoint's targets
+func (p *Provider) storedIDs(ctx context.Context, endpoint *Endpoint) ([]int, error) {
+	wanted, err := p.toRecords(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := p.lister.ListRecords(ctx, &models.RecordFilter{
+		Name:       endpoint.DNSName,
+		RecordType: strings.ToUpper(endpoint.RecordType),
+		Owner:      p.owner,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, record := range stored {
+		for _, target := range wanted {
+			if formatTarget(record) == formatTarget(target) {
+				ids = append(ids, record.ID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// toRecords converts endpoint to one normalized record per target
+func (p *Provider) toRecords(endpoint *Endpoint) ([]*models.DNSRecord, error) {
+	recordType := strings.ToUpper(endpoint.RecordType)
+	if !supportedTypes[recordType] {
+		return nil, fmt.Errorf("%s %s: unsupported record type", endpoint.DNSName, endpoint.RecordType)
+	}
+	if !p.manages(endpoint.DNSName) {
+		return nil, fmt.Errorf("%s is outside the managed domains", endpoint.DNSName)
+	}
+	if endpoint.SetIdentifier != "" {
+		return nil, fmt.Errorf("%s %s: set identifiers are not supported", endpoint.DNSName, recordType)
+	}
+
+	ttl := p.defaultTTL
+	if endpoint.RecordTTL > 0 {
+		ttl = uint32(endpoint.RecordTTL)
+	}
+
+	records := make([]*models.DNSRecord, 0, len(endpoint.Targets))
+	for _, target := range endpoint.Targets {
+		record := &models.DNSRecord{
+			Name:       endpoint.DNSName,
+			RecordType: recordType,
+			Target:     target,
+			TTL:        ttl,
+			Owner:      p.owner,
+		}
+		if err := parseTarget(record); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", endpoint.DNSName, recordType, err)
+		}
+		record.Normalize()
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// manages reports whether name is at or under one of the managed domains
+func (p *Provider) manages(name string) bool {
+	if len(p.domains) == 0 {
+		return true
+	}
+	name = models.NormalizeDomainName(name)
+	for _, domain := range p.domains {
+		if name == domain || strings.HasSuffix(name, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTarget splits the external-dns target formats of MX ("preference
+// host") and SRV ("priority weight port host") into the record's fields
+func parseTarget(record *models.DNSRecord) error {
+	fields := strings.Fields(record.Target)
+	switch record.RecordType {
+	case "MX":
+		if len(fields) != 2 {
+			return fmt.Errorf("invalid MX target %q: expected \"preference host\"", record.Target)
+		}
+		preference, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid MX preference %q", fields[0])
+		}
+		record.Priority = int(preference)
+		record.Target = fields[1]
+	case "SRV":
+		if len(fields) != 4 {
+			return fmt.Errorf("invalid SRV target %q: expected \"priority weight port host\"", record.Target)
+		}
+		var values [3]uint64
+		for i := range values {
+			value, err := strconv.ParseUint(fields[i], 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid SRV target %q: %q is not a 16-bit number", record.Target, fields[i])
+			}
+			values[i] = value
+		}
+		record.Priority = int(values[0])
+		record.Weight = uint32(values[1])
+		record.Port = uint16(values[2])
+		record.Target = fields[3]
+	}
+	return nil
+}
+
+// formatTarget renders a record's target in the external-dns format
+func formatTarget(record *models.DNSRecord) string {
+	switch record.RecordType {
+	case "MX":
+		return fmt.Sprintf("%d %s", record.Priority, models.NormalizeDomainName(record.Target))
+	case "SRV":
+		return fmt.Sprintf("%d %d %d %s", record.Priority, record.Weight, record.Port, models.NormalizeDomainName(record.Target))
+	case "CNAME", "NS", "PTR":
+		return models.NormalizeDomainName(record.Target)
+	}
+	return record.Target
+}
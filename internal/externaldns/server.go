@@ -0,0 +1,145 @@
+// internal/externaldns/server.go
+package externaldns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// mediaType is the content type of the external-dns webhook protocol
+const mediaType = "application/external.dns.webhook+json;version=1"
+
+// maxBodyBytes bounds change and adjust requests
+const maxBodyBytes = 16 << 20
+
+// Server serves the external-dns webhook provider API:
+//
+//	GET  /                 negotiate: the domains the provider manages
+//	GET  /records          list managed records as endpoints
+//	POST /records          apply a plan of changes
+//	POST /adjustendpoints  normalize desired endpoints
+//	GET  /healthz          liveness
+//
+// external-dns runs with --provider=webhook and, by default, expects this
+// on localhost:8888
+type Server struct {
+	provider   *Provider
+	httpServer *http.Server
+	address    string
+}
+
+// NewServer creates a webhook server for provider listening on address
+func NewServer(provider *Provider, address string) *Server {
+	s := &Server{provider: provider, address: address}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleNegotiate)
+	mux.HandleFunc("GET /records", s.handleRecords)
+	mux.HandleFunc("POST /records", s.handleApplyChanges)
+	mux.HandleFunc("POST /adjustendpoints", s.handleAdjustEndpoints)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	s.httpServer = &http.Server{
+		Addr:         address,
+		Handler:      mux,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+	return s
+}
+
+// Start serves webhook requests until the context is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	logging.Info("externaldns", "Starting external-dns webhook server", "address", s.address)
+
+	errChan := make(chan error, 1)
+	go func() {
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("external-dns webhook server error: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return s.httpServer.Shutdown(shutdownCtx)
+	}
+}
+
+// handleNegotiate returns the domain filter external-dns checks on startup
+func (s *Server) handleNegotiate(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.provider.DomainFilter())
+}
+
+// handleRecords returns the managed records
+func (s *Server) handleRecords(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := s.provider.Records(r.Context())
+	if err != nil {
+		logging.Error("externaldns", "Failed to list records", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, endpoints)
+}
+
+// handleApplyChanges applies a plan of changes
+func (s *Server) handleApplyChanges(w http.ResponseWriter, r *http.Request) {
+	var changes Changes
+	if err := decodeBody(w, r, &changes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.provider.ApplyChanges(r.Context(), &changes); err != nil {
+		logging.Error("externaldns", "Failed to apply changes", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logging.Info("externaldns", "Applied changes",
+		"created", len(changes.Create), "updated", len(changes.UpdateNew), "deleted", len(changes.Delete))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdjustEndpoints normalizes desired endpoints before planning
+func (s *Server) handleAdjustEndpoints(w http.ResponseWriter, r *http.Request) {
+	var endpoints []*Endpoint
+	if err := decodeBody(w, r, &endpoints); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.provider.AdjustEndpoints(endpoints))
+}
+
+// decodeBody decodes a JSON request body into v
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes v with the webhook media type
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Vary", "Content-Type")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Error("externaldns", "Failed to encode response", err)
+	}
+}
@@ -0,0 +1,47 @@
+// internal/forwarder/acl.go
+package forwarder
+
+import (
+	"fmt"
+	"net"
+)
+
+// ACL is a list of networks permitted to use recursion
+type ACL struct {
+	networks []*net.IPNet
+}
+
+// NewACL parses cidrs into an ACL. Bare addresses are treated as single hosts
+func NewACL(cidrs []string) (*ACL, error) {
+	acl := &ACL{}
+	for _, cidr := range cidrs {
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			acl.networks = append(acl.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+		acl.networks = append(acl.networks, network)
+	}
+	return acl, nil
+}
+
+// Contains reports whether ip falls inside any network in the ACL
+func (a *ACL) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
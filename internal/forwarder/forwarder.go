@@ -0,0 +1,131 @@
+// internal/forwarder/forwarder.go
+package forwarder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+)
+
+// Config holds configuration for forwarding non-authoritative queries upstream
+type Config struct {
+	Upstreams     []string      // upstream resolvers; see parseUpstream for the accepted forms
+	Timeout       time.Duration // per-upstream exchange timeout
+	AllowedCIDRs  []string      // clients permitted to use recursion
+	CAFile        string        // PEM bundle to verify DoT/DoH upstreams with; empty uses the system roots
+	ProbeInterval time.Duration // how often Run probes upstream health; 0 disables probing
+}
+
+// DefaultConfig returns forwarder config with sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		Upstreams:     []string{"1.1.1.1:53", "8.8.8.8:53"},
+		Timeout:       2 * time.Second,
+		ProbeInterval: 10 * time.Second,
+		AllowedCIDRs: []string{
+			"127.0.0.0/8", "::1/128",
+			"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+		},
+	}
+}
+
+// Forwarder relays queries we are not authoritative for to upstream
+// resolvers, on behalf of clients in its ACL. Queries go to the fastest
+// healthy upstream, failing over to the others in RTT order
+type Forwarder struct {
+	upstreams     []*upstreamState
+	acl           *ACL
+	timeout       time.Duration
+	probeInterval time.Duration
+}
+
+// NewForwarder creates a forwarder, returning an error for an invalid ACL,
+// upstream, or CA file, or an empty upstream list
+func NewForwarder(config *Config) (*Forwarder, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	if len(config.Upstreams) == 0 {
+		return nil, errors.New("at least one upstream is required")
+	}
+
+	acl, err := NewACL(config.AllowedCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recursion ACL: %w", err)
+	}
+
+	roots, err := loadRoots(config.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreams := make([]*upstreamState, len(config.Upstreams))
+	for i, address := range config.Upstreams {
+		u, err := parseUpstream(address, config.Timeout, roots)
+		if err != nil {
+			return nil, err
+		}
+		upstreams[i] = newUpstreamState(u)
+	}
+
+	return &Forwarder{
+		upstreams:     upstreams,
+		acl:           acl,
+		timeout:       config.Timeout,
+		probeInterval: config.ProbeInterval,
+	}, nil
+}
+
+// Allowed reports whether ip may use recursion
+func (f *Forwarder) Allowed(ip net.IP) bool {
+	return f.acl.Contains(ip)
+}
+
+// Forward sends the query to each upstream in turn, fastest healthy one
+// first, until one answers
+func (f *Forwarder) Forward(ctx context.Context, r *dns.Msg) (*dns.Msg, error) {
+	query := r.Copy()
+	query.RecursionDesired = true
+
+	var lastErr error
+	for _, state := range f.ordered() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		upstream := state.upstream
+		state.queries.Add(1)
+		start := time.Now()
+		reply, err := upstream.exchange(ctx, query)
+		if err != nil {
+			state.failureCount.Add(1)
+			if ctx.Err() == nil {
+				state.fail(err, f.timeout)
+			}
+			logging.DebugContext(ctx, "forwarder", "Upstream failed", "upstream", upstream.String(), "error", err)
+			lastErr = fmt.Errorf("upstream %s: %w", upstream, err)
+			continue
+		}
+		state.observe(time.Since(start))
+
+		logging.DebugContext(ctx, "forwarder", "Forwarded query", "upstream", upstream.String(), "rcode", dns.RcodeToString[reply.Rcode])
+		return reply, nil
+	}
+
+	return nil, fmt.Errorf("all upstreams failed: %w", lastErr)
+}
+
+// withDefaultPort appends port to an upstream address without one
+func withDefaultPort(address, port string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, port)
+}
@@ -0,0 +1,184 @@
+// internal/forwarder/pool.go
+package forwarder
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+)
+
+// maxFailures is how many consecutive failures mark an upstream unhealthy
+const maxFailures = 3
+
+// UpstreamStats holds the health and counters of one upstream
+type UpstreamStats struct {
+	Address   string  `json:"address"`
+	Healthy   bool    `json:"healthy"`
+	SRTTMs    float64 `json:"srtt_ms"` // smoothed round trip time; 0 until measured
+	Queries   int64   `json:"queries"`
+	Failures  int64   `json:"failures"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// upstreamState tracks the health and smoothed RTT of one upstream
+type upstreamState struct {
+	upstream upstream
+
+	mu        sync.Mutex
+	srtt      time.Duration
+	healthy   bool
+	failures  int // consecutive
+	lastError string
+
+	queries      atomic.Int64
+	failureCount atomic.Int64
+}
+
+func newUpstreamState(u upstream) *upstreamState {
+	return &upstreamState{upstream: u, healthy: true}
+}
+
+// observe folds a successful exchange's RTT into the smoothed RTT the way
+// TCP does (RFC 6298, alpha 1/8) and marks the upstream healthy
+func (s *upstreamState) observe(rtt time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.srtt == 0 {
+		s.srtt = rtt
+	} else {
+		s.srtt += (rtt - s.srtt) / 8
+	}
+	s.failures = 0
+	if !s.healthy {
+		s.healthy = true
+		logging.Info("forwarder", "Upstream recovered", "upstream", s.upstream.String())
+	}
+}
+
+// fail records a failed exchange, counting it against the smoothed RTT as
+// a timeout, and marks the upstream unhealthy after maxFailures in a row
+func (s *upstreamState) fail(err error, timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.srtt == 0 {
+		s.srtt = timeout
+	} else {
+		s.srtt += (timeout - s.srtt) / 8
+	}
+	s.lastError = err.Error()
+	s.failures++
+	if s.healthy && s.failures >= maxFailures {
+		s.healthy = false
+		logging.Warn("forwarder", "Upstream marked unhealthy", "upstream", s.upstream.String(), "error", err)
+	}
+}
+
+// rank returns the upstream's health and smoothed RTT for ordering
+func (s *upstreamState) rank() (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.healthy, s.srtt
+}
+
+// stats returns a copy of the upstream's statistics
+func (s *upstreamState) stats() UpstreamStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return UpstreamStats{
+		Address:   s.upstream.String(),
+		Healthy:   s.healthy,
+		SRTTMs:    float64(s.srtt) / float64(time.Millisecond),
+		Queries:   s.queries.Load(),
+		Failures:  s.failureCount.Load(),
+		LastError: s.lastError,
+	}
+}
+
+// ordered returns the upstreams to try: healthy ones fastest first, then
+// unhealthy ones as a last resort. Unmeasured upstreams sort first so they
+// get an RTT
+func (f *Forwarder) ordered() []*upstreamState {
+	type ranked struct {
+		state   *upstreamState
+		healthy bool
+		srtt    time.Duration
+	}
+	ranks := make([]ranked, len(f.upstreams))
+	for i, state := range f.upstreams {
+		healthy, srtt := state.rank()
+		ranks[i] = ranked{state, healthy, srtt}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].healthy != ranks[j].healthy {
+			return ranks[i].healthy
+		}
+		return ranks[i].srtt < ranks[j].srtt
+	})
+
+	states := make([]*upstreamState, len(ranks))
+	for i, r := range ranks {
+		states[i] = r.state
+	}
+	return states
+}
+
+// UpstreamStats returns the statistics of every upstream in configured order
+func (f *Forwarder) UpstreamStats() []UpstreamStats {
+	stats := make([]UpstreamStats, len(f.upstreams))
+	for i, state := range f.upstreams {
+		stats[i] = state.stats()
+	}
+	return stats
+}
+
+// Run probes every upstream each probe interval until ctx is cancelled, so
+// unhealthy upstreams are noticed recovering and RTTs stay current. It
+// returns immediately if probing is disabled
+func (f *Forwarder) Run(ctx context.Context) {
+	if f.probeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(f.probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.probe(ctx)
+		}
+	}
+}
+
+// probe sends a root NS query to every upstream concurrently
+func (f *Forwarder) probe(ctx context.Context) {
+	query := new(dns.Msg)
+	query.SetQuestion(".", dns.TypeNS)
+
+	var wg sync.WaitGroup
+	for _, state := range f.upstreams {
+		wg.Add(1)
+		go func(state *upstreamState) {
+			defer wg.Done()
+			start := time.Now()
+			if _, err := state.upstream.exchange(ctx, query); err != nil {
+				if ctx.Err() == nil {
+					state.fail(err, f.timeout)
+				}
+				return
+			}
+			state.observe(time.Since(start))
+		}(state)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,267 @@
+// internal/forwarder/upstream.go
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxIdleConns is how many idle DoT connections are kept per upstream
+const maxIdleConns = 4
+
+// dohMediaType is the RFC 8484 wire-format media type
+const dohMediaType = "application/dns-message"
+
+// upstream is a resolver queries can be forwarded to
+type upstream interface {
+	exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error)
+	String() string
+}
+
+// parseUpstream builds an upstream from its address:
+//
+//	host[:port], udp://host[:port]  plaintext UDP, retried over TCP when truncated
+//	tcp://host[:port]               plaintext TCP
+//	tls://host[:port][#name]        DNS over TLS (port 853), verified against name or host
+//	https://host[:port]/path        DNS over HTTPS
+func parseUpstream(address string, timeout time.Duration, roots *x509.CertPool) (upstream, error) {
+	scheme, rest, found := strings.Cut(address, "://")
+	if !found {
+		scheme, rest = "udp", address
+	}
+
+	switch strings.ToLower(scheme) {
+	case "udp":
+		return newPlainUpstream(withDefaultPort(rest, "53"), false, timeout), nil
+	case "tcp":
+		return newPlainUpstream(withDefaultPort(rest, "53"), true, timeout), nil
+	case "tls":
+		host, serverName, _ := strings.Cut(rest, "#")
+		host = withDefaultPort(host, "853")
+		if serverName == "" {
+			serverName, _, _ = net.SplitHostPort(host)
+		}
+		return newTLSUpstream(host, serverName, timeout, roots), nil
+	case "https":
+		u, err := url.Parse(address)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid DoH upstream %q", address)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return newHTTPSUpstream(u.String(), timeout, roots), nil
+	default:
+		return nil, fmt.Errorf("unsupported upstream scheme %q in %q", scheme, address)
+	}
+}
+
+// loadRoots reads a PEM bundle to verify encrypted upstreams with. An empty
+// path returns nil, which verifies against the system roots
+func loadRoots(caFile string) (*x509.CertPool, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstream CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in upstream CA file %s", caFile)
+	}
+	return pool, nil
+}
+
+// plainUpstream forwards over unencrypted UDP or TCP
+type plainUpstream struct {
+	address string
+	udp     *dns.Client
+	tcp     *dns.Client
+	tcpOnly bool
+}
+
+func newPlainUpstream(address string, tcpOnly bool, timeout time.Duration) *plainUpstream {
+	return &plainUpstream{
+		address: address,
+		udp:     &dns.Client{Net: "udp", Timeout: timeout},
+		tcp:     &dns.Client{Net: "tcp", Timeout: timeout},
+		tcpOnly: tcpOnly,
+	}
+}
+
+func (u *plainUpstream) exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	if u.tcpOnly {
+		reply, _, err := u.tcp.ExchangeContext(ctx, query, u.address)
+		return reply, err
+	}
+	reply, _, err := u.udp.ExchangeContext(ctx, query, u.address)
+	if err == nil && reply.Truncated {
+		reply, _, err = u.tcp.ExchangeContext(ctx, query, u.address)
+	}
+	return reply, err
+}
+
+func (u *plainUpstream) String() string {
+	if u.tcpOnly {
+		return "tcp://" + u.address
+	}
+	return u.address
+}
+
+// tlsUpstream forwards over DNS over TLS, keeping connections open between
+// queries so each one doesn't pay for a handshake
+type tlsUpstream struct {
+	address string
+	client  *dns.Client
+
+	mu   sync.Mutex
+	idle []*dns.Conn
+}
+
+func newTLSUpstream(address, serverName string, timeout time.Duration, roots *x509.CertPool) *tlsUpstream {
+	return &tlsUpstream{
+		address: address,
+		client: &dns.Client{
+			Net:     "tcp-tls",
+			Timeout: timeout,
+			TLSConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				ServerName: serverName,
+				RootCAs:    roots,
+			},
+		},
+	}
+}
+
+func (u *tlsUpstream) exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	// An idle connection may have been closed by the server; retry once on a
+	// fresh one before giving up
+	if conn := u.get(); conn != nil {
+		if reply, err := u.exchangeOn(ctx, conn, query); err == nil {
+			return reply, nil
+		}
+	}
+
+	conn, err := u.client.DialContext(ctx, u.address)
+	if err != nil {
+		return nil, err
+	}
+	return u.exchangeOn(ctx, conn, query)
+}
+
+// exchangeOn sends query on conn, returning conn to the idle pool if the
+// exchange succeeded and closing it otherwise
+func (u *tlsUpstream) exchangeOn(ctx context.Context, conn *dns.Conn, query *dns.Msg) (*dns.Msg, error) {
+	reply, _, err := u.client.ExchangeWithConnContext(ctx, query, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	u.put(conn)
+	return reply, nil
+}
+
+// get takes the most recently used idle connection, if any
+func (u *tlsUpstream) get() *dns.Conn {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.idle) == 0 {
+		return nil
+	}
+	conn := u.idle[len(u.idle)-1]
+	u.idle = u.idle[:len(u.idle)-1]
+	return conn
+}
+
+// put returns conn to the idle pool, closing it if the pool is full
+func (u *tlsUpstream) put(conn *dns.Conn) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.idle) >= maxIdleConns {
+		conn.Close()
+		return
+	}
+	u.idle = append(u.idle, conn)
+}
+
+func (u *tlsUpstream) String() string {
+	return "tls://" + u.address
+}
+
+// httpsUpstream forwards over DNS over HTTPS (RFC 8484). The HTTP transport
+// keeps connections alive and multiplexes queries over HTTP/2
+type httpsUpstream struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSUpstream(endpoint string, timeout time.Duration, roots *x509.CertPool) *httpsUpstream {
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: roots},
+		ForceAttemptHTTP2:   true,
+		MaxIdleConnsPerHost: maxIdleConns,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: timeout,
+	}
+	return &httpsUpstream{
+		url:    endpoint,
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}
+}
+
+func (u *httpsUpstream) exchange(ctx context.Context, query *dns.Msg) (*dns.Msg, error) {
+	// RFC 8484 recommends ID 0 so identical queries are cacheable
+	msg := query.Copy()
+	msg.Id = 0
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.url, bytes.NewReader(packed))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH request failed: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, err
+	}
+
+	reply := new(dns.Msg)
+	if err := reply.Unpack(body); err != nil {
+		return nil, fmt.Errorf("invalid DoH response: %w", err)
+	}
+	reply.Id = query.Id
+	return reply, nil
+}
+
+func (u *httpsUpstream) String() string {
+	return u.url
+}
@@ -0,0 +1,236 @@
+// internal/hosts/hosts.go
+package hosts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/reverse"
+)
+
+// entries is one parsed generation of the hosts files
+type entries struct {
+	addresses map[string][]net.IP // normalized name -> addresses in file order
+	names     map[string][]string // address -> names, canonical name first
+}
+
+// fileState identifies a version of a file so changes can be detected
+// without reading it
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// Table serves A, AAAA, and PTR answers from /etc/hosts-format files. Files
+// are re-read when they change, so edits take effect without a restart
+type Table struct {
+	paths []string
+	ttl   uint32
+
+	current atomic.Pointer[entries]
+	states  []fileState
+}
+
+// NewTable creates a table for the given files, answering with ttl. Load
+// must be called before the table answers anything
+func NewTable(paths []string, ttl uint32) *Table {
+	t := &Table{paths: paths, ttl: ttl}
+	t.current.Store(&entries{})
+	return t
+}
+
+// Load reads every file, replacing the table's entries. Missing files are
+// skipped so they can be created later
+func (t *Table) Load() error {
+	next := &entries{
+		addresses: make(map[string][]net.IP),
+		names:     make(map[string][]string),
+	}
+	states := make([]fileState, len(t.paths))
+
+	for i, path := range t.paths {
+		state, err := t.stat(path)
+		if err != nil {
+			return err
+		}
+		states[i] = state
+		if !state.exists {
+			continue
+		}
+		if err := next.parseFile(path); err != nil {
+			return err
+		}
+	}
+
+	t.current.Store(next)
+	t.states = states
+	return nil
+}
+
+// Len returns how many names the table holds
+func (t *Table) Len() int {
+	return len(t.current.Load().addresses)
+}
+
+// Lookup returns the records the hosts files hold for name and qtype, and
+// whether the files mention name at all. A name that's present with no
+// records of qtype is answered with no data rather than passed on
+func (t *Table) Lookup(name string, qtype uint16) ([]*models.DNSRecord, bool) {
+	current := t.current.Load()
+
+	if qtype == dns.TypePTR {
+		ip, ok := reverse.ParseName(name)
+		if !ok {
+			return nil, false
+		}
+		names, found := current.names[ip.String()]
+		if !found {
+			return nil, false
+		}
+		return []*models.DNSRecord{{
+			Name:       models.NormalizeDomainName(name),
+			RecordType: string(models.RecordTypePTR),
+			Target:     names[0],
+			TTL:        t.ttl,
+		}}, true
+	}
+
+	if qtype != dns.TypeA && qtype != dns.TypeAAAA {
+		return nil, false
+	}
+
+	owner := models.NormalizeDomainName(name)
+	addresses, found := current.addresses[owner]
+	if !found {
+		return nil, false
+	}
+
+	var records []*models.DNSRecord
+	for _, ip := range addresses {
+		isV4 := ip.To4() != nil
+		switch {
+		case qtype == dns.TypeA && isV4:
+			records = append(records, &models.DNSRecord{Name: owner, RecordType: string(models.RecordTypeA), Target: ip.String(), TTL: t.ttl})
+		case qtype == dns.TypeAAAA && !isV4:
+			records = append(records, &models.DNSRecord{Name: owner, RecordType: string(models.RecordTypeAAAA), Target: ip.String(), TTL: t.ttl})
+		}
+	}
+	return records, true
+}
+
+// Run checks the files for changes every interval until ctx is cancelled,
+// reloading the table when any of them was modified, created, or removed
+func (t *Table) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !t.changed() {
+				continue
+			}
+			if err := t.Load(); err != nil {
+				logging.Warn("hosts", "Failed to reload hosts files", "error", err)
+				continue
+			}
+			logging.Info("hosts", "Reloaded hosts files", "names", t.Len())
+		}
+	}
+}
+
+// changed reports whether any file differs from when it was last loaded
+func (t *Table) changed() bool {
+	for i, path := range t.paths {
+		state, err := t.stat(path)
+		if err != nil || state != t.states[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// stat returns the current state of path
+func (t *Table) stat(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, fmt.Errorf("failed to stat hosts file: %w", err)
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size(), exists: true}, nil
+}
+
+// parseFile adds the entries of one hosts file. Lines are an address
+// followed by its names; "#" starts a comment. Lines with addresses that
+// don't parse, such as link-local addresses with a zone, are skipped
+func (e *entries) parseFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open hosts file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+
+		for _, field := range fields[1:] {
+			name := models.NormalizeDomainName(field)
+			if _, ok := dns.IsDomainName(name); !ok {
+				continue
+			}
+			e.add(name, ip)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read hosts file %s: %w", path, err)
+	}
+	return nil
+}
+
+// add records that name resolves to ip, ignoring duplicates
+func (e *entries) add(name string, ip net.IP) {
+	for _, existing := range e.addresses[name] {
+		if existing.Equal(ip) {
+			return
+		}
+	}
+	e.addresses[name] = append(e.addresses[name], ip)
+
+	address := ip.String()
+	for _, existing := range e.names[address] {
+		if existing == name {
+			return
+		}
+	}
+	e.names[address] = append(e.names[address], name)
+}
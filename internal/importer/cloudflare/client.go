@@ -0,0 +1,161 @@
+// internal/importer/cloudflare/client.go
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const apiEndpoint = "https://api.cloudflare.com/client/v4"
+
+// Client reads zones and DNS records from the Cloudflare v4 API
+type Client struct {
+	token      string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewClient creates a Cloudflare API client authenticating with an API token
+func NewClient(token string, timeout time.Duration) *Client {
+	return &Client{
+		token:      token,
+		endpoint:   apiEndpoint,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Zone is the subset of zone details the syncer uses
+type Zone struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Record is a Cloudflare DNS record
+type Record struct {
+	ID       string     `json:"id"`
+	Type     string     `json:"type"`
+	Name     string     `json:"name"`
+	Content  string     `json:"content"`
+	TTL      uint32     `json:"ttl"` // 1 means "automatic"
+	Priority *uint16    `json:"priority,omitempty"`
+	Proxied  bool       `json:"proxied"`
+	Comment  string     `json:"comment"`
+	Data     RecordData `json:"data"`
+}
+
+// RecordData holds structured fields for SRV and CAA records
+type RecordData struct {
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+	Port     uint16 `json:"port"`
+	Target   string `json:"target"`
+	Flags    uint8  `json:"flags"`
+	Tag      string `json:"tag"`
+	Value    string `json:"value"`
+}
+
+type apiResponse struct {
+	Success    bool            `json:"success"`
+	Errors     []apiMessage    `json:"errors"`
+	Result     json.RawMessage `json:"result"`
+	ResultInfo *struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+type apiMessage struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// FindZone looks up a zone by name
+func (c *Client) FindZone(ctx context.Context, name string) (*Zone, error) {
+	var zones []Zone
+	if _, err := c.get(ctx, "/zones", url.Values{"name": {name}}, &zones); err != nil {
+		return nil, err
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("zone %s not found", name)
+	}
+	return &zones[0], nil
+}
+
+// ListRecords returns every DNS record in the zone, following pagination
+func (c *Client) ListRecords(ctx context.Context, zoneID string) ([]Record, error) {
+	var records []Record
+	for page := 1; ; page++ {
+		params := url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {"100"},
+		}
+
+		var batch []Record
+		totalPages, err := c.get(ctx, "/zones/"+url.PathEscape(zoneID)+"/dns_records", params, &batch)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, batch...)
+
+		if page >= totalPages {
+			return records, nil
+		}
+	}
+}
+
+// get performs an authenticated GET, decodes the result into v, and
+// returns the total page count
+func (c *Client) get(ctx context.Context, path string, params url.Values, v interface{}) (int, error) {
+	requestURL := c.endpoint + path
+	if len(params) > 0 {
+		requestURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("cloudflare request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cloudflare response: %w", err)
+	}
+
+	var response apiResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, fmt.Errorf("cloudflare returned HTTP %d with an unreadable body", resp.StatusCode)
+	}
+
+	if !response.Success || resp.StatusCode != http.StatusOK {
+		var messages []string
+		for _, apiErr := range response.Errors {
+			messages = append(messages, fmt.Sprintf("%d: %s", apiErr.Code, apiErr.Message))
+		}
+		return 0, fmt.Errorf("cloudflare returned HTTP %d: %s", resp.StatusCode, strings.Join(messages, "; "))
+	}
+
+	if err := json.Unmarshal(response.Result, v); err != nil {
+		return 0, fmt.Errorf("failed to parse cloudflare result: %w", err)
+	}
+
+	totalPages := 1
+	if response.ResultInfo != nil && response.ResultInfo.TotalPages > 0 {
+		totalPages = response.ResultInfo.TotalPages
+	}
+	return totalPages, nil
+}
@@ -0,0 +1,145 @@
+// internal/importer/cloudflare/sync.go
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"errantdns.io/internal/importer"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/storage"
+)
+
+// Syncer mirrors Cloudflare zones into local storage on a schedule.
+// Only records carrying the syncer's labels for a zone are created,
+// updated, or deleted; everything else in the table is left alone
+type Syncer struct {
+	client     *Client
+	store      importer.Store
+	reconciler *importer.Reconciler
+	zones      []string
+	interval   time.Duration
+	defaultTTL uint32
+
+	zoneIDs map[string]string // zone name -> Cloudflare zone ID, only touched by Run
+}
+
+// NewSyncer creates a syncer for the named zones. The invalidator, if not
+// nil, is told about every name/type the syncer changes
+func NewSyncer(client *Client, store importer.Store, invalidator storage.Invalidator, zones []string, interval time.Duration, defaultTTL uint32) *Syncer {
+	normalized := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		normalized = append(normalized, models.NormalizeDomainName(zone))
+	}
+
+	return &Syncer{
+		client: client,
+		store:  store,
+		reconciler: &importer.Reconciler{
+			Store:       store,
+			Invalidator: invalidator,
+			Key: func(record *models.DNSRecord) string {
+				return record.Labels[LabelID]
+			},
+		},
+		zones:      normalized,
+		interval:   interval,
+		defaultTTL: defaultTTL,
+		zoneIDs:    make(map[string]string),
+	}
+}
+
+// Run syncs every zone immediately and then on every tick until the
+// context is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	s.SyncAll(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.SyncAll(ctx)
+		}
+	}
+}
+
+// SyncAll reconciles each configured zone once, logging the outcome
+func (s *Syncer) SyncAll(ctx context.Context) {
+	for _, zone := range s.zones {
+		result, err := s.SyncZone(ctx, zone)
+		if err != nil {
+			logging.Error("cloudflare", "Zone sync failed", err, "zone", zone)
+			continue
+		}
+
+		if !result.Changed() {
+			logging.Debug("cloudflare", "Zone in sync", "zone", zone, "records", result.Unchanged)
+			continue
+		}
+
+		logging.Info("cloudflare", "Zone synced", "zone", zone,
+			"created", result.Created, "updated", result.Updated, "deleted", result.Deleted,
+			"unchanged", result.Unchanged, "failed", result.Failed)
+	}
+}
+
+// SyncZone reconciles one zone: records missing locally are created,
+// changed records are updated, and records removed from Cloudflare are
+// deleted. A Cloudflare record that cannot be translated keeps its
+// previously synced copy rather than being deleted
+func (s *Syncer) SyncZone(ctx context.Context, zone string) (*importer.SyncResult, error) {
+	zoneID, err := s.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := s.client.ListRecords(ctx, zoneID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cloudflare records: %w", err)
+	}
+
+	desired, report := Translate(zone, remote, s.defaultTTL)
+	for _, issue := range report.Warnings {
+		logging.Debug("cloudflare", "Record approximated", "zone", zone, "name", issue.Name, "type", issue.Type, "reason", issue.Reason)
+	}
+	skippedIDs := make(map[string]bool, len(report.Unmapped))
+	for _, issue := range report.Unmapped {
+		skippedIDs[issue.SetIdentifier] = true
+		logging.Warn("cloudflare", "Record not synced", "zone", zone, "name", issue.Name, "type", issue.Type, "reason", issue.Reason)
+	}
+
+	existing, err := s.store.ListRecords(ctx, &models.RecordFilter{
+		Labels: map[string]string{LabelSource: sourceName, LabelZone: zone},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list synced records: %w", err)
+	}
+
+	// An empty zone is far more likely to be an API or permissions problem
+	// than a real change, so never wipe a mirrored zone in one pass
+	if len(remote) == 0 && len(existing) > 0 {
+		return nil, fmt.Errorf("cloudflare returned no records for %s; refusing to delete %d synced records", zone, len(existing))
+	}
+
+	return s.reconciler.Reconcile(ctx, existing, desired, skippedIDs), nil
+}
+
+// zoneID resolves a zone name to its Cloudflare ID, caching the answer
+func (s *Syncer) zoneID(ctx context.Context, zone string) (string, error) {
+	if id, ok := s.zoneIDs[zone]; ok {
+		return id, nil
+	}
+
+	found, err := s.client.FindZone(ctx, zone)
+	if err != nil {
+		return "", fmt.Errorf("failed to find cloudflare zone: %w", err)
+	}
+	s.zoneIDs[zone] = found.ID
+	return found.ID, nil
+}
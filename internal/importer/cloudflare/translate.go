@@ -0,0 +1,138 @@
+// internal/importer/cloudflare/translate.go
+package cloudflare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/importer"
+	"errantdns.io/internal/models"
+)
+
+// Labels identifying records owned by the syncer. Records without them are
+// never modified, so synced and locally managed records can share a zone
+const (
+	LabelSource  = "source"
+	LabelZone    = "cloudflare/zone"
+	LabelID      = "cloudflare/id"
+	LabelProxied = "cloudflare/proxied"
+
+	sourceName = "cloudflare"
+	autoTTL    = 1 // Cloudflare's "automatic" TTL
+)
+
+// Translate converts Cloudflare DNS records into ErrantDNS records labelled
+// with their zone and Cloudflare record ID.
+//
+// Proxied records are imported with their origin content; Cloudflare's
+// public answers point at its edge instead, so these are reported as warnings
+func Translate(zone string, records []Record, defaultTTL uint32) ([]*models.DNSRecord, *importer.Report) {
+	zone = models.NormalizeDomainName(zone)
+	if defaultTTL == 0 {
+		defaultTTL = 300
+	}
+
+	report := &importer.Report{Source: sourceName, Zone: zone, Sets: len(records)}
+
+	var translated []*models.DNSRecord
+	for i := range records {
+		source := &records[i]
+		name := models.NormalizeDomainName(source.Name)
+		recordType := strings.ToUpper(source.Type)
+
+		record, err := translateRecord(source, name, recordType)
+		if err != nil {
+			report.Skip(name, recordType, source.ID, "%v", err)
+			continue
+		}
+
+		record.TTL = source.TTL
+		if record.TTL == autoTTL || record.TTL == 0 {
+			record.TTL = defaultTTL
+		}
+		record.Comment = source.Comment
+		record.Labels = map[string]string{
+			LabelSource: sourceName,
+			LabelZone:   zone,
+			LabelID:     source.ID,
+		}
+		if source.Proxied {
+			record.Labels[LabelProxied] = "true"
+			report.Warn(name, recordType, source.ID, "proxied record imported with its origin address instead of Cloudflare's edge")
+		}
+
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			report.Skip(name, recordType, source.ID, "%v", err)
+			continue
+		}
+		translated = append(translated, record)
+	}
+
+	report.Records = len(translated)
+	return translated, report
+}
+
+// translateRecord maps the type-specific fields of one Cloudflare record
+func translateRecord(source *Record, name, recordType string) (*models.DNSRecord, error) {
+	record := &models.DNSRecord{
+		Name:       name,
+		RecordType: recordType,
+	}
+
+	switch recordType {
+	case "A", "AAAA":
+		record.Target = source.Content
+	case "CNAME", "NS", "PTR":
+		record.Target = trimDot(source.Content)
+	case "TXT":
+		target, err := txtContent(name, source.Content)
+		if err != nil {
+			return nil, err
+		}
+		record.Target = target
+	case "MX":
+		if source.Priority == nil {
+			return nil, fmt.Errorf("MX record has no priority")
+		}
+		record.Priority = int(*source.Priority)
+		record.Target = trimDot(source.Content)
+	case "SRV":
+		record.Priority = int(source.Data.Priority)
+		record.Weight = uint32(source.Data.Weight)
+		record.Port = source.Data.Port
+		record.Target = trimDot(source.Data.Target)
+	case "CAA":
+		record.Priority = int(source.Data.Flags)
+		record.Tag = source.Data.Tag
+		record.Target = source.Data.Value
+	default:
+		return nil, fmt.Errorf("record type %s is not supported", recordType)
+	}
+
+	return record, nil
+}
+
+// txtContent returns the TXT value as a single string. Cloudflare returns
+// content either bare or as quoted zone-file character strings
+func txtContent(name, content string) (string, error) {
+	if !strings.HasPrefix(content, `"`) {
+		return content, nil
+	}
+
+	rr, err := dns.NewRR(fmt.Sprintf("%s 300 IN TXT %s", dns.Fqdn(name), content))
+	if err != nil {
+		return "", fmt.Errorf("cannot parse TXT content %q: %w", content, err)
+	}
+	txt, ok := rr.(*dns.TXT)
+	if !ok {
+		return "", fmt.Errorf("cannot parse TXT content %q", content)
+	}
+	return strings.Join(txt.Txt, ""), nil
+}
+
+func trimDot(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
@@ -0,0 +1,154 @@
+// internal/importer/reconcile.go
+package importer
+
+import (
+	"context"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/storage"
+)
+
+// Store is the record storage synced records are reconciled into
+type Store interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+	CreateRecord(ctx context.Context, record *models.DNSRecord) error
+	UpdateRecord(ctx context.Context, record *models.DNSRecord) error
+	DeleteRecord(ctx context.Context, id int) error
+}
+
+// SyncResult counts the changes made while reconciling one zone
+type SyncResult struct {
+	Created   int
+	Updated   int
+	Deleted   int
+	Unchanged int
+	Failed    int // storage writes that returned an error
+}
+
+// Changed reports whether reconciling wrote anything or tried to
+func (r *SyncResult) Changed() bool {
+	return r.Created+r.Updated+r.Deleted+r.Failed > 0
+}
+
+// Reconciler applies the difference between previously synced records and
+// the records a source currently holds
+type Reconciler struct {
+	Store       Store
+	Invalidator storage.Invalidator // told about every changed name/type, may be nil
+
+	// Key identifies the same record on both sides, e.g. a source record ID
+	Key func(record *models.DNSRecord) string
+}
+
+// Reconcile creates desired records missing from existing, updates those
+// that differ, and deletes existing records no longer desired. Existing
+// records whose key is in keep are never deleted
+func (rc *Reconciler) Reconcile(ctx context.Context, existing, desired []*models.DNSRecord, keep map[string]bool) *SyncResult {
+	result := &SyncResult{}
+
+	byKey := make(map[string]*models.DNSRecord, len(existing))
+	for _, record := range existing {
+		key := rc.Key(record)
+		if _, duplicate := byKey[key]; duplicate || key == "" {
+			rc.delete(ctx, record, result)
+			continue
+		}
+		byKey[key] = record
+	}
+
+	for _, record := range desired {
+		key := rc.Key(record)
+		current, ok := byKey[key]
+		delete(byKey, key)
+
+		if !ok {
+			if err := rc.Store.CreateRecord(ctx, record); err != nil {
+				logging.Error("importer", "Failed to create synced record", err, "name", record.Name, "type", record.RecordType)
+				result.Failed++
+				continue
+			}
+			rc.invalidate(record.Name, record.RecordType)
+			result.Created++
+			continue
+		}
+
+		if SameRecord(current, record) {
+			result.Unchanged++
+			continue
+		}
+
+		record.ID = current.ID
+		if err := rc.Store.UpdateRecord(ctx, record); err != nil {
+			logging.Error("importer", "Failed to update synced record", err, "id", current.ID, "name", record.Name, "type", record.RecordType)
+			result.Failed++
+			continue
+		}
+		rc.invalidate(current.Name, current.RecordType)
+		rc.invalidate(record.Name, record.RecordType)
+		result.Updated++
+	}
+
+	for key, record := range byKey {
+		if keep[key] {
+			continue
+		}
+		rc.delete(ctx, record, result)
+	}
+
+	return result
+}
+
+func (rc *Reconciler) delete(ctx context.Context, record *models.DNSRecord, result *SyncResult) {
+	if err := rc.Store.DeleteRecord(ctx, record.ID); err != nil {
+		logging.Error("importer", "Failed to delete synced record", err, "id", record.ID, "name", record.Name, "type", record.RecordType)
+		result.Failed++
+		return
+	}
+	rc.invalidate(record.Name, record.RecordType)
+	result.Deleted++
+}
+
+func (rc *Reconciler) invalidate(name, recordType string) {
+	if rc.Invalidator != nil {
+		rc.Invalidator.Invalidate(name, recordType)
+	}
+}
+
+// SameRecord reports whether a stored record already matches the desired
+// copy. Local edits to synced records, including activation windows, are
+// overwritten on the next sync
+func SameRecord(current, desired *models.DNSRecord) bool {
+	if current.Name != desired.Name ||
+		current.RecordType != desired.RecordType ||
+		current.Target != desired.Target ||
+		current.TTL != desired.TTL ||
+		current.Priority != desired.Priority ||
+		current.Weight != desired.Weight ||
+		current.Port != desired.Port ||
+		current.Tag != desired.Tag ||
+		current.Mbox != desired.Mbox ||
+		current.Serial != desired.Serial ||
+		current.Refresh != desired.Refresh ||
+		current.Retry != desired.Retry ||
+		current.Expire != desired.Expire ||
+		current.Minttl != desired.Minttl ||
+		current.Comment != desired.Comment ||
+		current.Owner != desired.Owner {
+		return false
+	}
+
+	if current.ExpiresAt != nil || current.NotBefore != nil || current.NotAfter != nil {
+		return false
+	}
+
+	if len(current.Labels) != len(desired.Labels) {
+		return false
+	}
+	for key, value := range desired.Labels {
+		if current.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+// internal/importer/report.go
+package importer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Issue describes a source record set that was approximated or skipped
+type Issue struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	SetIdentifier string `json:"set_identifier,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// Report summarizes the outcome of translating an external zone
+type Report struct {
+	Source   string  `json:"source"`
+	Zone     string  `json:"zone"`
+	Sets     int     `json:"sets"`     // record sets read from the source
+	Records  int     `json:"records"`  // ErrantDNS records produced
+	Warnings []Issue `json:"warnings"` // mapped, but behaviour differs from the source
+	Unmapped []Issue `json:"unmapped"` // not imported
+}
+
+// Warn records a set that was imported with different semantics
+func (r *Report) Warn(name, recordType, setIdentifier, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Issue{
+		Name:          name,
+		Type:          recordType,
+		SetIdentifier: setIdentifier,
+		Reason:        fmt.Sprintf(format, args...),
+	})
+}
+
+// Skip records a set that could not be imported
+func (r *Report) Skip(name, recordType, setIdentifier, format string, args ...interface{}) {
+	r.Unmapped = append(r.Unmapped, Issue{
+		Name:          name,
+		Type:          recordType,
+		SetIdentifier: setIdentifier,
+		Reason:        fmt.Sprintf(format, args...),
+	})
+}
+
+// Print writes a human-readable summary
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "%s import of %s: %d record sets -> %d records, %d warnings, %d unmapped\n",
+		r.Source, r.Zone, r.Sets, r.Records, len(r.Warnings), len(r.Unmapped))
+
+	printIssues(w, "Warnings", r.Warnings)
+	printIssues(w, "Unmapped", r.Unmapped)
+}
+
+func printIssues(w io.Writer, title string, issues []Issue) {
+	if len(issues) == 0 {
+		return
+	}
+
+	sorted := append([]Issue(nil), issues...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, issue := range sorted {
+		id := ""
+		if issue.SetIdentifier != "" {
+			id = fmt.Sprintf(" [%s]", issue.SetIdentifier)
+		}
+		fmt.Fprintf(w, "  %s %s%s: %s\n", issue.Name, issue.Type, id, issue.Reason)
+	}
+}
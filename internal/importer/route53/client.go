@@ -0,0 +1,256 @@
+// internal/importer/route53/client.go
+package route53
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	apiEndpoint = "https://route53.amazonaws.com"
+	apiVersion  = "2013-04-01"
+	signRegion  = "us-east-1" // Route 53 is a global service signed in us-east-1
+	signService = "route53"
+)
+
+// Credentials are AWS access keys used to sign API requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+// the optional AWS_SESSION_TOKEN
+func CredentialsFromEnv() (*Credentials, error) {
+	creds := &Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+// Client reads hosted zones from the Route 53 API
+type Client struct {
+	credentials *Credentials
+	endpoint    string
+	httpClient  *http.Client
+}
+
+// NewClient creates a Route 53 API client
+func NewClient(credentials *Credentials) *Client {
+	return &Client{
+		credentials: credentials,
+		endpoint:    apiEndpoint,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// HostedZone is the subset of hosted zone details the importer uses
+type HostedZone struct {
+	ID   string `xml:"Id"`
+	Name string `xml:"Name"`
+}
+
+type getHostedZoneResponse struct {
+	HostedZone HostedZone `xml:"HostedZone"`
+}
+
+type listRecordSetsResponse struct {
+	RecordSets           []RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated          bool        `xml:"IsTruncated"`
+	NextRecordName       string      `xml:"NextRecordName"`
+	NextRecordType       string      `xml:"NextRecordType"`
+	NextRecordIdentifier string      `xml:"NextRecordIdentifier"`
+}
+
+type apiError struct {
+	Type    string `xml:"Error>Type"`
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// GetHostedZone returns the hosted zone's ID and name
+func (c *Client) GetHostedZone(ctx context.Context, zoneID string) (*HostedZone, error) {
+	var response getHostedZoneResponse
+	if err := c.get(ctx, "/"+apiVersion+"/hostedzone/"+cleanZoneID(zoneID), nil, &response); err != nil {
+		return nil, err
+	}
+	return &response.HostedZone, nil
+}
+
+// ListRecordSets returns every record set in the hosted zone, following pagination
+func (c *Client) ListRecordSets(ctx context.Context, zoneID string) ([]RecordSet, error) {
+	path := "/" + apiVersion + "/hostedzone/" + cleanZoneID(zoneID) + "/rrset"
+	params := url.Values{"maxitems": {"300"}}
+
+	var sets []RecordSet
+	for {
+		var response listRecordSetsResponse
+		if err := c.get(ctx, path, params, &response); err != nil {
+			return nil, err
+		}
+		sets = append(sets, response.RecordSets...)
+
+		if !response.IsTruncated {
+			return sets, nil
+		}
+
+		params = url.Values{
+			"maxitems": {"300"},
+			"name":     {response.NextRecordName},
+			"type":     {response.NextRecordType},
+		}
+		if response.NextRecordIdentifier != "" {
+			params.Set("identifier", response.NextRecordIdentifier)
+		}
+	}
+}
+
+// get performs a signed GET and decodes the XML response into v
+func (c *Client) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	requestURL := c.endpoint + path
+	if len(params) > 0 {
+		// Send the canonical form so the signed and transmitted queries match
+		requestURL += "?" + canonicalQuery(params)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	c.sign(req, time.Now().UTC())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("route 53 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read route 53 response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if xml.Unmarshal(body, &apiErr) == nil && apiErr.Code != "" {
+			return fmt.Errorf("route 53 %s: %s", apiErr.Code, apiErr.Message)
+		}
+		return fmt.Errorf("route 53 returned HTTP %d", resp.StatusCode)
+	}
+
+	if err := xml.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse route 53 response: %w", err)
+	}
+	return nil
+}
+
+// sign adds AWS Signature Version 4 headers to a bodiless request
+func (c *Client) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if c.credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.credentials.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":       req.URL.Host,
+		"x-amz-date": amzDate,
+	}
+	if c.credentials.SessionToken != "" {
+		headers["x-amz-security-token"] = c.credentials.SessionToken
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	emptyHash := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(emptyHash[:]),
+	}, "\n")
+
+	scope := date + "/" + signRegion + "/" + signService + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, signRegion)
+	key = hmacSHA256(key, signService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by key with RFC 3986 escaping
+func canonicalQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), params[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything except RFC 3986 unreserved characters
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// cleanZoneID accepts both "Z123" and "/hostedzone/Z123"
+func cleanZoneID(zoneID string) string {
+	return strings.TrimPrefix(zoneID, "/hostedzone/")
+}
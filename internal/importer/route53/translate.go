@@ -0,0 +1,334 @@
+// internal/importer/route53/translate.go
+package route53
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/importer"
+	"errantdns.io/internal/models"
+)
+
+const (
+	primaryPriority   = 10 // served group
+	secondaryPriority = 20 // only served when the primary group is absent
+	aliasResolvedTTL  = 60
+	maxAliasDepth     = 8
+)
+
+// Options controls how Route 53 record sets are translated
+type Options struct {
+	Zone             string // hosted zone name, used to recognise apex records
+	DefaultTTL       uint32 // TTL for sets without one
+	IncludeApexNSSOA bool   // import the zone's Route 53 SOA and NS sets
+	ResolveAliases   bool   // snapshot addresses of aliases to AWS resources via DNS
+}
+
+// Translate converts Route 53 record sets into ErrantDNS records.
+//
+// ErrantDNS serves the lowest-priority group of a name/type and rotates
+// between ties, so routing policies are approximated:
+//   - weighted: non-zero weights share one group (weights are ignored);
+//     zero-weight sets form a fallback group
+//   - failover: PRIMARY is the served group, SECONDARY the fallback
+//   - latency and multivalue: all sets share one group
+//   - geolocation: only the default ("*") location is imported
+//
+// Aliases to names in the zone are flattened into copies of the target's
+// records. Aliases to AWS resources are resolved at import time when
+// ResolveAliases is set and reported as unmapped otherwise
+func Translate(ctx context.Context, sets []RecordSet, opts Options) ([]*models.DNSRecord, *importer.Report) {
+	zone := models.NormalizeDomainName(unescapeName(opts.Zone))
+	if opts.DefaultTTL == 0 {
+		opts.DefaultTTL = 300
+	}
+
+	report := &importer.Report{Source: "route53", Zone: zone, Sets: len(sets)}
+
+	var records []*models.DNSRecord
+	var aliases []*aliasSet
+
+	for i := range sets {
+		set := &sets[i]
+		name := models.NormalizeDomainName(unescapeName(set.Name))
+		recordType := strings.ToUpper(set.Type)
+
+		if name == zone && (recordType == "SOA" || recordType == "NS") && !opts.IncludeApexNSSOA {
+			report.Skip(name, recordType, set.SetIdentifier, "Route 53 zone %s set is not imported by default", recordType)
+			continue
+		}
+
+		if !models.RecordType(recordType).IsValid() {
+			report.Skip(name, recordType, set.SetIdentifier, "record type %s is not supported", recordType)
+			continue
+		}
+
+		priority, ok := routingPriority(set, name, recordType, report)
+		if !ok {
+			continue
+		}
+
+		if set.HealthCheckID != "" {
+			report.Warn(name, recordType, set.SetIdentifier, "health check %s is not imported; the record is always served", set.HealthCheckID)
+		}
+
+		labels := routingLabels(set)
+
+		if set.AliasTarget != nil {
+			aliases = append(aliases, &aliasSet{set: set, name: name, recordType: recordType, priority: priority, labels: labels})
+			continue
+		}
+
+		ttl := opts.DefaultTTL
+		if set.TTL != nil {
+			ttl = *set.TTL
+		}
+
+		for _, value := range set.ResourceRecords {
+			record, err := parseValue(name, recordType, ttl, value.Value)
+			if err != nil {
+				report.Skip(name, recordType, set.SetIdentifier, "cannot parse value %q: %v", value.Value, err)
+				continue
+			}
+			if usesRoutingPriority(recordType) {
+				record.Priority = priority
+			}
+			if recordType == "TXT" && strings.Count(value.Value, `"`) > 2 {
+				report.Warn(name, recordType, set.SetIdentifier, "multi-string TXT value joined into one string")
+			}
+			record.Labels = copyLabels(labels)
+			records = append(records, record)
+		}
+	}
+
+	records = append(records, flattenAliases(ctx, aliases, records, opts, report)...)
+
+	// Validate last so every produced record passes the same checks as the API
+	valid := records[:0]
+	for _, record := range records {
+		record.Normalize()
+		if err := record.Validate(); err != nil {
+			report.Skip(record.Name, record.RecordType, record.Labels["route53/set-identifier"], "%v", err)
+			continue
+		}
+		valid = append(valid, record)
+	}
+
+	report.Records = len(valid)
+	return valid, report
+}
+
+// aliasSet is an alias record set awaiting flattening
+type aliasSet struct {
+	set        *RecordSet
+	name       string
+	recordType string
+	priority   int
+	labels     map[string]string
+}
+
+// routingPriority maps a set's routing policy onto an ErrantDNS priority.
+// It returns false when the policy cannot be expressed at all
+func routingPriority(set *RecordSet, name, recordType string, report *importer.Report) (int, bool) {
+	id := set.SetIdentifier
+
+	switch policy := set.routingPolicy(); policy {
+	case "simple", "multivalue":
+		return primaryPriority, true
+
+	case "weighted":
+		if *set.Weight == 0 {
+			report.Warn(name, recordType, id, "weighted routing approximated: weight 0 imported as a fallback group")
+			return secondaryPriority, true
+		}
+		report.Warn(name, recordType, id, "weighted routing approximated: weight %d served in equal rotation", *set.Weight)
+		return primaryPriority, true
+
+	case "failover":
+		if strings.EqualFold(set.Failover, "SECONDARY") {
+			report.Warn(name, recordType, id, "failover SECONDARY imported as a fallback group that is never served while PRIMARY exists")
+			return secondaryPriority, true
+		}
+		report.Warn(name, recordType, id, "failover PRIMARY imported without health-based failover")
+		return primaryPriority, true
+
+	case "latency":
+		report.Warn(name, recordType, id, "latency routing for region %s approximated as equal rotation", set.Region)
+		return primaryPriority, true
+
+	case "geolocation":
+		if set.GeoLocation.CountryCode == "*" {
+			report.Warn(name, recordType, id, "default geolocation set served to every client")
+			return primaryPriority, true
+		}
+		report.Skip(name, recordType, id, "geolocation routing for %s is not supported", geoDescription(set.GeoLocation))
+		return 0, false
+
+	default:
+		report.Skip(name, recordType, id, "%s routing is not supported", policy)
+		return 0, false
+	}
+}
+
+// usesRoutingPriority reports whether the priority column is free to carry
+// the routing group, rather than MX preference, SRV priority, or CAA flags
+func usesRoutingPriority(recordType string) bool {
+	switch recordType {
+	case "MX", "SRV", "CAA", "SOA":
+		return false
+	}
+	return true
+}
+
+// routingLabels records the source routing policy on imported records
+func routingLabels(set *RecordSet) map[string]string {
+	labels := map[string]string{"source": "route53"}
+
+	policy := set.routingPolicy()
+	if policy != "simple" {
+		labels["route53/routing"] = policy
+	}
+	if set.SetIdentifier != "" {
+		labels["route53/set-identifier"] = set.SetIdentifier
+	}
+	if set.Weight != nil {
+		labels["route53/weight"] = strconv.FormatInt(*set.Weight, 10)
+	}
+	if set.Region != "" {
+		labels["route53/region"] = set.Region
+	}
+	if set.Failover != "" {
+		labels["route53/failover"] = strings.ToLower(set.Failover)
+	}
+	return labels
+}
+
+// flattenAliases turns alias sets into copies of their targets' records,
+// following alias chains inside the zone
+func flattenAliases(ctx context.Context, aliases []*aliasSet, records []*models.DNSRecord, opts Options, report *importer.Report) []*models.DNSRecord {
+	index := make(map[string][]*models.DNSRecord)
+	for _, record := range records {
+		key := record.Name + "|" + record.RecordType
+		index[key] = append(index[key], record)
+	}
+
+	var flattened []*models.DNSRecord
+	pending := aliases
+
+	for depth := 0; depth < maxAliasDepth && len(pending) > 0; depth++ {
+		var unresolved []*aliasSet
+		for _, alias := range pending {
+			target := models.NormalizeDomainName(unescapeName(alias.set.AliasTarget.DNSName))
+			targets := index[target+"|"+alias.recordType]
+			if len(targets) == 0 {
+				unresolved = append(unresolved, alias)
+				continue
+			}
+
+			key := alias.name + "|" + alias.recordType
+			for _, source := range targets {
+				record := *source
+				record.Name = alias.name
+				if usesRoutingPriority(alias.recordType) {
+					// Shift the target's groups so its fallbacks stay fallbacks
+					record.Priority = source.Priority + alias.priority - primaryPriority
+				}
+				record.Labels = copyLabels(alias.labels)
+				record.Labels["route53/alias-target"] = target
+
+				flattened = append(flattened, &record)
+				index[key] = append(index[key], &record)
+			}
+		}
+
+		if len(unresolved) == len(pending) {
+			break // no progress; the rest point outside the zone
+		}
+		pending = unresolved
+	}
+
+	for _, alias := range pending {
+		target := models.NormalizeDomainName(unescapeName(alias.set.AliasTarget.DNSName))
+		id := alias.set.SetIdentifier
+
+		if !opts.ResolveAliases || (alias.recordType != "A" && alias.recordType != "AAAA") {
+			report.Skip(alias.name, alias.recordType, id, "alias to %s is outside the zone; use -resolve-aliases to snapshot its addresses", target)
+			continue
+		}
+
+		resolved, err := resolveAlias(ctx, alias, target)
+		if err != nil || len(resolved) == 0 {
+			report.Skip(alias.name, alias.recordType, id, "alias to %s could not be resolved: %v", target, err)
+			continue
+		}
+
+		report.Warn(alias.name, alias.recordType, id, "alias to %s snapshotted as %d address(es); changes to the target will not be followed", target, len(resolved))
+		flattened = append(flattened, resolved...)
+	}
+
+	return flattened
+}
+
+// resolveAlias looks up an alias target's current addresses
+func resolveAlias(ctx context.Context, alias *aliasSet, target string) ([]*models.DNSRecord, error) {
+	network := "ip4"
+	if alias.recordType == "AAAA" {
+		network = "ip6"
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*models.DNSRecord
+	for _, ip := range ips {
+		labels := copyLabels(alias.labels)
+		labels["route53/alias-target"] = target
+		records = append(records, &models.DNSRecord{
+			Name:       alias.name,
+			RecordType: alias.recordType,
+			Target:     ip.String(),
+			TTL:        aliasResolvedTTL,
+			Priority:   alias.priority,
+			Labels:     labels,
+		})
+	}
+	return records, nil
+}
+
+// parseValue converts one presentation-format value into a record
+func parseValue(name, recordType string, ttl uint32, value string) (*models.DNSRecord, error) {
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(name), ttl, recordType, value))
+	if err != nil {
+		return nil, err
+	}
+	if rr == nil {
+		return nil, fmt.Errorf("empty value")
+	}
+
+	return importer.RecordFromRR(rr)
+}
+
+func geoDescription(geo *GeoLocation) string {
+	var parts []string
+	for _, part := range []string{geo.ContinentCode, geo.CountryCode, geo.SubdivisionCode} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
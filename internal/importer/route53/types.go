@@ -0,0 +1,124 @@
+// internal/importer/route53/types.go
+package route53
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RecordSet is a Route 53 resource record set. Tags match both the API's
+// XML and the JSON printed by `aws route53 list-resource-record-sets`
+type RecordSet struct {
+	Name             string           `xml:"Name" json:"Name"`
+	Type             string           `xml:"Type" json:"Type"`
+	TTL              *uint32          `xml:"TTL" json:"TTL,omitempty"`
+	ResourceRecords  []ResourceRecord `xml:"ResourceRecords>ResourceRecord" json:"ResourceRecords,omitempty"`
+	AliasTarget      *AliasTarget     `xml:"AliasTarget" json:"AliasTarget,omitempty"`
+	SetIdentifier    string           `xml:"SetIdentifier" json:"SetIdentifier,omitempty"`
+	Weight           *int64           `xml:"Weight" json:"Weight,omitempty"`
+	Region           string           `xml:"Region" json:"Region,omitempty"`
+	Failover         string           `xml:"Failover" json:"Failover,omitempty"`
+	GeoLocation      *GeoLocation     `xml:"GeoLocation" json:"GeoLocation,omitempty"`
+	MultiValueAnswer *bool            `xml:"MultiValueAnswer" json:"MultiValueAnswer,omitempty"`
+	HealthCheckID    string           `xml:"HealthCheckId" json:"HealthCheckId,omitempty"`
+
+	// Present on sets using routing policies this importer cannot express
+	GeoProximityLocation *unsupportedConfig `xml:"GeoProximityLocation" json:"GeoProximityLocation,omitempty"`
+	CidrRoutingConfig    *unsupportedConfig `xml:"CidrRoutingConfig" json:"CidrRoutingConfig,omitempty"`
+}
+
+// ResourceRecord is one value of a record set in zone-file presentation format
+type ResourceRecord struct {
+	Value string `xml:"Value" json:"Value"`
+}
+
+// AliasTarget points a record set at another Route 53 name or AWS resource
+type AliasTarget struct {
+	HostedZoneID         string `xml:"HostedZoneId" json:"HostedZoneId"`
+	DNSName              string `xml:"DNSName" json:"DNSName"`
+	EvaluateTargetHealth bool   `xml:"EvaluateTargetHealth" json:"EvaluateTargetHealth"`
+}
+
+// unsupportedConfig records only that a routing configuration was present
+type unsupportedConfig struct{}
+
+// GeoLocation is the location a geolocation record set answers for
+type GeoLocation struct {
+	ContinentCode   string `xml:"ContinentCode" json:"ContinentCode,omitempty"`
+	CountryCode     string `xml:"CountryCode" json:"CountryCode,omitempty"`
+	SubdivisionCode string `xml:"SubdivisionCode" json:"SubdivisionCode,omitempty"`
+}
+
+// routingPolicy names the set's routing policy
+func (rs *RecordSet) routingPolicy() string {
+	switch {
+	case rs.GeoProximityLocation != nil:
+		return "geoproximity"
+	case rs.CidrRoutingConfig != nil:
+		return "cidr"
+	case rs.GeoLocation != nil:
+		return "geolocation"
+	case rs.Failover != "":
+		return "failover"
+	case rs.Region != "":
+		return "latency"
+	case rs.Weight != nil:
+		return "weighted"
+	case rs.MultiValueAnswer != nil && *rs.MultiValueAnswer:
+		return "multivalue"
+	default:
+		return "simple"
+	}
+}
+
+// exportFile is the document printed by `aws route53 list-resource-record-sets`
+type exportFile struct {
+	ResourceRecordSets []RecordSet `json:"ResourceRecordSets"`
+}
+
+// LoadJSON reads record sets from `aws route53 list-resource-record-sets`
+// output. A bare JSON array of record sets is also accepted
+func LoadJSON(r io.Reader) ([]RecordSet, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var sets []RecordSet
+		if err := json.Unmarshal(data, &sets); err != nil {
+			return nil, fmt.Errorf("failed to parse record set array: %w", err)
+		}
+		return sets, nil
+	}
+
+	var file exportFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse Route 53 export: %w", err)
+	}
+	return file.ResourceRecordSets, nil
+}
+
+// unescapeName decodes Route 53's octal escapes (e.g. \052 for '*')
+func unescapeName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+4 <= len(name) {
+			if code, err := strconv.ParseUint(name[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(code))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
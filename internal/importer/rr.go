@@ -0,0 +1,110 @@
+// internal/importer/rr.go
+package importer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/models"
+)
+
+// RecordFromRR converts a parsed resource record into an ErrantDNS record
+func RecordFromRR(rr dns.RR) (*models.DNSRecord, error) {
+	header := rr.Header()
+	record := &models.DNSRecord{
+		Name:       models.NormalizeDomainName(header.Name),
+		RecordType: dns.TypeToString[header.Rrtype],
+		TTL:        header.Ttl,
+	}
+
+	switch v := rr.(type) {
+	case *dns.A:
+		record.Target = v.A.String()
+	case *dns.AAAA:
+		record.Target = v.AAAA.String()
+	case *dns.CNAME:
+		record.Target = trimDot(v.Target)
+	case *dns.NS:
+		record.Target = trimDot(v.Ns)
+	case *dns.DNAME:
+		record.Target = trimDot(v.Target)
+	case *dns.PTR:
+		record.Target = trimDot(v.Ptr)
+	case *dns.TXT:
+		record.Target = strings.Join(v.Txt, "")
+	case *dns.MX:
+		record.Priority = int(v.Preference)
+		record.Target = trimDot(v.Mx)
+	case *dns.SRV:
+		record.Priority = int(v.Priority)
+		record.Weight = uint32(v.Weight)
+		record.Port = v.Port
+		record.Target = trimDot(v.Target)
+	case *dns.CAA:
+		record.Priority = int(v.Flag)
+		record.Tag = v.Tag
+		record.Target = v.Value
+	case *dns.DS:
+		record.Target = fmt.Sprintf("%d %d %d %s", v.KeyTag, v.Algorithm, v.DigestType, strings.ToUpper(v.Digest))
+	case *dns.CDS:
+		record.Target = fmt.Sprintf("%d %d %d %s", v.KeyTag, v.Algorithm, v.DigestType, strings.ToUpper(v.Digest))
+	case *dns.DNSKEY:
+		record.Target = fmt.Sprintf("%d %d %d %s", v.Flags, v.Protocol, v.Algorithm, v.PublicKey)
+	case *dns.CDNSKEY:
+		record.Target = fmt.Sprintf("%d %d %d %s", v.Flags, v.Protocol, v.Algorithm, v.PublicKey)
+	case *dns.LOC:
+		record.Target = (&models.LOCData{
+			Size:      v.Size,
+			HorizPre:  v.HorizPre,
+			VertPre:   v.VertPre,
+			Latitude:  v.Latitude,
+			Longitude: v.Longitude,
+			Altitude:  v.Altitude,
+		}).String()
+	case *dns.HINFO:
+		// The strings are already escaped for presentation
+		record.Target = `"` + v.Cpu + `" "` + v.Os + `"`
+	case *dns.SOA:
+		record.Target = trimDot(v.Ns)
+		record.Mbox = trimDot(v.Mbox)
+		record.Serial = v.Serial
+		record.Refresh = v.Refresh
+		record.Retry = v.Retry
+		record.Expire = v.Expire
+		record.Minttl = v.Minttl
+	default:
+		return genericRecord(record, rr)
+	}
+
+	return record, nil
+}
+
+// genericRecord stores rr, of a type without native support, in the
+// generic form of RFC 3597
+func genericRecord(record *models.DNSRecord, rr dns.RR) (*models.DNSRecord, error) {
+	header := rr.Header()
+	recordType := models.GenericRecordType(header.Rrtype)
+	if !recordType.IsGeneric() {
+		return nil, fmt.Errorf("unsupported record type %s", dns.Type(header.Rrtype))
+	}
+
+	generic := new(dns.RFC3597)
+	if err := generic.ToRFC3597(rr); err != nil {
+		return nil, fmt.Errorf("failed to encode %s record: %w", dns.Type(header.Rrtype), err)
+	}
+	data, err := hex.DecodeString(generic.Rdata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s record: %w", dns.Type(header.Rrtype), err)
+	}
+
+	record.RecordType = string(recordType)
+	record.Target = models.FormatGenericData(data)
+	return record, nil
+}
+
+func trimDot(name string) string {
+	return strings.TrimSuffix(name, ".")
+}
@@ -0,0 +1,76 @@
+// internal/logging/async.go
+package logging
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// asyncWriter hands log lines to a goroutine that writes them to the
+// underlying writer, so a stalled disk never blocks the caller. Lines that
+// arrive while the buffer is full are dropped and counted
+type asyncWriter struct {
+	out     io.Writer
+	lines   chan []byte
+	done    chan struct{}
+	dropped atomic.Int64
+
+	closeOnce sync.Once
+	mu        sync.RWMutex // guards sends against close of lines
+	closed    bool
+}
+
+// newAsyncWriter starts a writer buffering up to size lines for out
+func newAsyncWriter(out io.Writer, size int) *asyncWriter {
+	w := &asyncWriter{
+		out:   out,
+		lines: make(chan []byte, size),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues a copy of p, which slog handlers reuse, without blocking.
+// It never fails, since a dropped line isn't an error for the caller
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		w.dropped.Add(1)
+		return len(p), nil
+	}
+
+	select {
+	case w.lines <- append([]byte(nil), p...):
+	default:
+		w.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// run writes queued lines until the writer is closed and drained
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	for line := range w.lines {
+		_, _ = w.out.Write(line)
+	}
+}
+
+// Close stops accepting lines and waits for the queued ones to be written
+func (w *asyncWriter) Close() {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		close(w.lines)
+		w.mu.Unlock()
+	})
+	<-w.done
+}
+
+// Dropped returns how many lines were dropped because the buffer was full
+func (w *asyncWriter) Dropped() int64 {
+	return w.dropped.Load()
+}
@@ -0,0 +1,155 @@
+// internal/logging/levels.go
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// levelSet is the default level plus per-component overrides. It is
+// replaced as a whole when levels change, so readers never lock
+type levelSet struct {
+	def        slog.Level
+	components map[string]slog.Level
+}
+
+// level returns the level component logs at
+func (s *levelSet) level(component string) slog.Level {
+	if level, ok := s.components[component]; ok {
+		return level
+	}
+	return s.def
+}
+
+// min returns the lowest level any component logs at
+func (s *levelSet) min() slog.Level {
+	min := s.def
+	for _, level := range s.components {
+		if level < min {
+			min = level
+		}
+	}
+	return min
+}
+
+// ParseLevel parses a level name such as "debug" or "WARN"
+func ParseLevel(name string) (LogLevel, error) {
+	level := LogLevel(strings.ToUpper(strings.TrimSpace(name)))
+	switch level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+		return level, nil
+	}
+	return "", fmt.Errorf("invalid log level %q: must be DEBUG, INFO, WARN, or ERROR", name)
+}
+
+// ParseComponentLevels parses overrides of the form "component=LEVEL",
+// e.g. "storage=DEBUG" or "cache=WARN"
+func ParseComponentLevels(rules []string) (map[string]LogLevel, error) {
+	levels := make(map[string]LogLevel, len(rules))
+	for _, rule := range rules {
+		component, name, found := strings.Cut(rule, "=")
+		component = strings.TrimSpace(component)
+		if !found || component == "" {
+			return nil, fmt.Errorf("invalid component level %q: expected component=LEVEL", rule)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid component level %q: %w", rule, err)
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+// fromSlogLevel converts slog.Level back to our LogLevel
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level <= slog.LevelDebug:
+		return LevelDebug
+	case level <= slog.LevelInfo:
+		return LevelInfo
+	case level <= slog.LevelWarn:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+// enabled reports whether component logs messages at level
+func (l *Logger) enabled(component string, level slog.Level) bool {
+	return level >= l.levels.Load().level(component)
+}
+
+// levelKey is the context key a request's log level is stored under
+type levelKey struct{}
+
+// WithLevel returns ctx carrying a log level that replaces the component
+// levels for log calls given the context, e.g. to log one client's queries
+// in more detail. Levels below the configured ones must be enabled with
+// EnableContextLevel first
+func WithLevel(ctx context.Context, level LogLevel) context.Context {
+	return context.WithValue(ctx, levelKey{}, toSlogLevel(level))
+}
+
+// enabledContext reports whether component logs messages at level for a
+// call given ctx
+func (l *Logger) enabledContext(ctx context.Context, component string, level slog.Level) bool {
+	if ctx != nil {
+		if override, ok := ctx.Value(levelKey{}).(slog.Level); ok {
+			return level >= override
+		}
+	}
+	return l.enabled(component, level)
+}
+
+// EnableContextLevel lets contexts from WithLevel log down to level even
+// when no component does
+func (l *Logger) EnableContextLevel(level LogLevel) {
+	l.levelMutex.Lock()
+	defer l.levelMutex.Unlock()
+
+	if floor := toSlogLevel(level); floor < l.contextFloor {
+		l.contextFloor = floor
+	}
+	l.minLevel.Set(min(l.levels.Load().min(), l.contextFloor))
+}
+
+// Levels returns the default level and the per-component overrides
+func (l *Logger) Levels() (LogLevel, map[string]LogLevel) {
+	set := l.levels.Load()
+	components := make(map[string]LogLevel, len(set.components))
+	for component, level := range set.components {
+		components[component] = fromSlogLevel(level)
+	}
+	return fromSlogLevel(set.def), components
+}
+
+// SetLevels changes levels at runtime. A non-empty def replaces the default
+// level; each entry of components overrides that component's level, or
+// removes its override if empty
+func (l *Logger) SetLevels(def LogLevel, components map[string]LogLevel) {
+	l.levelMutex.Lock()
+	defer l.levelMutex.Unlock()
+
+	current := l.levels.Load()
+	next := &levelSet{def: current.def, components: make(map[string]slog.Level, len(current.components))}
+	for component, level := range current.components {
+		next.components[component] = level
+	}
+
+	if def != "" {
+		next.def = toSlogLevel(def)
+	}
+	for component, level := range components {
+		if level == "" {
+			delete(next.components, component)
+		} else {
+			next.components[component] = toSlogLevel(level)
+		}
+	}
+
+	l.levels.Store(next)
+	l.minLevel.Set(min(next.min(), l.contextFloor))
+}
@@ -2,6 +2,7 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -32,6 +34,9 @@ type Config struct {
 	EnableConsole   bool     `json:"enable_console"`
 	QuerySampleRate float64  `json:"query_sample_rate"`
 	BufferSize      int      `json:"buffer_size"`
+
+	// ComponentLevels overrides Level for the named components
+	ComponentLevels map[string]LogLevel `json:"component_levels"`
 }
 
 // DefaultConfig returns default logging configuration
@@ -55,6 +60,14 @@ type Logger struct {
 	queryLogger *slog.Logger
 	errorLogger *slog.Logger
 
+	// Per-component levels; minLevel gates the app handler at the lowest
+	levels     atomic.Pointer[levelSet]
+	levelMutex sync.Mutex
+	minLevel   slog.LevelVar
+
+	// contextFloor is the lowest level enabled for WithLevel contexts
+	contextFloor slog.Level
+
 	// Query sampling
 	sampleRNG   *rand.Rand
 	sampleMutex sync.Mutex
@@ -68,6 +81,9 @@ type Logger struct {
 	appFile   *os.File
 	queryFile *os.File
 	errorFile *os.File
+
+	// Buffered writers in front of the outputs, nil when BufferSize is 0
+	asyncWriters []*asyncWriter
 }
 
 var (
@@ -101,9 +117,12 @@ func newLogger(config *Config) (*Logger, error) {
 	}
 
 	logger := &Logger{
-		config:    config,
-		sampleRNG: rand.New(rand.NewSource(time.Now().UnixNano())),
+		config:       config,
+		sampleRNG:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		contextFloor: slog.LevelError,
 	}
+	logger.levels.Store(&levelSet{def: toSlogLevel(config.Level)})
+	logger.SetLevels("", config.ComponentLevels)
 
 	// Set up application logger
 	if err := logger.setupAppLogger(); err != nil {
@@ -141,10 +160,10 @@ func (l *Logger) setupAppLogger() error {
 		writers = append(writers, os.Stdout)
 	}
 
-	multiWriter := io.MultiWriter(writers...)
+	multiWriter := l.buffered(io.MultiWriter(writers...))
 
 	opts := &slog.HandlerOptions{
-		Level: l.getSlogLevel(),
+		Level: &l.minLevel,
 	}
 
 	handler := slog.NewJSONHandler(multiWriter, opts)
@@ -166,7 +185,7 @@ func (l *Logger) setupQueryLogger() error {
 		Level: slog.LevelDebug, // Query logger accepts all levels
 	}
 
-	handler := slog.NewJSONHandler(queryFile, opts)
+	handler := slog.NewJSONHandler(l.buffered(queryFile), opts)
 	l.queryLogger = slog.New(handler)
 
 	return nil
@@ -185,15 +204,35 @@ func (l *Logger) setupErrorLogger() error {
 		Level: slog.LevelWarn, // Errors and warnings only
 	}
 
-	handler := slog.NewJSONHandler(errorFile, opts)
+	handler := slog.NewJSONHandler(l.buffered(errorFile), opts)
 	l.errorLogger = slog.New(handler)
 
 	return nil
 }
 
-// getSlogLevel converts our LogLevel to slog.Level
-func (l *Logger) getSlogLevel() slog.Level {
-	switch l.config.Level {
+// buffered wraps out so log calls queue lines instead of writing them,
+// keeping disk stalls off the query path
+func (l *Logger) buffered(out io.Writer) io.Writer {
+	if l.config.BufferSize <= 0 {
+		return out
+	}
+	w := newAsyncWriter(out, l.config.BufferSize)
+	l.asyncWriters = append(l.asyncWriters, w)
+	return w
+}
+
+// Dropped returns how many log lines were dropped because a buffer was full
+func (l *Logger) Dropped() int64 {
+	var dropped int64
+	for _, w := range l.asyncWriters {
+		dropped += w.Dropped()
+	}
+	return dropped
+}
+
+// toSlogLevel converts our LogLevel to slog.Level
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
 	case LevelDebug:
 		return slog.LevelDebug
 	case LevelInfo:
@@ -209,7 +248,7 @@ func (l *Logger) getSlogLevel() slog.Level {
 
 // shouldSampleQuery determines if a query should be logged based on sampling rate
 func (l *Logger) shouldSampleQuery() bool {
-	if l.config.Level == LevelDebug {
+	if l.levels.Load().def == slog.LevelDebug {
 		return true // Always log in debug mode
 	}
 
@@ -221,18 +260,38 @@ func (l *Logger) shouldSampleQuery() bool {
 
 // Application Logging Methods
 
+// Enabled reports whether any component writes messages at level. Hot paths
+// use it to skip building log fields that would be discarded
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l.appLogger.Enabled(context.Background(), toSlogLevel(level))
+}
+
+// EnabledFor reports whether component writes messages at level
+func (l *Logger) EnabledFor(component string, level LogLevel) bool {
+	return l.enabled(component, toSlogLevel(level))
+}
+
 // Info logs an informational message
 func (l *Logger) Info(component, message string, fields ...interface{}) {
+	if !l.enabled(component, slog.LevelInfo) {
+		return
+	}
 	l.appLogger.Info(message, append([]interface{}{"component", component}, fields...)...)
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(component, message string, fields ...interface{}) {
+	if !l.enabled(component, slog.LevelWarn) {
+		return
+	}
 	l.appLogger.Warn(message, append([]interface{}{"component", component}, fields...)...)
 }
 
 // Error logs an error message
 func (l *Logger) Error(component, message string, err error, fields ...interface{}) {
+	if !l.enabled(component, slog.LevelError) {
+		return
+	}
 	allFields := append([]interface{}{"component", component}, fields...)
 	if err != nil {
 		allFields = append(allFields, "error", err.Error())
@@ -242,6 +301,9 @@ func (l *Logger) Error(component, message string, err error, fields ...interface
 
 // Debug logs a debug message
 func (l *Logger) Debug(component, message string, fields ...interface{}) {
+	if !l.enabled(component, slog.LevelDebug) {
+		return
+	}
 	l.appLogger.Debug(message, append([]interface{}{"component", component}, fields...)...)
 }
 
@@ -267,7 +329,7 @@ func (l *Logger) LogQuery(domain, queryType, result, source string, responseTime
 
 // LogQueryDebug logs a DNS query with full debug information
 func (l *Logger) LogQueryDebug(domain, queryType, result, source string, responseTime time.Duration, extra map[string]interface{}) {
-	if l.config.Level != LevelDebug {
+	if l.levels.Load().def != slog.LevelDebug {
 		return
 	}
 
@@ -291,9 +353,10 @@ func (l *Logger) LogQueryDebug(domain, queryType, result, source string, respons
 // Error Event Logging Methods
 
 // LogNXDOMAIN logs NXDOMAIN responses
-func (l *Logger) LogNXDOMAIN(domain, queryType string, responseTime time.Duration) {
+func (l *Logger) LogNXDOMAIN(ctx context.Context, domain, queryType string, responseTime time.Duration) {
 	l.errorLogger.Warn("nxdomain",
 		"event_type", "nxdomain",
+		"query_id", QueryID(ctx),
 		"domain", domain,
 		"type", queryType,
 		"response_time_ms", responseTime.Milliseconds(),
@@ -303,9 +366,10 @@ func (l *Logger) LogNXDOMAIN(domain, queryType string, responseTime time.Duratio
 }
 
 // LogQueryTimeout logs query timeouts
-func (l *Logger) LogQueryTimeout(domain, queryType string, timeout time.Duration) {
+func (l *Logger) LogQueryTimeout(ctx context.Context, domain, queryType string, timeout time.Duration) {
 	l.errorLogger.Error("query_timeout",
 		"event_type", "timeout",
+		"query_id", QueryID(ctx),
 		"domain", domain,
 		"type", queryType,
 		"timeout_ms", timeout.Milliseconds(),
@@ -314,6 +378,20 @@ func (l *Logger) LogQueryTimeout(domain, queryType string, timeout time.Duration
 	l.errorsLogged++
 }
 
+// LogSlowQuery logs a storage query that ran longer than threshold
+func (l *Logger) LogSlowQuery(ctx context.Context, sqlQuery string, args []interface{}, duration, threshold time.Duration) {
+	l.errorLogger.Warn("slow_query",
+		"event_type", "slow_query",
+		"query_id", QueryID(ctx),
+		"sql", sqlQuery,
+		"args", args,
+		"duration_ms", duration.Milliseconds(),
+		"threshold_ms", threshold.Milliseconds(),
+		"timestamp", time.Now().Unix(),
+	)
+	l.errorsLogged++
+}
+
 // LogCacheMiss logs cache misses for analysis
 func (l *Logger) LogCacheMiss(domain, queryType string, cacheLevel string) {
 	l.errorLogger.Info("cache_miss",
@@ -342,15 +420,20 @@ func (l *Logger) GetStats() map[string]interface{} {
 		"queries_logged":  l.queriesLogged,
 		"queries_sampled": l.queriesSampled,
 		"errors_logged":   l.errorsLogged,
+		"logs_dropped":    l.Dropped(),
 		"sample_rate":     l.config.QuerySampleRate,
-		"log_level":       string(l.config.Level),
+		"log_level":       string(fromSlogLevel(l.levels.Load().def)),
 	}
 }
 
-// Close closes all log files
+// Close writes out buffered log lines and closes all log files
 func (l *Logger) Close() error {
 	var lastErr error
 
+	for _, w := range l.asyncWriters {
+		w.Close()
+	}
+
 	if l.appFile != nil {
 		if err := l.appFile.Close(); err != nil {
 			lastErr = err
@@ -374,6 +457,28 @@ func (l *Logger) Close() error {
 
 // Global convenience functions for easy migration
 
+// Enabled reports whether the global logger writes messages at level
+func Enabled(level LogLevel) bool {
+	return GetLogger().Enabled(level)
+}
+
+// lazyf defers fmt.Sprintf until a log record is actually written
+type lazyf struct {
+	format string
+	args   []interface{}
+}
+
+// LogValue formats the message
+func (l lazyf) LogValue() slog.Value {
+	return slog.StringValue(fmt.Sprintf(l.format, l.args...))
+}
+
+// Lazyf returns a log field value that is only formatted if the record
+// passes the level filter, e.g. logging.Debug("dns", "msg", "details", logging.Lazyf(...))
+func Lazyf(format string, args ...interface{}) slog.LogValuer {
+	return lazyf{format: format, args: args}
+}
+
 // Info logs an informational message using the global logger
 func Info(component, message string, fields ...interface{}) {
 	GetLogger().Info(component, message, fields...)
@@ -400,13 +505,13 @@ func LogQuery(domain, queryType, result, source string, responseTime time.Durati
 }
 
 // LogNXDOMAIN logs NXDOMAIN responses using the global logger
-func LogNXDOMAIN(domain, queryType string, responseTime time.Duration) {
-	GetLogger().LogNXDOMAIN(domain, queryType, responseTime)
+func LogNXDOMAIN(ctx context.Context, domain, queryType string, responseTime time.Duration) {
+	GetLogger().LogNXDOMAIN(ctx, domain, queryType, responseTime)
 }
 
 // LogQueryTimeout logs query timeouts using the global logger
-func LogQueryTimeout(domain, queryType string, timeout time.Duration) {
-	GetLogger().LogQueryTimeout(domain, queryType, timeout)
+func LogQueryTimeout(ctx context.Context, domain, queryType string, timeout time.Duration) {
+	GetLogger().LogQueryTimeout(ctx, domain, queryType, timeout)
 }
 
 // LogCacheMiss logs cache misses using the global logger
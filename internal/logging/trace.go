@@ -0,0 +1,104 @@
+// internal/logging/trace.go
+package logging
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"math/rand/v2"
+)
+
+// queryIDKey is the context key a query ID is stored under
+type queryIDKey struct{}
+
+// NewQueryID returns a random 16-character hex ID for one DNS request.
+// IDs only need to be unique enough to tell log lines apart
+func NewQueryID() string {
+	var b [8]byte
+	v := rand.Uint64()
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithQueryID returns ctx carrying id; log calls given the context include
+// it as the query_id field
+func WithQueryID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, queryIDKey{}, id)
+}
+
+// QueryID returns the query ID carried by ctx, or "" if there is none
+func QueryID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(queryIDKey{}).(string)
+	return id
+}
+
+// withQueryID prepends the component and, if ctx carries one, the query ID
+func withQueryID(ctx context.Context, component string, fields []interface{}) []interface{} {
+	all := make([]interface{}, 0, len(fields)+4)
+	all = append(all, "component", component)
+	if id := QueryID(ctx); id != "" {
+		all = append(all, "query_id", id)
+	}
+	return append(all, fields...)
+}
+
+// InfoContext logs an informational message tagged with ctx's query ID
+func (l *Logger) InfoContext(ctx context.Context, component, message string, fields ...interface{}) {
+	if !l.enabledContext(ctx, component, slog.LevelInfo) {
+		return
+	}
+	l.appLogger.Info(message, withQueryID(ctx, component, fields)...)
+}
+
+// WarnContext logs a warning message tagged with ctx's query ID
+func (l *Logger) WarnContext(ctx context.Context, component, message string, fields ...interface{}) {
+	if !l.enabledContext(ctx, component, slog.LevelWarn) {
+		return
+	}
+	l.appLogger.Warn(message, withQueryID(ctx, component, fields)...)
+}
+
+// ErrorContext logs an error message tagged with ctx's query ID
+func (l *Logger) ErrorContext(ctx context.Context, component, message string, err error, fields ...interface{}) {
+	if !l.enabledContext(ctx, component, slog.LevelError) {
+		return
+	}
+	allFields := withQueryID(ctx, component, fields)
+	if err != nil {
+		allFields = append(allFields, "error", err.Error())
+	}
+	l.appLogger.Error(message, allFields...)
+}
+
+// DebugContext logs a debug message tagged with ctx's query ID
+func (l *Logger) DebugContext(ctx context.Context, component, message string, fields ...interface{}) {
+	if !l.enabledContext(ctx, component, slog.LevelDebug) {
+		return
+	}
+	l.appLogger.Debug(message, withQueryID(ctx, component, fields)...)
+}
+
+// InfoContext logs an informational message using the global logger
+func InfoContext(ctx context.Context, component, message string, fields ...interface{}) {
+	GetLogger().InfoContext(ctx, component, message, fields...)
+}
+
+// WarnContext logs a warning message using the global logger
+func WarnContext(ctx context.Context, component, message string, fields ...interface{}) {
+	GetLogger().WarnContext(ctx, component, message, fields...)
+}
+
+// ErrorContext logs an error message using the global logger
+func ErrorContext(ctx context.Context, component, message string, err error, fields ...interface{}) {
+	GetLogger().ErrorContext(ctx, component, message, err, fields...)
+}
+
+// DebugContext logs a debug message using the global logger
+func DebugContext(ctx context.Context, component, message string, fields ...interface{}) {
+	GetLogger().DebugContext(ctx, component, message, fields...)
+}
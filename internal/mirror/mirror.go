@@ -0,0 +1,229 @@
+// internal/mirror/mirror.go
+package mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+)
+
+// Config holds configuration for mirroring queries to a shadow server
+type Config struct {
+	Target     string        // shadow server, host or host:port
+	SampleRate float64       // fraction of queries mirrored, above 0 and at most 1
+	Timeout    time.Duration // per-query exchange timeout
+	QueueSize  int           // queries waiting to be mirrored; more are dropped
+	Workers    int           // concurrent exchanges with the shadow
+}
+
+// DefaultConfig returns mirror config with sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		SampleRate: 0.01,
+		Timeout:    2 * time.Second,
+		QueueSize:  1024,
+		Workers:    4,
+	}
+}
+
+// Stats counts mirrored queries and how the shadow's answers compared
+type Stats struct {
+	Target           string `json:"target"`
+	Sampled          int64  `json:"sampled"`
+	Dropped          int64  `json:"dropped"` // sampled while the queue was full
+	Failed           int64  `json:"failed"`  // the shadow didn't answer
+	Matched          int64  `json:"matched"`
+	Mismatched       int64  `json:"mismatched"`
+	RcodeMismatches  int64  `json:"rcode_mismatches"`
+	AnswerMismatches int64  `json:"answer_mismatches"` // same rcode, different answer records
+}
+
+// exchange is a query and the answer our server gave it
+type exchange struct {
+	query  *dns.Msg
+	answer *dns.Msg
+}
+
+// Mirror sends a sample of the queries we answer to a shadow server in the
+// background and compares its answers with ours, so a new build or backend
+// can be checked against live traffic before it takes any. Clients never
+// wait on the shadow; when it can't keep up, queries are dropped from the
+// sample
+type Mirror struct {
+	target  string
+	rate    float64
+	workers int
+	udp     *dns.Client
+	tcp     *dns.Client
+	queue   chan exchange
+
+	sampled          atomic.Int64
+	dropped          atomic.Int64
+	failed           atomic.Int64
+	matched          atomic.Int64
+	rcodeMismatches  atomic.Int64
+	answerMismatches atomic.Int64
+}
+
+// New creates a mirror to config's target, returning an error for an
+// invalid target or sample rate
+func New(config *Config) (*Mirror, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	target := config.Target
+	if target == "" {
+		return nil, errors.New("a shadow server is required")
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(strings.Trim(target, "[]"), "53")
+	}
+	if _, port, err := net.SplitHostPort(target); err != nil || port == "" {
+		return nil, fmt.Errorf("invalid shadow server %q", config.Target)
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		return nil, fmt.Errorf("invalid sample rate %v: must be above 0 and at most 1", config.SampleRate)
+	}
+
+	workers := config.Workers
+	if workers <= 0 {
+		workers = DefaultConfig().Workers
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultConfig().QueueSize
+	}
+
+	return &Mirror{
+		target:  target,
+		rate:    config.SampleRate,
+		workers: workers,
+		udp:     &dns.Client{Net: "udp", Timeout: config.Timeout},
+		tcp:     &dns.Client{Net: "tcp", Timeout: config.Timeout},
+		queue:   make(chan exchange, queueSize),
+	}, nil
+}
+
+// Sample reports whether a query should be mirrored. Zone transfers never
+// are
+func (m *Mirror) Sample(query *dns.Msg) bool {
+	if len(query.Question) == 0 {
+		return false
+	}
+	switch query.Question[0].Qtype {
+	case dns.TypeAXFR, dns.TypeIXFR:
+		return false
+	}
+	return rand.Float64() < m.rate
+}
+
+// Submit queues a sampled query and our answer to it for mirroring without
+// blocking. Both are copied, so the caller may reuse them
+func (m *Mirror) Submit(query, answer *dns.Msg) {
+	m.sampled.Add(1)
+
+	select {
+	case m.queue <- exchange{query: query.Copy(), answer: answer.Copy()}:
+	default:
+		m.dropped.Add(1)
+	}
+}
+
+// Run mirrors queued queries until the context is cancelled. Queries still
+// queued then are discarded
+func (m *Mirror) Run(ctx context.Context) {
+	done := make(chan struct{}, m.workers)
+	for range m.workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ex := <-m.queue:
+					m.mirror(ctx, ex)
+				}
+			}
+		}()
+	}
+	for range m.workers {
+		<-done
+	}
+}
+
+// mirror sends one query to the shadow and compares the answers
+func (m *Mirror) mirror(ctx context.Context, ex exchange) {
+	ours := ex.answer
+
+	// Retry over TCP when only the shadow truncated. When we truncated too,
+	// only the rcodes are compared
+	query := ex.query
+	query.Id = dns.Id()
+	theirs, _, err := m.udp.ExchangeContext(ctx, query, m.target)
+	if err == nil && theirs.Truncated && !ours.Truncated {
+		theirs, _, err = m.tcp.ExchangeContext(ctx, query, m.target)
+	}
+	if err != nil {
+		m.failed.Add(1)
+		logging.Debug("mirror", "Shadow server didn't answer", "target", m.target, "error", err.Error())
+		return
+	}
+
+	question := query.Question[0]
+	switch {
+	case theirs.Rcode != ours.Rcode:
+		m.rcodeMismatches.Add(1)
+		logging.Debug("mirror", "Shadow answered with a different rcode",
+			"name", question.Name, "type", dns.TypeToString[question.Qtype],
+			"ours", dns.RcodeToString[ours.Rcode], "theirs", dns.RcodeToString[theirs.Rcode])
+	case !ours.Truncated && !theirs.Truncated && !slices.Equal(answerSet(ours), answerSet(theirs)):
+		m.answerMismatches.Add(1)
+		logging.Debug("mirror", "Shadow answered with different records",
+			"name", question.Name, "type", dns.TypeToString[question.Qtype],
+			"ours", len(ours.Answer), "theirs", len(theirs.Answer))
+	default:
+		m.matched.Add(1)
+	}
+}
+
+// answerSet returns a message's answer records in a canonical form, sorted
+// and without TTLs, which jitter and caching make differ between servers
+func answerSet(msg *dns.Msg) []string {
+	records := make([]string, 0, len(msg.Answer))
+	for _, rr := range msg.Answer {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		rr.Header().Name = strings.ToLower(rr.Header().Name)
+		records = append(records, rr.String())
+	}
+	slices.Sort(records)
+	return records
+}
+
+// Stats returns how many queries were mirrored and how their answers
+// compared
+func (m *Mirror) Stats() *Stats {
+	rcode := m.rcodeMismatches.Load()
+	answer := m.answerMismatches.Load()
+	return &Stats{
+		Target:           m.target,
+		Sampled:          m.sampled.Load(),
+		Dropped:          m.dropped.Load(),
+		Failed:           m.failed.Load(),
+		Matched:          m.matched.Load(),
+		Mismatched:       rcode + answer,
+		RcodeMismatches:  rcode,
+		AnswerMismatches: answer,
+	}
+}
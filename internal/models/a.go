@@ -4,7 +4,8 @@
 // - Must contain valid IPv4 address (dotted decimal notation)
 // - Cannot be empty
 // - Rejects IPv6 addresses (use AAAA instead)
-// - Rejects Class E addresses (240.0.0.0/4)
+// - Rejects Class E addresses (240.0.0.0/4), unless the validation
+//   profile allows them
 // - Rejects addresses starting with 0.x.x.x
 // - Allows private/internal addresses (10.x.x.x, 192.168.x.x, etc.)
 //
@@ -46,8 +47,8 @@ func (r *DNSRecord) validateARecord() error {
 		return fmt.Errorf("A record target cannot start with 0: %s", r.Target)
 	}
 
-	// Class E addresses (240.0.0.0/4) are reserved
-	if ipv4[0] >= 240 {
+	// Class E addresses (240.0.0.0/4) are reserved, but used in some labs
+	if ipv4[0] >= 240 && !CurrentValidationProfile().AllowClassE {
 		return fmt.Errorf("A record target cannot use Class E address space: %s", r.Target)
 	}
 
@@ -0,0 +1,70 @@
+// Record Changesets
+//
+// A changeset is a batch of record changes applied atomically: either every
+// change is stored or none is. Changes are applied in order, so a later
+// change can rely on an earlier one (e.g. delete an A record, then create a
+// CNAME at the same name).
+//
+// Actions:
+// - CREATE: store Record as a new record
+// - UPDATE: replace the record with Record.ID
+// - UPSERT: create Record, or update the existing record with its identity
+// - DELETE: remove the record with ID
+//
+// Every zone a changeset touches gets its SOA serial advanced once, by the
+// zone's serial strategy (see serial.go), however many of its records
+// changed.
+package models
+
+import "fmt"
+
+// ChangeAction is the operation a Change applies
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "CREATE"
+	ChangeUpdate ChangeAction = "UPDATE"
+	ChangeUpsert ChangeAction = "UPSERT"
+	ChangeDelete ChangeAction = "DELETE"
+)
+
+// Change is one operation in a changeset
+type Change struct {
+	Action ChangeAction `json:"action"`
+	Record *DNSRecord   `json:"record,omitempty"` // CREATE, UPDATE, UPSERT
+	ID     int          `json:"id,omitempty"`     // DELETE
+}
+
+// Validate checks that the change is well formed and its record is valid
+func (c *Change) Validate() error {
+	switch c.Action {
+	case ChangeCreate, ChangeUpsert:
+		if c.Record == nil {
+			return fmt.Errorf("%s requires a record", c.Action)
+		}
+	case ChangeUpdate:
+		if c.Record == nil {
+			return fmt.Errorf("%s requires a record", c.Action)
+		}
+		if c.Record.ID <= 0 {
+			return fmt.Errorf("%s requires a record ID", c.Action)
+		}
+	case ChangeDelete:
+		if c.ID <= 0 {
+			return fmt.Errorf("%s requires an ID", c.Action)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown change action %q", c.Action)
+	}
+
+	return c.Record.Validate()
+}
+
+// ChangesetResult summarizes an applied changeset
+type ChangesetResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Deleted int               `json:"deleted"`
+	Serials map[string]uint32 `json:"serials,omitempty"` // new SOA serial per touched zone
+}
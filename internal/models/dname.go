@@ -0,0 +1,74 @@
+// DNAME Record Validation
+//
+// A DNAME record (RFC 6672) redirects every name below its owner to the
+// same name below its target: with "old.example.com DNAME new.example.net",
+// a query for www.old.example.com is answered with the DNAME and a CNAME
+// synthesized from it, www.old.example.com CNAME www.new.example.net. The
+// owner itself isn't redirected.
+//
+// Rules:
+// - Target must be a valid domain name, not an IP address
+// - Target can't be the owner or below it, which would redirect forever
+//
+// Examples:
+// old.example.com -> new.example.net (valid)
+// old.example.com -> v2.old.example.com (invalid, below the owner)
+// old.example.com -> 192.0.2.1 (invalid, IP address)
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// maxNameLength is the longest domain name in presentation format, without
+// the trailing dot (RFC 1035 section 3.1)
+const maxNameLength = 253
+
+// ErrDNAMETooLong is returned when a DNAME's substitution yields a name too
+// long to exist, answered with YXDOMAIN (RFC 6672 section 2.2)
+var ErrDNAMETooLong = errors.New("name substituted by DNAME is too long")
+
+// IsSubdomain reports whether name is strictly below parent
+func IsSubdomain(name, parent string) bool {
+	name, parent = NormalizeDomainName(name), NormalizeDomainName(parent)
+	return parent != "" && strings.HasSuffix(name, "."+parent)
+}
+
+// DNAMESubstitute returns the name a DNAME at owner with target redirects
+// name to, replacing owner's labels at the end of name with target. name
+// must be below owner
+func DNAMESubstitute(name, owner, target string) (string, error) {
+	name, owner = NormalizeDomainName(name), NormalizeDomainName(owner)
+	if !IsSubdomain(name, owner) {
+		return "", fmt.Errorf("%s is not below DNAME owner %s", name, owner)
+	}
+
+	substituted := strings.TrimSuffix(name, owner) + NormalizeDomainName(target)
+	if len(substituted) > maxNameLength {
+		return "", ErrDNAMETooLong
+	}
+	return substituted, nil
+}
+
+// validateDNAMERecord checks the target of a DNAME record
+func (r *DNSRecord) validateDNAMERecord() error {
+	if err := r.validateDomainName(); err != nil {
+		return fmt.Errorf("DNAME record owner is not a valid domain name: %s", r.Name)
+	}
+	if net.ParseIP(r.Target) != nil {
+		return fmt.Errorf("DNAME record target cannot be an IP address: %s", r.Target)
+	}
+
+	// Checked on a copy, since validation records the apex of the name
+	target := NormalizeDomainName(r.Target)
+	if err := (&DNSRecord{Name: target}).validateDomainName(); err != nil {
+		return fmt.Errorf("DNAME record target is not a valid domain name: %s", r.Target)
+	}
+	if target == NormalizeDomainName(r.Name) || IsSubdomain(target, r.Name) {
+		return fmt.Errorf("DNAME record target cannot be at or below its owner %s", r.Name)
+	}
+	return nil
+}
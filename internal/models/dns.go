@@ -32,6 +32,92 @@ type DNSRecord struct {
 	Weight          uint32    `db:"weight"`
 	Port            uint16    `db:"port"`
 	Tag             string    `db:"tag"`
+
+	// ExpiresAt hides the record after this time; nil means it never expires
+	ExpiresAt *time.Time `db:"expires_at"`
+
+	// NotBefore and NotAfter bound the window in which the record is served.
+	// Unlike ExpiresAt, records outside the window are kept
+	NotBefore *time.Time `db:"not_before"`
+	NotAfter  *time.Time `db:"not_after"`
+
+	// Operator metadata, never served over DNS
+	Labels  map[string]string `db:"labels"`
+	Comment string            `db:"comment"`
+	Owner   string            `db:"owner"`
+
+	// ExternalKey identifies the record to the tool managing it; upserts
+	// carrying one update the record holding it
+	ExternalKey string `db:"external_key"`
+
+	// Version increments on every update. Updates carrying a non-zero
+	// Version only apply if the stored record still has that version
+	Version int `db:"version"`
+}
+
+// IsExpired reports whether the record's expiration time has passed
+func (r *DNSRecord) IsExpired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// IsActive reports whether the record should be served at now: it has not
+// expired and now falls within its not_before/not_after window
+func (r *DNSRecord) IsActive(now time.Time) bool {
+	if r.IsExpired(now) {
+		return false
+	}
+	if r.NotBefore != nil && now.Before(*r.NotBefore) {
+		return false
+	}
+	if r.NotAfter != nil && !now.Before(*r.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// EffectiveTTL returns the record TTL, capped so resolvers don't cache the
+// record past its expiration time or the end of its activation window
+func (r *DNSRecord) EffectiveTTL() uint32 {
+	end := r.ExpiresAt
+	if r.NotAfter != nil && (end == nil || r.NotAfter.Before(*end)) {
+		end = r.NotAfter
+	}
+	if end == nil {
+		return r.TTL
+	}
+
+	remaining := time.Until(*end)
+	if remaining <= 0 {
+		return 0
+	}
+	if seconds := uint32(remaining / time.Second); seconds < r.TTL {
+		return seconds
+	}
+	return r.TTL
+}
+
+// LiveRecords returns records that are active now. The input slice is
+// returned unchanged when every record is active so cache hits don't allocate
+func LiveRecords(records []*DNSRecord) []*DNSRecord {
+	now := time.Now()
+
+	inactive := 0
+	for _, record := range records {
+		if !record.IsActive(now) {
+			inactive++
+		}
+	}
+	if inactive == 0 {
+		return records
+	}
+
+	live := make([]*DNSRecord, 0, len(records)-inactive)
+	for _, record := range records {
+		if record.IsActive(now) {
+			live = append(live, record)
+		}
+	}
+	return live
 }
 
 // RecordType represents supported DNS record types
@@ -48,12 +134,28 @@ const (
 	RecordTypePTR   RecordType = "PTR"
 	RecordTypeSRV   RecordType = "SRV"
 	RecordTypeCAA   RecordType = "CAA"
+
+	RecordTypeDS      RecordType = "DS"
+	RecordTypeDNSKEY  RecordType = "DNSKEY"
+	RecordTypeCDS     RecordType = "CDS"
+	RecordTypeCDNSKEY RecordType = "CDNSKEY"
+
+	RecordTypeLOC   RecordType = "LOC"
+	RecordTypeHINFO RecordType = "HINFO"
+	RecordTypeDNAME RecordType = "DNAME"
 )
 
-// IsValid returns true if the record type is supported
+// IsValid returns true if the record type is supported, natively or as a
+// generic type (see generic.go)
 func (rt RecordType) IsValid() bool {
+	return rt.IsNative() || rt.IsGeneric()
+}
+
+// IsNative returns true if the record type is supported natively
+func (rt RecordType) IsNative() bool {
 	switch rt {
-	case RecordTypeA, RecordTypeAAAA, RecordTypeCNAME, RecordTypeTXT, RecordTypeMX, RecordTypeNS, RecordTypeSOA, RecordTypePTR, RecordTypeSRV, RecordTypeCAA:
+	case RecordTypeA, RecordTypeAAAA, RecordTypeCNAME, RecordTypeTXT, RecordTypeMX, RecordTypeNS, RecordTypeSOA, RecordTypePTR, RecordTypeSRV, RecordTypeCAA,
+		RecordTypeDS, RecordTypeDNSKEY, RecordTypeCDS, RecordTypeCDNSKEY, RecordTypeLOC, RecordTypeHINFO, RecordTypeDNAME:
 		return true
 	default:
 		return false
@@ -150,12 +252,45 @@ func (r *DNSRecord) Validate() error {
 		if err := r.validateCAARecord(); err != nil {
 			return fmt.Errorf("invalid CAA record: %s: %w", r.Target, err)
 		}
+	case RecordTypeDS, RecordTypeCDS:
+		if err := r.validateDSRecord(); err != nil {
+			return fmt.Errorf("invalid %s record: %s: %w", r.RecordType, r.Target, err)
+		}
+	case RecordTypeDNSKEY, RecordTypeCDNSKEY:
+		if err := r.validateDNSKEYRecord(); err != nil {
+			return fmt.Errorf("invalid %s record: %s: %w", r.RecordType, r.Target, err)
+		}
+	case RecordTypeLOC:
+		if err := r.validateLOCRecord(); err != nil {
+			return fmt.Errorf("invalid LOC record: %s: %w", r.Target, err)
+		}
+	case RecordTypeHINFO:
+		if err := r.validateHINFORecord(); err != nil {
+			return fmt.Errorf("invalid HINFO record: %s: %w", r.Target, err)
+		}
+	case RecordTypeDNAME:
+		if err := r.validateDNAMERecord(); err != nil {
+			return fmt.Errorf("invalid DNAME record: %s: %w", r.Target, err)
+		}
+	default:
+		// Only generic types get past IsValid
+		if err := r.validateGenericRecord(); err != nil {
+			return fmt.Errorf("invalid %s record: %w", r.RecordType, err)
+		}
 	}
 
 	if r.TTL > 2147483647 {
 		return fmt.Errorf("TTL too large: %d", r.TTL)
 	}
 
+	if r.NotBefore != nil && r.NotAfter != nil && !r.NotBefore.Before(*r.NotAfter) {
+		return fmt.Errorf("not_before must be earlier than not_after")
+	}
+
+	if err := r.validateMetadata(); err != nil {
+		return fmt.Errorf("invalid metadata: %w", err)
+	}
+
 	return nil
 }
 
@@ -167,7 +302,7 @@ func (r *DNSRecord) Normalize() {
 	// Normalize target based on record type
 	recordType := RecordType(r.RecordType)
 	switch recordType {
-	case RecordTypeCNAME, RecordTypeNS, RecordTypeMX:
+	case RecordTypeCNAME, RecordTypeNS, RecordTypeMX, RecordTypeDNAME:
 		// Ensure domain targets are normalized
 		r.Target = NormalizeDomainName(r.Target)
 	case RecordTypeA, RecordTypeAAAA:
@@ -175,6 +310,28 @@ func (r *DNSRecord) Normalize() {
 		if ip := net.ParseIP(r.Target); ip != nil {
 			r.Target = ip.String()
 		}
+	case RecordTypeDS, RecordTypeCDS:
+		if data, err := ParseDSData(r.Target, recordType == RecordTypeCDS); err == nil {
+			r.Target = data.String()
+		}
+	case RecordTypeDNSKEY, RecordTypeCDNSKEY:
+		if data, err := ParseDNSKEYData(r.Target, recordType == RecordTypeCDNSKEY); err == nil {
+			r.Target = data.String()
+		}
+	case RecordTypeLOC:
+		if data, err := ParseLOCData(r.Target); err == nil {
+			r.Target = data.String()
+		}
+	case RecordTypeHINFO:
+		if data, err := ParseHINFOData(r.Target); err == nil {
+			r.Target = data.String()
+		}
+	default:
+		if recordType.IsGeneric() {
+			if data, err := ParseGenericData(r.Target); err == nil {
+				r.Target = FormatGenericData(data)
+			}
+		}
 	}
 }
 
@@ -0,0 +1,170 @@
+// DNSSEC Delegation Record Validation
+//
+// DS and DNSKEY records, and their child-published CDS and CDNSKEY
+// counterparts (RFC 7344), keep their RDATA in presentation format in the
+// target:
+// - DS, CDS: "<key tag> <algorithm> <digest type> <digest hex>"
+// - DNSKEY, CDNSKEY: "<flags> <protocol> <algorithm> <public key base64>"
+//
+// Rules:
+// - Algorithms and digest types are 1-255; 0 is reserved
+// - DS digests of known types have their type's length (SHA-1: 20 bytes)
+// - DNSKEY protocol must be 3
+// - DNSKEY flags may only set ZONE (256), REVOKE (128), and SEP (1)
+// - CDS "0 0 0 00" and CDNSKEY "0 3 0 AA==" ask for deletion (RFC 8078)
+//
+// Targets are stored canonically: single spaces, digests in uppercase hex,
+// and public keys as unbroken base64.
+//
+// Examples:
+// DS: "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118" (valid)
+// DNSKEY: "257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+Gq..." (valid)
+// DS: "60485 5 2 2BB183AF" (invalid, SHA-256 digests are 32 bytes)
+package models
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DNSKEY flags (RFC 4034 section 2.1.1, RFC 5011 section 7)
+const (
+	DNSKEYFlagZone   = 256
+	DNSKEYFlagRevoke = 128
+	DNSKEYFlagSEP    = 1
+)
+
+// dsDigestLengths are the digest sizes of known DS digest types
+var dsDigestLengths = map[uint8]int{
+	1: 20, // SHA-1
+	2: 32, // SHA-256
+	3: 32, // GOST R 34.11-94
+	4: 48, // SHA-384
+}
+
+// DSData is the RDATA of a DS or CDS record
+type DSData struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     string // uppercase hex
+}
+
+// ParseDSData parses and checks DS RDATA in presentation format. cds
+// allows the CDS delete form
+func ParseDSData(target string, cds bool) (*DSData, error) {
+	fields := strings.Fields(target)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("expected key tag, algorithm, digest type, and digest")
+	}
+
+	keyTag, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key tag %q", fields[0])
+	}
+	algorithm, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid algorithm %q", fields[1])
+	}
+	digestType, err := strconv.ParseUint(fields[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest type %q", fields[2])
+	}
+	digest := strings.ToUpper(strings.Join(fields[3:], ""))
+	raw, err := hex.DecodeString(digest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest: %w", err)
+	}
+
+	data := &DSData{KeyTag: uint16(keyTag), Algorithm: uint8(algorithm), DigestType: uint8(digestType), Digest: digest}
+	if cds && data.KeyTag == 0 && data.Algorithm == 0 && data.DigestType == 0 && len(raw) == 1 && raw[0] == 0 {
+		return data, nil
+	}
+
+	if data.Algorithm == 0 {
+		return nil, fmt.Errorf("algorithm 0 is reserved")
+	}
+	if data.DigestType == 0 {
+		return nil, fmt.Errorf("digest type 0 is reserved")
+	}
+	if want, ok := dsDigestLengths[data.DigestType]; ok && len(raw) != want {
+		return nil, fmt.Errorf("digest type %d digests are %d bytes, got %d", data.DigestType, want, len(raw))
+	}
+	return data, nil
+}
+
+// String renders the RDATA in canonical presentation format
+func (d *DSData) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, d.Digest)
+}
+
+// DNSKEYData is the RDATA of a DNSKEY or CDNSKEY record
+type DNSKEYData struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey string // base64
+}
+
+// ParseDNSKEYData parses and checks DNSKEY RDATA in presentation format.
+// cdnskey allows the CDNSKEY delete form
+func ParseDNSKEYData(target string, cdnskey bool) (*DNSKEYData, error) {
+	fields := strings.Fields(target)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("expected flags, protocol, algorithm, and public key")
+	}
+
+	flags, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flags %q", fields[0])
+	}
+	protocol, err := strconv.ParseUint(fields[1], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protocol %q", fields[1])
+	}
+	algorithm, err := strconv.ParseUint(fields[2], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid algorithm %q", fields[2])
+	}
+	publicKey := strings.Join(fields[3:], "")
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	data := &DNSKEYData{Flags: uint16(flags), Protocol: uint8(protocol), Algorithm: uint8(algorithm), PublicKey: publicKey}
+	if data.Protocol != 3 {
+		return nil, fmt.Errorf("protocol must be 3, got %d", data.Protocol)
+	}
+	if cdnskey && data.Flags == 0 && data.Algorithm == 0 && len(raw) == 1 && raw[0] == 0 {
+		return data, nil
+	}
+
+	if data.Flags&^(DNSKEYFlagZone|DNSKEYFlagRevoke|DNSKEYFlagSEP) != 0 {
+		return nil, fmt.Errorf("flags %d set reserved bits; only 256 (zone), 128 (revoke), and 1 (SEP) are defined", data.Flags)
+	}
+	if data.Algorithm == 0 {
+		return nil, fmt.Errorf("algorithm 0 is reserved")
+	}
+	return data, nil
+}
+
+// String renders the RDATA in canonical presentation format
+func (d *DNSKEYData) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.Flags, d.Protocol, d.Algorithm, d.PublicKey)
+}
+
+// validateDSRecord checks the target of a DS or CDS record
+func (r *DNSRecord) validateDSRecord() error {
+	_, err := ParseDSData(r.Target, RecordType(r.RecordType) == RecordTypeCDS)
+	return err
+}
+
+// validateDNSKEYRecord checks the target of a DNSKEY or CDNSKEY record
+func (r *DNSRecord) validateDNSKEYRecord() error {
+	_, err := ParseDNSKEYData(r.Target, RecordType(r.RecordType) == RecordTypeCDNSKEY)
+	return err
+}
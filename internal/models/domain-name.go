@@ -8,12 +8,14 @@
 // - Label length: 1-63 characters each
 // - Valid characters: a-z, A-Z, 0-9, hyphens (not at label start/end)
 // - TLD requirements: minimum 2 chars, must start with letter, not all-numeric
+//   (the last two are relaxed by the numeric-tld validation relaxation)
 // - Wildcard labels: "*" allowed, partial wildcards rejected
 //
 // Public Suffix List Integration:
 // - Uses golang.org/x/net/publicsuffix for authoritative ETLD detection
 // - Handles complex suffixes: "co.uk", "github.io", "s3.amazonaws.com"
 // - Supports both ICANN and private suffixes
+// - Merges custom suffix rules for internal TLDs (see suffix.go)
 // - Accurately identifies registrable domain boundaries
 //
 // ETLD Processing:
@@ -35,8 +37,6 @@ package models
 import (
 	"fmt"
 	"strings"
-
-	"golang.org/x/net/publicsuffix"
 )
 
 // validateDomainName validates the domain name and extracts ETLD/apex information
@@ -137,16 +137,27 @@ func (r *DNSRecord) validateDomainNameOther(domain string) error {
 	return nil
 }
 
+// ApexDomain returns the registrable domain (ETLD+1) for a name, or the
+// normalized name itself when it is a bare public suffix
+func ApexDomain(name string) string {
+	domain := NormalizeDomainName(name)
+	apex, err := EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+	return apex
+}
+
 // extractAndSetETLDInfo extracts ETLD using Public Suffix List and sets DNSRecord fields
 func (r *DNSRecord) extractAndSetETLDInfo(domain string) error {
 	// Get the effective TLD + 1 (the registrable domain)
-	etldPlusOne, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	etldPlusOne, err := EffectiveTLDPlusOne(domain)
 	if err != nil {
 		return fmt.Errorf("failed to determine ETLD+1 for %s: %w", domain, err)
 	}
 
 	// Get just the effective TLD
-	etld, icann := publicsuffix.PublicSuffix(domain)
+	etld, icann := PublicSuffix(domain)
 	if etld == "" {
 		return fmt.Errorf("failed to determine public suffix for %s", domain)
 	}
@@ -256,6 +267,11 @@ func (r *DNSRecord) validateTLD(tld string) error {
 		return fmt.Errorf("TLD too short: %d characters (minimum 2)", len(tld))
 	}
 
+	// Internal zones may use numeric TLDs where the profile allows
+	if CurrentValidationProfile().AllowNumericTLD {
+		return nil
+	}
+
 	// TLD cannot be all numeric (RFC 3696)
 	allNumeric := true
 	for _, r := range tld {
@@ -0,0 +1,128 @@
+// Generic Record Types (RFC 3597)
+//
+// Types without native support can be stored as "TYPEnnn", nnn being the
+// type's number, with their RDATA in the generic form:
+//
+//	\# <length> <hex>
+//
+// e.g. "\# 4 0a000001". A target of bare hex digits is accepted too, and
+// stored in the generic form. Records are served exactly as stored, so
+// the RDATA must be valid wire format for the type.
+//
+// Natively supported types can't be stored generically, nor can types that
+// never appear in zone data: 0, OPT, meta and query types (128-255), and
+// the reserved 65535.
+package models
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxGenericData is the most RDATA a record can carry
+const maxGenericData = 65535
+
+// nativeTypeCodes are the type numbers of natively supported types
+var nativeTypeCodes = map[uint16]RecordType{
+	1:   RecordTypeA,
+	2:   RecordTypeNS,
+	5:   RecordTypeCNAME,
+	6:   RecordTypeSOA,
+	12:  RecordTypePTR,
+	15:  RecordTypeMX,
+	16:  RecordTypeTXT,
+	28:  RecordTypeAAAA,
+	33:  RecordTypeSRV,
+	257: RecordTypeCAA,
+
+	43: RecordTypeDS,
+	48: RecordTypeDNSKEY,
+	59: RecordTypeCDS,
+	60: RecordTypeCDNSKEY,
+
+	29: RecordTypeLOC,
+	13: RecordTypeHINFO,
+	39: RecordTypeDNAME,
+}
+
+// GenericRecordType returns the generic name of type number code
+func GenericRecordType(code uint16) RecordType {
+	return RecordType("TYPE" + strconv.Itoa(int(code)))
+}
+
+// GenericCode returns the type number of a generic type name, reporting
+// whether rt is one that can be stored
+func (rt RecordType) GenericCode() (uint16, bool) {
+	digits, ok := strings.CutPrefix(string(rt), "TYPE")
+	if !ok || digits == "" || digits[0] == '0' {
+		return 0, false
+	}
+	code, err := strconv.ParseUint(digits, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	switch {
+	case code == 41, code >= 128 && code <= 255, code == 65535:
+		return 0, false
+	}
+	if _, native := nativeTypeCodes[uint16(code)]; native {
+		return 0, false
+	}
+	return uint16(code), true
+}
+
+// IsGeneric reports whether the record type is stored generically
+func (rt RecordType) IsGeneric() bool {
+	_, ok := rt.GenericCode()
+	return ok
+}
+
+// ParseGenericData decodes RDATA in the generic form, or bare hex digits.
+// Whitespace between hex digits is ignored
+func ParseGenericData(target string) ([]byte, error) {
+	digits := strings.TrimSpace(target)
+	length := -1
+	if rest, ok := strings.CutPrefix(digits, `\#`); ok {
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf(`missing RDATA length after \#`)
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n < 0 || n > maxGenericData {
+			return nil, fmt.Errorf("invalid RDATA length %q", fields[0])
+		}
+		length = n
+		digits = strings.Join(fields[1:], "")
+	} else {
+		digits = strings.Join(strings.Fields(digits), "")
+	}
+
+	data, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex RDATA: %w", err)
+	}
+	if length >= 0 && len(data) != length {
+		return nil, fmt.Errorf("RDATA is %d bytes, not the %d given", len(data), length)
+	}
+	if len(data) > maxGenericData {
+		return nil, fmt.Errorf("RDATA too long: %d bytes", len(data))
+	}
+	return data, nil
+}
+
+// FormatGenericData renders RDATA in the generic form
+func FormatGenericData(data []byte) string {
+	if len(data) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %s`, len(data), hex.EncodeToString(data))
+}
+
+// validateGenericRecord checks that the target is generic RDATA
+func (r *DNSRecord) validateGenericRecord() error {
+	_, err := ParseGenericData(r.Target)
+	return err
+}
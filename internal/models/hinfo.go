@@ -0,0 +1,142 @@
+// HINFO Record Validation
+//
+// HINFO records (RFC 1035 section 3.3.2) describe a host's CPU and
+// operating system as two character-strings, kept in the target in
+// presentation format: each string quoted, or bare if it has no spaces.
+// Quotes and backslashes inside a string are escaped with a backslash, and
+// any byte can be written as \DDD (decimal).
+//
+// Rules:
+// - Exactly two strings, CPU then OS
+// - Each string is at most 255 bytes; either may be empty
+//
+// Targets are stored canonically, both strings quoted.
+//
+// Examples:
+// `"INTEL-386" "Linux 6.1"` (valid)
+// `ARM64 Linux` (valid, stored as "ARM64" "Linux")
+// `"RFC8482" ""` (valid, the minimal answer to ANY queries)
+// `"x86_64"` (invalid, missing OS)
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCharacterString is the most bytes in a character-string
+const maxCharacterString = 255
+
+// HINFOData is the RDATA of an HINFO record
+type HINFOData struct {
+	CPU string
+	OS  string
+}
+
+// ParseHINFOData parses and checks HINFO RDATA in presentation format
+func ParseHINFOData(target string) (*HINFOData, error) {
+	strs, err := ParseCharacterStrings(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(strs) != 2 {
+		return nil, fmt.Errorf("expected CPU and OS strings, got %d strings", len(strs))
+	}
+	return &HINFOData{CPU: strs[0], OS: strs[1]}, nil
+}
+
+// String renders the RDATA in canonical presentation format
+func (d *HINFOData) String() string {
+	return `"` + EscapeCharacterString(d.CPU) + `" "` + EscapeCharacterString(d.OS) + `"`
+}
+
+// ParseCharacterStrings splits presentation format text into its
+// character-strings, resolving quotes and escapes
+func ParseCharacterStrings(text string) ([]string, error) {
+	var strs []string
+	for i := 0; i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+
+		quoted := text[i] == '"'
+		if quoted {
+			i++
+		}
+		var b strings.Builder
+		closed := false
+		for i < len(text) {
+			c := text[i]
+			if quoted && c == '"' {
+				i++
+				closed = true
+				break
+			}
+			if !quoted && (c == ' ' || c == '\t') {
+				break
+			}
+			if !quoted && c == '"' {
+				return nil, fmt.Errorf("unexpected quote inside unquoted string")
+			}
+			if c != '\\' {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+
+			// \DDD is a decimal byte, \X is X itself
+			if i+3 < len(text) && isDigit(text[i+1]) && isDigit(text[i+2]) && isDigit(text[i+3]) {
+				value := int(text[i+1]-'0')*100 + int(text[i+2]-'0')*10 + int(text[i+3]-'0')
+				if value > 255 {
+					return nil, fmt.Errorf("invalid escape \\%s", text[i+1:i+4])
+				}
+				b.WriteByte(byte(value))
+				i += 4
+				continue
+			}
+			if i+1 == len(text) {
+				return nil, fmt.Errorf("text ends with a backslash")
+			}
+			b.WriteByte(text[i+1])
+			i += 2
+		}
+		if quoted && !closed {
+			return nil, fmt.Errorf("unterminated quoted string")
+		}
+		if b.Len() > maxCharacterString {
+			return nil, fmt.Errorf("string longer than %d bytes", maxCharacterString)
+		}
+		strs = append(strs, b.String())
+	}
+	return strs, nil
+}
+
+// EscapeCharacterString escapes s for use inside a quoted
+// character-string: quotes and backslashes get a backslash, and bytes
+// outside printable ASCII become \DDD
+func EscapeCharacterString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// validateHINFORecord checks the target of an HINFO record
+func (r *DNSRecord) validateHINFORecord() error {
+	_, err := ParseHINFOData(r.Target)
+	return err
+}
@@ -0,0 +1,119 @@
+// Record Listing
+//
+// Management listings select records with a RecordFilter and page through
+// them with keyset pagination: a page ends with a cursor holding the sort
+// key of its last record, and the next page starts right after that key.
+// Unlike offsets, cursors don't skip or repeat records when others are
+// created or deleted between pages.
+//
+// Sort orders, reversed with a "-" prefix (e.g. "-updated"):
+// - name: name, then type and priority (the default)
+// - created: creation time
+// - updated: last update time
+// - id: record ID
+//
+// Every order ends with the record ID, so no two records tie.
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Record sort orders
+const (
+	RecordSortName    = "name"
+	RecordSortCreated = "created"
+	RecordSortUpdated = "updated"
+	RecordSortID      = "id"
+)
+
+// RecordCursor is the sort key of the last record of a page
+type RecordCursor struct {
+	Sort     string    `json:"s"`
+	Name     string    `json:"n,omitempty"`
+	Type     string    `json:"t,omitempty"`
+	Priority int       `json:"p,omitempty"`
+	Time     time.Time `json:"at,omitzero"`
+	ID       int       `json:"id"`
+}
+
+// ParseRecordSort splits a sort order into its field and direction. An
+// empty order sorts by name
+func ParseRecordSort(sort string) (field string, descending bool, err error) {
+	field, descending = strings.CutPrefix(strings.ToLower(strings.TrimSpace(sort)), "-")
+	switch field {
+	case "":
+		return RecordSortName, descending, nil
+	case RecordSortName, RecordSortCreated, RecordSortUpdated, RecordSortID:
+		return field, descending, nil
+	default:
+		return "", false, fmt.Errorf("invalid sort %q: must be name, created, updated, or id, optionally prefixed with -", sort)
+	}
+}
+
+// Validate checks the filter's sort order, limit, and cursor
+func (f *RecordFilter) Validate() error {
+	if _, _, err := ParseRecordSort(f.Sort); err != nil {
+		return err
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("invalid limit %d: cannot be negative", f.Limit)
+	}
+	_, err := f.DecodeCursor()
+	return err
+}
+
+// Cursor returns the cursor that continues a listing after record, which
+// should be the last record of a page
+func (f *RecordFilter) Cursor(record *DNSRecord) string {
+	cursor := RecordCursor{Sort: f.sortKey(), ID: record.ID}
+	field, _, _ := ParseRecordSort(f.Sort)
+	switch field {
+	case RecordSortName:
+		cursor.Name = strings.ToLower(record.Name)
+		cursor.Type = record.RecordType
+		cursor.Priority = record.Priority
+	case RecordSortCreated:
+		cursor.Time = record.CreatedAt
+	case RecordSortUpdated:
+		cursor.Time = record.UpdatedAt
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns the sort key the filter's After cursor holds, or
+// nil if it has none. A cursor only continues a listing in the order it
+// was made for
+func (f *RecordFilter) DecodeCursor() (*RecordCursor, error) {
+	if f.After == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(f.After)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var cursor RecordCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if cursor.Sort != f.sortKey() {
+		return nil, fmt.Errorf("cursor was made for sort %q, not %q", cursor.Sort, f.sortKey())
+	}
+	return &cursor, nil
+}
+
+// sortKey is the filter's sort order in canonical form
+func (f *RecordFilter) sortKey() string {
+	field, descending, _ := ParseRecordSort(f.Sort)
+	if descending {
+		return "-" + field
+	}
+	return field
+}
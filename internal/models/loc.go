@@ -0,0 +1,200 @@
+// LOC Record Validation
+//
+// LOC records (RFC 1876) publish a location, kept in the target in the
+// RFC's presentation format:
+//
+//	d1 [m1 [s1]] N|S d2 [m2 [s2]] E|W alt[m] [size[m] [hp[m] [vp[m]]]]
+//
+// Rules:
+// - Latitude is at most 90 degrees, longitude at most 180
+// - Minutes are 0-59 and seconds 0-59.999
+// - Altitude is -100000.00m to 42849672.95m, to the centimeter
+// - Size and precisions are 0-90000000m, a digit times a power of ten cm
+// - Size defaults to 1m, horizontal precision to 10000m, vertical to 10m
+//
+// Targets are stored canonically, with every field given.
+//
+// Examples:
+// "52 22 23.000 N 4 53 32.000 E -2.00m 0m 10000m 10m" (valid)
+// "42 21 43.528 N 71 5 6.284 W 12m" (valid, default sizes)
+// "91 0 0 N 0 0 0 E 0m" (invalid latitude)
+// "52 N 4 E 0m 15m" (invalid size, not a digit times a power of ten)
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	locEquator      = 1 << 31      // latitude and longitude of 0 degrees
+	locAltitudeBase = 100000 * 100 // altitude of 0m, in cm
+	locMaxAltitude  = 42849672.95
+	locMaxPrecision = 90000000.00
+)
+
+// LOCData is the RDATA of a LOC record in wire format
+type LOCData struct {
+	Size      uint8  // diameter of the location's sphere, encoded
+	HorizPre  uint8  // horizontal precision, encoded
+	VertPre   uint8  // vertical precision, encoded
+	Latitude  uint32 // thousandths of an arcsecond, from locEquator
+	Longitude uint32 // thousandths of an arcsecond, from locEquator
+	Altitude  uint32 // cm, from locAltitudeBase below sea level
+}
+
+// ParseLOCData parses and checks LOC RDATA in presentation format
+func ParseLOCData(target string) (*LOCData, error) {
+	fields := strings.Fields(target)
+
+	latitude, fields, err := parseLOCCoordinate(fields, "N", "S", 90)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude: %w", err)
+	}
+	longitude, fields, err := parseLOCCoordinate(fields, "E", "W", 180)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude: %w", err)
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("missing altitude")
+	}
+	if len(fields) > 4 {
+		return nil, fmt.Errorf("unexpected %q after vertical precision", strings.Join(fields[4:], " "))
+	}
+	altitude, err := parseLOCMeters(fields[0])
+	if err != nil || altitude < -100000 || altitude > locMaxAltitude {
+		return nil, fmt.Errorf("invalid altitude %q: must be -100000m to %.2fm", fields[0], locMaxAltitude)
+	}
+
+	data := &LOCData{
+		Latitude:  latitude,
+		Longitude: longitude,
+		Altitude:  uint32(math.Round(altitude*100) + locAltitudeBase),
+		Size:      0x12, // 1m
+		HorizPre:  0x16, // 10000m
+		VertPre:   0x13, // 10m
+	}
+	for i, precision := range []*uint8{&data.Size, &data.HorizPre, &data.VertPre} {
+		if i+1 >= len(fields) {
+			break
+		}
+		if *precision, err = parseLOCPrecision(fields[i+1]); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", []string{"size", "horizontal precision", "vertical precision"}[i], fields[i+1], err)
+		}
+	}
+	return data, nil
+}
+
+// parseLOCCoordinate parses degrees, optional minutes and seconds, and a
+// hemisphere from the front of fields, returning the rest
+func parseLOCCoordinate(fields []string, positive, negative string, maxDegrees int) (uint32, []string, error) {
+	var parts []string
+	for len(fields) > 0 && len(parts) < 4 {
+		field := strings.ToUpper(fields[0])
+		fields = fields[1:]
+		if field == positive || field == negative {
+			return locCoordinate(parts, field == negative, maxDegrees, fields)
+		}
+		parts = append(parts, field)
+	}
+	return 0, nil, fmt.Errorf("expected degrees, minutes, seconds, and %s or %s", positive, negative)
+}
+
+// locCoordinate converts degrees, minutes, and seconds to wire format
+func locCoordinate(parts []string, negative bool, maxDegrees int, rest []string) (uint32, []string, error) {
+	if len(parts) == 0 || len(parts) > 3 {
+		return 0, nil, fmt.Errorf("expected 1 to 3 numbers before the hemisphere, got %d", len(parts))
+	}
+
+	degrees, err := strconv.Atoi(parts[0])
+	if err != nil || degrees < 0 || degrees > maxDegrees {
+		return 0, nil, fmt.Errorf("degrees must be 0-%d, got %q", maxDegrees, parts[0])
+	}
+	var minutes int
+	if len(parts) > 1 {
+		if minutes, err = strconv.Atoi(parts[1]); err != nil || minutes < 0 || minutes > 59 {
+			return 0, nil, fmt.Errorf("minutes must be 0-59, got %q", parts[1])
+		}
+	}
+	var seconds float64
+	if len(parts) > 2 {
+		if seconds, err = strconv.ParseFloat(parts[2], 64); err != nil || seconds < 0 || seconds >= 60 {
+			return 0, nil, fmt.Errorf("seconds must be 0-59.999, got %q", parts[2])
+		}
+	}
+
+	offset := int64(degrees*3600+minutes*60)*1000 + int64(math.Round(seconds*1000))
+	if offset > int64(maxDegrees)*3600*1000 {
+		return 0, nil, fmt.Errorf("more than %d degrees", maxDegrees)
+	}
+	if negative {
+		offset = -offset
+	}
+	return uint32(locEquator + offset), rest, nil
+}
+
+// parseLOCMeters parses a distance in meters, with an optional "m"
+func parseLOCMeters(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value), "m"), 64)
+}
+
+// parseLOCPrecision encodes a size or precision as a digit and a power of
+// ten, in cm (RFC 1876 section 2)
+func parseLOCPrecision(value string) (uint8, error) {
+	meters, err := parseLOCMeters(value)
+	if err != nil || meters < 0 || meters > locMaxPrecision {
+		return 0, fmt.Errorf("must be 0m to %.0fm", locMaxPrecision)
+	}
+
+	cm := int64(math.Round(meters * 100))
+	exponent := 0
+	for cm >= 10 && cm%10 == 0 {
+		cm /= 10
+		exponent++
+	}
+	if cm > 9 {
+		return 0, fmt.Errorf("must be a digit times a power of ten, e.g. 1m, 20m, or 0.05m")
+	}
+	return uint8(cm<<4 | int64(exponent)), nil
+}
+
+// locPrecision renders an encoded size or precision in meters
+func locPrecision(encoded uint8) string {
+	cm := int64(encoded >> 4)
+	for i := uint8(0); i < encoded&0x0f; i++ {
+		cm *= 10
+	}
+	if cm%100 == 0 {
+		return fmt.Sprintf("%dm", cm/100)
+	}
+	return fmt.Sprintf("%.2fm", float64(cm)/100)
+}
+
+// locCoordinateString renders a coordinate as degrees, minutes, seconds,
+// and hemisphere
+func locCoordinateString(value uint32, positive, negative string) string {
+	offset := int64(value) - locEquator
+	hemisphere := positive
+	if offset < 0 {
+		offset, hemisphere = -offset, negative
+	}
+	return fmt.Sprintf("%d %d %.3f %s", offset/3600000, offset/60000%60, float64(offset%60000)/1000, hemisphere)
+}
+
+// String renders the RDATA in canonical presentation format
+func (d *LOCData) String() string {
+	return fmt.Sprintf("%s %s %.2fm %s %s %s",
+		locCoordinateString(d.Latitude, "N", "S"),
+		locCoordinateString(d.Longitude, "E", "W"),
+		float64(int64(d.Altitude)-locAltitudeBase)/100,
+		locPrecision(d.Size), locPrecision(d.HorizPre), locPrecision(d.VertPre))
+}
+
+// validateLOCRecord checks the target of a LOC record
+func (r *DNSRecord) validateLOCRecord() error {
+	_, err := ParseLOCData(r.Target)
+	return err
+}
@@ -0,0 +1,114 @@
+// Record Metadata
+//
+// Records carry operator metadata that is never served over DNS:
+// - Labels: key/value pairs used for filtering (e.g. team=payments)
+// - Comment: free text explaining why the record exists
+// - Owner: the person or team responsible for the record
+// - ExternalKey: an ID chosen by the tool that manages the record
+//
+// External keys (e.g. "terraform:aws_route53_record.www") are unique among
+// records. Upserting a record with an external key updates the record
+// holding it, whatever its name, type, or target.
+//
+// Label keys are 1-63 characters of lowercase letters, digits, '.', '_',
+// '-', and '/'. Values may be empty and are limited to 255 characters.
+//
+// Label selectors are comma-separated key=value pairs and match records
+// carrying all of the listed labels:
+// "team=payments" (records labeled team=payments)
+// "team=payments,env=prod" (records labeled with both)
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	maxLabelKeyLength    = 63
+	maxLabelValueLength  = 255
+	maxCommentLength     = 1024
+	maxOwnerLength       = 255
+	maxExternalKeyLength = 255
+)
+
+// RecordFilter selects records for management listing and export. Empty
+// fields don't filter. Sort, After, and Limit order and page the listing
+// (see listing.go)
+type RecordFilter struct {
+	Name       string
+	NamePrefix string // names starting with this, e.g. "api-"
+	Zone       string // the zone's apex and every name below it
+	RecordType string
+	Owner      string
+	Labels     map[string]string // records must carry every label
+
+	ExternalKey string // the record holding this external key
+
+	Sort  string // a record sort order; empty sorts by name
+	After string // cursor of the previous page's last record
+	Limit int    // most records returned; 0 returns every match
+}
+
+// ParseLabelSelector parses "key=value,key2=value2" into a label map
+func ParseLabelSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(selector, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("label selector %q must be key=value", pair)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	if err := validateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+func (r *DNSRecord) validateMetadata() error {
+	if err := validateLabels(r.Labels); err != nil {
+		return err
+	}
+
+	if len(r.Comment) > maxCommentLength {
+		return fmt.Errorf("comment too long: %d characters (max %d)", len(r.Comment), maxCommentLength)
+	}
+
+	if len(r.Owner) > maxOwnerLength {
+		return fmt.Errorf("owner too long: %d characters (max %d)", len(r.Owner), maxOwnerLength)
+	}
+
+	if len(r.ExternalKey) > maxExternalKeyLength {
+		return fmt.Errorf("external key too long: %d characters (max %d)", len(r.ExternalKey), maxExternalKeyLength)
+	}
+
+	return nil
+}
+
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if key == "" || len(key) > maxLabelKeyLength {
+			return fmt.Errorf("label key %q must be 1-%d characters", key, maxLabelKeyLength)
+		}
+
+		for _, c := range key {
+			valid := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+				c == '.' || c == '_' || c == '-' || c == '/'
+			if !valid {
+				return fmt.Errorf("label key %q contains invalid character %q", key, c)
+			}
+		}
+
+		if len(value) > maxLabelValueLength {
+			return fmt.Errorf("label %q value too long: %d characters (max %d)", key, len(value), maxLabelValueLength)
+		}
+	}
+	return nil
+}
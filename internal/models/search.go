@@ -0,0 +1,68 @@
+// Record Search
+//
+// Searches match a pattern against record names, targets, or both, ignoring
+// case. A plain pattern matches anywhere in the value: "10.1.2" finds
+// 10.1.2.3 and 110.1.2.4. A pattern containing "*" is a wildcard matched
+// against the whole value, "*" standing for any run of characters:
+// "10.1.2.*", "*.example.com", "mail*".
+//
+// Fields:
+// - name: the record's name
+// - target: the record's target (address, host name, or text)
+// - any: either of them (the default)
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Search fields
+const (
+	SearchFieldName   = "name"
+	SearchFieldTarget = "target"
+	SearchFieldAny    = "any"
+)
+
+const maxSearchQueryLength = 255
+
+// RecordSearch finds records by a pattern on their name or target
+type RecordSearch struct {
+	Query      string `json:"query"`
+	Field      string `json:"field,omitempty"`
+	RecordType string `json:"type,omitempty"`
+	Limit      int    `json:"limit,omitempty"` // 0 returns every match
+}
+
+// Validate checks the search's pattern, field, and limit
+func (s *RecordSearch) Validate() error {
+	if strings.TrimSpace(s.Query) == "" {
+		return fmt.Errorf("search query cannot be empty")
+	}
+	if len(s.Query) > maxSearchQueryLength {
+		return fmt.Errorf("search query too long: %d characters (max %d)", len(s.Query), maxSearchQueryLength)
+	}
+	switch s.SearchField() {
+	case SearchFieldName, SearchFieldTarget, SearchFieldAny:
+	default:
+		return fmt.Errorf("invalid search field %q: must be name, target, or any", s.Field)
+	}
+	if s.Limit < 0 {
+		return fmt.Errorf("invalid limit %d: cannot be negative", s.Limit)
+	}
+	return nil
+}
+
+// SearchField returns the field searched, SearchFieldAny if none is set
+func (s *RecordSearch) SearchField() string {
+	if s.Field == "" {
+		return SearchFieldAny
+	}
+	return strings.ToLower(s.Field)
+}
+
+// Wildcard reports whether the pattern must match whole values rather
+// than anywhere in them
+func (s *RecordSearch) Wildcard() bool {
+	return strings.Contains(s.Query, "*")
+}
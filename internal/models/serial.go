@@ -0,0 +1,121 @@
+// SOA Serial Strategies
+//
+// A zone's SOA serial must grow whenever the zone changes so secondaries
+// notice. How it grows is the zone's serial strategy:
+// - increment: add one (the default)
+// - date: YYYYMMDDnn, today's date and a two digit revision
+// - unixtime: the current time in seconds since the epoch
+//
+// When the strategy's serial isn't past the current one (a date zone
+// changed 100 times in a day, or two unixtime changes in a second), the
+// current serial is incremented instead.
+//
+// Serials compare with RFC 1982 serial arithmetic, so a serial ahead of
+// the strategy's (e.g. a date serial for tomorrow) keeps incrementing
+// rather than going backwards.
+//
+// A serial policy maps zones to strategies with rules of the form
+// "zone=strategy", e.g. "example.com=date". A rule covers the zone's
+// subzones; the rule for the most specific zone wins.
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SerialStrategy is a way of advancing a zone's SOA serial
+type SerialStrategy string
+
+const (
+	SerialIncrement SerialStrategy = "increment"
+	SerialDate      SerialStrategy = "date"
+	SerialUnixTime  SerialStrategy = "unixtime"
+)
+
+// ParseSerialStrategy parses a strategy name
+func ParseSerialStrategy(name string) (SerialStrategy, error) {
+	switch strategy := SerialStrategy(strings.ToLower(strings.TrimSpace(name))); strategy {
+	case SerialIncrement, SerialDate, SerialUnixTime:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown serial strategy %q: must be increment, date, or unixtime", name)
+}
+
+// Next returns the serial following current at now
+func (s SerialStrategy) Next(current uint32, now time.Time) uint32 {
+	var candidate uint32
+	switch s {
+	case SerialDate:
+		now = now.UTC()
+		candidate = uint32(now.Year()*1000000 + int(now.Month())*10000 + now.Day()*100) // YYYYMMDD00
+	case SerialUnixTime:
+		candidate = uint32(now.Unix())
+	default:
+		return current + 1
+	}
+
+	// Serial arithmetic wraps (RFC 1982 section 3.2)
+	if int32(candidate-current) > 0 {
+		return candidate
+	}
+	return current + 1
+}
+
+// SerialPolicy chooses the serial strategy of each zone. A nil policy
+// increments every zone's serial
+type SerialPolicy struct {
+	fallback SerialStrategy
+	zones    map[string]SerialStrategy
+}
+
+// NewSerialPolicy creates a policy using fallback for zones no rule covers
+// (increment if empty) and parses rules of the form "zone=strategy"
+func NewSerialPolicy(fallback string, rules []string) (*SerialPolicy, error) {
+	policy := &SerialPolicy{fallback: SerialIncrement, zones: make(map[string]SerialStrategy)}
+	if fallback != "" {
+		strategy, err := ParseSerialStrategy(fallback)
+		if err != nil {
+			return nil, err
+		}
+		policy.fallback = strategy
+	}
+
+	for _, rule := range rules {
+		zone, name, found := strings.Cut(rule, "=")
+		zone = NormalizeDomainName(zone)
+		if !found || zone == "" {
+			return nil, fmt.Errorf("invalid serial strategy rule %q: expected zone=strategy", rule)
+		}
+		strategy, err := ParseSerialStrategy(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serial strategy rule %q: %w", rule, err)
+		}
+		policy.zones[zone] = strategy
+	}
+	return policy, nil
+}
+
+// Strategy returns the serial strategy of zone
+func (p *SerialPolicy) Strategy(zone string) SerialStrategy {
+	if p == nil {
+		return SerialIncrement
+	}
+	for name := NormalizeDomainName(zone); name != ""; {
+		if strategy, ok := p.zones[name]; ok {
+			return strategy
+		}
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		name = parent
+	}
+	return p.fallback
+}
+
+// Next returns the serial following current for zone at now
+func (p *SerialPolicy) Next(zone string, current uint32, now time.Time) uint32 {
+	return p.Strategy(zone).Next(current, now)
+}
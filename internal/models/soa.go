@@ -105,6 +105,9 @@ func (r *DNSRecord) validateSOATarget() error {
 	// MINIMUM can be 0, so no zero-check needed
 
 	// Cross-field validation
+	if CurrentValidationProfile().SkipSOATiming {
+		return nil
+	}
 	if retry >= refresh {
 		return fmt.Errorf("SOA timing conflict: RETRY (%d) must be less than REFRESH (%d)", retry, refresh)
 	}
@@ -130,6 +133,44 @@ func (r *DNSRecord) validateSOARecord() error {
 		return fmt.Errorf("SOA records cannot contain wildcards")
 	}
 
+	// Records stored with the SOA fields in their own columns carry just
+	// the primary nameserver as the target
+	if len(strings.Fields(r.Target)) == 1 {
+		return r.validateSOAColumns()
+	}
+
 	// Validate SOA target format
 	return r.validateSOATarget()
 }
+
+// validateSOAColumns validates an SOA record whose fields other than MNAME
+// are stored in Mbox, Serial, Refresh, Retry, Expire, and Minttl
+func (r *DNSRecord) validateSOAColumns() error {
+	if err := r.validateDomainNameOther(NormalizeDomainName(r.Target)); err != nil {
+		return fmt.Errorf("SOA MNAME invalid: %s is not a valid FQDN", r.Target)
+	}
+	if r.Mbox == "" {
+		return fmt.Errorf("SOA RNAME (mbox) cannot be empty")
+	}
+	if err := r.validateDomainNameOther(NormalizeDomainName(r.Mbox)); err != nil {
+		return fmt.Errorf("SOA RNAME invalid: %s is not a valid FQDN", r.Mbox)
+	}
+	if r.Refresh == 0 || r.Retry == 0 || r.Expire == 0 {
+		return fmt.Errorf("SOA REFRESH, RETRY, and EXPIRE must be greater than 0")
+	}
+
+	if CurrentValidationProfile().SkipSOATiming {
+		return nil
+	}
+
+	if r.Retry >= r.Refresh {
+		return fmt.Errorf("SOA timing conflict: RETRY (%d) must be less than REFRESH (%d)", r.Retry, r.Refresh)
+	}
+	if r.Expire <= r.Refresh {
+		return fmt.Errorf("SOA timing conflict: EXPIRE (%d) must be greater than REFRESH (%d)", r.Expire, r.Refresh)
+	}
+	if r.Minttl > r.Refresh {
+		return fmt.Errorf("SOA timing conflict: MINIMUM (%d) should not exceed REFRESH (%d)", r.Minttl, r.Refresh)
+	}
+	return nil
+}
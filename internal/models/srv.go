@@ -34,6 +34,9 @@ func (r *DNSRecord) validateSRVTarget() error {
 	}
 
 	fields := strings.Fields(r.Target)
+	if len(fields) == 1 {
+		return r.validateSRVColumns()
+	}
 	if len(fields) != 4 {
 		return fmt.Errorf("SRV record target must have 4 fields (priority weight port target), got %d", len(fields))
 	}
@@ -85,6 +88,31 @@ func (r *DNSRecord) validateSRVTarget() error {
 	return nil
 }
 
+// validateSRVColumns validates an SRV record whose priority, weight, and
+// port are stored in their own columns, leaving just the host as the target
+func (r *DNSRecord) validateSRVColumns() error {
+	if r.Priority < 0 || r.Priority > 65535 {
+		return fmt.Errorf("SRV priority invalid: %d is not a valid 16-bit unsigned integer", r.Priority)
+	}
+	if r.Weight > 65535 {
+		return fmt.Errorf("SRV weight invalid: %d is not a valid 16-bit unsigned integer", r.Weight)
+	}
+	if r.Port == 0 {
+		return fmt.Errorf("SRV port cannot be 0")
+	}
+
+	if r.Target == "." {
+		return nil
+	}
+	if net.ParseIP(r.Target) != nil {
+		return fmt.Errorf("SRV target cannot be an IP address: %s", r.Target)
+	}
+	if err := r.validateDomainNameOther(NormalizeDomainName(r.Target)); err != nil {
+		return fmt.Errorf("SRV target host is not a valid domain name: %s", r.Target)
+	}
+	return nil
+}
+
 func (r *DNSRecord) validateSRVName() error {
 	// SRV records must have name in format "_service._protocol.domain"
 	if r.Name == "" {
@@ -134,7 +162,7 @@ func (r *DNSRecord) validateSRVName() error {
 	domainLabels := labels[2:]
 	domainName := strings.Join(domainLabels, ".")
 
-	if err := r.validateDomainName(); err != nil {
+	if err := r.validateDomainNameOther(domainName); err != nil {
 		return fmt.Errorf("SRV domain portion invalid: %s", domainName)
 	}
 
@@ -0,0 +1,170 @@
+// Custom Public Suffixes
+//
+// Apex domains come from the compiled-in Public Suffix List, which knows
+// nothing of internal suffixes: "team.dev.corp" gets the apex "dev.corp"
+// when each team under dev.corp should be its own apex. Custom suffix
+// rules, in the Public Suffix List's format, are merged with it:
+// - "dev.corp": names below dev.corp are registered under it
+// - "*.corp": every name one label below corp is a suffix
+// - "!www.corp": an exception to a wildcard, registered under corp
+// - "//" starts a comment; blank lines are ignored
+//
+// The longer of the custom and compiled-in suffixes of a name wins, and a
+// custom exception overrides both.
+//
+// Examples:
+// "dev.corp": "team.dev.corp" has the apex "team.dev.corp"
+// "*.internal": "host.site.internal" has the apex "host.site.internal"
+// "*.corp" and "!www.corp": "www.corp" has the apex "www.corp"
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SuffixList is a set of custom public suffix rules
+type SuffixList struct {
+	suffixes   map[string]bool // "dev.corp"
+	wildcards  map[string]bool // "*.corp", keyed by "corp"
+	exceptions map[string]bool // "!www.corp", keyed by "www.corp"
+}
+
+// NewSuffixList parses suffix rules, such as the lines of a suffix file
+func NewSuffixList(rules []string) (*SuffixList, error) {
+	list := &SuffixList{
+		suffixes:   make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+	for _, rule := range rules {
+		if err := list.add(rule); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// add parses one rule; blank rules and comments are ignored
+func (l *SuffixList) add(rule string) error {
+	// Only the first field before any comment counts, as in the Public
+	// Suffix List
+	rule, _, _ = strings.Cut(rule, "//")
+	fields := strings.Fields(rule)
+	if len(fields) == 0 {
+		return nil
+	}
+	rule = NormalizeDomainName(fields[0])
+
+	set := l.suffixes
+	name := rule
+	switch {
+	case strings.HasPrefix(rule, "!"):
+		set, name = l.exceptions, rule[1:]
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("invalid suffix rule %q: an exception needs a parent suffix", rule)
+		}
+	case strings.HasPrefix(rule, "*."):
+		set, name = l.wildcards, rule[2:]
+	}
+
+	if name == "" || strings.ContainsAny(name, "*!") {
+		return fmt.Errorf("invalid suffix rule %q", rule)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid suffix rule %q: empty or overlong label", rule)
+		}
+	}
+	set[name] = true
+	return nil
+}
+
+// Len returns how many rules the list holds
+func (l *SuffixList) Len() int {
+	return len(l.suffixes) + len(l.wildcards) + len(l.exceptions)
+}
+
+// Equal reports whether l and other hold the same rules
+func (l *SuffixList) Equal(other *SuffixList) bool {
+	return equalSets(l.suffixes, other.suffixes) && equalSets(l.wildcards, other.wildcards) && equalSets(l.exceptions, other.exceptions)
+}
+
+func equalSets(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if !b[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// suffix returns the custom public suffix of domain, if a rule covers it,
+// and whether it came from an exception
+func (l *SuffixList) suffix(domain string) (suffix string, exception, ok bool) {
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		if l.exceptions[strings.Join(labels[i:], ".")] {
+			return strings.Join(labels[i+1:], "."), true, true
+		}
+	}
+
+	// The first match is the longest
+	for i := range labels {
+		name := strings.Join(labels[i:], ".")
+		if l.suffixes[name] || (i+1 < len(labels) && l.wildcards[strings.Join(labels[i+1:], ".")]) {
+			return name, false, true
+		}
+	}
+	return "", false, false
+}
+
+// customSuffixes are the custom rules merged with the compiled-in list
+var customSuffixes atomic.Pointer[SuffixList]
+
+// SetCustomSuffixes replaces the custom suffix rules; nil removes them.
+// Stored records keep the apex domains derived when they were written
+// until storage derives them again
+func SetCustomSuffixes(list *SuffixList) {
+	customSuffixes.Store(list)
+}
+
+// PublicSuffix returns the public suffix of domain, and whether it's an
+// ICANN suffix rather than a private or custom one
+func PublicSuffix(domain string) (string, bool) {
+	suffix, icann := publicsuffix.PublicSuffix(domain)
+	list := customSuffixes.Load()
+	if list == nil {
+		return suffix, icann
+	}
+
+	custom, exception, ok := list.suffix(domain)
+	if ok && (exception || strings.Count(custom, ".") > strings.Count(suffix, ".")) {
+		return custom, false
+	}
+	return suffix, icann
+}
+
+// EffectiveTLDPlusOne returns the public suffix of domain plus one label:
+// the registrable domain. It fails for a bare public suffix
+func EffectiveTLDPlusOne(domain string) (string, error) {
+	if customSuffixes.Load() == nil {
+		return publicsuffix.EffectiveTLDPlusOne(domain)
+	}
+
+	suffix, _ := PublicSuffix(domain)
+	if suffix == "" || len(domain) <= len(suffix) {
+		return "", fmt.Errorf("cannot derive eTLD+1 for domain %q", domain)
+	}
+	rest := strings.TrimSuffix(domain, "."+suffix)
+	if rest == domain {
+		return "", fmt.Errorf("cannot derive eTLD+1 for domain %q", domain)
+	}
+	return rest[strings.LastIndex(rest, ".")+1:] + "." + suffix, nil
+}
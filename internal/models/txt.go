@@ -1,23 +1,24 @@
 // TXT Record Validation
 //
 // Validates DNS TXT records according to RFC 1035/1123 standards:
-// - Max 255 octets per individual string, 65535 total length
-// - Supports quoted ("string") and unquoted (string) formats
-// - Handles backslash escaping (\") within quoted strings
-// - Space/tab separates multiple strings outside quotes
+// - Max 65535 total length
+// - Data over 255 octets is served split into strings of at most 255
+//   octets each (RFC 1035 section 3.3.14), so long DKIM keys need no
+//   manual splitting; the strings are joined again on import
+// - Handles backslash escaping (\" and \DDD)
+// - Quotes must be balanced
 // - Requires valid UTF-8 encoding
 // - Empty records allowed
 //
 // Examples:
 //   "v=spf1 include:_spf.google.com ~all"     (single quoted string)
-//   key=value "quoted string" other=data      (mixed quoted/unquoted)
+//   v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0B... (valid, over 255 octets)
 //   ""                                        (empty, valid)
 
 package models
 
 import (
 	"fmt"
-	"strings"
 	"unicode/utf8"
 )
 
@@ -27,92 +28,29 @@ func (r *DNSRecord) validateTXTRecord() error {
 		return nil
 	}
 
-	// Check total length - RFC 1035 allows up to 255 octets per string
-	// But implementations often allow longer total records
+	// Strings over 255 octets are split when served, so only the whole
+	// record is limited
 	if len(r.Target) > 65535 {
 		return fmt.Errorf("TXT record too long: %d characters (max 65535)", len(r.Target))
 	}
 
-	// Parse quoted strings - TXT records are stored as quoted strings
-	// Examples: "v=spf1 include:_spf.google.com ~all"
-	//          "key=value" "another=string"
-	//          "This is a single string"
-
-	var myStrings []string
-	var current strings.Builder
+	// Quotes must be balanced; a backslash escapes the next character
 	var inQuotes bool
 	var escaped bool
-
 	for _, r := range r.Target {
-		if escaped {
-			// Previous character was backslash, include this character literally
-			current.WriteRune(r)
+		switch {
+		case escaped:
 			escaped = false
-			continue
-		}
-
-		if r == '\\' {
-			// Next character should be escaped
+		case r == '\\':
 			escaped = true
-			current.WriteRune(r)
-			continue
-		}
-
-		if r == '"' {
-			if inQuotes {
-				// End of quoted string
-				str := current.String()
-				if len(str) > 255 {
-					return fmt.Errorf("TXT string too long: %d characters (max 255 per string)", len(str))
-				}
-				myStrings = append(myStrings, str)
-				current.Reset()
-				inQuotes = false
-			} else {
-				// Start of quoted string
-				inQuotes = true
-			}
-			continue
-		}
-
-		if !inQuotes && (r == ' ' || r == '\t') {
-			// Whitespace outside quotes - separator between strings
-			if current.Len() > 0 {
-				// We have an unquoted string
-				str := current.String()
-				if len(str) > 255 {
-					return fmt.Errorf("TXT string too long: %d characters (max 255 per string)", len(str))
-				}
-				myStrings = append(myStrings, str)
-				current.Reset()
-			}
-			continue
+		case r == '"':
+			inQuotes = !inQuotes
 		}
-
-		// Regular character
-		current.WriteRune(r)
 	}
-
-	// Handle final string
 	if inQuotes {
 		return fmt.Errorf("TXT record has unclosed quoted string")
 	}
 
-	if current.Len() > 0 {
-		str := current.String()
-		if len(str) > 255 {
-			return fmt.Errorf("TXT string too long: %d characters (max 255 per string)", len(str))
-		}
-		myStrings = append(myStrings, str)
-	}
-
-	// If no strings were parsed, treat entire target as single unquoted string
-	if len(myStrings) == 0 && r.Target != "" {
-		if len(r.Target) > 255 {
-			return fmt.Errorf("TXT string too long: %d characters (max 255 per string)", len(r.Target))
-		}
-	}
-
 	// Validate character encoding - should be UTF-8
 	if !utf8.ValidString(r.Target) {
 		return fmt.Errorf("TXT record contains invalid UTF-8 characters")
@@ -0,0 +1,111 @@
+// Validation Profiles
+//
+// Record validation follows public internet rules by default. Internal
+// and lab deployments whose naming or addressing breaks those rules can
+// relax them with a validation profile:
+// - strict: every rule applies (the default)
+// - permissive: every relaxation below
+// - a comma-separated list of relaxations
+//
+// Relaxations:
+// - class-e: A records may use Class E addresses (240.0.0.0/4)
+// - soa-timing: SOA timers aren't cross-checked against each other
+// - numeric-tld: TLDs may be all numeric or start with a digit
+//
+// One profile applies to every record validated in the process.
+//
+// Examples:
+// "strict" (default)
+// "class-e,numeric-tld" (lab addressing, internal zones like host.10)
+// "lenient" (invalid, unknown profile)
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ValidationProfile selects which validation rules are relaxed. The zero
+// value is strict
+type ValidationProfile struct {
+	AllowClassE     bool // A records may use 240.0.0.0/4
+	SkipSOATiming   bool // SOA timers aren't cross-checked
+	AllowNumericTLD bool // TLDs may be all numeric or start with a digit
+}
+
+// Validation profile and relaxation names
+const (
+	ValidationStrict     = "strict"
+	ValidationPermissive = "permissive"
+
+	RelaxClassE     = "class-e"
+	RelaxSOATiming  = "soa-timing"
+	RelaxNumericTLD = "numeric-tld"
+)
+
+// ParseValidationProfile parses a profile name or a comma-separated list of
+// relaxations. An empty spec is strict
+func ParseValidationProfile(spec string) (*ValidationProfile, error) {
+	profile := &ValidationProfile{}
+	switch spec = strings.ToLower(strings.TrimSpace(spec)); spec {
+	case "", ValidationStrict:
+		return profile, nil
+	case ValidationPermissive:
+		return &ValidationProfile{AllowClassE: true, SkipSOATiming: true, AllowNumericTLD: true}, nil
+	}
+
+	for _, relaxation := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(relaxation) {
+		case RelaxClassE:
+			profile.AllowClassE = true
+		case RelaxSOATiming:
+			profile.SkipSOATiming = true
+		case RelaxNumericTLD:
+			profile.AllowNumericTLD = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown validation profile or relaxation %q: must be strict, permissive, or a list of class-e, soa-timing, and numeric-tld", relaxation)
+		}
+	}
+	return profile, nil
+}
+
+// String returns the profile's name, or its relaxations
+func (p *ValidationProfile) String() string {
+	var relaxed []string
+	if p.AllowClassE {
+		relaxed = append(relaxed, RelaxClassE)
+	}
+	if p.SkipSOATiming {
+		relaxed = append(relaxed, RelaxSOATiming)
+	}
+	if p.AllowNumericTLD {
+		relaxed = append(relaxed, RelaxNumericTLD)
+	}
+
+	switch len(relaxed) {
+	case 0:
+		return ValidationStrict
+	case 3:
+		return ValidationPermissive
+	}
+	return strings.Join(relaxed, ",")
+}
+
+// validationProfile is the profile records are validated with
+var validationProfile atomic.Pointer[ValidationProfile]
+
+// SetValidationProfile sets the profile every record is validated with. A
+// nil profile is strict
+func SetValidationProfile(profile *ValidationProfile) {
+	validationProfile.Store(profile)
+}
+
+// CurrentValidationProfile returns the profile records are validated with
+func CurrentValidationProfile() *ValidationProfile {
+	if profile := validationProfile.Load(); profile != nil {
+		return profile
+	}
+	return &ValidationProfile{}
+}
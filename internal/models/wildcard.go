@@ -0,0 +1,83 @@
+// Positional Wildcard Matching
+//
+// Implements the matching and precedence rules of the positional wildcard
+// framework (docs/wildcard-framework.md) for records stored with their
+// domain components:
+// - A pattern matches a name under the same apex domain with the same
+//   number of subdomain labels, where every non-wildcard label is equal
+// - Among matching patterns, more exact labels win, then exact labels
+//   further left win
+//
+// Examples, for the query "api.service.example.com" (labels [api service]):
+//   "*.service.example.com" → mask 1, matches with 1 exact label
+//   "api.*.example.com"     → mask 2, matches with 1 exact label, and wins
+//                             over "*.service" since its exact label is leftmost
+//   "*.*.example.com"       → mask 3, matches with 0 exact labels
+
+package models
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// SetDomainComponents fills in ETLD, ApexDomain, SubdomainLabels, IsWildcard,
+// and WildcardMask from the record's name. Unlike full name validation it
+// accepts any label characters, so names such as "_dmarc.example.com" work
+func (r *DNSRecord) SetDomainComponents() error {
+	domain := NormalizeDomainName(r.Name)
+	if err := r.extractAndSetETLDInfo(domain); err != nil {
+		return err
+	}
+	return r.detectAndSetWildcards()
+}
+
+// DomainComponents splits a name into its apex domain and the subdomain
+// labels before it, as stored for records. ok is false for names without an
+// apex domain, such as bare public suffixes
+func DomainComponents(name string) (apex string, labels []string, ok bool) {
+	domain := NormalizeDomainName(name)
+	apex, err := EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", nil, false
+	}
+	if domain == apex {
+		return apex, nil, true
+	}
+	return apex, strings.Split(strings.TrimSuffix(domain, "."+apex), "."), true
+}
+
+// MatchesLabels reports whether a wildcard record's pattern matches a name
+// with the given subdomain labels under the record's apex domain
+func (r *DNSRecord) MatchesLabels(labels []string) bool {
+	if len(labels) != len(r.SubdomainLabels) || len(labels) > 64 {
+		return false
+	}
+	for i, label := range labels {
+		if r.WildcardMask&(1<<uint(i)) != 0 {
+			continue
+		}
+		if !strings.EqualFold(label, r.SubdomainLabels[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MoreSpecificThan reports whether r's wildcard pattern takes precedence over
+// other's, for patterns with the same number of labels
+func (r *DNSRecord) MoreSpecificThan(other *DNSRecord) bool {
+	// Fewer wildcard labels means more exact ones
+	wild, otherWild := bits.OnesCount64(r.WildcardMask), bits.OnesCount64(other.WildcardMask)
+	if wild != otherWild {
+		return wild < otherWild
+	}
+
+	// The leftmost differing position decides: an exact label there wins
+	diff := r.WildcardMask ^ other.WildcardMask
+	if diff == 0 {
+		return false
+	}
+	lowest := diff & -diff
+	return r.WildcardMask&lowest == 0
+}
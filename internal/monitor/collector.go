@@ -0,0 +1,336 @@
+// internal/monitor/collector.go
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"errantdns.io/internal/cache"
+	"errantdns.io/internal/chaos"
+	"errantdns.io/internal/dns"
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/mirror"
+	"errantdns.io/internal/pgsqlpool"
+	"errantdns.io/internal/plugin"
+	"errantdns.io/internal/redis"
+	"errantdns.io/internal/stats"
+	"errantdns.io/internal/storage"
+	"errantdns.io/internal/version"
+)
+
+// Snapshot is a point-in-time view of every component's statistics
+type Snapshot struct {
+	Timestamp     time.Time                  `json:"timestamp"`
+	StartedAt     time.Time                  `json:"started_at"`
+	Uptime        string                     `json:"uptime"`
+	UptimeSeconds float64                    `json:"uptime_seconds"`
+	Build         version.Info               `json:"build"`
+	DNS           dns.Stats                  `json:"dns"`
+	Latency       stats.Percentiles          `json:"latency"`
+	TierLatency   map[string]stats.Histogram `json:"tier_latency,omitempty"` // by the tier that answered
+	Interval      *IntervalStats             `json:"interval,omitempty"`
+	Cache         CacheSnapshot              `json:"cache"`
+	Forwarder     []forwarder.UpstreamStats  `json:"forwarder,omitempty"`
+	Plugins       []plugin.Stats             `json:"plugins,omitempty"`
+	Mirror        *mirror.Stats              `json:"mirror,omitempty"`
+	Chaos         []chaos.FaultStats         `json:"chaos,omitempty"`
+	Logging       map[string]interface{}     `json:"logging"`
+	Pools         PoolSnapshot               `json:"pools"`
+	PostgreSQL    *storage.PostgresStats     `json:"postgresql,omitempty"`
+	Breaker       *storage.BreakerStats      `json:"circuit_breaker,omitempty"`
+	CacheOnly     *storage.CacheOnlyStatus   `json:"cache_only,omitempty"`
+	ShadowReads   *storage.ShadowStats       `json:"shadow_reads,omitempty"`
+	Health        *Health                    `json:"health"`
+}
+
+// Health summarizes whether queries are answered normally. Status is "ok",
+// or "degraded" while answers come from caches or stale data alone; Reasons
+// says why. ReadOnly doesn't affect answers, so it doesn't degrade status
+type Health struct {
+	Status   string   `json:"status"`
+	Reasons  []string `json:"reasons,omitempty"`
+	ReadOnly bool     `json:"read_only"`
+}
+
+// Health statuses
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+)
+
+// CacheSnapshot holds statistics for each enabled cache tier
+type CacheSnapshot struct {
+	Mode string              `json:"mode"` // "disabled", "memory", or "memory+redis"
+	L0   *cache.Stats        `json:"l0_wire,omitempty"`
+	L1   *cache.Stats        `json:"l1_memory,omitempty"`
+	L2   *storage.RedisStats `json:"l2_redis,omitempty"`
+}
+
+// PoolSnapshot holds connection pool statistics by backend
+type PoolSnapshot struct {
+	PostgreSQL map[string]pgsqlpool.ConnectionStats `json:"postgresql"`
+	Redis      map[string]redis.PoolStats           `json:"redis,omitempty"`
+}
+
+// IntervalStats holds counter deltas over the most recent sampling interval
+type IntervalStats struct {
+	Start        time.Time `json:"start"`
+	Seconds      float64   `json:"seconds"`
+	Queries      int64     `json:"queries"`
+	Answered     int64     `json:"answered"`
+	NXDomain     int64     `json:"nxdomain"`
+	Errors       int64     `json:"errors"`
+	QPS          float64   `json:"qps"`
+	CacheHits    int64     `json:"cache_hits"`
+	CacheMisses  int64     `json:"cache_misses"`
+	CacheHitRate float64   `json:"cache_hit_rate"`
+}
+
+// counterSample is the set of cumulative counters used to compute deltas
+type counterSample struct {
+	at          time.Time
+	dns         dns.Stats
+	cacheHits   int64
+	cacheMisses int64
+}
+
+// Collector gathers statistics from the running components
+type Collector struct {
+	startedAt time.Time
+	dnsServer *dns.Server
+	storage   storage.Storage
+	pgStorage *storage.PostgresStorage
+	breaker   *storage.BreakerStorage
+	cacheOnly *storage.CacheOnlyStorage
+	shadow    *storage.ShadowStorage
+	pool      *pgsqlpool.Pool
+
+	// Interval sampling
+	mu           sync.Mutex
+	lastSample   *counterSample
+	lastInterval *IntervalStats
+}
+
+// NewCollector creates a collector for the given components; breaker,
+// cacheOnly, and shadow may be nil
+func NewCollector(dnsServer *dns.Server, storage storage.Storage, pgStorage *storage.PostgresStorage, breaker *storage.BreakerStorage, cacheOnly *storage.CacheOnlyStorage, shadow *storage.ShadowStorage, pool *pgsqlpool.Pool) *Collector {
+	return &Collector{
+		startedAt: time.Now(),
+		dnsServer: dnsServer,
+		storage:   storage,
+		pgStorage: pgStorage,
+		breaker:   breaker,
+		cacheOnly: cacheOnly,
+		shadow:    shadow,
+		pool:      pool,
+	}
+}
+
+// StartedAt returns the time the collector (and so the server) was started
+func (c *Collector) StartedAt() time.Time {
+	return c.startedAt
+}
+
+// Collect builds a snapshot of current statistics
+func (c *Collector) Collect() *Snapshot {
+	now := time.Now()
+	uptime := now.Sub(c.startedAt)
+
+	snapshot := &Snapshot{
+		Timestamp:     now,
+		StartedAt:     c.startedAt,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: uptime.Seconds(),
+		Build:         version.Get(),
+		Cache:         c.collectCache(),
+		Logging:       logging.GetLogger().GetStats(),
+	}
+
+	if c.dnsServer != nil {
+		snapshot.DNS = c.dnsServer.GetStats()
+		snapshot.Latency = c.dnsServer.GetLatency()
+		snapshot.TierLatency = c.dnsServer.GetTierLatency()
+		snapshot.Cache.L0 = c.dnsServer.GetWireCacheStats()
+		snapshot.Forwarder = c.dnsServer.GetForwarderStats()
+		snapshot.Plugins = c.dnsServer.GetPluginStats()
+		snapshot.Mirror = c.dnsServer.GetMirrorStats()
+		snapshot.Chaos = c.dnsServer.GetChaosStats()
+	}
+
+	c.mu.Lock()
+	if c.lastInterval != nil {
+		interval := *c.lastInterval
+		snapshot.Interval = &interval
+	}
+	c.mu.Unlock()
+
+	if c.pool != nil {
+		snapshot.Pools.PostgreSQL = c.pool.Stats()
+	}
+
+	if redisStats := redis.Stats(); len(redisStats) > 0 {
+		snapshot.Pools.Redis = redisStats
+	}
+
+	if c.pgStorage != nil {
+		pgStats := c.pgStorage.Stats()
+		snapshot.PostgreSQL = &pgStats
+	}
+
+	if c.breaker != nil {
+		breakerStats := c.breaker.Stats()
+		snapshot.Breaker = &breakerStats
+	}
+
+	if c.cacheOnly != nil {
+		status := c.cacheOnly.Status()
+		snapshot.CacheOnly = &status
+	}
+
+	if c.shadow != nil {
+		shadowStats := c.shadow.Stats()
+		snapshot.ShadowReads = &shadowStats
+	}
+
+	snapshot.Health = c.Health()
+	return snapshot
+}
+
+// Health reports whether queries are answered normally
+func (c *Collector) Health() *Health {
+	health := &Health{Status: HealthOK}
+	if c.cacheOnly != nil {
+		if status := c.cacheOnly.Status(); status.Active {
+			reason := "cache-only mode (" + status.Trigger + ")"
+			if status.Reason != "" {
+				reason += ": " + status.Reason
+			}
+			health.Reasons = append(health.Reasons, reason)
+		}
+	}
+	if c.breaker != nil && c.breaker.Stats().State == "open" {
+		health.Reasons = append(health.Reasons, "circuit breaker open; serving stale answers")
+	}
+	if len(health.Reasons) > 0 {
+		health.Status = HealthDegraded
+	}
+	if c.pgStorage != nil {
+		health.ReadOnly = c.pgStorage.ReadOnly()
+	}
+	return health
+}
+
+// TopQueries returns the top n queried names, NXDOMAIN names, and clients,
+// or nil when analytics are disabled
+func (c *Collector) TopQueries(n int) *stats.TopQueries {
+	if c.dnsServer == nil {
+		return nil
+	}
+	return c.dnsServer.GetTopQueries(n)
+}
+
+// collectCache extracts per-tier statistics from whichever cache wrapper is in use
+func (c *Collector) collectCache() CacheSnapshot {
+	switch s := c.storage.(type) {
+	case interface{ GetCacheStats() storage.CacheStats }:
+		stats := s.GetCacheStats()
+		return CacheSnapshot{
+			Mode: "memory+redis",
+			L1:   &stats.L1Stats,
+			L2:   &stats.L2Stats,
+		}
+	case interface{ GetCacheStats() cache.Stats }:
+		stats := s.GetCacheStats()
+		return CacheSnapshot{
+			Mode: "memory",
+			L1:   &stats,
+		}
+	default:
+		return CacheSnapshot{Mode: "disabled"}
+	}
+}
+
+// Run samples counters every interval so snapshots can report deltas and rates
+func (c *Collector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	c.mu.Lock()
+	c.lastSample = c.sample()
+	c.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			current := c.sample()
+			if c.lastSample != nil {
+				c.lastInterval = delta(c.lastSample, current)
+			}
+			c.lastSample = current
+			c.mu.Unlock()
+		}
+	}
+}
+
+// Reset zeroes server and cache counters and restarts interval tracking
+func (c *Collector) Reset() {
+	if c.dnsServer != nil {
+		c.dnsServer.ResetStats()
+	}
+
+	if resetter, ok := c.storage.(interface{ ResetCacheStats() }); ok {
+		resetter.ResetCacheStats()
+	}
+
+	c.mu.Lock()
+	c.lastSample = c.sample()
+	c.lastInterval = nil
+	c.mu.Unlock()
+}
+
+// sample captures the current cumulative counters
+func (c *Collector) sample() *counterSample {
+	sample := &counterSample{at: time.Now()}
+
+	if c.dnsServer != nil {
+		sample.dns = c.dnsServer.GetStats()
+	}
+
+	if l1 := c.collectCache().L1; l1 != nil {
+		sample.cacheHits = l1.Hits
+		sample.cacheMisses = l1.Misses
+	}
+
+	return sample
+}
+
+// delta computes interval statistics between two samples
+func delta(previous, current *counterSample) *IntervalStats {
+	seconds := current.at.Sub(previous.at).Seconds()
+
+	interval := &IntervalStats{
+		Start:       previous.at,
+		Seconds:     seconds,
+		Queries:     current.dns.QueriesReceived - previous.dns.QueriesReceived,
+		Answered:    current.dns.QueriesAnswered - previous.dns.QueriesAnswered,
+		NXDomain:    current.dns.QueriesNXDomain - previous.dns.QueriesNXDomain,
+		Errors:      current.dns.QueriesError - previous.dns.QueriesError,
+		CacheHits:   current.cacheHits - previous.cacheHits,
+		CacheMisses: current.cacheMisses - previous.cacheMisses,
+	}
+
+	if seconds > 0 {
+		interval.QPS = float64(interval.Queries) / seconds
+	}
+
+	if lookups := interval.CacheHits + interval.CacheMisses; lookups > 0 {
+		interval.CacheHitRate = float64(interval.CacheHits) / float64(lookups) * 100.0
+	}
+
+	return interval
+}
@@ -0,0 +1,95 @@
+// internal/monitor/graphite.go
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// graphiteTimeout bounds connecting to and writing to Carbon
+const graphiteTimeout = 5 * time.Second
+
+// GraphiteConfig holds configuration for a Graphite exporter
+type GraphiteConfig struct {
+	Address  string        // Carbon plaintext listener host:port
+	Prefix   string        // prepended to every metric path
+	Interval time.Duration // how often snapshots are sent
+}
+
+// GraphiteExporter pushes the collector's metrics to Carbon using the
+// plaintext protocol over TCP. Counters are sent as their cumulative value,
+// so rates come from Graphite's derivative functions. The connection is
+// reopened on the next push after a failure
+type GraphiteExporter struct {
+	collector *Collector
+	config    *GraphiteConfig
+	conn      net.Conn
+}
+
+// NewGraphiteExporter creates an exporter sending to config.Address
+func NewGraphiteExporter(collector *Collector, config *GraphiteConfig) *GraphiteExporter {
+	return &GraphiteExporter{collector: collector, config: config}
+}
+
+// Run pushes metrics every interval until ctx is cancelled
+func (e *GraphiteExporter) Run(ctx context.Context) {
+	defer e.disconnect()
+
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.push(ctx); err != nil {
+				logging.Warn("monitor", "Failed to send Graphite metrics", "address", e.config.Address, "error", err)
+			}
+		}
+	}
+}
+
+// push sends one snapshot, all stamped with the time it was taken
+func (e *GraphiteExporter) push(ctx context.Context) error {
+	if e.conn == nil {
+		dialer := net.Dialer{Timeout: graphiteTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", e.config.Address)
+		if err != nil {
+			return fmt.Errorf("failed to connect to Carbon: %w", err)
+		}
+		e.conn = conn
+	}
+
+	snapshot := e.collector.Collect()
+	timestamp := strconv.FormatInt(snapshot.Timestamp.Unix(), 10)
+
+	if err := e.conn.SetWriteDeadline(time.Now().Add(graphiteTimeout)); err != nil {
+		e.disconnect()
+		return err
+	}
+
+	w := bufio.NewWriter(e.conn)
+	for _, metric := range snapshot.Metrics() {
+		w.WriteString(e.config.Prefix + metric.Name + " " + strconv.FormatFloat(metric.Value, 'f', -1, 64) + " " + timestamp + "\n")
+	}
+	if err := w.Flush(); err != nil {
+		e.disconnect()
+		return err
+	}
+	return nil
+}
+
+// disconnect closes the connection, if any
+func (e *GraphiteExporter) disconnect() {
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
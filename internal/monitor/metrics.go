@@ -0,0 +1,228 @@
+// internal/monitor/metrics.go
+package monitor
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"errantdns.io/internal/cache"
+	"errantdns.io/internal/stats"
+)
+
+// MetricKind says how a metric's value behaves between samples
+type MetricKind int
+
+const (
+	// Counter values only grow until counters are reset; exporters that
+	// want per-interval counts send the difference between samples
+	Counter MetricKind = iota
+	// Gauge values are the current level of something
+	Gauge
+)
+
+// Metric is one named value from a snapshot. Names are dot-separated
+// paths such as "dns.queries.received"
+type Metric struct {
+	Name  string
+	Kind  MetricKind
+	Value float64
+}
+
+// Metrics flattens the snapshot into the metrics push exporters send
+func (s *Snapshot) Metrics() []Metric {
+	var m metricList
+
+	m.gauge("uptime_seconds", s.UptimeSeconds)
+
+	m.counter("dns.queries.received", s.DNS.QueriesReceived)
+	m.counter("dns.queries.answered", s.DNS.QueriesAnswered)
+	m.counter("dns.queries.nxdomain", s.DNS.QueriesNXDomain)
+	m.counter("dns.queries.error", s.DNS.QueriesError)
+	m.counter("dns.queries.forwarded", s.DNS.QueriesForwarded)
+	m.counter("dns.queries.refused", s.DNS.QueriesRefused)
+	m.counter("dns.queries.timed_out", s.DNS.QueriesTimedOut)
+	m.counter("dns.queries.blocked", s.DNS.QueriesBlocked)
+	m.counter("dns.queries.plugin", s.DNS.QueriesPlugin)
+	m.counter("dns.queries.duplicate", s.DNS.QueriesDuplicate)
+	m.counter("dns.answers.limited", s.DNS.AnswersLimited)
+	m.counter("dns.responses.truncated", s.DNS.ResponsesTruncated)
+	m.counter("dns.rate_limited.dropped", s.DNS.RateLimitedDropped)
+	m.counter("dns.rate_limited.truncated", s.DNS.RateLimitedTruncated)
+
+	m.counter("dns.types.a", s.DNS.TypeA)
+	m.counter("dns.types.aaaa", s.DNS.TypeAAAA)
+	m.counter("dns.types.cname", s.DNS.TypeCNAME)
+	m.counter("dns.types.mx", s.DNS.TypeMX)
+	m.counter("dns.types.txt", s.DNS.TypeTXT)
+	m.counter("dns.types.ns", s.DNS.TypeNS)
+	m.counter("dns.types.srv", s.DNS.TypeSRV)
+	m.counter("dns.types.soa", s.DNS.TypeSOA)
+	m.counter("dns.types.ptr", s.DNS.TypePTR)
+	m.counter("dns.types.caa", s.DNS.TypeCAA)
+	m.counter("dns.types.other", s.DNS.TypeOther)
+
+	if s.Interval != nil {
+		m.gauge("interval.qps", s.Interval.QPS)
+		m.gauge("interval.cache_hit_rate", s.Interval.CacheHitRate)
+	}
+
+	if s.Latency.Samples > 0 {
+		m.gauge("latency.p50_ms", s.Latency.P50)
+		m.gauge("latency.p90_ms", s.Latency.P90)
+		m.gauge("latency.p99_ms", s.Latency.P99)
+		m.gauge("latency.max_ms", s.Latency.Max)
+		m.gauge("latency.mean_ms", s.Latency.Mean)
+	}
+	for _, tier := range slices.Sorted(maps.Keys(s.TierLatency)) {
+		m.histogram("latency.tier."+metricSegment(strings.ToLower(tier)), s.TierLatency[tier])
+	}
+
+	if l0 := s.Cache.L0; l0 != nil {
+		m.cache("cache.l0", l0)
+	}
+	if l1 := s.Cache.L1; l1 != nil {
+		m.cache("cache.l1", l1)
+	}
+	if l2 := s.Cache.L2; l2 != nil {
+		m.gauge("cache.l2.keys", float64(l2.KeyCount))
+		m.counter("cache.l2.timeouts", l2.Timeouts)
+		m.counter("cache.l2.undecoded", l2.Undecoded)
+	}
+
+	if s.PostgreSQL != nil {
+		m.counter("postgresql.slow_queries", s.PostgreSQL.SlowQueries)
+		m.counter("postgresql.timeouts", s.PostgreSQL.Timeouts)
+	}
+
+	if s.Breaker != nil {
+		open := 0.0
+		if s.Breaker.State == "open" {
+			open = 1
+		}
+		m.gauge("circuit_breaker.open", open)
+		m.counter("circuit_breaker.opened", s.Breaker.Opened)
+		m.counter("circuit_breaker.stale_served", s.Breaker.StaleServed)
+		m.counter("circuit_breaker.rejected", s.Breaker.Rejected)
+	}
+
+	if s.CacheOnly != nil {
+		active := 0.0
+		if s.CacheOnly.Active {
+			active = 1
+		}
+		m.gauge("cache_only.active", active)
+		m.counter("cache_only.entered", s.CacheOnly.Entered)
+		m.counter("cache_only.rejected", s.CacheOnly.Rejected)
+	}
+
+	if s.ShadowReads != nil {
+		m.counter("shadow_reads.sampled", s.ShadowReads.Sampled)
+		m.counter("shadow_reads.dropped", s.ShadowReads.Dropped)
+		m.counter("shadow_reads.failed", s.ShadowReads.Failed)
+		m.counter("shadow_reads.matched", s.ShadowReads.Matched)
+		m.counter("shadow_reads.mismatched", s.ShadowReads.Mismatched)
+	}
+
+	for name, pool := range s.Pools.PostgreSQL {
+		m.gauge("pools.postgresql."+name+".open", float64(pool.OpenConnections))
+		m.gauge("pools.postgresql."+name+".in_use", float64(pool.InUse))
+		m.gauge("pools.postgresql."+name+".idle", float64(pool.Idle))
+		m.counter("pools.postgresql."+name+".wait_count", pool.WaitCount)
+	}
+
+	for _, upstream := range s.Forwarder {
+		prefix := "forwarder." + metricSegment(upstream.Address)
+		healthy := 0.0
+		if upstream.Healthy {
+			healthy = 1
+		}
+		m.gauge(prefix+".healthy", healthy)
+		m.gauge(prefix+".srtt_ms", upstream.SRTTMs)
+		m.counter(prefix+".queries", upstream.Queries)
+		m.counter(prefix+".failures", upstream.Failures)
+	}
+
+	for _, p := range s.Plugins {
+		prefix := "plugins." + metricSegment(p.Name)
+		m.counter(prefix+".calls", p.Calls)
+		m.counter(prefix+".failures", p.Failures)
+	}
+
+	if s.Mirror != nil {
+		m.counter("mirror.sampled", s.Mirror.Sampled)
+		m.counter("mirror.dropped", s.Mirror.Dropped)
+		m.counter("mirror.failed", s.Mirror.Failed)
+		m.counter("mirror.matched", s.Mirror.Matched)
+		m.counter("mirror.rcode_mismatches", s.Mirror.RcodeMismatches)
+		m.counter("mirror.answer_mismatches", s.Mirror.AnswerMismatches)
+	}
+
+	for _, f := range s.Chaos {
+		m.counter("chaos."+metricSegment(f.Fault)+".injected", f.Injected)
+	}
+
+	if dropped, ok := s.Logging["logs_dropped"].(int64); ok {
+		m.counter("logging.dropped", dropped)
+	}
+
+	return m
+}
+
+// metricList collects metrics as they are named
+type metricList []Metric
+
+// counter adds a cumulative count
+func (m *metricList) counter(name string, value int64) {
+	*m = append(*m, Metric{Name: name, Kind: Counter, Value: float64(value)})
+}
+
+// gauge adds a current value
+func (m *metricList) gauge(name string, value float64) {
+	*m = append(*m, Metric{Name: name, Kind: Gauge, Value: value})
+}
+
+// histogram adds a latency distribution's summary and cumulative buckets,
+// named for their upper bounds in microseconds
+func (m *metricList) histogram(prefix string, h stats.Histogram) {
+	m.counter(prefix+".count", h.Count)
+	m.gauge(prefix+".p50_ms", h.P50)
+	m.gauge(prefix+".p90_ms", h.P90)
+	m.gauge(prefix+".p99_ms", h.P99)
+	m.gauge(prefix+".max_ms", h.Max)
+	m.gauge(prefix+".mean_ms", h.Mean)
+	for i, bucket := range h.Buckets {
+		m.counter(fmt.Sprintf("%s.le_%dus", prefix, stats.LatencyBuckets[i].Microseconds()), bucket.Count)
+	}
+}
+
+// cache adds the metrics common to the in-process cache tiers
+func (m *metricList) cache(prefix string, stats *cache.Stats) {
+	m.counter(prefix+".hits", stats.Hits)
+	m.counter(prefix+".misses", stats.Misses)
+	m.counter(prefix+".evictions", stats.Evictions)
+	m.gauge(prefix+".entries", float64(stats.Entries))
+	m.gauge(prefix+".bytes", float64(stats.Bytes))
+	m.gauge(prefix+".hit_rate", stats.HitRate)
+
+	// Per-type counters only: zones are too many to export as metrics
+	for _, recordType := range slices.Sorted(maps.Keys(stats.ByType)) {
+		counts := stats.ByType[recordType]
+		typePrefix := prefix + ".type." + metricSegment(recordType)
+		m.counter(typePrefix+".hits", counts.Hits)
+		m.counter(typePrefix+".misses", counts.Misses)
+		m.counter(typePrefix+".evictions", counts.Evictions)
+	}
+}
+
+// metricSegment makes s usable as one segment of a metric name, replacing
+// the dots, colons, and slashes of addresses and URLs with underscores
+func metricSegment(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
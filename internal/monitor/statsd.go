@@ -0,0 +1,129 @@
+// internal/monitor/statsd.go
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// maxStatsDPacket keeps each datagram under a typical Ethernet MTU
+const maxStatsDPacket = 1432
+
+// StatsDConfig holds configuration for a StatsD exporter
+type StatsDConfig struct {
+	Address  string        // agent host:port
+	Prefix   string        // prepended to every metric name
+	Tags     []string      // DogStatsD tags such as "env:prod"; none for plain StatsD
+	Interval time.Duration // how often metrics are sent
+}
+
+// StatsDExporter pushes the collector's metrics to a StatsD or DogStatsD
+// agent over UDP. Counters are sent as the increase since the last push,
+// gauges as their current value
+type StatsDExporter struct {
+	collector *Collector
+	config    *StatsDConfig
+	conn      net.Conn
+	suffix    string // tag section appended to every line
+
+	last map[string]float64 // counter values at the last push
+}
+
+// NewStatsDExporter creates an exporter sending to config.Address
+func NewStatsDExporter(collector *Collector, config *StatsDConfig) (*StatsDExporter, error) {
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open StatsD socket: %w", err)
+	}
+
+	e := &StatsDExporter{
+		collector: collector,
+		config:    config,
+		conn:      conn,
+		last:      make(map[string]float64),
+	}
+	if len(config.Tags) > 0 {
+		e.suffix = "|#" + strings.Join(config.Tags, ",")
+	}
+	return e, nil
+}
+
+// Run pushes metrics every interval until ctx is cancelled, then closes the
+// socket
+func (e *StatsDExporter) Run(ctx context.Context) {
+	defer e.conn.Close()
+
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.push(); err != nil {
+				logging.Warn("monitor", "Failed to send StatsD metrics", "address", e.config.Address, "error", err)
+			}
+		}
+	}
+}
+
+// push sends one round of metrics, batching lines into datagrams
+func (e *StatsDExporter) push() error {
+	var packet bytes.Buffer
+	var lastErr error
+
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, err := e.conn.Write(packet.Bytes()); err != nil {
+			lastErr = err
+		}
+		packet.Reset()
+	}
+
+	for _, metric := range e.collector.Collect().Metrics() {
+		line := e.line(metric)
+		if line == "" {
+			continue
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsDPacket {
+			send()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	send()
+
+	return lastErr
+}
+
+// line formats metric in the StatsD line protocol, or returns "" for a
+// counter that hasn't moved
+func (e *StatsDExporter) line(metric Metric) string {
+	value, kind := metric.Value, "g"
+	if metric.Kind == Counter {
+		// A counter below its last value was reset, so all of it is new
+		previous := e.last[metric.Name]
+		e.last[metric.Name] = value
+		if value >= previous {
+			value -= previous
+		}
+		if value == 0 {
+			return ""
+		}
+		kind = "c"
+	}
+
+	return e.config.Prefix + metric.Name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind + e.suffix
+}
@@ -0,0 +1,370 @@
+// internal/operator/controller.go
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// Owner is the owner of every record the controller manages. Records are
+// tied to the object declaring them by ResourceLabel
+const (
+	Owner         = "kubernetes"
+	ResourceLabel = "errantdns.io/resource"
+)
+
+// Ready condition reasons
+const (
+	reasonReconciled  = "Reconciled"
+	reasonInvalidSpec = "InvalidSpec"
+	reasonApplyFailed = "ApplyFailed"
+)
+
+// retryDelay is how long the controller waits after a failed list or watch
+const retryDelay = 5 * time.Second
+
+// RecordLister enumerates stored records
+type RecordLister interface {
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+}
+
+// SerialStrategist reports how a zone's SOA serial is advanced. Listers
+// that don't implement it have their serials incremented
+type SerialStrategist interface {
+	SerialStrategy(zone string) models.SerialStrategy
+}
+
+// ChangesetApplier applies a batch of record changes atomically
+type ChangesetApplier interface {
+	Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error)
+}
+
+// Controller watches DNSRecord and DNSZone custom resources and reconciles
+// the records they declare into storage. Each object's Ready condition
+// reports whether its records are stored, or why they aren't
+type Controller struct {
+	client    *kubeClient
+	lister    RecordLister
+	applier   ChangesetApplier
+	namespace string
+	resync    time.Duration
+}
+
+// NewController creates a controller for the cluster the process runs in,
+// watching namespace (all namespaces if empty) and relisting every resync
+func NewController(lister RecordLister, applier ChangesetApplier, namespace string, resync time.Duration) (*Controller, error) {
+	client, err := newInClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Controller{
+		client:    client,
+		lister:    lister,
+		applier:   applier,
+		namespace: namespace,
+		resync:    resync,
+	}, nil
+}
+
+// Run reconciles every resource kind until ctx is cancelled
+func (c *Controller) Run(ctx context.Context) {
+	done := make(chan struct{}, len(kinds))
+	for _, kind := range kinds {
+		go func(kind *resourceKind) {
+			c.runKind(ctx, kind)
+			done <- struct{}{}
+		}(kind)
+	}
+	for range kinds {
+		<-done
+	}
+}
+
+// runKind lists and reconciles every object of kind, removes the records
+// of deleted objects, then follows changes until the next resync
+func (c *Controller) runKind(ctx context.Context, kind *resourceKind) {
+	path := kind.path(c.namespace)
+	for ctx.Err() == nil {
+		items, version, err := c.client.list(ctx, path)
+		if err != nil {
+			if ctx.Err() == nil {
+				logging.Warn("operator", "Failed to list resources", "kind", kind.kind, "error", err)
+				sleep(ctx, retryDelay)
+			}
+			continue
+		}
+
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			if key := c.reconcile(ctx, kind, item); key != "" {
+				seen[key] = true
+			}
+		}
+		if err := c.collect(ctx, kind, seen); err != nil {
+			logging.Warn("operator", "Failed to remove records of deleted resources", "kind", kind.kind, "error", err)
+		}
+
+		deadline := time.Now().Add(c.resync)
+		for ctx.Err() == nil && time.Until(deadline) > time.Second {
+			version, err = c.client.watch(ctx, path, version, int(time.Until(deadline).Seconds()), func(event watchEvent) {
+				if event.Type == "DELETED" {
+					c.remove(ctx, kind, event.Object)
+				} else {
+					c.reconcile(ctx, kind, event.Object)
+				}
+			})
+			if errors.Is(err, errGone) {
+				break
+			}
+			if err != nil && ctx.Err() == nil {
+				logging.Warn("operator", "Watch failed", "kind", kind.kind, "error", err)
+				sleep(ctx, retryDelay)
+				break
+			}
+		}
+	}
+}
+
+// reconcile stores the records object declares and updates its status,
+// returning its resource key ("" if the object couldn't be decoded)
+func (c *Controller) reconcile(ctx context.Context, kind *resourceKind, raw json.RawMessage) string {
+	var object struct {
+		Metadata objectMeta      `json:"metadata"`
+		Spec     json.RawMessage `json:"spec"`
+		Status   Status          `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		logging.Warn("operator", "Failed to decode resource", "kind", kind.kind, "error", err)
+		return ""
+	}
+	meta := &object.Metadata
+	key := kind.resourceKey(meta)
+
+	if meta.DeletionTimestamp != nil {
+		c.remove(ctx, kind, raw)
+		return key
+	}
+
+	desired, err := kind.records(object.Spec)
+	if err == nil {
+		err = prepare(desired, key)
+	}
+	if err != nil {
+		c.setReady(ctx, kind, meta, &object.Status, false, reasonInvalidSpec, err.Error(), 0)
+		return key
+	}
+
+	if err := c.sync(ctx, key, desired); err != nil {
+		logging.Warn("operator", "Failed to reconcile resource", "resource", key, "error", err)
+		c.setReady(ctx, kind, meta, &object.Status, false, reasonApplyFailed, err.Error(), 0)
+		return key
+	}
+
+	c.setReady(ctx, kind, meta, &object.Status, true, reasonReconciled,
+		fmt.Sprintf("%d records stored", len(desired)), len(desired))
+	return key
+}
+
+// prepare marks records as declared by key and validates them
+func prepare(records []*models.DNSRecord, key string) error {
+	for i, record := range records {
+		record.Owner = Owner
+		record.Labels = map[string]string{ResourceLabel: key}
+		if err := record.Validate(); err != nil {
+			return fmt.Errorf("record %d (%s %s): %w", i, record.Name, record.RecordType, err)
+		}
+		record.Normalize()
+	}
+	return nil
+}
+
+// sync makes the stored records of key match desired in one changeset.
+// A changed SOA record is updated in place so its serial keeps counting up
+func (c *Controller) sync(ctx context.Context, key string, desired []*models.DNSRecord) error {
+	stored, err := c.storedRecords(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	unmatched := make(map[string][]*models.DNSRecord)
+	for _, record := range stored {
+		id := identity(record)
+		unmatched[id] = append(unmatched[id], record)
+	}
+
+	var created []*models.DNSRecord
+	for _, record := range desired {
+		id := identity(record)
+		if existing := unmatched[id]; len(existing) > 0 {
+			unmatched[id] = existing[1:]
+			continue
+		}
+		created = append(created, record)
+	}
+
+	var deleted []*models.DNSRecord
+	for _, records := range unmatched {
+		deleted = append(deleted, records...)
+	}
+
+	var upserts []models.Change
+	for _, record := range created {
+		if record.RecordType == string(models.RecordTypeSOA) {
+			if old := takeSOA(&deleted); old != nil {
+				record.ID = old.ID
+				record.Serial = c.nextSerial(record.Name, old.Serial)
+				upserts = append(upserts, models.Change{Action: models.ChangeUpdate, Record: record})
+				continue
+			}
+		}
+		upserts = append(upserts, models.Change{Action: models.ChangeCreate, Record: record})
+	}
+
+	// Deletes go first so a replaced record doesn't conflict with itself
+	changes := make([]models.Change, 0, len(deleted)+len(upserts))
+	for _, record := range deleted {
+		changes = append(changes, models.Change{Action: models.ChangeDelete, ID: record.ID})
+	}
+	changes = append(changes, upserts...)
+
+	if len(changes) == 0 {
+		return nil
+	}
+	_, err = c.applier.Apply(ctx, changes)
+	return err
+}
+
+// remove deletes the records of a deleted object
+func (c *Controller) remove(ctx context.Context, kind *resourceKind, raw json.RawMessage) {
+	var object struct {
+		Metadata objectMeta `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return
+	}
+
+	key := kind.resourceKey(&object.Metadata)
+	if err := c.sync(ctx, key, nil); err != nil {
+		logging.Warn("operator", "Failed to remove records of deleted resource", "resource", key, "error", err)
+		return
+	}
+	logging.Info("operator", "Removed records of deleted resource", "resource", key)
+}
+
+// collect deletes the records of objects of kind that no longer exist,
+// such as those deleted while the controller wasn't watching
+func (c *Controller) collect(ctx context.Context, kind *resourceKind, seen map[string]bool) error {
+	records, err := c.lister.ListRecords(ctx, &models.RecordFilter{Owner: Owner})
+	if err != nil {
+		return err
+	}
+
+	prefix := strings.ToLower(kind.kind) + "/"
+	if c.namespace != "" {
+		prefix += c.namespace + "/"
+	}
+
+	var changes []models.Change
+	for _, record := range records {
+		key := record.Labels[ResourceLabel]
+		if strings.HasPrefix(key, prefix) && !seen[key] {
+			changes = append(changes, models.Change{Action: models.ChangeDelete, ID: record.ID})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	_, err = c.applier.Apply(ctx, changes)
+	if err == nil {
+		logging.Info("operator", "Removed records of deleted resources", "kind", kind.kind, "records", len(changes))
+	}
+	return err
+}
+
+// storedRecords returns the stored records declared by key
+func (c *Controller) storedRecords(ctx context.Context, key string) ([]*models.DNSRecord, error) {
+	return c.lister.ListRecords(ctx, &models.RecordFilter{
+		Owner:  Owner,
+		Labels: map[string]string{ResourceLabel: key},
+	})
+}
+
+// setReady records the Ready condition in the object's status, skipping
+// the update if nothing changed so status writes don't trigger reconciles
+func (c *Controller) setReady(ctx context.Context, kind *resourceKind, meta *objectMeta, current *Status, ready bool, reason, message string, records int) {
+	status := "False"
+	if ready {
+		status = "True"
+	}
+
+	condition := Condition{
+		Type:               "Ready",
+		Status:             status,
+		ObservedGeneration: meta.Generation,
+		LastTransitionTime: time.Now().UTC().Format(time.RFC3339),
+		Reason:             reason,
+		Message:            message,
+	}
+	for _, existing := range current.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		if existing == condition && current.ObservedGeneration == meta.Generation && current.Records == records {
+			return
+		}
+	}
+
+	next := &Status{ObservedGeneration: meta.Generation, Records: records, Conditions: []Condition{condition}}
+	if err := c.client.patchStatus(ctx, kind.objectPath(meta), next); err != nil && ctx.Err() == nil {
+		logging.Warn("operator", "Failed to update resource status", "resource", kind.resourceKey(meta), "error", err)
+	}
+}
+
+// identity is what makes two records the same declaration. SOA serials are
+// left out since changesets increment them
+func identity(record *models.DNSRecord) string {
+	return fmt.Sprintf("%s|%s|%s|%d|%d|%d|%d|%s|%s|%s|%d|%d|%d|%d",
+		models.NormalizeDomainName(record.Name), record.RecordType, record.Target,
+		record.TTL, record.Priority, record.Weight, record.Port, record.Tag, record.Comment,
+		models.NormalizeDomainName(record.Mbox), record.Refresh, record.Retry, record.Expire, record.Minttl)
+}
+
+// nextSerial returns the serial following current for zone
+func (c *Controller) nextSerial(zone string, current uint32) uint32 {
+	if strategist, ok := c.lister.(SerialStrategist); ok {
+		return strategist.SerialStrategy(zone).Next(current, time.Now())
+	}
+	return current + 1
+}
+
+// takeSOA removes and returns the first SOA record in records, if any
+func takeSOA(records *[]*models.DNSRecord) *models.DNSRecord {
+	for i, record := range *records {
+		if record.RecordType == string(models.RecordTypeSOA) {
+			*records = append((*records)[:i], (*records)[i+1:]...)
+			return record
+		}
+	}
+	return nil
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,192 @@
+// internal/operator/kube.go
+package operator
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Paths of the service account credentials mounted into every pod
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	tokenFile         = serviceAccountDir + "/token"
+	caFile            = serviceAccountDir + "/ca.crt"
+)
+
+// errGone is returned by watch when the resource version is too old and
+// the caller must list again
+var errGone = errors.New("resource version expired")
+
+// kubeClient is the small part of the Kubernetes API the controller needs,
+// authenticated with the pod's service account
+type kubeClient struct {
+	base string
+	http *http.Client
+}
+
+// watchEvent is one event of a watch stream
+type watchEvent struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK, or ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// newInClusterClient creates a client for the API server of the cluster
+// the process runs in
+func newInClusterClient() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are unset")
+	}
+
+	ca, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("service account CA contains no certificates")
+	}
+	if _, err := os.Stat(tokenFile); err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	return &kubeClient{
+		base: "https://" + net.JoinHostPort(host, port),
+		http: &http.Client{Transport: transport},
+	}, nil
+}
+
+// do sends a request, re-reading the token each time since projected
+// service account tokens are rotated
+func (c *kubeClient) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusGone {
+			return nil, errGone
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// list fetches every object at path, returning them with the list's
+// resource version for a following watch
+func (c *kubeClient) list(ctx context.Context, path string) ([]json.RawMessage, string, error) {
+	resp, err := c.do(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// watch streams changes to the objects at path after resourceVersion to
+// handle until the server ends the watch, returning the last version seen
+func (c *kubeClient) watch(ctx context.Context, path, resourceVersion string, timeoutSeconds int, handle func(watchEvent)) (string, error) {
+	query := url.Values{
+		"watch":               {"true"},
+		"allowWatchBookmarks": {"true"},
+		"resourceVersion":     {resourceVersion},
+		"timeoutSeconds":      {fmt.Sprint(timeoutSeconds)},
+	}
+	resp, err := c.do(ctx, http.MethodGet, path+"?"+query.Encode(), "", nil)
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return resourceVersion, nil
+			}
+			return resourceVersion, fmt.Errorf("watch %s: %w", path, err)
+		}
+
+		if event.Type == "ERROR" {
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return resourceVersion, errGone
+			}
+			return resourceVersion, fmt.Errorf("watch %s: %s", path, status.Message)
+		}
+
+		var meta struct {
+			Metadata objectMeta `json:"metadata"`
+		}
+		if err := json.Unmarshal(event.Object, &meta); err == nil && meta.Metadata.ResourceVersion != "" {
+			resourceVersion = meta.Metadata.ResourceVersion
+		}
+		if event.Type != "BOOKMARK" {
+			handle(event)
+		}
+	}
+}
+
+// patchStatus replaces the status of the object at path
+func (c *kubeClient) patchStatus(ctx context.Context, path string, status interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"status": status})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPatch, path+"/status", "application/merge-patch+json", body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,162 @@
+// internal/operator/resources.go
+package operator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"errantdns.io/internal/models"
+)
+
+// API group and version of the custom resources (kubernetes/crds.yaml)
+const (
+	apiGroup   = "errantdns.io"
+	apiVersion = "v1alpha1"
+)
+
+// objectMeta is the part of Kubernetes object metadata the controller uses
+type objectMeta struct {
+	Name              string  `json:"name"`
+	Namespace         string  `json:"namespace"`
+	UID               string  `json:"uid"`
+	Generation        int64   `json:"generation"`
+	ResourceVersion   string  `json:"resourceVersion"`
+	DeletionTimestamp *string `json:"deletionTimestamp,omitempty"`
+}
+
+// Condition is a standard Kubernetes status condition
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"` // "True" or "False"
+	ObservedGeneration int64  `json:"observedGeneration"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+}
+
+// Status is the status subresource of both resource kinds
+type Status struct {
+	ObservedGeneration int64       `json:"observedGeneration"`
+	Records            int         `json:"records"`
+	Conditions         []Condition `json:"conditions"`
+}
+
+// DNSRecordSpec is one name and type with its targets
+type DNSRecordSpec struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Targets  []string `json:"targets"`
+	TTL      uint32   `json:"ttl"`
+	Priority int      `json:"priority,omitempty"` // MX preference, SRV priority, or answer priority
+	Weight   uint32   `json:"weight,omitempty"`   // SRV
+	Port     uint16   `json:"port,omitempty"`     // SRV
+	Tag      string   `json:"tag,omitempty"`      // CAA
+	Comment  string   `json:"comment,omitempty"`
+}
+
+// DNSZoneSpec is a zone's SOA and NS records
+type DNSZoneSpec struct {
+	Zone        string   `json:"zone"`
+	TTL         uint32   `json:"ttl"`
+	Nameservers []string `json:"nameservers"` // the first is the SOA MNAME
+	Mbox        string   `json:"mbox"`
+	Refresh     uint32   `json:"refresh"`
+	Retry       uint32   `json:"retry"`
+	Expire      uint32   `json:"expire"`
+	Minttl      uint32   `json:"minttl"`
+}
+
+// resourceKind is a custom resource the controller reconciles
+type resourceKind struct {
+	kind   string
+	plural string
+
+	// records converts an object's spec into the records it declares
+	records func(spec json.RawMessage) ([]*models.DNSRecord, error)
+}
+
+var kinds = []*resourceKind{
+	{kind: "DNSRecord", plural: "dnsrecords", records: dnsRecordRecords},
+	{kind: "DNSZone", plural: "dnszones", records: dnsZoneRecords},
+}
+
+// path returns the API path of the kind's objects in namespace, or in all
+// namespaces if it's empty
+func (k *resourceKind) path(namespace string) string {
+	if namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", apiGroup, apiVersion, k.plural)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", apiGroup, apiVersion, namespace, k.plural)
+}
+
+// objectPath returns the API path of one object
+func (k *resourceKind) objectPath(meta *objectMeta) string {
+	return k.path(meta.Namespace) + "/" + meta.Name
+}
+
+// resourceKey identifies an object in the resource label of its records
+func (k *resourceKind) resourceKey(meta *objectMeta) string {
+	return strings.ToLower(k.kind) + "/" + meta.Namespace + "/" + meta.Name
+}
+
+// dnsRecordRecords converts a DNSRecord spec into one record per target
+func dnsRecordRecords(raw json.RawMessage) ([]*models.DNSRecord, error) {
+	var spec DNSRecordSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if len(spec.Targets) == 0 {
+		return nil, errors.New("spec.targets cannot be empty")
+	}
+
+	records := make([]*models.DNSRecord, 0, len(spec.Targets))
+	for _, target := range spec.Targets {
+		records = append(records, &models.DNSRecord{
+			Name:       spec.Name,
+			RecordType: strings.ToUpper(spec.Type),
+			Target:     target,
+			TTL:        spec.TTL,
+			Priority:   spec.Priority,
+			Weight:     spec.Weight,
+			Port:       spec.Port,
+			Tag:        spec.Tag,
+			Comment:    spec.Comment,
+		})
+	}
+	return records, nil
+}
+
+// dnsZoneRecords converts a DNSZone spec into its SOA and NS records
+func dnsZoneRecords(raw json.RawMessage) ([]*models.DNSRecord, error) {
+	var spec DNSZoneSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if len(spec.Nameservers) == 0 {
+		return nil, errors.New("spec.nameservers cannot be empty")
+	}
+
+	records := []*models.DNSRecord{{
+		Name:       spec.Zone,
+		RecordType: string(models.RecordTypeSOA),
+		Target:     spec.Nameservers[0],
+		TTL:        spec.TTL,
+		Serial:     1,
+		Mbox:       spec.Mbox,
+		Refresh:    spec.Refresh,
+		Retry:      spec.Retry,
+		Expire:     spec.Expire,
+		Minttl:     spec.Minttl,
+	}}
+	for _, nameserver := range spec.Nameservers {
+		records = append(records, &models.DNSRecord{
+			Name:       spec.Zone,
+			RecordType: string(models.RecordTypeNS),
+			Target:     nameserver,
+			TTL:        spec.TTL,
+		})
+	}
+	return records, nil
+}
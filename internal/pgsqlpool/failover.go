@@ -0,0 +1,281 @@
+// internal/pgsqlpool/failover.go
+package pgsqlpool
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"errantdns.io/internal/logging"
+)
+
+// Target session attributes, named as in libpq's target_session_attrs
+const (
+	SessionAny           = "any"
+	SessionReadWrite     = "read-write"
+	SessionReadOnly      = "read-only"
+	SessionPrimary       = "primary"
+	SessionStandby       = "standby"
+	SessionPreferStandby = "prefer-standby"
+)
+
+// cancelGracePeriod is how long a query whose context ends is given to
+// stop after the server is asked to cancel it, before its connection is
+// closed instead
+const cancelGracePeriod = time.Second
+
+// sessionStateQuery reports whether a server is a standby and whether its
+// sessions are read-only by default
+const sessionStateQuery = `SELECT pg_is_in_recovery(), current_setting('transaction_read_only') = 'on'`
+
+// validSessionAttrs reports whether attrs is a supported target_session_attrs value
+func validSessionAttrs(attrs string) bool {
+	switch attrs {
+	case "", SessionAny, SessionReadWrite, SessionReadOnly, SessionPrimary, SessionStandby, SessionPreferStandby:
+		return true
+	}
+	return false
+}
+
+// sessionState is what a server reported about itself
+type sessionState struct {
+	inRecovery bool
+	readOnly   bool
+}
+
+// matches reports whether a server in state satisfies attrs. prefer-standby
+// is handled by the connector trying standbys first
+func (s sessionState) matches(attrs string) bool {
+	switch attrs {
+	case SessionReadWrite:
+		return !s.readOnly
+	case SessionReadOnly:
+		return s.readOnly
+	case SessionPrimary:
+		return !s.inRecovery
+	case SessionStandby:
+		return s.inRecovery
+	}
+	return true
+}
+
+// hostConnector connects to one of a connection's hosts
+type hostConnector struct {
+	addr      string
+	connector driver.Connector
+}
+
+// failoverConnector connects to the first of several hosts whose session
+// state satisfies the target session attributes, in the style of a libpq
+// multi-host connection string
+type failoverConnector struct {
+	hosts []hostConnector
+	attrs string
+}
+
+// newFailoverConnector builds a connector over every host in config
+func newFailoverConnector(config *ConnectionConfig) (*failoverConnector, error) {
+	c := &failoverConnector{attrs: config.TargetSessionAttrs}
+	for _, addr := range config.hostAddrs() {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host %q: %w", addr, err)
+		}
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in host %q: %w", addr, err)
+		}
+		connConfig, err := pgx.ParseConfig(config.dsnFor(host, portNum))
+		if err != nil {
+			return nil, fmt.Errorf("invalid connection settings for host %s: %w", addr, err)
+		}
+		configureConn(connConfig, config)
+		c.hosts = append(c.hosts, hostConnector{addr: addr, connector: stdlib.GetConnector(*connConfig)})
+	}
+	return c, nil
+}
+
+// configureConn applies config's statement caching to connConfig, and has
+// queries whose context ends cancelled on the server, so the connection
+// can be reused rather than closed
+func configureConn(connConfig *pgx.ConnConfig, config *ConnectionConfig) {
+	connConfig.StatementCacheCapacity = config.StatementCacheSize
+	if config.StatementCacheSize > 0 {
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	} else {
+		connConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
+
+	connConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelGracePeriod}
+	}
+}
+
+// Connect opens a connection to the first suitable host
+func (c *failoverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.attrs == SessionPreferStandby {
+		if conn, err := c.connect(ctx, SessionStandby); err == nil {
+			return conn, nil
+		}
+		return c.connect(ctx, SessionAny)
+	}
+	return c.connect(ctx, c.attrs)
+}
+
+// Driver returns the underlying PostgreSQL driver
+func (c *failoverConnector) Driver() driver.Driver {
+	return stdlib.GetDefaultDriver()
+}
+
+// connect tries each host in order, returning the first connection whose
+// server satisfies attrs
+func (c *failoverConnector) connect(ctx context.Context, attrs string) (driver.Conn, error) {
+	var errs []error
+	for _, host := range c.hosts {
+		conn, err := host.connector.Connect(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", host.addr, err))
+			continue
+		}
+		if len(c.hosts) == 1 && (attrs == "" || attrs == SessionAny) {
+			return conn, nil
+		}
+
+		state, err := querySessionState(ctx, conn)
+		if err == nil && state.matches(attrs) {
+			return conn, nil
+		}
+		conn.Close()
+		if err == nil {
+			err = fmt.Errorf("server does not satisfy target_session_attrs=%s", attrs)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", host.addr, err))
+	}
+	return nil, fmt.Errorf("no suitable host: %w", errors.Join(errs...))
+}
+
+// querySessionState asks a freshly opened connection for its session state
+func querySessionState(ctx context.Context, conn driver.Conn) (sessionState, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return sessionState{}, fmt.Errorf("driver connection cannot run queries")
+	}
+	rows, err := queryer.QueryContext(ctx, sessionStateQuery, nil)
+	if err != nil {
+		return sessionState{}, err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, 2)
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("session state query returned no rows")
+		}
+		return sessionState{}, err
+	}
+	inRecovery, _ := dest[0].(bool)
+	readOnly, _ := dest[1].(bool)
+	return sessionState{inRecovery: inRecovery, readOnly: readOnly}, nil
+}
+
+// MonitorFailover checks every interval that each multi-host connection is
+// still talking to a server that satisfies its target session attributes,
+// for example that the primary wasn't demoted in a switchover. Connections
+// that fail the check are reopened against whichever host now qualifies.
+// It runs until the context is cancelled
+func (p *Pool) MonitorFailover(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, name := range p.failoverConnections() {
+				p.checkFailover(ctx, name, interval)
+			}
+		}
+	}
+}
+
+// failoverConnections returns the names of connections with failover configured
+func (p *Pool) failoverConnections() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var names []string
+	for name, config := range p.configs {
+		if config.failover() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkFailover reopens name if its server no longer satisfies its target
+// session attributes or can't be reached
+func (p *Pool) checkFailover(ctx context.Context, name string, timeout time.Duration) {
+	p.mu.RLock()
+	db, config := p.connections[name], p.configs[name]
+	p.mu.RUnlock()
+	if db == nil || config == nil {
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var state sessionState
+	err := db.QueryRowContext(checkCtx, sessionStateQuery).Scan(&state.inRecovery, &state.readOnly)
+	attrs := config.TargetSessionAttrs
+	if attrs == SessionPreferStandby {
+		attrs = SessionAny
+	}
+	if err == nil && state.matches(attrs) {
+		return
+	}
+
+	if err := p.reopen(checkCtx, name, db, config); err != nil {
+		logging.Error("pgsqlpool", "Connection failed its failover check and could not be reopened", err, "connection", name)
+		return
+	}
+	logging.Warn("pgsqlpool", "Reopened connection after its server stopped qualifying",
+		"connection", name, "target_session_attrs", config.TargetSessionAttrs)
+}
+
+// reopen replaces old with a new database handle for name, if a suitable
+// host can be reached. Queries already running on old are allowed to finish
+func (p *Pool) reopen(ctx context.Context, name string, old *sql.DB, config *ConnectionConfig) error {
+	db, err := openDB(config)
+	if err != nil {
+		return err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return err
+	}
+
+	p.mu.Lock()
+	if p.connections[name] != old {
+		// Replaced or removed meanwhile
+		p.mu.Unlock()
+		db.Close()
+		return nil
+	}
+	p.connections[name] = db
+	p.mu.Unlock()
+
+	return old.Close()
+}
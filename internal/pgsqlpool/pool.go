@@ -5,13 +5,21 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
+// DefaultStatementCacheSize is how many prepared statements each
+// connection keeps by default
+const DefaultStatementCacheSize = 512
+
 // ConnectionConfig holds configuration for a database connection
 type ConnectionConfig struct {
 	Host     string
@@ -21,11 +29,26 @@ type ConnectionConfig struct {
 	DBName   string
 	SSLMode  string // disable, require, verify-ca, verify-full
 
+	// Hosts, when set, replaces Host with servers tried in order, each
+	// "host" (using Port) or "host:port"
+	Hosts []string
+
+	// TargetSessionAttrs chooses among Hosts as libpq does: any,
+	// read-write, read-only, primary, standby, or prefer-standby
+	TargetSessionAttrs string
+
 	// Pool settings
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+
+	// StatementCacheSize is how many prepared statements each connection
+	// keeps, so repeated queries skip parsing and planning and exchange
+	// binary values in one round trip. 0 disables the cache, describing
+	// each query in a round trip of its own, for poolers such as PgBouncer
+	// in transaction mode that can't hold named statements
+	StatementCacheSize int
 }
 
 // DefaultConnectionConfig returns a config with sensible defaults
@@ -38,20 +61,65 @@ func DefaultConnectionConfig() *ConnectionConfig {
 		MaxIdleConns:    5,
 		ConnMaxLifetime: 5 * time.Minute,
 		ConnMaxIdleTime: 2 * time.Minute,
+
+		StatementCacheSize: DefaultStatementCacheSize,
 	}
 }
 
-// DSN returns the PostgreSQL data source name for this config
+// DSN returns the PostgreSQL data source name for this config's first host
 func (c *ConnectionConfig) DSN() string {
+	host, port := c.Host, c.Port
+	if addrs := c.hostAddrs(); len(addrs) > 0 {
+		if h, p, err := net.SplitHostPort(addrs[0]); err == nil {
+			host = h
+			port, _ = strconv.Atoi(p)
+		}
+	}
+	return c.dsnFor(host, port)
+}
+
+// dsnFor returns the data source name for connecting to one host
+func (c *ConnectionConfig) dsnFor(host string, port int) string {
 	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.Host, c.Port, c.User, c.Password, c.DBName, c.SSLMode)
+		dsnValue(host), port, dsnValue(c.User), dsnValue(c.Password), dsnValue(c.DBName), dsnValue(c.SSLMode))
+}
+
+// dsnValue quotes v for a keyword/value connection string, so empty values
+// and values with spaces or quotes survive parsing
+func dsnValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// hostAddrs returns every host as host:port, in the order they are tried
+func (c *ConnectionConfig) hostAddrs() []string {
+	if len(c.Hosts) == 0 {
+		return []string{net.JoinHostPort(c.Host, strconv.Itoa(c.Port))}
+	}
+	addrs := make([]string, len(c.Hosts))
+	for i, host := range c.Hosts {
+		if _, _, err := net.SplitHostPort(host); err == nil {
+			addrs[i] = host
+		} else {
+			addrs[i] = net.JoinHostPort(host, strconv.Itoa(c.Port))
+		}
+	}
+	return addrs
+}
+
+// failover reports whether the connection chooses among hosts, and so
+// should be watched for switchovers
+func (c *ConnectionConfig) failover() bool {
+	return len(c.Hosts) > 1 || (c.TargetSessionAttrs != "" && c.TargetSessionAttrs != SessionAny)
 }
 
 // Validate checks if the connection config is valid
 func (c *ConnectionConfig) Validate() error {
-	if c.Host == "" {
+	if c.Host == "" && len(c.Hosts) == 0 {
 		return fmt.Errorf("host cannot be empty")
 	}
+	if !validSessionAttrs(c.TargetSessionAttrs) {
+		return fmt.Errorf("unsupported target session attributes %q", c.TargetSessionAttrs)
+	}
 	if c.Port <= 0 || c.Port > 65535 {
 		return fmt.Errorf("port must be between 1 and 65535")
 	}
@@ -67,6 +135,9 @@ func (c *ConnectionConfig) Validate() error {
 	if c.MaxIdleConns < 0 {
 		return fmt.Errorf("max idle connections cannot be negative")
 	}
+	if c.StatementCacheSize < 0 {
+		return fmt.Errorf("statement cache size cannot be negative")
+	}
 	return nil
 }
 
@@ -74,17 +145,30 @@ func (c *ConnectionConfig) Validate() error {
 type Pool struct {
 	mu          sync.RWMutex
 	connections map[string]*sql.DB
+	configs     map[string]*ConnectionConfig
 }
 
 // NewPool creates a new connection pool
 func NewPool() *Pool {
 	return &Pool{
 		connections: make(map[string]*sql.DB),
+		configs:     make(map[string]*ConnectionConfig),
 	}
 }
 
 // AddConnection creates and adds a new named database connection
 func (p *Pool) AddConnection(ctx context.Context, name string, config *ConnectionConfig) error {
+	return p.addConnection(ctx, name, config, true)
+}
+
+// AddDeferredConnection adds a named database connection without checking
+// that the database is reachable. Connections are made on first use, so
+// queries fail until the database comes up
+func (p *Pool) AddDeferredConnection(name string, config *ConnectionConfig) error {
+	return p.addConnection(context.Background(), name, config, false)
+}
+
+func (p *Pool) addConnection(ctx context.Context, name string, config *ConnectionConfig, ping bool) error {
 	if err := config.Validate(); err != nil {
 		return fmt.Errorf("invalid config for connection %s: %w", name, err)
 	}
@@ -98,29 +182,44 @@ func (p *Pool) AddConnection(ctx context.Context, name string, config *Connectio
 	}
 
 	// Create the connection
-	db, err := sql.Open("postgres", config.DSN())
+	db, err := openDB(config)
 	if err != nil {
 		return fmt.Errorf("failed to open connection %s: %w", name, err)
 	}
 
-	// Configure connection pool
-	db.SetMaxOpenConns(config.MaxOpenConns)
-	db.SetMaxIdleConns(config.MaxIdleConns)
-	db.SetConnMaxLifetime(config.ConnMaxLifetime)
-	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
-
 	// Test the connection
-	if err := db.PingContext(ctx); err != nil {
-		db.Close()
-		return fmt.Errorf("failed to ping connection %s: %w", name, err)
+	if ping {
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			return fmt.Errorf("failed to ping connection %s: %w", name, err)
+		}
 	}
 
 	// Store the connection
 	p.connections[name] = db
+	p.configs[name] = config
 
 	return nil
 }
 
+// openDB creates a database handle for config. No connection is made
+// until it is used
+func openDB(config *ConnectionConfig) (*sql.DB, error) {
+	connector, err := newFailoverConnector(config)
+	if err != nil {
+		return nil, err
+	}
+
+	db := sql.OpenDB(connector)
+
+	// Configure connection pool
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	return db, nil
+}
+
 // GetConnection returns a named database connection
 func (p *Pool) GetConnection(name string) (*sql.DB, error) {
 	p.mu.RLock()
@@ -149,6 +248,7 @@ func (p *Pool) RemoveConnection(name string) error {
 	}
 
 	delete(p.connections, name)
+	delete(p.configs, name)
 	return nil
 }
 
@@ -187,6 +287,45 @@ func (p *Pool) HealthCheck(ctx context.Context, name string) error {
 	return nil
 }
 
+// ConnectionStats is a summary of a named connection's pool usage
+type ConnectionStats struct {
+	MaxOpenConnections int `json:"max_open_connections"`
+	OpenConnections    int `json:"open_connections"`
+	InUse              int `json:"in_use"`
+	Idle               int `json:"idle"`
+
+	// Waits for a free connection, which grow when the pool is exhausted
+	WaitCount         int64 `json:"wait_count"`
+	WaitDurationMs    int64 `json:"wait_duration_ms"`
+	MaxIdleClosed     int64 `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64 `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64 `json:"max_lifetime_closed"`
+}
+
+// Stats returns pool usage statistics for every named connection
+func (p *Pool) Stats() map[string]ConnectionStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make(map[string]ConnectionStats, len(p.connections))
+	for name, db := range p.connections {
+		dbStats := db.Stats()
+		stats[name] = ConnectionStats{
+			MaxOpenConnections: dbStats.MaxOpenConnections,
+			OpenConnections:    dbStats.OpenConnections,
+			InUse:              dbStats.InUse,
+			Idle:               dbStats.Idle,
+			WaitCount:          dbStats.WaitCount,
+			WaitDurationMs:     dbStats.WaitDuration.Milliseconds(),
+			MaxIdleClosed:      dbStats.MaxIdleClosed,
+			MaxIdleTimeClosed:  dbStats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  dbStats.MaxLifetimeClosed,
+		}
+	}
+
+	return stats
+}
+
 // HealthCheckAll checks all connections and returns any errors
 func (p *Pool) HealthCheckAll(ctx context.Context) map[string]error {
 	p.mu.RLock()
@@ -220,6 +359,7 @@ func (p *Pool) Close() error {
 
 	// Clear the map
 	p.connections = make(map[string]*sql.DB)
+	p.configs = make(map[string]*ConnectionConfig)
 
 	return lastErr
 }
@@ -281,6 +421,36 @@ func (p *Pool) Transaction(ctx context.Context, connectionName string, fn func(*
 	return nil
 }
 
+// SendBatch sends every query in batch on one connection of a named
+// connection in a single round trip, and passes the results to fn. Outside
+// an explicit transaction the batch runs as one implicit transaction
+func (p *Pool) SendBatch(ctx context.Context, connectionName string, batch *pgx.Batch, fn func(pgx.BatchResults) error) error {
+	db, err := p.GetConnection(connectionName)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("connection %s does not support batches", connectionName)
+		}
+
+		results := stdConn.Conn().SendBatch(ctx, batch)
+		if err := fn(results); err != nil {
+			results.Close()
+			return err
+		}
+		return results.Close()
+	})
+}
+
 // ExecSchemaFile executes SQL statements from a file on a named connection
 func (p *Pool) ExecSchemaFile(ctx context.Context, connectionName, filePath string) error {
 	db, err := p.GetConnection(connectionName)
@@ -0,0 +1,164 @@
+// internal/plugin/grpc.go
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// gRPC framing and limits
+const (
+	grpcContentType = "application/grpc+json"
+	frameHeaderSize = 5       // compressed flag and big-endian length
+	maxMessageSize  = 4 << 20 // gRPC's default receive limit
+)
+
+// StatusError is a non-OK gRPC status returned by a plugin
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+// Error returns the status code and message
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", e.Code, e.Message)
+}
+
+// conn makes unary gRPC calls to one plugin over HTTP/2, encoding messages
+// with the gRPC JSON codec. Plain addresses use cleartext HTTP/2; https://
+// addresses use TLS
+type conn struct {
+	base   string
+	client *http.Client
+}
+
+// newConn creates a connection to address, "host:port" or
+// "https://host:port"
+func newConn(address string) (*conn, error) {
+	base := address
+	if !strings.Contains(address, "://") {
+		base = "http://" + address
+	}
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid plugin address %q", address)
+	}
+
+	protocols := new(http.Protocols)
+	transport := &http.Transport{
+		Protocols:       protocols,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	if u.Scheme == "https" {
+		protocols.SetHTTP2(true)
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+
+	return &conn{
+		base:   strings.TrimSuffix(u.String(), "/"),
+		client: &http.Client{Transport: transport},
+	}, nil
+}
+
+// invoke calls method ("/package.Service/Method") with req, decoding the
+// reply into resp. The call is bounded by ctx's deadline
+func (c *conn) invoke(ctx context.Context, method string, req, resp any) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[1:frameHeaderSize], uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+method, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", grpcContentType)
+	httpReq.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", max(time.Until(deadline).Milliseconds(), 1)))
+	}
+
+	httpResp, err := c.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status %s", httpResp.Status)
+	}
+
+	// A trailers-only response carries the status in its headers
+	if err := grpcStatus(httpResp.Header); err != nil {
+		return err
+	}
+
+	message, err := readFrame(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, httpResp.Body); err != nil {
+		return err
+	}
+	if err := grpcStatus(httpResp.Trailer); err != nil {
+		return err
+	}
+	if message == nil {
+		return errors.New("plugin returned no message")
+	}
+	return json.Unmarshal(message, resp)
+}
+
+// readFrame reads one length-prefixed message, returning nil at the end of
+// the stream
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+	if header[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the %d byte limit", length, maxMessageSize)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	return message, nil
+}
+
+// grpcStatus returns the error for a non-OK grpc-status in header, if any
+func grpcStatus(header http.Header) error {
+	status := header.Get("Grpc-Status")
+	if status == "" || status == "0" {
+		return nil
+	}
+	code := 2 // UNKNOWN
+	fmt.Sscanf(status, "%d", &code)
+	message, err := url.PathUnescape(header.Get("Grpc-Message"))
+	if err != nil {
+		message = header.Get("Grpc-Message")
+	}
+	return &StatusError{Code: code, Message: message}
+}
@@ -0,0 +1,307 @@
+// internal/plugin/plugin.go
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ErrRejected is returned for record changes a mutation plugin refused
+var ErrRejected = errors.New("rejected by plugin")
+
+// Hooks a plugin can subscribe to
+const (
+	HookQuery    = "query"    // called for each query before the caches
+	HookMutation = "mutation" // called before record changes are written
+)
+
+// Methods of the errantdns.plugin.v1.Plugin service; see plugin.proto
+const (
+	methodQuery  = "/errantdns.plugin.v1.Plugin/Query"
+	methodMutate = "/errantdns.plugin.v1.Plugin/Mutate"
+)
+
+// Query actions
+const (
+	ActionContinue = "CONTINUE" // pass the query on unchanged
+	ActionAnswer   = "ANSWER"   // reply with the plugin's message
+	ActionDrop     = "DROP"     // send no reply
+)
+
+// QueryRequest asks a plugin what to do with a query
+type QueryRequest struct {
+	Message   []byte `json:"message"`         // packed query
+	Client    string `json:"client"`          // client IP address
+	Transport string `json:"transport"`       // "udp" or "tcp"
+	Group     string `json:"group,omitempty"` // client's policy group
+}
+
+// QueryResponse is a plugin's decision about a query
+type QueryResponse struct {
+	Action  string `json:"action,omitempty"`  // empty continues
+	Message []byte `json:"message,omitempty"` // packed reply for ANSWER
+}
+
+// MutateRequest asks a plugin to approve record changes
+type MutateRequest struct {
+	Source  string          `json:"source"` // "api" or "changeset"
+	Changes []models.Change `json:"changes"`
+}
+
+// MutateResponse approves or rejects record changes, optionally replacing
+// their records
+type MutateResponse struct {
+	Allow   bool            `json:"allow"`
+	Reason  string          `json:"reason,omitempty"`
+	Changes []models.Change `json:"changes,omitempty"` // same actions in the same order
+}
+
+// Config holds configuration for out-of-process plugins
+type Config struct {
+	Plugins  []string      // name=hooks@address entries; see ParsePlugin
+	Timeout  time.Duration // per-call timeout
+	FailOpen bool          // on plugin errors, continue queries and allow changes
+}
+
+// Spec is one configured plugin
+type Spec struct {
+	Name    string
+	Hooks   []string
+	Address string
+}
+
+// ParsePlugin parses a plugin entry of the form name=hooks@address, where
+// hooks is "query", "mutation", or both separated by "|", e.g.
+// "router=query@127.0.0.1:9000" or "audit=mutation@https://audit:9443"
+func ParsePlugin(entry string) (*Spec, error) {
+	name, rest, found := strings.Cut(entry, "=")
+	hooks, address, hasAddress := strings.Cut(rest, "@")
+	name, address = strings.TrimSpace(name), strings.TrimSpace(address)
+	if !found || !hasAddress || name == "" || address == "" {
+		return nil, fmt.Errorf("invalid plugin %q: expected name=hooks@address", entry)
+	}
+
+	spec := &Spec{Name: name, Address: address}
+	for _, hook := range strings.Split(hooks, "|") {
+		switch hook = strings.ToLower(strings.TrimSpace(hook)); hook {
+		case HookQuery, HookMutation:
+			spec.Hooks = append(spec.Hooks, hook)
+		default:
+			return nil, fmt.Errorf("invalid plugin %q: unknown hook %q (use query or mutation)", entry, hook)
+		}
+	}
+	return spec, nil
+}
+
+// Plugin is a connection to one external plugin process
+type Plugin struct {
+	name string
+	conn *conn
+
+	calls    atomic.Int64
+	failures atomic.Int64
+}
+
+// Stats holds call counts for one plugin
+type Stats struct {
+	Name     string `json:"name"`
+	Calls    int64  `json:"calls"`
+	Failures int64  `json:"failures"`
+}
+
+// Manager calls the configured plugins in order for each hook
+type Manager struct {
+	plugins  []*Plugin
+	query    []*Plugin
+	mutation []*Plugin
+	timeout  time.Duration
+	failOpen bool
+}
+
+// NewManager creates connections to the configured plugins. Plugins are
+// called lazily, so they don't have to be running yet
+func NewManager(config *Config) (*Manager, error) {
+	m := &Manager{timeout: config.Timeout, failOpen: config.FailOpen}
+	seen := make(map[string]bool)
+
+	for _, entry := range config.Plugins {
+		spec, err := ParsePlugin(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("plugin %q is configured more than once", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		conn, err := newConn(spec.Address)
+		if err != nil {
+			return nil, err
+		}
+		p := &Plugin{name: spec.Name, conn: conn}
+		m.plugins = append(m.plugins, p)
+		for _, hook := range spec.Hooks {
+			switch hook {
+			case HookQuery:
+				m.query = append(m.query, p)
+			case HookMutation:
+				m.mutation = append(m.mutation, p)
+			}
+		}
+	}
+	return m, nil
+}
+
+// HasQueryHooks reports whether any plugin sees queries
+func (m *Manager) HasQueryHooks() bool {
+	return m != nil && len(m.query) > 0
+}
+
+// HasMutationHooks reports whether any plugin sees record changes
+func (m *Manager) HasMutationHooks() bool {
+	return m != nil && len(m.mutation) > 0
+}
+
+// Stats returns call counts for every plugin
+func (m *Manager) Stats() []Stats {
+	stats := make([]Stats, len(m.plugins))
+	for i, p := range m.plugins {
+		stats[i] = Stats{Name: p.name, Calls: p.calls.Load(), Failures: p.failures.Load()}
+	}
+	return stats
+}
+
+// Query asks the query plugins in order what to do with query, stopping at
+// the first that doesn't continue. It returns the action and, for ANSWER,
+// the reply with query's ID. A failing plugin is skipped when failing open;
+// otherwise its error is returned
+func (m *Manager) Query(ctx context.Context, query *dns.Msg, client net.IP, transport, group string) (string, *dns.Msg, error) {
+	packed, err := query.Pack()
+	if err != nil {
+		return "", nil, err
+	}
+	req := &QueryRequest{Message: packed, Client: client.String(), Transport: transport, Group: group}
+
+	for _, p := range m.query {
+		var resp QueryResponse
+		if err := m.call(ctx, p, methodQuery, req, &resp); err != nil {
+			if m.failOpen {
+				logging.WarnContext(ctx, "plugin", "Query plugin failed, continuing", "plugin", p.name, "error", err)
+				continue
+			}
+			return "", nil, fmt.Errorf("plugin %s: %w", p.name, err)
+		}
+
+		switch resp.Action {
+		case "", ActionContinue:
+			continue
+		case ActionDrop:
+			return ActionDrop, nil, nil
+		case ActionAnswer:
+			reply := new(dns.Msg)
+			if err := reply.Unpack(resp.Message); err != nil {
+				p.failures.Add(1)
+				err = fmt.Errorf("plugin %s returned an invalid message: %w", p.name, err)
+				if m.failOpen {
+					logging.WarnContext(ctx, "plugin", "Query plugin failed, continuing", "plugin", p.name, "error", err)
+					continue
+				}
+				return "", nil, err
+			}
+			reply.Id = query.Id
+			return ActionAnswer, reply, nil
+		default:
+			p.failures.Add(1)
+			err := fmt.Errorf("plugin %s returned unknown action %q", p.name, resp.Action)
+			if m.failOpen {
+				logging.WarnContext(ctx, "plugin", "Query plugin failed, continuing", "plugin", p.name, "error", err)
+				continue
+			}
+			return "", nil, err
+		}
+	}
+	return ActionContinue, nil, nil
+}
+
+// Mutate asks the mutation plugins in order to approve changes, returning
+// the changes with any records the plugins replaced. A rejection returns an
+// error wrapping ErrRejected
+func (m *Manager) Mutate(ctx context.Context, source string, changes []models.Change) ([]models.Change, error) {
+	for _, p := range m.mutation {
+		var resp MutateResponse
+		err := m.call(ctx, p, methodMutate, &MutateRequest{Source: source, Changes: changes}, &resp)
+		if err != nil {
+			if m.failOpen {
+				logging.Warn("plugin", "Mutation plugin failed, allowing changes", "plugin", p.name, "error", err)
+				continue
+			}
+			return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+		}
+
+		if !resp.Allow {
+			reason := resp.Reason
+			if reason == "" {
+				reason = "no reason given"
+			}
+			return nil, fmt.Errorf("%w %s: %s", ErrRejected, p.name, reason)
+		}
+
+		if len(resp.Changes) > 0 {
+			if err := sameActions(changes, resp.Changes); err != nil {
+				p.failures.Add(1)
+				return nil, fmt.Errorf("plugin %s: %w", p.name, err)
+			}
+			changes = resp.Changes
+		}
+	}
+	return changes, nil
+}
+
+// call invokes method on p within the plugin timeout, counting the call
+func (m *Manager) call(ctx context.Context, p *Plugin, method string, req, resp any) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	p.calls.Add(1)
+	if err := p.conn.invoke(ctx, method, req, resp); err != nil {
+		p.failures.Add(1)
+		return err
+	}
+	return nil
+}
+
+// sameActions checks that a plugin's replacement changes line up with the
+// originals, so a plugin can edit records but not add or drop changes
+func sameActions(original, replaced []models.Change) error {
+	if len(replaced) != len(original) {
+		return fmt.Errorf("returned %d changes for %d", len(replaced), len(original))
+	}
+	for i := range original {
+		if replaced[i].Action != original[i].Action {
+			return fmt.Errorf("change %d: returned %s for %s", i, replaced[i].Action, original[i].Action)
+		}
+		if replaced[i].Action == models.ChangeDelete {
+			if replaced[i].ID != original[i].ID {
+				return fmt.Errorf("change %d: returned a delete of a different record", i)
+			}
+			continue
+		}
+		if replaced[i].Record == nil {
+			return fmt.Errorf("change %d: returned no record", i)
+		}
+		if err := replaced[i].Validate(); err != nil {
+			return fmt.Errorf("change %d: %w", i, err)
+		}
+	}
+	return nil
+}
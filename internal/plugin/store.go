@@ -0,0 +1,158 @@
+// internal/plugin/store.go
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"errantdns.io/internal/models"
+)
+
+// Sources of record changes sent to mutation plugins
+const (
+	SourceAPI       = "api"
+	SourceChangeset = "changeset"
+)
+
+// RecordStore applies single record changes
+type RecordStore interface {
+	CreateRecord(ctx context.Context, record *models.DNSRecord) error
+	UpdateRecord(ctx context.Context, record *models.DNSRecord) error
+	DeleteRecord(ctx context.Context, id int) error
+}
+
+// RecordUpserter creates a record or updates the matching stored record,
+// reporting whether it created one
+type RecordUpserter interface {
+	UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error)
+}
+
+// RecordBulkCreator creates many records in one transaction
+type RecordBulkCreator interface {
+	CreateRecords(ctx context.Context, records []*models.DNSRecord) error
+}
+
+// ChangesetApplier applies a batch of record changes atomically
+type ChangesetApplier interface {
+	Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error)
+}
+
+// Store passes record changes through the mutation plugins before writing
+// them to the wrapped store
+type Store struct {
+	next    RecordStore
+	manager *Manager
+}
+
+// Store wraps next so its writes are approved by the mutation plugins
+func (m *Manager) Store(next RecordStore) *Store {
+	return &Store{next: next, manager: m}
+}
+
+// CreateRecord creates record once the plugins approve it
+func (s *Store) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	approved, err := s.approve(ctx, models.Change{Action: models.ChangeCreate, Record: record})
+	if err != nil {
+		return err
+	}
+	return s.next.CreateRecord(ctx, approved.Record)
+}
+
+// UpdateRecord updates record once the plugins approve it
+func (s *Store) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	approved, err := s.approve(ctx, models.Change{Action: models.ChangeUpdate, Record: record})
+	if err != nil {
+		return err
+	}
+	return s.next.UpdateRecord(ctx, approved.Record)
+}
+
+// CreateRecords creates records once the plugins approve all of them,
+// asking them once for the whole batch. Stores that can't create records
+// in bulk create them one at a time
+func (s *Store) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	changes := make([]models.Change, len(records))
+	for i, record := range records {
+		changes[i] = models.Change{Action: models.ChangeCreate, Record: record}
+	}
+	approved, err := s.manager.Mutate(ctx, SourceAPI, changes)
+	if err != nil {
+		return err
+	}
+	for i, change := range approved {
+		if change.Record != nil && change.Record != records[i] {
+			*records[i] = *change.Record
+		}
+	}
+
+	if creator, ok := s.next.(RecordBulkCreator); ok {
+		return creator.CreateRecords(ctx, records)
+	}
+	for _, record := range records {
+		if err := s.next.CreateRecord(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteRecord deletes the record with id once the plugins approve it
+func (s *Store) DeleteRecord(ctx context.Context, id int) error {
+	if _, err := s.approve(ctx, models.Change{Action: models.ChangeDelete, ID: id}); err != nil {
+		return err
+	}
+	return s.next.DeleteRecord(ctx, id)
+}
+
+// UpsertRecord upserts record once the plugins approve it. Stores that
+// can't upsert create the record instead
+func (s *Store) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	approved, err := s.approve(ctx, models.Change{Action: models.ChangeUpsert, Record: record})
+	if err != nil {
+		return false, err
+	}
+	if upserter, ok := s.next.(RecordUpserter); ok {
+		return upserter.UpsertRecord(ctx, approved.Record)
+	}
+	if err := s.next.CreateRecord(ctx, approved.Record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// approve runs one change past the plugins. A replaced record is copied
+// back into the caller's, which reads IDs and timestamps from it
+func (s *Store) approve(ctx context.Context, change models.Change) (models.Change, error) {
+	changes, err := s.manager.Mutate(ctx, SourceAPI, []models.Change{change})
+	if err != nil {
+		return models.Change{}, err
+	}
+	approved := changes[0]
+	if change.Record != nil && approved.Record != change.Record {
+		*change.Record = *approved.Record
+		approved.Record = change.Record
+	}
+	return approved, nil
+}
+
+// Changesets passes changesets through the mutation plugins before
+// applying them with the wrapped applier
+type Changesets struct {
+	next    ChangesetApplier
+	manager *Manager
+}
+
+// Changesets wraps next so its changesets are approved by the mutation
+// plugins
+func (m *Manager) Changesets(next ChangesetApplier) *Changesets {
+	return &Changesets{next: next, manager: m}
+}
+
+// Apply applies changes once the plugins approve them
+func (c *Changesets) Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error) {
+	approved, err := c.manager.Mutate(ctx, SourceChangeset, changes)
+	if err != nil {
+		return nil, fmt.Errorf("changeset not applied: %w", err)
+	}
+	return c.next.Apply(ctx, approved)
+}
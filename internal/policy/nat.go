@@ -0,0 +1,100 @@
+// internal/policy/nat.go
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// natRule maps addresses in one network onto another of the same size
+type natRule struct {
+	external netip.Prefix
+	internal netip.Prefix
+}
+
+// NAT rewrites the addresses in a group's answers, for split-horizon
+// setups where records hold public addresses that internal clients reach
+// at private ones. Each rule maps an external network onto an internal
+// network of the same size, keeping the host part of the address
+type NAT struct {
+	rules []natRule
+}
+
+// NewNAT parses rules of the form "external>internal", e.g.
+// "203.0.113.0/24>10.1.0.0/24" or "198.51.100.7>10.2.0.7". The most
+// specific external network containing an address wins
+func NewNAT(rules []string) (*NAT, error) {
+	nat := &NAT{}
+	for _, rule := range rules {
+		r, err := parseNATRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		for _, existing := range nat.rules {
+			if existing.external == r.external {
+				return nil, fmt.Errorf("invalid nat rule %q: %s is already mapped", rule, r.external)
+			}
+		}
+		nat.rules = append(nat.rules, r)
+	}
+
+	sort.SliceStable(nat.rules, func(i, j int) bool {
+		return nat.rules[i].external.Bits() > nat.rules[j].external.Bits()
+	})
+	return nat, nil
+}
+
+// parseNATRule parses one external>internal mapping
+func parseNATRule(rule string) (natRule, error) {
+	external, internal, found := strings.Cut(rule, ">")
+	if !found {
+		return natRule{}, fmt.Errorf("invalid nat rule %q: expected external>internal", rule)
+	}
+
+	var r natRule
+	var err error
+	if r.external, err = parsePrefix(external); err != nil {
+		return natRule{}, fmt.Errorf("invalid nat rule %q: %w", rule, err)
+	}
+	if r.internal, err = parsePrefix(internal); err != nil {
+		return natRule{}, fmt.Errorf("invalid nat rule %q: %w", rule, err)
+	}
+	if r.external.Addr().Is4() != r.internal.Addr().Is4() || r.external.Bits() != r.internal.Bits() {
+		return natRule{}, fmt.Errorf("invalid nat rule %q: networks must be the same family and size", rule)
+	}
+	return r, nil
+}
+
+// Translate returns the internal address for addr, or reports false when
+// no rule maps it
+func (n *NAT) Translate(addr netip.Addr) (netip.Addr, bool) {
+	if n == nil {
+		return addr, false
+	}
+	addr = addr.Unmap()
+	for _, r := range n.rules {
+		if !r.external.Contains(addr) {
+			continue
+		}
+
+		// Network bits from the internal prefix, host bits from addr
+		network, host := r.internal.Addr().As16(), addr.As16()
+		offset := 0
+		if addr.Is4() {
+			offset = 96
+		}
+		for bit := offset + r.internal.Bits(); bit < 128; bit++ {
+			mask := byte(0x80) >> (bit % 8)
+			network[bit/8] = network[bit/8]&^mask | host[bit/8]&mask
+		}
+
+		translated := netip.AddrFrom16(network)
+		if addr.Is4() {
+			translated = translated.Unmap()
+		}
+		return translated, true
+	}
+	return addr, false
+}
@@ -0,0 +1,113 @@
+// internal/policy/policy.go
+package policy
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"errantdns.io/internal/blocklist"
+	"errantdns.io/internal/forwarder"
+	"errantdns.io/internal/logging"
+)
+
+// Group is the policy applied to the queries of one set of clients
+type Group struct {
+	Name string
+
+	// Blocker picks the names blocked for the group's clients; nil blocks
+	// nothing
+	Blocker *blocklist.View
+
+	// Forwarder, when set, recurses for the group's clients in place of
+	// the server's forwarder
+	Forwarder *forwarder.Forwarder
+
+	// NAT, when set, rewrites the addresses in the group's answers
+	NAT *NAT
+
+	// LogLevel, when set, replaces the component levels for the group's
+	// queries
+	LogLevel logging.LogLevel
+}
+
+// member maps a network to its group
+type member struct {
+	prefix netip.Prefix
+	group  *Group
+}
+
+// Groups maps client networks to policy groups. The longest matching
+// prefix wins, so a host route can carve one client out of its subnet
+type Groups struct {
+	members []member
+}
+
+// NewGroups creates an empty group map
+func NewGroups() *Groups {
+	return &Groups{}
+}
+
+// Add maps cidrs to group. Bare addresses are treated as single hosts
+func (g *Groups) Add(group *Group, cidrs []string) error {
+	for _, cidr := range cidrs {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("policy group %s: %w", group.Name, err)
+		}
+		for _, existing := range g.members {
+			if existing.prefix == prefix {
+				return fmt.Errorf("policy group %s: %s already belongs to group %s", group.Name, prefix, existing.group.Name)
+			}
+		}
+		g.members = append(g.members, member{prefix: prefix, group: group})
+	}
+
+	sort.SliceStable(g.members, func(i, j int) bool {
+		return g.members[i].prefix.Bits() > g.members[j].prefix.Bits()
+	})
+	return nil
+}
+
+// Len returns the number of mapped networks
+func (g *Groups) Len() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.members)
+}
+
+// Match returns the group of the most specific network containing ip, or
+// nil if there's none
+func (g *Groups) Match(ip net.IP) *Group {
+	if g == nil || len(g.members) == 0 {
+		return nil
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil
+	}
+	addr = addr.Unmap()
+	for _, m := range g.members {
+		if m.prefix.Contains(addr) {
+			return m.group
+		}
+	}
+	return nil
+}
+
+// parsePrefix parses a CIDR or bare address into a masked prefix
+func parsePrefix(cidr string) (netip.Prefix, error) {
+	cidr = strings.TrimSpace(cidr)
+	if addr, err := netip.ParseAddr(cidr); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", cidr)
+	}
+	return prefix.Masked(), nil
+}
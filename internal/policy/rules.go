@@ -0,0 +1,107 @@
+// internal/policy/rules.go
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"errantdns.io/internal/logging"
+)
+
+// Spec is a group's configuration as parsed from its rules
+type Spec struct {
+	Name      string
+	CIDRs     []string
+	Blocking  bool     // false exempts the group from blocking
+	Feeds     []string // blocklist feeds to block; empty blocks every feed
+	Response  string   // blocked-name response; empty uses the blocklist's
+	Upstreams []string // forwarder upstreams; empty uses the server's forwarder
+	NAT       []string // external>internal answer address mappings; see NewNAT
+	LogLevel  logging.LogLevel
+}
+
+// ParseRules parses group rules of the form "group:setting=value" into one
+// spec per group, in the order groups first appear. Settings are:
+//
+//	cidr=10.0.50.0/24        a client network; repeat for more
+//	blocking=on|off          whether the group's queries are blocked
+//	feeds=url|url            block only these blocklist feeds
+//	response=null            blocked-name response; sinkhole addresses are separated by |
+//	upstreams=addr|addr      forward through these upstreams
+//	nat=external>internal    rewrite answer addresses; repeat for more
+//	log=DEBUG                log level for the group's queries
+//
+// e.g. "guest:cidr=192.168.50.0/24", "servers:blocking=off"
+func ParseRules(rules []string) ([]*Spec, error) {
+	var specs []*Spec
+	byName := make(map[string]*Spec)
+
+	for _, rule := range rules {
+		name, setting, found := strings.Cut(rule, ":")
+		name = strings.TrimSpace(name)
+		key, value, hasValue := strings.Cut(setting, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !found || !hasValue || name == "" || value == "" {
+			return nil, fmt.Errorf("invalid policy rule %q: expected group:setting=value", rule)
+		}
+
+		spec, ok := byName[name]
+		if !ok {
+			spec = &Spec{Name: name, Blocking: true}
+			byName[name] = spec
+			specs = append(specs, spec)
+		}
+
+		switch key {
+		case "cidr":
+			spec.CIDRs = append(spec.CIDRs, value)
+		case "blocking":
+			switch strings.ToLower(value) {
+			case "on":
+				spec.Blocking = true
+			case "off":
+				spec.Blocking = false
+			default:
+				return nil, fmt.Errorf("invalid policy rule %q: blocking must be on or off", rule)
+			}
+		case "feeds":
+			spec.Feeds = splitValues(value)
+		case "response":
+			spec.Response = strings.Join(splitValues(value), ",")
+		case "upstreams":
+			spec.Upstreams = splitValues(value)
+		case "nat":
+			if _, err := parseNATRule(value); err != nil {
+				return nil, err
+			}
+			spec.NAT = append(spec.NAT, value)
+		case "log":
+			level, err := logging.ParseLevel(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid policy rule %q: %w", rule, err)
+			}
+			spec.LogLevel = level
+		default:
+			return nil, fmt.Errorf("invalid policy rule %q: unknown setting %q", rule, key)
+		}
+	}
+
+	for _, spec := range specs {
+		if len(spec.CIDRs) == 0 {
+			return nil, fmt.Errorf("policy group %s has no cidr", spec.Name)
+		}
+	}
+	return specs, nil
+}
+
+// splitValues splits a "|"-separated rule value, dropping empty items
+func splitValues(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,55 @@
+// internal/privdrop/privdrop.go
+package privdrop
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// Target identifies the unprivileged user and group to switch to
+type Target struct {
+	Username string
+	UID      int
+	GID      int
+}
+
+// Resolve looks up username (and optionally group, defaulting to the
+// user's primary group). Numeric IDs are accepted for both
+func Resolve(username, group string) (*Target, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		if _, numErr := strconv.Atoi(username); numErr != nil {
+			return nil, fmt.Errorf("unknown user %q: %w", username, err)
+		}
+		if u, err = user.LookupId(username); err != nil {
+			return nil, fmt.Errorf("unknown user id %q: %w", username, err)
+		}
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("user %q has non-numeric uid %q", username, u.Uid)
+	}
+
+	gidString := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if _, numErr := strconv.Atoi(group); numErr != nil {
+				return nil, fmt.Errorf("unknown group %q: %w", group, err)
+			}
+			if g, err = user.LookupGroupId(group); err != nil {
+				return nil, fmt.Errorf("unknown group id %q: %w", group, err)
+			}
+		}
+		gidString = g.Gid
+	}
+
+	gid, err := strconv.Atoi(gidString)
+	if err != nil {
+		return nil, fmt.Errorf("group has non-numeric gid %q", gidString)
+	}
+
+	return &Target{Username: u.Username, UID: uid, GID: gid}, nil
+}
@@ -0,0 +1,62 @@
+// internal/privdrop/privdrop_linux.go
+package privdrop
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Drop switches the whole process to target's uid and gid. Supplementary
+// groups are cleared first, and since keep-caps is never set the kernel
+// clears all capabilities as part of leaving uid 0
+func Drop(target *Target) error {
+	if os.Geteuid() != 0 {
+		if os.Geteuid() == target.UID {
+			return nil
+		}
+		return fmt.Errorf("cannot switch to user %s: not running as root", target.Username)
+	}
+
+	if err := syscall.Setgroups([]int{target.GID}); err != nil {
+		return fmt.Errorf("setgroups failed: %w", err)
+	}
+
+	if err := syscall.Setgid(target.GID); err != nil {
+		return fmt.Errorf("setgid(%d) failed: %w", target.GID, err)
+	}
+
+	if err := syscall.Setuid(target.UID); err != nil {
+		return fmt.Errorf("setuid(%d) failed: %w", target.UID, err)
+	}
+
+	// Make sure there is no way back
+	if err := syscall.Setuid(0); err == nil {
+		return fmt.Errorf("privileges were not dropped: setuid(0) still succeeds")
+	}
+
+	return nil
+}
+
+// EffectiveCapabilities returns the process's effective capability mask as
+// reported by the kernel, e.g. "0000000000000400" for CAP_NET_BIND_SERVICE
+func EffectiveCapabilities() (string, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("CapEff not found in /proc/self/status")
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+// internal/privdrop/privdrop_other.go
+package privdrop
+
+import (
+	"errors"
+	"os"
+)
+
+// Drop is only supported on Linux; elsewhere it succeeds only when already
+// running as the target user
+func Drop(target *Target) error {
+	if os.Geteuid() == target.UID {
+		return nil
+	}
+	return errors.New("privilege drop is only supported on Linux")
+}
+
+// EffectiveCapabilities is only available on Linux
+func EffectiveCapabilities() (string, error) {
+	return "", errors.New("capabilities are only available on Linux")
+}
@@ -0,0 +1,183 @@
+// internal/ratelimit/limiter.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Action determines what happens to queries over the limit
+type Action string
+
+const (
+	// ActionDrop silently discards the query
+	ActionDrop Action = "drop"
+
+	// ActionTruncate answers UDP queries with an empty truncated response so
+	// legitimate clients retry over TCP; TCP queries are dropped
+	ActionTruncate Action = "truncate"
+)
+
+// Config holds configuration for the per-source rate limiter
+type Config struct {
+	QPS         float64  // sustained queries per second per source
+	Burst       int      // bucket size
+	IPv4Prefix  int      // IPv4 addresses are grouped by this prefix length
+	IPv6Prefix  int      // IPv6 addresses are grouped by this prefix length
+	ExemptCIDRs []string // sources never limited
+	Action      Action
+}
+
+// DefaultConfig returns rate limiter config with sensible defaults
+func DefaultConfig() *Config {
+	return &Config{
+		QPS:        100,
+		Burst:      200,
+		IPv4Prefix: 32,
+		IPv6Prefix: 56,
+		Action:     ActionDrop,
+	}
+}
+
+// bucket is a token bucket for one source
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter applies token-bucket rate limits keyed by client IP or subnet
+type Limiter struct {
+	qps        float64
+	burst      float64
+	ipv4Mask   net.IPMask
+	ipv6Mask   net.IPMask
+	exempt     []*net.IPNet
+	action     Action
+	idleExpiry time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewLimiter creates a rate limiter, returning an error for invalid exempt CIDRs
+func NewLimiter(config *Config) (*Limiter, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	limiter := &Limiter{
+		qps:      config.QPS,
+		burst:    float64(config.Burst),
+		ipv4Mask: net.CIDRMask(config.IPv4Prefix, 32),
+		ipv6Mask: net.CIDRMask(config.IPv6Prefix, 128),
+		action:   config.Action,
+		buckets:  make(map[string]*bucket),
+	}
+
+	if limiter.ipv4Mask == nil || limiter.ipv6Mask == nil {
+		return nil, fmt.Errorf("invalid prefix lengths: /%d (IPv4), /%d (IPv6)", config.IPv4Prefix, config.IPv6Prefix)
+	}
+
+	for _, cidr := range config.ExemptCIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exempt CIDR %q: %w", cidr, err)
+		}
+		limiter.exempt = append(limiter.exempt, network)
+	}
+
+	// A bucket idle long enough to have refilled completely carries no state
+	limiter.idleExpiry = time.Duration(limiter.burst / limiter.qps * float64(time.Second))
+	if limiter.idleExpiry < time.Minute {
+		limiter.idleExpiry = time.Minute
+	}
+
+	return limiter, nil
+}
+
+// Action returns the configured action for queries over the limit
+func (l *Limiter) Action() Action {
+	return l.action
+}
+
+// Allow reports whether a query from ip is within its source's rate limit
+func (l *Limiter) Allow(ip net.IP) bool {
+	if ip == nil {
+		return true
+	}
+
+	for _, network := range l.exempt {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	key := l.sourceKey(ip)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * l.qps
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Sources returns the number of sources currently tracked
+func (l *Limiter) Sources() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Run periodically discards idle buckets until ctx is done
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.idleExpiry)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.cleanup(time.Now())
+		}
+	}
+}
+
+// cleanup removes buckets that have been idle longer than idleExpiry
+func (l *Limiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > l.idleExpiry {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// sourceKey groups an address into its rate-limited subnet
+func (l *Limiter) sourceKey(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(l.ipv4Mask).String()
+	}
+	return ip.Mask(l.ipv6Mask).String()
+}
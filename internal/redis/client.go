@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -326,6 +327,26 @@ func SetJSONOn(clientName, key string, value interface{}) error {
 	return client.Set(ctx, key, data, 0).Err()
 }
 
+// SetJSONEX stores a struct as JSON with an expiration time in seconds,
+// in a single SET so the key can never be left without a TTL
+func SetJSONEX(key string, value interface{}, seconds int) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return Client.Set(ctx, key, data, time.Duration(seconds)*time.Second).Err()
+}
+
+// SetJSONEXOn stores a struct as JSON with an expiration time on a specific client
+func SetJSONEXOn(clientName, key string, value interface{}, seconds int) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	client := GetClient(clientName)
+	return client.Set(ctx, key, data, time.Duration(seconds)*time.Second).Err()
+}
+
 // GetJSON retrieves a JSON value and unmarshals it
 func GetJSON(key string, dest interface{}) error {
 	data, err := Client.Get(ctx, key).Bytes()
@@ -345,6 +366,60 @@ func GetJSONFrom(clientName, key string, dest interface{}) error {
 	return json.Unmarshal(data, dest)
 }
 
+// GetJSONFromContext retrieves a JSON value from a specific client,
+// giving up when c is done
+func GetJSONFromContext(c context.Context, clientName, key string, dest interface{}) error {
+	client := GetClient(clientName)
+	data, err := client.Get(c, key).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
+
+// GetFromContext retrieves a key's value from a specific client, giving up
+// when c is done
+func GetFromContext(c context.Context, clientName, key string) ([]byte, error) {
+	client := GetClient(clientName)
+	return client.Get(c, key).Bytes()
+}
+
+// SetEXOnContext sets a key's value with an expiration time on a specific
+// client, giving up when c is done
+func SetEXOnContext(c context.Context, clientName, key string, value interface{}, seconds int) error {
+	client := GetClient(clientName)
+	return client.Set(c, key, value, time.Duration(seconds)*time.Second).Err()
+}
+
+// SetJSONOnContext stores a struct as JSON on a specific client, giving up
+// when c is done
+func SetJSONOnContext(c context.Context, clientName, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	client := GetClient(clientName)
+	return client.Set(c, key, data, 0).Err()
+}
+
+// SetJSONEXOnContext stores a struct as JSON with an expiration time on a
+// specific client, giving up when c is done
+func SetJSONEXOnContext(c context.Context, clientName, key string, value interface{}, seconds int) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	client := GetClient(clientName)
+	return client.Set(c, key, data, time.Duration(seconds)*time.Second).Err()
+}
+
+// ExpireOnContext sets a key's expiration time on a specific client,
+// giving up when c is done
+func ExpireOnContext(c context.Context, clientName, key string, seconds int) error {
+	client := GetClient(clientName)
+	return client.Expire(c, key, time.Duration(seconds)*time.Second).Err()
+}
+
 // WithContext executes a function with a specific context
 func WithContext(c context.Context, fn func(ctx context.Context) error) error {
 	return fn(c)
@@ -377,3 +452,48 @@ func TxPipelineFrom(clientName string) redis.Pipeliner {
 	client := GetClient(clientName)
 	return client.TxPipeline()
 }
+
+// PublishOn posts a message to a channel on a specific client
+func PublishOn(clientName, channel string, message interface{}) error {
+	client := GetClient(clientName)
+	return client.Publish(ctx, channel, message).Err()
+}
+
+// SubscribeOn subscribes to channels on a specific client. The caller
+// closes the subscription
+func SubscribeOn(c context.Context, clientName string, channels ...string) *redis.PubSub {
+	client := GetClient(clientName)
+	return client.Subscribe(c, channels...)
+}
+
+// KeyEntry is a key's value and remaining time to live
+type KeyEntry struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration // negative for keys without an expiration
+}
+
+// GetWithTTLFrom reads the values and remaining TTLs of keys in one
+// pipeline on a specific client. Keys that no longer exist are left out
+func GetWithTTLFrom(c context.Context, clientName string, keys ...string) ([]KeyEntry, error) {
+	pipe := GetClient(clientName).Pipeline()
+	values := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		values[i] = pipe.Get(c, key)
+		ttls[i] = pipe.PTTL(c, key)
+	}
+	if _, err := pipe.Exec(c); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	entries := make([]KeyEntry, 0, len(keys))
+	for i, key := range keys {
+		value, err := values[i].Bytes()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, KeyEntry{Key: key, Value: value, TTL: ttls[i].Val()})
+	}
+	return entries, nil
+}
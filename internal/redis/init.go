@@ -6,7 +6,6 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
-	"time"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -40,32 +39,17 @@ func Initialize(r string) *redis.Client {
 	return NewClient("default", r, true)
 }
 
-// NewClient creates a new Redis client with the given name and address
+// NewClient creates a new Redis client with the given name and address,
+// using the default options
 func NewClient(name, address string, useExisting bool) *redis.Client {
-	if address == "" {
-		address = "localhost:6379"
-	}
-
-	// use an existing connection unless otherwise requested
-	if useExisting {
-		clientsMutex.RLock()
-		if client, exists := clients[name]; exists {
-			clientsMutex.RUnlock()
-			return client
-		}
-		clientsMutex.RUnlock()
-	}
-
-	client := redis.NewClient(&redis.Options{
-		Addr:            address,
-		Password:        "",                             // no password by default
-		DB:              0,                              // use default DB
-		PoolSize:        10,                             // connection pool size
-		MinIdleConns:    3,                              // minimum number of idle connections
-		ConnMaxIdleTime: 240 * time.Second,              // how long connections stay idle
-		DialTimeout:     time.Duration(2 * time.Second), // 2 second timeout for making connections
-	})
+	// Default options don't use TLS, so this can't fail
+	client, _ := NewClientWithOptions(name, DefaultClientOptions(address), useExisting)
+	return client
+}
 
+// register stores client under name, making it the default client if it's
+// named "default" or is the first one
+func register(name string, client *redis.Client) {
 	// Store in our clients map
 	clientsMutex.Lock()
 	clients[name] = client
@@ -78,8 +62,6 @@ func NewClient(name, address string, useExisting bool) *redis.Client {
 
 	// Ensure cleanup hook is set
 	ensureCleanupHook()
-
-	return client
 }
 
 // GetClient returns a Redis client by name
@@ -95,6 +77,39 @@ func GetClient(name string) *redis.Client {
 	return Client
 }
 
+// PoolStats is a summary of a named client's connection pool usage
+type PoolStats struct {
+	PoolSize   int    `json:"pool_size"`
+	TotalConns uint32 `json:"total_connections"`
+	IdleConns  uint32 `json:"idle"`
+	StaleConns uint32 `json:"stale"`
+	Hits       uint32 `json:"hits"`     // free connection found in the pool
+	Misses     uint32 `json:"misses"`   // no free connection, a new one was dialed
+	Timeouts   uint32 `json:"timeouts"` // gave up waiting for a free connection
+}
+
+// Stats returns pool usage statistics for every named client
+func Stats() map[string]PoolStats {
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+
+	stats := make(map[string]PoolStats, len(clients))
+	for name, client := range clients {
+		poolStats := client.PoolStats()
+		stats[name] = PoolStats{
+			PoolSize:   client.Options().PoolSize,
+			TotalConns: poolStats.TotalConns,
+			IdleConns:  poolStats.IdleConns,
+			StaleConns: poolStats.StaleConns,
+			Hits:       poolStats.Hits,
+			Misses:     poolStats.Misses,
+			Timeouts:   poolStats.Timeouts,
+		}
+	}
+
+	return stats
+}
+
 // Close closes a specific Redis client by name
 func Close(name string) {
 	clientsMutex.Lock()
@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ClientOptions holds everything needed to connect a client
+type ClientOptions struct {
+	Address         string
+	Username        string // ACL user; empty uses the default user
+	Password        string
+	Database        int
+	PoolSize        int
+	MinIdleConns    int
+	ConnMaxIdleTime time.Duration
+	DialTimeout     time.Duration
+
+	// Sentinel: when MasterName is set the master is found through the
+	// sentinels and Address is ignored
+	MasterName        string
+	SentinelAddresses []string
+	SentinelPassword  string
+
+	TLS *TLSOptions // nil connects without TLS
+}
+
+// TLSOptions holds TLS settings for managed Redis services
+type TLSOptions struct {
+	CAFile             string // PEM bundle to verify the server with; empty uses the system roots
+	CertFile           string // client certificate for mutual TLS
+	KeyFile            string
+	ServerName         string // overrides the name verified against the server certificate
+	InsecureSkipVerify bool
+}
+
+// DefaultClientOptions returns the options NewClient uses
+func DefaultClientOptions(address string) *ClientOptions {
+	if address == "" {
+		address = "localhost:6379"
+	}
+	return &ClientOptions{
+		Address:         address,
+		PoolSize:        10,                // connection pool size
+		MinIdleConns:    3,                 // minimum number of idle connections
+		ConnMaxIdleTime: 240 * time.Second, // how long connections stay idle
+		DialTimeout:     2 * time.Second,   // timeout for making connections
+	}
+}
+
+// NewClientWithOptions creates a new Redis client with the given name from
+// opts, connecting through Sentinel when a master name is set
+func NewClientWithOptions(name string, opts *ClientOptions, useExisting bool) (*redis.Client, error) {
+	// use an existing connection unless otherwise requested
+	if useExisting {
+		clientsMutex.RLock()
+		if client, exists := clients[name]; exists {
+			clientsMutex.RUnlock()
+			return client, nil
+		}
+		clientsMutex.RUnlock()
+	}
+
+	var tlsConfig *tls.Config
+	if opts.TLS != nil {
+		var err error
+		if tlsConfig, err = opts.TLS.config(); err != nil {
+			return nil, err
+		}
+	}
+
+	var client *redis.Client
+	if opts.MasterName != "" {
+		// The failover client follows the master across failovers, so
+		// callers only see errors while a failover is in progress
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:            opts.MasterName,
+			SentinelAddrs:         opts.SentinelAddresses,
+			SentinelPassword:      opts.SentinelPassword,
+			Username:              opts.Username,
+			Password:              opts.Password,
+			DB:                    opts.Database,
+			PoolSize:              opts.PoolSize,
+			MinIdleConns:          opts.MinIdleConns,
+			ConnMaxIdleTime:       opts.ConnMaxIdleTime,
+			DialTimeout:           opts.DialTimeout,
+			TLSConfig:             tlsConfig,
+			ContextTimeoutEnabled: true,
+		})
+	} else {
+		client = redis.NewClient(&redis.Options{
+			Addr:                  opts.Address,
+			Username:              opts.Username,
+			Password:              opts.Password,
+			DB:                    opts.Database,
+			PoolSize:              opts.PoolSize,
+			MinIdleConns:          opts.MinIdleConns,
+			ConnMaxIdleTime:       opts.ConnMaxIdleTime,
+			DialTimeout:           opts.DialTimeout,
+			TLSConfig:             tlsConfig,
+			ContextTimeoutEnabled: true, // honor context deadlines on reads and writes
+		})
+	}
+
+	register(name, client)
+	return client, nil
+}
+
+// config builds a tls.Config, loading the CA bundle and client certificate
+func (o *TLSOptions) config() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", o.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"strings"
 
+	"errantdns.io/internal/logging"
 	"errantdns.io/internal/models"
 	"errantdns.io/internal/storage"
 )
@@ -232,6 +233,8 @@ func (r *Resolver) resolveSOA(ctx context.Context, query *models.LookupQuery) (*
 		}
 
 		if record != nil {
+			logging.DebugContext(ctx, "resolver", "SOA found in enclosing zone", "domain", query.Name, "zone", domain)
+
 			// Found SOA record, but update the name to match original query
 			// This maintains the illusion that the SOA applies to the queried domain
 			resultRecord := *record
@@ -243,6 +246,44 @@ func (r *Resolver) resolveSOA(ctx context.Context, query *models.LookupQuery) (*
 	return nil, nil // No SOA found in hierarchy
 }
 
+// ResolveDNAME finds the DNAME redirecting name, held by its nearest
+// ancestor, and returns it with the CNAME it synthesizes for name (RFC 6672
+// section 3.1). Both are nil if no DNAME covers name. When the substituted
+// name is too long, the DNAME is returned with models.ErrDNAMETooLong
+func (r *Resolver) ResolveDNAME(ctx context.Context, name string) (dname, cname *models.DNSRecord, err error) {
+	// A DNAME redirects the names below its owner, not the owner itself
+	domains := r.generateDomainHierarchy(name)
+	for _, domain := range domains[1:] {
+		dname, err = r.storage.LookupRecord(ctx, &models.LookupQuery{Name: domain, Type: models.RecordTypeDNAME})
+		if err != nil {
+			return nil, nil, err
+		}
+		if dname != nil {
+			break
+		}
+	}
+	if dname == nil {
+		return nil, nil, nil
+	}
+
+	target, err := models.DNAMESubstitute(domains[0], dname.Name, dname.Target)
+	if err != nil {
+		return dname, nil, err
+	}
+	logging.DebugContext(ctx, "resolver", "Name redirected by DNAME", "domain", name, "dname", dname.Name, "target", target)
+
+	// The CNAME lives exactly as long as the DNAME it came from
+	cname = &models.DNSRecord{
+		Name:       domains[0],
+		RecordType: string(models.RecordTypeCNAME),
+		Target:     target,
+		TTL:        dname.TTL,
+		ExpiresAt:  dname.ExpiresAt,
+		NotAfter:   dname.NotAfter,
+	}
+	return dname, cname, nil
+}
+
 // generateDomainHierarchy creates a list of domains from specific to general
 // Example: "www.test.internal" -> ["www.test.internal", "test.internal", "internal"]
 func (r *Resolver) generateDomainHierarchy(domain string) []string {
@@ -0,0 +1,133 @@
+// internal/reverse/reverse.go
+package reverse
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"errantdns.io/internal/models"
+)
+
+// AddressLookup finds forward records pointing at an address
+type AddressLookup interface {
+	LookupByAddress(ctx context.Context, address string) ([]*models.DNSRecord, error)
+}
+
+// Synthesizer answers PTR queries inside configured prefixes from the A and
+// AAAA records that point at the queried address. Answers are derived at
+// query time, so they follow forward record changes without a sync step
+type Synthesizer struct {
+	lookup   AddressLookup
+	prefixes []*net.IPNet
+}
+
+// NewSynthesizer creates a synthesizer for the given CIDR prefixes
+func NewSynthesizer(lookup AddressLookup, prefixes []string) (*Synthesizer, error) {
+	s := &Synthesizer{lookup: lookup}
+	for _, prefix := range prefixes {
+		_, network, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reverse prefix %q: %w", prefix, err)
+		}
+		s.prefixes = append(s.prefixes, network)
+	}
+	return s, nil
+}
+
+// Covers reports whether ip falls inside a configured prefix
+func (s *Synthesizer) Covers(ip net.IP) bool {
+	for _, network := range s.prefixes {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns synthesized PTR records for a reverse name, or nil if the
+// name isn't a reverse name inside a configured prefix or nothing points
+// at its address. Each PTR takes the TTL of the forward record it mirrors
+func (s *Synthesizer) Lookup(ctx context.Context, name string) ([]*models.DNSRecord, error) {
+	ip, ok := ParseName(name)
+	if !ok || !s.Covers(ip) {
+		return nil, nil
+	}
+
+	forward, err := s.lookup.LookupByAddress(ctx, ip.String())
+	if err != nil {
+		return nil, err
+	}
+
+	owner := models.NormalizeDomainName(name)
+	seen := make(map[string]bool, len(forward))
+	var records []*models.DNSRecord
+	for _, record := range forward {
+		target := models.NormalizeDomainName(record.Name)
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		records = append(records, &models.DNSRecord{
+			Name:       owner,
+			RecordType: string(models.RecordTypePTR),
+			Target:     target,
+			TTL:        record.EffectiveTTL(),
+		})
+	}
+	return records, nil
+}
+
+// ParseName extracts the address from an in-addr.arpa or ip6.arpa name.
+// Only complete names (4 octets or 32 nibbles) are accepted
+func ParseName(name string) (net.IP, bool) {
+	name = models.NormalizeDomainName(name)
+
+	if rest, ok := strings.CutSuffix(name, ".in-addr.arpa"); ok {
+		labels := strings.Split(rest, ".")
+		if len(labels) != net.IPv4len {
+			return nil, false
+		}
+
+		ip := make(net.IP, net.IPv4len)
+		for i, label := range labels {
+			octet, err := strconv.ParseUint(label, 10, 8)
+			if err != nil || (len(label) > 1 && label[0] == '0') {
+				return nil, false
+			}
+			ip[net.IPv4len-1-i] = byte(octet)
+		}
+		return ip, true
+	}
+
+	if rest, ok := strings.CutSuffix(name, ".ip6.arpa"); ok {
+		labels := strings.Split(rest, ".")
+		if len(labels) != 2*net.IPv6len {
+			return nil, false
+		}
+
+		ip := make(net.IP, net.IPv6len)
+		for i, label := range labels {
+			if len(label) != 1 {
+				return nil, false
+			}
+			nibble, err := strconv.ParseUint(label, 16, 8)
+			if err != nil {
+				return nil, false
+			}
+			// Labels run from the least significant nibble
+			pos := 2*net.IPv6len - 1 - i
+			if pos%2 == 0 {
+				ip[pos/2] |= byte(nibble) << 4
+			} else {
+				ip[pos/2] |= byte(nibble)
+			}
+		}
+		return ip, true
+	}
+
+	return nil, false
+}
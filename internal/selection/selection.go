@@ -0,0 +1,136 @@
+// internal/selection/selection.go
+package selection
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"errantdns.io/internal/models"
+)
+
+// Strategy selects one record from a group of equal priority records.
+// Implementations must be safe for concurrent use
+type Strategy interface {
+	Select(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord
+}
+
+// StrategyFunc adapts a function to the Strategy interface
+type StrategyFunc func(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord
+
+// Select calls f
+func (f StrategyFunc) Select(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
+	return f(records, query)
+}
+
+// Names of the built-in strategies
+const (
+	RoundRobin = "round_robin"
+	Random     = "random"
+)
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]Strategy{
+		RoundRobin: StrategyFunc(selectRoundRobin),
+		Random:     StrategyFunc(selectRandom),
+	}
+)
+
+// Register makes a strategy available by name, replacing any strategy
+// already registered under it. Call it before configuration is loaded
+func Register(name string, strategy Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[name] = strategy
+}
+
+// Get returns the strategy registered under name
+func Get(name string) (Strategy, error) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	strategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown selection strategy %q", name)
+	}
+	return strategy, nil
+}
+
+// Names returns the names of all registered strategies, sorted
+func Names() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select applies strategy to records, handling the empty and single record
+// cases so strategies only see groups of two or more
+func Select(strategy Strategy, records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if len(records) == 1 {
+		return records[0]
+	}
+
+	return strategy.Select(records, query)
+}
+
+// selectRandom picks a record with a seed derived from the query, so the
+// same query gets the same record for a while
+func selectRandom(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
+	rng := rand.New(rand.NewSource(generateSeed(query)))
+	return records[rng.Intn(len(records))]
+}
+
+// selectRoundRobin rotates through the records over time
+func selectRoundRobin(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
+	return records[roundRobinIndex(query, len(records))]
+}
+
+// generateSeed creates a deterministic seed based on the query
+func generateSeed(query *models.LookupQuery) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(query.Name))
+	h.Write([]byte(query.Type.String()))
+	// Add some time component for variation
+	timeComponent := time.Now().Unix() / 300 // Changes every 5 minutes
+	h.Write([]byte(fmt.Sprintf("%d", timeComponent)))
+	return int64(h.Sum64())
+}
+
+// roundRobinIndex calculates round-robin index based on time and query
+func roundRobinIndex(query *models.LookupQuery, count int) int {
+	if count <= 1 {
+		return 0
+	}
+
+	// Create deterministic hash of query
+	h := md5.New()
+	h.Write([]byte(query.Name))
+	h.Write([]byte(query.Type.String()))
+	queryHash := h.Sum(nil)
+
+	// Convert first 8 bytes to uint64
+	queryValue := binary.BigEndian.Uint64(queryHash[:8])
+
+	// Add time component (changes every 5 seconds for better rotation)
+	timeComponent := uint64(time.Now().Unix() / 5)
+
+	// Combine and mod by count
+	combined := queryValue + timeComponent
+	return int(combined % uint64(count))
+}
@@ -0,0 +1,125 @@
+// internal/stats/histogram.go
+package stats
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of a LatencyHistogram's buckets,
+// spanning memory cache hits to slow upstream resolvers
+var LatencyBuckets = []time.Duration{
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram counts latencies into fixed buckets since it was last
+// reset. Unlike LatencyWindow it never drops samples, and recording is
+// lock-free
+type LatencyHistogram struct {
+	buckets []atomic.Int64 // per bucket, not cumulative; the last is over every bound
+	count   atomic.Int64
+	sum     atomic.Int64 // nanoseconds
+	max     atomic.Int64 // nanoseconds
+}
+
+// Histogram summarizes a latency distribution in milliseconds
+type Histogram struct {
+	Count   int64             `json:"count"`
+	Mean    float64           `json:"mean_ms"`
+	P50     float64           `json:"p50_ms"` // percentiles are bucket upper bounds
+	P90     float64           `json:"p90_ms"`
+	P99     float64           `json:"p99_ms"`
+	Max     float64           `json:"max_ms"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket counts the samples at or below an upper bound; Count
+// covers every sample
+type HistogramBucket struct {
+	LE    float64 `json:"le_ms"`
+	Count int64   `json:"count"`
+}
+
+// NewLatencyHistogram creates an empty histogram over LatencyBuckets
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{buckets: make([]atomic.Int64, len(LatencyBuckets)+1)}
+}
+
+// Record adds a latency sample
+func (h *LatencyHistogram) Record(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+	for {
+		current := h.max.Load()
+		if int64(d) <= current || h.max.CompareAndSwap(current, int64(d)) {
+			break
+		}
+	}
+}
+
+// Reset discards all samples
+func (h *LatencyHistogram) Reset() {
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+	h.count.Store(0)
+	h.sum.Store(0)
+	h.max.Store(0)
+}
+
+// Snapshot returns the distribution recorded so far. Samples recorded
+// while it's taken may be only partly counted
+func (h *LatencyHistogram) Snapshot() Histogram {
+	result := Histogram{Buckets: make([]HistogramBucket, len(LatencyBuckets))}
+
+	counts := make([]int64, len(h.buckets))
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+		result.Count += counts[i]
+	}
+	var cumulative int64
+	for i, bound := range LatencyBuckets {
+		cumulative += counts[i]
+		result.Buckets[i] = HistogramBucket{LE: toMillis(bound), Count: cumulative}
+	}
+	if result.Count == 0 {
+		return result
+	}
+
+	result.Mean = toMillis(time.Duration(h.sum.Load() / max(h.count.Load(), 1)))
+	result.Max = toMillis(time.Duration(h.max.Load()))
+	result.P50 = result.quantile(0.50)
+	result.P90 = result.quantile(0.90)
+	result.P99 = result.quantile(0.99)
+	return result
+}
+
+// quantile returns the upper bound of the bucket holding the nearest-rank
+// sample for p, or the maximum when it's over every bound
+func (h *Histogram) quantile(p float64) float64 {
+	rank := max(int64(float64(h.Count)*p+0.5), 1)
+	for _, bucket := range h.Buckets {
+		if bucket.Count >= rank {
+			return min(bucket.LE, h.Max)
+		}
+	}
+	return h.Max
+}
@@ -0,0 +1,125 @@
+// internal/stats/latency.go
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyWindow keeps the most recent latency samples in a fixed-size ring
+// buffer and reports percentiles over samples newer than the window duration
+type LatencyWindow struct {
+	mu      sync.Mutex
+	samples []latencySample
+	next    int
+	full    bool
+	window  time.Duration
+}
+
+type latencySample struct {
+	at       time.Time
+	duration time.Duration
+}
+
+// Percentiles summarizes a latency distribution in milliseconds
+type Percentiles struct {
+	Samples int     `json:"samples"`
+	Window  string  `json:"window"`
+	P50     float64 `json:"p50_ms"`
+	P90     float64 `json:"p90_ms"`
+	P99     float64 `json:"p99_ms"`
+	Max     float64 `json:"max_ms"`
+	Mean    float64 `json:"mean_ms"`
+}
+
+// NewLatencyWindow creates a window holding up to capacity samples,
+// reporting over samples no older than window
+func NewLatencyWindow(capacity int, window time.Duration) *LatencyWindow {
+	if capacity <= 0 {
+		capacity = 4096
+	}
+	return &LatencyWindow{
+		samples: make([]latencySample, capacity),
+		window:  window,
+	}
+}
+
+// Record adds a latency sample
+func (w *LatencyWindow) Record(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = latencySample{at: time.Now(), duration: d}
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+// Reset discards all samples
+func (w *LatencyWindow) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.next = 0
+	w.full = false
+}
+
+// Percentiles computes percentiles over the samples inside the window
+func (w *LatencyWindow) Percentiles() Percentiles {
+	cutoff := time.Now().Add(-w.window)
+
+	w.mu.Lock()
+	count := w.next
+	if w.full {
+		count = len(w.samples)
+	}
+	durations := make([]time.Duration, 0, count)
+	for i := 0; i < count; i++ {
+		sample := w.samples[i]
+		if w.window > 0 && sample.at.Before(cutoff) {
+			continue
+		}
+		durations = append(durations, sample.duration)
+	}
+	w.mu.Unlock()
+
+	result := Percentiles{Samples: len(durations), Window: w.window.String()}
+	if len(durations) == 0 {
+		return result
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+
+	result.P50 = toMillis(percentile(durations, 0.50))
+	result.P90 = toMillis(percentile(durations, 0.90))
+	result.P99 = toMillis(percentile(durations, 0.99))
+	result.Max = toMillis(durations[len(durations)-1])
+	result.Mean = toMillis(total / time.Duration(len(durations)))
+
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// toMillis converts a duration to fractional milliseconds
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,182 @@
+// internal/stats/querycount.go
+package stats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// Outcome classifies how a query was answered
+type Outcome int
+
+const (
+	OutcomeAnswered Outcome = iota
+	OutcomeNXDomain
+	OutcomeError
+)
+
+// OtherZone is the zone queries for names outside the zones we hold are
+// counted under, so made-up names can't grow the counts without limit
+const OtherZone = "(other)"
+
+// QueryCount holds aggregated query counts for one zone and record type
+type QueryCount struct {
+	Zone       string `json:"zone"`
+	RecordType string `json:"record_type"`
+	Queries    int64  `json:"queries"`
+	Answered   int64  `json:"answered"`
+	NXDomain   int64  `json:"nxdomain"`
+	Errors     int64  `json:"errors"`
+}
+
+type queryKey struct {
+	zone       string
+	recordType string
+}
+
+// QueryCounter aggregates per-zone/per-type query counts between flushes
+type QueryCounter struct {
+	mu          sync.Mutex
+	counts      map[queryKey]*QueryCount
+	periodStart time.Time
+}
+
+// NewQueryCounter creates an empty query counter
+func NewQueryCounter() *QueryCounter {
+	return &QueryCounter{
+		counts:      make(map[queryKey]*QueryCount),
+		periodStart: time.Now(),
+	}
+}
+
+// Record counts a single query outcome
+func (c *QueryCounter) Record(zone, recordType string, outcome Outcome) {
+	key := queryKey{zone: zone, recordType: recordType}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, exists := c.counts[key]
+	if !exists {
+		count = &QueryCount{Zone: zone, RecordType: recordType}
+		c.counts[key] = count
+	}
+
+	count.Queries++
+	switch outcome {
+	case OutcomeAnswered:
+		count.Answered++
+	case OutcomeNXDomain:
+		count.NXDomain++
+	case OutcomeError:
+		count.Errors++
+	}
+}
+
+// Drain returns the counts accumulated since the last drain and starts a new period
+func (c *QueryCounter) Drain() (time.Time, time.Time, []QueryCount) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	start := c.periodStart
+	end := time.Now()
+
+	counts := make([]QueryCount, 0, len(c.counts))
+	for _, count := range c.counts {
+		counts = append(counts, *count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Zone != counts[j].Zone {
+			return counts[i].Zone < counts[j].Zone
+		}
+		return counts[i].RecordType < counts[j].RecordType
+	})
+
+	c.counts = make(map[queryKey]*QueryCount)
+	c.periodStart = end
+
+	return start, end, counts
+}
+
+// Restore merges counts back in after a failed flush so they are retried
+func (c *QueryCounter) Restore(start time.Time, counts []QueryCount) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if start.Before(c.periodStart) {
+		c.periodStart = start
+	}
+
+	for _, restored := range counts {
+		key := queryKey{zone: restored.Zone, recordType: restored.RecordType}
+		count, exists := c.counts[key]
+		if !exists {
+			count = &QueryCount{Zone: restored.Zone, RecordType: restored.RecordType}
+			c.counts[key] = count
+		}
+		count.Queries += restored.Queries
+		count.Answered += restored.Answered
+		count.NXDomain += restored.NXDomain
+		count.Errors += restored.Errors
+	}
+}
+
+// QueryStatsWriter persists a period's query counts
+type QueryStatsWriter interface {
+	WriteQueryStats(ctx context.Context, periodStart, periodEnd time.Time, counts []QueryCount) error
+}
+
+// Flusher periodically drains a QueryCounter into a QueryStatsWriter
+type Flusher struct {
+	counter  *QueryCounter
+	writer   QueryStatsWriter
+	interval time.Duration
+}
+
+// NewFlusher creates a flusher writing every interval
+func NewFlusher(counter *QueryCounter, writer QueryStatsWriter, interval time.Duration) *Flusher {
+	return &Flusher{
+		counter:  counter,
+		writer:   writer,
+		interval: interval,
+	}
+}
+
+// Run flushes on every tick until the context is cancelled, then flushes once more
+func (f *Flusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Final flush so counts since the last tick aren't lost on shutdown
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			f.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			f.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes the current period's counts, restoring them on failure
+func (f *Flusher) Flush(ctx context.Context) {
+	start, end, counts := f.counter.Drain()
+	if len(counts) == 0 {
+		return
+	}
+
+	if err := f.writer.WriteQueryStats(ctx, start, end, counts); err != nil {
+		logging.Error("stats", "Failed to flush query statistics", err, "rows", len(counts))
+		f.counter.Restore(start, counts)
+		return
+	}
+
+	logging.Debug("stats", "Flushed query statistics", "rows", len(counts), "period_start", start, "period_end", end)
+}
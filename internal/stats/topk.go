@@ -0,0 +1,296 @@
+// internal/stats/topk.go
+package stats
+
+import (
+	"hash/maphash"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CountMinSketch estimates per-key frequencies in fixed memory. Estimates
+// never undercount; overcounting is bounded by the sketch width
+type CountMinSketch struct {
+	width  uint64
+	seeds  []maphash.Seed
+	counts [][]uint64
+}
+
+// NewCountMinSketch creates a sketch with depth rows of width counters
+func NewCountMinSketch(width, depth int) *CountMinSketch {
+	if width <= 0 {
+		width = 2048
+	}
+	if depth <= 0 {
+		depth = 4
+	}
+
+	sketch := &CountMinSketch{
+		width:  uint64(width),
+		seeds:  make([]maphash.Seed, depth),
+		counts: make([][]uint64, depth),
+	}
+	for i := range sketch.counts {
+		sketch.seeds[i] = maphash.MakeSeed()
+		sketch.counts[i] = make([]uint64, width)
+	}
+	return sketch
+}
+
+// Add increments key by n and returns the new estimate
+func (s *CountMinSketch) Add(key string, n uint64) uint64 {
+	var estimate uint64
+	for i, row := range s.counts {
+		index := maphash.String(s.seeds[i], key) % s.width
+		row[index] += n
+		if i == 0 || row[index] < estimate {
+			estimate = row[index]
+		}
+	}
+	return estimate
+}
+
+// Estimate returns the estimated count for key
+func (s *CountMinSketch) Estimate(key string) uint64 {
+	var estimate uint64
+	for i, row := range s.counts {
+		value := row[maphash.String(s.seeds[i], key)%s.width]
+		if i == 0 || value < estimate {
+			estimate = value
+		}
+	}
+	return estimate
+}
+
+// Reset zeroes all counters
+func (s *CountMinSketch) Reset() {
+	for _, row := range s.counts {
+		clear(row)
+	}
+}
+
+// TopEntry is a key and its estimated count
+type TopEntry struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// TopK tracks the most frequent keys over a sliding window. The window is
+// split into slots, each with its own sketch and bounded candidate set;
+// expired slots are recycled as time moves on
+type TopK struct {
+	mu        sync.Mutex
+	k         int
+	slotWidth time.Duration
+	slots     []topKSlot
+	current   int
+}
+
+type topKSlot struct {
+	start      time.Time
+	sketch     *CountMinSketch
+	candidates map[string]uint64
+}
+
+// minTopKSlotWidth is the narrowest sub-window a TopK rotates through
+const minTopKSlotWidth = time.Second
+
+// NewTopK creates a tracker for the k most frequent keys over window,
+// using slots sub-windows backed by width x depth sketches. Windows too
+// short for slots of at least a second get fewer slots, down to one
+func NewTopK(k int, window time.Duration, slots, width, depth int) *TopK {
+	if k <= 0 {
+		k = 20
+	}
+	if slots <= 0 {
+		slots = 6
+	}
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	// Fewer, wider slots for short windows: a zero-width slot never rotates
+	if window/time.Duration(slots) < minTopKSlotWidth {
+		slots = max(int(window/minTopKSlotWidth), 1)
+	}
+
+	t := &TopK{
+		k:         k,
+		slotWidth: max(window/time.Duration(slots), minTopKSlotWidth),
+		slots:     make([]topKSlot, slots),
+	}
+	now := time.Now()
+	for i := range t.slots {
+		t.slots[i] = topKSlot{
+			sketch:     NewCountMinSketch(width, depth),
+			candidates: make(map[string]uint64),
+		}
+	}
+	t.slots[0].start = now
+	return t
+}
+
+// Add counts one occurrence of key
+func (t *TopK) Add(key string) {
+	if key == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	slot := t.advance(time.Now())
+	estimate := slot.sketch.Add(key, 1)
+
+	if _, tracked := slot.candidates[key]; tracked || len(slot.candidates) < t.candidateLimit() {
+		slot.candidates[key] = estimate
+		return
+	}
+
+	// Replace the weakest candidate if this key has overtaken it
+	minKey, minCount := "", uint64(0)
+	for candidate, count := range slot.candidates {
+		if minKey == "" || count < minCount {
+			minKey, minCount = candidate, count
+		}
+	}
+	if estimate > minCount {
+		delete(slot.candidates, minKey)
+		slot.candidates[key] = estimate
+	}
+}
+
+// Top returns up to n keys ordered by estimated count over the window
+func (t *TopK) Top(n int) []TopEntry {
+	if n <= 0 || n > t.k {
+		n = t.k
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.advance(time.Now())
+
+	keys := make(map[string]struct{})
+	for i := range t.slots {
+		for key := range t.slots[i].candidates {
+			keys[key] = struct{}{}
+		}
+	}
+
+	entries := make([]TopEntry, 0, len(keys))
+	for key := range keys {
+		var total uint64
+		for i := range t.slots {
+			if !t.slots[i].start.IsZero() {
+				total += t.slots[i].sketch.Estimate(key)
+			}
+		}
+		entries = append(entries, TopEntry{Key: key, Count: total})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// Reset discards all counts
+func (t *TopK) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.slots {
+		t.slots[i].start = time.Time{}
+		t.slots[i].sketch.Reset()
+		clear(t.slots[i].candidates)
+	}
+	t.current = 0
+	t.slots[0].start = time.Now()
+}
+
+// Window returns the total duration covered by the tracker
+func (t *TopK) Window() time.Duration {
+	return t.slotWidth * time.Duration(len(t.slots))
+}
+
+// advance rotates to the slot covering now, clearing any slots that have
+// aged out, and returns it. Callers must hold t.mu
+func (t *TopK) advance(now time.Time) *topKSlot {
+	for steps := 0; now.Sub(t.slots[t.current].start) >= t.slotWidth; steps++ {
+		next := t.slots[t.current].start.Add(t.slotWidth)
+		// After a full lap every slot is stale, so jump straight to now
+		if steps >= len(t.slots) {
+			next = now
+		}
+		t.current = (t.current + 1) % len(t.slots)
+		slot := &t.slots[t.current]
+		slot.start = next
+		slot.sketch.Reset()
+		clear(slot.candidates)
+	}
+	return &t.slots[t.current]
+}
+
+// candidateLimit bounds each slot's candidate set to a multiple of k so
+// keys just below the cut can still climb into the top list
+func (t *TopK) candidateLimit() int {
+	return t.k * 4
+}
+
+// QueryAnalytics tracks the top queried names, NXDOMAIN names, and clients
+type QueryAnalytics struct {
+	names    *TopK
+	nxdomain *TopK
+	clients  *TopK
+}
+
+// TopQueries is a point-in-time view of query analytics
+type TopQueries struct {
+	Window   string     `json:"window"`
+	Names    []TopEntry `json:"names"`
+	NXDomain []TopEntry `json:"nxdomain"`
+	Clients  []TopEntry `json:"clients"`
+}
+
+// NewQueryAnalytics creates trackers for the top k keys over window
+func NewQueryAnalytics(k int, window time.Duration, width, depth int) *QueryAnalytics {
+	const slots = 6
+	return &QueryAnalytics{
+		names:    NewTopK(k, window, slots, width, depth),
+		nxdomain: NewTopK(k, window, slots, width, depth),
+		clients:  NewTopK(k, window, slots, width, depth),
+	}
+}
+
+// Record counts a single query for name from client
+func (a *QueryAnalytics) Record(name, client string, nxdomain bool) {
+	a.names.Add(name)
+	a.clients.Add(client)
+	if nxdomain {
+		a.nxdomain.Add(name)
+	}
+}
+
+// Top returns up to n entries from each tracker
+func (a *QueryAnalytics) Top(n int) TopQueries {
+	return TopQueries{
+		Window:   a.names.Window().String(),
+		Names:    a.names.Top(n),
+		NXDomain: a.nxdomain.Top(n),
+		Clients:  a.clients.Top(n),
+	}
+}
+
+// Reset discards all tracked counts
+func (a *QueryAnalytics) Reset() {
+	a.names.Reset()
+	a.nxdomain.Reset()
+	a.clients.Reset()
+}
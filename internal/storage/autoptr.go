@@ -0,0 +1,339 @@
+// internal/storage/autoptr.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// Labels carried by PTR records maintained for A/AAAA records
+const (
+	LabelAutoPTRSource = "source"
+	LabelAutoPTRRecord = "auto-ptr/record" // ID of the forward record
+	autoPTRSourceName  = "auto-ptr"
+)
+
+// AutoPTRStorage creates, updates, and deletes the matching PTR record in
+// the same transaction as A/AAAA record writes made in the configured
+// zones. Managed PTRs are labeled with the forward record's ID; an existing
+// PTR for the address is never replaced
+type AutoPTRStorage struct {
+	next        Storage
+	pg          *PostgresStorage
+	invalidator Invalidator
+	zones       []string
+}
+
+// NewAutoPTRStorage wraps next. Address records created in zones, and all
+// updates, upserts, and deletes, are written with pg directly so any managed PTR
+// changes with them; other creates are passed to next unchanged
+func NewAutoPTRStorage(next Storage, pg *PostgresStorage, invalidator Invalidator, zones []string) *AutoPTRStorage {
+	normalized := make([]string, len(zones))
+	for i, zone := range zones {
+		normalized[i] = models.NormalizeDomainName(zone)
+	}
+	return &AutoPTRStorage{
+		next:        next,
+		pg:          pg,
+		invalidator: invalidator,
+		zones:       normalized,
+	}
+}
+
+// CreateRecord creates record, adding its PTR if it's an address record in
+// a configured zone
+func (a *AutoPTRStorage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	if err := a.pg.checkWritable(); err != nil {
+		return err
+	}
+	if !a.applies(record) {
+		return a.next.CreateRecord(ctx, record)
+	}
+
+	var ptrName string
+	err := a.pg.pool.Transaction(ctx, a.pg.connectionName, func(tx *sql.Tx) error {
+		if err := insertRecord(ctx, tx, record); err != nil {
+			return err
+		}
+		if err := a.pg.harmonizeTTLs(ctx, tx, []*models.DNSRecord{record}); err != nil {
+			return err
+		}
+		var err error
+		ptrName, err = createPTR(ctx, tx, record)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidate(record.Name, record.RecordType)
+	if ptrName != "" {
+		a.invalidate(ptrName, string(models.RecordTypePTR))
+	}
+	return nil
+}
+
+// CreateRecords creates records in one transaction, adding the PTRs of
+// address records in configured zones to it
+func (a *AutoPTRStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := a.pg.checkWritable(); err != nil {
+		return err
+	}
+	if err := prepareRecords(records); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(records, a.applies) {
+		return a.next.CreateRecords(ctx, records)
+	}
+
+	var ptrNames []string
+	err := a.pg.pool.Transaction(ctx, a.pg.connectionName, func(tx *sql.Tx) error {
+		if err := createRecords(ctx, tx, records); err != nil {
+			return err
+		}
+		if err := a.pg.harmonizeTTLs(ctx, tx, records); err != nil {
+			return err
+		}
+		for _, record := range records {
+			if !a.applies(record) {
+				continue
+			}
+			ptrName, err := createPTR(ctx, tx, record)
+			if err != nil {
+				return err
+			}
+			if ptrName != "" {
+				ptrNames = append(ptrNames, ptrName)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		clearRecordIDs(records)
+		return err
+	}
+
+	for _, key := range recordNameTypes(records) {
+		a.invalidate(key.name, key.recordType)
+	}
+	for _, ptrName := range ptrNames {
+		a.invalidate(ptrName, string(models.RecordTypePTR))
+	}
+	return nil
+}
+
+// UpdateRecord updates record and replaces its managed PTR, removing it if
+// the record no longer qualifies for one
+func (a *AutoPTRStorage) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	if err := a.pg.checkWritable(); err != nil {
+		return err
+	}
+	var removed []string
+	var ptrName string
+	err := a.pg.pool.Transaction(ctx, a.pg.connectionName, func(tx *sql.Tx) error {
+		if err := updateRecord(ctx, tx, record); err != nil {
+			return err
+		}
+		if err := a.pg.harmonizeTTLs(ctx, tx, []*models.DNSRecord{record}); err != nil {
+			return err
+		}
+
+		var err error
+		if removed, err = deletePTRs(ctx, tx, record.ID); err != nil {
+			return err
+		}
+		if a.applies(record) {
+			ptrName, err = createPTR(ctx, tx, record)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidate(record.Name, record.RecordType)
+	for _, name := range append(removed, ptrName) {
+		if name != "" {
+			a.invalidate(name, string(models.RecordTypePTR))
+		}
+	}
+	return nil
+}
+
+// UpsertRecord creates or updates record, replacing its managed PTR as
+// UpdateRecord does
+func (a *AutoPTRStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	if err := a.pg.checkWritable(); err != nil {
+		return false, err
+	}
+	partitioned, err := a.pg.isPartitioned(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var created bool
+	var previous touchedKey
+	var removed []string
+	var ptrName string
+	err = a.pg.pool.Transaction(ctx, a.pg.connectionName, func(tx *sql.Tx) error {
+		var err error
+		if created, previous, err = upsertRecord(ctx, tx, record, partitioned); err != nil {
+			return err
+		}
+		if err := a.pg.harmonizeTTLs(ctx, tx, []*models.DNSRecord{record}); err != nil {
+			return err
+		}
+
+		if removed, err = deletePTRs(ctx, tx, record.ID); err != nil {
+			return err
+		}
+		if a.applies(record) {
+			ptrName, err = createPTR(ctx, tx, record)
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if previous != (touchedKey{record.Name, record.RecordType}) {
+		a.invalidate(previous.name, previous.recordType)
+	}
+	a.invalidate(record.Name, record.RecordType)
+	for _, name := range append(removed, ptrName) {
+		if name != "" {
+			a.invalidate(name, string(models.RecordTypePTR))
+		}
+	}
+	return created, nil
+}
+
+// DeleteRecord deletes a record together with its managed PTR
+func (a *AutoPTRStorage) DeleteRecord(ctx context.Context, id int) error {
+	if err := a.pg.checkWritable(); err != nil {
+		return err
+	}
+	var removed []string
+	var name, recordType string
+	err := a.pg.pool.Transaction(ctx, a.pg.connectionName, func(tx *sql.Tx) error {
+		var err error
+		if removed, err = deletePTRs(ctx, tx, id); err != nil {
+			return err
+		}
+
+		row := tx.QueryRowContext(ctx, `DELETE FROM dns_records WHERE id = $1 RETURNING name, record_type`, id)
+		if err := row.Scan(&name, &recordType); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: ID %d", ErrRecordNotFound, id)
+			}
+			return fmt.Errorf("failed to delete record ID %d: %w", id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidate(name, recordType)
+	for _, ptr := range removed {
+		a.invalidate(ptr, string(models.RecordTypePTR))
+	}
+	return nil
+}
+
+// applies reports whether record is an address record in a configured zone
+func (a *AutoPTRStorage) applies(record *models.DNSRecord) bool {
+	if record.RecordType != string(models.RecordTypeA) && record.RecordType != string(models.RecordTypeAAAA) {
+		return false
+	}
+	if net.ParseIP(record.Target) == nil {
+		return false
+	}
+
+	name := models.NormalizeDomainName(record.Name)
+	for _, zone := range a.zones {
+		if name == zone || strings.HasSuffix(name, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AutoPTRStorage) invalidate(name, recordType string) {
+	if a.invalidator != nil {
+		a.invalidator.Invalidate(name, recordType)
+	}
+}
+
+// createPTR inserts the PTR for a stored address record and returns its
+// name, or "" if a PTR for the address already exists
+func createPTR(ctx context.Context, q dbtx, record *models.DNSRecord) (string, error) {
+	reverse, err := dns.ReverseAddr(record.Target)
+	if err != nil {
+		return "", fmt.Errorf("failed to build PTR name for %s: %w", record.Target, err)
+	}
+	name := strings.TrimSuffix(reverse, ".")
+
+	var exists bool
+	row := q.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM dns_records WHERE LOWER(name) = LOWER($1) AND record_type = 'PTR')`, name)
+	if err := row.Scan(&exists); err != nil {
+		return "", fmt.Errorf("failed to check PTR for %s: %w", record.Target, err)
+	}
+	if exists {
+		logging.Warn("storage", "PTR already exists, not creating one", "name", name, "record", record.Name, "address", record.Target)
+		return "", nil
+	}
+
+	ptr := &models.DNSRecord{
+		Name:       name,
+		RecordType: string(models.RecordTypePTR),
+		Target:     record.Name,
+		TTL:        record.TTL,
+		ExpiresAt:  record.ExpiresAt,
+		NotBefore:  record.NotBefore,
+		NotAfter:   record.NotAfter,
+		Labels: map[string]string{
+			LabelAutoPTRSource: autoPTRSourceName,
+			LabelAutoPTRRecord: strconv.Itoa(record.ID),
+		},
+	}
+	if err := insertRecord(ctx, q, ptr); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// deletePTRs deletes the PTRs managed for a record and returns their names
+func deletePTRs(ctx context.Context, q dbtx, id int) ([]string, error) {
+	rows, err := q.QueryContext(ctx,
+		`DELETE FROM dns_records WHERE record_type = 'PTR' AND labels ->> $1 = $2 RETURNING name`,
+		LabelAutoPTRRecord, strconv.Itoa(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete PTR for record ID %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan PTR name: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
@@ -0,0 +1,343 @@
+// internal/storage/breaker.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"errantdns.io/internal/cache"
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ErrCircuitOpen is returned for lookups made while the circuit breaker is
+// open when no earlier answer is available to serve instead
+var ErrCircuitOpen = errors.New("storage circuit breaker open")
+
+// BreakerConfig holds circuit breaker configuration
+type BreakerConfig struct {
+	FailureThreshold int           // consecutive lookup failures that open the circuit
+	ProbeInterval    time.Duration // how often the backend is probed while open
+	StaleTTL         time.Duration // how long answers are kept for fallback
+	StaleMaxEntries  int
+
+	// ServeStale, when set, decides per query name whether a lookup that
+	// failed with err falls back to its last answer; nil always does
+	ServeStale func(name string, err error) bool
+}
+
+// BreakerStats holds circuit breaker statistics
+type BreakerStats struct {
+	State               string `json:"state"` // "closed" or "open"
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Opened              int64  `json:"opened"`       // times the circuit opened
+	StaleServed         int64  `json:"stale_served"` // lookups answered from the fallback store
+	Rejected            int64  `json:"rejected"`     // lookups failed with nothing to fall back on
+}
+
+// BreakerStorage wraps a storage backend with a circuit breaker. After
+// FailureThreshold consecutive lookup failures it stops sending lookups to
+// the backend and answers them from a store of the last answer seen for
+// each query, however old, until a background probe finds the backend
+// healthy again. A failed lookup is answered from the same store even while
+// the circuit is closed. Writes always go to the backend
+type BreakerStorage struct {
+	next   Storage
+	config *BreakerConfig
+	stale  *cache.MemoryCache
+
+	mu       sync.Mutex
+	open     bool
+	failures int
+
+	opened      atomic.Int64
+	staleServed atomic.Int64
+	rejected    atomic.Int64
+}
+
+// NewBreakerStorage wraps next with a circuit breaker
+func NewBreakerStorage(next Storage, config *BreakerConfig) *BreakerStorage {
+	return &BreakerStorage{
+		next:   next,
+		config: config,
+		stale: cache.NewMemoryCache(&cache.Config{
+			MaxEntries:      config.StaleMaxEntries,
+			CleanupInterval: time.Minute,
+		}),
+	}
+}
+
+// LookupRecord finds a single record, falling back to the last answer
+func (b *BreakerStorage) LookupRecord(ctx context.Context, query *models.LookupQuery) (*models.DNSRecord, error) {
+	key := "record:" + query.CacheKey()
+	records, err := b.lookup(ctx, query, key, func() ([]*models.DNSRecord, error) {
+		record, err := b.next.LookupRecord(ctx, query)
+		if record == nil {
+			return nil, err
+		}
+		return []*models.DNSRecord{record}, err
+	})
+	if len(records) == 0 {
+		return nil, err
+	}
+	return records[0], err
+}
+
+// LookupRecords finds all matching records, falling back to the last answer
+func (b *BreakerStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	return b.lookup(ctx, query, "records:"+query.CacheKey(), func() ([]*models.DNSRecord, error) {
+		return b.next.LookupRecords(ctx, query)
+	})
+}
+
+// LookupRecordGroup finds the lowest priority group, falling back to the
+// last answer
+func (b *BreakerStorage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	return b.lookup(ctx, query, "group:"+query.CacheKey(), func() ([]*models.DNSRecord, error) {
+		return b.next.LookupRecordGroup(ctx, query)
+	})
+}
+
+// lookup runs fn unless the circuit is open, remembering what it returns
+// under key and serving the remembered answer when fn can't be used
+func (b *BreakerStorage) lookup(ctx context.Context, query *models.LookupQuery, key string, fn func() ([]*models.DNSRecord, error)) ([]*models.DNSRecord, error) {
+	if b.isOpen() {
+		return b.fallback(ctx, query, key, ErrCircuitOpen)
+	}
+
+	records, err := fn()
+	if err != nil {
+		// Requests canceled by the client say nothing about the backend
+		if !errors.Is(err, context.Canceled) {
+			b.recordFailure(err)
+		}
+		return b.fallback(ctx, query, key, err)
+	}
+
+	b.recordSuccess()
+	if len(records) > 0 {
+		b.stale.Set(key, records, b.config.StaleTTL)
+	} else {
+		b.stale.Delete(key)
+	}
+	return records, nil
+}
+
+// fallback returns the remembered answer for key, or err if there is none
+// or ServeStale rules it out for the query
+func (b *BreakerStorage) fallback(ctx context.Context, query *models.LookupQuery, key string, err error) ([]*models.DNSRecord, error) {
+	if b.config.ServeStale != nil && !b.config.ServeStale(query.Name, err) {
+		b.rejected.Add(1)
+		return nil, err
+	}
+	if records, found := b.stale.Get(key); found {
+		b.staleServed.Add(1)
+		if trace := lookupTrace(ctx); trace != nil {
+			trace.stale.Store(true)
+		}
+		if logging.Enabled(logging.LevelDebug) {
+			logging.DebugContext(ctx, "storage", "Serving stale answer", "key", key, "reason", err.Error())
+		}
+		return records, nil
+	}
+	b.rejected.Add(1)
+	return nil, err
+}
+
+func (b *BreakerStorage) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
+
+// recordFailure counts a failed lookup, opening the circuit at the threshold
+func (b *BreakerStorage) recordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.open || b.failures < b.config.FailureThreshold {
+		return
+	}
+	b.open = true
+	b.opened.Add(1)
+	logging.Error("storage", "Circuit breaker opened; serving lookups from stale answers", err,
+		"consecutive_failures", b.failures)
+}
+
+func (b *BreakerStorage) recordSuccess() {
+	b.mu.Lock()
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+// Run probes the backend while the circuit is open, closing it once the
+// backend is healthy, until the context is cancelled
+func (b *BreakerStorage) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if b.isOpen() {
+				b.probe(ctx)
+			}
+		}
+	}
+}
+
+// probe checks the backend's health once, closing the circuit on success
+func (b *BreakerStorage) probe(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, b.config.ProbeInterval)
+	defer cancel()
+
+	if err := b.next.Health(probeCtx); err != nil {
+		logging.Debug("storage", "Circuit breaker probe failed", "error", err.Error())
+		return
+	}
+
+	b.mu.Lock()
+	b.open = false
+	b.failures = 0
+	b.mu.Unlock()
+	logging.Info("storage", "Circuit breaker closed; storage backend is healthy again")
+}
+
+// Stats returns circuit breaker statistics
+func (b *BreakerStorage) Stats() BreakerStats {
+	b.mu.Lock()
+	state, failures := "closed", b.failures
+	if b.open {
+		state = "open"
+	}
+	b.mu.Unlock()
+
+	return BreakerStats{
+		State:               state,
+		ConsecutiveFailures: failures,
+		Opened:              b.opened.Load(),
+		StaleServed:         b.staleServed.Load(),
+		Rejected:            b.rejected.Load(),
+	}
+}
+
+// CreateRecord creates a record in the backend
+func (b *BreakerStorage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	if err := b.next.CreateRecord(ctx, record); err != nil {
+		return err
+	}
+	b.Invalidate(record.Name, record.RecordType)
+	return nil
+}
+
+// UpdateRecord updates a record in the backend
+func (b *BreakerStorage) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	if err := b.next.UpdateRecord(ctx, record); err != nil {
+		return err
+	}
+	b.Invalidate(record.Name, record.RecordType)
+	return nil
+}
+
+// UpsertRecord creates or updates a record in the backend
+func (b *BreakerStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	previous := keyHolder(ctx, b.next, record)
+	created, err := b.next.UpsertRecord(ctx, record)
+	if err != nil {
+		return false, err
+	}
+	if previous != nil {
+		b.Invalidate(previous.Name, previous.RecordType)
+	}
+	b.Invalidate(record.Name, record.RecordType)
+	return created, nil
+}
+
+// CreateRecords creates records in the backend in one transaction
+func (b *BreakerStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	if err := b.next.CreateRecords(ctx, records); err != nil {
+		return err
+	}
+	for _, key := range recordNameTypes(records) {
+		b.Invalidate(key.name, key.recordType)
+	}
+	return nil
+}
+
+// GetRecordByID fetches a record from the backend, with no fallback
+func (b *BreakerStorage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	return b.next.GetRecordByID(ctx, id)
+}
+
+// DeleteRecord deletes a record from the backend, reading it first to
+// learn which fallback answers to drop
+func (b *BreakerStorage) DeleteRecord(ctx context.Context, id int) error {
+	record, err := b.next.GetRecordByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := b.next.DeleteRecord(ctx, id); err != nil {
+		return err
+	}
+	b.Invalidate(record.Name, record.RecordType)
+	return nil
+}
+
+// DeleteRecords deletes records from the backend
+func (b *BreakerStorage) DeleteRecords(ctx context.Context, name string, recordType string) error {
+	if err := b.next.DeleteRecords(ctx, name, recordType); err != nil {
+		return err
+	}
+	b.Invalidate(name, recordType)
+	return nil
+}
+
+// ListRecords lists records from the backend, with no fallback
+func (b *BreakerStorage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	return b.next.ListRecords(ctx, filter)
+}
+
+// SearchRecords searches records in the backend, with no fallback
+func (b *BreakerStorage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	return b.next.SearchRecords(ctx, search)
+}
+
+// CountRecords counts records in the backend, with no fallback
+func (b *BreakerStorage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	return b.next.CountRecords(ctx, filter)
+}
+
+// Invalidate drops the fallback answers for a name/type combination
+func (b *BreakerStorage) Invalidate(name, recordType string) {
+	key := models.NewLookupQuery(name, recordType).CacheKey()
+	for _, prefix := range []string{"record:", "records:", "group:"} {
+		b.stale.Delete(prefix + key)
+	}
+}
+
+// Flush drops the fallback answers a flush selects
+func (b *BreakerStorage) Flush(ctx context.Context, f Flush) error {
+	if f.All {
+		b.stale.Clear()
+		return nil
+	}
+	b.stale.DeleteFunc(f.normalize().matches)
+	return nil
+}
+
+// Health reports the backend's health
+func (b *BreakerStorage) Health(ctx context.Context) error {
+	return b.next.Health(ctx)
+}
+
+// Close closes the fallback store and the backend
+func (b *BreakerStorage) Close() error {
+	b.stale.Close()
+	return b.next.Close()
+}
@@ -0,0 +1,350 @@
+// internal/storage/bulk.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"errantdns.io/internal/models"
+)
+
+// bulkInsertRows is how many records one INSERT statement carries, keeping
+// its parameters well under PostgreSQL's limit of 65535
+const bulkInsertRows = 1000
+
+// RecordError identifies the record that caused a bulk create to be
+// rejected
+type RecordError struct {
+	Index int
+	Err   error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// CreateRecords inserts records in a single transaction: either every
+// record is stored or none is. Every record is validated, and checked for
+// duplicates and CNAME conflicts among the others and against storage,
+// before anything is written; the first problem found is returned as a
+// *RecordError. On success each record's ID and timestamps are set
+func (s *PostgresStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := prepareRecords(records); err != nil {
+		return err
+	}
+
+	err := s.pool.Transaction(ctx, s.connectionName, func(tx *sql.Tx) error {
+		if err := createRecords(ctx, tx, records); err != nil {
+			return err
+		}
+		return s.harmonizeTTLs(ctx, tx, records)
+	})
+	if err != nil {
+		clearRecordIDs(records)
+		return err
+	}
+	return nil
+}
+
+// createRecords checks prepared records against storage and inserts them
+// in chunks of bulkInsertRows. Their names are locked first, so concurrent
+// writes can't slip a conflicting record in after the check
+func createRecords(ctx context.Context, tx *sql.Tx, records []*models.DNSRecord) error {
+	if err := lockRecords(ctx, tx, records); err != nil {
+		return err
+	}
+	if err := checkStoredConflicts(ctx, tx, records); err != nil {
+		return err
+	}
+	for start := 0; start < len(records); start += bulkInsertRows {
+		if err := insertRecords(ctx, tx, records[start:min(start+bulkInsertRows, len(records))], start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearRecordIDs forgets the IDs assigned in a transaction that was rolled
+// back; those records don't exist
+func clearRecordIDs(records []*models.DNSRecord) {
+	for _, record := range records {
+		record.ID = 0
+	}
+}
+
+// prepareRecords validates and normalizes records, and checks them for
+// duplicates and CNAME conflicts among themselves, returning the first
+// problem found
+func prepareRecords(records []*models.DNSRecord) error {
+	if problems := checkRecords(records); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// checkRecords validates and normalizes records, and checks them for
+// duplicates and CNAME conflicts among themselves, returning every problem
+// found in record order. A record with a problem isn't compared with the
+// records after it
+func checkRecords(records []*models.DNSRecord) []*RecordError {
+	var problems []*RecordError
+	identities := make(map[string]int, len(records))
+	externalKeys := make(map[string]int)
+	byName := make(map[string][]int)
+	now := time.Now()
+
+records:
+	for i, record := range records {
+		if record == nil {
+			problems = append(problems, &RecordError{Index: i, Err: errors.New("record is empty")})
+			continue
+		}
+		if err := record.Validate(); err != nil {
+			problems = append(problems, &RecordError{Index: i, Err: fmt.Errorf("invalid record: %w", err)})
+			continue
+		}
+		record.Normalize()
+		setDomainComponents(record)
+
+		identity := recordIdentity(record)
+		if j, ok := identities[identity]; ok {
+			problems = append(problems, &RecordError{Index: i, Err: fmt.Errorf("%w (same as record %d)", duplicateError(record), j)})
+			continue
+		}
+
+		if record.ExternalKey != "" {
+			if j, ok := externalKeys[record.ExternalKey]; ok {
+				problems = append(problems, &RecordError{Index: i, Err: fmt.Errorf("%w: external key %q is also used by record %d", ErrDuplicateRecord, record.ExternalKey, j)})
+				continue
+			}
+		}
+
+		if !record.IsExpired(now) {
+			for _, j := range byName[record.Name] {
+				if typesConflict(record.RecordType, records[j].RecordType) && windowsOverlap(record, records[j]) {
+					problems = append(problems, &RecordError{Index: i, Err: cnameConflictError(record, records[j].RecordType)})
+					continue records
+				}
+			}
+			byName[record.Name] = append(byName[record.Name], i)
+		}
+		identities[identity] = i
+		if record.ExternalKey != "" {
+			externalKeys[record.ExternalKey] = i
+		}
+	}
+	return problems
+}
+
+// recordIdentity is the key idx_dns_records_unique holds for a normalized
+// record
+func recordIdentity(record *models.DNSRecord) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%d\x00%s",
+		strings.ToLower(record.Name), record.RecordType, record.Target, record.Priority, record.Port, record.Tag)
+}
+
+// checkStoredConflicts checks records against stored records, returning
+// the first problem found
+func checkStoredConflicts(ctx context.Context, q dbtx, records []*models.DNSRecord) error {
+	problems, err := storedConflicts(ctx, q, records)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// storedConflicts checks normalized records for duplicates, CNAME
+// conflicts, and reused external keys against stored records, with one
+// query for names and one for keys. Nil records are skipped. Problems are
+// returned in record order
+func storedConflicts(ctx context.Context, q dbtx, records []*models.DNSRecord) ([]*RecordError, error) {
+	byName := make(map[string][]int)
+	var names, externalKeys []string
+	keyIndex := make(map[string][]int)
+	for i, record := range records {
+		if record == nil {
+			continue
+		}
+		name := strings.ToLower(record.Name)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], i)
+		if record.ExternalKey != "" {
+			if _, ok := keyIndex[record.ExternalKey]; !ok {
+				externalKeys = append(externalKeys, record.ExternalKey)
+			}
+			keyIndex[record.ExternalKey] = append(keyIndex[record.ExternalKey], i)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, LOWER(name), record_type, target, priority, COALESCE(port, 0), COALESCE(tag, ''),
+			not_before, not_after, expires_at
+		FROM dns_records
+		WHERE LOWER(name) = ANY($1)
+	`, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check stored records: %w", err)
+	}
+	defer rows.Close()
+
+	// A record is reported once, for the first problem found
+	found := make(map[int]*RecordError)
+	now := time.Now()
+	for rows.Next() {
+		var stored models.DNSRecord
+		var notBefore, notAfter, expiresAt sql.NullTime
+		if err := rows.Scan(&stored.ID, &stored.Name, &stored.RecordType, &stored.Target, &stored.Priority,
+			&stored.Port, &stored.Tag, &notBefore, &notAfter, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to check stored records: %w", err)
+		}
+		if notBefore.Valid {
+			stored.NotBefore = &notBefore.Time
+		}
+		if notAfter.Valid {
+			stored.NotAfter = &notAfter.Time
+		}
+		if expiresAt.Valid {
+			stored.ExpiresAt = &expiresAt.Time
+		}
+
+		identity := recordIdentity(&stored)
+		for _, i := range byName[stored.Name] {
+			record := records[i]
+			if found[i] != nil {
+				continue
+			}
+			switch {
+			case recordIdentity(record) == identity:
+				found[i] = &RecordError{Index: i, Err: fmt.Errorf("%w (record ID %d)", duplicateError(record), stored.ID)}
+			case !stored.IsExpired(now) && typesConflict(record.RecordType, stored.RecordType) && windowsOverlap(record, &stored):
+				found[i] = &RecordError{Index: i, Err: cnameConflictError(record, stored.RecordType)}
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check stored records: %w", err)
+	}
+
+	if len(externalKeys) > 0 {
+		rows, err := q.QueryContext(ctx, `SELECT external_key, id FROM dns_records WHERE external_key = ANY($1)`,
+			externalKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check external keys: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var key string
+			var id int
+			if err := rows.Scan(&key, &id); err != nil {
+				return nil, fmt.Errorf("failed to check external keys: %w", err)
+			}
+			for _, i := range keyIndex[key] {
+				if found[i] == nil {
+					found[i] = &RecordError{Index: i, Err: fmt.Errorf("%w: external key %q is held by record ID %d", ErrDuplicateRecord, key, id)}
+				}
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to check external keys: %w", err)
+		}
+	}
+
+	problems := make([]*RecordError, 0, len(found))
+	for _, problem := range found {
+		problems = append(problems, problem)
+	}
+	slices.SortFunc(problems, func(a, b *RecordError) int { return a.Index - b.Index })
+	return problems, nil
+}
+
+// insertRecords stores records, which start at offset in the batch, with
+// one multi-row INSERT, filling in their IDs and timestamps. Rows come back
+// in the order they were listed
+func insertRecords(ctx context.Context, tx *sql.Tx, records []*models.DNSRecord, offset int) error {
+	var args []interface{}
+	values := make([]string, len(records))
+	for i, record := range records {
+		params, err := recordParams(record)
+		if err != nil {
+			return &RecordError{Index: offset + i, Err: err}
+		}
+
+		placeholders := make([]string, len(params))
+		for j := range params {
+			placeholders[j] = fmt.Sprintf("$%d", len(args)+j+1)
+		}
+		values[i] = "(" + strings.Join(placeholders, ", ") + ")"
+		args = append(args, params...)
+	}
+
+	sqlQuery := `INSERT INTO dns_records (` + insertRecordColumns + `) VALUES ` + strings.Join(values, ", ") +
+		` RETURNING id, created_at, updated_at, version`
+
+	rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return bulkInsertError(err)
+	}
+	defer rows.Close()
+
+	for i := 0; rows.Next(); i++ {
+		record := records[i]
+		if err := rows.Scan(&record.ID, &record.CreatedAt, &record.UpdatedAt, &record.Version); err != nil {
+			return fmt.Errorf("failed to create records: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return bulkInsertError(err)
+	}
+	return nil
+}
+
+// bulkInsertError describes a failed multi-row INSERT. A duplicate can't
+// be traced to one record, so PostgreSQL's description of the key is used
+func bulkInsertError(err error) error {
+	var pgErr *pgconn.PgError
+	if isUniqueViolation(err) && errors.As(err, &pgErr) {
+		return fmt.Errorf("%w: %s", ErrDuplicateRecord, pgErr.Detail)
+	}
+	return fmt.Errorf("failed to create records: %w", err)
+}
+
+// recordNameTypes returns the distinct names and types of records, so a
+// bulk change invalidates each cached answer once
+func recordNameTypes(records []*models.DNSRecord) []touchedKey {
+	seen := make(map[touchedKey]bool)
+	var keys []touchedKey
+	for _, record := range records {
+		key := touchedKey{record.Name, record.RecordType}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -3,30 +3,27 @@ package storage
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/binary"
 	"fmt"
-	"hash/fnv"
-	"math/rand"
 	"time"
 
 	"errantdns.io/internal/cache"
 	"errantdns.io/internal/models"
+	"errantdns.io/internal/selection"
 )
 
 // CachedStorage wraps a Storage implementation with caching
 type CachedStorage struct {
-	storage    Storage
-	cache      cache.Cache
-	tieBreaker string
+	storage  Storage
+	cache    cache.Cache
+	strategy selection.Strategy
 }
 
 // NewCachedStorage creates a new cached storage wrapper
-func NewCachedStorage(storage Storage, cache cache.Cache, tieBreaker string) *CachedStorage {
+func NewCachedStorage(storage Storage, cache cache.Cache, strategy selection.Strategy) *CachedStorage {
 	return &CachedStorage{
-		storage:    storage,
-		cache:      cache,
-		tieBreaker: tieBreaker,
+		storage:  storage,
+		cache:    cache,
+		strategy: strategy,
 	}
 }
 
@@ -34,11 +31,14 @@ func NewCachedStorage(storage Storage, cache cache.Cache, tieBreaker string) *Ca
 func (cs *CachedStorage) LookupRecord(ctx context.Context, query *models.LookupQuery) (*models.DNSRecord, error) {
 	cacheKey := query.CacheKey()
 
-	// Check cache first
+	// Check cache first, skipping records that expired or left their
+	// activation window while cached
 	if records, found := cs.cache.Get(cacheKey); found {
+		records = models.LiveRecords(records)
 		// Apply selection to cached record array
 		if len(records) > 0 {
-			return cs.selectFromArray(records, query), nil
+			logLookup(ctx, query, SourceMemory)
+			return selection.Select(cs.strategy, records, query), nil
 		}
 	}
 
@@ -54,23 +54,60 @@ func (cs *CachedStorage) LookupRecord(ctx context.Context, query *models.LookupQ
 	}
 
 	// Cache the entire group using the first record's TTL
+	logLookup(ctx, query, SourceDatabase)
 	ttl := time.Duration(records[0].TTL) * time.Second
 	cs.cache.Set(cacheKey, records, ttl)
 
 	// Apply selection and return
-	return cs.selectFromArray(records, query), nil
+	return selection.Select(cs.strategy, records, query), nil
 }
 
-// LookupRecords queries storage directly (no caching for multiple records)
-// Multiple records are less commonly cached and more complex to manage
+// LookupRecords caches full RRsets for SRV, MX, and NS, which are answered
+// with every record. Other types query storage directly
 func (cs *CachedStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
-	return cs.storage.LookupRecords(ctx, query)
+	if !cachesRRset(query.Type) {
+		return cs.storage.LookupRecords(ctx, query)
+	}
+
+	cacheKey := rrsetCacheKey(query)
+	if records, found := cs.cache.Get(cacheKey); found {
+		if records = models.LiveRecords(records); len(records) > 0 {
+			logLookup(ctx, query, SourceMemory)
+			return records, nil
+		}
+	}
+
+	records, err := cs.storage.LookupRecords(ctx, query)
+	if err != nil || len(records) == 0 {
+		return records, err
+	}
+
+	logLookup(ctx, query, SourceDatabase)
+	cs.cache.Set(cacheKey, records, rrsetTTL(records))
+	return records, nil
 }
 
-// LookupRecordGroup queries storage directly (no caching for record groups)
-// Record groups change based on tie-breaking logic and are complex to cache
+// LookupRecordGroup implements read-through caching for the lowest priority
+// group, sharing cache entries with LookupRecord
 func (cs *CachedStorage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
-	return cs.storage.LookupRecordGroup(ctx, query)
+	cacheKey := query.CacheKey()
+
+	if records, found := cs.cache.Get(cacheKey); found {
+		if records = models.LiveRecords(records); len(records) > 0 {
+			logLookup(ctx, query, SourceMemory)
+			return records, nil
+		}
+	}
+
+	records, err := cs.storage.LookupRecordGroup(ctx, query)
+	if err != nil || len(records) == 0 {
+		return records, err
+	}
+
+	logLookup(ctx, query, SourceDatabase)
+	ttl := time.Duration(records[0].TTL) * time.Second
+	cs.cache.Set(cacheKey, records, ttl)
+	return records, nil
 }
 
 // CreateRecord creates a record and invalidates cache
@@ -99,20 +136,69 @@ func (cs *CachedStorage) UpdateRecord(ctx context.Context, record *models.DNSRec
 	return nil
 }
 
-// DeleteRecord deletes a record and invalidates cache
+// UpsertRecord creates or updates a record and invalidates cache, including
+// under the old name and type of a record moved by its external key
+func (cs *CachedStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	previous := keyHolder(ctx, cs.storage, record)
+
+	created, err := cs.storage.UpsertRecord(ctx, record)
+	if err != nil {
+		return false, err
+	}
+
+	if previous != nil {
+		cs.invalidateRecord(previous)
+	}
+	cs.invalidateRecord(record)
+
+	return created, nil
+}
+
+// CreateRecords creates records in one transaction and invalidates each
+// cached name and type they touch once
+func (cs *CachedStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	if err := cs.storage.CreateRecords(ctx, records); err != nil {
+		return err
+	}
+
+	for _, key := range recordNameTypes(records) {
+		cs.invalidateNameType(key.name, key.recordType)
+	}
+
+	return nil
+}
+
+// keyHolder returns the stored record holding record's external key, or
+// nil if it has none or the lookup fails
+func keyHolder(ctx context.Context, storage Storage, record *models.DNSRecord) *models.DNSRecord {
+	if record.ExternalKey == "" {
+		return nil
+	}
+	records, err := storage.ListRecords(ctx, &models.RecordFilter{ExternalKey: record.ExternalKey, Limit: 1})
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+	return records[0]
+}
+
+// GetRecordByID fetches a record from storage
+func (cs *CachedStorage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	return cs.storage.GetRecordByID(ctx, id)
+}
+
+// DeleteRecord deletes a record and invalidates cache. The record is read
+// first to learn which name and type it was cached under
 func (cs *CachedStorage) DeleteRecord(ctx context.Context, id int) error {
-	// We need to get the record first to know what to invalidate
-	// This is a bit inefficient but necessary for proper cache invalidation
-	// Alternative would be to return the deleted record from storage layer
+	record, err := cs.storage.GetRecordByID(ctx, id)
+	if err != nil {
+		return err
+	}
 
-	// For now, we'll delete from storage and clear entire cache entry
-	// This is a trade-off between complexity and efficiency
 	if err := cs.storage.DeleteRecord(ctx, id); err != nil {
 		return err
 	}
 
-	// Note: We could optimize this by making DeleteRecord return the deleted record
-	// For now, this is a simplified approach that works correctly
+	cs.invalidateRecord(record)
 
 	return nil
 }
@@ -136,6 +222,21 @@ func (cs *CachedStorage) DeleteRecords(ctx context.Context, name string, recordT
 	return nil
 }
 
+// ListRecords lists records from storage; listings aren't cached
+func (cs *CachedStorage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	return cs.storage.ListRecords(ctx, filter)
+}
+
+// SearchRecords searches records in storage
+func (cs *CachedStorage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	return cs.storage.SearchRecords(ctx, search)
+}
+
+// CountRecords counts records in storage
+func (cs *CachedStorage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	return cs.storage.CountRecords(ctx, filter)
+}
+
 // Health checks both storage and cache health
 func (cs *CachedStorage) Health(ctx context.Context) error {
 	// Check storage health
@@ -193,23 +294,41 @@ func (cs *CachedStorage) GetCacheStats() cache.Stats {
 	return cs.cache.Stats()
 }
 
+// ResetCacheStats zeroes the cache statistics counters
+func (cs *CachedStorage) ResetCacheStats() {
+	cs.cache.ResetStats()
+}
+
 // ClearCache clears all cached entries
 func (cs *CachedStorage) ClearCache() {
 	cs.cache.Clear()
 }
 
+// Invalidate drops any cached entries for a name/type combination
+func (cs *CachedStorage) Invalidate(name, recordType string) {
+	cs.invalidateNameType(name, recordType)
+}
+
+// Flush drops the cached entries a flush selects
+func (cs *CachedStorage) Flush(ctx context.Context, f Flush) error {
+	if f.All {
+		cs.cache.Clear()
+		return nil
+	}
+	cs.cache.DeleteFunc(f.normalize().matches)
+	return nil
+}
+
 // invalidateRecord invalidates cache entries for a specific record
 func (cs *CachedStorage) invalidateRecord(record *models.DNSRecord) {
-	query := models.NewLookupQuery(record.Name, record.RecordType)
-	cacheKey := query.CacheKey()
-	cs.cache.Delete(cacheKey)
+	cs.invalidateNameType(record.Name, record.RecordType)
 }
 
 // invalidateNameType invalidates cache entries for a specific name/type combination
 func (cs *CachedStorage) invalidateNameType(name, recordType string) {
 	query := models.NewLookupQuery(name, recordType)
-	cacheKey := query.CacheKey()
-	cs.cache.Delete(cacheKey)
+	cs.cache.Delete(query.CacheKey())
+	cs.cache.Delete(rrsetCacheKey(query))
 }
 
 // invalidateDomain invalidates all cached entries for a domain (all record types)
@@ -238,64 +357,3 @@ func (cs *CachedStorage) invalidateDomain(name string) {
 	// 3. Tagged cache entries
 	// For now, this covers the most common use cases
 }
-
-// selectFromArray applies tie-breaking logic to select one record from an array
-func (cs *CachedStorage) selectFromArray(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
-	if len(records) == 0 {
-		return nil
-	}
-
-	if len(records) == 1 {
-		return records[0]
-	}
-
-	switch cs.tieBreaker {
-	case "random":
-		// Use query-based seed for consistency within same query
-		seed := cs.generateSeed(query)
-		rng := rand.New(rand.NewSource(seed))
-		index := rng.Intn(len(records))
-		return records[index]
-
-	case "round_robin":
-		fallthrough
-	default:
-		// Round-robin based on time and query hash
-		index := cs.roundRobinIndex(query, len(records))
-		return records[index]
-	}
-}
-
-// generateSeed creates a deterministic seed based on the query
-func (cs *CachedStorage) generateSeed(query *models.LookupQuery) int64 {
-	h := fnv.New64a()
-	h.Write([]byte(query.Name))
-	h.Write([]byte(query.Type.String()))
-	// Add some time component for variation
-	timeComponent := time.Now().Unix() / 300 // Changes every 5 minutes
-	h.Write([]byte(fmt.Sprintf("%d", timeComponent)))
-	return int64(h.Sum64())
-}
-
-// roundRobinIndex calculates round-robin index based on time and query
-func (cs *CachedStorage) roundRobinIndex(query *models.LookupQuery, count int) int {
-	if count <= 1 {
-		return 0
-	}
-
-	// Create deterministic hash of query
-	h := md5.New()
-	h.Write([]byte(query.Name))
-	h.Write([]byte(query.Type.String()))
-	queryHash := h.Sum(nil)
-
-	// Convert first 8 bytes to uint64
-	queryValue := binary.BigEndian.Uint64(queryHash[:8])
-
-	// Add time component (changes every 5 seconds for better rotation)
-	timeComponent := uint64(time.Now().Unix() / 5)
-
-	// Combine and mod by count
-	combined := queryValue + timeComponent
-	return int(combined % uint64(count))
-}
@@ -0,0 +1,293 @@
+// internal/storage/cacheonly.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ErrCacheOnly is returned for lookups that miss the caches while the
+// server is answering from them alone
+var ErrCacheOnly = errors.New("serving from cache only")
+
+// Reasons cache-only mode was entered
+const (
+	CacheOnlyManual   = "manual"
+	CacheOnlyFailures = "storage failures"
+)
+
+// CacheOnlyConfig holds cache-only mode configuration
+type CacheOnlyConfig struct {
+	FailureThreshold int           // consecutive lookup failures that enter the mode; 0 never
+	ProbeInterval    time.Duration // how often the backend is probed after entering on failures
+}
+
+// CacheOnlyStatus describes whether lookups reach the backend
+type CacheOnlyStatus struct {
+	Active              bool       `json:"active"`
+	Trigger             string     `json:"trigger,omitempty"` // "manual" or "storage failures"
+	Reason              string     `json:"reason,omitempty"`
+	Since               *time.Time `json:"since,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Entered             int64      `json:"entered"`  // times the mode was entered
+	Rejected            int64      `json:"rejected"` // lookups failed instead of reaching the backend
+}
+
+// CacheOnlyStorage sits between the caches and the backend. While active,
+// lookups fail with ErrCacheOnly instead of reaching the backend, so only
+// cached answers are served. It's switched by an operator, or entered after
+// FailureThreshold consecutive lookup failures and left once a background
+// probe finds the backend healthy again. Management operations and writes
+// always go to the backend
+type CacheOnlyStorage struct {
+	next   Storage
+	config *CacheOnlyConfig
+
+	mu       sync.Mutex
+	trigger  string // empty while inactive
+	reason   string
+	since    time.Time
+	failures int
+
+	entered  atomic.Int64
+	rejected atomic.Int64
+}
+
+// NewCacheOnlyStorage wraps next, inactive
+func NewCacheOnlyStorage(next Storage, config *CacheOnlyConfig) *CacheOnlyStorage {
+	return &CacheOnlyStorage{
+		next:   next,
+		config: config,
+	}
+}
+
+// LookupRecord finds a single record unless serving from cache only
+func (c *CacheOnlyStorage) LookupRecord(ctx context.Context, query *models.LookupQuery) (*models.DNSRecord, error) {
+	if err := c.admit(); err != nil {
+		return nil, err
+	}
+	record, err := c.next.LookupRecord(ctx, query)
+	c.observe(err)
+	return record, err
+}
+
+// LookupRecords finds all matching records unless serving from cache only
+func (c *CacheOnlyStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	if err := c.admit(); err != nil {
+		return nil, err
+	}
+	records, err := c.next.LookupRecords(ctx, query)
+	c.observe(err)
+	return records, err
+}
+
+// LookupRecordGroup finds the lowest priority group unless serving from
+// cache only
+func (c *CacheOnlyStorage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	if err := c.admit(); err != nil {
+		return nil, err
+	}
+	records, err := c.next.LookupRecordGroup(ctx, query)
+	c.observe(err)
+	return records, err
+}
+
+// admit fails with ErrCacheOnly while the mode is active
+func (c *CacheOnlyStorage) admit() error {
+	c.mu.Lock()
+	active := c.trigger != ""
+	c.mu.Unlock()
+
+	if active {
+		c.rejected.Add(1)
+		return ErrCacheOnly
+	}
+	return nil
+}
+
+// observe counts a lookup's outcome, entering the mode at the threshold
+func (c *CacheOnlyStorage) observe(err error) {
+	// Requests canceled by the client say nothing about the backend
+	if err != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.trigger != "" || c.config.FailureThreshold <= 0 || c.failures < c.config.FailureThreshold {
+		return
+	}
+	c.enter(CacheOnlyFailures, err.Error())
+	logging.Error("storage", "Entered cache-only mode; answering from caches alone", err,
+		"consecutive_failures", c.failures)
+}
+
+// enter activates the mode; c.mu must be held
+func (c *CacheOnlyStorage) enter(trigger, reason string) {
+	c.trigger = trigger
+	c.reason = reason
+	c.since = time.Now()
+	c.entered.Add(1)
+}
+
+// SetCacheOnly enters or leaves cache-only mode by hand. Entered by hand,
+// it stays active until it's left by hand
+func (c *CacheOnlyStorage) SetCacheOnly(active bool, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case active && c.trigger == "":
+		c.enter(CacheOnlyManual, reason)
+	case active:
+		// Already active; probes no longer end it
+		c.trigger, c.reason = CacheOnlyManual, reason
+	default:
+		c.trigger, c.reason = "", ""
+		c.failures = 0
+	}
+}
+
+// Active reports whether lookups are answered from the caches alone
+func (c *CacheOnlyStorage) Active() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trigger != ""
+}
+
+// Run probes the backend while the mode is active after failures, leaving
+// it once the backend is healthy, until the context is cancelled
+func (c *CacheOnlyStorage) Run(ctx context.Context) {
+	if c.config.ProbeInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			automatic := c.trigger == CacheOnlyFailures
+			c.mu.Unlock()
+			if automatic {
+				c.probe(ctx)
+			}
+		}
+	}
+}
+
+// probe checks the backend's health once, leaving the mode on success
+func (c *CacheOnlyStorage) probe(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, c.config.ProbeInterval)
+	defer cancel()
+
+	if err := c.next.Health(probeCtx); err != nil {
+		logging.Debug("storage", "Cache-only probe failed", "error", err.Error())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Switched by hand while probing
+	if c.trigger != CacheOnlyFailures {
+		return
+	}
+	c.trigger, c.reason = "", ""
+	c.failures = 0
+	logging.Info("storage", "Left cache-only mode; storage backend is healthy again")
+}
+
+// Status returns whether the mode is active, and why
+func (c *CacheOnlyStorage) Status() CacheOnlyStatus {
+	c.mu.Lock()
+	status := CacheOnlyStatus{
+		Active:              c.trigger != "",
+		Trigger:             c.trigger,
+		Reason:              c.reason,
+		ConsecutiveFailures: c.failures,
+	}
+	if status.Active {
+		since := c.since
+		status.Since = &since
+	}
+	c.mu.Unlock()
+
+	status.Entered = c.entered.Load()
+	status.Rejected = c.rejected.Load()
+	return status
+}
+
+// GetRecordByID fetches a record from the backend
+func (c *CacheOnlyStorage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	return c.next.GetRecordByID(ctx, id)
+}
+
+// CreateRecord creates a record in the backend
+func (c *CacheOnlyStorage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return c.next.CreateRecord(ctx, record)
+}
+
+// UpdateRecord updates a record in the backend
+func (c *CacheOnlyStorage) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return c.next.UpdateRecord(ctx, record)
+}
+
+// UpsertRecord creates or updates a record in the backend
+func (c *CacheOnlyStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	return c.next.UpsertRecord(ctx, record)
+}
+
+// CreateRecords creates records in the backend in one transaction
+func (c *CacheOnlyStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	return c.next.CreateRecords(ctx, records)
+}
+
+// DeleteRecord deletes a record from the backend
+func (c *CacheOnlyStorage) DeleteRecord(ctx context.Context, id int) error {
+	return c.next.DeleteRecord(ctx, id)
+}
+
+// DeleteRecords deletes records from the backend
+func (c *CacheOnlyStorage) DeleteRecords(ctx context.Context, name string, recordType string) error {
+	return c.next.DeleteRecords(ctx, name, recordType)
+}
+
+// ListRecords lists records from the backend
+func (c *CacheOnlyStorage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	return c.next.ListRecords(ctx, filter)
+}
+
+// SearchRecords searches records in the backend
+func (c *CacheOnlyStorage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	return c.next.SearchRecords(ctx, search)
+}
+
+// CountRecords counts records in the backend
+func (c *CacheOnlyStorage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	return c.next.CountRecords(ctx, filter)
+}
+
+// Health checks the backend's health
+func (c *CacheOnlyStorage) Health(ctx context.Context) error {
+	return c.next.Health(ctx)
+}
+
+// Close closes the backend
+func (c *CacheOnlyStorage) Close() error {
+	return c.next.Close()
+}
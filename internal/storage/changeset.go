@@ -0,0 +1,251 @@
+// internal/storage/changeset.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/models"
+)
+
+// ChangeError identifies the change that caused a changeset to be rejected
+type ChangeError struct {
+	Index  int
+	Action models.ChangeAction
+	Err    error
+}
+
+func (e *ChangeError) Error() string {
+	return fmt.Sprintf("change %d (%s): %v", e.Index, e.Action, e.Err)
+}
+
+func (e *ChangeError) Unwrap() error {
+	return e.Err
+}
+
+// ChangesetApplier applies changesets in a single PostgreSQL transaction,
+// then invalidates every touched name/type once
+type ChangesetApplier struct {
+	pg          *PostgresStorage
+	invalidator Invalidator
+}
+
+// NewChangesetApplier creates an applier writing to pg
+func NewChangesetApplier(pg *PostgresStorage, invalidator Invalidator) *ChangesetApplier {
+	return &ChangesetApplier{pg: pg, invalidator: invalidator}
+}
+
+// touchedKey is a name/type whose cached answers a changeset invalidates
+type touchedKey struct {
+	name       string
+	recordType string
+}
+
+// Apply validates every change, then applies them in order. Nothing is
+// stored if any change fails
+func (c *ChangesetApplier) Apply(ctx context.Context, changes []models.Change) (*models.ChangesetResult, error) {
+	if err := c.pg.checkWritable(); err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		if err := changes[i].Validate(); err != nil {
+			return nil, &ChangeError{Index: i, Action: changes[i].Action, Err: err}
+		}
+	}
+
+	partitioned, err := c.pg.isPartitioned(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ChangesetResult{}
+	var touched []touchedKey
+	var records []*models.DNSRecord
+	for _, change := range changes {
+		if change.Action != models.ChangeDelete {
+			records = append(records, change.Record)
+		}
+	}
+
+	err = c.pg.pool.Transaction(ctx, c.pg.connectionName, func(tx *sql.Tx) error {
+		// Every name is locked before any change is checked, so changesets
+		// touching the same names queue rather than deadlock
+		if err := lockRecords(ctx, tx, records); err != nil {
+			return err
+		}
+
+		for i, change := range changes {
+			keys, err := applyChange(ctx, tx, change, partitioned, result)
+			if err != nil {
+				return &ChangeError{Index: i, Action: change.Action, Err: err}
+			}
+			touched = append(touched, keys...)
+		}
+
+		if err := c.pg.harmonizeTTLs(ctx, tx, records); err != nil {
+			return err
+		}
+
+		serials, err := bumpSerials(ctx, tx, touched, changes, c.pg.serials)
+		if err != nil {
+			return err
+		}
+		result.Serials = serials
+		for zone := range serials {
+			touched = append(touched, touchedKey{name: zone, recordType: string(models.RecordTypeSOA)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[touchedKey]bool, len(touched))
+	for _, key := range touched {
+		if seen[key] || c.invalidator == nil {
+			continue
+		}
+		seen[key] = true
+		c.invalidator.Invalidate(key.name, key.recordType)
+	}
+	return result, nil
+}
+
+// applyChange applies one change inside tx, returning the names and types
+// it touched. partitioned is passed on to upsertRecord
+func applyChange(ctx context.Context, tx *sql.Tx, change models.Change, partitioned bool, result *models.ChangesetResult) ([]touchedKey, error) {
+	switch change.Action {
+	case models.ChangeCreate:
+		if err := insertRecord(ctx, tx, change.Record); err != nil {
+			return nil, err
+		}
+		result.Created++
+		return []touchedKey{{change.Record.Name, change.Record.RecordType}}, nil
+
+	case models.ChangeUpsert:
+		// An upsert by external key may have moved the record
+		created, previous, err := upsertRecord(ctx, tx, change.Record, partitioned)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		return []touchedKey{previous, {change.Record.Name, change.Record.RecordType}}, nil
+
+	case models.ChangeUpdate:
+		// The old name/type is invalidated too in case the update moved it
+		var oldName, oldType string
+		row := tx.QueryRowContext(ctx, `SELECT name, record_type FROM dns_records WHERE id = $1 FOR UPDATE`, change.Record.ID)
+		if err := row.Scan(&oldName, &oldType); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("%w: ID %d", ErrRecordNotFound, change.Record.ID)
+			}
+			return nil, fmt.Errorf("failed to load record ID %d: %w", change.Record.ID, err)
+		}
+		if err := updateRecord(ctx, tx, change.Record); err != nil {
+			return nil, err
+		}
+		result.Updated++
+		return []touchedKey{{oldName, oldType}, {change.Record.Name, change.Record.RecordType}}, nil
+
+	case models.ChangeDelete:
+		var name, recordType string
+		row := tx.QueryRowContext(ctx, `DELETE FROM dns_records WHERE id = $1 RETURNING name, record_type`, change.ID)
+		if err := row.Scan(&name, &recordType); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("%w: ID %d", ErrRecordNotFound, change.ID)
+			}
+			return nil, fmt.Errorf("failed to delete record ID %d: %w", change.ID, err)
+		}
+		result.Deleted++
+		return []touchedKey{{name, recordType}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown change action %q", change.Action)
+}
+
+// zoneSOA is the SOA row a zone's serial is kept in
+type zoneSOA struct {
+	id     int
+	serial uint32
+}
+
+// bumpSerials advances the SOA serial of every zone containing a touched
+// name, once per zone, by the zone's strategy in policy. Zones whose SOA
+// the changeset itself wrote keep the serial it set
+func bumpSerials(ctx context.Context, tx *sql.Tx, touched []touchedKey, changes []models.Change, policy *models.SerialPolicy) (map[string]uint32, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, LOWER(name), serial FROM dns_records WHERE record_type = 'SOA' ORDER BY priority, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SOA records: %w", err)
+	}
+
+	soas := make(map[string]zoneSOA)
+	for rows.Next() {
+		var id int
+		var name string
+		var serial sql.NullInt32
+		if err := rows.Scan(&id, &name, &serial); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan SOA record: %w", err)
+		}
+		name = models.NormalizeDomainName(name)
+		if _, ok := soas[name]; !ok {
+			soas[name] = zoneSOA{id: id, serial: uint32(serial.Int32)}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load SOA records: %w", err)
+	}
+
+	explicit := make(map[string]bool)
+	for _, change := range changes {
+		if change.Record != nil && change.Record.RecordType == string(models.RecordTypeSOA) {
+			explicit[models.NormalizeDomainName(change.Record.Name)] = true
+		}
+	}
+
+	zones := make(map[string]bool)
+	for _, key := range touched {
+		if zone := closestApex(models.NormalizeDomainName(key.name), soas); zone != "" && !explicit[zone] {
+			zones[zone] = true
+		}
+	}
+
+	now := time.Now()
+	serials := make(map[string]uint32, len(zones))
+	for zone := range zones {
+		// The column holds the serial's bits as int32
+		serial := policy.Next(zone, soas[zone].serial, now)
+		if _, err := tx.ExecContext(ctx, `UPDATE dns_records SET serial = $1 WHERE id = $2`, int32(serial), soas[zone].id); err != nil {
+			return nil, fmt.Errorf("failed to bump SOA serial for %s: %w", zone, err)
+		}
+		serials[zone] = serial
+	}
+	return serials, nil
+}
+
+// SerialStrategy returns how changesets advance zone's SOA serial
+func (s *PostgresStorage) SerialStrategy(zone string) models.SerialStrategy {
+	return s.serials.Strategy(zone)
+}
+
+// closestApex returns the closest name at or above name in apexes
+func closestApex(name string, apexes map[string]zoneSOA) string {
+	for {
+		if _, ok := apexes[name]; ok {
+			return name
+		}
+		dot := strings.IndexByte(name, '.')
+		if dot < 0 {
+			return ""
+		}
+		name = name[dot+1:]
+	}
+}
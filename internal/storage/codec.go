@@ -0,0 +1,278 @@
+// internal/storage/codec.go
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"errantdns.io/internal/models"
+)
+
+// Redis cache entries start with a magic byte, an encoding version, and a
+// flags byte. Entries written by older versions (including the JSON entries
+// written before the binary encoding) fail to decode and are treated as
+// misses, so they're replaced as they're looked up again. Bump
+// cacheEncodingVersion whenever the record layout below changes
+const (
+	cacheEncodingMagic   byte = 0xED
+	cacheEncodingVersion byte = 1
+
+	cacheFlagCompressed byte = 1 << 0
+)
+
+// errCacheEncoding is returned for cache entries in an unknown encoding
+var errCacheEncoding = errors.New("unsupported cache entry encoding")
+
+// encodeRecords serializes records for the Redis cache, compressing the
+// payload with DEFLATE if compress is set
+func encodeRecords(records []*models.DNSRecord, compress bool) ([]byte, error) {
+	var payload []byte
+	payload = binary.AppendUvarint(payload, uint64(len(records)))
+	for _, record := range records {
+		payload = appendRecord(payload, record)
+	}
+
+	header := []byte{cacheEncodingMagic, cacheEncodingVersion, 0}
+	if !compress {
+		return append(header, payload...), nil
+	}
+
+	header[2] |= cacheFlagCompressed
+	buf := bytes.NewBuffer(header)
+	w, err := flate.NewWriter(buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRecords deserializes records written by encodeRecords
+func decodeRecords(data []byte) ([]*models.DNSRecord, error) {
+	if len(data) < 3 || data[0] != cacheEncodingMagic || data[1] != cacheEncodingVersion {
+		return nil, errCacheEncoding
+	}
+
+	payload := data[3:]
+	if data[2]&cacheFlagCompressed != 0 {
+		var err error
+		if payload, err = io.ReadAll(flate.NewReader(bytes.NewReader(payload))); err != nil {
+			return nil, fmt.Errorf("failed to decompress cache entry: %w", err)
+		}
+	}
+
+	d := &decoder{buf: payload}
+	count := d.uvarint()
+	if d.err == nil && count > uint64(len(payload)) {
+		return nil, fmt.Errorf("corrupt cache entry: %d records in %d bytes", count, len(payload))
+	}
+	records := make([]*models.DNSRecord, 0, count)
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		records = append(records, d.record())
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("corrupt cache entry: %w", d.err)
+	}
+	return records, nil
+}
+
+// appendRecord appends one record's fields in a fixed order
+func appendRecord(b []byte, r *models.DNSRecord) []byte {
+	b = binary.AppendVarint(b, int64(r.ID))
+	b = appendString(b, r.Name)
+	b = appendString(b, r.RecordType)
+	b = appendString(b, r.Target)
+	b = binary.AppendUvarint(b, uint64(r.TTL))
+	b = binary.AppendVarint(b, int64(r.Priority))
+	b = appendTime(b, &r.CreatedAt)
+	b = appendTime(b, &r.UpdatedAt)
+	b = appendString(b, r.ETLD)
+	b = appendString(b, r.ApexDomain)
+	b = binary.AppendUvarint(b, uint64(len(r.SubdomainLabels)))
+	for _, label := range r.SubdomainLabels {
+		b = appendString(b, label)
+	}
+	b = appendBool(b, r.IsWildcard)
+	b = binary.AppendUvarint(b, r.WildcardMask)
+	b = binary.AppendUvarint(b, uint64(r.Serial))
+	b = appendString(b, r.Mbox)
+	b = binary.AppendUvarint(b, uint64(r.Refresh))
+	b = binary.AppendUvarint(b, uint64(r.Retry))
+	b = binary.AppendUvarint(b, uint64(r.Expire))
+	b = binary.AppendUvarint(b, uint64(r.Minttl))
+	b = binary.AppendUvarint(b, uint64(r.Weight))
+	b = binary.AppendUvarint(b, uint64(r.Port))
+	b = appendString(b, r.Tag)
+	b = appendTime(b, r.ExpiresAt)
+	b = appendTime(b, r.NotBefore)
+	b = appendTime(b, r.NotAfter)
+	b = binary.AppendUvarint(b, uint64(len(r.Labels)))
+	for key, value := range r.Labels {
+		b = appendString(b, key)
+		b = appendString(b, value)
+	}
+	b = appendString(b, r.Comment)
+	b = appendString(b, r.Owner)
+	b = binary.AppendVarint(b, int64(r.Version))
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+// appendTime appends a presence flag, then the time as Unix seconds and
+// nanoseconds. Nil and zero times are both written as absent
+func appendTime(b []byte, t *time.Time) []byte {
+	if t == nil || t.IsZero() {
+		return append(b, 0)
+	}
+	b = append(b, 1)
+	b = binary.AppendVarint(b, t.Unix())
+	return binary.AppendUvarint(b, uint64(t.Nanosecond()))
+}
+
+// decoder reads fields written by appendRecord, remembering the first error
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) record() *models.DNSRecord {
+	r := &models.DNSRecord{}
+	r.ID = int(d.varint())
+	r.Name = d.string()
+	r.RecordType = d.string()
+	r.Target = d.string()
+	r.TTL = uint32(d.uvarint())
+	r.Priority = int(d.varint())
+	if t := d.time(); t != nil {
+		r.CreatedAt = *t
+	}
+	if t := d.time(); t != nil {
+		r.UpdatedAt = *t
+	}
+	r.ETLD = d.string()
+	r.ApexDomain = d.string()
+	if n := d.length(); n > 0 {
+		r.SubdomainLabels = make([]string, n)
+		for i := range r.SubdomainLabels {
+			r.SubdomainLabels[i] = d.string()
+		}
+	}
+	r.IsWildcard = d.bool()
+	r.WildcardMask = d.uvarint()
+	r.Serial = uint32(d.uvarint())
+	r.Mbox = d.string()
+	r.Refresh = uint32(d.uvarint())
+	r.Retry = uint32(d.uvarint())
+	r.Expire = uint32(d.uvarint())
+	r.Minttl = uint32(d.uvarint())
+	r.Weight = uint32(d.uvarint())
+	r.Port = uint16(d.uvarint())
+	r.Tag = d.string()
+	r.ExpiresAt = d.time()
+	r.NotBefore = d.time()
+	r.NotAfter = d.time()
+	if n := d.length(); n > 0 {
+		r.Labels = make(map[string]string, n)
+		for i := 0; i < n; i++ {
+			key := d.string()
+			r.Labels[key] = d.string()
+		}
+	}
+	r.Comment = d.string()
+	r.Owner = d.string()
+	r.Version = int(d.varint())
+	return r
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+// length reads a count of following items, which can't exceed the bytes left
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+func (d *decoder) bool() bool {
+	if d.err != nil {
+		return false
+	}
+	if len(d.buf) == 0 {
+		d.err = io.ErrUnexpectedEOF
+		return false
+	}
+	v := d.buf[0] != 0
+	d.buf = d.buf[1:]
+	return v
+}
+
+func (d *decoder) time() *time.Time {
+	if !d.bool() {
+		return nil
+	}
+	sec := d.varint()
+	nsec := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	t := time.Unix(sec, int64(nsec))
+	return &t
+}
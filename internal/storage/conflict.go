@@ -0,0 +1,229 @@
+// internal/storage/conflict.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"errantdns.io/internal/models"
+)
+
+// ErrRecordNotFound is returned when no record has the requested ID
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrVersionConflict is returned when an update's version precondition
+// fails because the record was changed since it was read
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrDuplicateRecord is returned when a write would store a second record
+// with the same name, type, target, priority, port, and tag
+var ErrDuplicateRecord = errors.New("duplicate record")
+
+// ErrCNAMEConflict is returned when a write would leave a CNAME sharing its
+// name with other record types (RFC 1034 section 3.6.2)
+var ErrCNAMEConflict = errors.New("CNAME conflict")
+
+// cnameCompatibleTypes may coexist with a CNAME (RFC 4035 section 2.5)
+var cnameCompatibleTypes = map[string]bool{
+	"RRSIG": true,
+	"NSEC":  true,
+	"NSEC3": true,
+}
+
+// checkCNAMEConflict fails if storing record would put a CNAME alongside
+// other types at its name. Expired records and records whose serving
+// windows don't overlap record's are ignored, so staged cutovers between a
+// CNAME and other types remain possible. q must be the transaction that
+// writes record: record's name stays locked until it ends, so a concurrent
+// write at the name can't pass the check too
+func checkCNAMEConflict(ctx context.Context, q dbtx, record *models.DNSRecord) error {
+	if cnameCompatibleTypes[record.RecordType] {
+		return nil
+	}
+	if err := lockNames(ctx, q, []string{record.Name}); err != nil {
+		return err
+	}
+
+	// A CNAME conflicts with any other type; other types conflict with a CNAME
+	typeCondition := `record_type = 'CNAME'`
+	if record.RecordType == string(models.RecordTypeCNAME) {
+		typeCondition = `record_type NOT IN ('CNAME', 'RRSIG', 'NSEC', 'NSEC3')`
+	}
+
+	sqlQuery := `
+		SELECT record_type
+		FROM dns_records
+		WHERE LOWER(name) = LOWER($1)
+			AND id <> $2
+			AND ` + typeCondition + `
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND ($3::timestamptz IS NULL OR not_before IS NULL OR not_before < $3)
+			AND ($4::timestamptz IS NULL OR not_after IS NULL OR not_after > $4)
+		LIMIT 1
+	`
+
+	var windowEnd, windowStart sql.NullTime
+	if end := recordWindowEnd(record); end != nil {
+		windowEnd = sql.NullTime{Time: *end, Valid: true}
+	}
+	if record.NotBefore != nil {
+		windowStart = sql.NullTime{Time: *record.NotBefore, Valid: true}
+	}
+
+	var existing string
+	err := q.QueryRowContext(ctx, sqlQuery, record.Name, record.ID, windowEnd, windowStart).Scan(&existing)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check CNAME conflicts for %s: %w", record.Name, err)
+	}
+
+	return cnameConflictError(record, existing)
+}
+
+// cnameConflictError explains why record can't be stored alongside a
+// record of existingType at its name
+func cnameConflictError(record *models.DNSRecord, existingType string) error {
+	if record.RecordType == string(models.RecordTypeCNAME) {
+		return fmt.Errorf("%w: %s already has %s records; a CNAME must be the only record at a name", ErrCNAMEConflict, record.Name, existingType)
+	}
+	return fmt.Errorf("%w: %s has a CNAME record; no other record types may be added at that name", ErrCNAMEConflict, record.Name)
+}
+
+// typesConflict reports whether records of types a and b may not share a
+// name: one is a CNAME and the other isn't a type allowed beside it
+func typesConflict(a, b string) bool {
+	if cnameCompatibleTypes[a] || cnameCompatibleTypes[b] {
+		return false
+	}
+	cname := string(models.RecordTypeCNAME)
+	return (a == cname) != (b == cname)
+}
+
+// windowsOverlap reports whether a and b are ever served at the same time
+func windowsOverlap(a, b *models.DNSRecord) bool {
+	aEnd, bEnd := recordWindowEnd(a), recordWindowEnd(b)
+	return (aEnd == nil || b.NotBefore == nil || b.NotBefore.Before(*aEnd)) &&
+		(bEnd == nil || a.NotBefore == nil || bEnd.After(*a.NotBefore))
+}
+
+// checkExternalKey fails if another record already holds record's external
+// key. Keys can't be kept unique by an index on a partitioned table, so
+// writers check here, and upserts by key serialize on lockExternalKey
+func checkExternalKey(ctx context.Context, q dbtx, record *models.DNSRecord) error {
+	if record.ExternalKey == "" {
+		return nil
+	}
+
+	var id int
+	err := q.QueryRowContext(ctx, `SELECT id FROM dns_records WHERE external_key = $1 AND id <> $2 LIMIT 1`,
+		record.ExternalKey, record.ID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check external key %q: %w", record.ExternalKey, err)
+	}
+	return fmt.Errorf("%w: external key %q is held by record ID %d", ErrDuplicateRecord, record.ExternalKey, id)
+}
+
+// lockExternalKey holds a transaction-scoped lock on an external key, so
+// concurrent upserts of one key don't both create a record
+func lockExternalKey(ctx context.Context, q dbtx, key string) error {
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('errantdns.external_key:' || $1))`, key); err != nil {
+		return fmt.Errorf("failed to lock external key %q: %w", key, err)
+	}
+	return nil
+}
+
+// lockNames holds transaction-scoped locks on names, so writes that could
+// conflict at a name are checked one at a time. They're taken in a fixed
+// order, so transactions locking several names can't deadlock
+func lockNames(ctx context.Context, q dbtx, names []string) error {
+	normalized := make([]string, len(names))
+	for i, name := range names {
+		normalized[i] = models.NormalizeDomainName(name)
+	}
+
+	_, err := q.ExecContext(ctx, `
+		SELECT pg_advisory_xact_lock(key)
+		FROM (
+			SELECT DISTINCT hashtext('errantdns.name:' || LOWER(name)) AS key
+			FROM unnest($1::text[]) AS name
+			ORDER BY key
+		) AS keys
+	`, normalized)
+	if err != nil {
+		return fmt.Errorf("failed to lock record names: %w", err)
+	}
+	return nil
+}
+
+// lockRecords takes the locks writing records in one transaction needs up
+// front, in the order single writes take them: external keys, then names
+func lockRecords(ctx context.Context, q dbtx, records []*models.DNSRecord) error {
+	var keys, names []string
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		if record.ExternalKey != "" {
+			keys = append(keys, record.ExternalKey)
+		}
+		names = append(names, record.Name)
+	}
+
+	slices.Sort(keys)
+	for _, key := range slices.Compact(keys) {
+		if err := lockExternalKey(ctx, q, key); err != nil {
+			return err
+		}
+	}
+	return lockNames(ctx, q, names)
+}
+
+// recordWindowEnd returns when record stops being served, or nil if never
+func recordWindowEnd(record *models.DNSRecord) *time.Time {
+	end := record.NotAfter
+	if record.ExpiresAt != nil && (end == nil || record.ExpiresAt.Before(*end)) {
+		end = record.ExpiresAt
+	}
+	return end
+}
+
+// isUniqueViolation reports whether err is a violation of the record
+// uniqueness index
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_dns_records_unique"
+}
+
+// duplicateError describes the record that collided with an existing one
+func duplicateError(record *models.DNSRecord) error {
+	return fmt.Errorf("%w: %s %s %s (priority %d) already exists", ErrDuplicateRecord, record.Name, record.RecordType, record.Target, record.Priority)
+}
+
+// missingOrStale explains an update that matched no row: either the record
+// is gone, or its version moved on since the caller read it
+func missingOrStale(ctx context.Context, q dbtx, record *models.DNSRecord) error {
+	if record.Version == 0 {
+		return fmt.Errorf("%w: ID %d", ErrRecordNotFound, record.ID)
+	}
+
+	var current int
+	err := q.QueryRowContext(ctx, `SELECT version FROM dns_records WHERE id = $1`, record.ID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: ID %d", ErrRecordNotFound, record.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check version of record ID %d: %w", record.ID, err)
+	}
+	return fmt.Errorf("%w: record ID %d is at version %d, not %d", ErrVersionConflict, record.ID, current, record.Version)
+}
@@ -0,0 +1,203 @@
+// internal/storage/flush.go
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/redis"
+)
+
+// Flush selects cached answers to drop: one name (of one type, or of every
+// type), an apex domain and every name below it, or everything
+type Flush struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"` // with Name; empty flushes every type
+	Apex string `json:"apex,omitempty"`
+	All  bool   `json:"all,omitempty"`
+
+	// localOnly skips the shared Redis tier, which the node that took the
+	// request has already flushed
+	localOnly bool
+}
+
+// Validate checks that the flush selects exactly one scope
+func (f Flush) Validate() error {
+	scopes := 0
+	for _, set := range []bool{f.Name != "", f.Apex != "", f.All} {
+		if set {
+			scopes++
+		}
+	}
+	if scopes != 1 {
+		return errors.New("exactly one of name, apex, or all is required")
+	}
+	if f.Type != "" && f.Name == "" {
+		return errors.New("type requires name")
+	}
+	return nil
+}
+
+// String describes the flush for logs
+func (f Flush) String() string {
+	switch {
+	case f.All:
+		return "all"
+	case f.Apex != "":
+		return "apex " + f.Apex
+	case f.Type != "":
+		return f.Name + " " + f.Type
+	default:
+		return f.Name
+	}
+}
+
+// normalize lowercases names and types the way cache keys store them
+func (f Flush) normalize() Flush {
+	f.Name = models.NormalizeDomainName(f.Name)
+	f.Apex = models.NormalizeDomainName(f.Apex)
+	f.Type = strings.ToUpper(f.Type)
+	return f
+}
+
+// matches reports whether a cache key, which ends in "name:TYPE" after any
+// prefixes, is selected by a normalized flush
+func (f Flush) matches(key string) bool {
+	if f.All {
+		return true
+	}
+
+	rest, recordType, found := cutLast(key, ":")
+	if !found {
+		return false
+	}
+	_, name, _ := cutLast(rest, ":")
+
+	if f.Apex != "" {
+		return inZone(name, f.Apex)
+	}
+	return name == f.Name && (f.Type == "" || recordType == f.Type)
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return "", s, false
+}
+
+// inZone reports whether name is apex or below it
+func inZone(name, apex string) bool {
+	return name == apex || strings.HasSuffix(name, "."+apex)
+}
+
+// Flusher drops the cached answers a flush selects
+type Flusher interface {
+	Flush(ctx context.Context, f Flush) error
+}
+
+// FlusherFunc adapts a function to the Flusher interface
+type FlusherFunc func(ctx context.Context, f Flush) error
+
+// Flush calls fn(ctx, f)
+func (fn FlusherFunc) Flush(ctx context.Context, f Flush) error {
+	return fn(ctx, f)
+}
+
+// FlushBus broadcasts flushes over Redis pub/sub so every node sharing the
+// Redis server drops the answers from its own memory tiers, not just the
+// node that took the request
+type FlushBus struct {
+	local   Flusher
+	client  string
+	channel string
+	node    string // tags our own broadcasts, which we've already applied
+}
+
+// flushMessage is a flush as broadcast on the channel
+type flushMessage struct {
+	Node string `json:"node"`
+	Flush
+}
+
+// NewFlushBus creates a bus applying flushes to local and broadcasting
+// them on channel
+func NewFlushBus(local Flusher, clientName, channel string) *FlushBus {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return &FlushBus{
+		local:   local,
+		client:  clientName,
+		channel: channel,
+		node:    hex.EncodeToString(id),
+	}
+}
+
+// Flush drops the answers on this node and in Redis, then tells the other
+// nodes to drop them too
+func (b *FlushBus) Flush(ctx context.Context, f Flush) error {
+	if err := b.local.Flush(ctx, f); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(flushMessage{Node: b.node, Flush: f})
+	if err != nil {
+		return err
+	}
+	if err := redis.PublishOn(b.client, b.channel, data); err != nil {
+		return fmt.Errorf("flushed this node, but failed to notify other nodes: %w", err)
+	}
+	return nil
+}
+
+// Run applies flushes broadcast by other nodes until the context is
+// cancelled
+func (b *FlushBus) Run(ctx context.Context) {
+	sub := redis.SubscribeOn(ctx, b.client, b.channel)
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			b.apply(ctx, msg.Payload)
+		}
+	}
+}
+
+// apply flushes this node's memory tiers for a broadcast flush
+func (b *FlushBus) apply(ctx context.Context, payload string) {
+	var msg flushMessage
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		logging.Warn("storage", "Ignoring undecodable cache flush", "error", err.Error())
+		return
+	}
+	if msg.Node == b.node {
+		return
+	}
+	if err := msg.Flush.Validate(); err != nil {
+		logging.Warn("storage", "Ignoring invalid cache flush", "node", msg.Node, "error", err.Error())
+		return
+	}
+
+	msg.Flush.localOnly = true
+	if err := b.local.Flush(ctx, msg.Flush); err != nil {
+		logging.Error("storage", "Failed to apply cache flush", err, "node", msg.Node, "flush", msg.Flush.String())
+		return
+	}
+	logging.Info("storage", "Applied cache flush from another node", "node", msg.Node, "flush", msg.Flush.String())
+}
@@ -0,0 +1,185 @@
+// internal/storage/inspect.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/cache"
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/redis"
+)
+
+// CacheEntry describes one cached answer for inspection
+type CacheEntry struct {
+	Tier         CacheSource `json:"tier"`
+	Key          string      `json:"key"`
+	Records      int         `json:"records"`
+	TTLRemaining int64       `json:"ttl_remaining"` // seconds
+	Hits         int64       `json:"hits"`          // always 0 in Redis, which doesn't count hits per key
+}
+
+// CacheFilter selects cached entries to inspect. Empty fields match
+// everything
+type CacheFilter struct {
+	Tier CacheSource
+	Name string // exact name
+	Apex string // a domain and every name below it
+	Type string
+}
+
+// Validate checks the filter's tier and that it uses at most one of name
+// and apex
+func (f CacheFilter) Validate() error {
+	switch f.Tier {
+	case "", SourceWire, SourceMemory, SourceRedis, SourceStale:
+	default:
+		return fmt.Errorf("unknown tier %q: expected %s, %s, %s, or %s", f.Tier, SourceWire, SourceMemory, SourceRedis, SourceStale)
+	}
+	if f.Name != "" && f.Apex != "" {
+		return errors.New("name and apex are mutually exclusive")
+	}
+	return nil
+}
+
+// normalize lowercases names and types the way cache keys store them
+func (f CacheFilter) normalize() CacheFilter {
+	f.Name = models.NormalizeDomainName(f.Name)
+	f.Apex = models.NormalizeDomainName(f.Apex)
+	f.Type = strings.ToUpper(f.Type)
+	return f
+}
+
+// includes reports whether the filter selects entries of tier
+func (f CacheFilter) includes(tier CacheSource) bool {
+	return f.Tier == "" || f.Tier == tier
+}
+
+// matches reports whether a cache key, which ends in "name:TYPE" after any
+// prefixes, is selected by a normalized filter
+func (f CacheFilter) matches(key string) bool {
+	rest, recordType, found := cutLast(key, ":")
+	if !found {
+		return false
+	}
+	_, name, _ := cutLast(rest, ":")
+
+	switch {
+	case f.Type != "" && recordType != f.Type:
+		return false
+	case f.Name != "" && name != f.Name:
+		return false
+	case f.Apex != "" && !inZone(name, f.Apex):
+		return false
+	}
+	return true
+}
+
+// CacheInspector lists the cached entries a filter selects
+type CacheInspector interface {
+	CacheEntries(ctx context.Context, filter CacheFilter) ([]CacheEntry, error)
+}
+
+// CacheInspectorFunc adapts a function to the CacheInspector interface
+type CacheInspectorFunc func(ctx context.Context, filter CacheFilter) ([]CacheEntry, error)
+
+// CacheEntries calls fn(ctx, filter)
+func (fn CacheInspectorFunc) CacheEntries(ctx context.Context, filter CacheFilter) ([]CacheEntry, error) {
+	return fn(ctx, filter)
+}
+
+// DescribeEntries converts the entries of an in-memory tier that filter
+// selects
+func DescribeEntries(tier CacheSource, infos []cache.EntryInfo, filter CacheFilter) []CacheEntry {
+	if !filter.includes(tier) {
+		return nil
+	}
+	filter = filter.normalize()
+
+	now := time.Now()
+	var entries []CacheEntry
+	for _, info := range infos {
+		if !filter.matches(info.Key) {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Tier:         tier,
+			Key:          info.Key,
+			Records:      info.Records,
+			TTLRemaining: int64(info.ExpiresAt.Sub(now).Seconds()),
+			Hits:         info.Hits,
+		})
+	}
+	return entries
+}
+
+// CacheEntries lists the memory cache entries filter selects
+func (cs *CachedStorage) CacheEntries(ctx context.Context, filter CacheFilter) ([]CacheEntry, error) {
+	return DescribeEntries(SourceMemory, cs.cache.Entries(), filter), nil
+}
+
+// CacheEntries lists the fallback answers filter selects
+func (b *BreakerStorage) CacheEntries(ctx context.Context, filter CacheFilter) ([]CacheEntry, error) {
+	return DescribeEntries(SourceStale, b.stale.Entries(), filter), nil
+}
+
+// redisInspectBatch is how many Redis entries are read per pipeline
+const redisInspectBatch = 100
+
+// CacheEntries lists the memory and Redis cache entries filter selects.
+// Redis keys are listed without their prefix, matching the memory cache
+func (rcs *RedisCacheStorage) CacheEntries(ctx context.Context, filter CacheFilter) ([]CacheEntry, error) {
+	entries := DescribeEntries(SourceMemory, rcs.memoryCache.Entries(), filter)
+	for i := range entries {
+		entries[i].Key = strings.TrimPrefix(entries[i].Key, rcs.keyPrefix)
+	}
+	if !filter.includes(SourceRedis) {
+		return entries, nil
+	}
+	filter = filter.normalize()
+
+	// Keys end in "name:TYPE"; the scan narrows by name or type and matches
+	// filters out names that merely end the same way
+	pattern := rcs.keyPrefix + "*"
+	switch {
+	case filter.Name != "":
+		pattern += escapeGlob(filter.Name) + ":*"
+	case filter.Apex != "":
+		pattern += escapeGlob(filter.Apex) + ":*"
+	case filter.Type != "":
+		pattern += ":" + escapeGlob(filter.Type)
+	}
+	keys, err := redis.ScanFrom(rcs.redisClient, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan Redis cache: %w", err)
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return !filter.matches(key)
+	})
+
+	for start := 0; start < len(keys); start += redisInspectBatch {
+		batch := keys[start:min(start+redisInspectBatch, len(keys))]
+		values, err := redis.GetWithTTLFrom(ctx, rcs.redisClient, batch...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis cache: %w", err)
+		}
+
+		for _, value := range values {
+			records := 0
+			if decoded, err := decodeRecords(value.Value); err == nil {
+				records = len(decoded)
+			}
+			entries = append(entries, CacheEntry{
+				Tier:         SourceRedis,
+				Key:          strings.TrimPrefix(value.Key, rcs.keyPrefix),
+				Records:      records,
+				TTLRemaining: int64(value.TTL.Seconds()),
+			})
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,465 @@
+// internal/storage/migrate.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"errantdns.io/internal/logging"
+)
+
+// MigrateOptions selects the optional schema changes Migrate applies
+type MigrateOptions struct {
+	// Partitions splits dns_records into this many hash partitions on
+	// apex_domain; 0 leaves it unpartitioned
+	Partitions int
+
+	// RemoveDuplicates deletes all but the oldest of each set of duplicate
+	// records, logging every row it deletes, so the uniqueness index can
+	// be built
+	RemoveDuplicates bool
+}
+
+// migration is one versioned schema change, applied at most once
+type migration struct {
+	version     int
+	description string
+
+	// skip reports whether the migration is disabled by opts. Skipped
+	// migrations aren't recorded, so they run once they're enabled
+	skip func(opts *MigrateOptions) bool
+	up   func(ctx context.Context, tx *sql.Tx, opts *MigrateOptions) error
+}
+
+// migrations in the order they're applied. Never change or renumber an
+// applied migration; add a new one instead
+var migrations = []migration{
+	{
+		version:     1,
+		description: "store domain columns and index lookups by apex domain",
+		up:          migrateDomainColumns,
+	},
+	{
+		version:     2,
+		description: "partition dns_records by hash of apex_domain",
+		skip:        func(opts *MigrateOptions) bool { return opts.Partitions == 0 },
+		up:          migratePartitionByApex,
+	},
+	{
+		version:     3,
+		description: "allow generic record types (RFC 3597)",
+		up:          replaceTypeCheck("A", "AAAA", "CNAME", "TXT", "MX", "NS", "SOA", "PTR", "SRV", "CAA"),
+	},
+	{
+		version:     4,
+		description: "allow DS, DNSKEY, CDS, and CDNSKEY records",
+		up:          replaceTypeCheck("A", "AAAA", "CNAME", "TXT", "MX", "NS", "SOA", "PTR", "SRV", "CAA", "DS", "DNSKEY", "CDS", "CDNSKEY"),
+	},
+	{
+		version:     5,
+		description: "allow LOC records",
+		up:          replaceTypeCheck("A", "AAAA", "CNAME", "TXT", "MX", "NS", "SOA", "PTR", "SRV", "CAA", "DS", "DNSKEY", "CDS", "CDNSKEY", "LOC"),
+	},
+	{
+		version:     6,
+		description: "allow HINFO records",
+		up:          replaceTypeCheck("A", "AAAA", "CNAME", "TXT", "MX", "NS", "SOA", "PTR", "SRV", "CAA", "DS", "DNSKEY", "CDS", "CDNSKEY", "LOC", "HINFO"),
+	},
+	{
+		version:     7,
+		description: "allow DNAME records",
+		up:          replaceTypeCheck("A", "AAAA", "CNAME", "TXT", "MX", "NS", "SOA", "PTR", "SRV", "CAA", "DS", "DNSKEY", "CDS", "CDNSKEY", "LOC", "HINFO", "DNAME"),
+	},
+	{
+		version:     8,
+		description: "store custom public suffix rules",
+		up:          migratePublicSuffixes,
+	},
+	{
+		version:     9,
+		description: "remove duplicate records and enforce uniqueness",
+		skip:        func(opts *MigrateOptions) bool { return !opts.RemoveDuplicates },
+		up:          migrateRemoveDuplicates,
+	},
+	{
+		version:     10,
+		description: "index records without an apex domain for lookups by name",
+		up:          migrateNoApexIndex,
+	},
+}
+
+// migrationLockKey serializes migrations across servers sharing a database
+const migrationLockKey = "errantdns.schema_migrations"
+
+// Layouts of dns_records, cached by isPartitioned
+const (
+	layoutUnknown int32 = iota
+	layoutPlain
+	layoutPartitioned
+)
+
+// Migrate applies the schema migrations not yet recorded in
+// schema_migrations, each in its own transaction, and returns the versions
+// it applied
+func (s *PostgresStorage) Migrate(ctx context.Context, opts *MigrateOptions) ([]int, error) {
+	_, err := s.pool.Exec(ctx, s.connectionName, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var applied []int
+	for _, m := range migrations {
+		if m.skip != nil && m.skip(opts) {
+			continue
+		}
+
+		ran := false
+		err := s.pool.Transaction(ctx, s.connectionName, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, migrationLockKey); err != nil {
+				return fmt.Errorf("failed to lock schema_migrations: %w", err)
+			}
+
+			var done bool
+			err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&done)
+			if err != nil {
+				return fmt.Errorf("failed to check migration %d: %w", m.version, err)
+			}
+			if done {
+				return nil
+			}
+
+			logging.Info("storage", "Applying schema migration", "version", m.version, "description", m.description)
+			if err := m.up(ctx, tx, opts); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.description, err)
+			}
+
+			_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`, m.version, m.description)
+			if err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+			}
+			ran = true
+			return nil
+		})
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, m.version)
+		}
+	}
+
+	// The migrations may have changed the layout
+	s.layout.Store(layoutUnknown)
+	return applied, nil
+}
+
+// PendingMigrations returns the versions Migrate would apply, without
+// applying them
+func (s *PostgresStorage) PendingMigrations(ctx context.Context, opts *MigrateOptions) ([]int, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, s.connectionName, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema_migrations: %w", err)
+	}
+
+	done := make(map[int]bool)
+	if exists {
+		rows, err := s.pool.Query(ctx, s.connectionName, `SELECT version FROM schema_migrations`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var version int
+			if err := rows.Scan(&version); err != nil {
+				return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+			}
+			done[version] = true
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+
+	var pending []int
+	for _, m := range migrations {
+		if (m.skip == nil || !m.skip(opts)) && !done[m.version] {
+			pending = append(pending, m.version)
+		}
+	}
+	return pending, nil
+}
+
+// isPartitioned reports whether dns_records is partitioned, checking the
+// catalog on first use
+func (s *PostgresStorage) isPartitioned(ctx context.Context) (bool, error) {
+	switch s.layout.Load() {
+	case layoutPlain:
+		return false, nil
+	case layoutPartitioned:
+		return true, nil
+	}
+
+	var partitioned bool
+	err := s.pool.QueryRow(ctx, s.connectionName,
+		`SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('dns_records'))`).Scan(&partitioned)
+	if err != nil {
+		return false, fmt.Errorf("failed to check dns_records layout: %w", err)
+	}
+
+	if partitioned {
+		s.layout.Store(layoutPartitioned)
+	} else {
+		s.layout.Store(layoutPlain)
+	}
+	return partitioned, nil
+}
+
+// migrateDomainColumns adds the columns wildcard and apex-filtered lookups
+// use, for databases created from an older schema file, and fills them in
+func migrateDomainColumns(ctx context.Context, tx *sql.Tx, _ *MigrateOptions) error {
+	statements := []string{
+		`ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS etld TEXT DEFAULT NULL`,
+		`ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS apex_domain TEXT DEFAULT NULL`,
+		`ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS subdomain_labels TEXT[] DEFAULT NULL`,
+		`ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS is_wildcard BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS wildcard_mask BIGINT NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_dns_records_apex_type
+			ON dns_records(apex_domain, record_type, LOWER(name), priority)
+			WHERE apex_domain IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_dns_records_wildcard
+			ON dns_records(apex_domain, record_type, cardinality(subdomain_labels))
+			WHERE is_wildcard`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	_, err := backfillDomainColumns(ctx, tx)
+	return err
+}
+
+// migratePartitionByApex rebuilds dns_records as a table partitioned by
+// hash of apex_domain, so each lookup only touches one partition. Records
+// are copied into the new table, which takes over the name, indexes,
+// triggers, dependent views, and id sequence of the old one. Writes are
+// blocked while it runs
+func migratePartitionByApex(ctx context.Context, tx *sql.Tx, opts *MigrateOptions) error {
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE dns_records IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	// Every row needs its partition key; names without an apex domain
+	// share the empty one
+	if _, err := backfillDomainColumns(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE dns_records SET apex_domain = '' WHERE apex_domain IS NULL`); err != nil {
+		return err
+	}
+
+	// Remember what hangs off the old table so it can be rebuilt
+	indexes, err := queryPairs(ctx, tx, `
+		SELECT indexname, indexdef
+		FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = 'dns_records'
+			AND indexname NOT IN ('dns_records_pkey', 'idx_dns_records_unique')
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list indexes: %w", err)
+	}
+	triggers, err := queryPairs(ctx, tx, `
+		SELECT tgname, pg_get_triggerdef(oid)
+		FROM pg_trigger
+		WHERE tgrelid = 'dns_records'::regclass AND NOT tgisinternal
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list triggers: %w", err)
+	}
+	views, err := queryPairs(ctx, tx, `
+		SELECT DISTINCT v.oid::regclass::text, pg_get_viewdef(v.oid)
+		FROM pg_depend d
+		JOIN pg_rewrite r ON r.oid = d.objid
+		JOIN pg_class v ON v.oid = r.ev_class
+		WHERE d.refobjid = 'dns_records'::regclass AND v.oid <> 'dns_records'::regclass AND v.relkind = 'v'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to list dependent views: %w", err)
+	}
+	var sequence sql.NullString
+	if err := tx.QueryRowContext(ctx, `SELECT pg_get_serial_sequence('dns_records', 'id')`).Scan(&sequence); err != nil {
+		return fmt.Errorf("failed to find id sequence: %w", err)
+	}
+
+	statements := []string{
+		`CREATE TABLE dns_records_partitioned
+			(LIKE dns_records INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+			PARTITION BY HASH (apex_domain)`,
+		`ALTER TABLE dns_records_partitioned
+			ALTER COLUMN apex_domain SET DEFAULT '',
+			ALTER COLUMN apex_domain SET NOT NULL`,
+	}
+	for i := 0; i < opts.Partitions; i++ {
+		statements = append(statements, fmt.Sprintf(
+			`CREATE TABLE dns_records_p%d PARTITION OF dns_records_partitioned FOR VALUES WITH (MODULUS %d, REMAINDER %d)`,
+			i, opts.Partitions, i))
+	}
+	statements = append(statements, `INSERT INTO dns_records_partitioned SELECT * FROM dns_records`)
+	for _, view := range views {
+		statements = append(statements, `DROP VIEW `+view[0])
+	}
+	if sequence.Valid {
+		statements = append(statements, `ALTER SEQUENCE `+sequence.String+` OWNED BY NONE`)
+	}
+	statements = append(statements,
+		`DROP TABLE dns_records`,
+		`ALTER TABLE dns_records_partitioned RENAME TO dns_records`,
+		// Unique indexes on a partitioned table must include the partition key
+		`ALTER TABLE dns_records ADD CONSTRAINT dns_records_pkey PRIMARY KEY (id, apex_domain)`,
+		`CREATE UNIQUE INDEX idx_dns_records_unique
+			ON dns_records(apex_domain, LOWER(name), record_type, md5(target), priority, COALESCE(port, 0), COALESCE(tag, ''))`,
+	)
+	if sequence.Valid {
+		statements = append(statements, `ALTER SEQUENCE `+sequence.String+` OWNED BY dns_records.id`)
+	}
+	for _, index := range indexes {
+		statements = append(statements, index[1])
+	}
+	for _, trigger := range triggers {
+		statements = append(statements, trigger[1])
+	}
+	for _, view := range views {
+		statements = append(statements, `CREATE VIEW `+view[0]+` AS `+view[1])
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(strings.Fields(statement), " "), err)
+		}
+	}
+	return nil
+}
+
+// replaceTypeCheck returns a migration replacing the record type check
+// constraint with one admitting types and generic TYPEnnn types
+func replaceTypeCheck(types ...string) func(ctx context.Context, tx *sql.Tx, opts *MigrateOptions) error {
+	return func(ctx context.Context, tx *sql.Tx, _ *MigrateOptions) error {
+		statements := []string{
+			`ALTER TABLE dns_records DROP CONSTRAINT IF EXISTS dns_records_type_check`,
+			fmt.Sprintf(`ALTER TABLE dns_records ADD CONSTRAINT dns_records_type_check
+				CHECK (record_type IN ('%s') OR record_type ~ '^TYPE[1-9][0-9]{0,4}$')`, strings.Join(types, "', '")),
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migratePublicSuffixes creates the table of custom public suffix rules
+// merged with the compiled-in Public Suffix List
+func migratePublicSuffixes(ctx context.Context, tx *sql.Tx, _ *MigrateOptions) error {
+	_, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS public_suffixes (
+			rule VARCHAR(255) PRIMARY KEY,
+			comment TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		)
+	`)
+	return err
+}
+
+// migrateNoApexIndex indexes rows inserted with plain SQL, which have no
+// apex domain until the next backfill, so apex-filtered lookups can still
+// find them by name
+func migrateNoApexIndex(ctx context.Context, tx *sql.Tx, _ *MigrateOptions) error {
+	_, err := tx.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_dns_records_no_apex
+		ON dns_records(LOWER(name), record_type)
+		WHERE apex_domain IS NULL OR apex_domain = ''`)
+	return err
+}
+
+// migrateRemoveDuplicates deletes all but the oldest row of each logical
+// record, as idx_dns_records_unique defines it, and builds the index if
+// duplicates kept the schema file from creating it
+func migrateRemoveDuplicates(ctx context.Context, tx *sql.Tx, _ *MigrateOptions) error {
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM dns_records
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY LOWER(name), record_type, target, priority, COALESCE(port, 0), COALESCE(tag, '')
+					ORDER BY id
+				) AS copy
+				FROM dns_records
+			) copies
+			WHERE copy > 1
+		)
+		RETURNING id, name, record_type, target, priority
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to delete duplicate records: %w", err)
+	}
+	defer rows.Close()
+
+	removed := 0
+	for rows.Next() {
+		var id, priority int
+		var name, recordType, target string
+		if err := rows.Scan(&id, &name, &recordType, &target, &priority); err != nil {
+			return err
+		}
+		removed++
+		logging.Warn("storage", "Removed duplicate record", "id", id, "name", name,
+			"type", recordType, "target", target, "priority", priority)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	logging.Info("storage", "Duplicate records removed", "count", removed)
+
+	// On a partitioned table the index must lead with the partition key
+	var partitioned bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM pg_partitioned_table WHERE partrelid = to_regclass('dns_records'))`).Scan(&partitioned)
+	if err != nil {
+		return fmt.Errorf("failed to check dns_records layout: %w", err)
+	}
+	columns := `LOWER(name), record_type, md5(target), priority, COALESCE(port, 0), COALESCE(tag, '')`
+	if partitioned {
+		columns = `apex_domain, ` + columns
+	}
+	_, err = tx.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_dns_records_unique ON dns_records(`+columns+`)`)
+	return err
+}
+
+// queryPairs runs a query selecting two text columns
+func queryPairs(ctx context.Context, tx *sql.Tx, query string) ([][2]string, error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs [][2]string
+	for rows.Next() {
+		var pair [2]string
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, rows.Err()
+}
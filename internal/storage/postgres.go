@@ -3,17 +3,19 @@ package storage
 
 import (
 	"context"
-	"crypto/md5"
 	"database/sql"
-	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"hash/fnv"
-	"log"
-	"math/rand"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"errantdns.io/internal/models"
 	"errantdns.io/internal/pgsqlpool"
+	"errantdns.io/internal/selection"
+	"errantdns.io/internal/stats"
+
+	"github.com/jackc/pgx/v5"
 )
 
 // Storage interface defines the contract for DNS record storage
@@ -24,10 +26,16 @@ type Storage interface {
 	LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error)
 
 	// Management operations
+	GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error)
 	CreateRecord(ctx context.Context, record *models.DNSRecord) error
 	UpdateRecord(ctx context.Context, record *models.DNSRecord) error
+	UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error)
+	CreateRecords(ctx context.Context, records []*models.DNSRecord) error
 	DeleteRecord(ctx context.Context, id int) error
 	DeleteRecords(ctx context.Context, name string, recordType string) error
+	ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error)
+	SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error)
+	CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error)
 
 	// System operations
 	Health(ctx context.Context) error
@@ -38,7 +46,42 @@ type Storage interface {
 type PostgresStorage struct {
 	pool           *pgsqlpool.Pool
 	connectionName string
-	tieBreaker     string
+	strategy       selection.Strategy
+
+	// Lookups running at least this long are logged; 0 disables
+	slowQueryThreshold time.Duration
+	slowQueries        atomic.Int64
+
+	// Lookups are abandoned after this long; 0 leaves only the caller's deadline
+	lookupTimeout time.Duration
+	timeouts      atomic.Int64
+
+	// Names without records of the queried type fall back to wildcards
+	wildcardLookups bool
+
+	// What writes do about RRsets with mixed TTLs, see rrsetttl.go
+	rrsetTTL string
+
+	// How changesets advance each zone's SOA serial
+	serials *models.SerialPolicy
+
+	// Set once every record has its domain columns, see lookupFilter.
+	// backfilled stays set while SetPublicSuffixes turns filtering off
+	apexFiltering atomic.Bool
+	backfilled    atomic.Bool
+
+	// Whether dns_records is partitioned, see isPartitioned
+	layout atomic.Int32
+
+	// Record writes fail with ErrReadOnly while set, see readonly.go
+	readOnly atomic.Bool
+}
+
+// dbtx runs statements on a connection or inside a transaction
+type dbtx interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
 // Config holds configuration for PostgreSQL storage
@@ -53,6 +96,24 @@ type Config struct {
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+
+	// Prepared statements kept per connection; 0 disables the cache
+	StatementCacheSize int
+
+	// Hosts and TargetSessionAttrs configure multi-host failover; see
+	// pgsqlpool.ConnectionConfig
+	Hosts              []string
+	TargetSessionAttrs string
+
+	SlowQueryThreshold time.Duration        // log lookups slower than this; 0 disables
+	LookupTimeout      time.Duration        // abandon lookups after this long; 0 disables
+	WildcardLookups    bool                 // answer names without records from matching wildcards
+	RRSetTTL           string               // an RRset TTL mode; empty is RRSetTTLOff
+	SerialPolicy       *models.SerialPolicy // how changesets advance each zone's SOA serial; nil increments
+
+	// Deferred skips checking the database is reachable; it is connected
+	// to on first use
+	Deferred bool
 }
 
 // DefaultConfig returns a config with sensible defaults
@@ -65,11 +126,14 @@ func DefaultConfig() *Config {
 		MaxIdleConns:    5,
 		ConnMaxLifetime: 5 * time.Minute,
 		ConnMaxIdleTime: 2 * time.Minute,
+		WildcardLookups: true,
+
+		StatementCacheSize: pgsqlpool.DefaultStatementCacheSize,
 	}
 }
 
 // NewPostgresStorage creates a new PostgreSQL storage instance
-func NewPostgresStorage(ctx context.Context, pool *pgsqlpool.Pool, connectionName string, config *Config, tieBreaker string) (*PostgresStorage, error) {
+func NewPostgresStorage(ctx context.Context, pool *pgsqlpool.Pool, connectionName string, config *Config, strategy selection.Strategy) (*PostgresStorage, error) {
 	// Create connection config
 	connConfig := &pgsqlpool.ConnectionConfig{
 		Host:            config.Host,
@@ -82,17 +146,33 @@ func NewPostgresStorage(ctx context.Context, pool *pgsqlpool.Pool, connectionNam
 		MaxIdleConns:    config.MaxIdleConns,
 		ConnMaxLifetime: config.ConnMaxLifetime,
 		ConnMaxIdleTime: config.ConnMaxIdleTime,
+
+		StatementCacheSize: config.StatementCacheSize,
+
+		Hosts:              config.Hosts,
+		TargetSessionAttrs: config.TargetSessionAttrs,
 	}
 
 	// Add the connection to the provided pool
-	if err := pool.AddConnection(ctx, connectionName, connConfig); err != nil {
+	var err error
+	if config.Deferred {
+		err = pool.AddDeferredConnection(connectionName, connConfig)
+	} else {
+		err = pool.AddConnection(ctx, connectionName, connConfig)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to create database connection: %w", err)
 	}
 
 	return &PostgresStorage{
-		pool:           pool,
-		connectionName: connectionName,
-		tieBreaker:     tieBreaker,
+		pool:               pool,
+		connectionName:     connectionName,
+		strategy:           strategy,
+		slowQueryThreshold: config.SlowQueryThreshold,
+		lookupTimeout:      config.LookupTimeout,
+		wildcardLookups:    config.WildcardLookups,
+		rrsetTTL:           config.RRSetTTL,
+		serials:            config.SerialPolicy,
 	}, nil
 }
 
@@ -115,12 +195,17 @@ func (s *PostgresStorage) LookupRecord(ctx context.Context, query *models.Lookup
 	}
 
 	// Apply tie-breaking for multiple records
-	selected := s.selectFromGroup(records, query)
+	selected := selection.Select(s.strategy, records, query)
 	return selected, nil
 }
 
 // LookupRecords finds all DNS records matching the query, ordered by priority
 func (s *PostgresStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	noteSource(ctx, SourceDatabase)
+	ctx, cancel := withTimeout(ctx, s.lookupTimeout)
+	defer cancel()
+
+	filter, args := s.lookupFilter(query)
 	sqlQuery := `
 		SELECT 	
 			id, 
@@ -138,13 +223,21 @@ func (s *PostgresStorage) LookupRecords(ctx context.Context, query *models.Looku
 			expire, 
 			minttl, 
 			weight, 
-			port
+			port,
+			expires_at,
+			not_before,
+			not_after
 		FROM dns_records 
-		WHERE LOWER(name) = LOWER($1) AND record_type = $2
+		WHERE ` + filter + `
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (not_before IS NULL OR not_before <= NOW())
+			AND (not_after IS NULL OR not_after > NOW())
 		ORDER BY priority ASC
 	`
 
-	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, query.Name, query.Type.String())
+	defer s.observeQuery(ctx, sqlQuery, args, time.Now())
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query records for %s %s: %w", query.Name, query.Type, err)
 	}
@@ -158,6 +251,7 @@ func (s *PostgresStorage) LookupRecords(ctx context.Context, query *models.Looku
 		var serial, refresh, retry, expire, minttl sql.NullInt32
 		var mbox sql.NullString
 		var weight, port sql.NullInt16
+		var expiresAt, notBefore, notAfter sql.NullTime
 
 		err := rows.Scan(
 			&record.ID,
@@ -176,6 +270,9 @@ func (s *PostgresStorage) LookupRecords(ctx context.Context, query *models.Looku
 			&minttl,
 			&weight,
 			&port,
+			&expiresAt,
+			&notBefore,
+			&notAfter,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan record: %w", err)
@@ -206,6 +303,15 @@ func (s *PostgresStorage) LookupRecords(ctx context.Context, query *models.Looku
 		if port.Valid {
 			record.Port = uint16(port.Int16)
 		}
+		if expiresAt.Valid {
+			record.ExpiresAt = &expiresAt.Time
+		}
+		if notBefore.Valid {
+			record.NotBefore = &notBefore.Time
+		}
+		if notAfter.Valid {
+			record.NotAfter = &notAfter.Time
+		}
 
 		records = append(records, &record)
 	}
@@ -214,30 +320,22 @@ func (s *PostgresStorage) LookupRecords(ctx context.Context, query *models.Looku
 		return nil, fmt.Errorf("error iterating records: %w", err)
 	}
 
+	if len(records) == 0 && s.wildcardLookups {
+		return s.lookupWildcard(ctx, query, false)
+	}
+
 	return records, nil
 }
 
 // LookupRecordGroup finds all records with the same lowest priority for the query
 func (s *PostgresStorage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
-	// First, get the lowest priority value
-	minPriorityQuery := `
-		SELECT MIN(priority) 
-		FROM dns_records 
-		WHERE LOWER(name) = LOWER($1) AND record_type = $2
-	`
+	noteSource(ctx, SourceDatabase)
+	ctx, cancel := withTimeout(ctx, s.lookupTimeout)
+	defer cancel()
 
-	row := s.pool.QueryRow(ctx, s.connectionName, minPriorityQuery, query.Name, query.Type.String())
-
-	var minPriority sql.NullInt32
-	err := row.Scan(&minPriority)
-	if err != nil {
-		if err == sql.ErrNoRows || !minPriority.Valid {
-			return nil, nil // No records found
-		}
-		return nil, fmt.Errorf("failed to get min priority for %s %s: %w", query.Name, query.Type, err)
-	}
-
-	// Now get all records with that minimum priority - ADD MISSING FIELDS:
+	// The lowest priority is found in a subquery so the group is fetched in
+	// a single round trip
+	filter, args := s.lookupFilter(query)
 	recordsQuery := `
 		SELECT 	
 			id, 
@@ -255,13 +353,29 @@ func (s *PostgresStorage) LookupRecordGroup(ctx context.Context, query *models.L
 			expire, 
 			minttl, 
 			weight, 
-			port
+			port,
+			expires_at,
+			not_before,
+			not_after
 		FROM dns_records 
-		WHERE LOWER(name) = LOWER($1) AND record_type = $2 AND priority = $3
+		WHERE ` + filter + `
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (not_before IS NULL OR not_before <= NOW())
+			AND (not_after IS NULL OR not_after > NOW())
+			AND priority = (
+				SELECT MIN(priority)
+				FROM dns_records
+				WHERE ` + filter + `
+					AND (expires_at IS NULL OR expires_at > NOW())
+					AND (not_before IS NULL OR not_before <= NOW())
+					AND (not_after IS NULL OR not_after > NOW())
+			)
 		ORDER BY id ASC
 	`
 
-	rows, err := s.pool.Query(ctx, s.connectionName, recordsQuery, query.Name, query.Type.String(), minPriority.Int32)
+	defer s.observeQuery(ctx, recordsQuery, args, time.Now())
+
+	rows, err := s.pool.Query(ctx, s.connectionName, recordsQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query record group for %s %s: %w", query.Name, query.Type, err)
 	}
@@ -275,6 +389,7 @@ func (s *PostgresStorage) LookupRecordGroup(ctx context.Context, query *models.L
 		var serial, refresh, retry, expire, minttl sql.NullInt32
 		var mbox sql.NullString
 		var weight, port sql.NullInt16
+		var expiresAt, notBefore, notAfter sql.NullTime
 
 		err := rows.Scan(
 			&record.ID,
@@ -293,6 +408,9 @@ func (s *PostgresStorage) LookupRecordGroup(ctx context.Context, query *models.L
 			&minttl,
 			&weight,
 			&port,
+			&expiresAt,
+			&notBefore,
+			&notAfter,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan record: %w", err)
@@ -323,6 +441,15 @@ func (s *PostgresStorage) LookupRecordGroup(ctx context.Context, query *models.L
 		if port.Valid {
 			record.Port = uint16(port.Int16)
 		}
+		if expiresAt.Valid {
+			record.ExpiresAt = &expiresAt.Time
+		}
+		if notBefore.Valid {
+			record.NotBefore = &notBefore.Time
+		}
+		if notAfter.Valid {
+			record.NotAfter = &notAfter.Time
+		}
 
 		records = append(records, &record)
 	}
@@ -331,20 +458,105 @@ func (s *PostgresStorage) LookupRecordGroup(ctx context.Context, query *models.L
 		return nil, fmt.Errorf("error iterating record group: %w", err)
 	}
 
+	if len(records) == 0 && s.wildcardLookups {
+		return s.lookupWildcard(ctx, query, true)
+	}
+
 	return records, nil
 }
 
-// CreateRecord inserts a new DNS record
-func (s *PostgresStorage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
-	// Validate and normalize the record
-	if err := record.Validate(); err != nil {
-		return fmt.Errorf("invalid record: %w", err)
+// lookupFilter returns the condition selecting records with query's name
+// and type, and its arguments. Once every record has its domain columns
+// stored, the apex domain is filtered on first so lookups use
+// idx_dns_records_apex_type; until then, and for names without an apex
+// domain, they match on the name alone. Rows inserted with plain SQL since
+// have no apex domain (NULL, or empty when partitioned) and are matched on
+// the name alone through idx_dns_records_no_apex
+func (s *PostgresStorage) lookupFilter(query *models.LookupQuery) (string, []interface{}) {
+	if s.apexFiltering.Load() {
+		if apex, _, ok := models.DomainComponents(query.Name); ok {
+			return "(apex_domain = $3 OR apex_domain = '' OR apex_domain IS NULL) AND record_type = $2 AND LOWER(name) = LOWER($1)",
+				[]interface{}{query.Name, query.Type.String(), apex}
+		}
 	}
-	record.Normalize()
+	return "LOWER(name) = LOWER($1) AND record_type = $2", []interface{}{query.Name, query.Type.String()}
+}
+
+// LookupByAddress finds active A and AAAA records pointing at address,
+// which must be in canonical net.IP.String() form. Wildcard names are
+// skipped since they can't be the target of a PTR record
+func (s *PostgresStorage) LookupByAddress(ctx context.Context, address string) ([]*models.DNSRecord, error) {
+	ctx, cancel := withTimeout(ctx, s.lookupTimeout)
+	defer cancel()
 
 	sqlQuery := `
-		INSERT INTO dns_records 
-			(
+		SELECT 
+			id, 
+			name, 
+			record_type, 
+			target, 
+			ttl, 
+			priority, 
+			expires_at, 
+			not_before, 
+			not_after
+		FROM dns_records 
+		WHERE record_type IN ('A', 'AAAA') AND target = $1 AND name NOT LIKE '%*%'
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (not_before IS NULL OR not_before <= NOW())
+			AND (not_after IS NULL OR not_after > NOW())
+		ORDER BY priority ASC, LOWER(name) ASC
+	`
+
+	defer s.observeQuery(ctx, sqlQuery, []interface{}{address}, time.Now())
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query records for address %s: %w", address, err)
+	}
+	defer rows.Close()
+
+	var records []*models.DNSRecord
+	for rows.Next() {
+		var record models.DNSRecord
+		var expiresAt, notBefore, notAfter sql.NullTime
+
+		if err := rows.Scan(
+			&record.ID,
+			&record.Name,
+			&record.RecordType,
+			&record.Target,
+			&record.TTL,
+			&record.Priority,
+			&expiresAt,
+			&notBefore,
+			&notAfter,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+
+		if expiresAt.Valid {
+			record.ExpiresAt = &expiresAt.Time
+		}
+		if notBefore.Valid {
+			record.NotBefore = &notBefore.Time
+		}
+		if notAfter.Valid {
+			record.NotAfter = &notAfter.Time
+		}
+
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating records: %w", err)
+	}
+
+	return records, nil
+}
+
+// insertRecordColumns are the columns bound by recordParams, in order
+const insertRecordColumns = `
 				name, 
 				record_type, 
 				target, 
@@ -357,73 +569,97 @@ func (s *PostgresStorage) CreateRecord(ctx context.Context, record *models.DNSRe
 				expire, 
 				minttl, 
 				weight, 
-				port
-			)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-		RETURNING id, created_at, updated_at
-	`
+				port,
+				expires_at,
+				not_before,
+				not_after,
+				labels,
+				comment,
+				owner,
+				tag,
+				etld,
+				apex_domain,
+				subdomain_labels,
+				is_wildcard,
+				wildcard_mask,
+				external_key
+`
+
+// insertRecordSQL inserts the columns bound by recordParams
+const insertRecordSQL = `
+		INSERT INTO dns_records 
+			(` + insertRecordColumns + `			)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
+`
 
-	// Convert to nullable values - only set if non-zero
-	var serial, refresh, retry, expire, minttl sql.NullInt32
-	var mbox sql.NullString
-	var weight, port sql.NullInt16
+// CreateRecord inserts a new DNS record
+func (s *PostgresStorage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return s.writeRecord(ctx, record, func(q dbtx) error {
+		return insertRecord(ctx, q, record)
+	})
+}
 
-	if record.Serial != 0 {
-		serial = sql.NullInt32{Int32: int32(record.Serial), Valid: true}
-	}
-	if record.Mbox != "" {
-		mbox = sql.NullString{String: record.Mbox, Valid: true}
-	}
-	if record.Refresh != 0 {
-		refresh = sql.NullInt32{Int32: int32(record.Refresh), Valid: true}
-	}
-	if record.Retry != 0 {
-		retry = sql.NullInt32{Int32: int32(record.Retry), Valid: true}
-	}
-	if record.Expire != 0 {
-		expire = sql.NullInt32{Int32: int32(record.Expire), Valid: true}
+// insertRecord inserts record using q, filling in its ID and timestamps
+func insertRecord(ctx context.Context, q dbtx, record *models.DNSRecord) error {
+	// Validate and normalize the record
+	if err := record.Validate(); err != nil {
+		return fmt.Errorf("invalid record: %w", err)
 	}
-	if record.Minttl != 0 {
-		minttl = sql.NullInt32{Int32: int32(record.Minttl), Valid: true}
+	record.Normalize()
+	setDomainComponents(record)
+
+	if err := checkCNAMEConflict(ctx, q, record); err != nil {
+		return err
 	}
-	if record.Weight != 0 {
-		weight = sql.NullInt16{Int16: int16(record.Weight), Valid: true}
+	if err := checkExternalKey(ctx, q, record); err != nil {
+		return err
 	}
-	if record.Port != 0 {
-		port = sql.NullInt16{Int16: int16(record.Port), Valid: true}
+
+	sqlQuery := insertRecordSQL + `
+		RETURNING id, created_at, updated_at, version
+	`
+
+	args, err := recordParams(record)
+	if err != nil {
+		return err
 	}
 
-	row := s.pool.QueryRow(ctx, s.connectionName, sqlQuery,
-		record.Name,
-		record.RecordType,
-		record.Target,
-		record.TTL,
-		record.Priority,
-		serial,
-		mbox,
-		refresh,
-		retry,
-		expire,
-		minttl,
-		weight,
-		port,
-	)
+	row := q.QueryRowContext(ctx, sqlQuery, args...)
 
-	err := row.Scan(&record.ID, &record.CreatedAt, &record.UpdatedAt)
+	err = row.Scan(&record.ID, &record.CreatedAt, &record.UpdatedAt, &record.Version)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateError(record)
+		}
 		return fmt.Errorf("failed to create record %s %s: %w", record.Name, record.RecordType, err)
 	}
 
 	return nil
 }
 
-// UpdateRecord updates an existing DNS record
+// UpdateRecord updates an existing DNS record. If record.Version is set,
+// the update fails with ErrVersionConflict unless it matches the stored one
 func (s *PostgresStorage) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return s.writeRecord(ctx, record, func(q dbtx) error {
+		return updateRecord(ctx, q, record)
+	})
+}
+
+// updateRecord overwrites the record with record.ID using q
+func updateRecord(ctx context.Context, q dbtx, record *models.DNSRecord) error {
 	// Validate and normalize the record
 	if err := record.Validate(); err != nil {
 		return fmt.Errorf("invalid record: %w", err)
 	}
 	record.Normalize()
+	setDomainComponents(record)
+
+	if err := checkCNAMEConflict(ctx, q, record); err != nil {
+		return err
+	}
+	if err := checkExternalKey(ctx, q, record); err != nil {
+		return err
+	}
 
 	sqlQuery := `
 		UPDATE dns_records 
@@ -441,71 +677,201 @@ func (s *PostgresStorage) UpdateRecord(ctx context.Context, record *models.DNSRe
 		    minttl = $11, 
 			weight = $12, 
 			port = $13, 
+			expires_at = $14,
+			not_before = $15,
+			not_after = $16,
+			labels = $17,
+			comment = $18,
+			owner = $19,
+			tag = $20,
+			etld = $21,
+			apex_domain = $22,
+			subdomain_labels = $23,
+			is_wildcard = $24,
+			wildcard_mask = $25,
+			external_key = $26,
+			version = version + 1,
 			updated_at = NOW()
-		WHERE id = $14
-		RETURNING updated_at
+		WHERE id = $27 AND ($28 = 0 OR version = $28)
+		RETURNING updated_at, version
 	`
 
-	// Convert to nullable values
-	var serial, refresh, retry, expire, minttl sql.NullInt32
-	var mbox sql.NullString
-	var weight, port sql.NullInt16
+	args, err := recordParams(record)
+	if err != nil {
+		return err
+	}
 
-	if record.Serial != 0 {
-		serial = sql.NullInt32{Int32: int32(record.Serial), Valid: true}
+	row := q.QueryRowContext(ctx, sqlQuery, append(args, record.ID, record.Version)...)
+
+	err = row.Scan(&record.UpdatedAt, &record.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return missingOrStale(ctx, q, record)
+		}
+		if isUniqueViolation(err) {
+			return duplicateError(record)
+		}
+		return fmt.Errorf("failed to update record ID %d: %w", record.ID, err)
 	}
-	if record.Mbox != "" {
-		mbox = sql.NullString{String: record.Mbox, Valid: true}
+
+	return nil
+}
+
+// UpsertRecord creates record, or updates the stored record with the same
+// name, type, target, priority, port, and tag in place. A record with an
+// external key instead updates the record holding that key. It reports
+// whether a new record was created; either way record's ID and timestamps
+// are set
+func (s *PostgresStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	if err := s.checkWritable(); err != nil {
+		return false, err
 	}
-	if record.Refresh != 0 {
-		refresh = sql.NullInt32{Int32: int32(record.Refresh), Valid: true}
+	partitioned, err := s.isPartitioned(ctx)
+	if err != nil {
+		return false, err
 	}
-	if record.Retry != 0 {
-		retry = sql.NullInt32{Int32: int32(record.Retry), Valid: true}
+
+	if record.ExternalKey != "" {
+		var created bool
+		err := s.pool.Transaction(ctx, s.connectionName, func(tx *sql.Tx) error {
+			var err error
+			if created, _, err = upsertRecord(ctx, tx, record, partitioned); err != nil {
+				return err
+			}
+			return s.harmonizeTTLs(ctx, tx, []*models.DNSRecord{record})
+		})
+		return created, err
 	}
-	if record.Expire != 0 {
-		expire = sql.NullInt32{Int32: int32(record.Expire), Valid: true}
+
+	var created bool
+	err = s.writeRecord(ctx, record, func(q dbtx) error {
+		var err error
+		created, _, err = upsertRecord(ctx, q, record, partitioned)
+		return err
+	})
+	return created, err
+}
+
+// upsertRecord inserts record using q, updating the matching row if one
+// exists, and returns the name and type the row had before. The conflict
+// target matches idx_dns_records_unique, which leads with the partition key
+// when dns_records is partitioned. Records with an external key are matched
+// by key instead, which needs q to be a transaction
+func upsertRecord(ctx context.Context, q dbtx, record *models.DNSRecord, partitioned bool) (bool, touchedKey, error) {
+	if record.ExternalKey != "" {
+		return upsertRecordByKey(ctx, q, record)
 	}
-	if record.Minttl != 0 {
-		minttl = sql.NullInt32{Int32: int32(record.Minttl), Valid: true}
+
+	if err := record.Validate(); err != nil {
+		return false, touchedKey{}, fmt.Errorf("invalid record: %w", err)
 	}
-	if record.Weight != 0 {
-		weight = sql.NullInt16{Int16: int16(record.Weight), Valid: true}
+	record.Normalize()
+	setDomainComponents(record)
+
+	if err := checkCNAMEConflict(ctx, q, record); err != nil {
+		return false, touchedKey{}, err
 	}
-	if record.Port != 0 {
-		port = sql.NullInt16{Int16: int16(record.Port), Valid: true}
+
+	conflict := `(LOWER(name), record_type, md5(target), priority, COALESCE(port, 0), COALESCE(tag, ''))`
+	if partitioned {
+		conflict = `(apex_domain, LOWER(name), record_type, md5(target), priority, COALESCE(port, 0), COALESCE(tag, ''))`
 	}
 
-	row := s.pool.QueryRow(ctx, s.connectionName, sqlQuery,
-		record.Name,
-		record.RecordType,
-		record.Target,
-		record.TTL,
-		record.Priority,
-		serial,
-		mbox,
-		refresh,
-		retry,
-		expire,
-		minttl,
-		weight,
-		port,
-		record.ID,
-	)
+	sqlQuery := insertRecordSQL + `
+		ON CONFLICT ` + conflict + `
+		DO UPDATE SET
+			ttl = EXCLUDED.ttl,
+			serial = EXCLUDED.serial,
+			mbox = EXCLUDED.mbox,
+			refresh = EXCLUDED.refresh,
+			retry = EXCLUDED.retry,
+			expire = EXCLUDED.expire,
+			minttl = EXCLUDED.minttl,
+			weight = EXCLUDED.weight,
+			expires_at = EXCLUDED.expires_at,
+			not_before = EXCLUDED.not_before,
+			not_after = EXCLUDED.not_after,
+			labels = EXCLUDED.labels,
+			comment = EXCLUDED.comment,
+			owner = EXCLUDED.owner,
+			etld = EXCLUDED.etld,
+			apex_domain = EXCLUDED.apex_domain,
+			subdomain_labels = EXCLUDED.subdomain_labels,
+			is_wildcard = EXCLUDED.is_wildcard,
+			wildcard_mask = EXCLUDED.wildcard_mask,
+			version = dns_records.version + 1,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at, version, (xmax = 0) AS inserted
+	`
 
-	err := row.Scan(&record.UpdatedAt)
+	args, err := recordParams(record)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("record with ID %d not found", record.ID)
+		return false, touchedKey{}, err
+	}
+
+	var created bool
+	err = q.QueryRowContext(ctx, sqlQuery, args...).Scan(&record.ID, &record.CreatedAt, &record.UpdatedAt, &record.Version, &created)
+	if err != nil {
+		return false, touchedKey{}, fmt.Errorf("failed to upsert record %s %s: %w", record.Name, record.RecordType, err)
+	}
+
+	return created, touchedKey{record.Name, record.RecordType}, nil
+}
+
+// upsertRecordByKey updates the record holding record's external key, or
+// creates record if none does
+func upsertRecordByKey(ctx context.Context, q dbtx, record *models.DNSRecord) (bool, touchedKey, error) {
+	if err := lockExternalKey(ctx, q, record.ExternalKey); err != nil {
+		return false, touchedKey{}, err
+	}
+
+	var previous touchedKey
+	row := q.QueryRowContext(ctx, `SELECT id, name, record_type FROM dns_records WHERE external_key = $1 LIMIT 1 FOR UPDATE`, record.ExternalKey)
+	err := row.Scan(&record.ID, &previous.name, &previous.recordType)
+	if err == sql.ErrNoRows {
+		record.ID = 0
+		if err := insertRecord(ctx, q, record); err != nil {
+			return false, touchedKey{}, err
 		}
-		return fmt.Errorf("failed to update record ID %d: %w", record.ID, err)
+		return true, touchedKey{record.Name, record.RecordType}, nil
+	}
+	if err != nil {
+		return false, touchedKey{}, fmt.Errorf("failed to find record with external key %q: %w", record.ExternalKey, err)
 	}
 
-	return nil
+	record.Version = 0
+	if err := updateRecord(ctx, q, record); err != nil {
+		return false, touchedKey{}, err
+	}
+	return false, previous, nil
+}
+
+// GetRecordByID returns the record with id, including metadata, whether
+// or not it's being served, or ErrRecordNotFound
+func (s *PostgresStorage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	sqlQuery := "SELECT " + fullRecordColumns + " FROM dns_records WHERE id = $1"
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get record ID %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	records, err := scanFullRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: ID %d", ErrRecordNotFound, id)
+	}
+	return records[0], nil
 }
 
 // DeleteRecord deletes a DNS record by ID
 func (s *PostgresStorage) DeleteRecord(ctx context.Context, id int) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
 	sqlQuery := `DELETE FROM dns_records WHERE id = $1`
 
 	result, err := s.pool.Exec(ctx, s.connectionName, sqlQuery, id)
@@ -519,7 +885,7 @@ func (s *PostgresStorage) DeleteRecord(ctx context.Context, id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("record with ID %d not found", id)
+		return fmt.Errorf("%w: ID %d", ErrRecordNotFound, id)
 	}
 
 	return nil
@@ -527,6 +893,9 @@ func (s *PostgresStorage) DeleteRecord(ctx context.Context, id int) error {
 
 // DeleteRecords deletes all DNS records matching name and optionally type
 func (s *PostgresStorage) DeleteRecords(ctx context.Context, name string, recordType string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
 	normalizedName := models.NormalizeDomainName(name)
 
 	var sqlQuery string
@@ -559,83 +928,457 @@ func (s *PostgresStorage) DeleteRecords(ctx context.Context, name string, record
 	return nil
 }
 
-// Health checks if the database connection is healthy
-func (s *PostgresStorage) Health(ctx context.Context) error {
-	return s.pool.HealthCheck(ctx, s.connectionName)
-}
+// ListRecords returns the records matching filter, including metadata and
+// records outside their activation window, in the filter's sort order
+// (name, type, and priority by default). With a limit it returns one page,
+// starting after the filter's cursor
+func (s *PostgresStorage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	if filter == nil {
+		filter = &models.RecordFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
 
-// Close closes the database connection pool
-func (s *PostgresStorage) Close() error {
-	return s.pool.Close()
+	sqlQuery := "SELECT " + fullRecordColumns + " FROM dns_records"
+	conditions, args, err := recordFilterConditions(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keyset pagination: the sort key columns compared as a row pick up
+	// right after the cursor
+	field, descending, _ := models.ParseRecordSort(filter.Sort)
+	columns := recordSortColumns[field]
+	cursor, _ := filter.DecodeCursor()
+	if cursor != nil {
+		var placeholders []string
+		for _, value := range recordCursorValues(field, cursor) {
+			args = append(args, value)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		comparison := ">"
+		if descending {
+			comparison = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(columns, ", "), comparison, strings.Join(placeholders, ", ")))
+	}
+
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	direction := " ASC"
+	if descending {
+		direction = " DESC"
+	}
+	sqlQuery += " ORDER BY " + strings.Join(columns, direction+", ") + direction
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		sqlQuery += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records: %w", err)
+	}
+	defer rows.Close()
+	return scanFullRecords(rows)
 }
 
-// selectFromGroup applies tie-breaking logic to select one record from a group
-func (s *PostgresStorage) selectFromGroup(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
-	if len(records) == 0 {
-		return nil
+// fullRecordColumns selects every dns_records column in the order
+// scanFullRecord reads them
+const fullRecordColumns = `id, name, record_type, target, ttl, priority, created_at, updated_at,
+	serial, mbox, refresh, retry, expire, minttl, weight, port, tag,
+	expires_at, not_before, not_after, labels, comment, owner, version, external_key`
+
+// recordFilterConditions returns the WHERE conditions selecting the
+// records filter matches, and their arguments. The cursor, sort, and limit
+// aren't included
+func recordFilterConditions(filter *models.RecordFilter) ([]string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
 	}
 
-	if len(records) == 1 {
-		return records[0]
+	if filter.Name != "" {
+		addCondition("LOWER(name) = LOWER($%d)", models.NormalizeDomainName(filter.Name))
+	}
+	if filter.NamePrefix != "" {
+		addCondition(`LOWER(name) LIKE $%d ESCAPE '\'`, escapeLike(strings.ToLower(filter.NamePrefix))+"%")
 	}
+	if filter.Zone != "" {
+		zone := models.NormalizeDomainName(filter.Zone)
+		args = append(args, zone, "%."+escapeLike(zone))
+		conditions = append(conditions, fmt.Sprintf(`(LOWER(name) = $%d OR LOWER(name) LIKE $%d ESCAPE '\')`, len(args)-1, len(args)))
+	}
+	if filter.RecordType != "" {
+		addCondition("record_type = $%d", strings.ToUpper(filter.RecordType))
+	}
+	if filter.Owner != "" {
+		addCondition("owner = $%d", filter.Owner)
+	}
+	if filter.ExternalKey != "" {
+		addCondition("external_key = $%d", filter.ExternalKey)
+	}
+	if len(filter.Labels) > 0 {
+		selector, err := json.Marshal(filter.Labels)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode label selector: %w", err)
+		}
+		addCondition("labels @> $%d::jsonb", string(selector))
+	}
+
+	return conditions, args, nil
+}
 
-	switch s.tieBreaker {
-	case "random":
-		// Use query-based seed for consistency within same query
-		seed := s.generateSeed(query)
-		rng := rand.New(rand.NewSource(seed))
-		index := rng.Intn(len(records))
-		return records[index]
+// recordSortColumns are the columns each sort order compares, ending with
+// the ID so the order is total
+var recordSortColumns = map[string][]string{
+	models.RecordSortName:    {"LOWER(name)", "record_type", "priority", "id"},
+	models.RecordSortCreated: {"created_at", "id"},
+	models.RecordSortUpdated: {"updated_at", "id"},
+	models.RecordSortID:      {"id"},
+}
 
-	case "round_robin":
-		fallthrough
+// recordCursorValues returns a cursor's sort key in the order of
+// recordSortColumns
+func recordCursorValues(field string, cursor *models.RecordCursor) []interface{} {
+	switch field {
+	case models.RecordSortName:
+		return []interface{}{cursor.Name, cursor.Type, cursor.Priority, cursor.ID}
+	case models.RecordSortCreated, models.RecordSortUpdated:
+		return []interface{}{cursor.Time, cursor.ID}
 	default:
-		// Round-robin based on time and query hash
-		index := s.roundRobinIndex(query, len(records))
-		return records[index]
+		return []interface{}{cursor.ID}
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// scanFullRecord scans a row selected with every dns_records column,
+// in the column order used by ListRecords
+func scanFullRecord(rows *sql.Rows) (*models.DNSRecord, error) {
+	var record models.DNSRecord
+
+	var serial, refresh, retry, expire, minttl, weight sql.NullInt32
+	var mbox, tag, comment, owner, externalKey sql.NullString
+	var port sql.NullInt16
+	var expiresAt, notBefore, notAfter sql.NullTime
+	var labels []byte
+
+	err := rows.Scan(
+		&record.ID,
+		&record.Name,
+		&record.RecordType,
+		&record.Target,
+		&record.TTL,
+		&record.Priority,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+		&serial,
+		&mbox,
+		&refresh,
+		&retry,
+		&expire,
+		&minttl,
+		&weight,
+		&port,
+		&tag,
+		&expiresAt,
+		&notBefore,
+		&notAfter,
+		&labels,
+		&comment,
+		&owner,
+		&record.Version,
+		&externalKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan record: %w", err)
+	}
+
+	record.Serial = uint32(serial.Int32)
+	record.Mbox = mbox.String
+	record.Refresh = uint32(refresh.Int32)
+	record.Retry = uint32(retry.Int32)
+	record.Expire = uint32(expire.Int32)
+	record.Minttl = uint32(minttl.Int32)
+	record.Weight = uint32(weight.Int32)
+	record.Port = uint16(port.Int16)
+	record.Tag = tag.String
+	record.Comment = comment.String
+	record.Owner = owner.String
+	record.ExternalKey = externalKey.String
+
+	if expiresAt.Valid {
+		record.ExpiresAt = &expiresAt.Time
+	}
+	if notBefore.Valid {
+		record.NotBefore = &notBefore.Time
+	}
+	if notAfter.Valid {
+		record.NotAfter = &notAfter.Time
+	}
+
+	if len(labels) > 0 {
+		if err := json.Unmarshal(labels, &record.Labels); err != nil {
+			return nil, fmt.Errorf("failed to decode labels for record ID %d: %w", record.ID, err)
+		}
+	}
+
+	return &record, nil
+}
+
+// recordParams returns the column values shared by INSERT and UPDATE, in
+// statement order ($1-$26)
+func recordParams(record *models.DNSRecord) ([]interface{}, error) {
+	// Convert to nullable values - only set if non-zero
+	var serial, refresh, retry, expire, minttl sql.NullInt32
+	var mbox, tag sql.NullString
+	var weight, port sql.NullInt16
+
+	if record.Serial != 0 {
+		serial = sql.NullInt32{Int32: int32(record.Serial), Valid: true}
+	}
+	if record.Mbox != "" {
+		mbox = sql.NullString{String: record.Mbox, Valid: true}
 	}
+	if record.Refresh != 0 {
+		refresh = sql.NullInt32{Int32: int32(record.Refresh), Valid: true}
+	}
+	if record.Retry != 0 {
+		retry = sql.NullInt32{Int32: int32(record.Retry), Valid: true}
+	}
+	if record.Expire != 0 {
+		expire = sql.NullInt32{Int32: int32(record.Expire), Valid: true}
+	}
+	if record.Minttl != 0 {
+		minttl = sql.NullInt32{Int32: int32(record.Minttl), Valid: true}
+	}
+	if record.Weight != 0 {
+		weight = sql.NullInt16{Int16: int16(record.Weight), Valid: true}
+	}
+	if record.Port != 0 {
+		port = sql.NullInt16{Int16: int16(record.Port), Valid: true}
+	}
+	if record.Tag != "" {
+		tag = sql.NullString{String: record.Tag, Valid: true}
+	}
+
+	var expiresAt, notBefore, notAfter sql.NullTime
+	if record.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *record.ExpiresAt, Valid: true}
+	}
+	if record.NotBefore != nil {
+		notBefore = sql.NullTime{Time: *record.NotBefore, Valid: true}
+	}
+	if record.NotAfter != nil {
+		notAfter = sql.NullTime{Time: *record.NotAfter, Valid: true}
+	}
+
+	labels, comment, owner, err := metadataParams(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var externalKey sql.NullString
+	if record.ExternalKey != "" {
+		externalKey = sql.NullString{String: record.ExternalKey, Valid: true}
+	}
+
+	return []interface{}{
+		record.Name,
+		record.RecordType,
+		record.Target,
+		record.TTL,
+		record.Priority,
+		serial,
+		mbox,
+		refresh,
+		retry,
+		expire,
+		minttl,
+		weight,
+		port,
+		expiresAt,
+		notBefore,
+		notAfter,
+		labels,
+		comment,
+		owner,
+		tag,
+		record.ETLD,
+		record.ApexDomain,
+		record.SubdomainLabels,
+		record.IsWildcard,
+		int64(record.WildcardMask),
+		externalKey,
+	}, nil
 }
 
-// generateSeed creates a deterministic seed based on the query
-func (s *PostgresStorage) generateSeed(query *models.LookupQuery) int64 {
-	h := fnv.New64a()
-	h.Write([]byte(query.Name))
-	h.Write([]byte(query.Type.String()))
-	// Add some time component for variation
-	timeComponent := time.Now().Unix() / 300 // Changes every 5 minutes
-	h.Write([]byte(fmt.Sprintf("%d", timeComponent)))
-	return int64(h.Sum64())
+// metadataParams converts record metadata to query parameters
+func metadataParams(record *models.DNSRecord) (labels string, comment, owner sql.NullString, err error) {
+	labels = "{}"
+	if len(record.Labels) > 0 {
+		encoded, err := json.Marshal(record.Labels)
+		if err != nil {
+			return "", comment, owner, fmt.Errorf("failed to encode labels: %w", err)
+		}
+		labels = string(encoded)
+	}
+
+	if record.Comment != "" {
+		comment = sql.NullString{String: record.Comment, Valid: true}
+	}
+	if record.Owner != "" {
+		owner = sql.NullString{String: record.Owner, Valid: true}
+	}
+
+	return labels, comment, owner, nil
 }
 
-// roundRobinIndex calculates round-robin index based on time and query
-func (s *PostgresStorage) roundRobinIndex(query *models.LookupQuery, count int) int {
-	if count <= 1 {
-		return 0
+// DeleteExpiredRecords deletes records whose expiration time has passed and
+// returns the removed records' IDs, names, and types
+func (s *PostgresStorage) DeleteExpiredRecords(ctx context.Context) ([]*models.DNSRecord, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	sqlQuery := `
+		DELETE FROM dns_records
+		WHERE expires_at IS NOT NULL AND expires_at <= NOW()
+		RETURNING id, name, record_type
+	`
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired records: %w", err)
+	}
+	defer rows.Close()
+
+	var deleted []*models.DNSRecord
+	for rows.Next() {
+		var record models.DNSRecord
+		if err := rows.Scan(&record.ID, &record.Name, &record.RecordType); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted record: %w", err)
+		}
+		deleted = append(deleted, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted records: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// ScheduledChange is a point in time at which a name/type's served records change
+type ScheduledChange struct {
+	Name       string
+	RecordType string
+	At         time.Time
+}
+
+// UpcomingChanges returns activation window boundaries and expirations that
+// fall between now and until, ordered by time
+func (s *PostgresStorage) UpcomingChanges(ctx context.Context, until time.Time) ([]ScheduledChange, error) {
+	sqlQuery := `
+		SELECT name, record_type, at FROM (
+			SELECT name, record_type, not_before AS at FROM dns_records WHERE not_before > NOW() AND not_before <= $1
+			UNION
+			SELECT name, record_type, not_after AS at FROM dns_records WHERE not_after > NOW() AND not_after <= $1
+			UNION
+			SELECT name, record_type, expires_at AS at FROM dns_records WHERE expires_at > NOW() AND expires_at <= $1
+		) AS changes
+		ORDER BY at ASC
+	`
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled changes: %w", err)
 	}
+	defer rows.Close()
 
-	// Create deterministic hash of query
-	h := md5.New()
-	h.Write([]byte(query.Name))
-	h.Write([]byte(query.Type.String()))
-	queryHash := h.Sum(nil)
+	var changes []ScheduledChange
+	for rows.Next() {
+		var change ScheduledChange
+		if err := rows.Scan(&change.Name, &change.RecordType, &change.At); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled change: %w", err)
+		}
+		changes = append(changes, change)
+	}
 
-	// Convert first 8 bytes to uint64
-	queryValue := binary.BigEndian.Uint64(queryHash[:8])
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating scheduled changes: %w", err)
+	}
 
-	// Add time component (changes every 30 seconds for reasonable rotation)
-	timeComponent := uint64(time.Now().Unix() / 30)
+	return changes, nil
+}
 
-	// Combine and mod by count
-	combined := queryValue + timeComponent
-	result := int(combined % uint64(count))
+// Health checks if the database connection is healthy
+func (s *PostgresStorage) Health(ctx context.Context) error {
+	return s.pool.HealthCheck(ctx, s.connectionName)
+}
 
-	// DEBUG: Add this logging
-	log.Printf("RoundRobin DEBUG - queryValue: %d, timeComponent: %d, combined: %d, count: %d, result: %d",
-		queryValue, timeComponent, combined, count, result)
+// Close closes the database connection pool
+func (s *PostgresStorage) Close() error {
+	return s.pool.Close()
+}
+
+// WriteQueryStats inserts one period's per-zone/per-type query counts,
+// sending every row in one batch so the period is stored in a single round
+// trip, and all or nothing
+func (s *PostgresStorage) WriteQueryStats(ctx context.Context, periodStart, periodEnd time.Time, counts []stats.QueryCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, count := range counts {
+		batch.Queue(insertQueryStats,
+			periodStart,
+			periodEnd,
+			count.Zone,
+			count.RecordType,
+			count.Queries,
+			count.Answered,
+			count.NXDomain,
+			count.Errors,
+		)
+	}
 
-	return result
+	return s.pool.SendBatch(ctx, s.connectionName, batch, func(results pgx.BatchResults) error {
+		for _, count := range counts {
+			if _, err := results.Exec(); err != nil {
+				return fmt.Errorf("failed to insert query stats for %s %s: %w", count.Zone, count.RecordType, err)
+			}
+		}
+		return nil
+	})
 }
 
+// insertQueryStats stores one zone and type's counts for a period
+const insertQueryStats = `
+	INSERT INTO dns_query_stats
+		(
+			period_start,
+			period_end,
+			zone,
+			record_type,
+			queries,
+			answered,
+			nxdomain,
+			errors
+		)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`
+
 // InitializeSchema creates the DNS records table using a schema file
 func (s *PostgresStorage) InitializeSchema(ctx context.Context, schemaFilePath string) error {
 	return s.pool.ExecSchemaFile(ctx, s.connectionName, schemaFilePath)
@@ -0,0 +1,228 @@
+// internal/storage/postgres_bench_test.go
+package storage
+
+// These benchmarks need a database with schemas/postgresql.sql applied,
+// named by ERRANTDNS_BENCH_DB and reached with the standard PGHOST, PGPORT,
+// PGUSER, and PGPASSWORD settings. They're skipped otherwise:
+//
+//	ERRANTDNS_BENCH_DB=dnsdb go test ./internal/storage -run '^$' -bench . -benchmem
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"errantdns.io/internal/models"
+	"errantdns.io/internal/pgsqlpool"
+	"errantdns.io/internal/stats"
+)
+
+// benchGroupColumns are the columns the round trip benchmarks fetch
+const benchGroupColumns = `id, name, record_type, target, ttl, priority`
+
+// benchLiveFilter keeps only the records being served
+const benchLiveFilter = `
+	AND (expires_at IS NULL OR expires_at > NOW())
+	AND (not_before IS NULL OR not_before <= NOW())
+	AND (not_after IS NULL OR not_after > NOW())
+`
+
+// benchStorage connects to the benchmark database, keeping cacheSize
+// prepared statements per connection
+func benchStorage(b *testing.B, cacheSize int) *PostgresStorage {
+	b.Helper()
+
+	dbName := os.Getenv("ERRANTDNS_BENCH_DB")
+	if dbName == "" {
+		b.Skip("ERRANTDNS_BENCH_DB is not set")
+	}
+
+	config := DefaultConfig()
+	config.DBName = dbName
+	config.StatementCacheSize = cacheSize
+	config.WildcardLookups = false
+	if env := os.Getenv("PGHOST"); env != "" {
+		config.Host = env
+	}
+	if env := os.Getenv("PGPORT"); env != "" {
+		port, err := strconv.Atoi(env)
+		if err != nil {
+			b.Fatalf("invalid PGPORT: %v", err)
+		}
+		config.Port = port
+	}
+	config.User = os.Getenv("PGUSER")
+	if config.User == "" {
+		config.User = "postgres"
+	}
+	config.Password = os.Getenv("PGPASSWORD")
+
+	s, err := NewPostgresStorage(context.Background(), pgsqlpool.NewPool(), "bench", config, nil)
+	if err != nil {
+		b.Fatalf("failed to connect: %v", err)
+	}
+	b.Cleanup(func() { s.Close() })
+	return s
+}
+
+// benchGroup stores a name with two records at the lowest priority and one
+// above it, removed when the benchmark ends
+func benchGroup(b *testing.B, s *PostgresStorage) *models.LookupQuery {
+	b.Helper()
+	ctx := context.Background()
+
+	query := models.NewLookupQuery(fmt.Sprintf("group.bench-%d.example.com", time.Now().UnixNano()), "A")
+	records := []*models.DNSRecord{
+		{Name: query.Name, RecordType: "A", Target: "192.0.2.1", TTL: 300, Priority: 10},
+		{Name: query.Name, RecordType: "A", Target: "192.0.2.2", TTL: 300, Priority: 10},
+		{Name: query.Name, RecordType: "A", Target: "192.0.2.3", TTL: 300, Priority: 20},
+	}
+	if err := s.CreateRecords(ctx, records); err != nil {
+		b.Fatalf("failed to create records: %v", err)
+	}
+	b.Cleanup(func() { s.DeleteRecords(ctx, query.Name, "A") })
+	return query
+}
+
+// BenchmarkLookupRecordGroup compares group lookups over cached prepared
+// statements, exchanging binary values in one round trip, with lookups
+// that describe every statement first
+func BenchmarkLookupRecordGroup(b *testing.B) {
+	for _, bench := range []struct {
+		name      string
+		cacheSize int
+	}{
+		{"cached", pgsqlpool.DefaultStatementCacheSize},
+		{"uncached", 0},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			s := benchStorage(b, bench.cacheSize)
+			query := benchGroup(b, s)
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				records, err := s.LookupRecordGroup(ctx, query)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(records) != 2 {
+					b.Fatalf("got %d records, want 2", len(records))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRecordGroupRoundTrips compares fetching a record group with the
+// lowest priority found in a subquery against finding it in a query of its
+// own first
+func BenchmarkRecordGroupRoundTrips(b *testing.B) {
+	s := benchStorage(b, pgsqlpool.DefaultStatementCacheSize)
+	query := benchGroup(b, s)
+	ctx := context.Background()
+
+	oneQuery := `SELECT ` + benchGroupColumns + ` FROM dns_records
+		WHERE LOWER(name) = LOWER($1) AND record_type = $2` + benchLiveFilter + `
+			AND priority = (
+				SELECT MIN(priority) FROM dns_records
+				WHERE LOWER(name) = LOWER($1) AND record_type = $2` + benchLiveFilter + `
+			)
+		ORDER BY id ASC`
+	minPriority := `SELECT MIN(priority) FROM dns_records
+		WHERE LOWER(name) = LOWER($1) AND record_type = $2` + benchLiveFilter
+	atPriority := `SELECT ` + benchGroupColumns + ` FROM dns_records
+		WHERE LOWER(name) = LOWER($1) AND record_type = $2 AND priority = $3` + benchLiveFilter + `
+		ORDER BY id ASC`
+
+	b.Run("one", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			benchScanGroup(b, s, oneQuery, query.Name, query.Type.String())
+		}
+	})
+
+	b.Run("two", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var priority sql.NullInt32
+			err := s.pool.QueryRow(ctx, s.connectionName, minPriority, query.Name, query.Type.String()).Scan(&priority)
+			if err != nil {
+				b.Fatal(err)
+			}
+			benchScanGroup(b, s, atPriority, query.Name, query.Type.String(), priority.Int32)
+		}
+	})
+}
+
+// benchScanGroup runs a record group query, checking it found the group
+func benchScanGroup(b *testing.B, s *PostgresStorage, sqlQuery string, args ...interface{}) {
+	rows, err := s.pool.Query(context.Background(), s.connectionName, sqlQuery, args...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rows.Close()
+
+	found := 0
+	for rows.Next() {
+		var record models.DNSRecord
+		if err := rows.Scan(&record.ID, &record.Name, &record.RecordType, &record.Target, &record.TTL, &record.Priority); err != nil {
+			b.Fatal(err)
+		}
+		found++
+	}
+	if err := rows.Err(); err != nil {
+		b.Fatal(err)
+	}
+	if found != 2 {
+		b.Fatalf("got %d records, want 2", found)
+	}
+}
+
+// BenchmarkWriteQueryStats compares writing a period's counts as one batch
+// against a statement per row in a transaction
+func BenchmarkWriteQueryStats(b *testing.B) {
+	s := benchStorage(b, pgsqlpool.DefaultStatementCacheSize)
+	ctx := context.Background()
+
+	zone := fmt.Sprintf("bench-%d.example.com.", time.Now().UnixNano())
+	b.Cleanup(func() {
+		s.pool.Exec(ctx, s.connectionName, `DELETE FROM dns_query_stats WHERE zone = $1`, zone)
+	})
+
+	var counts []stats.QueryCount
+	for _, recordType := range []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "SOA", "SRV", "TXT", "CAA"} {
+		counts = append(counts, stats.QueryCount{Zone: zone, RecordType: recordType, Queries: 100, Answered: 90, NXDomain: 8, Errors: 2})
+	}
+	end := time.Now()
+	start := end.Add(-time.Minute)
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := s.WriteQueryStats(ctx, start, end, counts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("statements", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := s.pool.Transaction(ctx, s.connectionName, func(tx *sql.Tx) error {
+				for _, count := range counts {
+					_, err := tx.ExecContext(ctx, insertQueryStats, start, end,
+						count.Zone, count.RecordType, count.Queries, count.Answered, count.NXDomain, count.Errors)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,29 @@
+// internal/storage/readonly.go
+package storage
+
+import (
+	"errors"
+)
+
+// ErrReadOnly is returned by record writes while storage is read-only
+var ErrReadOnly = errors.New("storage is read-only for maintenance")
+
+// SetReadOnly switches read-only mode. While it's on, writes to stored
+// records fail with ErrReadOnly and lookups are served as usual. Writes
+// already running finish
+func (s *PostgresStorage) SetReadOnly(readOnly bool) {
+	s.readOnly.Store(readOnly)
+}
+
+// ReadOnly reports whether record writes are rejected
+func (s *PostgresStorage) ReadOnly() bool {
+	return s.readOnly.Load()
+}
+
+// checkWritable fails with ErrReadOnly while storage is read-only
+func (s *PostgresStorage) checkWritable() error {
+	if s.readOnly.Load() {
+		return ErrReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// internal/storage/reaper.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ExpiredRecordDeleter removes records past their expiration time
+type ExpiredRecordDeleter interface {
+	DeleteExpiredRecords(ctx context.Context) ([]*models.DNSRecord, error)
+}
+
+// Reaper periodically deletes expired records. Lookups already ignore
+// expired rows, so the reaper only keeps the table from accumulating them
+type Reaper struct {
+	deleter  ExpiredRecordDeleter
+	interval time.Duration
+}
+
+// NewReaper creates a reaper running every interval
+func NewReaper(deleter ExpiredRecordDeleter, interval time.Duration) *Reaper {
+	return &Reaper{
+		deleter:  deleter,
+		interval: interval,
+	}
+}
+
+// Run reaps on every tick until the context is cancelled
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reap(ctx)
+		}
+	}
+}
+
+// Reap deletes expired records once and logs what was removed
+func (r *Reaper) Reap(ctx context.Context) {
+	deleted, err := r.deleter.DeleteExpiredRecords(ctx)
+	if errors.Is(err, ErrReadOnly) {
+		logging.Debug("storage", "Skipped deleting expired records while read-only")
+		return
+	}
+	if err != nil {
+		logging.Error("storage", "Failed to delete expired records", err)
+		return
+	}
+
+	if len(deleted) == 0 {
+		return
+	}
+
+	logging.Info("storage", "Deleted expired records", "count", len(deleted))
+	for _, record := range deleted {
+		logging.Debug("storage", "Deleted expired record", "id", record.ID, "name", record.Name, "type", record.RecordType)
+	}
+}
@@ -4,11 +4,16 @@ package storage
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"errantdns.io/internal/cache"
+	"errantdns.io/internal/logging"
 	"errantdns.io/internal/models"
 	"errantdns.io/internal/redis"
+	"errantdns.io/internal/selection"
 )
 
 // RedisCacheStorage wraps existing cached storage with Redis as L2 cache
@@ -17,7 +22,14 @@ type RedisCacheStorage struct {
 	memoryCache cache.Cache
 	redisClient string
 	keyPrefix   string
-	tieBreaker  string
+	strategy    selection.Strategy
+	compress    bool // DEFLATE-compress cache entries
+
+	// Redis operations are abandoned after timeout and treated as misses
+	timeout  time.Duration
+	timeouts atomic.Int64
+
+	undecoded atomic.Int64
 }
 
 // CacheStats represents comprehensive cache statistics for three-tier caching
@@ -29,18 +41,22 @@ type CacheStats struct {
 
 // RedisStats represents Redis-specific cache statistics
 type RedisStats struct {
-	Connected bool `json:"connected"`
-	KeyCount  int  `json:"key_count"`
+	Connected bool  `json:"connected"`
+	KeyCount  int   `json:"key_count"`
+	Timeouts  int64 `json:"timeouts"`
+	Undecoded int64 `json:"undecoded"` // entries in an old or corrupt encoding, treated as misses
 }
 
 // NewRedisCacheStorage creates a new Redis-backed cache storage
-func NewRedisCacheStorage(storage Storage, memoryCache cache.Cache, redisClientName, keyPrefix, tieBreaker string) *RedisCacheStorage {
+func NewRedisCacheStorage(storage Storage, memoryCache cache.Cache, redisClientName, keyPrefix string, strategy selection.Strategy, timeout time.Duration, compress bool) *RedisCacheStorage {
 	return &RedisCacheStorage{
 		storage:     storage,
 		memoryCache: memoryCache,
 		redisClient: redisClientName,
 		keyPrefix:   keyPrefix,
-		tieBreaker:  tieBreaker,
+		strategy:    strategy,
+		compress:    compress,
+		timeout:     timeout,
 	}
 }
 
@@ -51,6 +67,8 @@ func (rcs *RedisCacheStorage) GetCacheStats() CacheStats {
 	redisStats := RedisStats{
 		Connected: redis.PingClient(rcs.redisClient) == nil,
 		KeyCount:  rcs.getRedisKeyCount(),
+		Timeouts:  rcs.timeouts.Load(),
+		Undecoded: rcs.undecoded.Load(),
 	}
 
 	return CacheStats{
@@ -60,6 +78,11 @@ func (rcs *RedisCacheStorage) GetCacheStats() CacheStats {
 	}
 }
 
+// ResetCacheStats zeroes the memory cache statistics counters
+func (rcs *RedisCacheStorage) ResetCacheStats() {
+	rcs.memoryCache.ResetStats()
+}
+
 // ClearCache clears both memory and Redis cache layers
 func (rcs *RedisCacheStorage) ClearCache() {
 	// Clear L1 (memory cache)
@@ -80,16 +103,29 @@ func (rcs *RedisCacheStorage) getRedisKeyCount() int {
 }
 
 // clearRedisCache removes all keys with our prefix from Redis
-func (rcs *RedisCacheStorage) clearRedisCache() {
+func (rcs *RedisCacheStorage) clearRedisCache() error {
 	pattern := rcs.keyPrefix + "*"
 	keys, err := redis.ScanFrom(rcs.redisClient, pattern)
 	if err != nil {
-		return
+		return fmt.Errorf("failed to scan Redis cache: %w", err)
 	}
 
 	if len(keys) > 0 {
-		redis.DeleteOn(rcs.redisClient, keys...)
+		return redis.DeleteOn(rcs.redisClient, keys...)
 	}
+	return nil
+}
+
+// escapeGlob escapes the characters Redis key patterns treat specially
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\^`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // LookupRecordWithSource implements three-tier caching with source tracking
@@ -97,21 +133,23 @@ func (rcs *RedisCacheStorage) LookupRecordWithSource(ctx context.Context, query
 	cacheKey := rcs.getCacheKey(query)
 
 	// L1: Check memory cache first
-	if records, found := rcs.memoryCache.Get(cacheKey); found && len(records) > 0 {
+	if records, found := rcs.memoryCache.Get(cacheKey); found && hasLiveRecords(&records) {
+		logLookup(ctx, query, SourceMemory)
 		return &LookupResult{
-			Record: rcs.selectFromArray(records, query),
+			Record: selection.Select(rcs.strategy, records, query),
 			Source: SourceMemory,
 		}, nil
 	}
 
 	// L2: Check Redis cache
 	var records []*models.DNSRecord
-	if err := redis.GetJSONFrom(rcs.redisClient, cacheKey, &records); err == nil && len(records) > 0 {
+	if err := rcs.getRecords(ctx, cacheKey, &records); err == nil && hasLiveRecords(&records) {
 		// Cache hit in Redis - populate memory cache
+		logLookup(ctx, query, SourceRedis)
 		ttl := time.Duration(records[0].TTL/10) * time.Second
 		rcs.memoryCache.Set(cacheKey, records, ttl)
 		return &LookupResult{
-			Record: rcs.selectFromArray(records, query),
+			Record: selection.Select(rcs.strategy, records, query),
 			Source: SourceRedis,
 		}, nil
 	}
@@ -127,15 +165,15 @@ func (rcs *RedisCacheStorage) LookupRecordWithSource(ctx context.Context, query
 	}
 
 	// Populate both cache layers
+	logLookup(ctx, query, SourceDatabase)
 	l1TTL := time.Duration(records[0].TTL/10) * time.Second
 	l2TTL := time.Duration(records[0].TTL/2) * time.Second
 
 	rcs.memoryCache.Set(cacheKey, records, l1TTL)
-	redis.SetJSONOn(rcs.redisClient, cacheKey, records)
-	redis.ExpireOn(rcs.redisClient, cacheKey, int(l2TTL.Seconds()))
+	rcs.storeRecords(ctx, cacheKey, records, l2TTL)
 
 	return &LookupResult{
-		Record: rcs.selectFromArray(records, query),
+		Record: selection.Select(rcs.strategy, records, query),
 		Source: SourceDatabase,
 	}, nil
 }
@@ -145,7 +183,8 @@ func (rcs *RedisCacheStorage) LookupRecordGroupWithSource(ctx context.Context, q
 	cacheKey := rcs.getCacheKey(query)
 
 	// L1: Check memory cache first
-	if records, found := rcs.memoryCache.Get(cacheKey); found && len(records) > 0 {
+	if records, found := rcs.memoryCache.Get(cacheKey); found && hasLiveRecords(&records) {
+		logLookup(ctx, query, SourceMemory)
 		return &LookupGroupResult{
 			Records: records,
 			Source:  SourceMemory,
@@ -154,8 +193,9 @@ func (rcs *RedisCacheStorage) LookupRecordGroupWithSource(ctx context.Context, q
 
 	// L2: Check Redis cache
 	var records []*models.DNSRecord
-	if err := redis.GetJSONFrom(rcs.redisClient, cacheKey, &records); err == nil && len(records) > 0 {
+	if err := rcs.getRecords(ctx, cacheKey, &records); err == nil && hasLiveRecords(&records) {
 		// Cache hit in Redis - populate memory cache
+		logLookup(ctx, query, SourceRedis)
 		ttl := time.Duration(records[0].TTL/10) * time.Second
 		rcs.memoryCache.Set(cacheKey, records, ttl)
 		return &LookupGroupResult{
@@ -175,12 +215,12 @@ func (rcs *RedisCacheStorage) LookupRecordGroupWithSource(ctx context.Context, q
 	}
 
 	// Populate both cache layers
+	logLookup(ctx, query, SourceDatabase)
 	l1TTL := time.Duration(records[0].TTL/10) * time.Second
 	l2TTL := time.Duration(records[0].TTL/2) * time.Second
 
 	rcs.memoryCache.Set(cacheKey, records, l1TTL)
-	redis.SetJSONOn(rcs.redisClient, cacheKey, records)
-	redis.ExpireOn(rcs.redisClient, cacheKey, int(l2TTL.Seconds()))
+	rcs.storeRecords(ctx, cacheKey, records, l2TTL)
 
 	return &LookupGroupResult{
 		Records: records,
@@ -193,17 +233,19 @@ func (rcs *RedisCacheStorage) LookupRecord(ctx context.Context, query *models.Lo
 	cacheKey := rcs.getCacheKey(query)
 
 	// L1: Check memory cache first
-	if records, found := rcs.memoryCache.Get(cacheKey); found && len(records) > 0 {
-		return rcs.selectFromArray(records, query), nil
+	if records, found := rcs.memoryCache.Get(cacheKey); found && hasLiveRecords(&records) {
+		logLookup(ctx, query, SourceMemory)
+		return selection.Select(rcs.strategy, records, query), nil
 	}
 
 	// L2: Check Redis cache
 	var records []*models.DNSRecord
-	if err := redis.GetJSONFrom(rcs.redisClient, cacheKey, &records); err == nil && len(records) > 0 {
+	if err := rcs.getRecords(ctx, cacheKey, &records); err == nil && hasLiveRecords(&records) {
 		// Cache hit in Redis - populate memory cache
+		logLookup(ctx, query, SourceRedis)
 		ttl := time.Duration(records[0].TTL/10) * time.Second // 10% of record TTL for L1
 		rcs.memoryCache.Set(cacheKey, records, ttl)
-		return rcs.selectFromArray(records, query), nil
+		return selection.Select(rcs.strategy, records, query), nil
 	}
 
 	// L3: Cache miss - query storage
@@ -217,19 +259,51 @@ func (rcs *RedisCacheStorage) LookupRecord(ctx context.Context, query *models.Lo
 	}
 
 	// Populate both cache layers
+	logLookup(ctx, query, SourceDatabase)
 	l1TTL := time.Duration(records[0].TTL/10) * time.Second // 10% for L1
 	l2TTL := time.Duration(records[0].TTL/2) * time.Second  // 50% for L2
 
 	rcs.memoryCache.Set(cacheKey, records, l1TTL)
-	redis.SetJSONOn(rcs.redisClient, cacheKey, records) // Use JSON for complex objects
-	redis.ExpireOn(rcs.redisClient, cacheKey, int(l2TTL.Seconds()))
+	rcs.storeRecords(ctx, cacheKey, records, l2TTL)
 
-	return rcs.selectFromArray(records, query), nil
+	return selection.Select(rcs.strategy, records, query), nil
 }
 
-// LookupRecords queries storage directly (no caching for multiple records)
+// LookupRecords caches full RRsets for SRV, MX, and NS, which are answered
+// with every record, in both layers. Other types query storage directly
 func (rcs *RedisCacheStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
-	return rcs.storage.LookupRecords(ctx, query)
+	if !cachesRRset(query.Type) {
+		return rcs.storage.LookupRecords(ctx, query)
+	}
+
+	cacheKey := rcs.keyPrefix + rrsetCacheKey(query)
+
+	// L1: Check memory cache first
+	if records, found := rcs.memoryCache.Get(cacheKey); found && hasLiveRecords(&records) {
+		logLookup(ctx, query, SourceMemory)
+		return records, nil
+	}
+
+	// L2: Check Redis cache
+	var records []*models.DNSRecord
+	if err := rcs.getRecords(ctx, cacheKey, &records); err == nil && hasLiveRecords(&records) {
+		logLookup(ctx, query, SourceRedis)
+		rcs.memoryCache.Set(cacheKey, records, rrsetTTL(records)/10)
+		return records, nil
+	}
+
+	// L3: Cache miss - query storage
+	records, err := rcs.storage.LookupRecords(ctx, query)
+	if err != nil || len(records) == 0 {
+		return records, err
+	}
+
+	logLookup(ctx, query, SourceDatabase)
+	ttl := rrsetTTL(records)
+	rcs.memoryCache.Set(cacheKey, records, ttl/10)
+	rcs.storeRecords(ctx, cacheKey, records, ttl/2)
+
+	return records, nil
 }
 
 // LookupRecordGroup queries with caching
@@ -237,14 +311,16 @@ func (rcs *RedisCacheStorage) LookupRecordGroup(ctx context.Context, query *mode
 	cacheKey := rcs.getCacheKey(query)
 
 	// L1: Check memory cache first
-	if records, found := rcs.memoryCache.Get(cacheKey); found && len(records) > 0 {
+	if records, found := rcs.memoryCache.Get(cacheKey); found && hasLiveRecords(&records) {
+		logLookup(ctx, query, SourceMemory)
 		return records, nil
 	}
 
 	// L2: Check Redis cache
 	var records []*models.DNSRecord
-	if err := redis.GetJSONFrom(rcs.redisClient, cacheKey, &records); err == nil && len(records) > 0 {
+	if err := rcs.getRecords(ctx, cacheKey, &records); err == nil && hasLiveRecords(&records) {
 		// Cache hit in Redis - populate memory cache
+		logLookup(ctx, query, SourceRedis)
 		ttl := time.Duration(records[0].TTL/10) * time.Second
 		rcs.memoryCache.Set(cacheKey, records, ttl)
 		return records, nil
@@ -261,12 +337,12 @@ func (rcs *RedisCacheStorage) LookupRecordGroup(ctx context.Context, query *mode
 	}
 
 	// Populate both cache layers
+	logLookup(ctx, query, SourceDatabase)
 	l1TTL := time.Duration(records[0].TTL/10) * time.Second
 	l2TTL := time.Duration(records[0].TTL/2) * time.Second
 
 	rcs.memoryCache.Set(cacheKey, records, l1TTL)
-	redis.SetJSONOn(rcs.redisClient, cacheKey, records)
-	redis.ExpireOn(rcs.redisClient, cacheKey, int(l2TTL.Seconds()))
+	rcs.storeRecords(ctx, cacheKey, records, l2TTL)
 
 	return records, nil
 }
@@ -289,9 +365,59 @@ func (rcs *RedisCacheStorage) UpdateRecord(ctx context.Context, record *models.D
 	return nil
 }
 
-// DeleteRecord deletes a record and invalidates cache
+// UpsertRecord creates or updates a record and invalidates cache
+func (rcs *RedisCacheStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	previous := keyHolder(ctx, rcs.storage, record)
+	created, err := rcs.storage.UpsertRecord(ctx, record)
+	if err != nil {
+		return false, err
+	}
+	if previous != nil {
+		rcs.invalidateRecord(previous)
+	}
+	rcs.invalidateRecord(record)
+	return created, nil
+}
+
+// CreateRecords creates records in one transaction and invalidates cache,
+// deleting every affected Redis key in a few round trips
+func (rcs *RedisCacheStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	if err := rcs.storage.CreateRecords(ctx, records); err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, key := range recordNameTypes(records) {
+		query := models.NewLookupQuery(key.name, key.recordType)
+		groupKey := rcs.getCacheKey(query)
+		rrsetKey := rcs.keyPrefix + rrsetCacheKey(query)
+		rcs.memoryCache.Delete(groupKey)
+		rcs.memoryCache.Delete(rrsetKey)
+		keys = append(keys, groupKey, rrsetKey)
+	}
+	for start := 0; start < len(keys); start += bulkInsertRows {
+		redis.DeleteOn(rcs.redisClient, keys[start:min(start+bulkInsertRows, len(keys))]...)
+	}
+	return nil
+}
+
+// GetRecordByID fetches a record from storage
+func (rcs *RedisCacheStorage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	return rcs.storage.GetRecordByID(ctx, id)
+}
+
+// DeleteRecord deletes a record and invalidates cache. The record is read
+// first to learn which name and type it was cached under
 func (rcs *RedisCacheStorage) DeleteRecord(ctx context.Context, id int) error {
-	return rcs.storage.DeleteRecord(ctx, id)
+	record, err := rcs.storage.GetRecordByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := rcs.storage.DeleteRecord(ctx, id); err != nil {
+		return err
+	}
+	rcs.invalidateRecord(record)
+	return nil
 }
 
 // DeleteRecords deletes records and invalidates cache
@@ -307,6 +433,21 @@ func (rcs *RedisCacheStorage) DeleteRecords(ctx context.Context, name string, re
 	return nil
 }
 
+// ListRecords lists records from storage; listings aren't cached
+func (rcs *RedisCacheStorage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	return rcs.storage.ListRecords(ctx, filter)
+}
+
+// SearchRecords searches records in storage
+func (rcs *RedisCacheStorage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	return rcs.storage.SearchRecords(ctx, search)
+}
+
+// CountRecords counts records in storage
+func (rcs *RedisCacheStorage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	return rcs.storage.CountRecords(ctx, filter)
+}
+
 // Health checks storage, memory cache, and Redis
 func (rcs *RedisCacheStorage) Health(ctx context.Context) error {
 	if err := rcs.storage.Health(ctx); err != nil {
@@ -328,23 +469,118 @@ func (rcs *RedisCacheStorage) Close() error {
 	return nil
 }
 
+// Invalidate drops any cached entries for a name/type combination from both layers
+func (rcs *RedisCacheStorage) Invalidate(name, recordType string) {
+	rcs.invalidateNameType(name, recordType)
+}
+
+// Flush drops the cached entries a flush selects from both layers
+func (rcs *RedisCacheStorage) Flush(ctx context.Context, f Flush) error {
+	f = f.normalize()
+	if f.All {
+		rcs.memoryCache.Clear()
+	} else {
+		rcs.memoryCache.DeleteFunc(f.matches)
+	}
+	if f.localOnly {
+		return nil
+	}
+
+	switch {
+	case f.All:
+		return rcs.clearRedisCache()
+	case f.Type != "":
+		query := models.NewLookupQuery(f.Name, f.Type)
+		return redis.DeleteOn(rcs.redisClient, rcs.getCacheKey(query), rcs.keyPrefix+rrsetCacheKey(query))
+	}
+
+	// Keys end in "name:TYPE"; the scan narrows by name and matches filters
+	// out names that merely end the same way
+	name := f.Name
+	if f.Apex != "" {
+		name = f.Apex
+	}
+	keys, err := redis.ScanFrom(rcs.redisClient, rcs.keyPrefix+"*"+escapeGlob(name)+":*")
+	if err != nil {
+		return fmt.Errorf("failed to scan Redis cache: %w", err)
+	}
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		return !f.matches(key)
+	})
+	if len(keys) == 0 {
+		return nil
+	}
+	return redis.DeleteOn(rcs.redisClient, keys...)
+}
+
 // Helper methods
+
+// getRecords reads the records cached in Redis under key, giving up after
+// the Redis timeout
+func (rcs *RedisCacheStorage) getRecords(ctx context.Context, key string, records *[]*models.DNSRecord) error {
+	ctx, cancel := withTimeout(ctx, rcs.timeout)
+	defer cancel()
+
+	data, err := redis.GetFromContext(ctx, rcs.redisClient, key)
+	rcs.noteTimeout(ctx, err, "get", key)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := decodeRecords(data)
+	if err != nil {
+		rcs.undecoded.Add(1)
+		if logging.Enabled(logging.LevelDebug) {
+			logging.DebugContext(ctx, "storage", "Ignoring undecodable Redis cache entry", "key", key, "error", err.Error())
+		}
+		return err
+	}
+	*records = decoded
+	return nil
+}
+
+// storeRecords caches records in Redis under key for ttl. The write isn't
+// canceled with the request that triggered it, but is still bounded by the
+// Redis timeout
+func (rcs *RedisCacheStorage) storeRecords(ctx context.Context, key string, records []*models.DNSRecord, ttl time.Duration) {
+	writeCtx, cancel := withTimeout(context.WithoutCancel(ctx), rcs.timeout)
+	defer cancel()
+
+	data, err := encodeRecords(records, rcs.compress)
+	if err != nil {
+		logging.ErrorContext(ctx, "storage", "Failed to encode records for Redis", err, "key", key)
+		return
+	}
+
+	// A zero expiration would store the key without a TTL
+	seconds := max(int(ttl.Seconds()), 1)
+	err = redis.SetEXOnContext(writeCtx, rcs.redisClient, key, data, seconds)
+	rcs.noteTimeout(ctx, err, "set", key)
+}
+
+// noteTimeout counts and logs err if it was a Redis operation timing out
+func (rcs *RedisCacheStorage) noteTimeout(ctx context.Context, err error, op, key string) {
+	if err == nil || !isTimeout(err) {
+		return
+	}
+	rcs.timeouts.Add(1)
+	logging.WarnContext(ctx, "storage", "Redis operation timed out", "op", op, "key", key, "timeout", rcs.timeout)
+}
 func (rcs *RedisCacheStorage) getCacheKey(query *models.LookupQuery) string {
 	return rcs.keyPrefix + query.CacheKey()
 }
 
 func (rcs *RedisCacheStorage) invalidateRecord(record *models.DNSRecord) {
-	query := models.NewLookupQuery(record.Name, record.RecordType)
-	cacheKey := rcs.getCacheKey(query)
-	rcs.memoryCache.Delete(cacheKey)
-	redis.DeleteOn(rcs.redisClient, cacheKey)
+	rcs.invalidateNameType(record.Name, record.RecordType)
 }
 
 func (rcs *RedisCacheStorage) invalidateNameType(name, recordType string) {
 	query := models.NewLookupQuery(name, recordType)
-	cacheKey := rcs.getCacheKey(query)
-	rcs.memoryCache.Delete(cacheKey)
-	redis.DeleteOn(rcs.redisClient, cacheKey)
+	groupKey := rcs.getCacheKey(query)
+	rrsetKey := rcs.keyPrefix + rrsetCacheKey(query)
+	rcs.memoryCache.Delete(groupKey)
+	rcs.memoryCache.Delete(rrsetKey)
+	redis.DeleteOn(rcs.redisClient, groupKey, rrsetKey)
 }
 
 func (rcs *RedisCacheStorage) invalidateDomain(name string) {
@@ -352,6 +588,8 @@ func (rcs *RedisCacheStorage) invalidateDomain(name string) {
 		models.RecordTypeA, models.RecordTypeAAAA, models.RecordTypeCNAME,
 		models.RecordTypeTXT, models.RecordTypeMX, models.RecordTypeNS,
 		models.RecordTypeSOA, models.RecordTypePTR, models.RecordTypeSRV, models.RecordTypeCAA,
+		models.RecordTypeDS, models.RecordTypeDNSKEY, models.RecordTypeCDS, models.RecordTypeCDNSKEY,
+		models.RecordTypeDNAME,
 	}
 
 	for _, recordType := range commonTypes {
@@ -359,15 +597,9 @@ func (rcs *RedisCacheStorage) invalidateDomain(name string) {
 	}
 }
 
-func (rcs *RedisCacheStorage) selectFromArray(records []*models.DNSRecord, query *models.LookupQuery) *models.DNSRecord {
-	if len(records) == 0 {
-		return nil
-	}
-	if len(records) == 1 {
-		return records[0]
-	}
-
-	// Simple round-robin for now
-	// TODO: Use the same tie-breaking logic as the original cached storage
-	return records[0]
+// hasLiveRecords drops inactive records from a cached group in place and
+// reports whether any remain. An all-inactive group falls through to storage
+func hasLiveRecords(records *[]*models.DNSRecord) bool {
+	*records = models.LiveRecords(*records)
+	return len(*records) > 0
 }
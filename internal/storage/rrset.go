@@ -0,0 +1,36 @@
+// internal/storage/rrset.go
+package storage
+
+import (
+	"time"
+
+	"errantdns.io/internal/models"
+)
+
+// cachesRRset reports whether full RRset lookups of recordType are cached.
+// These are the types answered with every record rather than one selected
+// from the lowest priority group
+func cachesRRset(recordType models.RecordType) bool {
+	switch recordType {
+	case models.RecordTypeSRV, models.RecordTypeMX, models.RecordTypeNS,
+		models.RecordTypeDS, models.RecordTypeDNSKEY, models.RecordTypeCDS, models.RecordTypeCDNSKEY:
+		return true
+	}
+	return false
+}
+
+// rrsetCacheKey returns the cache key for a full RRset lookup, kept apart
+// from the lowest priority group cached for the same name and type
+func rrsetCacheKey(query *models.LookupQuery) string {
+	return "rrset:" + query.CacheKey()
+}
+
+// rrsetTTL returns the lowest TTL in an RRset, so no record is cached
+// longer than its own TTL allows
+func rrsetTTL(records []*models.DNSRecord) time.Duration {
+	ttl := records[0].TTL
+	for _, record := range records[1:] {
+		ttl = min(ttl, record.TTL)
+	}
+	return time.Duration(ttl) * time.Second
+}
@@ -0,0 +1,81 @@
+// internal/storage/rrsetttl.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"errantdns.io/internal/models"
+)
+
+// RRset TTL modes choose what writes do about an RRset, the records of one
+// name and type, whose records have different TTLs. Answers always carry
+// the lowest TTL of the records served (RFC 2181 section 5.2), whatever
+// the mode
+const (
+	RRSetTTLOff     = "off"     // stored TTLs are left alone
+	RRSetTTLEnforce = "enforce" // writes that leave an RRset with mixed TTLs fail
+	RRSetTTLAlign   = "align"   // writes give the record's whole RRset its TTL
+)
+
+// ErrTTLMismatch is returned in RRSetTTLEnforce mode when a write would
+// leave an RRset with records of different TTLs
+var ErrTTLMismatch = errors.New("TTL mismatch")
+
+// harmonizeTTLs applies the RRset TTL mode to the RRsets of records, which
+// were just written by the transaction q. When aligning, later records win;
+// when enforcing, each RRset is checked once every record is written, so
+// one transaction can change the TTL of a whole RRset
+func (s *PostgresStorage) harmonizeTTLs(ctx context.Context, q dbtx, records []*models.DNSRecord) error {
+	switch s.rrsetTTL {
+	case RRSetTTLAlign:
+		for _, record := range records {
+			_, err := q.ExecContext(ctx, `
+				UPDATE dns_records
+				SET ttl = $3, version = version + 1, updated_at = NOW()
+				WHERE LOWER(name) = LOWER($1) AND record_type = $2 AND ttl <> $3
+			`, record.Name, record.RecordType, record.TTL)
+			if err != nil {
+				return fmt.Errorf("failed to align TTLs of %s %s: %w", record.Name, record.RecordType, err)
+			}
+		}
+
+	case RRSetTTLEnforce:
+		for _, key := range recordNameTypes(records) {
+			var low, high uint32
+			err := q.QueryRowContext(ctx, `
+				SELECT MIN(ttl), MAX(ttl)
+				FROM dns_records
+				WHERE LOWER(name) = LOWER($1) AND record_type = $2
+					AND (expires_at IS NULL OR expires_at > NOW())
+				HAVING MIN(ttl) <> MAX(ttl)
+			`, key.name, key.recordType).Scan(&low, &high)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to check TTLs of %s %s: %w", key.name, key.recordType, err)
+			}
+			return fmt.Errorf("%w: %s %s would have records with TTLs from %d to %d; change them together", ErrTTLMismatch, key.name, key.recordType, low, high)
+		}
+	}
+	return nil
+}
+
+// writeRecord runs write, a write of record, in a transaction, which holds
+// the name lock its conflict check takes, along with harmonizeTTLs when an
+// RRset TTL mode needs it
+func (s *PostgresStorage) writeRecord(ctx context.Context, record *models.DNSRecord, write func(q dbtx) error) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+
+	return s.pool.Transaction(ctx, s.connectionName, func(tx *sql.Tx) error {
+		if err := write(tx); err != nil {
+			return err
+		}
+		return s.harmonizeTTLs(ctx, tx, []*models.DNSRecord{record})
+	})
+}
@@ -0,0 +1,124 @@
+// internal/storage/schedule.go
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// ChangeSource reports upcoming times at which served records change
+type ChangeSource interface {
+	UpcomingChanges(ctx context.Context, until time.Time) ([]ScheduledChange, error)
+}
+
+// Invalidator drops cached answers for a name/type combination
+type Invalidator interface {
+	Invalidate(name, recordType string)
+}
+
+// InvalidatorFunc adapts a function to the Invalidator interface
+type InvalidatorFunc func(name, recordType string)
+
+// Invalidate calls f(name, recordType)
+func (f InvalidatorFunc) Invalidate(name, recordType string) {
+	f(name, recordType)
+}
+
+// ChangeScheduler invalidates caches at record activation boundaries so a
+// staged cutover takes effect at the planned time instead of when cached
+// answers happen to expire. It polls for boundaries inside a lookahead
+// window and arms a timer for each one
+type ChangeScheduler struct {
+	source      ChangeSource
+	invalidator Invalidator
+	interval    time.Duration
+	lookahead   time.Duration
+
+	mu     sync.Mutex
+	timers map[changeKey]*time.Timer
+}
+
+// changeKey identifies an armed timer. Times are compared as Unix
+// nanoseconds because scanned time.Time values can differ in location
+type changeKey struct {
+	name       string
+	recordType string
+	at         int64
+}
+
+// NewChangeScheduler creates a scheduler polling every interval. Each poll
+// looks two intervals ahead so consecutive polls overlap
+func NewChangeScheduler(source ChangeSource, invalidator Invalidator, interval time.Duration) *ChangeScheduler {
+	return &ChangeScheduler{
+		source:      source,
+		invalidator: invalidator,
+		interval:    interval,
+		lookahead:   2 * interval,
+		timers:      make(map[changeKey]*time.Timer),
+	}
+}
+
+// Run polls until the context is cancelled, then stops any pending timers
+func (cs *ChangeScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(cs.interval)
+	defer ticker.Stop()
+
+	cs.Poll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			cs.stopAll()
+			return
+		case <-ticker.C:
+			cs.Poll(ctx)
+		}
+	}
+}
+
+// Poll loads boundaries within the lookahead window and arms timers for new ones
+func (cs *ChangeScheduler) Poll(ctx context.Context) {
+	changes, err := cs.source.UpcomingChanges(ctx, time.Now().Add(cs.lookahead))
+	if err != nil {
+		logging.Error("storage", "Failed to load scheduled record changes", err)
+		return
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, change := range changes {
+		key := changeKey{name: change.Name, recordType: change.RecordType, at: change.At.UnixNano()}
+		if _, armed := cs.timers[key]; armed {
+			continue
+		}
+
+		cs.timers[key] = time.AfterFunc(time.Until(change.At), func() {
+			cs.fire(key, change)
+		})
+		logging.Debug("storage", "Scheduled record change", "name", change.Name, "type", change.RecordType, "at", change.At)
+	}
+}
+
+// fire invalidates cached answers for a change whose time has come
+func (cs *ChangeScheduler) fire(key changeKey, change ScheduledChange) {
+	cs.mu.Lock()
+	delete(cs.timers, key)
+	cs.mu.Unlock()
+
+	cs.invalidator.Invalidate(change.Name, change.RecordType)
+	logging.Info("storage", "Record activation boundary reached", "name", change.Name, "type", change.RecordType)
+}
+
+// stopAll cancels timers that have not fired yet
+func (cs *ChangeScheduler) stopAll() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for key, timer := range cs.timers {
+		timer.Stop()
+		delete(cs.timers, key)
+	}
+}
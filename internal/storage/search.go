@@ -0,0 +1,104 @@
+// internal/storage/search.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"errantdns.io/internal/models"
+)
+
+// SearchRecords returns the records whose name or target matches search,
+// ordered by name, type, and priority
+func (s *PostgresStorage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	if err := search.Validate(); err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{searchPattern(search)}
+	var condition string
+	switch search.SearchField() {
+	case models.SearchFieldName:
+		condition = `LOWER(name) LIKE $1 ESCAPE '\'`
+	case models.SearchFieldTarget:
+		condition = `LOWER(target) LIKE $1 ESCAPE '\'`
+	default:
+		condition = `(LOWER(name) LIKE $1 ESCAPE '\' OR LOWER(target) LIKE $1 ESCAPE '\')`
+	}
+	if search.RecordType != "" {
+		args = append(args, strings.ToUpper(search.RecordType))
+		condition += fmt.Sprintf(" AND record_type = $%d", len(args))
+	}
+
+	sqlQuery := "SELECT " + fullRecordColumns + " FROM dns_records WHERE " + condition +
+		" ORDER BY " + strings.Join(recordSortColumns[models.RecordSortName], ", ")
+	if search.Limit > 0 {
+		args = append(args, search.Limit)
+		sqlQuery += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search records: %w", err)
+	}
+	defer rows.Close()
+	return scanFullRecords(rows)
+}
+
+// CountRecords returns how many records filter matches. Its sort, cursor,
+// and limit are ignored
+func (s *PostgresStorage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	if filter == nil {
+		filter = &models.RecordFilter{}
+	}
+
+	conditions, args, err := recordFilterConditions(filter)
+	if err != nil {
+		return 0, err
+	}
+	sqlQuery := "SELECT COUNT(*) FROM dns_records"
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var count int
+	if err := s.pool.QueryRow(ctx, s.connectionName, sqlQuery, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count records: %w", err)
+	}
+	return count, nil
+}
+
+// searchPattern turns a search query into a lowercase LIKE pattern: its
+// wildcards become %, and a query without any matches anywhere
+func searchPattern(search *models.RecordSearch) string {
+	query := strings.ToLower(strings.TrimSpace(search.Query))
+	if !search.Wildcard() {
+		return "%" + escapeLike(query) + "%"
+	}
+
+	parts := strings.Split(query, "*")
+	for i, part := range parts {
+		parts[i] = escapeLike(part)
+	}
+	return strings.Join(parts, "%")
+}
+
+// scanFullRecords scans every row of a query selecting fullRecordColumns
+func scanFullRecords(rows *sql.Rows) ([]*models.DNSRecord, error) {
+	var records []*models.DNSRecord
+	for rows.Next() {
+		record, err := scanFullRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating records: %w", err)
+	}
+
+	return records, nil
+}
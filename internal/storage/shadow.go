@@ -0,0 +1,285 @@
+// internal/storage/shadow.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// ShadowConfig holds shadow-read configuration
+type ShadowConfig struct {
+	SampleRate float64       // fraction of lookups repeated against the shadow
+	Timeout    time.Duration // per-lookup timeout on the shadow
+	QueueSize  int           // lookups waiting to be repeated; more are dropped
+	Workers    int           // concurrent lookups on the shadow
+}
+
+// ShadowStats counts shadow reads and how their results compared
+type ShadowStats struct {
+	Sampled    int64 `json:"sampled"`
+	Dropped    int64 `json:"dropped"` // sampled while the queue was full
+	Failed     int64 `json:"failed"`  // the shadow returned an error
+	Matched    int64 `json:"matched"`
+	Mismatched int64 `json:"mismatched"`
+}
+
+// Kinds of lookup repeated against the shadow
+const (
+	shadowLookupRecord = "record"
+	shadowLookupAll    = "records"
+	shadowLookupGroup  = "group"
+)
+
+// shadowRead is a lookup and the primary's result for it
+type shadowRead struct {
+	kind    string
+	query   models.LookupQuery
+	records []*models.DNSRecord
+}
+
+// ShadowStorage answers from the primary backend and repeats a sample of
+// successful lookups against a shadow backend in the background, such as
+// one being migrated to, counting and logging where their results differ.
+// Lookups never wait on the shadow; when it can't keep up, they're dropped
+// from the sample. Management operations and writes only go to the primary
+type ShadowStorage struct {
+	next   Storage
+	shadow Storage
+	config *ShadowConfig
+	queue  chan shadowRead
+
+	sampled    atomic.Int64
+	dropped    atomic.Int64
+	failed     atomic.Int64
+	matched    atomic.Int64
+	mismatched atomic.Int64
+}
+
+// NewShadowStorage wraps next, repeating lookups against shadow
+func NewShadowStorage(next, shadow Storage, config *ShadowConfig) *ShadowStorage {
+	return &ShadowStorage{
+		next:   next,
+		shadow: shadow,
+		config: config,
+		queue:  make(chan shadowRead, max(config.QueueSize, 1)),
+	}
+}
+
+// LookupRecord finds a single record in the primary
+func (s *ShadowStorage) LookupRecord(ctx context.Context, query *models.LookupQuery) (*models.DNSRecord, error) {
+	record, err := s.next.LookupRecord(ctx, query)
+	if err == nil {
+		var records []*models.DNSRecord
+		if record != nil {
+			records = append(records, record)
+		}
+		s.submit(shadowLookupRecord, query, records)
+	}
+	return record, err
+}
+
+// LookupRecords finds all matching records in the primary
+func (s *ShadowStorage) LookupRecords(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	records, err := s.next.LookupRecords(ctx, query)
+	if err == nil {
+		s.submit(shadowLookupAll, query, records)
+	}
+	return records, err
+}
+
+// LookupRecordGroup finds the lowest priority group in the primary
+func (s *ShadowStorage) LookupRecordGroup(ctx context.Context, query *models.LookupQuery) ([]*models.DNSRecord, error) {
+	records, err := s.next.LookupRecordGroup(ctx, query)
+	if err == nil {
+		s.submit(shadowLookupGroup, query, records)
+	}
+	return records, err
+}
+
+// submit queues a sampled lookup for the shadow without blocking
+func (s *ShadowStorage) submit(kind string, query *models.LookupQuery, records []*models.DNSRecord) {
+	if rand.Float64() >= s.config.SampleRate {
+		return
+	}
+	s.sampled.Add(1)
+
+	select {
+	case s.queue <- shadowRead{kind: kind, query: *query, records: records}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Run repeats queued lookups against the shadow until the context is
+// cancelled. Lookups still queued then are discarded
+func (s *ShadowStorage) Run(ctx context.Context) {
+	workers := max(s.config.Workers, 1)
+	done := make(chan struct{}, workers)
+	for range workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case read := <-s.queue:
+					s.compare(ctx, read)
+				}
+			}
+		}()
+	}
+	for range workers {
+		<-done
+	}
+}
+
+// compare repeats one lookup against the shadow and diffs the results
+func (s *ShadowStorage) compare(ctx context.Context, read shadowRead) {
+	lookupCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	var records []*models.DNSRecord
+	var err error
+	switch read.kind {
+	case shadowLookupRecord:
+		var record *models.DNSRecord
+		if record, err = s.shadow.LookupRecord(lookupCtx, &read.query); record != nil {
+			records = append(records, record)
+		}
+	case shadowLookupAll:
+		records, err = s.shadow.LookupRecords(lookupCtx, &read.query)
+	case shadowLookupGroup:
+		records, err = s.shadow.LookupRecordGroup(lookupCtx, &read.query)
+	}
+	if err != nil {
+		// Shutting down says nothing about the shadow
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		s.failed.Add(1)
+		logging.Debug("storage", "Shadow lookup failed", "name", read.query.Name,
+			"type", string(read.query.Type), "error", err.Error())
+		return
+	}
+
+	// Tie-breaking may pick a different record of the same group, so single
+	// record lookups only compare which group was found
+	key := shadowRecordKey
+	if read.kind == shadowLookupRecord {
+		key = shadowGroupKey
+	}
+	primary, shadow := shadowKeys(read.records, key), shadowKeys(records, key)
+	if slices.Equal(primary, shadow) {
+		s.matched.Add(1)
+		return
+	}
+
+	s.mismatched.Add(1)
+	logging.Warn("storage", "Shadow lookup differs from primary", "lookup", read.kind,
+		"name", read.query.Name, "type", string(read.query.Type),
+		"primary", primary, "shadow", shadow)
+}
+
+// shadowKeys returns the sorted keys of records
+func shadowKeys(records []*models.DNSRecord, key func(*models.DNSRecord) string) []string {
+	keys := make([]string, len(records))
+	for i, record := range records {
+		keys[i] = key(record)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// shadowRecordKey identifies a record by what's served from it, ignoring
+// IDs and timestamps, which differ between backends
+func shadowRecordKey(record *models.DNSRecord) string {
+	return fmt.Sprintf("%s %s %s ttl=%d priority=%d weight=%d port=%d tag=%s serial=%d",
+		strings.ToLower(record.Name), record.RecordType, record.Target, record.TTL,
+		record.Priority, record.Weight, record.Port, record.Tag, record.Serial)
+}
+
+// shadowGroupKey identifies a record's priority group
+func shadowGroupKey(record *models.DNSRecord) string {
+	return fmt.Sprintf("%s %s priority=%d", strings.ToLower(record.Name), record.RecordType, record.Priority)
+}
+
+// Stats returns how many lookups were repeated and how their results
+// compared
+func (s *ShadowStorage) Stats() ShadowStats {
+	return ShadowStats{
+		Sampled:    s.sampled.Load(),
+		Dropped:    s.dropped.Load(),
+		Failed:     s.failed.Load(),
+		Matched:    s.matched.Load(),
+		Mismatched: s.mismatched.Load(),
+	}
+}
+
+// GetRecordByID fetches a record from the primary
+func (s *ShadowStorage) GetRecordByID(ctx context.Context, id int) (*models.DNSRecord, error) {
+	return s.next.GetRecordByID(ctx, id)
+}
+
+// CreateRecord creates a record in the primary
+func (s *ShadowStorage) CreateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return s.next.CreateRecord(ctx, record)
+}
+
+// UpdateRecord updates a record in the primary
+func (s *ShadowStorage) UpdateRecord(ctx context.Context, record *models.DNSRecord) error {
+	return s.next.UpdateRecord(ctx, record)
+}
+
+// UpsertRecord creates or updates a record in the primary
+func (s *ShadowStorage) UpsertRecord(ctx context.Context, record *models.DNSRecord) (bool, error) {
+	return s.next.UpsertRecord(ctx, record)
+}
+
+// CreateRecords creates records in the primary in one transaction
+func (s *ShadowStorage) CreateRecords(ctx context.Context, records []*models.DNSRecord) error {
+	return s.next.CreateRecords(ctx, records)
+}
+
+// DeleteRecord deletes a record from the primary
+func (s *ShadowStorage) DeleteRecord(ctx context.Context, id int) error {
+	return s.next.DeleteRecord(ctx, id)
+}
+
+// DeleteRecords deletes records from the primary
+func (s *ShadowStorage) DeleteRecords(ctx context.Context, name string, recordType string) error {
+	return s.next.DeleteRecords(ctx, name, recordType)
+}
+
+// ListRecords lists records from the primary
+func (s *ShadowStorage) ListRecords(ctx context.Context, filter *models.RecordFilter) ([]*models.DNSRecord, error) {
+	return s.next.ListRecords(ctx, filter)
+}
+
+// SearchRecords searches records in the primary
+func (s *ShadowStorage) SearchRecords(ctx context.Context, search *models.RecordSearch) ([]*models.DNSRecord, error) {
+	return s.next.SearchRecords(ctx, search)
+}
+
+// CountRecords counts records in the primary
+func (s *ShadowStorage) CountRecords(ctx context.Context, filter *models.RecordFilter) (int, error) {
+	return s.next.CountRecords(ctx, filter)
+}
+
+// Health checks the primary's health; the shadow's doesn't affect serving
+func (s *ShadowStorage) Health(ctx context.Context) error {
+	return s.next.Health(ctx)
+}
+
+// Close closes both backends
+func (s *ShadowStorage) Close() error {
+	return errors.Join(s.next.Close(), s.shadow.Close())
+}
@@ -0,0 +1,54 @@
+// internal/storage/slowquery.go
+package storage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/logging"
+)
+
+// PostgresStats holds query timing statistics for PostgreSQL storage
+type PostgresStats struct {
+	SlowQueries          int64 `json:"slow_queries"`
+	SlowQueryThresholdMs int64 `json:"slow_query_threshold_ms"` // 0 when disabled
+	Timeouts             int64 `json:"timeouts"`
+	LookupTimeoutMs      int64 `json:"lookup_timeout_ms"` // 0 when disabled
+}
+
+// Stats returns query timing statistics
+func (s *PostgresStorage) Stats() PostgresStats {
+	return PostgresStats{
+		SlowQueries:          s.slowQueries.Load(),
+		SlowQueryThresholdMs: s.slowQueryThreshold.Milliseconds(),
+		Timeouts:             s.timeouts.Load(),
+		LookupTimeoutMs:      s.lookupTimeout.Milliseconds(),
+	}
+}
+
+// observeQuery counts sqlQuery as timed out if ctx's deadline passed while
+// it ran, and logs it to the error log if it ran longer than the slow query
+// threshold. Lookups defer it with their start time so the duration
+// includes reading the rows
+func (s *PostgresStorage) observeQuery(ctx context.Context, sqlQuery string, args []interface{}, start time.Time) {
+	duration := time.Since(start)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		s.timeouts.Add(1)
+		logging.WarnContext(ctx, "storage", "PostgreSQL lookup timed out",
+			"sql", compactSQL(sqlQuery), "duration_ms", duration.Milliseconds())
+	}
+
+	if s.slowQueryThreshold <= 0 || duration < s.slowQueryThreshold {
+		return
+	}
+
+	s.slowQueries.Add(1)
+	logging.GetLogger().LogSlowQuery(ctx, compactSQL(sqlQuery), args, duration, s.slowQueryThreshold)
+}
+
+// compactSQL collapses the whitespace in a query so it logs on one line
+func compactSQL(sqlQuery string) string {
+	return strings.Join(strings.Fields(sqlQuery), " ")
+}
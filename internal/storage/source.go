@@ -1,7 +1,13 @@
 // internal/storage/source.go
 package storage
 
-import "errantdns.io/internal/models"
+import (
+	"context"
+	"sync/atomic"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
 
 // CacheSource indicates where a DNS record was retrieved from
 type CacheSource string
@@ -10,6 +16,13 @@ const (
 	SourceDatabase CacheSource = "DB" // Retrieved from database (L3)
 	SourceRedis    CacheSource = "L2" // Retrieved from Redis cache (L2)
 	SourceMemory   CacheSource = "L1" // Retrieved from memory cache (L1)
+
+	// Tiers only reported by cache inspection
+	SourceWire  CacheSource = "L0"    // Packed responses in the DNS server (L0)
+	SourceStale CacheSource = "stale" // Circuit breaker fallback answers
+
+	// Answers from outside storage, only reported by the DNS server
+	SourceForwarded CacheSource = "forwarded" // Answered by an upstream resolver
 )
 
 // String returns a human-readable representation of the cache source
@@ -28,3 +41,75 @@ type LookupGroupResult struct {
 	Records []*models.DNSRecord
 	Source  CacheSource
 }
+
+// logLookup records which tier answered a lookup, tagged with the query ID
+// carried by ctx
+func logLookup(ctx context.Context, query *models.LookupQuery, source CacheSource) {
+	noteSource(ctx, source)
+	if logging.Enabled(logging.LevelDebug) {
+		logging.DebugContext(ctx, "storage", "Lookup answered", "domain", query.Name, "type", query.Type, "source", source.String())
+	}
+}
+
+// tierDepth orders the tiers a lookup can reach, fastest first
+var tierDepth = map[CacheSource]int32{
+	SourceMemory:   1,
+	SourceRedis:    2,
+	SourceDatabase: 3,
+}
+
+// LookupTrace collects what the lookups made with a traced context
+// reached: the slowest tier any of them was answered from or went down to,
+// and whether any was answered with stale data
+type LookupTrace struct {
+	depth atomic.Int32
+	stale atomic.Bool
+}
+
+// traceKey is the context key for a LookupTrace
+type traceKey struct{}
+
+// WithLookupTrace returns a context whose lookups are collected in the
+// returned trace
+func WithLookupTrace(ctx context.Context) (context.Context, *LookupTrace) {
+	trace := new(LookupTrace)
+	return context.WithValue(ctx, traceKey{}, trace), trace
+}
+
+// lookupTrace returns the trace ctx carries, or nil
+func lookupTrace(ctx context.Context) *LookupTrace {
+	trace, _ := ctx.Value(traceKey{}).(*LookupTrace)
+	return trace
+}
+
+// noteSource records that a lookup reached source in ctx's trace, if any
+func noteSource(ctx context.Context, source CacheSource) {
+	trace := lookupTrace(ctx)
+	if trace == nil {
+		return
+	}
+	depth := tierDepth[source]
+	for {
+		current := trace.depth.Load()
+		if depth <= current || trace.depth.CompareAndSwap(current, depth) {
+			return
+		}
+	}
+}
+
+// Source returns the slowest tier the traced lookups reached, or "" if
+// they reached none, such as when nothing was looked up
+func (t *LookupTrace) Source() CacheSource {
+	depth := t.depth.Load()
+	for source, d := range tierDepth {
+		if d == depth {
+			return source
+		}
+	}
+	return ""
+}
+
+// Stale reports whether any traced lookup was answered with stale data
+func (t *LookupTrace) Stale() bool {
+	return t.stale.Load()
+}
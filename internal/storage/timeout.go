@@ -0,0 +1,27 @@
+// internal/storage/timeout.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// withTimeout bounds ctx by timeout, or returns it unchanged if timeout is 0.
+// An earlier deadline already on ctx still applies
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// isTimeout reports whether err was caused by a deadline expiring
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
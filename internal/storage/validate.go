@@ -0,0 +1,47 @@
+// internal/storage/validate.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"errantdns.io/internal/models"
+)
+
+// ValidateRecords runs the checks CreateRecords would on records without
+// writing anything, returning every problem found in record order, at most
+// one per record. Records are normalized in place
+func (s *PostgresStorage) ValidateRecords(ctx context.Context, records []*models.DNSRecord) ([]*RecordError, error) {
+	problems := checkRecords(records)
+
+	// Records that failed validation aren't normalized, so only the rest
+	// are checked against storage
+	candidates := slices.Clone(records)
+	reported := make(map[int]bool, len(problems))
+	for _, problem := range problems {
+		reported[problem.Index] = true
+		if !errors.Is(problem, ErrDuplicateRecord) && !errors.Is(problem, ErrCNAMEConflict) {
+			candidates[problem.Index] = nil
+		}
+	}
+
+	var stored []*RecordError
+	err := s.pool.Transaction(ctx, s.connectionName, func(tx *sql.Tx) error {
+		var err error
+		stored, err = storedConflicts(ctx, tx, candidates)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, problem := range stored {
+		if !reported[problem.Index] {
+			problems = append(problems, problem)
+		}
+	}
+	slices.SortFunc(problems, func(a, b *RecordError) int { return a.Index - b.Index })
+	return problems, nil
+}
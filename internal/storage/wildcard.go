@@ -0,0 +1,347 @@
+// internal/storage/wildcard.go
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"errantdns.io/internal/models"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// backfillBatchSize is how many records BackfillDomainColumns updates per query
+const backfillBatchSize = 500
+
+// setDomainComponents derives the domain columns for record. Names without
+// an apex domain (such as bare public suffixes) are stored with empty ones,
+// and names with partial wildcard labels keep their apex but never match as
+// wildcards
+func setDomainComponents(record *models.DNSRecord) {
+	record.ETLD = ""
+	record.ApexDomain = ""
+	record.SubdomainLabels = nil
+	if err := record.SetDomainComponents(); err != nil {
+		record.IsWildcard = false
+		record.WildcardMask = 0
+	}
+}
+
+// lookupWildcard answers query from the wildcard records covering its name.
+// Candidates are narrowed down in the database by apex domain and label
+// count, then matched against the name in Go. Only the records of the most
+// specific matching pattern are returned, limited to its lowest priority
+// when groupOnly is set, with Name set to the query name
+func (s *PostgresStorage) lookupWildcard(ctx context.Context, query *models.LookupQuery, groupOnly bool) ([]*models.DNSRecord, error) {
+	apex, labels, ok := models.DomainComponents(query.Name)
+	if !ok || len(labels) == 0 || len(labels) > 64 {
+		return nil, nil
+	}
+
+	sqlQuery := `
+		SELECT
+			id,
+			name,
+			record_type,
+			target,
+			ttl,
+			priority,
+			created_at,
+			updated_at,
+		    serial,
+			mbox,
+			refresh,
+			retry,
+			expire,
+			minttl,
+			weight,
+			port,
+			expires_at,
+			not_before,
+			not_after,
+			subdomain_labels,
+			wildcard_mask
+		FROM dns_records
+		WHERE apex_domain = $1 AND record_type = $2
+			AND is_wildcard AND cardinality(subdomain_labels) = $3
+			AND (expires_at IS NULL OR expires_at > NOW())
+			AND (not_before IS NULL OR not_before <= NOW())
+			AND (not_after IS NULL OR not_after > NOW())
+		ORDER BY priority ASC, id ASC
+	`
+
+	args := []interface{}{apex, query.Type.String(), len(labels)}
+	defer s.observeQuery(ctx, sqlQuery, args, time.Now())
+
+	rows, err := s.pool.Query(ctx, s.connectionName, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wildcard records for %s %s: %w", query.Name, query.Type, err)
+	}
+	defer rows.Close()
+
+	// database/sql can't scan arrays itself
+	types := pgtype.NewMap()
+
+	var best *models.DNSRecord
+	var records []*models.DNSRecord
+	for rows.Next() {
+		var record models.DNSRecord
+
+		var serial, refresh, retry, expire, minttl sql.NullInt32
+		var mbox sql.NullString
+		var weight, port sql.NullInt16
+		var expiresAt, notBefore, notAfter sql.NullTime
+		var mask int64
+
+		err := rows.Scan(
+			&record.ID,
+			&record.Name,
+			&record.RecordType,
+			&record.Target,
+			&record.TTL,
+			&record.Priority,
+			&record.CreatedAt,
+			&record.UpdatedAt,
+			&serial,
+			&mbox,
+			&refresh,
+			&retry,
+			&expire,
+			&minttl,
+			&weight,
+			&port,
+			&expiresAt,
+			&notBefore,
+			&notAfter,
+			types.SQLScanner(&record.SubdomainLabels),
+			&mask,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan wildcard record: %w", err)
+		}
+
+		record.ApexDomain = apex
+		record.IsWildcard = true
+		record.WildcardMask = uint64(mask)
+		if !record.MatchesLabels(labels) {
+			continue
+		}
+
+		// A more specific pattern replaces everything matched so far. Two
+		// patterns matching the same name with the same mask are the same
+		// pattern, so any other mask is less specific
+		switch {
+		case best == nil || record.MoreSpecificThan(best):
+			best = &record
+			records = records[:0]
+		case record.WildcardMask != best.WildcardMask:
+			continue
+		}
+		if groupOnly && len(records) > 0 && record.Priority != records[0].Priority {
+			continue
+		}
+
+		record.Serial = uint32(serial.Int32)
+		record.Mbox = mbox.String
+		record.Refresh = uint32(refresh.Int32)
+		record.Retry = uint32(retry.Int32)
+		record.Expire = uint32(expire.Int32)
+		record.Minttl = uint32(minttl.Int32)
+		record.Weight = uint32(weight.Int16)
+		record.Port = uint16(port.Int16)
+		if expiresAt.Valid {
+			record.ExpiresAt = &expiresAt.Time
+		}
+		if notBefore.Valid {
+			record.NotBefore = &notBefore.Time
+		}
+		if notAfter.Valid {
+			record.NotAfter = &notAfter.Time
+		}
+
+		records = append(records, &record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating wildcard records: %w", err)
+	}
+
+	// Answers are for the name that was asked about, not the pattern
+	for _, record := range records {
+		record.Name = models.NormalizeDomainName(query.Name)
+	}
+
+	return records, nil
+}
+
+// BackfillDomainColumns fills in the domain columns of records written
+// before they were stored, returning how many records were updated. Once it
+// succeeds, lookups filter on the apex domain (see lookupFilter)
+func (s *PostgresStorage) BackfillDomainColumns(ctx context.Context) (int, error) {
+	db, err := s.pool.GetConnection(s.connectionName)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := backfillDomainColumns(ctx, db)
+	if err != nil {
+		return updated, err
+	}
+	s.backfilled.Store(true)
+	s.apexFiltering.Store(true)
+	return updated, nil
+}
+
+// PublicSuffixRules returns the custom public suffix rules stored in the
+// public_suffixes table
+func (s *PostgresStorage) PublicSuffixRules(ctx context.Context) ([]string, error) {
+	rows, err := s.pool.Query(ctx, s.connectionName, `SELECT rule FROM public_suffixes ORDER BY rule`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query public suffix rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []string
+	for rows.Next() {
+		var rule string
+		if err := rows.Scan(&rule); err != nil {
+			return nil, fmt.Errorf("failed to scan public suffix rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating public suffix rules: %w", err)
+	}
+	return rules, nil
+}
+
+// SetPublicSuffixes replaces the custom public suffix rules and derives the
+// domain columns of every record again, returning how many records changed.
+// Lookups don't filter on the apex domain until every record is updated,
+// since stored apex domains may be stale until then
+func (s *PostgresStorage) SetPublicSuffixes(ctx context.Context, list *models.SuffixList) (int, error) {
+	db, err := s.pool.GetConnection(s.connectionName)
+	if err != nil {
+		return 0, err
+	}
+
+	s.apexFiltering.Store(false)
+	models.SetCustomSuffixes(list)
+	updated, err := rederiveDomainColumns(ctx, db)
+	if err != nil {
+		return updated, err
+	}
+	s.apexFiltering.Store(s.backfilled.Load())
+	return updated, nil
+}
+
+// rederiveDomainColumns derives the domain columns of every record using
+// q, updating the records whose columns differ
+func rederiveDomainColumns(ctx context.Context, q dbtx) (int, error) {
+	updated := 0
+	lastID := 0
+	for {
+		rows, err := q.QueryContext(ctx, `
+			SELECT id, name, COALESCE(etld, ''), COALESCE(apex_domain, '')
+			FROM dns_records
+			WHERE id > $1
+			ORDER BY id ASC
+			LIMIT $2
+		`, lastID, backfillBatchSize)
+		if err != nil {
+			return updated, fmt.Errorf("failed to query records to derive: %w", err)
+		}
+
+		var batch []*models.DNSRecord
+		for rows.Next() {
+			var record models.DNSRecord
+			if err := rows.Scan(&record.ID, &record.Name, &record.ETLD, &record.ApexDomain); err != nil {
+				rows.Close()
+				return updated, fmt.Errorf("failed to scan record: %w", err)
+			}
+			batch = append(batch, &record)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, fmt.Errorf("error iterating records to derive: %w", err)
+		}
+
+		for _, record := range batch {
+			lastID = record.ID
+			etld, apex := record.ETLD, record.ApexDomain
+			setDomainComponents(record)
+			if record.ETLD == etld && record.ApexDomain == apex {
+				continue
+			}
+
+			_, err := q.ExecContext(ctx, `
+				UPDATE dns_records
+				SET etld = $1, apex_domain = $2, subdomain_labels = $3, is_wildcard = $4, wildcard_mask = $5
+				WHERE id = $6
+			`, record.ETLD, record.ApexDomain, record.SubdomainLabels, record.IsWildcard, int64(record.WildcardMask), record.ID)
+			if err != nil {
+				return updated, fmt.Errorf("failed to derive domain columns of record ID %d: %w", record.ID, err)
+			}
+			updated++
+		}
+
+		if len(batch) < backfillBatchSize {
+			return updated, nil
+		}
+	}
+}
+
+// backfillDomainColumns derives the domain columns of every record without
+// them using q. Names without an apex domain get empty ones, so each record
+// is only visited once
+func backfillDomainColumns(ctx context.Context, q dbtx) (int, error) {
+	updated := 0
+	lastID := 0
+	for {
+		rows, err := q.QueryContext(ctx, `
+			SELECT id, name
+			FROM dns_records
+			WHERE apex_domain IS NULL AND id > $1
+			ORDER BY id ASC
+			LIMIT $2
+		`, lastID, backfillBatchSize)
+		if err != nil {
+			return updated, fmt.Errorf("failed to query records to backfill: %w", err)
+		}
+
+		var batch []*models.DNSRecord
+		for rows.Next() {
+			var record models.DNSRecord
+			if err := rows.Scan(&record.ID, &record.Name); err != nil {
+				rows.Close()
+				return updated, fmt.Errorf("failed to scan record: %w", err)
+			}
+			batch = append(batch, &record)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, fmt.Errorf("error iterating records to backfill: %w", err)
+		}
+
+		for _, record := range batch {
+			lastID = record.ID
+			setDomainComponents(record)
+
+			_, err := q.ExecContext(ctx, `
+				UPDATE dns_records
+				SET etld = $1, apex_domain = $2, subdomain_labels = $3, is_wildcard = $4, wildcard_mask = $5
+				WHERE id = $6 AND apex_domain IS NULL
+			`, record.ETLD, record.ApexDomain, record.SubdomainLabels, record.IsWildcard, int64(record.WildcardMask), record.ID)
+			if err != nil {
+				return updated, fmt.Errorf("failed to backfill record ID %d: %w", record.ID, err)
+			}
+			updated++
+		}
+
+		if len(batch) < backfillBatchSize {
+			return updated, nil
+		}
+	}
+}
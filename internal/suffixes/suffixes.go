@@ -0,0 +1,118 @@
+// internal/suffixes/suffixes.go
+package suffixes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"errantdns.io/internal/logging"
+	"errantdns.io/internal/models"
+)
+
+// RuleSource returns stored custom suffix rules, such as the rows of the
+// public_suffixes table
+type RuleSource func(ctx context.Context) ([]string, error)
+
+// Applier puts a new suffix list into effect, returning how many records
+// it changed
+type Applier func(ctx context.Context, list *models.SuffixList) (int, error)
+
+// Loader reads custom public suffix rules from a file in the Public Suffix
+// List's format and a rule source, merged into one list. Run reloads them
+// so edits take effect without a restart
+type Loader struct {
+	file   string
+	source RuleSource
+
+	current *models.SuffixList
+}
+
+// NewLoader creates a loader for file and source; either may be empty
+func NewLoader(file string, source RuleSource) *Loader {
+	return &Loader{file: file, source: source}
+}
+
+// Load reads the rules and installs them, without re-deriving stored
+// records. Used at startup, before records are written
+func (l *Loader) Load(ctx context.Context) (*models.SuffixList, error) {
+	list, err := l.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models.SetCustomSuffixes(list)
+	l.current = list
+	return list, nil
+}
+
+// Run re-reads the rules every interval until ctx is cancelled, applying
+// them when they changed
+func (l *Loader) Run(ctx context.Context, interval time.Duration, apply Applier) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			list, err := l.read(ctx)
+			if err != nil {
+				logging.Warn("suffixes", "Failed to reload public suffix rules", "error", err)
+				continue
+			}
+			if l.current != nil && list.Equal(l.current) {
+				continue
+			}
+
+			updated, err := apply(ctx, list)
+			if err != nil {
+				logging.Warn("suffixes", "Failed to apply public suffix rules", "error", err, "updated", updated)
+				continue
+			}
+			l.current = list
+			logging.Info("suffixes", "Reloaded public suffix rules", "rules", list.Len(), "records_updated", updated)
+		}
+	}
+}
+
+// read merges the rules of the file and the source
+func (l *Loader) read(ctx context.Context) (*models.SuffixList, error) {
+	var rules []string
+	if l.file != "" {
+		lines, err := readLines(l.file)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, lines...)
+	}
+	if l.source != nil {
+		stored, err := l.source(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, stored...)
+	}
+	return models.NewSuffixList(rules)
+}
+
+// readLines returns the lines of a suffix file
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open suffix file: %w", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read suffix file %s: %w", path, err)
+	}
+	return lines, nil
+}
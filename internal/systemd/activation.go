@@ -0,0 +1,101 @@
+// internal/systemd/activation.go
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd
+const listenFDsStart = 3
+
+// Sockets holds the sockets passed in by systemd socket activation
+type Sockets struct {
+	PacketConns []NamedPacketConn // datagram sockets (UDP)
+	Listeners   []NamedListener   // stream sockets (TCP)
+}
+
+// NamedPacketConn is an activated datagram socket and its FileDescriptorName
+type NamedPacketConn struct {
+	Name string
+	Conn net.PacketConn
+}
+
+// NamedListener is an activated stream socket and its FileDescriptorName
+type NamedListener struct {
+	Name     string
+	Listener net.Listener
+}
+
+// Empty reports whether no sockets were passed in
+func (s *Sockets) Empty() bool {
+	return len(s.PacketConns) == 0 && len(s.Listeners) == 0
+}
+
+// PacketConn returns the datagram socket with the given name, falling back
+// to the first unnamed one
+func (s *Sockets) PacketConn(name string) net.PacketConn {
+	for _, pc := range s.PacketConns {
+		if pc.Name == name {
+			return pc.Conn
+		}
+	}
+	for _, pc := range s.PacketConns {
+		if pc.Name == "" {
+			return pc.Conn
+		}
+	}
+	return nil
+}
+
+// Listener returns the stream socket with the given name, falling back to
+// the first unnamed one
+func (s *Sockets) Listener(name string) net.Listener {
+	for _, l := range s.Listeners {
+		if l.Name == name {
+			return l.Listener
+		}
+	}
+	for _, l := range s.Listeners {
+		if l.Name == "" {
+			return l.Listener
+		}
+	}
+	return nil
+}
+
+// Activated reports whether this process was started with sockets from systemd
+func Activated() bool {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return false
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return err == nil && count > 0
+}
+
+// fdNames returns the LISTEN_FDNAMES entries, padded to count
+func fdNames(count int) []string {
+	names := make([]string, count)
+	if env := os.Getenv("LISTEN_FDNAMES"); env != "" {
+		for i, name := range strings.Split(env, ":") {
+			if i >= count {
+				break
+			}
+			// systemd uses "unknown" when no FileDescriptorName is set
+			if name != "unknown" {
+				names[i] = name
+			}
+		}
+	}
+	return names
+}
+
+// unsetEnv clears the activation variables so child processes don't inherit them
+func unsetEnv() {
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+}
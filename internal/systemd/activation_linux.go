@@ -0,0 +1,58 @@
+// internal/systemd/activation_linux.go
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// Listen collects the sockets passed by systemd (LISTEN_FDS), sorted into
+// datagram and stream sockets. It returns empty Sockets when the process
+// was not socket-activated
+func Listen() (*Sockets, error) {
+	sockets := &Sockets{}
+	if !Activated() {
+		return sockets, nil
+	}
+	defer unsetEnv()
+
+	count, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := fdNames(count)
+
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		syscall.CloseOnExec(fd)
+
+		sockType, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_TYPE)
+		if err != nil {
+			return nil, fmt.Errorf("fd %d is not a socket: %w", fd, err)
+		}
+
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("systemd-fd-%d", fd))
+
+		switch sockType {
+		case syscall.SOCK_DGRAM:
+			conn, err := net.FilePacketConn(file)
+			if err != nil {
+				return nil, fmt.Errorf("fd %d: %w", fd, err)
+			}
+			sockets.PacketConns = append(sockets.PacketConns, NamedPacketConn{Name: names[i], Conn: conn})
+		case syscall.SOCK_STREAM:
+			listener, err := net.FileListener(file)
+			if err != nil {
+				return nil, fmt.Errorf("fd %d: %w", fd, err)
+			}
+			sockets.Listeners = append(sockets.Listeners, NamedListener{Name: names[i], Listener: listener})
+		default:
+			return nil, fmt.Errorf("fd %d has unsupported socket type %d", fd, sockType)
+		}
+
+		// net.File* duplicate the descriptor, so the original can be closed
+		file.Close()
+	}
+
+	return sockets, nil
+}
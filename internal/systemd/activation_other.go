@@ -0,0 +1,9 @@
+//go:build !linux
+
+// internal/systemd/activation_other.go
+package systemd
+
+// Listen returns no sockets; socket activation is only supported on Linux
+func Listen() (*Sockets, error) {
+	return &Sockets{}, nil
+}
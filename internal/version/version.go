@@ -0,0 +1,55 @@
+// internal/version/version.go
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// These values are overridden at build time, e.g.
+//
+//	go build -ldflags "-X errantdns.io/internal/version.Version=1.2.0 -X errantdns.io/internal/version.Commit=abc123"
+var (
+	Version   = "1.0.0"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// Get returns build information, falling back to the VCS stamp embedded
+// by the Go toolchain when no commit was provided via ldflags
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,338 @@
+// internal/zonecheck/zonecheck.go
+package zonecheck
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"errantdns.io/internal/models"
+)
+
+// Severity ranks a finding: errors break resolution, warnings are likely
+// mistakes that still resolve
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Checks a finding can come from
+const (
+	CheckApexSOA       = "apex_soa"
+	CheckApexNS        = "apex_ns"
+	CheckCNAMEConflict = "cname_conflict"
+	CheckDNAME         = "dname"
+	CheckDangling      = "dangling_target"
+	CheckAliasTarget   = "alias_target"
+	CheckDuplicate     = "duplicate"
+	CheckTTL           = "ttl_mismatch"
+)
+
+// Finding is one problem found in a zone
+type Finding struct {
+	Severity  Severity `json:"severity"`
+	Check     string   `json:"check"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type,omitempty"`
+	Message   string   `json:"message"`
+	RecordIDs []int    `json:"record_ids,omitempty"`
+}
+
+// Report is the result of checking a zone
+type Report struct {
+	Zone      string    `json:"zone"`
+	CheckedAt time.Time `json:"checked_at"`
+	Records   int       `json:"records"`  // live records checked
+	Errors    int       `json:"errors"`   // findings with SeverityError
+	Warnings  int       `json:"warnings"` // findings with SeverityWarning
+	Findings  []Finding `json:"findings"`
+}
+
+// OK reports whether the zone has no errors
+func (r *Report) OK() bool {
+	return r.Errors == 0
+}
+
+// rrsetKey identifies the records of one name and type
+type rrsetKey struct {
+	name       string
+	recordType string
+}
+
+// Check audits the records of zone, which should be every record at or
+// below its apex, for:
+// - a missing or repeated SOA, or missing NS records, at the apex
+// - CNAMEs sharing a name with other types
+// - names holding more than one DNAME, and records a DNAME occludes
+// - CNAME, MX, and SRV targets in the zone that don't exist
+// - MX and SRV targets that are CNAMEs (RFC 2181 section 10.3)
+// - records that differ only in letter case or a trailing dot
+// - RRsets whose records have different TTLs (RFC 2181 section 5.2)
+//
+// Only records served at now are checked. Targets outside the zone aren't
+// resolved
+func Check(zone string, records []*models.DNSRecord, now time.Time) *Report {
+	zone = models.NormalizeDomainName(zone)
+	c := &checker{
+		zone:   zone,
+		rrsets: make(map[rrsetKey][]*models.DNSRecord),
+		names:  make(map[string][]string),
+		report: &Report{Zone: zone, CheckedAt: now, Findings: []Finding{}},
+	}
+
+	for _, record := range records {
+		if !record.IsActive(now) {
+			continue
+		}
+		c.report.Records++
+		key := rrsetKey{models.NormalizeDomainName(record.Name), strings.ToUpper(record.RecordType)}
+		if _, ok := c.rrsets[key]; !ok {
+			c.names[key.name] = append(c.names[key.name], key.recordType)
+		}
+		c.rrsets[key] = append(c.rrsets[key], record)
+	}
+
+	c.checkApex()
+	c.checkCNAMEs()
+	c.checkDNAMEs()
+	c.checkTargets()
+	c.checkDuplicates()
+	c.checkTTLs()
+
+	slices.SortStableFunc(c.report.Findings, func(a, b Finding) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Type, b.Type), cmp.Compare(a.Check, b.Check))
+	})
+	return c.report
+}
+
+// checker holds a zone's live records by RRset while it's checked
+type checker struct {
+	zone   string
+	rrsets map[rrsetKey][]*models.DNSRecord
+	names  map[string][]string // types present at each name
+	report *Report
+}
+
+func (c *checker) add(severity Severity, check, name, recordType, message string, records []*models.DNSRecord) {
+	finding := Finding{Severity: severity, Check: check, Name: name, Type: recordType, Message: message}
+	for _, record := range records {
+		finding.RecordIDs = append(finding.RecordIDs, record.ID)
+	}
+	c.report.Findings = append(c.report.Findings, finding)
+	if severity == SeverityError {
+		c.report.Errors++
+	} else {
+		c.report.Warnings++
+	}
+}
+
+func (c *checker) rrset(name string, recordType models.RecordType) []*models.DNSRecord {
+	return c.rrsets[rrsetKey{name, string(recordType)}]
+}
+
+// checkApex requires exactly one SOA and at least one NS at the apex
+func (c *checker) checkApex() {
+	soa := c.rrset(c.zone, models.RecordTypeSOA)
+	switch {
+	case len(soa) == 0:
+		c.add(SeverityError, CheckApexSOA, c.zone, "SOA", "zone apex has no SOA record", nil)
+	case len(soa) > 1:
+		c.add(SeverityError, CheckApexSOA, c.zone, "SOA", fmt.Sprintf("zone apex has %d SOA records; it must have exactly one", len(soa)), soa)
+	}
+
+	if len(c.rrset(c.zone, models.RecordTypeNS)) == 0 {
+		c.add(SeverityError, CheckApexNS, c.zone, "NS", "zone apex has no NS records", nil)
+	}
+}
+
+// checkCNAMEs flags names holding a CNAME and any other type, or more than
+// one CNAME
+func (c *checker) checkCNAMEs() {
+	for name, types := range c.names {
+		cnames := c.rrset(name, models.RecordTypeCNAME)
+		if len(cnames) == 0 {
+			continue
+		}
+		if len(cnames) > 1 {
+			c.add(SeverityError, CheckCNAMEConflict, name, "CNAME", fmt.Sprintf("%s has %d CNAME records; it may have only one", name, len(cnames)), cnames)
+		}
+
+		var others []string
+		for _, recordType := range types {
+			if recordType != string(models.RecordTypeCNAME) && !cnameCompatible[recordType] {
+				others = append(others, recordType)
+			}
+		}
+		if len(others) > 0 {
+			slices.Sort(others)
+			c.add(SeverityError, CheckCNAMEConflict, name, "CNAME",
+				fmt.Sprintf("%s has a CNAME alongside %s records; a CNAME must be the only record at a name", name, strings.Join(others, ", ")), cnames)
+		}
+	}
+}
+
+// cnameCompatible types may share a name with a CNAME (RFC 4035 section 2.5)
+var cnameCompatible = map[string]bool{"RRSIG": true, "NSEC": true, "NSEC3": true}
+
+// checkDNAMEs flags names holding more than one DNAME (RFC 6672 section
+// 2.4), and records below a DNAME's owner, which are never served because
+// queries for their names are redirected
+func (c *checker) checkDNAMEs() {
+	for name := range c.names {
+		dnames := c.rrset(name, models.RecordTypeDNAME)
+		if len(dnames) > 1 {
+			c.add(SeverityError, CheckDNAME, name, "DNAME", fmt.Sprintf("%s has %d DNAME records; it may have only one", name, len(dnames)), dnames)
+		}
+
+		for parent := name; ; {
+			_, next, found := strings.Cut(parent, ".")
+			if !found || !c.inZone(next) {
+				break
+			}
+			parent = next
+			if len(c.rrset(parent, models.RecordTypeDNAME)) == 0 {
+				continue
+			}
+
+			var occluded []*models.DNSRecord
+			for _, recordType := range c.names[name] {
+				occluded = append(occluded, c.rrset(name, models.RecordType(recordType))...)
+			}
+			c.add(SeverityError, CheckDNAME, name, "",
+				fmt.Sprintf("%s is below the DNAME at %s, so its records are never served", name, parent), occluded)
+			break
+		}
+	}
+}
+
+// checkTargets flags CNAME, MX, and SRV records whose in-zone target
+// doesn't exist, and MX and SRV records whose target is an alias
+func (c *checker) checkTargets() {
+	for key, records := range c.rrsets {
+		var needsAddress bool
+		switch models.RecordType(key.recordType) {
+		case models.RecordTypeCNAME:
+		case models.RecordTypeMX, models.RecordTypeSRV:
+			needsAddress = true
+		default:
+			continue
+		}
+
+		for _, record := range records {
+			target := models.NormalizeDomainName(record.Target)
+			// A null MX or SRV target ("."), or one in another zone
+			if target == "" || !c.inZone(target) {
+				continue
+			}
+
+			if needsAddress && len(c.lookup(target, models.RecordTypeCNAME)) > 0 {
+				c.add(SeverityWarning, CheckAliasTarget, key.name, key.recordType,
+					fmt.Sprintf("%s target %s is a CNAME; %s targets must have address records", key.recordType, target, key.recordType),
+					[]*models.DNSRecord{record})
+				continue
+			}
+
+			switch {
+			case !needsAddress && !c.exists(target):
+				c.add(SeverityError, CheckDangling, key.name, key.recordType,
+					fmt.Sprintf("CNAME target %s has no records", target), []*models.DNSRecord{record})
+			case needsAddress && len(c.lookup(target, models.RecordTypeA)) == 0 && len(c.lookup(target, models.RecordTypeAAAA)) == 0:
+				c.add(SeverityError, CheckDangling, key.name, key.recordType,
+					fmt.Sprintf("%s target %s has no A or AAAA records", key.recordType, target), []*models.DNSRecord{record})
+			}
+		}
+	}
+}
+
+// inZone reports whether name is the apex or below it
+func (c *checker) inZone(name string) bool {
+	return name == c.zone || strings.HasSuffix(name, "."+c.zone)
+}
+
+// exists reports whether name has records of any type, directly or
+// through a wildcard
+func (c *checker) exists(name string) bool {
+	if len(c.names[name]) > 0 {
+		return true
+	}
+	wildcard := c.wildcard(name)
+	return wildcard != "" && len(c.names[wildcard]) > 0
+}
+
+// lookup returns the records answering name and type, directly or through
+// a wildcard
+func (c *checker) lookup(name string, recordType models.RecordType) []*models.DNSRecord {
+	if len(c.names[name]) > 0 {
+		return c.rrset(name, recordType)
+	}
+	if wildcard := c.wildcard(name); wildcard != "" {
+		return c.rrset(wildcard, recordType)
+	}
+	return nil
+}
+
+// wildcard returns the wildcard name that would answer for name, or "" if
+// name has no parent in the zone
+func (c *checker) wildcard(name string) string {
+	_, parent, found := strings.Cut(name, ".")
+	if !found || !c.inZone(parent) {
+		return ""
+	}
+	return "*." + parent
+}
+
+// checkDuplicates flags records of one RRset that serve the same data,
+// which storage can't catch when they differ in case or a trailing dot
+func (c *checker) checkDuplicates() {
+	for key, records := range c.rrsets {
+		seen := make(map[string]*models.DNSRecord)
+		for _, record := range records {
+			data := fmt.Sprintf("%s\x00%d\x00%d\x00%s", duplicateTarget(record), record.Priority, record.Port, strings.ToLower(record.Tag))
+			if first, ok := seen[data]; ok {
+				c.add(SeverityWarning, CheckDuplicate, key.name, key.recordType,
+					fmt.Sprintf("records %d and %d serve the same %s data", first.ID, record.ID, key.recordType),
+					[]*models.DNSRecord{first, record})
+				continue
+			}
+			seen[data] = record
+		}
+	}
+}
+
+// duplicateTarget is record's target as compared for duplicates: host
+// names ignore case and a trailing dot
+func duplicateTarget(record *models.DNSRecord) string {
+	switch models.RecordType(strings.ToUpper(record.RecordType)) {
+	case models.RecordTypeCNAME, models.RecordTypeNS, models.RecordTypeMX, models.RecordTypeSRV, models.RecordTypePTR, models.RecordTypeDNAME:
+		return models.NormalizeDomainName(record.Target)
+	}
+	return record.Target
+}
+
+// checkTTLs flags RRsets whose records don't share one TTL; resolvers
+// treat an RRset as a unit with a single TTL
+func (c *checker) checkTTLs() {
+	for key, records := range c.rrsets {
+		var ttls []uint32
+		for _, record := range records {
+			if !slices.Contains(ttls, record.TTL) {
+				ttls = append(ttls, record.TTL)
+			}
+		}
+		if len(ttls) < 2 {
+			continue
+		}
+
+		slices.Sort(ttls)
+		values := make([]string, len(ttls))
+		for i, ttl := range ttls {
+			values[i] = fmt.Sprintf("%ds", ttl)
+		}
+		c.add(SeverityWarning, CheckTTL, key.name, key.recordType,
+			fmt.Sprintf("%s %s records have different TTLs (%s)", key.name, key.recordType, strings.Join(values, ", ")), records)
+	}
+}
@@ -5,7 +5,7 @@
 CREATE TABLE IF NOT EXISTS dns_records (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,           -- Domain name (e.g., "example.com", "www.example.com")
-    record_type VARCHAR(10) NOT NULL,     -- DNS record type (A, AAAA, CNAME, TXT, MX, NS)
+    record_type VARCHAR(10) NOT NULL,     -- DNS record type (A, AAAA, CNAME, TXT, MX, NS, or generic TYPEnnn)
     target TEXT NOT NULL,                 -- Target value (IP address, domain name, text, etc.)
     ttl INTEGER NOT NULL DEFAULT 300,     -- Time to live in seconds
     priority INTEGER NOT NULL DEFAULT 0,  -- Priority for MX records, general priority for others
@@ -20,15 +20,44 @@ CREATE TABLE IF NOT EXISTS dns_records (
     weight INTEGER DEFAULT NULL,
     port SMALLINT DEFAULT NULL,
     tag TEXT DEFAULT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE DEFAULT NULL, -- Record is hidden and later deleted after this time
+    not_before TIMESTAMP WITH TIME ZONE DEFAULT NULL, -- Record is not served before this time
+    not_after TIMESTAMP WITH TIME ZONE DEFAULT NULL,  -- Record is not served from this time on (but kept)
+    labels JSONB NOT NULL DEFAULT '{}',   -- Operator key/value labels (e.g. {"team": "payments"})
+    comment TEXT DEFAULT NULL,            -- Free-text note on why the record exists
+    owner TEXT DEFAULT NULL,              -- Person or team responsible for the record
+    external_key TEXT DEFAULT NULL,       -- Identifier set by the tool managing the record, for upserts
+    version INTEGER NOT NULL DEFAULT 1,   -- Incremented on every update, for optimistic concurrency
+    etld TEXT DEFAULT NULL,               -- Public suffix of name (e.g. "com", "co.uk")
+    apex_domain TEXT DEFAULT NULL,        -- Registrable domain of name (e.g. "example.com")
+    subdomain_labels TEXT[] DEFAULT NULL, -- Labels of name before apex_domain, leftmost first
+    is_wildcard BOOLEAN NOT NULL DEFAULT FALSE,
+    wildcard_mask BIGINT NOT NULL DEFAULT 0, -- Bit N set when subdomain label N is "*"
     
     -- Constraints
     CONSTRAINT dns_records_ttl_check CHECK (ttl >= 0 AND ttl <= 2147483647),
     CONSTRAINT dns_records_priority_check CHECK (priority >= 0),
     CONSTRAINT dns_records_name_check CHECK (LENGTH(name) > 0),
     CONSTRAINT dns_records_target_check CHECK (LENGTH(target) > 0),
-    CONSTRAINT dns_records_type_check CHECK (record_type IN ('A', 'AAAA', 'CNAME', 'TXT', 'MX', 'NS', 'SOA', 'PTR', 'SRV', 'CAA'))
+    CONSTRAINT dns_records_window_check CHECK (not_before IS NULL OR not_after IS NULL OR not_before < not_after),
+    CONSTRAINT dns_records_type_check CHECK (record_type IN ('A', 'AAAA', 'CNAME', 'TXT', 'MX', 'NS', 'SOA', 'PTR', 'SRV', 'CAA', 'DS', 'DNSKEY', 'CDS', 'CDNSKEY', 'LOC', 'HINFO', 'DNAME') OR record_type ~ '^TYPE[1-9][0-9]{0,4}$')
 );
 
+-- Add columns to tables created before they existed
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS not_before TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS not_after TIMESTAMP WITH TIME ZONE DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS comment TEXT DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS owner TEXT DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS external_key TEXT DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS etld TEXT DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS apex_domain TEXT DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS subdomain_labels TEXT[] DEFAULT NULL;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS is_wildcard BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE dns_records ADD COLUMN IF NOT EXISTS wildcard_mask BIGINT NOT NULL DEFAULT 0;
+
 -- Create indexes for performance
 -- Primary lookup index: name + record_type (case-insensitive name)
 CREATE INDEX IF NOT EXISTS idx_dns_records_name_type 
@@ -53,11 +82,122 @@ CREATE INDEX IF NOT EXISTS idx_dns_records_created_at
 CREATE INDEX IF NOT EXISTS idx_dns_records_updated_at 
     ON dns_records(updated_at);
 
+-- Index for the expired record reaper
+CREATE INDEX IF NOT EXISTS idx_dns_records_expires_at 
+    ON dns_records(expires_at) 
+    WHERE expires_at IS NOT NULL;
+
+-- Indexes for the activation boundary scheduler
+CREATE INDEX IF NOT EXISTS idx_dns_records_not_before 
+    ON dns_records(not_before) 
+    WHERE not_before IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_dns_records_not_after 
+    ON dns_records(not_after) 
+    WHERE not_after IS NOT NULL;
+
+-- Indexes for management filtering by label and owner
+CREATE INDEX IF NOT EXISTS idx_dns_records_labels 
+    ON dns_records USING GIN (labels);
+
+CREATE INDEX IF NOT EXISTS idx_dns_records_owner 
+    ON dns_records(owner) 
+    WHERE owner IS NOT NULL;
+
+-- Index for upserts by external key. Not unique, as unique indexes on a
+-- partitioned table must include the partition key; the server keeps
+-- keys unique instead
+CREATE INDEX IF NOT EXISTS idx_dns_records_external_key 
+    ON dns_records(external_key) 
+    WHERE external_key IS NOT NULL;
+
+-- Index for lookups filtered on apex domain first, once the server has
+-- filled in domain columns for every record at startup
+CREATE INDEX IF NOT EXISTS idx_dns_records_apex_type 
+    ON dns_records(apex_domain, record_type, LOWER(name), priority) 
+    WHERE apex_domain IS NOT NULL;
+
+-- Index for rows inserted with plain SQL, which have no apex domain until
+-- the server's next startup fills it in; lookups match them on name alone
+CREATE INDEX IF NOT EXISTS idx_dns_records_no_apex 
+    ON dns_records(LOWER(name), record_type) 
+    WHERE apex_domain IS NULL OR apex_domain = '';
+
+-- Index for wildcard candidate lookups by apex domain and depth
+CREATE INDEX IF NOT EXISTS idx_dns_records_wildcard 
+    ON dns_records(apex_domain, record_type, cardinality(subdomain_labels)) 
+    WHERE is_wildcard;
+
+-- Index for synthesized reverse (PTR) lookups by address
+CREATE INDEX IF NOT EXISTS idx_dns_records_address 
+    ON dns_records(target) 
+    WHERE record_type IN ('A', 'AAAA');
+
+-- Logical record identity: one row per name/type/target/priority (plus
+-- port for SRV and tag for CAA). target is hashed since TXT values can
+-- exceed the btree row limit. Duplicates left by earlier imports are
+-- reported and keep the index from being built; nothing is deleted here.
+-- Remove them by hand, or start the server once with DB_AUTO_MIGRATE and
+-- DB_REMOVE_DUPLICATES to delete all but the oldest of each and build it
+DO $$
+DECLARE
+    duplicates BIGINT;
+BEGIN
+    IF to_regclass('idx_dns_records_unique') IS NOT NULL THEN
+        RETURN;
+    END IF;
+
+    SELECT COALESCE(SUM(copies - 1), 0) INTO duplicates
+    FROM (
+        SELECT COUNT(*) AS copies
+        FROM dns_records
+        GROUP BY LOWER(name), record_type, target, priority, COALESCE(port, 0), COALESCE(tag, '')
+        HAVING COUNT(*) > 1
+    ) groups;
+
+    IF duplicates > 0 THEN
+        RAISE WARNING 'dns_records has % duplicate rows; idx_dns_records_unique was not created', duplicates;
+        RETURN;
+    END IF;
+
+    CREATE UNIQUE INDEX idx_dns_records_unique 
+        ON dns_records(LOWER(name), record_type, md5(target), priority, COALESCE(port, 0), COALESCE(tag, ''));
+END;
+$$;
+
 -- Index for CAA records
 CREATE INDEX IF NOT EXISTS idx_dns_records_caa_tag 
     ON dns_records(LOWER(name), record_type, tag) 
     WHERE record_type = 'CAA';
 
+-- Aggregated query counts written periodically by the stats flusher
+CREATE TABLE IF NOT EXISTS dns_query_stats (
+    id BIGSERIAL PRIMARY KEY,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    zone VARCHAR(255) NOT NULL,           -- Zone with an SOA the queries fell under, or (other)
+    record_type VARCHAR(10) NOT NULL,     -- Query type (A, AAAA, MX, ...)
+    queries BIGINT NOT NULL DEFAULT 0,
+    answered BIGINT NOT NULL DEFAULT 0,
+    nxdomain BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0
+);
+
+-- Reporting index: per-zone time ranges
+CREATE INDEX IF NOT EXISTS idx_dns_query_stats_zone_period 
+    ON dns_query_stats(zone, period_start);
+
+CREATE INDEX IF NOT EXISTS idx_dns_query_stats_period 
+    ON dns_query_stats(period_start);
+
+-- Custom public suffix rules ("dev.corp", "*.corp", "!www.corp") merged
+-- with the compiled-in Public Suffix List when SUFFIX_LIST_DATABASE is set
+CREATE TABLE IF NOT EXISTS public_suffixes (
+    rule VARCHAR(255) PRIMARY KEY,
+    comment TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- Function to automatically update the updated_at timestamp
 CREATE OR REPLACE FUNCTION update_dns_records_updated_at()
 RETURNS TRIGGER AS $$
@@ -97,6 +237,9 @@ INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
     -- TXT records
     ('test.internal', 'TXT', 'v=spf1 include:_spf.test.internal ~all', 300, 10),
     ('_dmarc.test.internal', 'TXT', 'v=DMARC1; p=none; ruf=mailto:dmarc@test.internal', 300, 10),
+    -- 260 octets with escapes at the 255-octet string boundary: the
+    -- answer splits it after \009, so the second string starts with \"
+    ('long-txt.test.internal', 'TXT', repeat('a', 252) || '\"\\\009\"tail', 300, 10),
     
     -- NS records
     ('test.internal', 'NS', 'ns1.test.internal', 86400, 10),
@@ -122,31 +265,57 @@ INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
     ('round-robin.internal', 'A', '10.0.3.12', 300, 10),
     ('round-robin.internal', 'A', '10.0.3.13', 300, 10),
     
-    -- Wildcard preparation domains (for future wildcard testing)
+    -- Names sharing one tied pool for testing round-robin through the
+    -- Redis cache. Round-robin offsets each name by a hash of it, and
+    -- rr-cache-3 lands on a different member than the other three
+    ('rr-cache-1.internal', 'A', '10.0.3.20', 300, 10),
+    ('rr-cache-1.internal', 'A', '10.0.3.21', 300, 10),
+    ('rr-cache-1.internal', 'A', '10.0.3.22', 300, 10),
+    ('rr-cache-1.internal', 'A', '10.0.3.23', 300, 10),
+    ('rr-cache-2.internal', 'A', '10.0.3.20', 300, 10),
+    ('rr-cache-2.internal', 'A', '10.0.3.21', 300, 10),
+    ('rr-cache-2.internal', 'A', '10.0.3.22', 300, 10),
+    ('rr-cache-2.internal', 'A', '10.0.3.23', 300, 10),
+    ('rr-cache-3.internal', 'A', '10.0.3.20', 300, 10),
+    ('rr-cache-3.internal', 'A', '10.0.3.21', 300, 10),
+    ('rr-cache-3.internal', 'A', '10.0.3.22', 300, 10),
+    ('rr-cache-3.internal', 'A', '10.0.3.23', 300, 10),
+    ('rr-cache-4.internal', 'A', '10.0.3.20', 300, 10),
+    ('rr-cache-4.internal', 'A', '10.0.3.21', 300, 10),
+    ('rr-cache-4.internal', 'A', '10.0.3.22', 300, 10),
+    ('rr-cache-4.internal', 'A', '10.0.3.23', 300, 10),
+    
+    -- Wildcard domains: other-name.wildcard-parent.internal answers from
+    -- the wildcard, while sub1 and sub2 keep their exact records
     ('wildcard-parent.internal', 'A', '10.0.4.10', 300, 10),
     ('sub1.wildcard-parent.internal', 'A', '10.0.4.11', 300, 10),
-    ('sub2.wildcard-parent.internal', 'A', '10.0.4.12', 300, 10)
+    ('sub2.wildcard-parent.internal', 'A', '10.0.4.12', 300, 10),
+    ('*.wildcard-parent.internal', 'A', '10.0.4.20', 300, 10)
 ON CONFLICT DO NOTHING;
 
 -- Add SOA record example:
 INSERT INTO dns_records (name, record_type, target, ttl, priority, mbox, serial, refresh, retry, expire, minttl) VALUES
-    ('test.internal', 'SOA', 'ns1.test.internal', 86400, 1, 'admin.test.internal', 2024062301, 7200, 3600, 604800, 300);
+    ('test.internal', 'SOA', 'ns1.test.internal', 86400, 1, 'admin.test.internal', 2024062301, 7200, 3600, 604800, 300)
+ON CONFLICT DO NOTHING;
 
 -- Add SRV record examples:
 INSERT INTO dns_records (name, record_type, target, ttl, priority, weight, port) VALUES
     ('_http._tcp.test.internal', 'SRV', 'web1.test.internal', 300, 10, 5, 80),
-    ('_http._tcp.test.internal', 'SRV', 'web2.test.internal', 300, 10, 5, 80);
+    ('_http._tcp.test.internal', 'SRV', 'web2.test.internal', 300, 10, 5, 80)
+ON CONFLICT DO NOTHING;
 
 -- Add PTR record example:
 INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
-    ('10.0.0.10.in-addr.arpa', 'PTR', 'test.internal', 300, 10);
+    ('10.0.0.10.in-addr.arpa', 'PTR', 'test.internal', 300, 10)
+ON CONFLICT DO NOTHING;
 
 -- Additional PTR records for reverse DNS
 INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
 ('20.0.0.10.in-addr.arpa', 'PTR', 'mail.test.internal', 300, 10),
 ('30.0.0.10.in-addr.arpa', 'PTR', 'api.test.internal', 300, 10),
 -- IPv6 PTR record (fd00::1 reverse)
-('1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa', 'PTR', 'test.internal', 300, 10);
+('1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa', 'PTR', 'test.internal', 300, 10)
+ON CONFLICT DO NOTHING;
 
 -- Additional SRV records for service discovery
 INSERT INTO dns_records (name, record_type, target, ttl, priority, weight, port) VALUES
@@ -167,7 +336,8 @@ INSERT INTO dns_records (name, record_type, target, ttl, priority, weight, port)
 -- Multiple SRV with same priority but different weights (for weight testing)
 ('_cluster._tcp.test.internal', 'SRV', 'node1.test.internal', 300, 10, 10, 8080),
 ('_cluster._tcp.test.internal', 'SRV', 'node2.test.internal', 300, 10, 20, 8080),
-('_cluster._tcp.test.internal', 'SRV', 'node3.test.internal', 300, 10, 30, 8080);
+('_cluster._tcp.test.internal', 'SRV', 'node3.test.internal', 300, 10, 30, 8080)
+ON CONFLICT DO NOTHING;
 
 -- Additional A records needed for SRV targets
 INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
@@ -178,13 +348,15 @@ INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
 ('web3.test.internal', 'A', '10.0.5.30', 300, 10),
 ('node1.test.internal', 'A', '10.0.6.10', 300, 10),
 ('node2.test.internal', 'A', '10.0.6.20', 300, 10),
-('node3.test.internal', 'A', '10.0.6.30', 300, 10);
+('node3.test.internal', 'A', '10.0.6.30', 300, 10)
+ON CONFLICT DO NOTHING;
 
 -- Additional NS records for the hosts that need A records
 INSERT INTO dns_records (name, record_type, target, ttl, priority) VALUES
 ('ns1.test.internal', 'A', '10.0.0.100', 86400, 10),
 ('ns2.test.internal', 'A', '10.0.0.101', 86400, 10),
-('mail2.test.internal', 'A', '10.0.0.21', 300, 10);
+('mail2.test.internal', 'A', '10.0.0.21', 300, 10)
+ON CONFLICT DO NOTHING;
 
 
 -- Add sample CAA records for testing
@@ -246,7 +418,14 @@ SELECT
             END
         ELSE NULL
     END as caa_flag_description,
-    EXTRACT(EPOCH FROM (updated_at - created_at)) as age_seconds
+    EXTRACT(EPOCH FROM (updated_at - created_at)) as age_seconds,
+    expires_at,
+    not_before,
+    not_after,
+    labels,
+    comment,
+    owner,
+    external_key
 FROM dns_records
 ORDER BY name, record_type, priority DESC;
 
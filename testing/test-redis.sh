@@ -77,6 +77,45 @@ echo ""
 echo "Test completed. Check the timing differences above."
 echo "Cached queries should be significantly faster."
 
+# Test that tie-breaking still rotates answers when they come from Redis.
+# Round-robin only moves on every few seconds, so rather than waiting it
+# out this queries names sharing one tied pool, which it offsets by a hash
+# of each name (see rr-cache-*.internal in schemas/postgresql.sql)
+echo ""
+echo "Testing round-robin through the Redis cache..."
+RR_NAMES="rr-cache-1.internal rr-cache-2.internal rr-cache-3.internal rr-cache-4.internal"
+RR_QUERIES=5
+
+# Warm the cache so every later answer is a cache hit
+for name in $RR_NAMES; do
+    dig @$DNS_SERVER -p $DNS_PORT +short $name A > /dev/null
+    if ! redis-cli -h $REDIS_HOST -p $REDIS_PORT --scan --pattern "errantdns:*$name:A" | grep -q .; then
+        echo "✗ $name A was not cached in Redis"
+        RR_FAILED=1
+    fi
+done
+
+declare -A RR_SEEN
+for name in $RR_NAMES; do
+    for i in $(seq $RR_QUERIES); do
+        RR_RESULT=$(dig @$DNS_SERVER -p $DNS_PORT +short $name A | head -1)
+        if [ -n "$RR_RESULT" ]; then
+            RR_SEEN["$RR_RESULT"]=1
+        fi
+    done
+done
+
+if [ "${#RR_SEEN[@]}" -gt 1 ]; then
+    echo "✓ Round-robin spread cached answers across ${#RR_SEEN[@]} IPs: ${!RR_SEEN[*]}"
+else
+    echo "✗ Round-robin returned the same IP for every name: ${!RR_SEEN[*]}"
+    RR_FAILED=1
+fi
+
 # Clean up test cache entries
 redis-cli -h $REDIS_HOST -p $REDIS_PORT --scan --pattern "errantdns:*" | xargs -r redis-cli -h $REDIS_HOST -p $REDIS_PORT del > /dev/null 2>&1
 echo "✓ Test cache entries cleaned up"
+
+if [ "${RR_FAILED:-0}" -eq 1 ]; then
+    exit 1
+fi
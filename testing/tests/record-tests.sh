@@ -16,6 +16,7 @@ run_record_tests() {
     # TXT Record Tests
     run_test "SPF Record" "test.internal" "TXT" "spf1" "Should contain SPF record"
     run_test "DMARC Record" "_dmarc.test.internal" "TXT" "DMARC1" "Should contain DMARC policy"
+    run_custom_test "Long TXT Record" "test_long_txt_split" "TXT over 255 octets should split into strings without breaking escapes"
     
     # NS Record Tests
     run_test "NS Records" "test.internal" "NS" "ns" "Should show nameservers"
@@ -48,3 +49,32 @@ run_record_tests() {
     # Priority Tests
     run_test "Priority Test" "priority-test.internal" "A" "10.0.2.2" "Should return priority 10 records (not priority 20)"
 }
+
+test_long_txt_split() {
+    # Matches long-txt.test.internal in schemas/postgresql.sql. The escapes
+    # are printed by dig exactly as they're stored
+    local stored
+    stored="$(printf 'a%.0s' {1..252})"'\"\\\009\"tail'
+
+    echo "  Query: long-txt.test.internal TXT"
+    echo "  Expected: several strings joining to the stored ${#stored}-character value"
+
+    local result
+    result=$(dig @"$DNS_SERVER" -p "$DNS_PORT" +short +time="$DNS_TIMEOUT" long-txt.test.internal TXT 2>/dev/null)
+
+    # Each string is quoted, with any quote or backslash in it escaped
+    local strings
+    readarray -t strings < <(echo "$result" | grep -oE '"([^"\\]|\\.)*"' | sed -e 's/^"//' -e 's/"$//')
+
+    local joined
+    joined=$(printf '%s' "${strings[@]}")
+
+    if [ ${#strings[@]} -gt 1 ] && [ "$joined" = "$stored" ]; then
+        echo "  ✓ Got ${#strings[@]} strings joining to the stored value"
+        return 0
+    else
+        echo "  ✗ Expected several strings joining to the stored value"
+        echo "  Result: $result"
+        return 1
+    fi
+}